@@ -78,6 +78,19 @@ build(){
             fi;
                 CGO_CFLAGS=-I/tmp/tensorflow CGO_LDFLAGS=-L$(pwd)/extensions/functions/tfLite/lib go build -trimpath --buildmode=plugin -o extensions/functions/tfLite/tfLite@$VERSION.so extensions/functions/tfLite/*.go
             ;;
+        onnx )
+            ONNXRUNTIME_VERSION=1.17.1
+            if [ "$(uname -m)" = "x86_64" ]; then
+                ONNXRUNTIME_ARCH=x64
+            fi;
+            if [ "$(uname -m)" = "aarch64" ]; then
+                ONNXRUNTIME_ARCH=aarch64
+            fi;
+            wget "https://github.com/microsoft/onnxruntime/releases/download/v$ONNXRUNTIME_VERSION/onnxruntime-linux-$ONNXRUNTIME_ARCH-$ONNXRUNTIME_VERSION.tgz" -O /tmp/onnxruntime.tgz;
+            tar -zxvf /tmp/onnxruntime.tgz -C /tmp
+            cp -P /tmp/onnxruntime-linux-$ONNXRUNTIME_ARCH-$ONNXRUNTIME_VERSION/lib/libonnxruntime.so* $(pwd)/extensions/functions/onnx/lib
+            go build -trimpath --buildmode=plugin -o extensions/functions/onnx/onnx@$VERSION.so extensions/functions/onnx/*.go
+            ;;
         * )
             go build -trimpath --buildmode=plugin -o extensions/$PLUGIN_TYPE/$PLUGIN_NAME/$PLUGIN_NAME@$VERSION.so extensions/$PLUGIN_TYPE/$PLUGIN_NAME/*.go
           ;;
@@ -150,7 +150,7 @@ func main() {
 		{
 			Name:    "create",
 			Aliases: []string{"create"},
-			Usage:   "create stream $stream_name | create stream $stream_name -f $stream_def_file | create table $table_name | create table $table_name -f $table_def_file| create rule $rule_name $rule_json | create rule $rule_name -f $rule_def_file | create plugin $plugin_type $plugin_name $plugin_json | create plugin $plugin_type $plugin_name -f $plugin_def_file | create service $service_name $service_json | create schema $schema_type $schema_name $schema_json",
+			Usage:   "create stream $stream_name | create stream $stream_name -f $stream_def_file | create table $table_name | create table $table_name -f $table_def_file| create rule $rule_name $rule_json | create rule $rule_name -f $rule_def_file | create pipeline $pipeline_name $pipeline_json | create pipeline $pipeline_name -f $pipeline_def_file | create plugin $plugin_type $plugin_name $plugin_json | create plugin $plugin_type $plugin_name -f $plugin_def_file | create service $service_name $service_json | create schema $schema_type $schema_name $schema_json",
 
 			Subcommands: []cli.Command{
 				{
@@ -258,6 +258,48 @@ func main() {
 						}
 					},
 				},
+				{
+					Name:  "pipeline",
+					Usage: "create pipeline $pipeline_name [$pipeline_json | -f pipeline_def_file]",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "file, f",
+							Usage:    "the location of pipeline definition file",
+							FilePath: "/home/mypipeline.txt",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if len(c.Args()) < 1 {
+							fmt.Printf("Expect pipeline name.\n")
+							return nil
+						}
+						pname := c.Args()[0]
+						var pjson string
+						if sfile := c.String("file"); sfile != "" {
+							pipeline, err := readDef(sfile, "pipeline")
+							if err != nil {
+								fmt.Printf("%s", err)
+								return nil
+							}
+							pjson = string(pipeline)
+						} else {
+							if len(c.Args()) != 2 {
+								fmt.Printf("Expect pipeline name and json.\nBut found %d args:%s.\n", len(c.Args()), c.Args())
+								return nil
+							}
+							pjson = c.Args()[1]
+						}
+						var reply string
+						args := &model.RPCArgDesc{Name: pname, Json: pjson}
+						err = client.Call("Server.CreatePipeline", args, &reply)
+						if err != nil {
+							fmt.Println(err)
+						} else {
+							fmt.Println(reply)
+						}
+						return nil
+					},
+				},
 				{
 					Name:  "plugin",
 					Usage: "create plugin $plugin_type $plugin_name [$plugin_json | -f plugin_def_file]",
@@ -362,7 +404,7 @@ func main() {
 		{
 			Name:    "describe",
 			Aliases: []string{"describe"},
-			Usage:   "describe stream $stream_name | describe table $table_name | describe rule $rule_name | describe plugin $plugin_type $plugin_name | describe udf $udf_name | describe service $service_name | describe service_func $service_func_name | describe schema $schema_type $schema_name",
+			Usage:   "describe stream $stream_name | describe table $table_name | describe rule $rule_name | describe pipeline $pipeline_name | describe plugin $plugin_type $plugin_name | describe udf $udf_name | describe service $service_name | describe service_func $service_func_name | describe schema $schema_type $schema_name",
 			Subcommands: []cli.Command{
 				{
 					Name:  "stream",
@@ -401,6 +443,25 @@ func main() {
 						return nil
 					},
 				},
+				{
+					Name:  "pipeline",
+					Usage: "describe pipeline $pipeline_name",
+					Action: func(c *cli.Context) error {
+						if len(c.Args()) != 1 {
+							fmt.Printf("Expect pipeline name.\n")
+							return nil
+						}
+						pname := c.Args()[0]
+						var reply string
+						err = client.Call("Server.DescPipeline", pname, &reply)
+						if err != nil {
+							fmt.Println(err)
+						} else {
+							fmt.Println(reply)
+						}
+						return nil
+					},
+				},
 				{
 					Name:  "plugin",
 					Usage: "describe plugin $plugin_type $plugin_name",
@@ -519,7 +580,7 @@ func main() {
 		{
 			Name:    "drop",
 			Aliases: []string{"drop"},
-			Usage:   "drop stream $stream_name | drop table $table_name |drop rule $rule_name | drop plugin $plugin_type $plugin_name -s $stop | drop service $service_name | drop schema $schema_type $schema_name",
+			Usage:   "drop stream $stream_name | drop table $table_name |drop rule $rule_name | drop pipeline $pipeline_name | drop plugin $plugin_type $plugin_name -s $stop | drop service $service_name | drop schema $schema_type $schema_name",
 			Subcommands: []cli.Command{
 				{
 					Name:  "stream",
@@ -559,6 +620,25 @@ func main() {
 						return nil
 					},
 				},
+				{
+					Name:  "pipeline",
+					Usage: "drop pipeline $pipeline_name",
+					Action: func(c *cli.Context) error {
+						if len(c.Args()) != 1 {
+							fmt.Printf("Expect pipeline name.\n")
+							return nil
+						}
+						pname := c.Args()[0]
+						var reply string
+						err = client.Call("Server.DropPipeline", pname, &reply)
+						if err != nil {
+							fmt.Println(err)
+						} else {
+							fmt.Println(reply)
+						}
+						return nil
+					},
+				},
 				{
 					Name:  "plugin",
 					Usage: "drop plugin $plugin_type $plugin_name -s stop",
@@ -649,7 +729,7 @@ func main() {
 		{
 			Name:    "show",
 			Aliases: []string{"show"},
-			Usage:   "show streams | show tables | show rules | show plugins $plugin_type | show services | show service_funcs | show schemas $schema_type",
+			Usage:   "show streams | show tables | show rules | show pipelines | show plugins $plugin_type | show services | show service_funcs | show schemas $schema_type",
 
 			Subcommands: []cli.Command{
 				{
@@ -682,6 +762,20 @@ func main() {
 						return nil
 					},
 				},
+				{
+					Name:  "pipelines",
+					Usage: "show pipelines",
+					Action: func(c *cli.Context) error {
+						var reply string
+						err = client.Call("Server.ShowPipelines", 0, &reply)
+						if err != nil {
+							fmt.Println(err)
+						} else {
+							fmt.Println(reply)
+						}
+						return nil
+					},
+				},
 				{
 					Name:  "plugins",
 					Usage: "show plugins $plugin_type",
@@ -771,7 +865,7 @@ func main() {
 		{
 			Name:    "getstatus",
 			Aliases: []string{"getstatus"},
-			Usage:   "getstatus rule $rule_name | import",
+			Usage:   "getstatus rule $rule_name | getstatus pipeline $pipeline_name | import",
 			Subcommands: []cli.Command{
 				{
 					Name:  "rule",
@@ -793,6 +887,25 @@ func main() {
 						return nil
 					},
 				},
+				{
+					Name:  "pipeline",
+					Usage: "getstatus pipeline $pipeline_name",
+					Action: func(c *cli.Context) error {
+						if len(c.Args()) != 1 {
+							fmt.Printf("Expect pipeline name.\n")
+							return nil
+						}
+						pname := c.Args()[0]
+						var reply string
+						err = client.Call("Server.GetStatusPipeline", pname, &reply)
+						if err != nil {
+							fmt.Println(err)
+						} else {
+							fmt.Println(reply)
+						}
+						return nil
+					},
+				},
 				{
 					Name:  "import",
 					Usage: "getstatus import",
@@ -813,7 +926,7 @@ func main() {
 		{
 			Name:    "gettopo",
 			Aliases: []string{"gettopo"},
-			Usage:   "gettopo rule $rule_name",
+			Usage:   "gettopo rule $rule_name | gettopo pipeline $pipeline_name",
 			Subcommands: []cli.Command{
 				{
 					Name:  "rule",
@@ -835,12 +948,31 @@ func main() {
 						return nil
 					},
 				},
+				{
+					Name:  "pipeline",
+					Usage: "gettopo pipeline $pipeline_name",
+					Action: func(c *cli.Context) error {
+						if len(c.Args()) != 1 {
+							fmt.Printf("Expect pipeline name.\n")
+							return nil
+						}
+						pname := c.Args()[0]
+						var reply string
+						err = client.Call("Server.GetTopoPipeline", pname, &reply)
+						if err != nil {
+							fmt.Println(err)
+						} else {
+							fmt.Println(reply)
+						}
+						return nil
+					},
+				},
 			},
 		},
 		{
 			Name:    "start",
 			Aliases: []string{"start"},
-			Usage:   "start rule $rule_name",
+			Usage:   "start rule $rule_name | start pipeline $pipeline_name",
 			Subcommands: []cli.Command{
 				{
 					Name:  "rule",
@@ -862,12 +994,31 @@ func main() {
 						return nil
 					},
 				},
+				{
+					Name:  "pipeline",
+					Usage: "start pipeline $pipeline_name",
+					Action: func(c *cli.Context) error {
+						if len(c.Args()) != 1 {
+							fmt.Printf("Expect pipeline name.\n")
+							return nil
+						}
+						pname := c.Args()[0]
+						var reply string
+						err = client.Call("Server.StartPipeline", pname, &reply)
+						if err != nil {
+							fmt.Println(err)
+						} else {
+							fmt.Println(reply)
+						}
+						return nil
+					},
+				},
 			},
 		},
 		{
 			Name:    "stop",
 			Aliases: []string{"stop"},
-			Usage:   "stop rule $rule_name",
+			Usage:   "stop rule $rule_name | stop pipeline $pipeline_name",
 			Subcommands: []cli.Command{
 				{
 					Name:  "rule",
@@ -889,6 +1040,25 @@ func main() {
 						return nil
 					},
 				},
+				{
+					Name:  "pipeline",
+					Usage: "stop pipeline $pipeline_name",
+					Action: func(c *cli.Context) error {
+						if len(c.Args()) != 1 {
+							fmt.Printf("Expect pipeline name.\n")
+							return nil
+						}
+						pname := c.Args()[0]
+						var reply string
+						err = client.Call("Server.StopPipeline", pname, &reply)
+						if err != nil {
+							fmt.Println(err)
+						} else {
+							fmt.Println(reply)
+						}
+						return nil
+					},
+				},
 			},
 		},
 		{
@@ -918,6 +1088,30 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:    "test",
+			Aliases: []string{"test"},
+			Usage:   "test $test_file",
+			Action: func(c *cli.Context) error {
+				if len(c.Args()) != 1 {
+					fmt.Printf("Expect rule test file.\n")
+					return nil
+				}
+				content, err := os.ReadFile(c.Args()[0])
+				if err != nil {
+					fmt.Printf("Failed to read from rule test file %s.\n", c.Args()[0])
+					return nil
+				}
+				var reply string
+				err = client.Call("Server.TestRule", string(content), &reply)
+				if err != nil {
+					fmt.Println(err)
+				} else {
+					fmt.Println(reply)
+				}
+				return nil
+			},
+		},
 		{
 			Name:    "register",
 			Aliases: []string{"register"},
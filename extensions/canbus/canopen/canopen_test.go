@@ -0,0 +1,140 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canopen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testEds = `[FileInfo]
+FileName=drive.eds
+Description=Test drive
+
+[1800sub1]
+ParameterName=COB-ID used by TPDO1
+DataType=0x0007
+DefaultValue=$NODEID+0x180
+
+[1801sub1]
+ParameterName=COB-ID used by TPDO2
+DataType=0x0007
+DefaultValue=0x80000280
+
+[1A00]
+ParameterName=TPDO1 mapping parameter
+SubNumber=3
+
+[1A00sub0]
+ParameterName=Number of mapped objects
+DataType=0x0005
+DefaultValue=3
+
+[1A00sub1]
+ParameterName=Mapped object 1
+DataType=0x0007
+DefaultValue=0x60410010
+
+[1A00sub2]
+ParameterName=Mapped object 2
+DataType=0x0007
+DefaultValue=0x60640020
+
+[1A00sub3]
+ParameterName=Mapped object 3
+DataType=0x0007
+DefaultValue=0x20000108
+
+[1A01sub0]
+ParameterName=Number of mapped objects
+DataType=0x0005
+DefaultValue=1
+
+[1A01sub1]
+ParameterName=Mapped object 1
+DataType=0x0007
+DefaultValue=0x60410010
+
+[6041]
+ParameterName=Statusword
+DataType=0x0006
+
+[6064]
+ParameterName=Position actual value
+DataType=0x0004
+
+[2000sub1]
+ParameterName=Temperature
+DataType=0x0002
+`
+
+func TestParseEDS(t *testing.T) {
+	d, err := ParseEDS(strings.NewReader(testEds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, ok := d.Object(0x6064, 0)
+	if !ok {
+		t.Fatal("object 6064 not found")
+	}
+	exp := &Object{Index: 0x6064, Name: "Position actual value", DataType: 0x04}
+	if !reflect.DeepEqual(exp, o) {
+		t.Errorf("object mismatch\nexp\t%+v\ngot\t%+v", exp, o)
+	}
+	o, ok = d.Object(0x1800, 1)
+	if !ok || o.DefaultValue != "$NODEID+0x180" {
+		t.Errorf("object 1800sub1 mismatch %+v", o)
+	}
+}
+
+func TestTPDOMessages(t *testing.T) {
+	d, err := ParseEDS(strings.NewReader(testEds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := d.TPDOMessages(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// TPDO2 is disabled
+	if len(msgs) != 1 {
+		t.Fatalf("expect 1 message but got %d", len(msgs))
+	}
+	m := msgs[0]
+	if m.ID != 0x185 || m.Name != "Node5_TPDO1" || m.Length != 7 {
+		t.Errorf("message mismatch %+v", m)
+	}
+	got := m.Decode([]byte{0x37, 0x02, 0x18, 0xFC, 0xFF, 0xFF, 0xF6})
+	exp := map[string]interface{}{
+		"Statusword":            int64(0x0237),
+		"Position_actual_value": int64(-1000),
+		"Temperature":           int64(-10),
+	}
+	if !reflect.DeepEqual(exp, got) {
+		t.Errorf("decode mismatch\nexp\t%v\ngot\t%v", exp, got)
+	}
+}
+
+func TestTPDOMessagesError(t *testing.T) {
+	d, err := ParseEDS(strings.NewReader("[1A00sub0]\nDefaultValue=1\n[1A00sub1]\nDefaultValue=0x60410010\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.TPDOMessages(1)
+	if err == nil || err.Error() != "TPDO1 mapping entry 1: object 6041sub0 is not defined" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
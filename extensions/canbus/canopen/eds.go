@@ -0,0 +1,152 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package canopen imports the object dictionary from CANopen EDS files and maps the PDOs into DBC messages.
+package canopen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Object is an entry of the object dictionary
+type Object struct {
+	Index        uint16
+	SubIndex     uint8
+	Name         string
+	DataType     uint16
+	DefaultValue string
+}
+
+// Dictionary is the object dictionary read from an EDS file
+type Dictionary struct {
+	objects map[uint32]*Object
+}
+
+func key(index uint16, subIndex uint8) uint32 {
+	return uint32(index)<<8 | uint32(subIndex)
+}
+
+// Object finds the object by index and sub-index
+func (d *Dictionary) Object(index uint16, subIndex uint8) (*Object, bool) {
+	o, ok := d.objects[key(index, subIndex)]
+	return o, ok
+}
+
+// ParseEDSFile reads and parses the EDS file in the path
+func ParseEDSFile(path string) (*Dictionary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open eds file %s: %v", path, err)
+	}
+	defer f.Close()
+	return ParseEDS(f)
+}
+
+// ParseEDS parses the object sections of an EDS file which is in ini format. The section name is the hex index like
+// [6000] or the index with sub-index like [6000sub1].
+func ParseEDS(r io.Reader) (*Dictionary, error) {
+	d := &Dictionary{objects: make(map[uint32]*Object)}
+	var current *Object
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = nil
+			if o, ok := parseSection(line[1 : len(line)-1]); ok {
+				current = o
+				d.objects[key(o.Index, o.SubIndex)] = o
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		k, v, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid eds entry at line %d: %s", lineNo, line)
+		}
+		v = strings.TrimSpace(v)
+		switch strings.ToLower(strings.TrimSpace(k)) {
+		case "parametername":
+			current.Name = v
+		case "datatype":
+			dt, err := parseUint(v, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid data type %s at line %d", v, lineNo)
+			}
+			current.DataType = uint16(dt)
+		case "defaultvalue":
+			current.DefaultValue = v
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("fail to read eds: %v", err)
+	}
+	return d, nil
+}
+
+// parseSection parses the object section name, other sections like [FileInfo] are ignored
+func parseSection(name string) (*Object, bool) {
+	lower := strings.ToLower(name)
+	idx, sub, hasSub := strings.Cut(lower, "sub")
+	if len(idx) != 4 {
+		return nil, false
+	}
+	index, err := strconv.ParseUint(idx, 16, 16)
+	if err != nil {
+		return nil, false
+	}
+	o := &Object{Index: uint16(index)}
+	if hasSub {
+		s, err := strconv.ParseUint(sub, 16, 8)
+		if err != nil {
+			return nil, false
+		}
+		o.SubIndex = uint8(s)
+	}
+	return o, true
+}
+
+// parseUint parses the EDS number which can be hex with 0x prefix, octal with 0 prefix or decimal
+func parseUint(v string, bitSize int) (uint64, error) {
+	return strconv.ParseUint(strings.TrimSpace(v), 0, bitSize)
+}
+
+// evalValue evaluates the EDS default value which may refer to the node id like $NODEID+0x180
+func evalValue(v string, nodeId uint8) (uint64, error) {
+	var result uint64
+	for _, part := range strings.Split(v, "+") {
+		part = strings.TrimSpace(part)
+		if strings.EqualFold(part, "$NODEID") {
+			result += uint64(nodeId)
+			continue
+		}
+		n, err := parseUint(part, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %s", v)
+		}
+		result += n
+	}
+	return result, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package canopen
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/lf-edge/ekuiper/extensions/canbus/dbc"
+)
+
+// The object indexes of the transmit PDO communication and mapping parameters
+const (
+	tpdoCommIndex    uint16 = 0x1800
+	tpdoMappingIndex uint16 = 0x1A00
+	// MaxPDO is the number of default PDOs of a node
+	MaxPDO = 4
+	// cobIdInvalid bit in the PDO COB-ID means the PDO is disabled
+	cobIdInvalid uint64 = 0x80000000
+)
+
+// CANopen data types defined in CiA 301
+const (
+	typeBoolean    = 0x01
+	typeInteger8   = 0x02
+	typeInteger16  = 0x03
+	typeInteger32  = 0x04
+	typeUnsigned8  = 0x05
+	typeUnsigned16 = 0x06
+	typeUnsigned32 = 0x07
+	typeReal32     = 0x08
+	typeReal64     = 0x11
+	typeInteger24  = 0x10
+	typeInteger64  = 0x15
+	typeUnsigned24 = 0x16
+	typeUnsigned64 = 0x1B
+)
+
+var nameRegex = regexp.MustCompile(`\W+`)
+
+// TPDOMessages builds the message definitions of the transmit PDOs of the node from the object dictionary.
+// The PDOs which are disabled or have no mapping are skipped. The signal names are the parameter names of the mapped
+// objects with the non-word characters replaced by underscore.
+func (d *Dictionary) TPDOMessages(nodeId uint8) ([]*dbc.Message, error) {
+	var result []*dbc.Message
+	for i := 0; i < MaxPDO; i++ {
+		cobId := uint64(0x180+0x100*i) + uint64(nodeId)
+		if o, ok := d.Object(tpdoCommIndex+uint16(i), 1); ok && o.DefaultValue != "" {
+			v, err := evalValue(o.DefaultValue, nodeId)
+			if err != nil {
+				return nil, fmt.Errorf("TPDO%d cob-id: %v", i+1, err)
+			}
+			cobId = v
+		}
+		if cobId&cobIdInvalid != 0 {
+			continue
+		}
+		msg := &dbc.Message{
+			ID:   uint32(cobId & 0x7FF),
+			Name: fmt.Sprintf("Node%d_TPDO%d", nodeId, i+1),
+		}
+		mapIndex := tpdoMappingIndex + uint16(i)
+		count, ok := d.Object(mapIndex, 0)
+		if !ok || count.DefaultValue == "" {
+			continue
+		}
+		n, err := evalValue(count.DefaultValue, nodeId)
+		if err != nil {
+			return nil, fmt.Errorf("TPDO%d mapping count: %v", i+1, err)
+		}
+		bit := 0
+		for sub := 1; sub <= int(n); sub++ {
+			entry, ok := d.Object(mapIndex, uint8(sub))
+			if !ok {
+				return nil, fmt.Errorf("TPDO%d mapping entry %d is not defined", i+1, sub)
+			}
+			v, err := evalValue(entry.DefaultValue, nodeId)
+			if err != nil {
+				return nil, fmt.Errorf("TPDO%d mapping entry %d: %v", i+1, sub, err)
+			}
+			s, err := d.mappedSignal(v, bit)
+			if err != nil {
+				return nil, fmt.Errorf("TPDO%d mapping entry %d: %v", i+1, sub, err)
+			}
+			msg.Signals = append(msg.Signals, s)
+			bit += s.Length
+		}
+		if bit > 64 {
+			return nil, fmt.Errorf("TPDO%d mapping length %d exceeds 64 bits", i+1, bit)
+		}
+		if len(msg.Signals) == 0 {
+			continue
+		}
+		msg.Length = (bit + 7) / 8
+		result = append(result, msg)
+	}
+	return result, nil
+}
+
+// mappedSignal converts the mapping entry which is index(16) sub-index(8) length(8) into a little endian signal
+func (d *Dictionary) mappedSignal(mapping uint64, start int) (*dbc.Signal, error) {
+	index := uint16(mapping >> 16)
+	subIndex := uint8(mapping >> 8)
+	length := int(mapping & 0xFF)
+	if length == 0 {
+		return nil, fmt.Errorf("object %04Xsub%X has zero length", index, subIndex)
+	}
+	o, ok := d.Object(index, subIndex)
+	if !ok {
+		return nil, fmt.Errorf("object %04Xsub%X is not defined", index, subIndex)
+	}
+	s := &dbc.Signal{
+		Name:      nameRegex.ReplaceAllString(o.Name, "_"),
+		StartBit:  start,
+		Length:    length,
+		ByteOrder: dbc.LittleEndian,
+		Factor:    1,
+	}
+	if s.Name == "" {
+		s.Name = fmt.Sprintf("%04Xsub%X", index, subIndex)
+	}
+	switch o.DataType {
+	case typeInteger8, typeInteger16, typeInteger24, typeInteger32, typeInteger64:
+		s.Signed = true
+	case typeReal32:
+		s.ValueType = dbc.Float32
+	case typeReal64:
+		s.ValueType = dbc.Float64
+	case typeBoolean, typeUnsigned8, typeUnsigned16, typeUnsigned24, typeUnsigned32, typeUnsigned64:
+	default:
+		return nil, fmt.Errorf("object %04Xsub%X has unsupported data type %#x", index, subIndex, o.DataType)
+	}
+	return s, nil
+}
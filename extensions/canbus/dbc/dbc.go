@@ -0,0 +1,290 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dbc parses Vector DBC files and decodes CAN frames into physical signal values.
+package dbc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// extendedFlag is set in the DBC message id when the message uses a 29 bits identifier
+const extendedFlag uint32 = 0x80000000
+
+type ByteOrder int
+
+const (
+	// BigEndian is the Motorola byte order, marked as @0 in DBC
+	BigEndian ByteOrder = iota
+	// LittleEndian is the Intel byte order, marked as @1 in DBC
+	LittleEndian
+)
+
+type ValueType int
+
+const (
+	Integer ValueType = iota
+	// Float32 is the IEEE float, marked as 1 in SIG_VALTYPE_
+	Float32
+	// Float64 is the IEEE double, marked as 2 in SIG_VALTYPE_
+	Float64
+)
+
+type Signal struct {
+	Name      string
+	StartBit  int
+	Length    int
+	ByteOrder ByteOrder
+	Signed    bool
+	ValueType ValueType
+	Factor    float64
+	Offset    float64
+	Min       float64
+	Max       float64
+	Unit      string
+	Receivers []string
+	// IsMultiplexer marks the multiplexer switch signal of the message
+	IsMultiplexer bool
+	// IsMultiplexed marks the signal only presents when the multiplexer equals to MultiplexValue
+	IsMultiplexed  bool
+	MultiplexValue int64
+	// ValueTable is the VAL_ description of the raw values
+	ValueTable map[int64]string
+}
+
+type Message struct {
+	ID          uint32
+	Extended    bool
+	Name        string
+	Length      int
+	Transmitter string
+	// CycleTime is the GenMsgCycleTime attribute in milliseconds, 0 if not defined
+	CycleTime int
+	Signals   []*Signal
+}
+
+type Database struct {
+	Version  string
+	Messages map[uint32]*Message
+}
+
+var (
+	boRegex  = regexp.MustCompile(`^BO_\s+(\d+)\s+(\w+)\s*:\s*(\d+)\s+(\w+)`)
+	sgRegex  = regexp.MustCompile(`^SG_\s+(\w+)\s*(M|m\d+M?)?\s*:\s*(\d+)\|(\d+)@([01])([+-])\s*\(\s*([^,]+?)\s*,\s*([^)]+?)\s*\)\s*\[\s*([^|]+?)\s*\|\s*([^\]]+?)\s*\]\s*"([^"]*)"\s*(.*)$`)
+	valRegex = regexp.MustCompile(`^VAL_\s+(\d+)\s+(\w+)\s+(.*);`)
+	vpRegex  = regexp.MustCompile(`(-?\d+)\s+"([^"]*)"`)
+	baRegex  = regexp.MustCompile(`^BA_\s+"GenMsgCycleTime"\s+BO_\s+(\d+)\s+(\d+)\s*;`)
+	badRegex = regexp.MustCompile(`^BA_DEF_DEF_\s+"GenMsgCycleTime"\s+(\d+)\s*;`)
+	verRegex = regexp.MustCompile(`^VERSION\s+"([^"]*)"`)
+	svtRegex = regexp.MustCompile(`^SIG_VALTYPE_\s+(\d+)\s+(\w+)\s*:?\s*([012])\s*;`)
+)
+
+// ParseFile reads and parses the DBC file in the path
+func ParseFile(path string) (*Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open dbc file %s: %v", path, err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse parses the DBC content. Only the sections that are required for decoding are read: the messages, signals,
+// value tables and the cycle time attributes. All the other sections are ignored.
+func Parse(r io.Reader) (*Database, error) {
+	db := &Database{Messages: make(map[uint32]*Message)}
+	var (
+		current      *Message
+		defaultCycle int
+		cycles       = make(map[uint32]int)
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		// Quoted strings like comments may span multiple lines, join them into one statement
+		for strings.Count(line, `"`)%2 == 1 && scanner.Scan() {
+			lineNo++
+			line += "\n" + scanner.Text()
+		}
+		switch {
+		case strings.HasPrefix(line, "VERSION"):
+			if m := verRegex.FindStringSubmatch(line); m != nil {
+				db.Version = m[1]
+			}
+		case strings.HasPrefix(line, "BO_ "):
+			m := boRegex.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("invalid message definition at line %d: %s", lineNo, line)
+			}
+			rawId, _ := strconv.ParseUint(m[1], 10, 32)
+			l, _ := strconv.Atoi(m[3])
+			current = &Message{
+				ID:          uint32(rawId) &^ extendedFlag,
+				Extended:    uint32(rawId)&extendedFlag != 0,
+				Name:        m[2],
+				Length:      l,
+				Transmitter: m[4],
+			}
+			db.Messages[current.ID] = current
+		case strings.HasPrefix(line, "SG_ "):
+			if current == nil {
+				return nil, fmt.Errorf("signal definition without message at line %d", lineNo)
+			}
+			s, err := parseSignal(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid signal definition at line %d: %v", lineNo, err)
+			}
+			current.Signals = append(current.Signals, s)
+		case strings.HasPrefix(line, "SIG_VALTYPE_ "):
+			m := svtRegex.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			rawId, _ := strconv.ParseUint(m[1], 10, 32)
+			if msg, ok := db.Messages[uint32(rawId)&^extendedFlag]; ok {
+				if s := msg.Signal(m[2]); s != nil {
+					vt, _ := strconv.Atoi(m[3])
+					s.ValueType = ValueType(vt)
+				}
+			}
+		case strings.HasPrefix(line, "VAL_ "):
+			m := valRegex.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			rawId, _ := strconv.ParseUint(m[1], 10, 32)
+			msg, ok := db.Messages[uint32(rawId)&^extendedFlag]
+			if !ok {
+				continue
+			}
+			if s := msg.Signal(m[2]); s != nil {
+				s.ValueTable = make(map[int64]string)
+				for _, vp := range vpRegex.FindAllStringSubmatch(m[3], -1) {
+					v, _ := strconv.ParseInt(vp[1], 10, 64)
+					s.ValueTable[v] = vp[2]
+				}
+			}
+		case strings.HasPrefix(line, "BA_DEF_DEF_ "):
+			if m := badRegex.FindStringSubmatch(line); m != nil {
+				defaultCycle, _ = strconv.Atoi(m[1])
+			}
+		case strings.HasPrefix(line, "BA_ "):
+			if m := baRegex.FindStringSubmatch(line); m != nil {
+				rawId, _ := strconv.ParseUint(m[1], 10, 32)
+				c, _ := strconv.Atoi(m[2])
+				cycles[uint32(rawId)&^extendedFlag] = c
+			}
+		default:
+			// signals are always right after the message, so any other statement ends the current message
+			if line != "" {
+				current = nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("fail to read dbc: %v", err)
+	}
+	for id, msg := range db.Messages {
+		if c, ok := cycles[id]; ok {
+			msg.CycleTime = c
+		} else {
+			msg.CycleTime = defaultCycle
+		}
+	}
+	return db, nil
+}
+
+func parseSignal(line string) (*Signal, error) {
+	m := sgRegex.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("cannot parse %s", line)
+	}
+	s := &Signal{
+		Name:   m[1],
+		Signed: m[6] == "-",
+		Unit:   m[11],
+	}
+	switch mux := m[2]; {
+	case mux == "M":
+		s.IsMultiplexer = true
+	case strings.HasPrefix(mux, "m"):
+		s.IsMultiplexed = true
+		s.IsMultiplexer = strings.HasSuffix(mux, "M")
+		v, err := strconv.ParseInt(strings.TrimSuffix(mux[1:], "M"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiplex indicator %s", mux)
+		}
+		s.MultiplexValue = v
+	}
+	s.StartBit, _ = strconv.Atoi(m[3])
+	s.Length, _ = strconv.Atoi(m[4])
+	if s.Length <= 0 || s.Length > 64 {
+		return nil, fmt.Errorf("signal %s length %d is out of range", s.Name, s.Length)
+	}
+	if m[5] == "1" {
+		s.ByteOrder = LittleEndian
+	} else {
+		s.ByteOrder = BigEndian
+	}
+	nums := make([]float64, 4)
+	for i, n := range []string{m[7], m[8], m[9], m[10]} {
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return nil, fmt.Errorf("signal %s has invalid number %s", s.Name, n)
+		}
+		nums[i] = f
+	}
+	s.Factor, s.Offset, s.Min, s.Max = nums[0], nums[1], nums[2], nums[3]
+	for _, r := range strings.Split(m[12], ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			s.Receivers = append(s.Receivers, r)
+		}
+	}
+	return s, nil
+}
+
+// Signal finds the signal by name, return nil if not found
+func (m *Message) Signal(name string) *Signal {
+	for _, s := range m.Signals {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Message finds the message definition by the frame id
+func (db *Database) Message(id uint32) (*Message, bool) {
+	msg, ok := db.Messages[id&^extendedFlag]
+	return msg, ok
+}
+
+// MessageByName finds the message definition by the message name
+func (db *Database) MessageByName(name string) (*Message, bool) {
+	for _, msg := range db.Messages {
+		if msg.Name == name {
+			return msg, true
+		}
+	}
+	return nil, false
+}
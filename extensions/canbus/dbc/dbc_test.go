@@ -0,0 +1,220 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDbc = `VERSION "1.0"
+
+NS_ :
+	CM_
+	BA_DEF_
+
+BS_:
+
+BU_: ECU1 ECU2
+
+BO_ 100 EngineData: 8 ECU1
+ SG_ EngineSpeed : 0|16@1+ (0.125,0) [0|8031.875] "rpm" ECU2
+ SG_ Temperature : 16|8@1- (1,-40) [-40|215] "degC" ECU2
+ SG_ Gear : 24|4@1+ (1,0) [0|15] "" ECU2,ECU1
+
+BO_ 2364540158 EEC1: 8 ECU1
+ SG_ Torque : 7|16@0+ (1,0) [0|65535] "Nm" ECU2
+
+BO_ 200 MuxMsg: 8 ECU1
+ SG_ Mux M : 0|8@1+ (1,0) [0|255] "" ECU2
+ SG_ SigA m1 : 8|8@1+ (1,0) [0|255] "" ECU2
+ SG_ SigB m2 : 8|8@1+ (1,0) [0|255] "" ECU2
+
+BO_ 300 FloatMsg: 8 ECU1
+ SG_ Pressure : 0|32@1- (1,0) [0|0] "bar" ECU2
+
+CM_ SG_ 100 EngineSpeed "Engine speed
+BO_ 300 Fake: 8 ECU1";
+BA_DEF_ BO_  "GenMsgCycleTime" INT 0 65535;
+BA_DEF_DEF_ "GenMsgCycleTime" 50;
+BA_ "GenMsgCycleTime" BO_ 100 10;
+SIG_VALTYPE_ 300 Pressure : 1;
+VAL_ 100 Gear 0 "P" 1 "R" 2 "N" 3 "D" ;
+`
+
+func TestParse(t *testing.T) {
+	db, err := Parse(strings.NewReader(testDbc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Version != "1.0" {
+		t.Errorf("version mismatch, got %s", db.Version)
+	}
+	if len(db.Messages) != 4 {
+		t.Fatalf("expect 4 messages but got %d", len(db.Messages))
+	}
+	msg, ok := db.Message(100)
+	if !ok {
+		t.Fatal("message 100 not found")
+	}
+	if msg.Name != "EngineData" || msg.Length != 8 || msg.Transmitter != "ECU1" || msg.CycleTime != 10 {
+		t.Errorf("message mismatch, got %+v", msg)
+	}
+	exp := &Signal{
+		Name:       "Gear",
+		StartBit:   24,
+		Length:     4,
+		ByteOrder:  LittleEndian,
+		Factor:     1,
+		Max:        15,
+		Receivers:  []string{"ECU2", "ECU1"},
+		ValueTable: map[int64]string{0: "P", 1: "R", 2: "N", 3: "D"},
+	}
+	if !reflect.DeepEqual(exp, msg.Signal("Gear")) {
+		t.Errorf("signal mismatch\nexp\t%+v\ngot\t%+v", exp, msg.Signal("Gear"))
+	}
+	ext, ok := db.Message(0x0CF004FE)
+	if !ok {
+		t.Fatal("extended message not found")
+	}
+	if !ext.Extended || ext.CycleTime != 50 {
+		t.Errorf("extended message mismatch, got %+v", ext)
+	}
+	if _, ok := db.MessageByName("Fake"); ok {
+		t.Errorf("message in comment should not be parsed")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	db, err := Parse(strings.NewReader(testDbc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id   uint32
+		data []byte
+		exp  map[string]interface{}
+	}{
+		{
+			id:   100,
+			data: []byte{0x40, 0x1F, 0xF6, 0x03, 0, 0, 0, 0},
+			exp: map[string]interface{}{
+				"EngineSpeed": 1000.0,
+				"Temperature": int64(-50),
+				"Gear":        int64(3),
+			},
+		}, {
+			id:   0x8CF004FE,
+			data: []byte{0x12, 0x34, 0, 0, 0, 0, 0, 0},
+			exp: map[string]interface{}{
+				"Torque": int64(0x1234),
+			},
+		}, {
+			id:   200,
+			data: []byte{1, 42},
+			exp: map[string]interface{}{
+				"Mux":  int64(1),
+				"SigA": int64(42),
+			},
+		}, {
+			id:   300,
+			data: []byte{0x00, 0x00, 0x20, 0x40},
+			exp: map[string]interface{}{
+				"Pressure": 2.5,
+			},
+		}, {
+			id:   200,
+			data: []byte{2, 43},
+			exp: map[string]interface{}{
+				"Mux":  int64(2),
+				"SigB": int64(43),
+			},
+		},
+	}
+	for i, tt := range tests {
+		msg, ok := db.Message(tt.id)
+		if !ok {
+			t.Errorf("%d: message %d not found", i, tt.id)
+			continue
+		}
+		if got := msg.Decode(tt.data); !reflect.DeepEqual(tt.exp, got) {
+			t.Errorf("%d: decode mismatch\nexp\t%v\ngot\t%v", i, tt.exp, got)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Parse(strings.NewReader("BO_ 100 EngineData: 8 ECU1\n SG_ EngineSpeed : 0|0@1+ (1,0) [0|1] \"\" ECU2\n"))
+	if err == nil || err.Error() != "invalid signal definition at line 2: signal EngineSpeed length 0 is out of range" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	db, err := Parse(strings.NewReader(testDbc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id     uint32
+		values map[string]interface{}
+		exp    []byte
+		err    string
+	}{
+		{
+			id:     100,
+			values: map[string]interface{}{"EngineSpeed": 1000, "Temperature": -50, "Gear": "D"},
+			exp:    []byte{0x40, 0x1F, 0xF6, 0x03, 0, 0, 0, 0},
+		}, {
+			id:     0x0CF004FE,
+			values: map[string]interface{}{"Torque": int64(0x1234)},
+			exp:    []byte{0x12, 0x34, 0, 0, 0, 0, 0, 0},
+		}, {
+			id:     200,
+			values: map[string]interface{}{"Mux": 2, "SigA": 42, "SigB": 43},
+			exp:    []byte{2, 43, 0, 0, 0, 0, 0, 0},
+		}, {
+			id:     300,
+			values: map[string]interface{}{"Pressure": 2.5},
+			exp:    []byte{0x00, 0x00, 0x20, 0x40, 0, 0, 0, 0},
+		}, {
+			id:     100,
+			values: map[string]interface{}{"Gear": 16},
+			err:    "signal Gear value 16 is out of range",
+		}, {
+			id:     100,
+			values: map[string]interface{}{"Gear": "X"},
+			err:    "signal Gear has no value description X",
+		},
+	}
+	for i, tt := range tests {
+		msg, _ := db.Message(tt.id)
+		got, err := msg.Encode(tt.values)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.exp, got) {
+			t.Errorf("%d: encode mismatch\nexp\t%v\ngot\t%v", i, tt.exp, got)
+		}
+	}
+}
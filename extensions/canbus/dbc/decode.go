@@ -0,0 +1,102 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbc
+
+import "math"
+
+// Decode decodes the frame payload into a map of signal name to physical value.
+// Multiplexed signals are only decoded when the multiplexer switch matches.
+func (m *Message) Decode(data []byte) map[string]interface{} {
+	result := make(map[string]interface{}, len(m.Signals))
+	var (
+		mux    int64
+		hasMux bool
+	)
+	for _, s := range m.Signals {
+		if s.IsMultiplexer && !s.IsMultiplexed {
+			mux = s.RawValue(data)
+			hasMux = true
+			break
+		}
+	}
+	for _, s := range m.Signals {
+		if s.IsMultiplexed && (!hasMux || s.MultiplexValue != mux) {
+			continue
+		}
+		result[s.Name] = s.Decode(data)
+	}
+	return result
+}
+
+// Decode returns the physical value of the signal. The value is int64 if both factor and offset are integers,
+// otherwise it is float64. IEEE float signals are always float64.
+func (s *Signal) Decode(data []byte) interface{} {
+	raw := s.RawValue(data)
+	switch s.ValueType {
+	case Float32:
+		return float64(math.Float32frombits(uint32(raw)))*s.Factor + s.Offset
+	case Float64:
+		return math.Float64frombits(uint64(raw))*s.Factor + s.Offset
+	}
+	if s.isIntegral() {
+		return raw*int64(s.Factor) + int64(s.Offset)
+	}
+	return float64(raw)*s.Factor + s.Offset
+}
+
+// RawValue extracts the raw value of the signal from the frame payload with sign extension
+func (s *Signal) RawValue(data []byte) int64 {
+	var v uint64
+	if s.ByteOrder == LittleEndian {
+		for i := 0; i < s.Length; i++ {
+			if getBit(data, s.StartBit+i) {
+				v |= 1 << uint(i)
+			}
+		}
+	} else {
+		pos := s.StartBit
+		for i := 0; i < s.Length; i++ {
+			v <<= 1
+			if getBit(data, pos) {
+				v |= 1
+			}
+			pos = nextMotorolaBit(pos)
+		}
+	}
+	if s.Signed && s.Length < 64 && v&(1<<uint(s.Length-1)) != 0 {
+		v |= ^uint64(0) << uint(s.Length)
+	}
+	return int64(v)
+}
+
+func (s *Signal) isIntegral() bool {
+	return s.Factor == math.Trunc(s.Factor) && s.Offset == math.Trunc(s.Offset)
+}
+
+// nextMotorolaBit returns the next less significant bit position in the DBC sawtooth bit numbering
+func nextMotorolaBit(pos int) int {
+	if pos%8 == 0 {
+		return pos + 15
+	}
+	return pos - 1
+}
+
+func getBit(data []byte, pos int) bool {
+	i := pos / 8
+	if pos < 0 || i >= len(data) {
+		return false
+	}
+	return data[i]>>(uint(pos)%8)&1 == 1
+}
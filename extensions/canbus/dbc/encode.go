@@ -0,0 +1,151 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dbc
+
+import (
+	"fmt"
+	"math"
+)
+
+// Encode encodes the physical signal values into the frame payload. The signals which are not in the values are
+// left as raw 0. Multiplexed signals are only encoded when they match the multiplexer switch value.
+func (m *Message) Encode(values map[string]interface{}) ([]byte, error) {
+	data := make([]byte, m.Length)
+	var (
+		mux    int64
+		hasMux bool
+	)
+	for _, s := range m.Signals {
+		if s.IsMultiplexer && !s.IsMultiplexed {
+			hasMux = true
+			if v, ok := values[s.Name]; ok {
+				raw, err := s.toRaw(v)
+				if err != nil {
+					return nil, err
+				}
+				mux = raw
+			}
+			break
+		}
+	}
+	for _, s := range m.Signals {
+		if s.IsMultiplexed && (!hasMux || s.MultiplexValue != mux) {
+			continue
+		}
+		v, ok := values[s.Name]
+		if !ok {
+			continue
+		}
+		if err := s.Encode(data, v); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// Encode converts the physical value into raw value and writes it into the frame payload.
+// The value can be a number, a bool or a string defined in the value table.
+func (s *Signal) Encode(data []byte, v interface{}) error {
+	raw, err := s.toRaw(v)
+	if err != nil {
+		return err
+	}
+	u := uint64(raw)
+	if s.ByteOrder == LittleEndian {
+		for i := 0; i < s.Length; i++ {
+			if !setBit(data, s.StartBit+i, u>>uint(i)&1 == 1) {
+				return fmt.Errorf("signal %s exceeds the frame length %d", s.Name, len(data))
+			}
+		}
+	} else {
+		pos := s.StartBit
+		for i := s.Length - 1; i >= 0; i-- {
+			if !setBit(data, pos, u>>uint(i)&1 == 1) {
+				return fmt.Errorf("signal %s exceeds the frame length %d", s.Name, len(data))
+			}
+			pos = nextMotorolaBit(pos)
+		}
+	}
+	return nil
+}
+
+func (s *Signal) toRaw(v interface{}) (int64, error) {
+	var f float64
+	switch vt := v.(type) {
+	case int:
+		f = float64(vt)
+	case int32:
+		f = float64(vt)
+	case int64:
+		f = float64(vt)
+	case uint32:
+		f = float64(vt)
+	case uint64:
+		f = float64(vt)
+	case float32:
+		f = float64(vt)
+	case float64:
+		f = vt
+	case bool:
+		if vt {
+			f = 1
+		}
+	case string:
+		for raw, desc := range s.ValueTable {
+			if desc == vt {
+				return raw, nil
+			}
+		}
+		return 0, fmt.Errorf("signal %s has no value description %s", s.Name, vt)
+	default:
+		return 0, fmt.Errorf("signal %s value %v is not a number", s.Name, v)
+	}
+	factor := s.Factor
+	if factor == 0 {
+		factor = 1
+	}
+	switch s.ValueType {
+	case Float32:
+		return int64(math.Float32bits(float32((f - s.Offset) / factor))), nil
+	case Float64:
+		return int64(math.Float64bits((f - s.Offset) / factor)), nil
+	}
+	raw := int64(math.Round((f - s.Offset) / factor))
+	if s.Length < 64 {
+		var lo, hi int64
+		if s.Signed {
+			lo, hi = -(1 << uint(s.Length-1)), 1<<uint(s.Length-1)-1
+		} else {
+			lo, hi = 0, 1<<uint(s.Length)-1
+		}
+		if raw < lo || raw > hi {
+			return 0, fmt.Errorf("signal %s value %v is out of range", s.Name, v)
+		}
+	}
+	return raw, nil
+}
+
+func setBit(data []byte, pos int, b bool) bool {
+	i := pos / 8
+	if pos < 0 || i >= len(data) {
+		return false
+	}
+	if b {
+		data[i] |= 1 << (uint(pos) % 8)
+	} else {
+		data[i] &^= 1 << (uint(pos) % 8)
+	}
+	return true
+}
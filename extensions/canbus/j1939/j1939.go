@@ -0,0 +1,177 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package j1939 parses the SAE J1939 identifiers and reassembles the multi-packet transport protocol messages.
+package j1939
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+const (
+	// PgnTPCM is the transport protocol connection management PGN
+	PgnTPCM uint32 = 0xEC00
+	// PgnTPDT is the transport protocol data transfer PGN
+	PgnTPDT uint32 = 0xEB00
+
+	GlobalAddress uint8 = 0xFF
+)
+
+// control bytes of TP.CM
+const (
+	cmRTS   = 16
+	cmCTS   = 17
+	cmEOMA  = 19
+	cmBAM   = 32
+	cmAbort = 255
+)
+
+// DefaultTimeout is the T2/T3 timeout defined in J1939-21 after which an incomplete session is dropped
+const DefaultTimeout = 1250 * time.Millisecond
+
+type ID struct {
+	Priority    uint8
+	PGN         uint32
+	Source      uint8
+	Destination uint8
+}
+
+// ParseID extracts the J1939 fields from a 29 bits CAN identifier
+func ParseID(id uint32) ID {
+	r := ID{
+		Priority: uint8(id >> 26 & 0x7),
+		Source:   uint8(id),
+	}
+	pf := id >> 16 & 0xFF
+	ps := id >> 8 & 0xFF
+	dp := id >> 24 & 0x3
+	if pf < 240 {
+		// PDU1 format, PS is the destination address
+		r.PGN = dp<<16 | pf<<8
+		r.Destination = uint8(ps)
+	} else {
+		// PDU2 format, PS is the group extension and the message is always broadcast
+		r.PGN = dp<<16 | pf<<8 | ps
+		r.Destination = GlobalAddress
+	}
+	return r
+}
+
+// PGN returns the parameter group number of the CAN identifier
+func PGN(id uint32) uint32 {
+	return ParseID(id).PGN
+}
+
+// Message is a complete J1939 message either from a single frame or reassembled from the transport protocol
+type Message struct {
+	ID
+	Data []byte
+}
+
+type sessionKey struct {
+	source      uint8
+	destination uint8
+}
+
+type session struct {
+	pgn     uint32
+	size    int
+	packets int
+	data    []byte
+	next    int
+	updated time.Time
+}
+
+// Assembler reassembles the BAM and RTS/CTS transport sessions by listening passively on the bus.
+// It is not thread safe.
+type Assembler struct {
+	Timeout  time.Duration
+	sessions map[sessionKey]*session
+}
+
+func NewAssembler() *Assembler {
+	return &Assembler{
+		Timeout:  DefaultTimeout,
+		sessions: make(map[sessionKey]*session),
+	}
+}
+
+// Push feeds a frame into the assembler. It returns the message and true if a message is completed by this frame.
+// The transport protocol frames themselves are consumed and never returned.
+func (a *Assembler) Push(canId uint32, data []byte, now time.Time) (*Message, bool) {
+	id := ParseID(canId)
+	key := sessionKey{source: id.Source, destination: id.Destination}
+	switch id.PGN {
+	case PgnTPCM:
+		if len(data) < 8 {
+			return nil, false
+		}
+		switch data[0] {
+		case cmBAM, cmRTS:
+			size := int(binary.LittleEndian.Uint16(data[1:3]))
+			packets := int(data[3])
+			if packets == 0 || size > packets*7 {
+				delete(a.sessions, key)
+				return nil, false
+			}
+			a.sessions[key] = &session{
+				pgn:     uint32(data[5]) | uint32(data[6])<<8 | uint32(data[7])<<16,
+				size:    size,
+				packets: packets,
+				data:    make([]byte, 0, packets*7),
+				next:    1,
+				updated: now,
+			}
+		case cmAbort:
+			delete(a.sessions, key)
+			// abort can be sent by the receiver too
+			delete(a.sessions, sessionKey{source: id.Destination, destination: id.Source})
+		case cmCTS, cmEOMA:
+			// sent by the receiver, nothing to do for a passive listener
+		}
+		return nil, false
+	case PgnTPDT:
+		s, ok := a.sessions[key]
+		if !ok || len(data) < 1 {
+			return nil, false
+		}
+		if now.Sub(s.updated) > a.Timeout || int(data[0]) != s.next {
+			// timeout or lost packet, the whole session is invalid
+			delete(a.sessions, key)
+			return nil, false
+		}
+		s.data = append(s.data, data[1:]...)
+		s.next++
+		s.updated = now
+		if s.next > s.packets {
+			delete(a.sessions, key)
+			if len(s.data) < s.size {
+				return nil, false
+			}
+			return &Message{
+				ID: ID{
+					Priority:    id.Priority,
+					PGN:         s.pgn,
+					Source:      id.Source,
+					Destination: id.Destination,
+				},
+				Data: s.data[:s.size],
+			}, true
+		}
+		return nil, false
+	default:
+		return &Message{ID: id, Data: data}, true
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package j1939
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		id  uint32
+		exp ID
+	}{
+		{
+			// EEC1 from engine, PDU2
+			id:  0x0CF00400,
+			exp: ID{Priority: 3, PGN: 61444, Source: 0, Destination: GlobalAddress},
+		}, {
+			// TP.CM to address 0x17, PDU1
+			id:  0x1CEC17F9,
+			exp: ID{Priority: 7, PGN: PgnTPCM, Source: 0xF9, Destination: 0x17},
+		}, {
+			// data page set
+			id:  0x19FEF100,
+			exp: ID{Priority: 6, PGN: 0x1FEF1, Source: 0, Destination: GlobalAddress},
+		},
+	}
+	for i, tt := range tests {
+		if got := ParseID(tt.id); !reflect.DeepEqual(tt.exp, got) {
+			t.Errorf("%d: parse id mismatch\nexp\t%+v\ngot\t%+v", i, tt.exp, got)
+		}
+	}
+}
+
+func TestAssembleBAM(t *testing.T) {
+	a := NewAssembler()
+	now := time.Now()
+	// single frame is returned directly
+	m, ok := a.Push(0x0CF00400, []byte{1, 2, 3, 4, 5, 6, 7, 8}, now)
+	if !ok || m.PGN != 61444 || len(m.Data) != 8 {
+		t.Fatalf("single frame mismatch %+v", m)
+	}
+	// BAM of PGN 65226 (DM1), 10 bytes in 2 packets
+	frames := []struct {
+		id   uint32
+		data []byte
+	}{
+		{0x1CECFF00, []byte{32, 10, 0, 2, 0xFF, 0xCA, 0xFE, 0x00}},
+		{0x1CEBFF00, []byte{1, 1, 2, 3, 4, 5, 6, 7}},
+		{0x1CEBFF00, []byte{2, 8, 9, 10, 0xFF, 0xFF, 0xFF, 0xFF}},
+	}
+	for i, f := range frames {
+		m, ok = a.Push(f.id, f.data, now)
+		if i < len(frames)-1 && ok {
+			t.Fatalf("%d: should not complete", i)
+		}
+	}
+	if !ok {
+		t.Fatal("bam should complete")
+	}
+	exp := &Message{
+		ID:   ID{Priority: 7, PGN: 65226, Source: 0, Destination: GlobalAddress},
+		Data: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+	}
+	if !reflect.DeepEqual(exp, m) {
+		t.Errorf("bam mismatch\nexp\t%+v\ngot\t%+v", exp, m)
+	}
+}
+
+func TestAssembleRTS(t *testing.T) {
+	a := NewAssembler()
+	now := time.Now()
+	frames := []struct {
+		id   uint32
+		data []byte
+	}{
+		// RTS from 0x00 to 0xF9, 9 bytes in 2 packets of PGN 0xFEE5
+		{0x1CECF900, []byte{16, 9, 0, 2, 2, 0xE5, 0xFE, 0x00}},
+		// CTS from 0xF9
+		{0x1CEC00F9, []byte{17, 2, 1, 0xFF, 0xFF, 0xE5, 0xFE, 0x00}},
+		{0x1CEBF900, []byte{1, 1, 2, 3, 4, 5, 6, 7}},
+		{0x1CEBF900, []byte{2, 8, 9, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+	}
+	var (
+		m  *Message
+		ok bool
+	)
+	for _, f := range frames {
+		m, ok = a.Push(f.id, f.data, now)
+	}
+	if !ok {
+		t.Fatal("rts should complete")
+	}
+	exp := &Message{
+		ID:   ID{Priority: 7, PGN: 0xFEE5, Source: 0, Destination: 0xF9},
+		Data: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9},
+	}
+	if !reflect.DeepEqual(exp, m) {
+		t.Errorf("rts mismatch\nexp\t%+v\ngot\t%+v", exp, m)
+	}
+}
+
+func TestAssembleInvalid(t *testing.T) {
+	a := NewAssembler()
+	now := time.Now()
+	a.Push(0x1CECFF00, []byte{32, 10, 0, 2, 0xFF, 0xCA, 0xFE, 0x00}, now)
+	// lost the first packet
+	if _, ok := a.Push(0x1CEBFF00, []byte{2, 8, 9, 10, 0xFF, 0xFF, 0xFF, 0xFF}, now); ok {
+		t.Error("should drop out of order packet")
+	}
+	if len(a.sessions) != 0 {
+		t.Error("session should be dropped")
+	}
+	// timeout
+	a.Push(0x1CECFF00, []byte{32, 10, 0, 2, 0xFF, 0xCA, 0xFE, 0x00}, now)
+	a.Push(0x1CEBFF00, []byte{1, 1, 2, 3, 4, 5, 6, 7}, now)
+	if _, ok := a.Push(0x1CEBFF00, []byte{2, 8, 9, 10, 0xFF, 0xFF, 0xFF, 0xFF}, now.Add(2*time.Second)); ok {
+		t.Error("should drop timeout session")
+	}
+	// abort
+	a.Push(0x1CECF900, []byte{16, 9, 0, 2, 2, 0xE5, 0xFE, 0x00}, now)
+	a.Push(0x1CEC00F9, []byte{255, 1, 0xFF, 0xFF, 0xFF, 0xE5, 0xFE, 0x00}, now)
+	if len(a.sessions) != 0 {
+		t.Error("session should be aborted")
+	}
+}
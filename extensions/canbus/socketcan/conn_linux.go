@@ -0,0 +1,78 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package socketcan
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+type Conn struct {
+	f *os.File
+}
+
+// Dial opens a raw CAN socket bound to the interface like can0 or vcan0
+func Dial(ifname string) (*Conn, error) {
+	iface, err := net.InterfaceByName(ifname)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find can interface %s: %v", ifname, err)
+	}
+	fd, err := unix.Socket(unix.AF_CAN, unix.SOCK_RAW, unix.CAN_RAW)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create can socket: %v", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrCAN{Ifindex: iface.Index}); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("cannot bind can socket to %s: %v", ifname, err)
+	}
+	// Non-blocking fd is managed by the runtime poller so that Close can interrupt a pending read
+	if err := unix.SetNonblock(fd, true); err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+	return &Conn{f: os.NewFile(uintptr(fd), ifname)}, nil
+}
+
+// ReadFrame blocks until a frame arrives or the connection is closed
+func (c *Conn) ReadFrame() (*Frame, error) {
+	b := make([]byte, frameSize)
+	if _, err := io.ReadFull(c.f, b); err != nil {
+		return nil, err
+	}
+	f := &Frame{}
+	if err := f.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (c *Conn) WriteFrame(f *Frame) error {
+	b, err := f.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = c.f.Write(b)
+	return err
+}
+
+func (c *Conn) Close() error {
+	return c.f.Close()
+}
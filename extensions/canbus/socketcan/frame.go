@@ -0,0 +1,86 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package socketcan reads and writes classic CAN frames through the Linux SocketCAN raw interface.
+package socketcan
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// frameSize is the size of struct can_frame in linux/can.h
+const frameSize = 16
+
+// flags and masks of can_id in struct can_frame
+const (
+	effFlag uint32 = 0x80000000
+	rtrFlag uint32 = 0x40000000
+	errFlag uint32 = 0x20000000
+	effMask uint32 = 0x1FFFFFFF
+	sffMask uint32 = 0x000007FF
+)
+
+type Frame struct {
+	ID       uint32
+	Extended bool
+	Remote   bool
+	Error    bool
+	Data     []byte
+}
+
+// UnmarshalBinary decodes the raw struct can_frame. The can_id is in host byte order which is little endian for all
+// the supported platforms.
+func (f *Frame) UnmarshalBinary(b []byte) error {
+	if len(b) != frameSize {
+		return fmt.Errorf("invalid can frame size %d", len(b))
+	}
+	id := binary.LittleEndian.Uint32(b[0:4])
+	f.Extended = id&effFlag != 0
+	f.Remote = id&rtrFlag != 0
+	f.Error = id&errFlag != 0
+	if f.Extended {
+		f.ID = id & effMask
+	} else {
+		f.ID = id & sffMask
+	}
+	l := int(b[4])
+	if l > 8 {
+		return fmt.Errorf("invalid can frame length %d", l)
+	}
+	f.Data = make([]byte, l)
+	copy(f.Data, b[8:8+l])
+	return nil
+}
+
+// MarshalBinary encodes the frame into the raw struct can_frame
+func (f *Frame) MarshalBinary() ([]byte, error) {
+	if len(f.Data) > 8 {
+		return nil, fmt.Errorf("can frame data length %d exceeds 8", len(f.Data))
+	}
+	id := f.ID
+	if f.Extended {
+		id = id&effMask | effFlag
+	} else if id > sffMask {
+		return nil, fmt.Errorf("standard can id %x exceeds 11 bits", id)
+	}
+	if f.Remote {
+		id |= rtrFlag
+	}
+	b := make([]byte, frameSize)
+	binary.LittleEndian.PutUint32(b[0:4], id)
+	b[4] = byte(len(f.Data))
+	copy(b[8:], f.Data)
+	return b, nil
+}
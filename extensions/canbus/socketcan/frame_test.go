@@ -0,0 +1,57 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package socketcan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrameCodec(t *testing.T) {
+	tests := []struct {
+		f   *Frame
+		raw []byte
+	}{
+		{
+			f:   &Frame{ID: 0x123, Data: []byte{1, 2, 3}},
+			raw: []byte{0x23, 0x01, 0, 0, 3, 0, 0, 0, 1, 2, 3, 0, 0, 0, 0, 0},
+		}, {
+			f:   &Frame{ID: 0x0CF004FE, Extended: true, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+			raw: []byte{0xFE, 0x04, 0xF0, 0x8C, 8, 0, 0, 0, 1, 2, 3, 4, 5, 6, 7, 8},
+		},
+	}
+	for i, tt := range tests {
+		raw, err := tt.f.MarshalBinary()
+		if err != nil {
+			t.Errorf("%d: marshal error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.raw, raw) {
+			t.Errorf("%d: marshal mismatch\nexp\t%v\ngot\t%v", i, tt.raw, raw)
+		}
+		f := &Frame{}
+		if err := f.UnmarshalBinary(raw); err != nil {
+			t.Errorf("%d: unmarshal error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.f, f) {
+			t.Errorf("%d: unmarshal mismatch\nexp\t%+v\ngot\t%+v", i, tt.f, f)
+		}
+	}
+	_, err := (&Frame{ID: 0x800}).MarshalBinary()
+	if err == nil {
+		t.Error("should fail for standard id exceeding 11 bits")
+	}
+}
@@ -15,6 +15,10 @@
 package main
 
 var (
-	Thumbnail thumbnail
-	Resize    imageResize
+	Thumbnail   thumbnail
+	Resize      imageResize
+	DecodeImage decodeImage
+	Crop        cropImage
+	ToTensor    toTensor
+	EncodeImage encodeImage
 )
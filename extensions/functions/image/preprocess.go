@@ -0,0 +1,301 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// decodeImage decodes the image into the raw RGB pixels along with the metadata
+type decodeImage struct{}
+
+func (f *decodeImage) Validate(args []interface{}) error {
+	if len(args) != 1 {
+		return fmt.Errorf("The decodeImage function supports 1 parameter, but got %d", len(args))
+	}
+	return nil
+}
+
+func (f *decodeImage) IsAggregate() bool {
+	return false
+}
+
+func (f *decodeImage) Exec(args []interface{}, _ api.FunctionContext) (interface{}, bool) {
+	img, format, err := decode(args[0])
+	if err != nil {
+		return err, false
+	}
+	bounds := img.Bounds()
+	return map[string]interface{}{
+		"format": format,
+		"width":  bounds.Dx(),
+		"height": bounds.Dy(),
+		"data":   rgbPixels(img),
+	}, true
+}
+
+// cropImage crops the rectangle of the image and encodes it in the original format
+type cropImage struct{}
+
+func (f *cropImage) Validate(args []interface{}) error {
+	if len(args) != 5 {
+		return fmt.Errorf("The crop function supports 5 parameters, but got %d", len(args))
+	}
+	return nil
+}
+
+func (f *cropImage) IsAggregate() bool {
+	return false
+}
+
+func (f *cropImage) Exec(args []interface{}, _ api.FunctionContext) (interface{}, bool) {
+	img, format, err := decode(args[0])
+	if err != nil {
+		return err, false
+	}
+	var rect [4]int
+	for i := range rect {
+		rect[i], err = cast.ToInt(args[i+1], cast.CONVERT_SAMEKIND)
+		if err != nil || rect[i] < 0 {
+			return fmt.Errorf("arg[%d] is not a non-negative bigint, got %v", i+1, args[i+1]), false
+		}
+	}
+	r := image.Rect(rect[0], rect[1], rect[0]+rect[2], rect[1]+rect[3]).Add(img.Bounds().Min).Intersect(img.Bounds())
+	if r.Empty() {
+		return fmt.Errorf("the crop rectangle is out of the image bounds %v", img.Bounds()), false
+	}
+	cropped := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), img, r.Min, draw.Src)
+	b, err := encode(cropped, format)
+	if err != nil {
+		return err, false
+	}
+	return b, true
+}
+
+// toTensor converts the image to the normalized float array which can be fed to the AI model
+type toTensor struct{}
+
+func (f *toTensor) Validate(args []interface{}) error {
+	if len(args) < 1 || len(args) > 4 {
+		return fmt.Errorf("The toTensor function supports 1 to 4 parameters, but got %d", len(args))
+	}
+	return nil
+}
+
+func (f *toTensor) IsAggregate() bool {
+	return false
+}
+
+func (f *toTensor) Exec(args []interface{}, ctx api.FunctionContext) (interface{}, bool) {
+	img, _, err := decode(args[0])
+	if err != nil {
+		return err, false
+	}
+	mean, std := [3]float64{0, 0, 0}, [3]float64{1, 1, 1}
+	if len(args) > 1 {
+		if mean, err = channelValues(args[1]); err != nil {
+			return fmt.Errorf("arg[1] %v", err), false
+		}
+	}
+	if len(args) > 2 {
+		if std, err = channelValues(args[2]); err != nil {
+			return fmt.Errorf("arg[2] %v", err), false
+		}
+		for _, s := range std {
+			if s == 0 {
+				return fmt.Errorf("arg[2] must not contain 0"), false
+			}
+		}
+	}
+	layout := "CHW"
+	if len(args) > 3 {
+		l, ok := args[3].(string)
+		if !ok {
+			return fmt.Errorf("arg[3] is not a string, got %v", args[3]), false
+		}
+		layout = strings.ToUpper(l)
+		if layout != "CHW" && layout != "HWC" {
+			return fmt.Errorf("arg[3] must be CHW or HWC, got %s", l), false
+		}
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	ctx.GetLogger().Debugf("toTensor: %dx%d image in %s layout", w, h, layout)
+	pixels := rgbPixels(img)
+	result := make([]interface{}, len(pixels))
+	for i, p := range pixels {
+		c := i % 3
+		v := (float64(p)/255.0 - mean[c]) / std[c]
+		if layout == "CHW" {
+			result[c*w*h+i/3] = v
+		} else {
+			result[i] = v
+		}
+	}
+	return result, true
+}
+
+// encodeImage encodes the raw RGB or gray pixels into the image of the format
+type encodeImage struct{}
+
+func (f *encodeImage) Validate(args []interface{}) error {
+	if len(args) < 3 || len(args) > 4 {
+		return fmt.Errorf("The encodeImage function supports 3 or 4 parameters, but got %d", len(args))
+	}
+	return nil
+}
+
+func (f *encodeImage) IsAggregate() bool {
+	return false
+}
+
+func (f *encodeImage) Exec(args []interface{}, _ api.FunctionContext) (interface{}, bool) {
+	var (
+		pixels []byte
+		err    error
+	)
+	switch v := args[0].(type) {
+	case []byte:
+		pixels = v
+	case []interface{}:
+		pixels = make([]byte, len(v))
+		for i, p := range v {
+			pixels[i], err = cast.ToUint8(p, cast.CONVERT_SAMEKIND)
+			if err != nil {
+				return fmt.Errorf("arg[0] must contain the pixel values between 0 and 255, got %v", p), false
+			}
+		}
+	default:
+		return fmt.Errorf("arg[0] is not a bytea or array, got %v", args[0]), false
+	}
+	width, err := cast.ToInt(args[1], cast.CONVERT_SAMEKIND)
+	if err != nil || width <= 0 {
+		return fmt.Errorf("arg[1] is not a positive bigint, got %v", args[1]), false
+	}
+	height, err := cast.ToInt(args[2], cast.CONVERT_SAMEKIND)
+	if err != nil || height <= 0 {
+		return fmt.Errorf("arg[2] is not a positive bigint, got %v", args[2]), false
+	}
+	format := "png"
+	if len(args) > 3 {
+		f, ok := args[3].(string)
+		if !ok {
+			return fmt.Errorf("arg[3] is not a string, got %v", args[3]), false
+		}
+		format = strings.ToLower(f)
+	}
+	var img image.Image
+	switch len(pixels) {
+	case width * height * 3:
+		rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+		for i := 0; i < width*height; i++ {
+			copy(rgba.Pix[i*4:i*4+3], pixels[i*3:i*3+3])
+			rgba.Pix[i*4+3] = 0xff
+		}
+		img = rgba
+	case width * height:
+		gray := image.NewGray(image.Rect(0, 0, width, height))
+		copy(gray.Pix, pixels)
+		img = gray
+	default:
+		return fmt.Errorf("arg[0] must have %d bytes for RGB or %d bytes for gray image, but got %d", width*height*3, width*height, len(pixels)), false
+	}
+	b, err := encode(img, format)
+	if err != nil {
+		return err, false
+	}
+	return b, true
+}
+
+func decode(arg interface{}) (image.Image, string, error) {
+	payload, ok := arg.([]byte)
+	if !ok {
+		return nil, "", fmt.Errorf("arg[0] is not a bytea, got %v", arg)
+	}
+	img, format, err := image.Decode(bytes.NewReader(payload))
+	if err != nil {
+		return nil, "", fmt.Errorf("image decode error:%v", err)
+	}
+	return img, format, nil
+}
+
+func encode(img image.Image, format string) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg", "jpg":
+		err = jpeg.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("%s image type is not currently supported", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("image encode error:%v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// rgbPixels returns the 8-bit RGB values of the image in row-major order
+func rgbPixels(img image.Image) []byte {
+	bounds := img.Bounds()
+	dx, dy := bounds.Dx(), bounds.Dy()
+	bb := make([]byte, dx*dy*3)
+	for y := 0; y < dy; y++ {
+		for x := 0; x < dx; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			i := (y*dx + x) * 3
+			bb[i] = byte(r >> 8)
+			bb[i+1] = byte(g >> 8)
+			bb[i+2] = byte(b >> 8)
+		}
+	}
+	return bb
+}
+
+// channelValues accepts a number for all channels or an array of 3 numbers for RGB channels respectively
+func channelValues(arg interface{}) ([3]float64, error) {
+	var result [3]float64
+	if arr, ok := arg.([]interface{}); ok {
+		if len(arr) != 3 {
+			return result, fmt.Errorf("must have 3 elements for RGB channels, but got %d", len(arr))
+		}
+		for i, a := range arr {
+			v, err := cast.ToFloat64(a, cast.CONVERT_SAMEKIND)
+			if err != nil {
+				return result, fmt.Errorf("must be an array of float, got %v", arg)
+			}
+			result[i] = v
+		}
+		return result, nil
+	}
+	v, err := cast.ToFloat64(arg, cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return result, fmt.Errorf("must be a float or an array of float, got %v", arg)
+	}
+	return [3]float64{v, v, v}, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// testImage returns a 2x2 png image:
+// red   green
+// blue  white
+func testImage(t *testing.T) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	img.Set(1, 0, color.RGBA{G: 255, A: 255})
+	img.Set(0, 1, color.RGBA{B: 255, A: 255})
+	img.Set(1, 1, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPreprocess(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "testExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	fctx := kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), 2)
+	payload := testImage(t)
+	pixels := []byte{255, 0, 0, 0, 255, 0, 0, 0, 255, 255, 255, 255}
+
+	// decode
+	r, ok := DecodeImage.Exec([]interface{}{payload}, fctx)
+	if !ok {
+		t.Fatalf("decode error: %v", r)
+	}
+	exp := map[string]interface{}{
+		"format": "png",
+		"width":  2,
+		"height": 2,
+		"data":   pixels,
+	}
+	if !reflect.DeepEqual(r, exp) {
+		t.Errorf("decode result mismatch,\ngot:\t%v \nwant:\t%v", r, exp)
+	}
+
+	// crop the right column and decode it again
+	r, ok = Crop.Exec([]interface{}{payload, 1, 0, 1, 2}, fctx)
+	if !ok {
+		t.Fatalf("crop error: %v", r)
+	}
+	r, _ = DecodeImage.Exec([]interface{}{r}, fctx)
+	if d := r.(map[string]interface{})["data"]; !reflect.DeepEqual(d, []byte{0, 255, 0, 255, 255, 255}) {
+		t.Errorf("crop result mismatch, got %v", d)
+	}
+
+	// tensor in both layouts
+	r, ok = ToTensor.Exec([]interface{}{payload, 0.5, []interface{}{0.5, 0.5, 0.5}}, fctx)
+	if !ok {
+		t.Fatalf("toTensor error: %v", r)
+	}
+	expTensor := []interface{}{
+		1.0, -1.0, -1.0, 1.0, // R
+		-1.0, 1.0, -1.0, 1.0, // G
+		-1.0, -1.0, 1.0, 1.0, // B
+	}
+	if !reflect.DeepEqual(r, expTensor) {
+		t.Errorf("toTensor CHW result mismatch,\ngot:\t%v \nwant:\t%v", r, expTensor)
+	}
+	r, ok = ToTensor.Exec([]interface{}{payload, 0, 1, "hwc"}, fctx)
+	if !ok {
+		t.Fatalf("toTensor error: %v", r)
+	}
+	expTensor = []interface{}{1.0, 0.0, 0.0, 0.0, 1.0, 0.0, 0.0, 0.0, 1.0, 1.0, 1.0, 1.0}
+	if !reflect.DeepEqual(r, expTensor) {
+		t.Errorf("toTensor HWC result mismatch,\ngot:\t%v \nwant:\t%v", r, expTensor)
+	}
+
+	// encode the raw pixels back
+	for _, arg := range []interface{}{pixels, []interface{}{255, 0, 0, 0, 255, 0, 0, 0, 255, 255, 255, 255}} {
+		r, ok = EncodeImage.Exec([]interface{}{arg, 2, 2, "png"}, fctx)
+		if !ok {
+			t.Fatalf("encode error: %v", r)
+		}
+		r, _ = DecodeImage.Exec([]interface{}{r}, fctx)
+		if !reflect.DeepEqual(r, exp) {
+			t.Errorf("encode result mismatch,\ngot:\t%v \nwant:\t%v", r, exp)
+		}
+	}
+}
+
+func TestPreprocessError(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "testExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	fctx := kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), 2)
+	payload := testImage(t)
+	tests := []struct {
+		f    api.Function
+		args []interface{}
+		err  error
+	}{
+		{
+			f:    &DecodeImage,
+			args: []interface{}{"foo"},
+			err:  fmt.Errorf("arg[0] is not a bytea, got foo"),
+		}, {
+			f:    &Crop,
+			args: []interface{}{payload, 2, 2, 1, 1},
+			err:  fmt.Errorf("the crop rectangle is out of the image bounds (0,0)-(2,2)"),
+		}, {
+			f:    &ToTensor,
+			args: []interface{}{payload, []interface{}{0.5, 0.5}},
+			err:  fmt.Errorf("arg[1] must have 3 elements for RGB channels, but got 2"),
+		}, {
+			f:    &ToTensor,
+			args: []interface{}{payload, 0, 0},
+			err:  fmt.Errorf("arg[2] must not contain 0"),
+		}, {
+			f:    &ToTensor,
+			args: []interface{}{payload, 0, 1, "NCHW"},
+			err:  fmt.Errorf("arg[3] must be CHW or HWC, got NCHW"),
+		}, {
+			f:    &EncodeImage,
+			args: []interface{}{[]byte{1, 2, 3}, 2, 2},
+			err:  fmt.Errorf("arg[0] must have 12 bytes for RGB or 4 bytes for gray image, but got 3"),
+		}, {
+			f:    &EncodeImage,
+			args: []interface{}{[]byte{1, 2, 3, 4}, 2, 2, "bmp"},
+			err:  fmt.Errorf("bmp image type is not currently supported"),
+		},
+	}
+	for i, tt := range tests {
+		r, ok := tt.f.Exec(tt.args, fctx)
+		if ok || !reflect.DeepEqual(r, tt.err) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, r, tt.err)
+		}
+	}
+}
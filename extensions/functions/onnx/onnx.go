@@ -0,0 +1,204 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	ort "github.com/yalue/onnxruntime_go"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+type OnnxFunc struct{}
+
+// Validate the arguments.
+// args[0]: string, model name which maps to a path
+// args[1 to n]: tensors
+func (f *OnnxFunc) Validate(args []interface{}) error {
+	if len(args) < 2 {
+		return fmt.Errorf("onnx function must have at least 2 parameters but got %d", len(args))
+	}
+	return nil
+}
+
+func (f *OnnxFunc) IsAggregate() bool {
+	return false
+}
+
+func (f *OnnxFunc) Exec(args []interface{}, ctx api.FunctionContext) (interface{}, bool) {
+	model, ok := args[0].(string)
+	if !ok {
+		return fmt.Errorf("onnx function first parameter must be a string, but got %[1]T(%[1]v)", args[0]), false
+	}
+	sess, err := sessManager.GetOrCreate(model)
+	if err != nil {
+		return err, false
+	}
+	inputCount := len(sess.inputs)
+	if len(args)-1 != inputCount {
+		return fmt.Errorf("onnx function requires %d tensors but got %d", inputCount, len(args)-1), false
+	}
+
+	ctx.GetLogger().Debugf("onnx function %s with %d tensors", model, inputCount)
+	inputs := make([]ort.ArbitraryTensor, inputCount)
+	outputs := make([]ort.ArbitraryTensor, len(sess.outputs))
+	defer func() {
+		for _, v := range append(inputs, outputs...) {
+			if v != nil {
+				v.Destroy()
+			}
+		}
+	}()
+	for i := 1; i < len(args); i++ {
+		input, err := newInputTensor(sess.inputs[i-1], args[i])
+		if err != nil {
+			return fmt.Errorf("invalid %d parameter, %v", i, err), false
+		}
+		inputs[i-1] = input
+	}
+	// the outputs are all nil so that onnxruntime allocates them according to the actual output shapes
+	if err := sess.Run(inputs, outputs); err != nil {
+		return fmt.Errorf("invoke failed: %v", err), false
+	}
+	results := make([]interface{}, len(outputs))
+	for i, output := range outputs {
+		switch t := output.(type) {
+		case *ort.Tensor[float32]:
+			results[i] = copyData(t.GetData())
+		case *ort.Tensor[float64]:
+			results[i] = copyData(t.GetData())
+		case *ort.Tensor[int64]:
+			results[i] = copyData(t.GetData())
+		case *ort.Tensor[int32]:
+			results[i] = copyData(t.GetData())
+		case *ort.Tensor[int16]:
+			results[i] = copyData(t.GetData())
+		case *ort.Tensor[int8]:
+			results[i] = copyData(t.GetData())
+		case *ort.Tensor[uint8]:
+			results[i] = copyData(t.GetData())
+		default:
+			return fmt.Errorf("invalid %d output, unsupported type %T in the model", i, output), false
+		}
+	}
+	return results, true
+}
+
+// newInputTensor converts the argument to the tensor which matches the model input.
+// The argument can be a bytea for uint8 tensor, or an array which may be nested for multiple dimensions.
+func newInputTensor(info ort.InputOutputInfo, arg interface{}) (ort.ArbitraryTensor, error) {
+	var data []interface{}
+	switch v := arg.(type) {
+	case []byte:
+		if info.DataType != ort.TensorElementDataTypeUint8 {
+			return nil, fmt.Errorf("expect %v tensor but got bytea", info.DataType)
+		}
+		shape, err := inferShape(info.Dimensions, len(v))
+		if err != nil {
+			return nil, err
+		}
+		return ort.NewTensor(shape, v)
+	case []interface{}:
+		data = flatten(v, nil)
+	default:
+		return nil, fmt.Errorf("must be a bytea or array, but got %[1]T(%[1]v)", arg)
+	}
+	shape, err := inferShape(info.Dimensions, len(data))
+	if err != nil {
+		return nil, err
+	}
+	switch info.DataType {
+	case ort.TensorElementDataTypeFloat:
+		return newTensor(shape, data, cast.ToFloat32)
+	case ort.TensorElementDataTypeDouble:
+		return newTensor(shape, data, cast.ToFloat64)
+	case ort.TensorElementDataTypeInt64:
+		return newTensor(shape, data, cast.ToInt64)
+	case ort.TensorElementDataTypeInt32:
+		return newTensor(shape, data, cast.ToInt32)
+	case ort.TensorElementDataTypeInt16:
+		return newTensor(shape, data, cast.ToInt16)
+	case ort.TensorElementDataTypeInt8:
+		return newTensor(shape, data, cast.ToInt8)
+	case ort.TensorElementDataTypeUint8:
+		return newTensor(shape, data, cast.ToUint8)
+	default:
+		return nil, fmt.Errorf("unsupported type %v in the model", info.DataType)
+	}
+}
+
+func newTensor[T ort.TensorData](shape ort.Shape, data []interface{}, conv func(interface{}, cast.Strictness) (T, error)) (ort.ArbitraryTensor, error) {
+	typed := make([]T, len(data))
+	for i, d := range data {
+		v, err := conv(d, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, fmt.Errorf("expect %T but got %[2]T(%[2]v)", typed[i], d)
+		}
+		typed[i] = v
+	}
+	return ort.NewTensor(shape, typed)
+}
+
+// inferShape fills the dynamic dimensions of the model input with the number of elements.
+// The first dynamic dimension takes all the remaining elements and the others are 1.
+func inferShape(dims ort.Shape, size int) (ort.Shape, error) {
+	shape := make(ort.Shape, len(dims))
+	known, dynamic := int64(1), -1
+	for i, d := range dims {
+		if d <= 0 {
+			if dynamic < 0 {
+				dynamic = i
+			}
+			shape[i] = 1
+		} else {
+			shape[i] = d
+			known *= d
+		}
+	}
+	if dynamic < 0 {
+		if known != int64(size) {
+			return nil, fmt.Errorf("tensor must have %d elements but got %d", known, size)
+		}
+		return shape, nil
+	}
+	if size == 0 || int64(size)%known != 0 {
+		return nil, fmt.Errorf("tensor must have a multiple of %d elements but got %d", known, size)
+	}
+	shape[dynamic] = int64(size) / known
+	return shape, nil
+}
+
+// flatten the nested arrays in row-major order
+func flatten(arr []interface{}, result []interface{}) []interface{} {
+	for _, v := range arr {
+		if sub, ok := v.([]interface{}); ok {
+			result = flatten(sub, result)
+		} else {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// copyData copies the tensor data out of the memory managed by onnxruntime
+func copyData[T ort.TensorData](data []T) []T {
+	result := make([]T, len(data))
+	copy(result, data)
+	return result
+}
+
+var Onnx OnnxFunc
@@ -0,0 +1,88 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+func TestInferShape(t *testing.T) {
+	tests := []struct {
+		name  string
+		dims  ort.Shape
+		size  int
+		shape ort.Shape
+		err   error
+	}{
+		{
+			name:  "static",
+			dims:  ort.Shape{1, 2, 2},
+			size:  4,
+			shape: ort.Shape{1, 2, 2},
+		}, {
+			name: "static mismatch",
+			dims: ort.Shape{1, 2, 2},
+			size: 3,
+			err:  errors.New("tensor must have 4 elements but got 3"),
+		}, {
+			name:  "dynamic batch",
+			dims:  ort.Shape{-1, 3},
+			size:  6,
+			shape: ort.Shape{2, 3},
+		}, {
+			name:  "multiple dynamic",
+			dims:  ort.Shape{-1, -1, 4},
+			size:  8,
+			shape: ort.Shape{2, 1, 4},
+		}, {
+			name: "dynamic mismatch",
+			dims: ort.Shape{-1, 3},
+			size: 4,
+			err:  errors.New("tensor must have a multiple of 3 elements but got 4"),
+		}, {
+			name: "dynamic empty",
+			dims: ort.Shape{-1, 3},
+			size: 0,
+			err:  errors.New("tensor must have a multiple of 3 elements but got 0"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shape, err := inferShape(tt.dims, tt.size)
+			if !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("error mismatch,\ngot:\t%v \nwant:\t%v", err, tt.err)
+			}
+			if !reflect.DeepEqual(shape, tt.shape) {
+				t.Errorf("shape mismatch,\ngot:\t%v \nwant:\t%v", shape, tt.shape)
+			}
+		})
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	arr := []interface{}{
+		[]interface{}{1, 2},
+		[]interface{}{[]interface{}{3}, 4},
+		5,
+	}
+	exp := []interface{}{1, 2, 3, 4, 5}
+	if r := flatten(arr, nil); !reflect.DeepEqual(r, exp) {
+		t.Errorf("flatten mismatch,\ngot:\t%v \nwant:\t%v", r, exp)
+	}
+}
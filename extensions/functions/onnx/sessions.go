@@ -0,0 +1,114 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+// defaultLibPath is where install.sh puts the onnxruntime shared library.
+// Set ONNXRUNTIME_LIB_PATH environment variable to use another library.
+const defaultLibPath = "/usr/local/onnxruntime/lib/libonnxruntime.so"
+
+var sessManager *sessionManager
+
+func init() {
+	path, err := conf.GetDataLoc()
+	if err != nil {
+		panic(err)
+	}
+	sessManager = &sessionManager{
+		registry: make(map[string]*session),
+		path:     filepath.Join(path, "uploads"),
+	}
+}
+
+type session struct {
+	*ort.DynamicAdvancedSession
+	inputs  []ort.InputOutputInfo
+	outputs []ort.InputOutputInfo
+}
+
+type sessionManager struct {
+	sync.Mutex
+	registry map[string]*session
+	path     string
+}
+
+// initEnv loads the onnxruntime shared library lazily, so that the plugin can be installed before the library
+func (m *sessionManager) initEnv() error {
+	if ort.IsInitialized() {
+		return nil
+	}
+	lib := os.Getenv("ONNXRUNTIME_LIB_PATH")
+	if lib == "" {
+		lib = defaultLibPath
+	}
+	ort.SetSharedLibraryPath(lib)
+	if err := ort.InitializeEnvironment(); err != nil {
+		return fmt.Errorf("fail to initialize onnxruntime with %s: %v", lib, err)
+	}
+	return nil
+}
+
+func (m *sessionManager) GetOrCreate(name string) (*session, error) {
+	m.Lock()
+	defer m.Unlock()
+	s, ok := m.registry[name]
+	if !ok {
+		if err := m.initEnv(); err != nil {
+			return nil, err
+		}
+		mf := filepath.Join(m.path, name+".onnx")
+		inputs, outputs, err := ort.GetInputOutputInfo(mf)
+		if err != nil {
+			return nil, fmt.Errorf("fail to load model %s: %v", mf, err)
+		}
+		inputNames := make([]string, len(inputs))
+		for i, info := range inputs {
+			inputNames[i] = info.Name
+		}
+		outputNames := make([]string, len(outputs))
+		for i, info := range outputs {
+			outputNames[i] = info.Name
+		}
+		options, err := ort.NewSessionOptions()
+		if err != nil {
+			return nil, err
+		}
+		defer options.Destroy()
+		if err := options.SetIntraOpNumThreads(4); err != nil {
+			return nil, err
+		}
+		ds, err := ort.NewDynamicAdvancedSession(mf, inputNames, outputNames, options)
+		if err != nil {
+			return nil, fmt.Errorf("fail to create session for model %s: %v", mf, err)
+		}
+		s = &session{
+			DynamicAdvancedSession: ds,
+			inputs:                 inputs,
+			outputs:                outputs,
+		}
+		m.registry[name] = s
+	}
+	return s, nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"url": "localhost:5672", "exchange": "factory"},
+			err:   "url must start with amqp:// or amqps://",
+		}, {
+			props: map[string]interface{}{"exchange": "factory", "exchangeType": "match"},
+			err:   "exchangeType must be one of direct, fanout, topic and headers",
+		}, {
+			props: map[string]interface{}{},
+			err:   "routingKey is required to publish to the default exchange",
+		}, {
+			props: map[string]interface{}{"routingKey": "q1"},
+		}, {
+			props: map[string]interface{}{"exchange": "factory", "exchangeType": "topic", "routingKey": "line.{{.line}}"},
+		},
+	}
+	for i, tt := range tests {
+		err := Amqp().Configure(tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/extensions/canbus/dbc"
+	"github.com/lf-edge/ekuiper/extensions/canbus/socketcan"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+type frameConn interface {
+	WriteFrame(f *socketcan.Frame) error
+	Close() error
+}
+
+// dial is replaceable for test
+var dial = func(ifname string) (frameConn, error) {
+	c, err := socketcan.Dial(ifname)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type canSinkConfig struct {
+	// Interface is the SocketCAN interface to write to like can0
+	Interface string `json:"interface"`
+	// DbcPath is the path of the DBC file to encode the frames
+	DbcPath string `json:"dbcPath"`
+	// Message is the name of the message in the DBC file to encode the result into
+	Message string `json:"message"`
+}
+
+type canSink struct {
+	c    *canSinkConfig
+	msg  *dbc.Message
+	conn frameConn
+}
+
+func (m *canSink) Configure(props map[string]interface{}) error {
+	c := &canSinkConfig{}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Interface == "" {
+		return fmt.Errorf("sink `can` property `interface` is required")
+	}
+	if c.DbcPath == "" {
+		return fmt.Errorf("sink `can` property `dbcPath` is required")
+	}
+	if c.Message == "" {
+		return fmt.Errorf("sink `can` property `message` is required")
+	}
+	db, err := dbc.ParseFile(c.DbcPath)
+	if err != nil {
+		return err
+	}
+	msg, ok := db.MessageByName(c.Message)
+	if !ok {
+		return fmt.Errorf("message %s is not defined in dbc file %s", c.Message, c.DbcPath)
+	}
+	m.c = c
+	m.msg = msg
+	return nil
+}
+
+func (m *canSink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Debugf("Opening can sink to %s", m.c.Interface)
+	conn, err := dial(m.c.Interface)
+	if err != nil {
+		return err
+	}
+	m.conn = conn
+	return nil
+}
+
+func (m *canSink) Collect(ctx api.StreamContext, item interface{}) error {
+	logger := ctx.GetLogger()
+	logger.Debugf("can sink receive %s", item)
+	switch d := item.(type) {
+	case []map[string]interface{}:
+		for _, el := range d {
+			if err := m.send(el); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		return m.send(d)
+	default:
+		return fmt.Errorf("unrecognized format of %s", item)
+	}
+	return nil
+}
+
+func (m *canSink) send(values map[string]interface{}) error {
+	data, err := m.msg.Encode(values)
+	if err != nil {
+		return fmt.Errorf("can sink encode %s error: %v", m.msg.Name, err)
+	}
+	err = m.conn.WriteFrame(&socketcan.Frame{
+		ID:       m.msg.ID,
+		Extended: m.msg.Extended,
+		Data:     data,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: can sink fails to write to %s: %v", errorx.IOErr, m.c.Interface, err)
+	}
+	return nil
+}
+
+func (m *canSink) Close(_ api.StreamContext) error {
+	if m.conn != nil {
+		return m.conn.Close()
+	}
+	return nil
+}
+
+func Can() api.Sink {
+	return &canSink{}
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/extensions/canbus/socketcan"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+)
+
+type mockConn struct {
+	frames []*socketcan.Frame
+}
+
+func (m *mockConn) WriteFrame(f *socketcan.Frame) error {
+	m.frames = append(m.frames, f)
+	return nil
+}
+
+func (m *mockConn) Close() error {
+	return nil
+}
+
+func TestCollect(t *testing.T) {
+	conn := &mockConn{}
+	dial = func(_ string) (frameConn, error) {
+		return conn, nil
+	}
+	s := Can()
+	err := s.Configure(map[string]interface{}{
+		"interface": "vcan0",
+		"dbcPath":   "test/test.dbc",
+		"message":   "EngineData",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := mockContext.NewMockContext("ruleCan", "op1")
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err = s.Collect(ctx, []map[string]interface{}{
+		{"EngineSpeed": 1000.0, "Temperature": -50},
+		{"EngineSpeed": 0.125},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Collect(ctx, map[string]interface{}{"Temperature": 1000})
+	if err == nil || err.Error() != "can sink encode EngineData error: signal Temperature value 1000 is out of range" {
+		t.Errorf("unexpected error %v", err)
+	}
+	exp := []*socketcan.Frame{
+		{ID: 100, Data: []byte{0x40, 0x1F, 0xF6, 0, 0, 0, 0, 0}},
+		{ID: 100, Data: []byte{0x01, 0, 0, 0, 0, 0, 0, 0}},
+	}
+	if !reflect.DeepEqual(exp, conn.frames) {
+		t.Errorf("frames mismatch\nexp\t%v\ngot\t%v", exp, conn.frames)
+	}
+	_ = s.Close(ctx)
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/udp"
+	"github.com/plgd-dev/go-coap/v3/udp/client"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+var mediaTypes = map[string]message.MediaType{
+	"text/plain":               message.TextPlain,
+	"application/json":         message.AppJSON,
+	"application/cbor":         message.AppCBOR,
+	"application/xml":          message.AppXML,
+	"application/octet-stream": message.AppOctets,
+}
+
+type sinkConf struct {
+	// Server is the address of the CoAP server
+	Server string `json:"server"`
+	// Path is the path of the resource, it supports data template
+	Path   string `json:"path"`
+	Method string `json:"method"`
+	// ContentFormat is the media type of the payload
+	ContentFormat string `json:"contentFormat"`
+	// Timeout is the timeout of each request in milliseconds
+	Timeout int `json:"timeout"`
+}
+
+type coapSink struct {
+	c           *sinkConf
+	contentType message.MediaType
+	conn        *client.Conn
+}
+
+func (m *coapSink) Configure(props map[string]interface{}) error {
+	c := &sinkConf{
+		Server:        "localhost:5683",
+		Method:        "POST",
+		ContentFormat: "application/json",
+		Timeout:       5000,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Server == "" {
+		return fmt.Errorf("server is required")
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	c.Method = strings.ToUpper(c.Method)
+	if c.Method != "POST" && c.Method != "PUT" {
+		return fmt.Errorf("method %s is not supported, must be POST or PUT", c.Method)
+	}
+	ct, ok := mediaTypes[c.ContentFormat]
+	if !ok {
+		return fmt.Errorf("unsupported contentFormat %s", c.ContentFormat)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	m.c = c
+	m.contentType = ct
+	return nil
+}
+
+func (m *coapSink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("coap sink connecting to %s", m.c.Server)
+	conn, err := udp.Dial(m.c.Server)
+	if err != nil {
+		return fmt.Errorf("%s: coap sink fails to connect %s: %v", errorx.IOErr, m.c.Server, err)
+	}
+	m.conn = conn
+	return nil
+}
+
+func (m *coapSink) Collect(ctx api.StreamContext, item interface{}) error {
+	logger := ctx.GetLogger()
+	payload, _, err := ctx.TransformOutput(item)
+	if err != nil {
+		return err
+	}
+	path, err := ctx.ParseTemplate(m.c.Path, item)
+	if err != nil {
+		return err
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, time.Duration(m.c.Timeout)*time.Millisecond)
+	defer cancel()
+	var resp *pool.Message
+	if m.c.Method == "PUT" {
+		resp, err = m.conn.Put(reqCtx, path, m.contentType, bytes.NewReader(payload))
+	} else {
+		resp, err = m.conn.Post(reqCtx, path, m.contentType, bytes.NewReader(payload))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: coap sink fails to send to %s: %v", errorx.IOErr, path, err)
+	}
+	// 2.xx response codes are success
+	if resp.Code()>>5 != 2 {
+		body, _ := resp.ReadBody()
+		if resp.Code() == codes.ServiceUnavailable || resp.Code() == codes.GatewayTimeout {
+			return fmt.Errorf("%s: coap sink receives response code %v from %s: %s", errorx.IOErr, resp.Code(), path, string(body))
+		}
+		return fmt.Errorf("coap sink receives response code %v from %s: %s", resp.Code(), path, string(body))
+	}
+	logger.Debugf("coap sink sends %s to %s", payload, path)
+	return nil
+}
+
+func (m *coapSink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing coap sink")
+	if m.conn != nil {
+		return m.conn.Close()
+	}
+	return nil
+}
+
+func Coap() api.Sink {
+	return &coapSink{}
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		exp   message.MediaType
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"server": "", "path": "/alarm"},
+			err:   "server is required",
+		}, {
+			props: map[string]interface{}{"path": "alarm"},
+			err:   "path must start with /",
+		}, {
+			props: map[string]interface{}{"path": "/alarm", "method": "get"},
+			err:   "method GET is not supported, must be POST or PUT",
+		}, {
+			props: map[string]interface{}{"path": "/alarm", "contentFormat": "application/yaml"},
+			err:   "unsupported contentFormat application/yaml",
+		}, {
+			props: map[string]interface{}{"path": "/alarm", "timeout": 0},
+			err:   "timeout must be positive",
+		}, {
+			props: map[string]interface{}{"path": "/alarm"},
+			exp:   message.AppJSON,
+		}, {
+			props: map[string]interface{}{"path": "/{{.device}}/alarm", "method": "put", "contentFormat": "application/cbor"},
+			exp:   message.AppCBOR,
+		},
+	}
+	for i, tt := range tests {
+		s := Coap().(*coapSink)
+		err := s.Configure(tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if s.contentType != tt.exp {
+			t.Errorf("%d: expect content type %v but got %v", i, tt.exp, s.contentType)
+		}
+	}
+}
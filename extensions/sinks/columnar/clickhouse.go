@@ -0,0 +1,76 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// clickHouseWriter writes the batches by the native protocol
+type clickHouseWriter struct {
+	conn driver.Conn
+}
+
+func newClickHouseWriter(ctx context.Context, url string) (writer, error) {
+	opts, err := clickhouse.ParseDSN(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %v", err)
+	}
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Ping(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &clickHouseWriter{conn: conn}, nil
+}
+
+func (w *clickHouseWriter) exec(ctx context.Context, sqls []string) error {
+	for _, s := range sqls {
+		if err := w.conn.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *clickHouseWriter) write(ctx context.Context, table string, cols []column, rows [][]interface{}) error {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = "`" + col.name + "`"
+	}
+	batch, err := w.conn.PrepareBatch(ctx, fmt.Sprintf("INSERT INTO %s (%s)", table, strings.Join(names, ", ")))
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := batch.Append(row...); err != nil {
+			_ = batch.Abort()
+			return err
+		}
+	}
+	return batch.Send()
+}
+
+func (w *clickHouseWriter) close() error {
+	return w.conn.Close()
+}
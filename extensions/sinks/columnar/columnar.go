@@ -0,0 +1,453 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+const (
+	TypeClickHouse  = "clickhouse"
+	TypeTimescaleDB = "timescaledb"
+
+	kindBool   = "bool"
+	kindInt    = "int"
+	kindFloat  = "float"
+	kindString = "string"
+	kindTime   = "time"
+	kindJson   = "json"
+
+	// closeTimeout limits the time to write the remaining rows when closing
+	closeTimeout = 10 * time.Second
+)
+
+type sinkConf struct {
+	// Type is the database type, clickhouse or timescaledb
+	Type string `json:"type"`
+	// Url is the dsn of the native protocol for ClickHouse or the postgres connection string for TimescaleDB
+	Url       string   `json:"url"`
+	Table     string   `json:"table"`
+	Fields    []string `json:"fields"`
+	DataField string   `json:"dataField"`
+	// TimeField is the time column, the timestamp in milliseconds or the string in ISO format is converted to time.
+	// It is the order key of ClickHouse or the partition column of the hypertable of TimescaleDB for the created table
+	TimeField string `json:"timeField"`
+	// BatchSize is the max number of rows written in a batch
+	BatchSize int `json:"batchSize"`
+	// FlushInterval is the max duration in milliseconds to hold the rows before writing
+	FlushInterval int `json:"flushInterval"`
+	// BufferLength is the max number of the collected items waiting to be written. The collect blocks if it is full
+	// to apply the back-pressure to the rule
+	BufferLength int `json:"bufferLength"`
+	// CreateTable creates the table if not exists by the column types inferred from the first batch
+	CreateTable bool `json:"createTable"`
+}
+
+type column struct {
+	name string
+	kind string
+}
+
+// writer writes the batches into the database
+type writer interface {
+	exec(ctx context.Context, sqls []string) error
+	write(ctx context.Context, table string, cols []column, rows [][]interface{}) error
+	close() error
+}
+
+type columnarSink struct {
+	c *sinkConf
+	w writer
+	// cols are decided by the first batch
+	cols []column
+
+	input   chan []map[string]interface{}
+	flushCh chan chan error
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func (s *columnarSink) Configure(props map[string]interface{}) error {
+	c := &sinkConf{
+		BatchSize:     1000,
+		FlushInterval: 1000,
+		BufferLength:  1024,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Type != TypeClickHouse && c.Type != TypeTimescaleDB {
+		return fmt.Errorf("invalid type %s, must be %s or %s", c.Type, TypeClickHouse, TypeTimescaleDB)
+	}
+	if c.Url == "" {
+		return fmt.Errorf("url is required")
+	}
+	if c.Table == "" {
+		return fmt.Errorf("table is required")
+	}
+	if c.BatchSize <= 0 {
+		return fmt.Errorf("batchSize must be positive")
+	}
+	if c.FlushInterval <= 0 {
+		return fmt.Errorf("flushInterval must be positive")
+	}
+	if c.BufferLength <= 0 {
+		return fmt.Errorf("bufferLength must be positive")
+	}
+	if c.TimeField != "" && len(c.Fields) > 0 && !contains(c.Fields, c.TimeField) {
+		return fmt.Errorf("timeField %s must be in the fields", c.TimeField)
+	}
+	s.c = c
+	return nil
+}
+
+func (s *columnarSink) Open(ctx api.StreamContext) error {
+	logger := ctx.GetLogger()
+	logger.Infof("opening columnar sink to %s table %s", s.c.Type, s.c.Table)
+	if s.w == nil {
+		var (
+			w   writer
+			err error
+		)
+		if s.c.Type == TypeClickHouse {
+			w, err = newClickHouseWriter(ctx, s.c.Url)
+		} else {
+			w, err = newTimescaleWriter(ctx, s.c.Url)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: columnar sink fails to connect %s: %v", errorx.IOErr, s.c.Type, err)
+		}
+		s.w = w
+	}
+	s.input = make(chan []map[string]interface{}, s.c.BufferLength)
+	s.flushCh = make(chan chan error)
+	s.done = make(chan struct{})
+	s.wg.Add(1)
+	go s.run(ctx)
+	return nil
+}
+
+// Collect puts the rows into the buffer to be written asynchronously. It blocks if the buffer is full, for example
+// when the database is slow or unavailable, so that the rule is back-pressured
+func (s *columnarSink) Collect(ctx api.StreamContext, item interface{}) error {
+	tm, _, err := transform.TransItem(item, s.c.DataField, s.c.Fields)
+	if err != nil {
+		return fmt.Errorf("fail to transform data %v for error %v", item, err)
+	}
+	var rows []map[string]interface{}
+	switch v := tm.(type) {
+	case map[string]interface{}:
+		rows = []map[string]interface{}{v}
+	case []map[string]interface{}:
+		rows = v
+	case []interface{}:
+		rows = make([]map[string]interface{}, 0, len(v))
+		for _, data := range v {
+			m, ok := data.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("unsupported type: %T", data)
+			}
+			rows = append(rows, m)
+		}
+	default:
+		return fmt.Errorf("unsupported type: %T", tm)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	select {
+	case s.input <- rows:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush writes all the collected rows. It is called before the checkpoint
+func (s *columnarSink) Flush(ctx api.StreamContext) error {
+	reply := make(chan error, 1)
+	select {
+	case s.flushCh <- reply:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run accumulates the rows and writes them when the batch is full or the flush interval is reached. The failed rows
+// are kept to retry in the next interval, and the buffer is not consumed during the retrying
+func (s *columnarSink) run(ctx api.StreamContext) {
+	defer s.wg.Done()
+	logger := ctx.GetLogger()
+	ticker := conf.GetTicker(s.c.FlushInterval)
+	defer ticker.Stop()
+	var pending []map[string]interface{}
+	for {
+		in := s.input
+		if len(pending) >= s.c.BatchSize {
+			in = nil
+		}
+		select {
+		case rows := <-in:
+			pending = append(pending, rows...)
+			if len(pending) >= s.c.BatchSize {
+				pending, _ = s.flush(ctx, logger, pending)
+			}
+		case <-ticker.C:
+			pending, _ = s.flush(ctx, logger, pending)
+		case reply := <-s.flushCh:
+			var err error
+			pending, err = s.flush(ctx, logger, s.drain(pending))
+			reply <- err
+		case <-s.done:
+			// the rule may be cancelled, write the remaining rows in a limited time
+			c, cancel := context.WithTimeout(context.Background(), closeTimeout)
+			if rest, err := s.flush(c, logger, s.drain(pending)); err != nil {
+				logger.Errorf("columnar sink drops %d rows when closing: %v", len(rest), err)
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+func (s *columnarSink) drain(pending []map[string]interface{}) []map[string]interface{} {
+	for {
+		select {
+		case rows := <-s.input:
+			pending = append(pending, rows...)
+		default:
+			return pending
+		}
+	}
+}
+
+// flush writes the pending rows in batches and returns the rows failed to write
+func (s *columnarSink) flush(ctx context.Context, logger api.Logger, pending []map[string]interface{}) ([]map[string]interface{}, error) {
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	if s.cols == nil {
+		cols := inferColumns(s.c.Fields, s.c.TimeField, pending)
+		if s.c.CreateTable {
+			if err := s.w.exec(ctx, createSqls(s.c.Type, s.c.Table, s.c.TimeField, cols)); err != nil {
+				logger.Errorf("columnar sink fails to create table %s: %v", s.c.Table, err)
+				return pending, fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+			}
+		}
+		s.cols = cols
+	}
+	for len(pending) > 0 {
+		n := s.c.BatchSize
+		if n > len(pending) {
+			n = len(pending)
+		}
+		values := make([][]interface{}, 0, n)
+		for _, row := range pending[:n] {
+			v, err := rowValues(row, s.cols)
+			if err != nil {
+				logger.Warnf("columnar sink drops the invalid row %v: %v", row, err)
+				continue
+			}
+			values = append(values, v)
+		}
+		if len(values) > 0 {
+			if err := s.w.write(ctx, s.c.Table, s.cols, values); err != nil {
+				logger.Errorf("columnar sink fails to write %d rows: %v", len(values), err)
+				return pending, fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+			}
+			logger.Debugf("columnar sink writes %d rows", len(values))
+		}
+		pending = pending[n:]
+	}
+	return nil, nil
+}
+
+// inferColumns gets the columns of the fields or the sorted keys of the rows, and infers the kinds by the first
+// non-nil values
+func inferColumns(fields []string, timeField string, rows []map[string]interface{}) []column {
+	names := fields
+	if len(names) == 0 {
+		keys := make(map[string]struct{})
+		for _, row := range rows {
+			for k := range row {
+				if _, ok := keys[k]; !ok {
+					keys[k] = struct{}{}
+					names = append(names, k)
+				}
+			}
+		}
+		sort.Strings(names)
+	}
+	cols := make([]column, len(names))
+	for i, name := range names {
+		cols[i] = column{name: name, kind: kindString}
+		if name == timeField {
+			cols[i].kind = kindTime
+			continue
+		}
+		for _, row := range rows {
+			if v, ok := row[name]; ok && v != nil {
+				cols[i].kind = kindOf(v)
+				break
+			}
+		}
+	}
+	return cols
+}
+
+func kindOf(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return kindBool
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return kindInt
+	case float32, float64:
+		return kindFloat
+	case string:
+		return kindString
+	case time.Time:
+		return kindTime
+	default:
+		return kindJson
+	}
+}
+
+// rowValues converts the values of the row to the kinds of the columns
+func rowValues(row map[string]interface{}, cols []column) ([]interface{}, error) {
+	values := make([]interface{}, len(cols))
+	for i, col := range cols {
+		v, ok := row[col.name]
+		if !ok || v == nil {
+			if col.kind == kindTime {
+				return nil, fmt.Errorf("missing time field %s", col.name)
+			}
+			continue
+		}
+		var err error
+		switch col.kind {
+		case kindBool:
+			values[i], err = cast.ToBool(v, cast.CONVERT_SAMEKIND)
+		case kindInt:
+			values[i], err = cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+		case kindFloat:
+			values[i], err = cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+		case kindString:
+			values[i], err = cast.ToString(v, cast.CONVERT_ALL)
+		case kindTime:
+			values[i], err = cast.InterfaceToTime(v, "")
+		case kindJson:
+			var b []byte
+			b, err = json.Marshal(v)
+			values[i] = string(b)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %v", col.name, err)
+		}
+	}
+	return values, nil
+}
+
+var (
+	clickHouseTypes = map[string]string{
+		kindBool:   "Bool",
+		kindInt:    "Int64",
+		kindFloat:  "Float64",
+		kindString: "String",
+		kindTime:   "DateTime64(3)",
+		kindJson:   "String",
+	}
+	timescaleTypes = map[string]string{
+		kindBool:   "BOOLEAN",
+		kindInt:    "BIGINT",
+		kindFloat:  "DOUBLE PRECISION",
+		kindString: "TEXT",
+		kindTime:   "TIMESTAMPTZ",
+		kindJson:   "JSONB",
+	}
+)
+
+// createSqls builds the statements to create the table. The ClickHouse table uses the MergeTree engine ordered by the
+// time field, and the TimescaleDB table is converted to the hypertable partitioned by the time field
+func createSqls(typ string, table string, timeField string, cols []column) []string {
+	defs := make([]string, len(cols))
+	if typ == TypeClickHouse {
+		for i, col := range cols {
+			t := clickHouseTypes[col.kind]
+			if col.name != timeField {
+				t = "Nullable(" + t + ")"
+			}
+			defs[i] = fmt.Sprintf("`%s` %s", col.name, t)
+		}
+		orderBy := "tuple()"
+		if timeField != "" {
+			orderBy = fmt.Sprintf("`%s`", timeField)
+		}
+		return []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = MergeTree ORDER BY %s", table, strings.Join(defs, ", "), orderBy)}
+	}
+	for i, col := range cols {
+		defs[i] = fmt.Sprintf(`"%s" %s`, col.name, timescaleTypes[col.kind])
+		if col.name == timeField {
+			defs[i] += " NOT NULL"
+		}
+	}
+	sqls := []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table, strings.Join(defs, ", "))}
+	if timeField != "" {
+		sqls = append(sqls, fmt.Sprintf("SELECT create_hypertable('%s', '%s', if_not_exists => TRUE)", table, timeField))
+	}
+	return sqls
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *columnarSink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing columnar sink")
+	if s.done != nil {
+		close(s.done)
+		s.wg.Wait()
+	}
+	if s.w != nil {
+		return s.w.close()
+	}
+	return nil
+}
+
+func Columnar() api.Sink {
+	return &columnarSink{}
+}
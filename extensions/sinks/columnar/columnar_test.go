@@ -0,0 +1,357 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func init() {
+	testx.InitEnv()
+}
+
+type mockWriter struct {
+	sync.Mutex
+	sqls    []string
+	batches [][][]interface{}
+	err     error
+}
+
+func (w *mockWriter) exec(_ context.Context, sqls []string) error {
+	w.Lock()
+	defer w.Unlock()
+	w.sqls = append(w.sqls, sqls...)
+	return nil
+}
+
+func (w *mockWriter) write(_ context.Context, _ string, _ []column, rows [][]interface{}) error {
+	w.Lock()
+	defer w.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.batches = append(w.batches, rows)
+	return nil
+}
+
+func (w *mockWriter) close() error {
+	return nil
+}
+
+func (w *mockWriter) setErr(err error) {
+	w.Lock()
+	defer w.Unlock()
+	w.err = err
+}
+
+func (w *mockWriter) result() [][][]interface{} {
+	w.Lock()
+	defer w.Unlock()
+	return w.batches
+}
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		conf  *sinkConf
+		err   string
+	}{
+		{
+			props: map[string]interface{}{
+				"type":  "clickhouse",
+				"url":   "clickhouse://127.0.0.1:9000/default",
+				"table": "metrics",
+			},
+			conf: &sinkConf{
+				Type:          TypeClickHouse,
+				Url:           "clickhouse://127.0.0.1:9000/default",
+				Table:         "metrics",
+				BatchSize:     1000,
+				FlushInterval: 1000,
+				BufferLength:  1024,
+			},
+		}, {
+			props: map[string]interface{}{
+				"type":        "timescaledb",
+				"url":         "postgres://127.0.0.1:5432/ekuiper",
+				"table":       "metrics",
+				"fields":      []interface{}{"ts", "temperature"},
+				"timeField":   "ts",
+				"batchSize":   10,
+				"createTable": true,
+			},
+			conf: &sinkConf{
+				Type:          TypeTimescaleDB,
+				Url:           "postgres://127.0.0.1:5432/ekuiper",
+				Table:         "metrics",
+				Fields:        []string{"ts", "temperature"},
+				TimeField:     "ts",
+				BatchSize:     10,
+				FlushInterval: 1000,
+				BufferLength:  1024,
+				CreateTable:   true,
+			},
+		}, {
+			props: map[string]interface{}{
+				"type":  "mysql",
+				"url":   "mysql://127.0.0.1:3306/ekuiper",
+				"table": "metrics",
+			},
+			err: "invalid type mysql, must be clickhouse or timescaledb",
+		}, {
+			props: map[string]interface{}{
+				"type":  "clickhouse",
+				"table": "metrics",
+			},
+			err: "url is required",
+		}, {
+			props: map[string]interface{}{
+				"type": "clickhouse",
+				"url":  "clickhouse://127.0.0.1:9000/default",
+			},
+			err: "table is required",
+		}, {
+			props: map[string]interface{}{
+				"type":      "clickhouse",
+				"url":       "clickhouse://127.0.0.1:9000/default",
+				"table":     "metrics",
+				"batchSize": 0,
+			},
+			err: "batchSize must be positive",
+		}, {
+			props: map[string]interface{}{
+				"type":      "clickhouse",
+				"url":       "clickhouse://127.0.0.1:9000/default",
+				"table":     "metrics",
+				"fields":    []interface{}{"temperature"},
+				"timeField": "ts",
+			},
+			err: "timeField ts must be in the fields",
+		},
+	}
+	for i, tt := range tests {
+		s := &columnarSink{}
+		err := s.Configure(tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(s.c, tt.conf) {
+			t.Errorf("%d: expect %+v but got %+v", i, tt.conf, s.c)
+		}
+	}
+}
+
+func TestCreateSqls(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"ts": int64(1690000000000), "temperature": nil, "device": "d1", "tags": map[string]interface{}{"a": 1}},
+		{"ts": int64(1690000001000), "temperature": 23.5, "count": int64(3), "valid": true},
+	}
+	cols := inferColumns(nil, "ts", rows)
+	expCols := []column{
+		{name: "count", kind: kindInt},
+		{name: "device", kind: kindString},
+		{name: "tags", kind: kindJson},
+		{name: "temperature", kind: kindFloat},
+		{name: "ts", kind: kindTime},
+		{name: "valid", kind: kindBool},
+	}
+	if !reflect.DeepEqual(cols, expCols) {
+		t.Fatalf("expect columns %v but got %v", expCols, cols)
+	}
+	tests := []struct {
+		typ       string
+		timeField string
+		sqls      []string
+	}{
+		{
+			typ:       TypeClickHouse,
+			timeField: "ts",
+			sqls:      []string{"CREATE TABLE IF NOT EXISTS metrics (`count` Nullable(Int64), `device` Nullable(String), `tags` Nullable(String), `temperature` Nullable(Float64), `ts` DateTime64(3), `valid` Nullable(Bool)) ENGINE = MergeTree ORDER BY `ts`"},
+		}, {
+			typ:       TypeTimescaleDB,
+			timeField: "ts",
+			sqls: []string{
+				`CREATE TABLE IF NOT EXISTS metrics ("count" BIGINT, "device" TEXT, "tags" JSONB, "temperature" DOUBLE PRECISION, "ts" TIMESTAMPTZ NOT NULL, "valid" BOOLEAN)`,
+				"SELECT create_hypertable('metrics', 'ts', if_not_exists => TRUE)",
+			},
+		}, {
+			typ:  TypeClickHouse,
+			sqls: []string{"CREATE TABLE IF NOT EXISTS metrics (`count` Nullable(Int64), `device` Nullable(String), `tags` Nullable(String), `temperature` Nullable(Float64), `ts` Nullable(DateTime64(3)), `valid` Nullable(Bool)) ENGINE = MergeTree ORDER BY tuple()"},
+		},
+	}
+	for i, tt := range tests {
+		sqls := createSqls(tt.typ, "metrics", tt.timeField, cols)
+		if !reflect.DeepEqual(sqls, tt.sqls) {
+			t.Errorf("%d: expect %v but got %v", i, tt.sqls, sqls)
+		}
+	}
+}
+
+func TestRowValues(t *testing.T) {
+	cols := []column{
+		{name: "count", kind: kindInt},
+		{name: "tags", kind: kindJson},
+		{name: "temperature", kind: kindFloat},
+		{name: "ts", kind: kindTime},
+	}
+	values, err := rowValues(map[string]interface{}{"count": 3.0, "tags": []interface{}{"a"}, "ts": 1690000000000.0}, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []interface{}{int64(3), `["a"]`, nil, time.UnixMilli(1690000000000).UTC()}
+	if !reflect.DeepEqual(values, exp) {
+		t.Errorf("expect %v but got %v", exp, values)
+	}
+	_, err = rowValues(map[string]interface{}{"count": 3}, cols)
+	if err == nil || err.Error() != "missing time field ts" {
+		t.Errorf("expect missing time field error but got %v", err)
+	}
+	_, err = rowValues(map[string]interface{}{"count": "a", "ts": 1690000000000}, cols)
+	if err == nil {
+		t.Errorf("expect invalid value error")
+	}
+}
+
+func TestCollect(t *testing.T) {
+	mc := conf.Clock.(*clock.Mock)
+	ctx, cancel := mockContext.NewMockContext("ruleColumnar", "op1").WithCancel()
+	defer cancel()
+	w := &mockWriter{}
+	s := &columnarSink{w: w}
+	err := s.Configure(map[string]interface{}{
+		"type":        "clickhouse",
+		"url":         "clickhouse://127.0.0.1:9000/default",
+		"table":       "metrics",
+		"batchSize":   2,
+		"createTable": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := s.Collect(ctx, map[string]interface{}{"id": int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the full batch is written without waiting for the interval
+	waitBatches(t, w, 1)
+	// the rest is written in the flush interval
+	mc.Add(time.Second)
+	waitBatches(t, w, 2)
+	if err := s.Collect(ctx, []map[string]interface{}{{"id": int64(4)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	exp := [][][]interface{}{
+		{{int64(1)}, {int64(2)}},
+		{{int64(3)}},
+		{{int64(4)}},
+	}
+	if r := w.result(); !reflect.DeepEqual(r, exp) {
+		t.Errorf("expect %v but got %v", exp, r)
+	}
+	expSqls := []string{"CREATE TABLE IF NOT EXISTS metrics (`id` Nullable(Int64)) ENGINE = MergeTree ORDER BY tuple()"}
+	if !reflect.DeepEqual(w.sqls, expSqls) {
+		t.Errorf("expect sqls %v but got %v", expSqls, w.sqls)
+	}
+	if err := s.Close(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBackPressure(t *testing.T) {
+	ctx, cancel := mockContext.NewMockContext("ruleColumnar", "op1").WithCancel()
+	defer cancel()
+	w := &mockWriter{err: errors.New("connection refused")}
+	s := &columnarSink{w: w}
+	err := s.Configure(map[string]interface{}{
+		"type":         "timescaledb",
+		"url":          "postgres://127.0.0.1:5432/ekuiper",
+		"table":        "metrics",
+		"batchSize":    1,
+		"bufferLength": 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the first row is failed and kept, the second row is in the buffer
+	for i := 1; i <= 2; i++ {
+		if err := s.Collect(ctx, map[string]interface{}{"id": int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	collected := make(chan error)
+	go func() {
+		collected <- s.Collect(ctx, map[string]interface{}{"id": int64(3)})
+	}()
+	select {
+	case err := <-collected:
+		t.Fatalf("expect the collect blocked but returned %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := s.Flush(ctx); err == nil || err.Error() != "io error: connection refused" {
+		t.Errorf("expect flush error but got %v", err)
+	}
+	w.setErr(nil)
+	if err := <-collected; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	exp := [][][]interface{}{{{int64(1)}}, {{int64(2)}}, {{int64(3)}}}
+	if r := w.result(); !reflect.DeepEqual(r, exp) {
+		t.Errorf("expect %v but got %v", exp, r)
+	}
+	if err := s.Close(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func waitBatches(t *testing.T, w *mockWriter, n int) {
+	for i := 0; i < 100; i++ {
+		if len(w.result()) >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expect %d batches but got %d", n, len(w.result()))
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// timescaleWriter writes the batches by the COPY protocol of PostgreSQL
+type timescaleWriter struct {
+	db *sql.DB
+}
+
+func newTimescaleWriter(ctx context.Context, url string) (writer, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &timescaleWriter{db: db}, nil
+}
+
+func (w *timescaleWriter) exec(ctx context.Context, sqls []string) error {
+	for _, s := range sqls {
+		if _, err := w.db.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *timescaleWriter) write(ctx context.Context, table string, cols []column, rows [][]interface{}) (err error) {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.name
+	}
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	stmt, err := tx.PrepareContext(ctx, copyIn(table, names))
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err = stmt.ExecContext(ctx, row...); err != nil {
+			_ = stmt.Close()
+			return err
+		}
+	}
+	// the copy is finished by the exec without args
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		_ = stmt.Close()
+		return err
+	}
+	if err = stmt.Close(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// copyIn builds the COPY statement of the table which may be qualified by the schema
+func copyIn(table string, names []string) string {
+	if i := strings.IndexByte(table, '.'); i > 0 {
+		return pq.CopyInSchema(table[:i], table[i+1:], names...)
+	}
+	return pq.CopyIn(table, names...)
+}
+
+func (w *timescaleWriter) close() error {
+	return w.db.Close()
+}
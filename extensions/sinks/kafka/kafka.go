@@ -15,6 +15,7 @@
 package main
 
 import (
+	"encoding/gob"
 	"fmt"
 	"strings"
 
@@ -31,6 +32,22 @@ import (
 type kafkaSink struct {
 	writer *kafkago.Writer
 	c      *sinkConf
+	// records are the messages of the current transaction when exactlyOnce is enabled
+	records []kafkaRecord
+}
+
+// kafkaRecord is the message saved in the state of the sink until the transaction is committed
+type kafkaRecord struct {
+	Key   []byte
+	Value []byte
+}
+
+// txnHeader is the message header to identify the message in the transactions, the consumers can use it to drop the
+// duplicated messages which are sent again when the rule restores after failing in the middle of committing
+const txnHeader = "ekuiper-txn"
+
+func init() {
+	gob.Register([]kafkaRecord{})
 }
 
 const (
@@ -39,12 +56,28 @@ const (
 	SASL_SCRAM = "scram"
 )
 
+// balancers are the partition selection strategies
+var balancers = map[string]func() kafkago.Balancer{
+	"leastbytes": func() kafkago.Balancer { return &kafkago.LeastBytes{} },
+	"roundrobin": func() kafkago.Balancer { return &kafkago.RoundRobin{} },
+	"hash":       func() kafkago.Balancer { return &kafkago.Hash{} },
+	"crc32":      func() kafkago.Balancer { return &kafkago.CRC32Balancer{} },
+	"murmur2":    func() kafkago.Balancer { return &kafkago.Murmur2Balancer{} },
+}
+
 type sinkConf struct {
 	Brokers      string `json:"brokers"`
 	Topic        string `json:"topic"`
 	SaslAuthType string `json:"saslAuthType"`
 	SaslUserName string `json:"saslUserName"`
 	SaslPassword string `json:"saslPassword"`
+	// Key is the field of the result whose value is used as the message key
+	Key string `json:"key"`
+	// Partitioner is the strategy to select the partition of each message
+	Partitioner string `json:"partitioner"`
+	// ExactlyOnce buffers the messages and sends them when the checkpoint is completed. The delivery is still at least
+	// once because the client cannot produce in a kafka transaction, see Commit
+	ExactlyOnce bool `json:"exactlyOnce"`
 }
 
 func (m *kafkaSink) Configure(props map[string]interface{}) error {
@@ -52,6 +85,7 @@ func (m *kafkaSink) Configure(props map[string]interface{}) error {
 		Brokers:      "localhost:9092",
 		Topic:        "",
 		SaslAuthType: SASL_NONE,
+		Partitioner:  "leastbytes",
 	}
 	if err := cast.MapToStruct(props, c); err != nil {
 		return err
@@ -68,6 +102,9 @@ func (m *kafkaSink) Configure(props map[string]interface{}) error {
 	if (c.SaslAuthType == SASL_SCRAM || c.SaslAuthType == SASL_PLAIN) && (c.SaslUserName == "" || c.SaslPassword == "") {
 		return fmt.Errorf("username and password can not be empty")
 	}
+	if _, ok := balancers[c.Partitioner]; !ok {
+		return fmt.Errorf("partitioner must be one of leastbytes, roundrobin, hash, crc32 and murmur2")
+	}
 
 	m.c = c
 	return nil
@@ -98,7 +135,7 @@ func (m *kafkaSink) Open(ctx api.StreamContext) error {
 	w := &kafkago.Writer{
 		Addr:                   kafkago.TCP(brokers...),
 		Topic:                  m.c.Topic,
-		Balancer:               &kafkago.LeastBytes{},
+		Balancer:               balancers[m.c.Partitioner](),
 		Async:                  false,
 		AllowAutoTopicCreation: true,
 		MaxAttempts:            1,
@@ -119,22 +156,31 @@ func (m *kafkaSink) Collect(ctx api.StreamContext, item interface{}) error {
 	switch d := item.(type) {
 	case []map[string]interface{}:
 		for _, el := range d {
-			decodedBytes, _, err := ctx.TransformOutput(el)
+			msg, err := m.buildMsg(ctx, el)
 			if err != nil {
-				return fmt.Errorf("kafka sink transform data error: %v", err)
+				return err
 			}
-			messages = append(messages, kafkago.Message{Value: decodedBytes})
+			messages = append(messages, msg)
 		}
 	case map[string]interface{}:
-		decodedBytes, _, err := ctx.TransformOutput(d)
+		msg, err := m.buildMsg(ctx, d)
 		if err != nil {
-			return fmt.Errorf("kafka sink transform data error: %v", err)
+			return err
 		}
-		messages = append(messages, kafkago.Message{Value: decodedBytes})
+		messages = append(messages, msg)
 	default:
 		return fmt.Errorf("unrecognized format of %s", item)
 	}
+	if m.c.ExactlyOnce {
+		for _, msg := range messages {
+			m.records = append(m.records, kafkaRecord{Key: msg.Key, Value: msg.Value})
+		}
+		return nil
+	}
+	return m.write(ctx, messages)
+}
 
+func (m *kafkaSink) write(ctx api.StreamContext, messages []kafkago.Message) error {
 	err := m.writer.WriteMessages(ctx, messages...)
 	switch err := err.(type) {
 	case kafkago.Error:
@@ -162,10 +208,73 @@ func (m *kafkaSink) Collect(ctx api.StreamContext, item interface{}) error {
 	return err
 }
 
+func (m *kafkaSink) buildMsg(ctx api.StreamContext, item map[string]interface{}) (kafkago.Message, error) {
+	decodedBytes, _, err := ctx.TransformOutput(item)
+	if err != nil {
+		return kafkago.Message{}, fmt.Errorf("kafka sink transform data error: %v", err)
+	}
+	msg := kafkago.Message{Value: decodedBytes}
+	// the message without the key field will be sent without key
+	if m.c.Key != "" {
+		if v, ok := item[m.c.Key]; ok && v != nil {
+			msg.Key = []byte(cast.ToStringAlways(v))
+		}
+	}
+	return msg, nil
+}
+
 func (m *kafkaSink) Close(ctx api.StreamContext) error {
+	// The messages of the open transaction will be replayed from the checkpoint
+	m.records = nil
 	return m.writer.Close()
 }
 
+// PreCommit saves the messages of the transaction in the state, so that they are sent after restoring if the rule
+// fails before committing.
+func (m *kafkaSink) PreCommit(ctx api.StreamContext, checkpointId int64) error {
+	records := m.records
+	m.records = nil
+	return ctx.PutState(txnStateKey(checkpointId), records)
+}
+
+// Commit sends the messages of the transaction. The kafka transaction is not supported by the client, so it is not
+// idempotent as required by api.TwoPhaseCommitSink: if the rule fails after sending but before the next checkpoint,
+// the messages are sent again when the transaction is committed after restoring. Thus, the delivery is at least once,
+// and each message has a header of the transaction id and the sequence for the consumers to drop the duplications.
+func (m *kafkaSink) Commit(ctx api.StreamContext, checkpointId int64) error {
+	v, err := ctx.GetState(txnStateKey(checkpointId))
+	if err != nil || v == nil {
+		return err
+	}
+	records, ok := v.([]kafkaRecord)
+	if !ok {
+		return fmt.Errorf("invalid kafka transaction state %v", v)
+	}
+	if len(records) > 0 {
+		txnId := fmt.Sprintf("%s/%s/%d", ctx.GetRuleId(), ctx.GetOpId(), checkpointId)
+		messages := make([]kafkago.Message, len(records))
+		for i, r := range records {
+			messages[i] = kafkago.Message{
+				Key:     r.Key,
+				Value:   r.Value,
+				Headers: []kafkago.Header{{Key: txnHeader, Value: []byte(fmt.Sprintf("%s/%d", txnId, i))}},
+			}
+		}
+		if err := m.write(ctx, messages); err != nil {
+			return err
+		}
+	}
+	return ctx.DeleteState(txnStateKey(checkpointId))
+}
+
+func (m *kafkaSink) Abort(ctx api.StreamContext, checkpointId int64) error {
+	return ctx.DeleteState(txnStateKey(checkpointId))
+}
+
+func txnStateKey(checkpointId int64) string {
+	return fmt.Sprintf("$$kafkaTxn_%d", checkpointId)
+}
+
 func Kafka() api.Sink {
 	return &kafkaSink{}
 }
@@ -0,0 +1,123 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{},
+			err:   "topic can not be empty",
+		}, {
+			props: map[string]interface{}{"topic": "test", "saslAuthType": "plain"},
+			err:   "username and password can not be empty",
+		}, {
+			props: map[string]interface{}{"topic": "test", "partitioner": "random"},
+			err:   "partitioner must be one of leastbytes, roundrobin, hash, crc32 and murmur2",
+		}, {
+			props: map[string]interface{}{"topic": "test", "key": "id", "partitioner": "murmur2"},
+		},
+	}
+	for i, tt := range tests {
+		err := Kafka().Configure(tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestBuildMsg(t *testing.T) {
+	s := Kafka().(*kafkaSink)
+	if err := s.Configure(map[string]interface{}{"topic": "test", "key": "id"}); err != nil {
+		t.Fatal(err)
+	}
+	tf, _ := transform.GenTransform("", "json", "", "", "", []string{})
+	ctx := context.WithValue(mockContext.NewMockContext("ruleKafka", "op1").(*context.DefaultContext), context.TransKey, tf)
+	tests := []struct {
+		item map[string]interface{}
+		exp  kafkago.Message
+	}{
+		{
+			item: map[string]interface{}{"id": 12, "temperature": 20.5},
+			exp:  kafkago.Message{Key: []byte("12"), Value: []byte(`{"id":12,"temperature":20.5}`)},
+		}, {
+			item: map[string]interface{}{"temperature": 20.5},
+			exp:  kafkago.Message{Value: []byte(`{"temperature":20.5}`)},
+		},
+	}
+	for i, tt := range tests {
+		msg, err := s.buildMsg(ctx, tt.item)
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.exp, msg) {
+			t.Errorf("%d: message mismatch\nexp\t%s\ngot\t%s", i, tt.exp.Value, msg.Value)
+		}
+	}
+}
+
+func TestExactlyOnce(t *testing.T) {
+	s := Kafka().(*kafkaSink)
+	if err := s.Configure(map[string]interface{}{"topic": "test", "key": "id", "exactlyOnce": true}); err != nil {
+		t.Fatal(err)
+	}
+	tf, _ := transform.GenTransform("", "json", "", "", "", []string{})
+	ctx := context.WithValue(mockContext.NewMockContext("ruleKafka", "op1").(*context.DefaultContext), context.TransKey, tf)
+	// The messages are buffered without writing
+	if err := s.Collect(ctx, []map[string]interface{}{{"id": 1}, {"id": 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PreCommit(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	v, _ := ctx.GetState(txnStateKey(1))
+	exp := []kafkaRecord{{Key: []byte("1"), Value: []byte(`{"id":1}`)}, {Key: []byte("2"), Value: []byte(`{"id":2}`)}}
+	if !reflect.DeepEqual(v, exp) {
+		t.Errorf("transaction state mismatch, got %v", v)
+	}
+	if len(s.records) != 0 {
+		t.Errorf("records should be cleared after pre-commit")
+	}
+	if err := s.Abort(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	// The aborted transaction is not sent
+	if err := s.Commit(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := ctx.GetState(txnStateKey(1)); v != nil {
+		t.Errorf("transaction state should be deleted, got %v", v)
+	}
+}
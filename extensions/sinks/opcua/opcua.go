@@ -0,0 +1,326 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+var (
+	securityPolicies = map[string]bool{
+		"None":                  true,
+		"Basic128Rsa15":         true,
+		"Basic256":              true,
+		"Basic256Sha256":        true,
+		"Aes128_Sha256_RsaOaep": true,
+		"Aes256_Sha256_RsaPss":  true,
+	}
+	securityModes = map[string]bool{
+		"None":           true,
+		"Sign":           true,
+		"SignAndEncrypt": true,
+	}
+)
+
+type sinkConf struct {
+	Url string `json:"url"`
+	// Nodes maps the field names of the result to the ids of the nodes to write
+	Nodes map[string]string `json:"nodes"`
+	// BatchSize is the max number of nodes in one write request, 0 means no limit
+	BatchSize         int      `json:"batchSize"`
+	SecurityPolicy    string   `json:"securityPolicy"`
+	SecurityMode      string   `json:"securityMode"`
+	CertificationPath string   `json:"certificationPath"`
+	PrivateKeyPath    string   `json:"privateKeyPath"`
+	Username          string   `json:"username"`
+	Password          string   `json:"password"`
+	DataTemplate      string   `json:"dataTemplate"`
+	DataField         string   `json:"dataField"`
+	Fields            []string `json:"fields"`
+}
+
+type targetNode struct {
+	field  string
+	nodeId *ua.NodeID
+	// dataType is the id of the built-in data type of the node value, 0 if it is not a built-in type
+	dataType uint32
+}
+
+type opcuaSink struct {
+	c      *sinkConf
+	nodes  []*targetNode
+	client *opcua.Client
+}
+
+func (m *opcuaSink) Configure(props map[string]interface{}) error {
+	c := &sinkConf{
+		Url:            "opc.tcp://localhost:4840",
+		SecurityPolicy: "None",
+		SecurityMode:   "None",
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if !strings.HasPrefix(c.Url, "opc.tcp://") {
+		return fmt.Errorf("url must start with opc.tcp://")
+	}
+	if len(c.Nodes) == 0 {
+		return fmt.Errorf("nodes can not be empty")
+	}
+	if c.BatchSize < 0 {
+		return fmt.Errorf("batchSize can not be negative")
+	}
+	if !securityPolicies[c.SecurityPolicy] {
+		return fmt.Errorf("securityPolicy must be one of None, Basic128Rsa15, Basic256, Basic256Sha256, Aes128_Sha256_RsaOaep and Aes256_Sha256_RsaPss")
+	}
+	if !securityModes[c.SecurityMode] {
+		return fmt.Errorf("securityMode must be one of None, Sign and SignAndEncrypt")
+	}
+	if (c.SecurityPolicy == "None") != (c.SecurityMode == "None") {
+		return fmt.Errorf("securityPolicy and securityMode must be both None or both not None")
+	}
+	if c.SecurityMode != "None" && (c.CertificationPath == "" || c.PrivateKeyPath == "") {
+		return fmt.Errorf("certificationPath and privateKeyPath are required for securityMode %s", c.SecurityMode)
+	}
+	nodes := make([]*targetNode, 0, len(c.Nodes))
+	for field, n := range c.Nodes {
+		nodeId, err := ua.ParseNodeID(n)
+		if err != nil {
+			return fmt.Errorf("invalid node id %s of field %s: %v", n, field, err)
+		}
+		nodes = append(nodes, &targetNode{field: field, nodeId: nodeId})
+	}
+	// keep the write order stable
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].field < nodes[j].field
+	})
+	m.c = c
+	m.nodes = nodes
+	return nil
+}
+
+func (m *opcuaSink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("opening opcua sink to %s", m.c.Url)
+	if err := m.connect(ctx); err != nil {
+		return fmt.Errorf("%s: opcua sink fails to connect %s: %v", errorx.IOErr, m.c.Url, err)
+	}
+	return m.readDataTypes(ctx)
+}
+
+func (m *opcuaSink) connect(ctx api.StreamContext) error {
+	endpoints, err := opcua.GetEndpoints(ctx, m.c.Url)
+	if err != nil {
+		return err
+	}
+	ep := opcua.SelectEndpoint(endpoints, m.c.SecurityPolicy, ua.MessageSecurityModeFromString(m.c.SecurityMode))
+	if ep == nil {
+		return fmt.Errorf("no endpoint found for securityPolicy %s and securityMode %s", m.c.SecurityPolicy, m.c.SecurityMode)
+	}
+	opts := []opcua.Option{
+		opcua.SecurityPolicy(m.c.SecurityPolicy),
+		opcua.SecurityModeString(m.c.SecurityMode),
+	}
+	if m.c.CertificationPath != "" {
+		opts = append(opts, opcua.CertificateFile(m.c.CertificationPath), opcua.PrivateKeyFile(m.c.PrivateKeyPath))
+	}
+	if m.c.Username != "" {
+		opts = append(opts, opcua.AuthUsername(m.c.Username, m.c.Password), opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeUserName))
+	} else {
+		opts = append(opts, opcua.AuthAnonymous(), opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeAnonymous))
+	}
+	m.client = opcua.NewClient(ep.EndpointURL, opts...)
+	return m.client.Connect(ctx)
+}
+
+// readDataTypes reads the data type of the nodes so that the values can be coerced before writing
+func (m *opcuaSink) readDataTypes(ctx api.StreamContext) error {
+	req := &ua.ReadRequest{TimestampsToReturn: ua.TimestampsToReturnNeither}
+	for _, n := range m.nodes {
+		req.NodesToRead = append(req.NodesToRead, &ua.ReadValueID{NodeID: n.nodeId, AttributeID: ua.AttributeIDDataType})
+	}
+	resp, err := m.client.ReadWithContext(ctx, req)
+	if err != nil {
+		return fmt.Errorf("%s: opcua sink fails to read the data types: %v", errorx.IOErr, err)
+	}
+	for i, r := range resp.Results {
+		if r.Status != ua.StatusOK {
+			return fmt.Errorf("read data type of node %s error: %v", m.nodes[i].nodeId, r.Status)
+		}
+		// the values of other types such as enumerations and structures are written as is
+		if dt, ok := r.Value.Value().(*ua.NodeID); ok && dt.Namespace() == 0 && dt.IntID() <= id.DateTime {
+			m.nodes[i].dataType = dt.IntID()
+		}
+	}
+	return nil
+}
+
+func (m *opcuaSink) Collect(ctx api.StreamContext, item interface{}) error {
+	logger := ctx.GetLogger()
+	logger.Debugf("opcua sink receive %s", item)
+	if m.c.DataTemplate != "" {
+		jsonBytes, _, err := ctx.TransformOutput(item)
+		if err != nil {
+			return err
+		}
+		tm := make(map[string]interface{})
+		err = json.Unmarshal(jsonBytes, &tm)
+		if err != nil {
+			return fmt.Errorf("fail to decode data %s after applying dataTemplate for error %v", string(jsonBytes), err)
+		}
+		item = tm
+	} else {
+		tm, _, err := transform.TransItem(item, m.c.DataField, m.c.Fields)
+		if err != nil {
+			return fmt.Errorf("fail to transform data %v for error %v", item, err)
+		}
+		item = tm
+	}
+	var rows []map[string]interface{}
+	switch v := item.(type) {
+	case map[string]interface{}:
+		rows = []map[string]interface{}{v}
+	case []map[string]interface{}:
+		rows = v
+	default:
+		return fmt.Errorf("unsupported data %v", item)
+	}
+	values, err := m.buildWriteValues(rows)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		logger.Debugf("opcua sink has no node to write")
+		return nil
+	}
+	size := m.c.BatchSize
+	if size == 0 {
+		size = len(values)
+	}
+	for start := 0; start < len(values); start += size {
+		end := start + size
+		if end > len(values) {
+			end = len(values)
+		}
+		if err := m.write(ctx, values[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildWriteValues coerces the field values of the rows to the data type of the nodes. The nil and absent fields are not written
+func (m *opcuaSink) buildWriteValues(rows []map[string]interface{}) ([]*ua.WriteValue, error) {
+	var values []*ua.WriteValue
+	for _, row := range rows {
+		for _, n := range m.nodes {
+			v, ok := row[n.field]
+			if !ok || v == nil {
+				continue
+			}
+			cv, err := coerce(v, n.dataType)
+			if err != nil {
+				return nil, fmt.Errorf("field %s can not be written to node %s: %v", n.field, n.nodeId, err)
+			}
+			variant, err := ua.NewVariant(cv)
+			if err != nil {
+				return nil, fmt.Errorf("field %s can not be written to node %s: %v", n.field, n.nodeId, err)
+			}
+			values = append(values, &ua.WriteValue{
+				NodeID:      n.nodeId,
+				AttributeID: ua.AttributeIDValue,
+				Value: &ua.DataValue{
+					EncodingMask: ua.DataValueValue,
+					Value:        variant,
+				},
+			})
+		}
+	}
+	return values, nil
+}
+
+func (m *opcuaSink) write(ctx api.StreamContext, values []*ua.WriteValue) error {
+	resp, err := m.client.WriteWithContext(ctx, &ua.WriteRequest{NodesToWrite: values})
+	if err != nil {
+		return fmt.Errorf("%s: opcua sink fails to write: %v", errorx.IOErr, err)
+	}
+	var errs []string
+	for i, code := range resp.Results {
+		if code != ua.StatusOK {
+			errs = append(errs, fmt.Sprintf("node %s: %v", values[i].NodeID, code))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("opcua sink fails to write %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// coerce converts the value to the Go type of the built-in data type
+func coerce(v interface{}, dataType uint32) (interface{}, error) {
+	switch dataType {
+	case id.Boolean:
+		return cast.ToBool(v, cast.CONVERT_SAMEKIND)
+	case id.SByte:
+		return cast.ToInt8(v, cast.CONVERT_SAMEKIND)
+	case id.Byte:
+		return cast.ToUint8(v, cast.CONVERT_SAMEKIND)
+	case id.Int16:
+		return cast.ToInt16(v, cast.CONVERT_SAMEKIND)
+	case id.UInt16:
+		return cast.ToUint16(v, cast.CONVERT_SAMEKIND)
+	case id.Int32:
+		return cast.ToInt32(v, cast.CONVERT_SAMEKIND)
+	case id.UInt32:
+		return cast.ToUint32(v, cast.CONVERT_SAMEKIND)
+	case id.Int64:
+		return cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+	case id.UInt64:
+		return cast.ToUint64(v, cast.CONVERT_SAMEKIND)
+	case id.Float:
+		return cast.ToFloat32(v, cast.CONVERT_SAMEKIND)
+	case id.Double:
+		return cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+	case id.String:
+		return cast.ToString(v, cast.CONVERT_ALL)
+	case id.DateTime:
+		return cast.InterfaceToTime(v, "")
+	default:
+		return v, nil
+	}
+}
+
+func (m *opcuaSink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing opcua sink")
+	if m.client != nil {
+		return m.client.CloseWithContext(ctx)
+	}
+	return nil
+}
+
+func Opcua() api.Sink {
+	return &opcuaSink{}
+}
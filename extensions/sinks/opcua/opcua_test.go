@@ -0,0 +1,113 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"url": "localhost:4840", "nodes": map[string]interface{}{"speed": "ns=2;s=Speed"}},
+			err:   "url must start with opc.tcp://",
+		}, {
+			props: map[string]interface{}{},
+			err:   "nodes can not be empty",
+		}, {
+			props: map[string]interface{}{"nodes": map[string]interface{}{"speed": "ns=2;s=Speed"}, "batchSize": -1},
+			err:   "batchSize can not be negative",
+		}, {
+			props: map[string]interface{}{"nodes": map[string]interface{}{"speed": "ns=2;s=Speed"}, "securityMode": "Sign"},
+			err:   "securityPolicy and securityMode must be both None or both not None",
+		}, {
+			props: map[string]interface{}{"nodes": map[string]interface{}{"speed": "ns=2;s=Speed"}, "securityPolicy": "Basic256Sha256", "securityMode": "SignAndEncrypt"},
+			err:   "certificationPath and privateKeyPath are required for securityMode SignAndEncrypt",
+		}, {
+			props: map[string]interface{}{"nodes": map[string]interface{}{"speed": "ns=2;s=Speed", "running": "ns=2;i=1002"}, "batchSize": 10},
+		},
+	}
+	for i, tt := range tests {
+		err := Opcua().Configure(tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+	if err := Opcua().Configure(map[string]interface{}{"nodes": map[string]interface{}{"speed": "ns=2;i=abc"}}); err == nil {
+		t.Errorf("expect error for invalid node id")
+	}
+}
+
+func TestBuildWriteValues(t *testing.T) {
+	speed, running, label := ua.NewStringNodeID(2, "Speed"), ua.NewNumericNodeID(2, 1002), ua.NewStringNodeID(2, "Label")
+	m := &opcuaSink{nodes: []*targetNode{
+		{field: "label", nodeId: label, dataType: id.String},
+		{field: "running", nodeId: running, dataType: id.Boolean},
+		{field: "speed", nodeId: speed, dataType: id.Int16},
+	}}
+	value := func(nodeId *ua.NodeID, v interface{}) *ua.WriteValue {
+		return &ua.WriteValue{
+			NodeID:      nodeId,
+			AttributeID: ua.AttributeIDValue,
+			Value:       &ua.DataValue{EncodingMask: ua.DataValueValue, Value: ua.MustVariant(v)},
+		}
+	}
+	tests := []struct {
+		rows []map[string]interface{}
+		exp  []*ua.WriteValue
+		err  string
+	}{
+		{
+			rows: []map[string]interface{}{{"speed": float64(120), "running": true, "label": 3}},
+			exp:  []*ua.WriteValue{value(label, "3"), value(running, true), value(speed, int16(120))},
+		}, {
+			// nil and absent fields are not written, all the rows are written in one batch
+			rows: []map[string]interface{}{{"speed": int64(1), "running": nil}, {"speed": int64(2), "other": 1}},
+			exp:  []*ua.WriteValue{value(speed, int16(1)), value(speed, int16(2))},
+		}, {
+			rows: []map[string]interface{}{{"running": "yes"}},
+			err:  "field running can not be written to node ns=2;i=1002: cannot convert string(yes) to bool",
+		},
+	}
+	for i, tt := range tests {
+		values, err := m.buildWriteValues(tt.rows)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.exp, values) {
+			t.Errorf("%d: write values mismatch, got %v", i, values)
+		}
+	}
+}
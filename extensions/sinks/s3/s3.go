@@ -0,0 +1,303 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/lf-edge/ekuiper/internal/compressor"
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// maxPending limits the number of the rolled files waiting to be uploaded, the oldest are dropped if exceeded
+const maxPending = 10
+
+var extensions = map[string]string{
+	"":     "",
+	"gzip": ".gz",
+	"zstd": ".zst",
+}
+
+type sinkConf struct {
+	// Endpoint is the url of the S3 compatible service such as http://127.0.0.1:9000 of MinIO, the AWS endpoint of the
+	// region is used if not set
+	Endpoint  string `json:"endpoint"`
+	Region    string `json:"region"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	Bucket    string `json:"bucket"`
+	// PathStyle puts the bucket in the path instead of the host, it is usually required by MinIO
+	PathStyle bool `json:"pathStyle"`
+	// Key is the template of the object key, the variables are rule, date, hour, timestamp and n
+	Key string `json:"key"`
+	// Compression is the algorithm to compress the file, gzip or zstd
+	Compression string `json:"compression"`
+	// RollingInterval is the interval in milliseconds to roll the file
+	RollingInterval int `json:"rollingInterval"`
+	// RollingSize is the max size in bytes of the data of a file before compression
+	RollingSize int `json:"rollingSize"`
+	// RollingCount is the max number of the results in a file
+	RollingCount int `json:"rollingCount"`
+}
+
+// uploader puts an object into the bucket
+type uploader interface {
+	put(ctx context.Context, bucket string, key string, data []byte) error
+}
+
+type s3Uploader struct {
+	cli *s3.Client
+}
+
+func (u *s3Uploader) put(ctx context.Context, bucket string, key string, data []byte) error {
+	_, err := u.cli.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+// file is the rolling file buffered in memory
+type file struct {
+	key   string
+	buf   *bytes.Buffer
+	w     io.Writer
+	size  int
+	count int
+}
+
+type object struct {
+	key  string
+	data []byte
+}
+
+type s3Sink struct {
+	c        *sinkConf
+	uploader uploader
+	ruleId   string
+
+	mu  sync.Mutex
+	cur *file
+	// pending are the rolled files to upload
+	pending []object
+	// n is the sequence of the files
+	n int
+}
+
+func (s *s3Sink) Configure(props map[string]interface{}) error {
+	c := &sinkConf{
+		Region:          "us-east-1",
+		RollingInterval: 60000,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Bucket == "" {
+		return fmt.Errorf("bucket is required")
+	}
+	if c.Region == "" {
+		return fmt.Errorf("region is required")
+	}
+	if (c.AccessKey == "") != (c.SecretKey == "") {
+		return fmt.Errorf("accessKey and secretKey must be set together")
+	}
+	ext, ok := extensions[c.Compression]
+	if !ok {
+		return fmt.Errorf("invalid compression %s, must be gzip or zstd", c.Compression)
+	}
+	if c.RollingInterval < 0 || c.RollingSize < 0 || c.RollingCount < 0 {
+		return fmt.Errorf("rollingInterval, rollingSize and rollingCount must not be negative")
+	}
+	if c.RollingInterval == 0 && c.RollingSize == 0 && c.RollingCount == 0 {
+		return fmt.Errorf("one of rollingInterval, rollingSize and rollingCount must be set")
+	}
+	if c.Key == "" {
+		c.Key = "{{.rule}}/{{.date}}/part-{{.timestamp}}-{{.n}}.json" + ext
+	}
+	s.c = c
+	return nil
+}
+
+// CompressBySelf The compression property is to compress the whole file instead of each payload
+func (s *s3Sink) CompressBySelf() bool {
+	return true
+}
+
+func (s *s3Sink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("opening s3 sink to bucket %s", s.c.Bucket)
+	s.ruleId = ctx.GetRuleId()
+	if s.uploader == nil {
+		o := s3.Options{
+			Region:       s.c.Region,
+			UsePathStyle: s.c.PathStyle,
+		}
+		if s.c.AccessKey != "" {
+			o.Credentials = credentials.NewStaticCredentialsProvider(s.c.AccessKey, s.c.SecretKey, "")
+		}
+		if s.c.Endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(s.c.Endpoint)
+		}
+		s.uploader = &s3Uploader{cli: s3.New(o)}
+	}
+	if s.c.RollingInterval > 0 {
+		go s.run(ctx)
+	}
+	return nil
+}
+
+// run rolls and uploads the file in the interval
+func (s *s3Sink) run(ctx api.StreamContext) {
+	ticker := conf.GetTicker(s.c.RollingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Flush(ctx); err != nil {
+				ctx.GetLogger().Errorf("s3 sink fails to upload: %v", err)
+			}
+		}
+	}
+}
+
+// Collect writes the result as a line of the current file. The file is rolled and uploaded if it reaches the
+// rolling size or count
+func (s *s3Sink) Collect(ctx api.StreamContext, item interface{}) error {
+	b, _, err := ctx.TransformOutput(item)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cur == nil {
+		f, err := s.newFile(ctx)
+		if err != nil {
+			return err
+		}
+		s.cur = f
+	}
+	if _, err := s.cur.w.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("s3 sink fails to write the file %s: %v", s.cur.key, err)
+	}
+	s.cur.size += len(b) + 1
+	s.cur.count++
+	if (s.c.RollingSize > 0 && s.cur.size >= s.c.RollingSize) || (s.c.RollingCount > 0 && s.cur.count >= s.c.RollingCount) {
+		return s.flush(ctx, ctx.GetLogger())
+	}
+	return nil
+}
+
+// newFile creates the file with the key rendered by the current time
+func (s *s3Sink) newFile(ctx api.StreamContext) (*file, error) {
+	now := conf.GetNow()
+	key, err := ctx.ParseTemplate(s.c.Key, map[string]interface{}{
+		"rule":      s.ruleId,
+		"date":      now.Format("2006-01-02"),
+		"hour":      now.Format("15"),
+		"timestamp": now.UnixMilli(),
+		"n":         s.n,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parse template for key %s error: %v", s.c.Key, err)
+	}
+	s.n++
+	f := &file{key: key, buf: &bytes.Buffer{}}
+	f.w = f.buf
+	if s.c.Compression != "" {
+		f.w, err = compressor.GetCompressWriter(s.c.Compression, f.buf)
+		if err != nil {
+			return nil, fmt.Errorf("fail to get compress writer for %s: %v", s.c.Compression, err)
+		}
+	}
+	return f, nil
+}
+
+// roll closes the current file and puts it into the pending list
+func (s *s3Sink) roll(logger api.Logger) error {
+	if s.cur == nil {
+		return nil
+	}
+	f := s.cur
+	s.cur = nil
+	if c, ok := f.w.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			return fmt.Errorf("s3 sink fails to close the file %s: %v", f.key, err)
+		}
+	}
+	s.pending = append(s.pending, object{key: f.key, data: f.buf.Bytes()})
+	if len(s.pending) > maxPending {
+		logger.Warnf("s3 sink drops %d files for too many files are waiting to upload", len(s.pending)-maxPending)
+		s.pending = s.pending[len(s.pending)-maxPending:]
+	}
+	return nil
+}
+
+// upload puts the pending files in order. The failed files are kept to retry in the next upload
+func (s *s3Sink) upload(ctx context.Context, logger api.Logger) error {
+	for len(s.pending) > 0 {
+		o := s.pending[0]
+		if err := s.uploader.put(ctx, s.c.Bucket, o.key, o.data); err != nil {
+			return fmt.Errorf("upload %s to bucket %s error: %v", o.key, s.c.Bucket, err)
+		}
+		logger.Debugf("s3 sink uploads %s of %d bytes", o.key, len(o.data))
+		s.pending = s.pending[1:]
+	}
+	s.pending = nil
+	return nil
+}
+
+// Flush rolls the current file and uploads all the files. It is called before the checkpoint
+func (s *s3Sink) Flush(ctx api.StreamContext) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(ctx, ctx.GetLogger())
+}
+
+func (s *s3Sink) flush(ctx context.Context, logger api.Logger) error {
+	if err := s.roll(logger); err != nil {
+		return err
+	}
+	return s.upload(ctx, logger)
+}
+
+func (s *s3Sink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing s3 sink")
+	if s.uploader == nil {
+		return nil
+	}
+	// the rule context may be cancelled, upload the remaining files in a limited time
+	c, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(c, ctx.GetLogger())
+}
+
+func S3() api.Sink {
+	return &s3Sink{}
+}
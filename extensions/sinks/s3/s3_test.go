@@ -0,0 +1,198 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	gocontext "context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+)
+
+func init() {
+	testx.InitEnv()
+}
+
+type mockUploader struct {
+	err     error
+	keys    []string
+	objects [][]byte
+}
+
+func (u *mockUploader) put(_ gocontext.Context, bucket string, key string, data []byte) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.keys = append(u.keys, bucket+"/"+key)
+	u.objects = append(u.objects, data)
+	return nil
+}
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		key   string
+		err   string
+	}{
+		{
+			props: map[string]interface{}{},
+			err:   "bucket is required",
+		}, {
+			props: map[string]interface{}{"bucket": "b1", "accessKey": "ak"},
+			err:   "accessKey and secretKey must be set together",
+		}, {
+			props: map[string]interface{}{"bucket": "b1", "compression": "zlib"},
+			err:   "invalid compression zlib, must be gzip or zstd",
+		}, {
+			props: map[string]interface{}{"bucket": "b1", "rollingInterval": 0},
+			err:   "one of rollingInterval, rollingSize and rollingCount must be set",
+		}, {
+			props: map[string]interface{}{"bucket": "b1", "rollingSize": -1},
+			err:   "rollingInterval, rollingSize and rollingCount must not be negative",
+		}, {
+			props: map[string]interface{}{"bucket": "b1", "compression": "gzip"},
+			key:   "{{.rule}}/{{.date}}/part-{{.timestamp}}-{{.n}}.json.gz",
+		}, {
+			props: map[string]interface{}{"bucket": "b1", "key": "{{.rule}}/{{.n}}.json"},
+			key:   "{{.rule}}/{{.n}}.json",
+		},
+	}
+	for i, tt := range tests {
+		s := S3().(*s3Sink)
+		err := s.Configure(tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if s.c.Key != tt.key {
+			t.Errorf("%d: expect key %s but got %s", i, tt.key, s.c.Key)
+		}
+	}
+}
+
+func newContext() (*context.DefaultContext, gocontext.CancelFunc) {
+	tf, _ := transform.GenTransform("", "json", "", "", "", []string{})
+	ctx, cancel := mockContext.NewMockContext("ruleS3", "op1").WithCancel()
+	return context.WithValue(ctx.(*context.DefaultContext), context.TransKey, tf), cancel
+}
+
+func TestCollect(t *testing.T) {
+	u := &mockUploader{}
+	s := &s3Sink{uploader: u}
+	err := s.Configure(map[string]interface{}{
+		"bucket":          "b1",
+		"key":             "{{.rule}}/part-{{.n}}.json.gz",
+		"compression":     "gzip",
+		"rollingInterval": 0,
+		"rollingCount":    2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := newContext()
+	defer cancel()
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	data := []map[string]interface{}{{"a": 1}, {"a": 2}, {"a": 3}}
+	for _, d := range data {
+		if err := s.Collect(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(u.keys) != 1 {
+		t.Fatalf("expect 1 object before flush but got %v", u.keys)
+	}
+	if err := s.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// nothing to upload
+	if err := s.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expKeys := []string{"b1/ruleS3/part-0.json.gz", "b1/ruleS3/part-1.json.gz"}
+	if !reflect.DeepEqual(u.keys, expKeys) {
+		t.Errorf("expect keys %v but got %v", expKeys, u.keys)
+	}
+	expContents := []string{"{\"a\":1}\n{\"a\":2}\n", "{\"a\":3}\n"}
+	for i, o := range u.objects {
+		r, err := gzip.NewReader(bytes.NewReader(o))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expContents[i] {
+			t.Errorf("%d: expect content %s but got %s", i, expContents[i], string(b))
+		}
+	}
+}
+
+func TestUploadError(t *testing.T) {
+	u := &mockUploader{err: errors.New("connection refused")}
+	s := &s3Sink{uploader: u}
+	err := s.Configure(map[string]interface{}{
+		"bucket":          "b1",
+		"key":             "{{.rule}}/part-{{.n}}.json",
+		"rollingInterval": 0,
+		"rollingSize":     10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := newContext()
+	defer cancel()
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err = s.Collect(ctx, map[string]interface{}{"a": 100})
+	expErr := "upload ruleS3/part-0.json to bucket b1 error: connection refused"
+	if err == nil || err.Error() != expErr {
+		t.Errorf("expect error %s but got %v", expErr, err)
+	}
+	// the size is not reached
+	if err := s.Collect(ctx, map[string]interface{}{"b": 2}); err != nil {
+		t.Fatal(err)
+	}
+	// the failed file is kept and uploaded in order
+	u.err = nil
+	if err := s.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expKeys := []string{"b1/ruleS3/part-0.json", "b1/ruleS3/part-1.json"}
+	if !reflect.DeepEqual(u.keys, expKeys) {
+		t.Errorf("expect keys %v but got %v", expKeys, u.keys)
+	}
+	expObjects := [][]byte{[]byte("{\"a\":100}\n"), []byte("{\"b\":2}\n")}
+	if !reflect.DeepEqual(u.objects, expObjects) {
+		t.Errorf("expect objects %s but got %s", expObjects, u.objects)
+	}
+}
@@ -15,9 +15,12 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/lf-edge/ekuiper/extensions/sqldatabase"
@@ -39,8 +42,22 @@ type sqlConfig struct {
 	DataField      string   `json:"dataField"`
 	RowkindField   string   `json:"rowkindField"`
 	KeyField       string   `json:"keyField"`
+	// BatchSize is the max number of rows written in a transaction by the prepared statements. The prepared statements
+	// are used if it is set or upsert is enabled
+	BatchSize int `json:"batchSize"`
+	// Upsert updates the existing row if the key conflicts
+	Upsert bool `json:"upsert"`
+	// UpsertKeys are the fields of the unique key to detect the conflicts, default to the keyField
+	UpsertKeys []string `json:"upsertKeys"`
+	// ColumnMapping maps the field names to the column names, the field name is used if not mapped
+	ColumnMapping map[string]string `json:"columnMapping"`
+	// ConnectionId is the id of the shared sql connection in the connection pool, the url of the connection is used
+	ConnectionId string `json:"connectionId"`
+	driver       string
 }
 
+const defaultBatchSize = 100
+
 func (t *sqlConfig) buildInsertSql(ctx api.StreamContext, mapData map[string]interface{}) ([]string, string, error) {
 	keys, vals, err := t.getKeyValues(ctx, mapData)
 	if err != nil {
@@ -64,7 +81,7 @@ func (t *sqlConfig) getKeyValues(ctx api.StreamContext, mapData map[string]inter
 
 	if len(t.Fields) != 0 {
 		for _, k := range t.Fields {
-			keys = append(keys, k)
+			keys = append(keys, t.column(k))
 			if v, ok := mapData[k]; ok && v != nil {
 				if reflect.String == reflect.TypeOf(v).Kind() {
 					vals = append(vals, fmt.Sprintf("'%v'", v))
@@ -78,7 +95,7 @@ func (t *sqlConfig) getKeyValues(ctx api.StreamContext, mapData map[string]inter
 		}
 	} else {
 		for k, v := range mapData {
-			keys = append(keys, k)
+			keys = append(keys, t.column(k))
 			if reflect.String == reflect.TypeOf(v).Kind() {
 				vals = append(vals, fmt.Sprintf("'%v'", v))
 			} else {
@@ -89,6 +106,104 @@ func (t *sqlConfig) getKeyValues(ctx api.StreamContext, mapData map[string]inter
 	return keys, vals, nil
 }
 
+func (t *sqlConfig) column(field string) string {
+	if c, ok := t.ColumnMapping[field]; ok {
+		return c
+	}
+	return field
+}
+
+// rowArgs gets the columns and the values of a row for the prepared statement. The columns are the fields or the
+// sorted keys of the row, so that the rows with the same keys share the statement
+func (t *sqlConfig) rowArgs(row map[string]interface{}) ([]string, []interface{}) {
+	fields := t.Fields
+	if len(fields) == 0 {
+		fields = make([]string, 0, len(row))
+		for k := range row {
+			fields = append(fields, k)
+		}
+		sort.Strings(fields)
+	}
+	cols := make([]string, len(fields))
+	args := make([]interface{}, len(fields))
+	for i, f := range fields {
+		cols[i] = t.column(f)
+		v := row[f]
+		switch v.(type) {
+		case map[string]interface{}, []interface{}, []map[string]interface{}:
+			if b, err := json.Marshal(v); err == nil {
+				v = string(b)
+			}
+		}
+		args[i] = v
+	}
+	return cols, args
+}
+
+// buildStatement builds the insert or upsert statement with the placeholders of the driver
+func (t *sqlConfig) buildStatement(table string, cols []string) (string, error) {
+	quoted := cols
+	if t.driver == "dm" {
+		quoted = make([]string, len(cols))
+		for i, c := range cols {
+			quoted[i] = fmt.Sprintf(`"%v"`, c)
+		}
+	}
+	holders := make([]string, len(cols))
+	for i := range cols {
+		holders[i] = placeholder(t.driver, i+1)
+	}
+	sqlStr := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(quoted, ","), strings.Join(holders, ","))
+	if !t.Upsert {
+		return sqlStr, nil
+	}
+	keys := make(map[string]struct{}, len(t.UpsertKeys))
+	keyCols := make([]string, len(t.UpsertKeys))
+	for i, k := range t.UpsertKeys {
+		keyCols[i] = t.column(k)
+		keys[keyCols[i]] = struct{}{}
+	}
+	var updates []string
+	switch t.driver {
+	case "mysql":
+		for _, c := range cols {
+			if _, ok := keys[c]; !ok {
+				updates = append(updates, fmt.Sprintf("%s=VALUES(%s)", c, c))
+			}
+		}
+		// update nothing if all columns are the keys
+		if len(updates) == 0 {
+			updates = append(updates, fmt.Sprintf("%s=%s", cols[0], cols[0]))
+		}
+		return sqlStr + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ","), nil
+	case "postgres", "pgx", "sqlite3", "moderncsqlite":
+		for _, c := range cols {
+			if _, ok := keys[c]; !ok {
+				updates = append(updates, fmt.Sprintf("%s=EXCLUDED.%s", c, c))
+			}
+		}
+		if len(updates) == 0 {
+			return sqlStr + fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", strings.Join(keyCols, ",")), nil
+		}
+		return sqlStr + fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keyCols, ","), strings.Join(updates, ",")), nil
+	default:
+		return "", fmt.Errorf("upsert is not supported for driver %s", t.driver)
+	}
+}
+
+func placeholder(driver string, i int) string {
+	switch driver {
+	case "postgres", "pgx":
+		return "$" + strconv.Itoa(i)
+	case "sqlserver":
+		return "@p" + strconv.Itoa(i)
+	case "godror", "oracle":
+		return ":" + strconv.Itoa(i)
+	default:
+		return "?"
+	}
+}
+
 type sqlSink struct {
 	conf *sqlConfig
 	// The db connection instance
@@ -104,6 +219,11 @@ func (m *sqlSink) Configure(props map[string]interface{}) error {
 	if cfg.DataField == "" {
 		cfg.DataField = cfg.TableDataField
 	}
+	if cfg.ConnectionId != "" {
+		if cfg.Url, err = util.ConnectionUrl(cfg.ConnectionId); err != nil {
+			return err
+		}
+	}
 	if cfg.Url == "" {
 		return fmt.Errorf("property Url is required")
 	}
@@ -113,6 +233,33 @@ func (m *sqlSink) Configure(props map[string]interface{}) error {
 	if cfg.RowkindField != "" && cfg.KeyField == "" {
 		return fmt.Errorf("keyField is required when rowkindField is set")
 	}
+	if cfg.BatchSize < 0 {
+		return fmt.Errorf("batchSize must not be negative")
+	}
+	if cfg.BatchSize > 0 || cfg.Upsert {
+		if cfg.RowkindField != "" {
+			return fmt.Errorf("rowkindField cannot be used with batchSize or upsert")
+		}
+		if cfg.BatchSize == 0 {
+			cfg.BatchSize = defaultBatchSize
+		}
+		driver, err := util.ParseDriver(cfg.Url)
+		if err != nil {
+			return fmt.Errorf("dburl.Parse %s fail with error: %v", cfg.Url, err)
+		}
+		cfg.driver = driver
+	}
+	if cfg.Upsert {
+		if len(cfg.UpsertKeys) == 0 && cfg.KeyField != "" {
+			cfg.UpsertKeys = []string{cfg.KeyField}
+		}
+		if len(cfg.UpsertKeys) == 0 && cfg.driver != "mysql" {
+			return fmt.Errorf("upsertKeys or keyField is required for upsert")
+		}
+		if _, err := cfg.buildStatement(cfg.Table, []string{"test"}); err != nil {
+			return err
+		}
+	}
 	m.conf = cfg
 	return nil
 }
@@ -120,7 +267,7 @@ func (m *sqlSink) Configure(props map[string]interface{}) error {
 func (m *sqlSink) Open(ctx api.StreamContext) (err error) {
 	logger := ctx.GetLogger()
 	logger.Debugf("Opening sql sink")
-	db, err := util.FetchDBToOneNode(util.GlobalPool, m.conf.Url)
+	db, err := util.FetchDB(m.conf.Url, m.conf.ConnectionId)
 	if err != nil {
 		logger.Errorf("support build tags are %v", driver.KnownBuildTags())
 		return err
@@ -143,6 +290,65 @@ func (m *sqlSink) writeToDB(ctx api.StreamContext, sqlStr *string) error {
 	return nil
 }
 
+// writeRows writes the rows by the prepared statements in the transactions of the batch size
+func (m *sqlSink) writeRows(ctx api.StreamContext, table string, rows []map[string]interface{}) error {
+	db, ok := m.db.(*sql.DB)
+	if !ok {
+		return fmt.Errorf("prepared statements are not supported by the db")
+	}
+	for i := 0; i < len(rows); i += m.conf.BatchSize {
+		end := i + m.conf.BatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := m.writeBatch(ctx, db, table, rows[i:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *sqlSink) writeBatch(ctx api.StreamContext, db *sql.DB, table string, rows []map[string]interface{}) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+	}
+	stmts := make(map[string]*sql.Stmt)
+	defer func() {
+		for _, stmt := range stmts {
+			_ = stmt.Close()
+		}
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	for _, row := range rows {
+		cols, args := m.conf.rowArgs(row)
+		key := strings.Join(cols, ",")
+		stmt, ok := stmts[key]
+		if !ok {
+			sqlStr, err := m.conf.buildStatement(table, cols)
+			if err != nil {
+				return err
+			}
+			ctx.GetLogger().Debugf("prepare %s", sqlStr)
+			stmt, err = tx.PrepareContext(ctx, sqlStr)
+			if err != nil {
+				return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+			}
+			stmts[key] = stmt
+		}
+		if _, err = stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+	}
+	ctx.GetLogger().Debugf("Rows written: %d", len(rows))
+	return nil
+}
+
 func (m *sqlSink) Collect(ctx api.StreamContext, item interface{}) error {
 	ctx.GetLogger().Debugf("sql sink receive %s", item)
 	if m.conf.DataTemplate != "" {
@@ -191,6 +397,28 @@ func (m *sqlSink) Collect(ctx api.StreamContext, item interface{}) error {
 	var values []string = nil
 	var vars string
 
+	if m.conf.BatchSize > 0 {
+		var rows []map[string]interface{}
+		switch v := item.(type) {
+		case map[string]interface{}:
+			rows = []map[string]interface{}{v}
+		case []map[string]interface{}:
+			rows = v
+		case []interface{}:
+			rows = make([]map[string]interface{}, 0, len(v))
+			for _, data := range v {
+				mapData, ok := data.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("unsupported type: %T", data)
+				}
+				rows = append(rows, mapData)
+			}
+		default:
+			return fmt.Errorf("unsupported type: %T", item)
+		}
+		return m.writeRows(ctx, table, rows)
+	}
+
 	if m.conf.RowkindField == "" {
 		switch v := item.(type) {
 		case []map[string]interface{}:
@@ -276,7 +504,7 @@ func (m *sqlSink) Collect(ctx api.StreamContext, item interface{}) error {
 
 func (m *sqlSink) Close(_ api.StreamContext) error {
 	if m.db != nil {
-		return util.ReturnDBFromOneNode(util.GlobalPool, m.conf.Url)
+		return util.ReturnDB(m.conf.Url, m.conf.ConnectionId)
 	}
 	return nil
 }
@@ -322,9 +550,9 @@ func (m *sqlSink) save(ctx api.StreamContext, table string, data map[string]inte
 			sqlStr += fmt.Sprintf("%s=%s", key, vals[i])
 		}
 		if _, ok := keyval.(string); ok {
-			sqlStr += fmt.Sprintf(" WHERE %s = '%s';", m.conf.KeyField, keyval)
+			sqlStr += fmt.Sprintf(" WHERE %s = '%s';", m.conf.column(m.conf.KeyField), keyval)
 		} else {
-			sqlStr += fmt.Sprintf(" WHERE %s = %v;", m.conf.KeyField, keyval)
+			sqlStr += fmt.Sprintf(" WHERE %s = %v;", m.conf.column(m.conf.KeyField), keyval)
 		}
 	case ast.RowkindDelete:
 		keyval, ok := data[m.conf.KeyField]
@@ -332,9 +560,9 @@ func (m *sqlSink) save(ctx api.StreamContext, table string, data map[string]inte
 			return fmt.Errorf("field %s does not exist in data %v", m.conf.KeyField, data)
 		}
 		if _, ok := keyval.(string); ok {
-			sqlStr = fmt.Sprintf("DELETE FROM %s WHERE %s = '%s';", table, m.conf.KeyField, keyval)
+			sqlStr = fmt.Sprintf("DELETE FROM %s WHERE %s = '%s';", table, m.conf.column(m.conf.KeyField), keyval)
 		} else {
-			sqlStr = fmt.Sprintf("DELETE FROM %s WHERE %s = %v;", table, m.conf.KeyField, keyval)
+			sqlStr = fmt.Sprintf("DELETE FROM %s WHERE %s = %v;", table, m.conf.column(m.conf.KeyField), keyval)
 		}
 	default:
 		return fmt.Errorf("invalid rowkind %s", rowkind)
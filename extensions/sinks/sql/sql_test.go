@@ -298,6 +298,117 @@ func TestSaveSql(t *testing.T) {
 	}
 }
 
+func TestUpsert(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:test.db")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	contextLogger := econf.Log.WithField("rule", "test")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	s := &sqlSink{}
+	defer func() {
+		db.Close()
+		s.Close(ctx)
+		err := os.Remove("test.db")
+		if err != nil {
+			fmt.Println(err)
+		}
+	}()
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS upsertTable (id BIGINT PRIMARY KEY, username TEXT NOT NULL)")
+	if err != nil {
+		panic(err)
+	}
+	err = s.Configure(map[string]interface{}{
+		"url":           "sqlite://test.db",
+		"table":         "upsertTable",
+		"keyField":      "id",
+		"upsert":        true,
+		"batchSize":     2,
+		"columnMapping": map[string]interface{}{"name": "username"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = s.Open(ctx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	data := []map[string]interface{}{
+		{"id": 1, "name": "John"},
+		{"id": 2, "name": "Susan"},
+		{"id": 1, "name": "Jack"},
+	}
+	err = s.Collect(ctx, data)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = s.Collect(ctx, map[string]interface{}{"id": 2, "name": "Tom"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rows, err := db.Query("SELECT * FROM upsertTable")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	act, _ := rowsToMap(rows)
+	exp := []map[string]interface{}{
+		{"id": int64(1), "username": "Jack"},
+		{"id": int64(2), "username": "Tom"},
+	}
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("Expect %v but got %v", exp, act)
+	}
+}
+
+func TestBuildStatement(t *testing.T) {
+	tests := []struct {
+		name string
+		conf *sqlConfig
+		sql  string
+		err  string
+	}{
+		{
+			name: "mysql insert",
+			conf: &sqlConfig{driver: "mysql"},
+			sql:  "INSERT INTO test (id,name) VALUES (?,?)",
+		}, {
+			name: "mysql upsert",
+			conf: &sqlConfig{driver: "mysql", Upsert: true, UpsertKeys: []string{"id"}},
+			sql:  "INSERT INTO test (id,name) VALUES (?,?) ON DUPLICATE KEY UPDATE name=VALUES(name)",
+		}, {
+			name: "postgres upsert",
+			conf: &sqlConfig{driver: "postgres", Upsert: true, UpsertKeys: []string{"id"}, ColumnMapping: map[string]string{"id": "uid"}},
+			sql:  "INSERT INTO test (uid,name) VALUES ($1,$2) ON CONFLICT (uid) DO UPDATE SET name=EXCLUDED.name",
+		}, {
+			name: "sqlserver insert",
+			conf: &sqlConfig{driver: "sqlserver"},
+			sql:  "INSERT INTO test (id,name) VALUES (@p1,@p2)",
+		}, {
+			name: "sqlserver upsert",
+			conf: &sqlConfig{driver: "sqlserver", Upsert: true, UpsertKeys: []string{"id"}},
+			err:  "upsert is not supported for driver sqlserver",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cols, _ := tt.conf.rowArgs(map[string]interface{}{"id": 1, "name": "John"})
+			act, err := tt.conf.buildStatement("test", cols)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.sql, act)
+		})
+	}
+}
+
 func rowsToMap(rows *sql.Rows) ([]map[string]interface{}, error) {
 	cols, _ := rows.Columns()
 
@@ -0,0 +1,438 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mib parses the object definitions of SNMP MIB modules to translate between the object names and the numeric OIDs.
+// Only the OID assignments are parsed, the syntax and other clauses of the objects are ignored.
+package mib
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// macros are the definitions which assign an OID by ::= { parent n }
+var macros = map[string]bool{
+	"OBJECT-TYPE":        true,
+	"OBJECT-IDENTITY":    true,
+	"MODULE-IDENTITY":    true,
+	"NOTIFICATION-TYPE":  true,
+	"OBJECT-GROUP":       true,
+	"NOTIFICATION-GROUP": true,
+	"MODULE-COMPLIANCE":  true,
+	"AGENT-CAPABILITIES": true,
+}
+
+// builtinNodes are the well known nodes of SNMPv2-SMI, and the groups and notifications of SNMPv2-MIB and IF-MIB
+var builtinNodes = map[string]string{
+	"ccitt":                 "0",
+	"iso":                   "1",
+	"joint-iso-ccitt":       "2",
+	"org":                   "1.3",
+	"dod":                   "1.3.6",
+	"internet":              "1.3.6.1",
+	"directory":             "1.3.6.1.1",
+	"mgmt":                  "1.3.6.1.2",
+	"mib-2":                 "1.3.6.1.2.1",
+	"transmission":          "1.3.6.1.2.1.10",
+	"experimental":          "1.3.6.1.3",
+	"private":               "1.3.6.1.4",
+	"enterprises":           "1.3.6.1.4.1",
+	"security":              "1.3.6.1.5",
+	"snmpV2":                "1.3.6.1.6",
+	"snmpDomains":           "1.3.6.1.6.1",
+	"snmpProxys":            "1.3.6.1.6.2",
+	"snmpModules":           "1.3.6.1.6.3",
+	"system":                "1.3.6.1.2.1.1",
+	"snmpTraps":             "1.3.6.1.6.3.1.1.5",
+	"coldStart":             "1.3.6.1.6.3.1.1.5.1",
+	"warmStart":             "1.3.6.1.6.3.1.1.5.2",
+	"linkDown":              "1.3.6.1.6.3.1.1.5.3",
+	"linkUp":                "1.3.6.1.6.3.1.1.5.4",
+	"authenticationFailure": "1.3.6.1.6.3.1.1.5.5",
+	"interfaces":            "1.3.6.1.2.1.2",
+	"ifTable":               "1.3.6.1.2.1.2.2",
+	"ifEntry":               "1.3.6.1.2.1.2.2.1",
+	"ifXTable":              "1.3.6.1.2.1.31.1.1",
+	"ifXEntry":              "1.3.6.1.2.1.31.1.1.1",
+}
+
+// builtinObjects are the commonly used objects of SNMPv2-MIB and IF-MIB, so that they can be used without loading the MIB files
+var builtinObjects = map[string]string{
+	"sysDescr":                   "1.3.6.1.2.1.1.1",
+	"sysObjectID":                "1.3.6.1.2.1.1.2",
+	"sysUpTime":                  "1.3.6.1.2.1.1.3",
+	"sysContact":                 "1.3.6.1.2.1.1.4",
+	"sysName":                    "1.3.6.1.2.1.1.5",
+	"sysLocation":                "1.3.6.1.2.1.1.6",
+	"sysServices":                "1.3.6.1.2.1.1.7",
+	"snmpTrapOID":                "1.3.6.1.6.3.1.1.4.1",
+	"snmpTrapEnterprise":         "1.3.6.1.6.3.1.1.4.3",
+	"ifNumber":                   "1.3.6.1.2.1.2.1",
+	"ifIndex":                    "1.3.6.1.2.1.2.2.1.1",
+	"ifDescr":                    "1.3.6.1.2.1.2.2.1.2",
+	"ifType":                     "1.3.6.1.2.1.2.2.1.3",
+	"ifMtu":                      "1.3.6.1.2.1.2.2.1.4",
+	"ifSpeed":                    "1.3.6.1.2.1.2.2.1.5",
+	"ifPhysAddress":              "1.3.6.1.2.1.2.2.1.6",
+	"ifAdminStatus":              "1.3.6.1.2.1.2.2.1.7",
+	"ifOperStatus":               "1.3.6.1.2.1.2.2.1.8",
+	"ifLastChange":               "1.3.6.1.2.1.2.2.1.9",
+	"ifInOctets":                 "1.3.6.1.2.1.2.2.1.10",
+	"ifInUcastPkts":              "1.3.6.1.2.1.2.2.1.11",
+	"ifInDiscards":               "1.3.6.1.2.1.2.2.1.13",
+	"ifInErrors":                 "1.3.6.1.2.1.2.2.1.14",
+	"ifOutOctets":                "1.3.6.1.2.1.2.2.1.16",
+	"ifOutUcastPkts":             "1.3.6.1.2.1.2.2.1.17",
+	"ifOutDiscards":              "1.3.6.1.2.1.2.2.1.19",
+	"ifOutErrors":                "1.3.6.1.2.1.2.2.1.20",
+	"ifName":                     "1.3.6.1.2.1.31.1.1.1.1",
+	"ifHCInOctets":               "1.3.6.1.2.1.31.1.1.1.6",
+	"ifHCOutOctets":              "1.3.6.1.2.1.31.1.1.1.10",
+	"ifHighSpeed":                "1.3.6.1.2.1.31.1.1.1.15",
+	"ifAlias":                    "1.3.6.1.2.1.31.1.1.1.18",
+	"ifCounterDiscontinuityTime": "1.3.6.1.2.1.31.1.1.1.19",
+}
+
+type definition struct {
+	parent string
+	subIds []string
+	// isObject marks the OBJECT-TYPE definitions whose instances have values
+	isObject bool
+}
+
+// Registry holds the object names and their numeric OIDs
+type Registry struct {
+	byName map[string]string
+	byOid  map[string]string
+	// objects are the names of the OBJECT-TYPE definitions
+	objects map[string]bool
+	// defs are the definitions parsed but not resolved yet
+	defs map[string]*definition
+}
+
+// New creates a registry with the builtin objects
+func New() *Registry {
+	r := &Registry{
+		byName:  make(map[string]string),
+		byOid:   make(map[string]string),
+		objects: make(map[string]bool),
+		defs:    make(map[string]*definition),
+	}
+	for name, oid := range builtinNodes {
+		r.add(name, oid, false)
+	}
+	for name, oid := range builtinObjects {
+		r.add(name, oid, true)
+	}
+	return r
+}
+
+func (r *Registry) add(name, oid string, isObject bool) {
+	r.byName[name] = oid
+	if isObject {
+		r.objects[name] = true
+	}
+	// the first name wins if there are aliases of the same oid
+	if _, ok := r.byOid[oid]; !ok {
+		r.byOid[oid] = name
+	}
+}
+
+// LoadPath loads a MIB file or all the files in a directory
+func (r *Registry) LoadPath(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return r.loadFile(path)
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if err := r.loadFile(filepath.Join(path, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := r.Load(f); err != nil {
+		return fmt.Errorf("parse mib file %s error: %v", path, err)
+	}
+	return nil
+}
+
+// Load parses the MIB module from the reader. The definitions referring to the parents which are not loaded yet
+// are kept and resolved when the parents are loaded, so the modules can be loaded in any order
+func (r *Registry) Load(reader io.Reader) error {
+	tokens, err := tokenize(reader)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		// skip the macro definitions in SMI modules
+		if i+1 < len(tokens) && tokens[i+1] == "MACRO" {
+			for i < len(tokens) && tokens[i] != "END" {
+				i++
+			}
+			continue
+		}
+		if !isValueName(t) || i+1 >= len(tokens) {
+			continue
+		}
+		var start int
+		switch {
+		case macros[tokens[i+1]]:
+			start = i + 2
+		case tokens[i+1] == "OBJECT" && i+3 < len(tokens) && tokens[i+2] == "IDENTIFIER" && tokens[i+3] == "::=":
+			start = i + 3
+		default:
+			continue
+		}
+		// find the assignment of the definition
+		j := start
+		for j < len(tokens) && tokens[j] != "::=" {
+			j++
+		}
+		if j+1 >= len(tokens) || tokens[j+1] != "{" {
+			i = j
+			continue
+		}
+		k := j + 2
+		for k < len(tokens) && tokens[k] != "}" {
+			k++
+		}
+		if k >= len(tokens) {
+			return fmt.Errorf("unclosed oid value of %s", t)
+		}
+		if err := r.define(t, tokens[j+2:k], tokens[i+1] == "OBJECT-TYPE"); err != nil {
+			return err
+		}
+		i = k
+	}
+	r.resolve()
+	return nil
+}
+
+// define records the oid value like { parent 1 } or { iso org(3) dod(6) 1 }
+func (r *Registry) define(name string, components []string, isObject bool) error {
+	if len(components) == 0 {
+		return fmt.Errorf("empty oid value of %s", name)
+	}
+	parent := components[0]
+	var subIds []string
+	if _, err := strconv.ParseUint(parent, 10, 32); err == nil {
+		// starts with a number such as { 1 3 6 }
+		parent, subIds = "", []string{parent}
+	}
+	for _, c := range components[1:] {
+		// named number such as org(3)
+		if p := strings.IndexByte(c, '('); p > 0 && strings.HasSuffix(c, ")") {
+			c = c[p+1 : len(c)-1]
+		}
+		if _, err := strconv.ParseUint(c, 10, 32); err != nil {
+			return fmt.Errorf("invalid oid component %s of %s", c, name)
+		}
+		subIds = append(subIds, c)
+	}
+	r.defs[name] = &definition{parent: parent, subIds: subIds, isObject: isObject}
+	return nil
+}
+
+// resolve resolves the pending definitions whose parents are known
+func (r *Registry) resolve() {
+	for changed := true; changed; {
+		changed = false
+		for name, d := range r.defs {
+			var prefix string
+			if d.parent != "" {
+				p, ok := r.byName[d.parent]
+				if !ok {
+					continue
+				}
+				prefix = p + "."
+			}
+			r.add(name, prefix+strings.Join(d.subIds, "."), d.isObject)
+			delete(r.defs, name)
+			changed = true
+		}
+	}
+}
+
+// Resolve translates the object name like sysUpTime.0 or the numeric oid into the numeric oid
+func (r *Registry) Resolve(s string) (string, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), ".")
+	if s == "" {
+		return "", fmt.Errorf("empty oid")
+	}
+	name, suffix := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		name, suffix = s[:i], s[i:]
+	}
+	if isNumber(name) {
+		for _, c := range strings.Split(s, ".") {
+			if !isNumber(c) {
+				return "", fmt.Errorf("invalid oid %s", s)
+			}
+		}
+		return s, nil
+	}
+	oid, ok := r.byName[name]
+	if !ok {
+		return "", fmt.Errorf("unknown object %s", name)
+	}
+	for _, c := range strings.Split(strings.TrimPrefix(suffix, "."), ".") {
+		if suffix != "" && !isNumber(c) {
+			return "", fmt.Errorf("invalid oid %s", s)
+		}
+	}
+	return oid + suffix, nil
+}
+
+// Lookup finds the object of the longest prefix of the numeric oid and returns the object name and the instance index.
+// If no object is found, the name of the oid translated by Name is returned
+func (r *Registry) Lookup(oid string) (string, string) {
+	oid = strings.TrimPrefix(oid, ".")
+	for prefix := oid; ; {
+		if name, ok := r.byOid[prefix]; ok && r.objects[name] {
+			return name, strings.TrimPrefix(oid[len(prefix):], ".")
+		}
+		i := strings.LastIndexByte(prefix, '.')
+		if i < 0 {
+			return r.Name(oid), ""
+		}
+		prefix = prefix[:i]
+	}
+}
+
+// Name translates the numeric oid into the name of the longest known prefix with the remaining sub ids like sysUpTime.0
+func (r *Registry) Name(oid string) string {
+	oid = strings.TrimPrefix(oid, ".")
+	for prefix := oid; ; {
+		if name, ok := r.byOid[prefix]; ok {
+			return name + oid[len(prefix):]
+		}
+		i := strings.LastIndexByte(prefix, '.')
+		if i < 0 {
+			return oid
+		}
+		prefix = prefix[:i]
+	}
+}
+
+// FieldNames translates the oids of a message into the field names. The field name is the object name without
+// the instance index. If an object has multiple instances in the message, the index is appended with underscores like ifInOctets_2
+func (r *Registry) FieldNames(oids []string) []string {
+	names := make([]string, len(oids))
+	indexes := make([]string, len(oids))
+	count := make(map[string]int, len(oids))
+	for i, oid := range oids {
+		names[i], indexes[i] = r.Lookup(oid)
+		count[names[i]]++
+	}
+	for i := range names {
+		if count[names[i]] > 1 && indexes[i] != "" {
+			names[i] = names[i] + "_" + strings.ReplaceAll(indexes[i], ".", "_")
+		}
+	}
+	return names
+}
+
+// tokenize splits the MIB module into tokens. The comments and the quoted strings are dropped,
+// the named numbers like org(3) are kept as one token
+func tokenize(reader io.Reader) ([]string, error) {
+	var tokens []string
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	inString := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		for i := 0; i < len(line); {
+			c := line[i]
+			switch {
+			case inString:
+				if c == '"' {
+					inString = false
+				}
+				i++
+			case c == '"':
+				inString = true
+				i++
+			case c == '-' && i+1 < len(line) && line[i+1] == '-':
+				// the comment ends at the next -- or the end of line
+				end := strings.Index(line[i+2:], "--")
+				if end < 0 {
+					i = len(line)
+				} else {
+					i += end + 4
+				}
+			case c == '{' || c == '}' || c == ',' || c == ';':
+				tokens = append(tokens, string(c))
+				i++
+			case c == ' ' || c == '\t' || c == '\r':
+				i++
+			default:
+				j := i
+				for j < len(line) && !strings.ContainsRune(" \t\r{},;\"", rune(line[j])) {
+					if line[j] == '-' && j+1 < len(line) && line[j+1] == '-' {
+						break
+					}
+					j++
+				}
+				// keep the named number like org(3) as one token
+				tokens = append(tokens, line[i:j])
+				i = j
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// isValueName checks if the token is a value reference which starts with a lower case letter
+func isValueName(t string) bool {
+	return len(t) > 0 && t[0] >= 'a' && t[0] <= 'z'
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
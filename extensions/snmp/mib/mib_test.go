@@ -0,0 +1,247 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMib = `
+ACME-MIB DEFINITIONS ::= BEGIN
+
+IMPORTS
+    MODULE-IDENTITY, OBJECT-TYPE, NOTIFICATION-TYPE, Integer32, enterprises
+        FROM SNMPv2-SMI
+    DisplayString
+        FROM SNMPv2-TC;
+
+acmeMIB MODULE-IDENTITY
+    LAST-UPDATED "202301010000Z"
+    ORGANIZATION "ACME"
+    CONTACT-INFO "acme { not an oid }"
+    DESCRIPTION  "The MIB module of ACME -- with a quoted comment"
+    ::= { acme 1 }
+
+-- acme OBJECT IDENTIFIER ::= { enterprises 999 }
+acme OBJECT IDENTIFIER ::= { enterprises 99999 }  -- the enterprise number
+
+acmeObjects  OBJECT IDENTIFIER ::= { acmeMIB 1 }
+acmeTraps    OBJECT IDENTIFIER ::= { acmeMIB 2 }
+
+acmeTemperature OBJECT-TYPE
+    SYNTAX      Integer32
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION
+        "The temperature in
+         celsius."
+    ::= { acmeObjects 1 }
+
+acmeSensorTable OBJECT-TYPE
+    SYNTAX      SEQUENCE OF AcmeSensorEntry
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    DESCRIPTION "The sensors."
+    ::= { acmeObjects 2 }
+
+acmeSensorEntry OBJECT-TYPE
+    SYNTAX      AcmeSensorEntry
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    DESCRIPTION "A sensor."
+    INDEX       { acmeSensorIndex }
+    ::= { acmeSensorTable 1 }
+
+AcmeSensorEntry ::= SEQUENCE {
+    acmeSensorIndex  Integer32,
+    acmeSensorValue  Integer32
+}
+
+acmeSensorIndex OBJECT-TYPE
+    SYNTAX      Integer32
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    DESCRIPTION "The index."
+    ::= { acmeSensorEntry 1 }
+
+acmeSensorValue OBJECT-TYPE
+    SYNTAX      Integer32
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "The value."
+    ::= { acmeSensorEntry 2 }
+
+acmeOverheat NOTIFICATION-TYPE
+    OBJECTS     { acmeTemperature }
+    STATUS      current
+    DESCRIPTION "The temperature is too high."
+    ::= { acmeTraps 1 }
+
+acmeTest OBJECT IDENTIFIER ::= { iso org(3) dod(6) internet(1) private(4) 2 }
+
+END
+`
+
+func TestLoad(t *testing.T) {
+	r := New()
+	if err := r.Load(strings.NewReader(testMib)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		oid  string
+		err  string
+	}{
+		{name: "acme", oid: "1.3.6.1.4.1.99999"},
+		{name: "acmeMIB", oid: "1.3.6.1.4.1.99999.1"},
+		{name: "acmeTemperature", oid: "1.3.6.1.4.1.99999.1.1.1"},
+		{name: "acmeTemperature.0", oid: "1.3.6.1.4.1.99999.1.1.1.0"},
+		{name: "acmeSensorValue.3", oid: "1.3.6.1.4.1.99999.1.1.2.1.2.3"},
+		{name: "acmeOverheat", oid: "1.3.6.1.4.1.99999.1.2.1"},
+		{name: "acmeTest", oid: "1.3.6.1.4.2"},
+		{name: "sysUpTime.0", oid: "1.3.6.1.2.1.1.3.0"},
+		{name: ".1.3.6.1.2.1.1.5.0", oid: "1.3.6.1.2.1.1.5.0"},
+		{name: "acmeUnknown", err: "unknown object acmeUnknown"},
+		{name: "acmeTemperature.a", err: "invalid oid acmeTemperature.a"},
+		{name: "1.3.a", err: "invalid oid 1.3.a"},
+		{name: "AcmeSensorEntry", err: "unknown object AcmeSensorEntry"},
+	}
+	for _, tt := range tests {
+		oid, err := r.Resolve(tt.name)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: expect error %s but got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if oid != tt.oid {
+			t.Errorf("%s: expect oid %s but got %s", tt.name, tt.oid, oid)
+		}
+	}
+}
+
+func TestLoadOutOfOrder(t *testing.T) {
+	r := New()
+	child := `CHILD-MIB DEFINITIONS ::= BEGIN
+fooValue OBJECT-TYPE
+    SYNTAX Integer32
+    ::= { foo 1 }
+END`
+	parent := `PARENT-MIB DEFINITIONS ::= BEGIN
+foo OBJECT IDENTIFIER ::= { enterprises 4242 }
+END`
+	if err := r.Load(strings.NewReader(child)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Resolve("fooValue"); err == nil {
+		t.Errorf("expect fooValue unresolved before loading the parent")
+	}
+	if err := r.Load(strings.NewReader(parent)); err != nil {
+		t.Fatal(err)
+	}
+	oid, err := r.Resolve("fooValue")
+	if err != nil || oid != "1.3.6.1.4.1.4242.1" {
+		t.Errorf("expect 1.3.6.1.4.1.4242.1 but got %s with error %v", oid, err)
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	tests := []struct {
+		mib string
+		err string
+	}{
+		{
+			mib: `foo OBJECT IDENTIFIER ::= { enterprises 1`,
+			err: "unclosed oid value of foo",
+		}, {
+			mib: `foo OBJECT IDENTIFIER ::= { }`,
+			err: "empty oid value of foo",
+		}, {
+			mib: `foo OBJECT IDENTIFIER ::= { enterprises bar }`,
+			err: "invalid oid component bar of foo",
+		},
+	}
+	for i, tt := range tests {
+		err := New().Load(strings.NewReader(tt.mib))
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	r := New()
+	tests := []struct {
+		oid  string
+		name string
+	}{
+		{oid: ".1.3.6.1.2.1.1.3.0", name: "sysUpTime.0"},
+		{oid: "1.3.6.1.2.1.2.2.1.10.2", name: "ifInOctets.2"},
+		{oid: "1.3.6.1.6.3.1.1.5.3", name: "linkDown"},
+		{oid: "1.3.6.1.4.1.99999.1", name: "enterprises.99999.1"},
+		{oid: "3.1", name: "3.1"},
+	}
+	for _, tt := range tests {
+		if name := r.Name(tt.oid); name != tt.name {
+			t.Errorf("%s: expect name %s but got %s", tt.oid, tt.name, name)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r := New()
+	if err := r.Load(strings.NewReader(testMib)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		oid   string
+		name  string
+		index string
+	}{
+		{oid: ".1.3.6.1.4.1.99999.1.1.1.0", name: "acmeTemperature", index: "0"},
+		{oid: "1.3.6.1.4.1.99999.1.1.2.1.2.3", name: "acmeSensorValue", index: "3"},
+		// only the objects have instances
+		{oid: "1.3.6.1.4.1.99999.1.1.3.0", name: "acmeObjects.3.0"},
+		{oid: "1.3.6.1.4.1.99999.1.2.1", name: "acmeOverheat"},
+	}
+	for _, tt := range tests {
+		name, index := r.Lookup(tt.oid)
+		if name != tt.name || index != tt.index {
+			t.Errorf("%s: expect %s with index %s but got %s with index %s", tt.oid, tt.name, tt.index, name, index)
+		}
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	r := New()
+	names := r.FieldNames([]string{
+		".1.3.6.1.2.1.1.3.0",
+		".1.3.6.1.2.1.2.2.1.10.1",
+		".1.3.6.1.2.1.2.2.1.10.2",
+		".1.3.6.1.2.1.2.2.1.2.1",
+		".1.3.6.1.4.1.99999.1.1.1.0",
+		"3.1",
+	})
+	exp := []string{"sysUpTime", "ifInOctets_1", "ifInOctets_2", "ifDescr", "enterprises.99999.1.1.1.0", "3.1"}
+	if !reflect.DeepEqual(names, exp) {
+		t.Errorf("expect %v but got %v", exp, names)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snmp provides the protocol properties and the value conversion shared by the SNMP sources.
+package snmp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gosnmp/gosnmp"
+
+	"github.com/lf-edge/ekuiper/extensions/snmp/mib"
+)
+
+var (
+	versions = map[string]gosnmp.SnmpVersion{
+		"1":  gosnmp.Version1,
+		"2c": gosnmp.Version2c,
+		"3":  gosnmp.Version3,
+	}
+	securityLevels = map[string]gosnmp.SnmpV3MsgFlags{
+		"noAuthNoPriv": gosnmp.NoAuthNoPriv,
+		"authNoPriv":   gosnmp.AuthNoPriv,
+		"authPriv":     gosnmp.AuthPriv,
+	}
+	authProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+		"MD5":    gosnmp.MD5,
+		"SHA":    gosnmp.SHA,
+		"SHA224": gosnmp.SHA224,
+		"SHA256": gosnmp.SHA256,
+		"SHA384": gosnmp.SHA384,
+		"SHA512": gosnmp.SHA512,
+	}
+	privProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+		"DES":     gosnmp.DES,
+		"AES":     gosnmp.AES,
+		"AES192":  gosnmp.AES192,
+		"AES256":  gosnmp.AES256,
+		"AES192C": gosnmp.AES192C,
+		"AES256C": gosnmp.AES256C,
+	}
+)
+
+// Conf is the protocol properties shared by the SNMP sources
+type Conf struct {
+	// Version is the SNMP version, 1, 2c or 3
+	Version   string `json:"version"`
+	Community string `json:"community"`
+	// Timeout is the timeout of each request in milliseconds
+	Timeout int `json:"timeout"`
+	Retries int `json:"retries"`
+	// The USM properties of SNMPv3
+	UserName       string `json:"userName"`
+	SecurityLevel  string `json:"securityLevel"`
+	AuthProtocol   string `json:"authProtocol"`
+	AuthPassphrase string `json:"authPassphrase"`
+	PrivProtocol   string `json:"privProtocol"`
+	PrivPassphrase string `json:"privPassphrase"`
+	ContextName    string `json:"contextName"`
+	// MibPaths are the MIB files or directories to translate between the object names and the OIDs
+	MibPaths []string `json:"mibPaths"`
+}
+
+// Apply validates the properties and sets them to the SNMP client or trap listener parameters
+func (c *Conf) Apply(g *gosnmp.GoSNMP) error {
+	v, ok := versions[c.Version]
+	if !ok {
+		return fmt.Errorf("invalid version %s, must be 1, 2c or 3", c.Version)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	if c.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	g.Version = v
+	g.Community = c.Community
+	g.Timeout = time.Duration(c.Timeout) * time.Millisecond
+	g.Retries = c.Retries
+	if v != gosnmp.Version3 {
+		return nil
+	}
+	if c.UserName == "" {
+		return fmt.Errorf("userName is required for version 3")
+	}
+	flags, ok := securityLevels[c.SecurityLevel]
+	if !ok {
+		return fmt.Errorf("invalid securityLevel %s, must be noAuthNoPriv, authNoPriv or authPriv", c.SecurityLevel)
+	}
+	usm := &gosnmp.UsmSecurityParameters{
+		UserName:               c.UserName,
+		AuthenticationProtocol: gosnmp.NoAuth,
+		PrivacyProtocol:        gosnmp.NoPriv,
+	}
+	if flags != gosnmp.NoAuthNoPriv {
+		ap, ok := authProtocols[strings.ToUpper(c.AuthProtocol)]
+		if !ok {
+			return fmt.Errorf("invalid authProtocol %s", c.AuthProtocol)
+		}
+		if c.AuthPassphrase == "" {
+			return fmt.Errorf("authPassphrase is required for securityLevel %s", c.SecurityLevel)
+		}
+		usm.AuthenticationProtocol = ap
+		usm.AuthenticationPassphrase = c.AuthPassphrase
+	}
+	if flags == gosnmp.AuthPriv {
+		pp, ok := privProtocols[strings.ToUpper(c.PrivProtocol)]
+		if !ok {
+			return fmt.Errorf("invalid privProtocol %s", c.PrivProtocol)
+		}
+		if c.PrivPassphrase == "" {
+			return fmt.Errorf("privPassphrase is required for securityLevel %s", c.SecurityLevel)
+		}
+		usm.PrivacyProtocol = pp
+		usm.PrivacyPassphrase = c.PrivPassphrase
+	}
+	g.SecurityModel = gosnmp.UserSecurityModel
+	g.MsgFlags = flags
+	g.SecurityParameters = usm
+	g.ContextName = c.ContextName
+	return nil
+}
+
+// LoadMibs creates the MIB registry with the builtin objects and the configured MIB files
+func (c *Conf) LoadMibs() (*mib.Registry, error) {
+	r := mib.New()
+	for _, p := range c.MibPaths {
+		if err := r.LoadPath(p); err != nil {
+			return nil, fmt.Errorf("load mib %s error: %v", p, err)
+		}
+	}
+	return r, nil
+}
+
+// ToMap converts the variables into a tuple whose keys are the field names of the objects.
+// The variables without value such as noSuchObject are skipped
+func ToMap(vars []gosnmp.SnmpPDU, r *mib.Registry) map[string]interface{} {
+	oids := make([]string, len(vars))
+	for i, v := range vars {
+		oids[i] = v.Name
+	}
+	names := r.FieldNames(oids)
+	result := make(map[string]interface{}, len(vars))
+	for i, v := range vars {
+		if val, ok := Value(v, r); ok {
+			result[names[i]] = val
+		}
+	}
+	return result
+}
+
+// Value converts the value of the variable by its type. The octet strings are converted into strings if they are
+// printable, otherwise into the hex strings like 00:1a:2b. The OIDs are translated into the object names
+func Value(v gosnmp.SnmpPDU, r *mib.Registry) (interface{}, bool) {
+	switch v.Type {
+	case gosnmp.Null, gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.UnknownType:
+		return nil, false
+	case gosnmp.OctetString:
+		b, _ := v.Value.([]byte)
+		if isPrintable(b) {
+			return string(b), true
+		}
+		return hexString(b), true
+	case gosnmp.Opaque, gosnmp.BitString:
+		b, _ := v.Value.([]byte)
+		return hexString(b), true
+	case gosnmp.ObjectIdentifier:
+		s, _ := v.Value.(string)
+		return r.Name(s), true
+	case gosnmp.IPAddress:
+		return v.Value, true
+	case gosnmp.Boolean:
+		return v.Value, true
+	case gosnmp.OpaqueFloat:
+		f, _ := v.Value.(float32)
+		return float64(f), true
+	case gosnmp.OpaqueDouble:
+		return v.Value, true
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+		n := gosnmp.ToBigInt(v.Value)
+		if n.IsInt64() {
+			return n.Int64(), true
+		}
+		return n.Uint64(), true
+	default:
+		return v.Value, true
+	}
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func hexString(b []byte) string {
+	parts := make([]string, len(b))
+	for i := range b {
+		parts[i] = hex.EncodeToString(b[i : i+1])
+	}
+	return strings.Join(parts, ":")
+}
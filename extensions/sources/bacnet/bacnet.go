@@ -0,0 +1,372 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+const (
+	defaultPort = "47808"
+	// processId identifies the COV subscriptions of the source
+	processId = 1
+)
+
+type point struct {
+	// Name is the field name of the point in the tuple
+	Name     string `json:"name"`
+	Object   string `json:"object"`
+	Property string `json:"property"`
+	// Cov subscribes the changes of the object instead of polling
+	Cov bool `json:"cov"`
+
+	object   objectId
+	property uint32
+}
+
+type sourceConf struct {
+	Address      string `json:"address"`
+	LocalAddress string `json:"localAddress"`
+	// Interval is the polling interval in milliseconds
+	Interval int `json:"interval"`
+	// Timeout is the timeout of each request in milliseconds
+	Timeout int `json:"timeout"`
+	// CovLifetime is the lifetime of the COV subscriptions in seconds, they are renewed before expiration. 0 means indefinite
+	CovLifetime int      `json:"covLifetime"`
+	Points      []*point `json:"points"`
+}
+
+type bacnetSource struct {
+	c          *sourceConf
+	remote     *net.UDPAddr
+	local      *net.UDPAddr
+	polls      []*objectRequest
+	covObjects []objectId
+	conn       *net.UDPConn
+
+	mu       sync.Mutex
+	invokeId byte
+	pending  map[byte]chan *apdu
+}
+
+func (s *bacnetSource) Configure(_ string, props map[string]interface{}) error {
+	c := &sourceConf{
+		LocalAddress: ":0",
+		Interval:     10000,
+		Timeout:      3000,
+		CovLifetime:  300,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	address := c.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultPort)
+	}
+	remote, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %v", c.Address, err)
+	}
+	local, err := net.ResolveUDPAddr("udp4", c.LocalAddress)
+	if err != nil {
+		return fmt.Errorf("invalid localAddress %s: %v", c.LocalAddress, err)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	if c.CovLifetime < 0 {
+		return fmt.Errorf("covLifetime can not be negative")
+	}
+	if len(c.Points) == 0 {
+		return fmt.Errorf("points can not be empty")
+	}
+	var (
+		names      = make(map[string]bool)
+		polls      []*objectRequest
+		pollIndex  = make(map[objectId]*objectRequest)
+		covObjects []objectId
+		covIndex   = make(map[objectId]bool)
+	)
+	for _, p := range c.Points {
+		if p.Name == "" {
+			return fmt.Errorf("point name can not be empty")
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate point name %s", p.Name)
+		}
+		names[p.Name] = true
+		if p.object, err = parseObjectId(p.Object); err != nil {
+			return fmt.Errorf("point %s: %v", p.Name, err)
+		}
+		if p.Property == "" {
+			p.Property = "presentValue"
+		}
+		if p.property, err = parseProperty(p.Property); err != nil {
+			return fmt.Errorf("point %s: %v", p.Name, err)
+		}
+		if p.Cov {
+			// the COV notification only reports the present value and status flags
+			if p.property != properties["presentValue"] && p.property != properties["statusFlags"] {
+				return fmt.Errorf("point %s: only presentValue and statusFlags support cov", p.Name)
+			}
+			if !covIndex[p.object] {
+				covIndex[p.object] = true
+				covObjects = append(covObjects, p.object)
+			}
+			continue
+		}
+		req, ok := pollIndex[p.object]
+		if !ok {
+			req = &objectRequest{object: p.object}
+			pollIndex[p.object] = req
+			polls = append(polls, req)
+		}
+		req.properties = append(req.properties, p.property)
+	}
+	if len(polls) > 0 && c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	s.c = c
+	s.remote = remote
+	s.local = local
+	s.polls = polls
+	s.covObjects = covObjects
+	return nil
+}
+
+func (s *bacnetSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	conn, err := net.ListenUDP("udp4", s.local)
+	if err != nil {
+		errCh <- fmt.Errorf("%s: bacnet source fails to listen %s: %v", errorx.IOErr, s.local, err)
+		return
+	}
+	s.conn = conn
+	covCh := make(chan *covNotification, 16)
+	go s.receive(ctx, covCh)
+
+	var (
+		pollCh  <-chan time.Time
+		renewCh <-chan time.Time
+	)
+	if len(s.polls) > 0 {
+		ticker := time.NewTicker(time.Duration(s.c.Interval) * time.Millisecond)
+		defer ticker.Stop()
+		pollCh = ticker.C
+		s.poll(ctx, consumer)
+	}
+	if len(s.covObjects) > 0 {
+		if err := s.subscribe(ctx); err != nil {
+			errCh <- err
+			return
+		}
+		if s.c.CovLifetime > 0 {
+			// renew at the half of the lifetime so that no notification is missed
+			ticker := time.NewTicker(time.Duration(s.c.CovLifetime) * time.Second / 2)
+			defer ticker.Stop()
+			renewCh = ticker.C
+		}
+	}
+	logger.Infof("bacnet source polls %d objects and subscribes %d objects of %s", len(s.polls), len(s.covObjects), s.remote)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infof("bacnet source done")
+			return
+		case <-pollCh:
+			s.poll(ctx, consumer)
+		case <-renewCh:
+			if err := s.subscribe(ctx); err != nil {
+				logger.Warnf("bacnet source fails to renew the cov subscriptions: %v", err)
+			}
+		case n := <-covCh:
+			s.notify(ctx, n, consumer)
+		}
+	}
+}
+
+// receive reads the packets from the device, dispatches the responses to the pending requests and the COV notifications to the channel
+func (s *bacnetSource) receive(ctx api.StreamContext, covCh chan<- *covNotification) {
+	logger := ctx.GetLogger()
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Errorf("bacnet source fails to read: %v", err)
+			}
+			return
+		}
+		if !addr.IP.Equal(s.remote.IP) {
+			continue
+		}
+		a, err := decodePacket(buf[:n])
+		if err != nil {
+			logger.Warnf("bacnet source receives invalid packet: %v", err)
+			continue
+		}
+		if a == nil {
+			continue
+		}
+		if a.pduType == pduUnconfirmedRequest {
+			if a.service != serviceUnconfirmedCOVNotification {
+				continue
+			}
+			cn, err := decodeCOVNotification(a.data)
+			if err != nil {
+				logger.Warnf("bacnet source receives invalid cov notification: %v", err)
+				continue
+			}
+			select {
+			case covCh <- cn:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		// the data is copied as the buffer is reused
+		a.data = append([]byte(nil), a.data...)
+		s.mu.Lock()
+		ch, ok := s.pending[a.invokeId]
+		delete(s.pending, a.invokeId)
+		s.mu.Unlock()
+		if ok {
+			ch <- a
+		}
+	}
+}
+
+// request sends the confirmed request and waits for the response
+func (s *bacnetSource) request(ctx api.StreamContext, service byte, body []byte) (*apdu, error) {
+	ch := make(chan *apdu, 1)
+	s.mu.Lock()
+	s.invokeId++
+	id := s.invokeId
+	s.pending[id] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+	if _, err := s.conn.WriteToUDP(encodeConfirmedRequest(id, service, body), s.remote); err != nil {
+		return nil, fmt.Errorf("%s: %v", errorx.IOErr, err)
+	}
+	select {
+	case a := <-ch:
+		if a.err != nil {
+			return nil, a.err
+		}
+		return a, nil
+	case <-time.After(time.Duration(s.c.Timeout) * time.Millisecond):
+		return nil, fmt.Errorf("%s: request timeout", errorx.IOErr)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *bacnetSource) subscribe(ctx api.StreamContext) error {
+	for _, o := range s.covObjects {
+		if _, err := s.request(ctx, serviceSubscribeCOV, encodeSubscribeCOV(processId, o, uint32(s.c.CovLifetime))); err != nil {
+			return fmt.Errorf("bacnet source fails to subscribe cov of %s: %v", o, err)
+		}
+	}
+	return nil
+}
+
+func (s *bacnetSource) poll(ctx api.StreamContext, consumer chan<- api.SourceTuple) {
+	logger := ctx.GetLogger()
+	a, err := s.request(ctx, serviceReadPropertyMultiple, encodeReadPropertyMultiple(s.polls))
+	if err != nil {
+		logger.Errorf("bacnet source fails to read the properties: %v", err)
+		return
+	}
+	rcvTime := conf.GetNow()
+	values, err := decodeReadPropertyMultipleAck(a.data)
+	if err != nil {
+		logger.Errorf("bacnet source receives invalid response: %v", err)
+		return
+	}
+	for _, v := range values {
+		if v.err != nil {
+			logger.Warnf("bacnet source fails to read property %d of %s: %v", v.property, v.object, v.err)
+		}
+	}
+	message := s.toMessage(values, false)
+	if len(message) == 0 {
+		return
+	}
+	select {
+	case consumer <- api.NewDefaultSourceTupleWithTime(message, map[string]interface{}{"address": s.remote.String()}, rcvTime):
+	case <-ctx.Done():
+	}
+}
+
+func (s *bacnetSource) notify(ctx api.StreamContext, n *covNotification, consumer chan<- api.SourceTuple) {
+	if n.processId != processId {
+		return
+	}
+	message := s.toMessage(n.values, true)
+	if len(message) == 0 {
+		return
+	}
+	meta := map[string]interface{}{
+		"address": s.remote.String(),
+		"object":  n.object.String(),
+	}
+	select {
+	case consumer <- api.NewDefaultSourceTupleWithTime(message, meta, conf.GetNow()):
+	case <-ctx.Done():
+	}
+}
+
+// toMessage maps the property values to the points. The properties with error are omitted
+func (s *bacnetSource) toMessage(values []*propertyValue, cov bool) map[string]interface{} {
+	message := make(map[string]interface{})
+	for _, v := range values {
+		if v.err != nil {
+			continue
+		}
+		for _, p := range s.c.Points {
+			if p.Cov == cov && p.object == v.object && p.property == v.property {
+				message[p.Name] = v.value
+			}
+		}
+	}
+	return message
+}
+
+func (s *bacnetSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing bacnet source")
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+func Bacnet() api.Source {
+	return &bacnetSource{pending: make(map[byte]chan *apdu)}
+}
@@ -0,0 +1,115 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigure(t *testing.T) {
+	points := []map[string]interface{}{{"name": "temperature", "object": "analogInput:1"}}
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"points": points},
+			err:   "address is required",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "timeout": 0, "points": points},
+			err:   "timeout must be positive",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "covLifetime": -1, "points": points},
+			err:   "covLifetime can not be negative",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1"},
+			err:   "points can not be empty",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "points": []map[string]interface{}{{"object": "analogInput:1"}}},
+			err:   "point name can not be empty",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "points": []map[string]interface{}{{"name": "a", "object": "analogInput:1"}, {"name": "a", "object": "analogInput:2"}}},
+			err:   "duplicate point name a",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "points": []map[string]interface{}{{"name": "a", "object": "analog:1"}}},
+			err:   "point a: invalid object type analog",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "points": []map[string]interface{}{{"name": "a", "object": "analogInput:1", "property": "value"}}},
+			err:   "point a: invalid property value",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "points": []map[string]interface{}{{"name": "a", "object": "analogInput:1", "property": "objectName", "cov": true}}},
+			err:   "point a: only presentValue and statusFlags support cov",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "interval": 0, "points": points},
+			err:   "interval must be positive",
+		}, {
+			// interval is not used if all the points are subscribed
+			props: map[string]interface{}{"address": "127.0.0.1:47809", "interval": 0, "points": []map[string]interface{}{{"name": "a", "object": "analogInput:1", "cov": true}}},
+		},
+	}
+	for i, tt := range tests {
+		err := Bacnet().Configure("", tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestConfigurePoints(t *testing.T) {
+	s := Bacnet().(*bacnetSource)
+	err := s.Configure("", map[string]interface{}{
+		"address": "127.0.0.1",
+		"points": []map[string]interface{}{
+			{"name": "temperature", "object": "analogInput:1"},
+			{"name": "status", "object": "analogInput:1", "property": "statusFlags"},
+			{"name": "fan", "object": "binaryOutput:2", "cov": true},
+			{"name": "fanStatus", "object": "binaryOutput:2", "property": "statusFlags", "cov": true},
+			{"name": "setpoint", "object": "analogValue:3", "property": "85"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.remote.String() != "127.0.0.1:47808" {
+		t.Errorf("expect default port but got %s", s.remote)
+	}
+	expPolls := []*objectRequest{
+		{object: objectId{Type: 0, Instance: 1}, properties: []uint32{85, 111}},
+		{object: objectId{Type: 2, Instance: 3}, properties: []uint32{85}},
+	}
+	if !reflect.DeepEqual(expPolls, s.polls) {
+		t.Errorf("polls mismatch, got %v", s.polls)
+	}
+	if !reflect.DeepEqual([]objectId{{Type: 4, Instance: 2}}, s.covObjects) {
+		t.Errorf("cov objects mismatch, got %v", s.covObjects)
+	}
+	message := s.toMessage([]*propertyValue{
+		{object: objectId{Type: 4, Instance: 2}, property: 85, value: int64(1)},
+		{object: objectId{Type: 4, Instance: 2}, property: 111, value: []interface{}{false, false, false, false}},
+		// not subscribed
+		{object: objectId{Type: 0, Instance: 1}, property: 85, value: 20.5},
+	}, true)
+	expMsg := map[string]interface{}{"fan": int64(1), "fanStatus": []interface{}{false, false, false, false}}
+	if !reflect.DeepEqual(expMsg, message) {
+		t.Errorf("message mismatch, got %v", message)
+	}
+}
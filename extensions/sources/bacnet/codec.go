@@ -0,0 +1,735 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The minimal BACnet/IP codec for the services used by the source: ReadPropertyMultiple, SubscribeCOV and
+// UnconfirmedCOVNotification. Segmented messages are not supported.
+
+const (
+	bvlcTypeIP                = 0x81
+	bvlcForwardedNPDU         = 0x04
+	bvlcOriginalUnicastNPDU   = 0x0a
+	bvlcOriginalBroadcastNPDU = 0x0b
+
+	pduConfirmedRequest   = 0x00
+	pduUnconfirmedRequest = 0x10
+	pduSimpleAck          = 0x20
+	pduComplexAck         = 0x30
+	pduError              = 0x50
+	pduReject             = 0x60
+	pduAbort              = 0x70
+
+	serviceSubscribeCOV               = 5
+	serviceReadPropertyMultiple       = 14
+	serviceUnconfirmedCOVNotification = 2
+)
+
+// application tag numbers
+const (
+	tagNull = iota
+	tagBoolean
+	tagUnsigned
+	tagSigned
+	tagReal
+	tagDouble
+	tagOctetString
+	tagCharacterString
+	tagBitString
+	tagEnumerated
+	tagDate
+	tagTime
+	tagObjectId
+)
+
+var objectTypes = map[string]uint16{
+	"analogInput":      0,
+	"analogOutput":     1,
+	"analogValue":      2,
+	"binaryInput":      3,
+	"binaryOutput":     4,
+	"binaryValue":      5,
+	"device":           8,
+	"multiStateInput":  13,
+	"multiStateOutput": 14,
+	"multiStateValue":  19,
+}
+
+var properties = map[string]uint32{
+	"description":  28,
+	"eventState":   36,
+	"objectName":   77,
+	"outOfService": 81,
+	"presentValue": 85,
+	"reliability":  103,
+	"statusFlags":  111,
+	"units":        117,
+}
+
+type objectId struct {
+	Type     uint16
+	Instance uint32
+}
+
+// parseObjectId parses the object id in the format of type:instance such as analogInput:1. The type can also be a number
+func parseObjectId(s string) (objectId, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return objectId{}, fmt.Errorf("invalid object %s, the format must be type:instance", s)
+	}
+	t, ok := objectTypes[parts[0]]
+	if !ok {
+		n, err := strconv.ParseUint(parts[0], 10, 10)
+		if err != nil {
+			return objectId{}, fmt.Errorf("invalid object type %s", parts[0])
+		}
+		t = uint16(n)
+	}
+	i, err := strconv.ParseUint(parts[1], 10, 22)
+	if err != nil {
+		return objectId{}, fmt.Errorf("invalid object instance %s", parts[1])
+	}
+	return objectId{Type: t, Instance: uint32(i)}, nil
+}
+
+// parseProperty parses the property name such as presentValue. The property can also be a number
+func parseProperty(s string) (uint32, error) {
+	if p, ok := properties[s]; ok {
+		return p, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 22)
+	if err != nil {
+		return 0, fmt.Errorf("invalid property %s", s)
+	}
+	return uint32(n), nil
+}
+
+func (o objectId) String() string {
+	for name, t := range objectTypes {
+		if t == o.Type {
+			return fmt.Sprintf("%s:%d", name, o.Instance)
+		}
+	}
+	return fmt.Sprintf("%d:%d", o.Type, o.Instance)
+}
+
+func (o objectId) encode() uint32 {
+	return uint32(o.Type)<<22 | o.Instance&0x3FFFFF
+}
+
+func decodeObjectId(v uint32) objectId {
+	return objectId{Type: uint16(v >> 22), Instance: v & 0x3FFFFF}
+}
+
+/*** Encoding ***/
+
+type objectRequest struct {
+	object     objectId
+	properties []uint32
+}
+
+func writeTag(buf *bytes.Buffer, number byte, context bool, length int) {
+	b := number << 4
+	if context {
+		b |= 0x08
+	}
+	if length < 5 {
+		buf.WriteByte(b | byte(length))
+		return
+	}
+	buf.WriteByte(b | 5)
+	switch {
+	case length < 254:
+		buf.WriteByte(byte(length))
+	case length < 65536:
+		buf.WriteByte(254)
+		_ = binary.Write(buf, binary.BigEndian, uint16(length))
+	default:
+		buf.WriteByte(255)
+		_ = binary.Write(buf, binary.BigEndian, uint32(length))
+	}
+}
+
+func encodeUnsigned(v uint32) []byte {
+	switch {
+	case v < 1<<8:
+		return []byte{byte(v)}
+	case v < 1<<16:
+		return []byte{byte(v >> 8), byte(v)}
+	case v < 1<<24:
+		return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+	default:
+		return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+	}
+}
+
+func writeContextUnsigned(buf *bytes.Buffer, number byte, v uint32) {
+	b := encodeUnsigned(v)
+	writeTag(buf, number, true, len(b))
+	buf.Write(b)
+}
+
+func writeContextObjectId(buf *bytes.Buffer, number byte, o objectId) {
+	writeTag(buf, number, true, 4)
+	_ = binary.Write(buf, binary.BigEndian, o.encode())
+}
+
+func writeContextBool(buf *bytes.Buffer, number byte, v bool) {
+	writeTag(buf, number, true, 1)
+	if v {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+}
+
+func writeOpening(buf *bytes.Buffer, number byte) {
+	buf.WriteByte(number<<4 | 0x0E)
+}
+
+func writeClosing(buf *bytes.Buffer, number byte) {
+	buf.WriteByte(number<<4 | 0x0F)
+}
+
+// encodeConfirmedRequest wraps the service request into a BVLC unicast packet
+func encodeConfirmedRequest(invokeId byte, service byte, body []byte) []byte {
+	buf := new(bytes.Buffer)
+	buf.Write([]byte{bvlcTypeIP, bvlcOriginalUnicastNPDU, 0, 0})
+	// NPDU version 1, expecting reply
+	buf.Write([]byte{0x01, 0x04})
+	// no segmentation, accept up to 1476 bytes APDU
+	buf.Write([]byte{pduConfirmedRequest, 0x05, invokeId, service})
+	buf.Write(body)
+	b := buf.Bytes()
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)))
+	return b
+}
+
+func encodeReadPropertyMultiple(reqs []*objectRequest) []byte {
+	buf := new(bytes.Buffer)
+	for _, req := range reqs {
+		writeContextObjectId(buf, 0, req.object)
+		writeOpening(buf, 1)
+		for _, p := range req.properties {
+			writeContextUnsigned(buf, 0, p)
+		}
+		writeClosing(buf, 1)
+	}
+	return buf.Bytes()
+}
+
+// encodeSubscribeCOV encodes the subscription with unconfirmed notifications. The lifetime is in seconds, 0 means indefinite
+func encodeSubscribeCOV(processId uint32, object objectId, lifetime uint32) []byte {
+	buf := new(bytes.Buffer)
+	writeContextUnsigned(buf, 0, processId)
+	writeContextObjectId(buf, 1, object)
+	writeContextBool(buf, 2, false)
+	writeContextUnsigned(buf, 3, lifetime)
+	return buf.Bytes()
+}
+
+/*** Decoding ***/
+
+type apdu struct {
+	pduType  byte
+	invokeId byte
+	service  byte
+	data     []byte
+	// err is the error returned by the device for the request
+	err error
+}
+
+// decodePacket decodes the BVLC packet and returns the APDU. It returns nil for the network layer messages
+func decodePacket(b []byte) (*apdu, error) {
+	if len(b) < 4 || b[0] != bvlcTypeIP {
+		return nil, fmt.Errorf("not a BACnet/IP packet")
+	}
+	if int(binary.BigEndian.Uint16(b[2:])) != len(b) {
+		return nil, fmt.Errorf("invalid BVLC length")
+	}
+	var npdu []byte
+	switch b[1] {
+	case bvlcOriginalUnicastNPDU, bvlcOriginalBroadcastNPDU:
+		npdu = b[4:]
+	case bvlcForwardedNPDU:
+		// skip the original source address
+		if len(b) < 10 {
+			return nil, fmt.Errorf("invalid forwarded NPDU")
+		}
+		npdu = b[10:]
+	default:
+		return nil, nil
+	}
+	if len(npdu) < 2 || npdu[0] != 0x01 {
+		return nil, fmt.Errorf("invalid NPDU")
+	}
+	control := npdu[1]
+	if control&0x80 != 0 {
+		return nil, nil
+	}
+	pos := 2
+	skipAddress := func() error {
+		// network number and address length
+		if pos+3 > len(npdu) {
+			return fmt.Errorf("invalid NPDU address")
+		}
+		pos += 3 + int(npdu[pos+2])
+		return nil
+	}
+	if control&0x20 != 0 {
+		if err := skipAddress(); err != nil {
+			return nil, err
+		}
+	}
+	if control&0x08 != 0 {
+		if err := skipAddress(); err != nil {
+			return nil, err
+		}
+	}
+	if control&0x20 != 0 {
+		// hop count
+		pos++
+	}
+	if pos >= len(npdu) {
+		return nil, fmt.Errorf("empty APDU")
+	}
+	p := npdu[pos:]
+	a := &apdu{pduType: p[0] & 0xF0}
+	switch a.pduType {
+	case pduUnconfirmedRequest:
+		if len(p) < 2 {
+			return nil, fmt.Errorf("invalid unconfirmed request")
+		}
+		a.service, a.data = p[1], p[2:]
+	case pduSimpleAck, pduComplexAck, pduError, pduReject, pduAbort:
+		if len(p) < 3 {
+			return nil, fmt.Errorf("invalid APDU")
+		}
+		a.invokeId, a.service, a.data = p[1], p[2], p[3:]
+		switch a.pduType {
+		case pduComplexAck:
+			if p[0]&0x08 != 0 {
+				a.err = fmt.Errorf("segmented response is not supported")
+			}
+		case pduError:
+			r := &reader{b: a.data}
+			class, err1 := r.readEnumerated()
+			code, err2 := r.readEnumerated()
+			if err1 != nil || err2 != nil {
+				a.err = fmt.Errorf("device returns error")
+			} else {
+				a.err = fmt.Errorf("device returns error class %d code %d", class, code)
+			}
+		case pduReject:
+			a.err = fmt.Errorf("device rejects the request with reason %d", p[2])
+		case pduAbort:
+			a.err = fmt.Errorf("device aborts the request with reason %d", p[2])
+		}
+	default:
+		// confirmed requests and segment acks from the device are not handled
+		return nil, nil
+	}
+	return a, nil
+}
+
+type tag struct {
+	number  byte
+	context bool
+	opening bool
+	closing bool
+	// boolValue is the value of the application boolean tag which is encoded in the length
+	boolValue bool
+	value     []byte
+}
+
+type reader struct {
+	b   []byte
+	pos int
+}
+
+func (r *reader) done() bool {
+	return r.pos >= len(r.b)
+}
+
+func (r *reader) next() (*tag, error) {
+	if r.done() {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	b0 := r.b[r.pos]
+	r.pos++
+	t := &tag{number: b0 >> 4, context: b0&0x08 != 0}
+	if t.number == 0x0F {
+		if r.done() {
+			return nil, fmt.Errorf("unexpected end of data")
+		}
+		t.number = r.b[r.pos]
+		r.pos++
+	}
+	lvt := int(b0 & 0x07)
+	if t.context && lvt == 6 {
+		t.opening = true
+		return t, nil
+	}
+	if t.context && lvt == 7 {
+		t.closing = true
+		return t, nil
+	}
+	if !t.context && t.number == tagBoolean {
+		t.boolValue = lvt == 1
+		return t, nil
+	}
+	length := lvt
+	if lvt == 5 {
+		if r.done() {
+			return nil, fmt.Errorf("unexpected end of data")
+		}
+		length = int(r.b[r.pos])
+		r.pos++
+		switch length {
+		case 254:
+			if r.pos+2 > len(r.b) {
+				return nil, fmt.Errorf("unexpected end of data")
+			}
+			length = int(binary.BigEndian.Uint16(r.b[r.pos:]))
+			r.pos += 2
+		case 255:
+			if r.pos+4 > len(r.b) {
+				return nil, fmt.Errorf("unexpected end of data")
+			}
+			length = int(binary.BigEndian.Uint32(r.b[r.pos:]))
+			r.pos += 4
+		}
+	}
+	if r.pos+length > len(r.b) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+	t.value = r.b[r.pos : r.pos+length]
+	r.pos += length
+	return t, nil
+}
+
+// peek returns the next tag without consuming it
+func (r *reader) peek() (*tag, error) {
+	pos := r.pos
+	t, err := r.next()
+	r.pos = pos
+	return t, err
+}
+
+func (r *reader) expectContext(number byte) (*tag, error) {
+	t, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if !t.context || t.opening || t.closing || t.number != number {
+		return nil, fmt.Errorf("expect context tag %d", number)
+	}
+	return t, nil
+}
+
+func (r *reader) expectOpening(number byte) error {
+	t, err := r.next()
+	if err != nil {
+		return err
+	}
+	if !t.opening || t.number != number {
+		return fmt.Errorf("expect opening tag %d", number)
+	}
+	return nil
+}
+
+func (r *reader) readEnumerated() (uint32, error) {
+	t, err := r.next()
+	if err != nil {
+		return 0, err
+	}
+	if t.context || t.number != tagEnumerated {
+		return 0, fmt.Errorf("expect enumerated")
+	}
+	return uint32(decodeUnsigned(t.value)), nil
+}
+
+// readValues reads the application values until the closing tag
+func (r *reader) readValues(closing byte) (interface{}, error) {
+	var values []interface{}
+	for {
+		t, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if t.closing && t.number == closing {
+			break
+		}
+		if t.context {
+			return nil, fmt.Errorf("constructed value is not supported")
+		}
+		v, err := decodeAppValue(t)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	switch len(values) {
+	case 0:
+		return nil, nil
+	case 1:
+		return values[0], nil
+	default:
+		return values, nil
+	}
+}
+
+func decodeUnsigned(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func decodeSigned(b []byte) int64 {
+	if len(b) == 0 {
+		return 0
+	}
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+// decodeAppValue converts the application tagged value to the types supported by the rule engine
+func decodeAppValue(t *tag) (interface{}, error) {
+	v := t.value
+	switch t.number {
+	case tagNull:
+		return nil, nil
+	case tagBoolean:
+		return t.boolValue, nil
+	case tagUnsigned, tagEnumerated:
+		return int64(decodeUnsigned(v)), nil
+	case tagSigned:
+		return decodeSigned(v), nil
+	case tagReal:
+		if len(v) != 4 {
+			return nil, fmt.Errorf("invalid real length %d", len(v))
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(v))), nil
+	case tagDouble:
+		if len(v) != 8 {
+			return nil, fmt.Errorf("invalid double length %d", len(v))
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(v)), nil
+	case tagOctetString:
+		// copy as the packet buffer is reused
+		return append([]byte(nil), v...), nil
+	case tagCharacterString:
+		if len(v) == 0 {
+			return "", nil
+		}
+		switch v[0] {
+		case 0: // UTF-8
+			return string(v[1:]), nil
+		case 5: // ISO 8859-1
+			r := make([]rune, len(v)-1)
+			for i, c := range v[1:] {
+				r[i] = rune(c)
+			}
+			return string(r), nil
+		default:
+			return nil, fmt.Errorf("unsupported character set %d", v[0])
+		}
+	case tagBitString:
+		if len(v) == 0 {
+			return []interface{}{}, nil
+		}
+		n := (len(v)-1)*8 - int(v[0])
+		bits := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			bits = append(bits, v[1+i/8]&(0x80>>(i%8)) != 0)
+		}
+		return bits, nil
+	case tagDate:
+		if len(v) != 4 {
+			return nil, fmt.Errorf("invalid date length %d", len(v))
+		}
+		return fmt.Sprintf("%s-%s-%s", dateField(v[0], 1900, 4), dateField(v[1], 0, 2), dateField(v[2], 0, 2)), nil
+	case tagTime:
+		if len(v) != 4 {
+			return nil, fmt.Errorf("invalid time length %d", len(v))
+		}
+		return fmt.Sprintf("%s:%s:%s.%s", dateField(v[0], 0, 2), dateField(v[1], 0, 2), dateField(v[2], 0, 2), dateField(v[3], 0, 2)), nil
+	case tagObjectId:
+		if len(v) != 4 {
+			return nil, fmt.Errorf("invalid object id length %d", len(v))
+		}
+		return decodeObjectId(binary.BigEndian.Uint32(v)).String(), nil
+	default:
+		return nil, fmt.Errorf("unsupported application tag %d", t.number)
+	}
+}
+
+// dateField formats the date or time field, 0xFF means unspecified
+func dateField(b byte, offset int, width int) string {
+	if b == 0xFF {
+		return strings.Repeat("*", width)
+	}
+	return fmt.Sprintf("%0*d", width, int(b)+offset)
+}
+
+type propertyValue struct {
+	object   objectId
+	property uint32
+	value    interface{}
+	// err is the error returned by the device for the property
+	err error
+}
+
+func decodeReadPropertyMultipleAck(data []byte) ([]*propertyValue, error) {
+	r := &reader{b: data}
+	var result []*propertyValue
+	for !r.done() {
+		t, err := r.expectContext(0)
+		if err != nil {
+			return nil, err
+		}
+		object := decodeObjectId(uint32(decodeUnsigned(t.value)))
+		if err := r.expectOpening(1); err != nil {
+			return nil, err
+		}
+		for {
+			t, err := r.next()
+			if err != nil {
+				return nil, err
+			}
+			if t.closing && t.number == 1 {
+				break
+			}
+			if !t.context || t.number != 2 {
+				return nil, fmt.Errorf("expect context tag 2")
+			}
+			pv := &propertyValue{object: object, property: uint32(decodeUnsigned(t.value))}
+			t, err = r.next()
+			if err != nil {
+				return nil, err
+			}
+			// skip the array index
+			if t.context && !t.opening && t.number == 3 {
+				if t, err = r.next(); err != nil {
+					return nil, err
+				}
+			}
+			switch {
+			case t.opening && t.number == 4:
+				pv.value, err = r.readValues(4)
+				if err != nil {
+					return nil, fmt.Errorf("decode property %d of %s error: %v", pv.property, object, err)
+				}
+			case t.opening && t.number == 5:
+				class, err := r.readEnumerated()
+				if err != nil {
+					return nil, err
+				}
+				code, err := r.readEnumerated()
+				if err != nil {
+					return nil, err
+				}
+				if t, err := r.next(); err != nil || !t.closing || t.number != 5 {
+					return nil, fmt.Errorf("expect closing tag 5")
+				}
+				pv.err = fmt.Errorf("error class %d code %d", class, code)
+			default:
+				return nil, fmt.Errorf("expect opening tag 4 or 5")
+			}
+			result = append(result, pv)
+		}
+	}
+	return result, nil
+}
+
+type covNotification struct {
+	processId uint32
+	device    objectId
+	object    objectId
+	values    []*propertyValue
+}
+
+func decodeCOVNotification(data []byte) (*covNotification, error) {
+	r := &reader{b: data}
+	n := &covNotification{}
+	t, err := r.expectContext(0)
+	if err != nil {
+		return nil, err
+	}
+	n.processId = uint32(decodeUnsigned(t.value))
+	if t, err = r.expectContext(1); err != nil {
+		return nil, err
+	}
+	n.device = decodeObjectId(uint32(decodeUnsigned(t.value)))
+	if t, err = r.expectContext(2); err != nil {
+		return nil, err
+	}
+	n.object = decodeObjectId(uint32(decodeUnsigned(t.value)))
+	// time remaining
+	if _, err = r.expectContext(3); err != nil {
+		return nil, err
+	}
+	if err = r.expectOpening(4); err != nil {
+		return nil, err
+	}
+	for {
+		t, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if t.closing && t.number == 4 {
+			break
+		}
+		if !t.context || t.number != 0 {
+			return nil, fmt.Errorf("expect context tag 0")
+		}
+		pv := &propertyValue{object: n.object, property: uint32(decodeUnsigned(t.value))}
+		t, err = r.next()
+		if err != nil {
+			return nil, err
+		}
+		// skip the array index
+		if t.context && !t.opening && t.number == 1 {
+			if t, err = r.next(); err != nil {
+				return nil, err
+			}
+		}
+		if !t.opening || t.number != 2 {
+			return nil, fmt.Errorf("expect opening tag 2")
+		}
+		if pv.value, err = r.readValues(2); err != nil {
+			return nil, fmt.Errorf("decode property %d of %s error: %v", pv.property, n.object, err)
+		}
+		// skip the priority
+		if t, err := r.peek(); err == nil && t.context && !t.opening && !t.closing && t.number == 3 {
+			_, _ = r.next()
+		}
+		n.values = append(n.values, pv)
+	}
+	return n, nil
+}
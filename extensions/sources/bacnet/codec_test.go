@@ -0,0 +1,234 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseObjectId(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp objectId
+		err string
+	}{
+		{s: "analogInput:1", exp: objectId{Type: 0, Instance: 1}},
+		{s: "multiStateValue:4194303", exp: objectId{Type: 19, Instance: 4194303}},
+		{s: "130:2", exp: objectId{Type: 130, Instance: 2}},
+		{s: "analogInput", err: "invalid object analogInput, the format must be type:instance"},
+		{s: "analog:1", err: "invalid object type analog"},
+		{s: "analogInput:4194304", err: "invalid object instance 4194304"},
+	}
+	for i, tt := range tests {
+		o, err := parseObjectId(tt.s)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if o != tt.exp {
+			t.Errorf("%d: expect %v but got %v", i, tt.exp, o)
+		}
+		if o.String() != tt.s {
+			t.Errorf("%d: expect string %s but got %s", i, tt.s, o.String())
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		b   []byte
+		exp []byte
+	}{
+		{
+			b: encodeReadPropertyMultiple([]*objectRequest{
+				{object: objectId{Type: 0, Instance: 1}, properties: []uint32{85, 111}},
+				{object: objectId{Type: 5, Instance: 300}, properties: []uint32{85}},
+			}),
+			exp: []byte{
+				0x0C, 0x00, 0x00, 0x00, 0x01, 0x1E, 0x09, 0x55, 0x09, 0x6F, 0x1F,
+				0x0C, 0x01, 0x40, 0x01, 0x2C, 0x1E, 0x09, 0x55, 0x1F,
+			},
+		}, {
+			b:   encodeSubscribeCOV(1, objectId{Type: 0, Instance: 1}, 300),
+			exp: []byte{0x09, 0x01, 0x1C, 0x00, 0x00, 0x00, 0x01, 0x29, 0x00, 0x3A, 0x01, 0x2C},
+		}, {
+			b:   encodeConfirmedRequest(7, serviceSubscribeCOV, []byte{0x09, 0x01}),
+			exp: []byte{0x81, 0x0A, 0x00, 0x0C, 0x01, 0x04, 0x00, 0x05, 0x07, 0x05, 0x09, 0x01},
+		},
+	}
+	for i, tt := range tests {
+		if !bytes.Equal(tt.exp, tt.b) {
+			t.Errorf("%d: expect % X but got % X", i, tt.exp, tt.b)
+		}
+	}
+}
+
+func TestDecodePacket(t *testing.T) {
+	tests := []struct {
+		b   []byte
+		exp *apdu
+		err string
+	}{
+		{
+			b:   []byte{0x81, 0x0A, 0x00, 0x0A, 0x01, 0x00, 0x30, 0x07, 0x0E, 0x0C},
+			exp: &apdu{pduType: pduComplexAck, invokeId: 7, service: serviceReadPropertyMultiple, data: []byte{0x0C}},
+		}, {
+			// routed from a remote network with the source address
+			b:   []byte{0x81, 0x0A, 0x00, 0x0D, 0x01, 0x08, 0x00, 0x02, 0x01, 0x05, 0x20, 0x03, 0x05},
+			exp: &apdu{pduType: pduSimpleAck, invokeId: 3, service: serviceSubscribeCOV, data: []byte{}},
+		}, {
+			b:   []byte{0x81, 0x0B, 0x00, 0x09, 0x01, 0x00, 0x10, 0x02, 0x09},
+			exp: &apdu{pduType: pduUnconfirmedRequest, service: serviceUnconfirmedCOVNotification, data: []byte{0x09}},
+		}, {
+			b:   []byte{0x81, 0x0A, 0x00, 0x0D, 0x01, 0x00, 0x50, 0x01, 0x0E, 0x91, 0x02, 0x91, 0x20},
+			exp: &apdu{pduType: pduError, invokeId: 1, service: serviceReadPropertyMultiple, data: []byte{0x91, 0x02, 0x91, 0x20}, err: fmt.Errorf("device returns error class 2 code 32")},
+		}, {
+			b:   []byte{0x81, 0x0A, 0x00, 0x09, 0x01, 0x00, 0x71, 0x01, 0x04},
+			exp: &apdu{pduType: pduAbort, invokeId: 1, service: 4, data: []byte{}, err: fmt.Errorf("device aborts the request with reason 4")},
+		}, {
+			b:   []byte{0x81, 0x0A, 0x00, 0x06, 0x01, 0x80},
+			exp: nil,
+		}, {
+			b:   []byte{0x81, 0x0A, 0x00, 0x07, 0x01, 0x00},
+			err: "invalid BVLC length",
+		}, {
+			b:   []byte{0x82, 0x0A, 0x00, 0x04},
+			err: "not a BACnet/IP packet",
+		},
+	}
+	for i, tt := range tests {
+		a, err := decodePacket(tt.b)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.exp, a) {
+			t.Errorf("%d: expect %+v but got %+v", i, tt.exp, a)
+		}
+	}
+}
+
+func TestDecodeReadPropertyMultipleAck(t *testing.T) {
+	data := []byte{
+		0x0C, 0x00, 0x00, 0x00, 0x01, 0x1E,
+		// presentValue: real 20.5
+		0x29, 0x55, 0x4E, 0x44, 0x41, 0xA4, 0x00, 0x00, 0x4F,
+		// statusFlags: bit string of 4 bits with fault set
+		0x29, 0x6F, 0x4E, 0x82, 0x04, 0x40, 0x4F,
+		// description: unknown property error
+		0x29, 0x1C, 0x5E, 0x91, 0x02, 0x91, 0x20, 0x5F,
+		0x1F,
+		0x0C, 0x00, 0xC0, 0x00, 0x02, 0x1E,
+		// presentValue: enumerated active
+		0x29, 0x55, 0x4E, 0x91, 0x01, 0x4F,
+		// objectName: character string
+		0x29, 0x4D, 0x4E, 0x75, 0x05, 0x00, 0x46, 0x61, 0x6E, 0x31, 0x4F,
+		0x1F,
+	}
+	values, err := decodeReadPropertyMultipleAck(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []*propertyValue{
+		{object: objectId{Type: 0, Instance: 1}, property: 85, value: 20.5},
+		{object: objectId{Type: 0, Instance: 1}, property: 111, value: []interface{}{false, true, false, false}},
+		{object: objectId{Type: 0, Instance: 1}, property: 28, err: fmt.Errorf("error class 2 code 32")},
+		{object: objectId{Type: 3, Instance: 2}, property: 85, value: int64(1)},
+		{object: objectId{Type: 3, Instance: 2}, property: 77, value: "Fan1"},
+	}
+	if !reflect.DeepEqual(exp, values) {
+		for _, v := range values {
+			t.Logf("%+v", v)
+		}
+		t.Errorf("values mismatch")
+	}
+	if _, err := decodeReadPropertyMultipleAck(data[:10]); err == nil {
+		t.Errorf("expect error for truncated data")
+	}
+}
+
+func TestDecodeCOVNotification(t *testing.T) {
+	data := []byte{
+		0x09, 0x01, 0x1C, 0x02, 0x00, 0x00, 0x0A, 0x2C, 0x00, 0x00, 0x00, 0x01, 0x39, 0x00, 0x4E,
+		0x09, 0x55, 0x2E, 0x44, 0x41, 0xA4, 0x00, 0x00, 0x2F,
+		0x09, 0x6F, 0x2E, 0x82, 0x04, 0x00, 0x2F,
+		0x4F,
+	}
+	n, err := decodeCOVNotification(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := &covNotification{
+		processId: 1,
+		device:    objectId{Type: 8, Instance: 10},
+		object:    objectId{Type: 0, Instance: 1},
+		values: []*propertyValue{
+			{object: objectId{Type: 0, Instance: 1}, property: 85, value: 20.5},
+			{object: objectId{Type: 0, Instance: 1}, property: 111, value: []interface{}{false, false, false, false}},
+		},
+	}
+	if !reflect.DeepEqual(exp, n) {
+		t.Errorf("expect %+v but got %+v", exp, n)
+	}
+}
+
+func TestDecodeAppValue(t *testing.T) {
+	tests := []struct {
+		b   []byte
+		exp interface{}
+	}{
+		{b: []byte{0x00}, exp: nil},
+		{b: []byte{0x11}, exp: true},
+		{b: []byte{0x22, 0x01, 0x00}, exp: int64(256)},
+		{b: []byte{0x31, 0xFE}, exp: int64(-2)},
+		{b: []byte{0x55, 0x08, 0x40, 0x09, 0x21, 0xFB, 0x54, 0x44, 0x2D, 0x18}, exp: 3.141592653589793},
+		{b: []byte{0x62, 0x01, 0x02}, exp: []byte{0x01, 0x02}},
+		{b: []byte{0xA4, 0x7B, 0x05, 0x01, 0xFF}, exp: "2023-05-01"},
+		{b: []byte{0xA4, 0xFF, 0xFF, 0x01, 0xFF}, exp: "****-**-01"},
+		{b: []byte{0xB4, 0x0D, 0x1E, 0x00, 0x00}, exp: "13:30:00.00"},
+		{b: []byte{0xC4, 0x00, 0x80, 0x00, 0x03}, exp: "analogValue:3"},
+	}
+	for i, tt := range tests {
+		r := &reader{b: tt.b}
+		tg, err := r.next()
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		v, err := decodeAppValue(tg)
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.exp, v) {
+			t.Errorf("%d: expect %v but got %v", i, tt.exp, v)
+		}
+	}
+}
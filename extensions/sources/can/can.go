@@ -0,0 +1,164 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/extensions/canbus/dbc"
+	"github.com/lf-edge/ekuiper/extensions/canbus/socketcan"
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+type frameConn interface {
+	ReadFrame() (*socketcan.Frame, error)
+	Close() error
+}
+
+// dial is replaceable for test
+var dial = func(ifname string) (frameConn, error) {
+	c, err := socketcan.Dial(ifname)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type canSourceConfig struct {
+	// DbcPath is the path of the DBC file to decode the frames
+	DbcPath string `json:"dbcPath"`
+	// Messages are the names of the messages to decode. All messages in the DBC are decoded if not set
+	Messages []string `json:"messages"`
+	// Protocol is the higher layer protocol of the frames, support raw CAN, j1939 and canopen
+	Protocol string `json:"protocol"`
+	// Nodes are the CANopen nodes whose PDOs are decoded by the mapping in the EDS files
+	Nodes []*canopenNode `json:"nodes"`
+}
+
+type canSource struct {
+	ifname   string
+	protocol string
+	db       *dbc.Database
+	// the CANopen node id of each PDO message id
+	nodeIds map[uint32]uint8
+	// the message ids to decode, nil means all
+	filter map[uint32]struct{}
+	conn   frameConn
+}
+
+func (s *canSource) Configure(datasource string, props map[string]interface{}) error {
+	cfg := &canSourceConfig{}
+	err := cast.MapToStruct(props, cfg)
+	if err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if datasource == "" {
+		return fmt.Errorf("source `can` requires the can interface name as the datasource")
+	}
+	switch cfg.Protocol {
+	case "":
+		cfg.Protocol = protocolRaw
+	case protocolRaw, protocolJ1939, protocolCANopen:
+	default:
+		return fmt.Errorf("source `can` property `protocol` must be one of %s, %s and %s but got %s", protocolRaw, protocolJ1939, protocolCANopen, cfg.Protocol)
+	}
+	if cfg.Protocol == protocolCANopen {
+		s.db, s.nodeIds, err = loadCANopenNodes(cfg.Nodes)
+		if err != nil {
+			return err
+		}
+	} else {
+		if cfg.DbcPath == "" {
+			return fmt.Errorf("source `can` property `dbcPath` is required")
+		}
+		s.db, err = dbc.ParseFile(cfg.DbcPath)
+		if err != nil {
+			return err
+		}
+	}
+	if len(cfg.Messages) > 0 {
+		s.filter = make(map[uint32]struct{}, len(cfg.Messages))
+		for _, name := range cfg.Messages {
+			msg, ok := s.db.MessageByName(name)
+			if !ok {
+				return fmt.Errorf("message %s is not defined", name)
+			}
+			s.filter[msg.ID] = struct{}{}
+		}
+	}
+	s.ifname = datasource
+	s.protocol = cfg.Protocol
+	return nil
+}
+
+func (s *canSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	conn, err := dial(s.ifname)
+	if err != nil {
+		errCh <- err
+		return
+	}
+	s.conn = conn
+	dec := newDecoder(s.protocol, s.db, s.nodeIds)
+	logger.Infof("can source connected to %s with protocol %s", s.ifname, s.protocol)
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+	for {
+		frame, err := conn.ReadFrame()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				logger.Infof("can source done")
+			default:
+				errCh <- fmt.Errorf("can source read from %s error: %v", s.ifname, err)
+			}
+			return
+		}
+		if frame.Error || frame.Remote {
+			continue
+		}
+		rcvTime := conf.GetNow()
+		msg, data, meta, ok := dec.decode(frame, rcvTime)
+		if !ok {
+			continue
+		}
+		if s.filter != nil {
+			if _, ok := s.filter[msg.ID]; !ok {
+				continue
+			}
+		}
+		select {
+		case consumer <- api.NewDefaultSourceTupleWithTime(msg.Decode(data), meta, rcvTime):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *canSource) Close(_ api.StreamContext) error {
+	// the connection may be closed already when the context is done
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+	return nil
+}
+
+func Can() api.Source {
+	return &canSource{}
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/extensions/canbus/socketcan"
+	"github.com/lf-edge/ekuiper/internal/io/mock"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+type mockConn struct {
+	frames chan *socketcan.Frame
+}
+
+func (m *mockConn) ReadFrame() (*socketcan.Frame, error) {
+	f, ok := <-m.frames
+	if !ok {
+		return nil, errors.New("closed")
+	}
+	return f, nil
+}
+
+func (m *mockConn) Close() error {
+	return nil
+}
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		ds    string
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			ds:    "vcan0",
+			props: map[string]interface{}{},
+			err:   "source `can` property `dbcPath` is required",
+		}, {
+			ds:    "",
+			props: map[string]interface{}{"dbcPath": "test/test.dbc"},
+			err:   "source `can` requires the can interface name as the datasource",
+		}, {
+			ds:    "vcan0",
+			props: map[string]interface{}{"dbcPath": "test/test.dbc", "messages": []interface{}{"Unknown"}},
+			err:   "message Unknown is not defined",
+		}, {
+			ds:    "vcan0",
+			props: map[string]interface{}{"dbcPath": "test/test.dbc", "protocol": "j1587"},
+			err:   "source `can` property `protocol` must be one of raw, j1939 and canopen but got j1587",
+		}, {
+			ds:    "vcan0",
+			props: map[string]interface{}{"protocol": "canopen"},
+			err:   "source `can` property `nodes` is required for canopen protocol",
+		}, {
+			ds:    "vcan0",
+			props: map[string]interface{}{"protocol": "canopen", "nodes": []interface{}{map[string]interface{}{"nodeId": 128, "edsPath": "test/test.eds"}}},
+			err:   "canopen node id 128 must be in range 1 to 127",
+		}, {
+			ds:    "vcan0",
+			props: map[string]interface{}{"protocol": "canopen", "nodes": []interface{}{map[string]interface{}{"nodeId": 5, "edsPath": "test/test.eds"}}, "messages": []interface{}{"Node5_TPDO1"}},
+		}, {
+			ds:    "vcan0",
+			props: map[string]interface{}{"dbcPath": "test/test.dbc", "messages": []interface{}{"GearData"}},
+		},
+	}
+	for i, tt := range tests {
+		err := Can().Configure(tt.ds, tt.props)
+		if tt.err == "" && err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		} else if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	frames := make(chan *socketcan.Frame, 3)
+	frames <- &socketcan.Frame{ID: 300, Data: []byte{1}}
+	frames <- &socketcan.Frame{ID: 100, Data: []byte{0x40, 0x1F, 0xF6}}
+	frames <- &socketcan.Frame{ID: 200, Data: []byte{0x03}}
+	dial = func(_ string) (frameConn, error) {
+		return &mockConn{frames: frames}, nil
+	}
+	r := Can()
+	err := r.Configure("vcan0", map[string]interface{}{"dbcPath": "test/test.dbc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []api.SourceTuple{
+		api.NewDefaultSourceTuple(map[string]interface{}{"EngineSpeed": 1000.0, "Temperature": int64(-50)}, map[string]interface{}{"id": uint32(100), "name": "EngineData"}),
+		api.NewDefaultSourceTuple(map[string]interface{}{"Gear": int64(3)}, map[string]interface{}{"id": uint32(200), "name": "GearData"}),
+	}
+	mock.TestSourceOpen(r, exp, t)
+}
+
+func TestOpenJ1939(t *testing.T) {
+	frames := make(chan *socketcan.Frame, 6)
+	// standard frame is ignored
+	frames <- &socketcan.Frame{ID: 100, Data: []byte{0x40, 0x1F, 0xF6}}
+	// EEC1 from source address 0x00 instead of 0xFE in the dbc
+	frames <- &socketcan.Frame{ID: 0x0CF00400, Extended: true, Data: []byte{0, 0, 0, 0x40, 0x1F, 0, 0, 0}}
+	// DM1 in BAM
+	frames <- &socketcan.Frame{ID: 0x1CECFF00, Extended: true, Data: []byte{32, 10, 0, 2, 0xFF, 0xCA, 0xFE, 0x00}}
+	frames <- &socketcan.Frame{ID: 0x1CEBFF00, Extended: true, Data: []byte{1, 0x04, 0xFF, 0x6E, 0x00, 0x00, 0x01, 0x00}}
+	frames <- &socketcan.Frame{ID: 0x1CEBFF00, Extended: true, Data: []byte{2, 0x00, 0x05, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}}
+	dial = func(_ string) (frameConn, error) {
+		return &mockConn{frames: frames}, nil
+	}
+	r := Can()
+	err := r.Configure("vcan0", map[string]interface{}{"dbcPath": "test/test.dbc", "protocol": "j1939"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []api.SourceTuple{
+		api.NewDefaultSourceTuple(map[string]interface{}{"EngineSpeed": 1000.0}, map[string]interface{}{
+			"id": uint32(0x0CF00400), "name": "EEC1", "pgn": uint32(61444), "priority": uint8(3), "source": uint8(0), "destination": uint8(0xFF),
+		}),
+		api.NewDefaultSourceTuple(map[string]interface{}{"LampStatus": int64(4), "SPN": int64(110), "OccurrenceCount": int64(5)}, map[string]interface{}{
+			"id": uint32(0x1CEBFF00), "name": "DM1", "pgn": uint32(65226), "priority": uint8(7), "source": uint8(0), "destination": uint8(0xFF),
+		}),
+	}
+	mock.TestSourceOpen(r, exp, t)
+}
+
+func TestOpenCANopen(t *testing.T) {
+	frames := make(chan *socketcan.Frame, 3)
+	frames <- &socketcan.Frame{ID: 0x185, Data: []byte{0x37, 0x02, 0x18, 0xFC, 0xFF, 0xFF}}
+	frames <- &socketcan.Frame{ID: 0x705, Data: []byte{0x05}}
+	frames <- &socketcan.Frame{ID: 0x186, Data: []byte{0x40, 0x00, 0x0A, 0x00, 0x00, 0x00}}
+	dial = func(_ string) (frameConn, error) {
+		return &mockConn{frames: frames}, nil
+	}
+	r := Can()
+	err := r.Configure("vcan0", map[string]interface{}{
+		"protocol": "canopen",
+		"nodes": []interface{}{
+			map[string]interface{}{"nodeId": 5, "edsPath": "test/test.eds"},
+			map[string]interface{}{"nodeId": 6, "edsPath": "test/test.eds"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []api.SourceTuple{
+		api.NewDefaultSourceTuple(map[string]interface{}{"Statusword": int64(0x0237), "Position_actual_value": int64(-1000)}, map[string]interface{}{"id": uint32(0x185), "name": "Node5_TPDO1", "nodeId": uint8(5)}),
+		api.NewDefaultSourceTuple(map[string]interface{}{"Statusword": int64(0x0040), "Position_actual_value": int64(10)}, map[string]interface{}{"id": uint32(0x186), "name": "Node6_TPDO1", "nodeId": uint8(6)}),
+	}
+	mock.TestSourceOpen(r, exp, t)
+}
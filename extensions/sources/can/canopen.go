@@ -0,0 +1,68 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/extensions/canbus/canopen"
+	"github.com/lf-edge/ekuiper/extensions/canbus/dbc"
+)
+
+type canopenNode struct {
+	NodeId  int    `json:"nodeId"`
+	EdsPath string `json:"edsPath"`
+}
+
+// loadCANopenNodes maps the transmit PDOs of all the nodes into one message database.
+// It also returns the node id of each PDO message.
+func loadCANopenNodes(nodes []*canopenNode) (*dbc.Database, map[uint32]uint8, error) {
+	if len(nodes) == 0 {
+		return nil, nil, fmt.Errorf("source `can` property `nodes` is required for canopen protocol")
+	}
+	db := &dbc.Database{Messages: make(map[uint32]*dbc.Message)}
+	nodeIds := make(map[uint32]uint8)
+	// the same EDS file is usually shared by the nodes of the same device type
+	dicts := make(map[string]*canopen.Dictionary)
+	for _, n := range nodes {
+		if n.NodeId < 1 || n.NodeId > 127 {
+			return nil, nil, fmt.Errorf("canopen node id %d must be in range 1 to 127", n.NodeId)
+		}
+		if n.EdsPath == "" {
+			return nil, nil, fmt.Errorf("canopen node %d property `edsPath` is required", n.NodeId)
+		}
+		dict, ok := dicts[n.EdsPath]
+		if !ok {
+			var err error
+			dict, err = canopen.ParseEDSFile(n.EdsPath)
+			if err != nil {
+				return nil, nil, err
+			}
+			dicts[n.EdsPath] = dict
+		}
+		msgs, err := dict.TPDOMessages(uint8(n.NodeId))
+		if err != nil {
+			return nil, nil, fmt.Errorf("canopen node %d: %v", n.NodeId, err)
+		}
+		for _, msg := range msgs {
+			if _, ok := db.Messages[msg.ID]; ok {
+				return nil, nil, fmt.Errorf("canopen node %d: cob-id %#x is used by multiple PDOs", n.NodeId, msg.ID)
+			}
+			db.Messages[msg.ID] = msg
+			nodeIds[msg.ID] = uint8(n.NodeId)
+		}
+	}
+	return db, nodeIds, nil
+}
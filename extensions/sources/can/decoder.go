@@ -0,0 +1,120 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+
+	"github.com/lf-edge/ekuiper/extensions/canbus/dbc"
+	"github.com/lf-edge/ekuiper/extensions/canbus/j1939"
+	"github.com/lf-edge/ekuiper/extensions/canbus/socketcan"
+)
+
+const (
+	protocolRaw     = "raw"
+	protocolJ1939   = "j1939"
+	protocolCANopen = "canopen"
+)
+
+// decoder finds the message definition of a frame and returns the payload to decode with the tuple metadata.
+// It returns false if the frame does not produce a message.
+type decoder interface {
+	decode(frame *socketcan.Frame, now time.Time) (*dbc.Message, []byte, map[string]interface{}, bool)
+}
+
+func newDecoder(protocol string, db *dbc.Database, nodeIds map[uint32]uint8) decoder {
+	switch protocol {
+	case protocolJ1939:
+		return newJ1939Decoder(db)
+	case protocolCANopen:
+		return &canopenDecoder{rawDecoder: rawDecoder{db: db}, nodeIds: nodeIds}
+	default:
+		return &rawDecoder{db: db}
+	}
+}
+
+type rawDecoder struct {
+	db *dbc.Database
+}
+
+func (d *rawDecoder) decode(frame *socketcan.Frame, _ time.Time) (*dbc.Message, []byte, map[string]interface{}, bool) {
+	msg, ok := d.db.Message(frame.ID)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	return msg, frame.Data, map[string]interface{}{
+		"id":   frame.ID,
+		"name": msg.Name,
+	}, true
+}
+
+// j1939Decoder matches the messages by PGN regardless of the priority and the source address
+// and reassembles the transport protocol messages which are longer than 8 bytes
+type j1939Decoder struct {
+	pgns      map[uint32]*dbc.Message
+	assembler *j1939.Assembler
+}
+
+func newJ1939Decoder(db *dbc.Database) *j1939Decoder {
+	d := &j1939Decoder{
+		pgns:      make(map[uint32]*dbc.Message),
+		assembler: j1939.NewAssembler(),
+	}
+	for _, msg := range db.Messages {
+		if msg.Extended {
+			d.pgns[j1939.PGN(msg.ID)] = msg
+		}
+	}
+	return d
+}
+
+func (d *j1939Decoder) decode(frame *socketcan.Frame, now time.Time) (*dbc.Message, []byte, map[string]interface{}, bool) {
+	if !frame.Extended {
+		return nil, nil, nil, false
+	}
+	m, ok := d.assembler.Push(frame.ID, frame.Data, now)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	msg, ok := d.pgns[m.PGN]
+	if !ok {
+		return nil, nil, nil, false
+	}
+	return msg, m.Data, map[string]interface{}{
+		"id":          frame.ID,
+		"name":        msg.Name,
+		"pgn":         m.PGN,
+		"priority":    m.Priority,
+		"source":      m.Source,
+		"destination": m.Destination,
+	}, true
+}
+
+// canopenDecoder decodes the PDOs by the message definitions mapped from the EDS files
+type canopenDecoder struct {
+	rawDecoder
+	nodeIds map[uint32]uint8
+}
+
+func (d *canopenDecoder) decode(frame *socketcan.Frame, now time.Time) (*dbc.Message, []byte, map[string]interface{}, bool) {
+	if frame.Extended {
+		return nil, nil, nil, false
+	}
+	msg, data, meta, ok := d.rawDecoder.decode(frame, now)
+	if ok {
+		meta["nodeId"] = d.nodeIds[msg.ID]
+	}
+	return msg, data, meta, ok
+}
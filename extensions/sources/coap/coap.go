@@ -0,0 +1,290 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	coapnet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/options"
+	"github.com/plgd-dev/go-coap/v3/udp"
+	"github.com/plgd-dev/go-coap/v3/udp/client"
+	udpServer "github.com/plgd-dev/go-coap/v3/udp/server"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+const (
+	modeClient = "client"
+	modeServer = "server"
+)
+
+type sourceConf struct {
+	// Mode is client to request the resource of a CoAP server or server to receive the requests from the devices
+	Mode string `json:"mode"`
+	// Server is the address of the CoAP server in client mode
+	Server string `json:"server"`
+	// Addr is the listening address in server mode
+	Addr string `json:"addr"`
+	// Observe subscribes the changes of the resource instead of polling in client mode
+	Observe bool `json:"observe"`
+	// Interval is the polling interval in milliseconds
+	Interval int `json:"interval"`
+	// Timeout is the timeout of each request in milliseconds
+	Timeout int `json:"timeout"`
+}
+
+type coapSource struct {
+	path string
+	c    *sourceConf
+
+	conn     *client.Conn
+	server   *udpServer.Server
+	listener *coapnet.UDPConn
+}
+
+func (s *coapSource) Configure(path string, props map[string]interface{}) error {
+	c := &sourceConf{
+		Mode:     modeClient,
+		Server:   "localhost:5683",
+		Addr:     ":5683",
+		Interval: 10000,
+		Timeout:  5000,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("coap source requires the resource path starting with / as the datasource")
+	}
+	switch c.Mode {
+	case modeClient:
+		if c.Server == "" {
+			return fmt.Errorf("server is required in client mode")
+		}
+		if !c.Observe && c.Interval <= 0 {
+			return fmt.Errorf("interval must be positive")
+		}
+	case modeServer:
+		if c.Addr == "" {
+			return fmt.Errorf("addr is required in server mode")
+		}
+	default:
+		return fmt.Errorf("invalid mode %s, must be client or server", c.Mode)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	s.path = path
+	s.c = c
+	return nil
+}
+
+func (s *coapSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	var err error
+	switch {
+	case s.c.Mode == modeServer:
+		err = s.serve(ctx, consumer)
+	case s.c.Observe:
+		err = s.observe(ctx, consumer)
+	default:
+		err = s.poll(ctx, consumer)
+	}
+	if err != nil {
+		errCh <- err
+	}
+}
+
+// serve listens for the POST and PUT requests from the devices
+func (s *coapSource) serve(ctx api.StreamContext, consumer chan<- api.SourceTuple) error {
+	logger := ctx.GetLogger()
+	r := mux.NewRouter()
+	err := r.Handle(s.path, mux.HandlerFunc(func(w mux.ResponseWriter, req *mux.Message) {
+		if req.Code() != codes.POST && req.Code() != codes.PUT {
+			_ = w.SetResponse(codes.MethodNotAllowed, message.TextPlain, nil)
+			return
+		}
+		meta := map[string]interface{}{
+			"path":       s.path,
+			"method":     req.Code().String(),
+			"remoteAddr": w.Conn().RemoteAddr().String(),
+		}
+		if err := s.emit(ctx, req.Message, meta, consumer); err != nil {
+			logger.Errorf("coap source fails to process the request from %s: %v", w.Conn().RemoteAddr(), err)
+			_ = w.SetResponse(codes.BadRequest, message.TextPlain, strings.NewReader(err.Error()))
+			return
+		}
+		_ = w.SetResponse(codes.Changed, message.TextPlain, nil)
+	}))
+	if err != nil {
+		return err
+	}
+	l, err := coapnet.NewListenUDP("udp", s.c.Addr)
+	if err != nil {
+		return fmt.Errorf("%s: coap source fails to listen %s: %v", errorx.IOErr, s.c.Addr, err)
+	}
+	s.listener = l
+	s.server = udp.NewServer(options.WithMux(r))
+	go func() {
+		<-ctx.Done()
+		s.server.Stop()
+	}()
+	logger.Infof("coap source serves %s on %s", s.path, s.c.Addr)
+	if err := s.server.Serve(l); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("%s: coap source server error: %v", errorx.IOErr, err)
+	}
+	return nil
+}
+
+// observe registers the observation of the resource and receives the notifications until the connection is broken
+func (s *coapSource) observe(ctx api.StreamContext, consumer chan<- api.SourceTuple) error {
+	logger := ctx.GetLogger()
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	obsCtx, cancel := context.WithTimeout(ctx, time.Duration(s.c.Timeout)*time.Millisecond)
+	defer cancel()
+	obs, err := conn.Observe(obsCtx, s.path, func(resp *pool.Message) {
+		if err := s.emit(ctx, resp, s.clientMeta(resp), consumer); err != nil {
+			logger.Errorf("coap source fails to process the notification of %s: %v", s.path, err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%s: coap source fails to observe %s: %v", errorx.IOErr, s.path, err)
+	}
+	logger.Infof("coap source observes %s of %s", s.path, s.c.Server)
+	select {
+	case <-ctx.Done():
+		cancelCtx, cancel := context.WithTimeout(context.Background(), time.Duration(s.c.Timeout)*time.Millisecond)
+		defer cancel()
+		if err := obs.Cancel(cancelCtx); err != nil {
+			logger.Warnf("coap source fails to cancel the observation: %v", err)
+		}
+		return nil
+	case <-conn.Done():
+		return fmt.Errorf("%s: coap source connection to %s is closed", errorx.IOErr, s.c.Server)
+	}
+}
+
+func (s *coapSource) poll(ctx api.StreamContext, consumer chan<- api.SourceTuple) error {
+	logger := ctx.GetLogger()
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	logger.Infof("coap source polls %s of %s every %d ms", s.path, s.c.Server, s.c.Interval)
+	ticker := time.NewTicker(time.Duration(s.c.Interval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		reqCtx, cancel := context.WithTimeout(ctx, time.Duration(s.c.Timeout)*time.Millisecond)
+		resp, err := conn.Get(reqCtx, s.path)
+		cancel()
+		if err != nil {
+			logger.Errorf("coap source fails to get %s: %v", s.path, err)
+		} else if !isSuccess(resp.Code()) {
+			logger.Errorf("coap source gets %s with response code %v", s.path, resp.Code())
+		} else if err := s.emit(ctx, resp, s.clientMeta(resp), consumer); err != nil {
+			logger.Errorf("coap source fails to process the response of %s: %v", s.path, err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-conn.Done():
+			return fmt.Errorf("%s: coap source connection to %s is closed", errorx.IOErr, s.c.Server)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *coapSource) dial() (*client.Conn, error) {
+	conn, err := udp.Dial(s.c.Server)
+	if err != nil {
+		return nil, fmt.Errorf("%s: coap source fails to connect %s: %v", errorx.IOErr, s.c.Server, err)
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+func (s *coapSource) clientMeta(resp *pool.Message) map[string]interface{} {
+	meta := map[string]interface{}{
+		"path": s.path,
+		"code": resp.Code().String(),
+	}
+	if seq, err := resp.Observe(); err == nil {
+		meta["observe"] = seq
+	}
+	return meta
+}
+
+// emit decodes the payload of the message and sends the tuples to the rule
+func (s *coapSource) emit(ctx api.StreamContext, msg *pool.Message, meta map[string]interface{}, consumer chan<- api.SourceTuple) error {
+	rcvTime := conf.GetNow()
+	payload, err := msg.ReadBody()
+	if err != nil {
+		return err
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+	if cf, err := msg.ContentFormat(); err == nil {
+		meta["contentFormat"] = cf.String()
+	}
+	results, err := ctx.DecodeIntoList(payload)
+	if err != nil {
+		return fmt.Errorf("invalid data format, cannot decode %s with error %s", string(payload), err)
+	}
+	for _, result := range results {
+		select {
+		case consumer <- api.NewDefaultSourceTupleWithTime(result, meta, rcvTime):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// isSuccess checks if the response code is in the 2.xx class
+func isSuccess(c codes.Code) bool {
+	return c>>5 == 2
+}
+
+func (s *coapSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing coap source")
+	if s.server != nil {
+		s.server.Stop()
+	}
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+func Coap() api.Source {
+	return &coapSource{}
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		path  string
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			path:  "sensor",
+			props: map[string]interface{}{},
+			err:   "coap source requires the resource path starting with / as the datasource",
+		}, {
+			path:  "/sensor",
+			props: map[string]interface{}{"server": ""},
+			err:   "server is required in client mode",
+		}, {
+			path:  "/sensor",
+			props: map[string]interface{}{"interval": 0},
+			err:   "interval must be positive",
+		}, {
+			path:  "/sensor",
+			props: map[string]interface{}{"mode": "server", "addr": ""},
+			err:   "addr is required in server mode",
+		}, {
+			path:  "/sensor",
+			props: map[string]interface{}{"mode": "proxy"},
+			err:   "invalid mode proxy, must be client or server",
+		}, {
+			path:  "/sensor",
+			props: map[string]interface{}{"timeout": -1},
+			err:   "timeout must be positive",
+		}, {
+			// interval is not used when observing
+			path:  "/sensor",
+			props: map[string]interface{}{"observe": true, "interval": 0},
+		}, {
+			path:  "/sensor",
+			props: map[string]interface{}{"mode": "server", "addr": ":5683"},
+		},
+	}
+	for i, tt := range tests {
+		err := Coap().Configure(tt.path, tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestIsSuccess(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		exp  bool
+	}{
+		{code: codes.Content, exp: true},
+		{code: codes.Changed, exp: true},
+		{code: codes.Created, exp: true},
+		{code: codes.NotFound, exp: false},
+		{code: codes.InternalServerError, exp: false},
+		{code: codes.GET, exp: false},
+	}
+	for i, tt := range tests {
+		if r := isSuccess(tt.code); r != tt.exp {
+			t.Errorf("%d: expect %v but got %v for %v", i, tt.exp, r, tt.code)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/cert"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+const (
+	SASL_NONE  = "none"
+	SASL_PLAIN = "plain"
+	SASL_SCRAM = "scram"
+
+	OFFSET_EARLIEST = "earliest"
+	OFFSET_LATEST   = "latest"
+)
+
+type sourceConf struct {
+	Brokers string `json:"brokers"`
+	GroupId string `json:"groupId"`
+	// StartOffset is where to start when the group has no committed offset of a partition
+	StartOffset string `json:"startOffset"`
+	// CommitInterval is the interval in milliseconds to commit the offsets to the broker. 0 means commit each message
+	CommitInterval int    `json:"commitInterval"`
+	MaxBytes       int    `json:"maxBytes"`
+	SaslAuthType   string `json:"saslAuthType"`
+	SaslUserName   string `json:"saslUserName"`
+	SaslPassword   string `json:"saslPassword"`
+	// Tls enables the TLS connection to the brokers
+	Tls                bool   `json:"tls"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	CertificationPath  string `json:"certificationPath"`
+	PrivateKeyPath     string `json:"privateKeyPath"`
+	RootCaPath         string `json:"rootCaPath"`
+}
+
+type kafkaSource struct {
+	topic   string
+	c       *sourceConf
+	brokers []string
+	dialer  *kafkago.Dialer
+	group   *kafkago.ConsumerGroup
+
+	mu sync.Mutex
+	// offsets are the next offset to read of each partition, they are saved as the state to rewind
+	offsets map[int]int64
+}
+
+func (s *kafkaSource) Configure(topic string, props map[string]interface{}) error {
+	c := &sourceConf{
+		Brokers:        "localhost:9092",
+		GroupId:        "ekuiper",
+		StartOffset:    OFFSET_LATEST,
+		CommitInterval: 1000,
+		MaxBytes:       1e6,
+		SaslAuthType:   SASL_NONE,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return err
+	}
+	if topic == "" {
+		return fmt.Errorf("kafka source requires the topic as the datasource")
+	}
+	brokers := make([]string, 0)
+	for _, b := range strings.Split(c.Brokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	if len(brokers) == 0 {
+		return fmt.Errorf("brokers can not be empty")
+	}
+	if c.GroupId == "" {
+		return fmt.Errorf("groupId can not be empty")
+	}
+	if c.StartOffset != OFFSET_EARLIEST && c.StartOffset != OFFSET_LATEST {
+		return fmt.Errorf("startOffset must be %s or %s", OFFSET_EARLIEST, OFFSET_LATEST)
+	}
+	if c.CommitInterval < 0 {
+		return fmt.Errorf("commitInterval can not be negative")
+	}
+	if c.MaxBytes <= 0 {
+		return fmt.Errorf("maxBytes must be positive")
+	}
+	if !(c.SaslAuthType == SASL_NONE || c.SaslAuthType == SASL_SCRAM || c.SaslAuthType == SASL_PLAIN) {
+		return fmt.Errorf("saslAuthType incorrect")
+	}
+	if (c.SaslAuthType == SASL_SCRAM || c.SaslAuthType == SASL_PLAIN) && (c.SaslUserName == "" || c.SaslPassword == "") {
+		return fmt.Errorf("username and password can not be empty")
+	}
+
+	var (
+		mechanism sasl.Mechanism
+		err       error
+	)
+	switch c.SaslAuthType {
+	case SASL_PLAIN:
+		mechanism = plain.Mechanism{
+			Username: c.SaslUserName,
+			Password: c.SaslPassword,
+		}
+	case SASL_SCRAM:
+		mechanism, err = scram.Mechanism(scram.SHA512, c.SaslUserName, c.SaslPassword)
+		if err != nil {
+			return err
+		}
+	}
+	dialer := &kafkago.Dialer{
+		Timeout:       10 * time.Second,
+		DualStack:     true,
+		SASLMechanism: mechanism,
+	}
+	if c.Tls {
+		dialer.TLS, err = cert.GenerateTLSForClient(cert.TlsConfigurationOptions{
+			SkipCertVerify: c.InsecureSkipVerify,
+			CertFile:       c.CertificationPath,
+			KeyFile:        c.PrivateKeyPath,
+			CaFile:         c.RootCaPath,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	s.topic = topic
+	s.c = c
+	s.brokers = brokers
+	s.dialer = dialer
+	return nil
+}
+
+func (s *kafkaSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	startOffset := kafkago.LastOffset
+	if s.c.StartOffset == OFFSET_EARLIEST {
+		startOffset = kafkago.FirstOffset
+	}
+	group, err := kafkago.NewConsumerGroup(kafkago.ConsumerGroupConfig{
+		ID:          s.c.GroupId,
+		Brokers:     s.brokers,
+		Topics:      []string{s.topic},
+		Dialer:      s.dialer,
+		StartOffset: startOffset,
+	})
+	if err != nil {
+		errCh <- fmt.Errorf("%s: kafka source fails to create consumer group %s: %v", errorx.IOErr, s.c.GroupId, err)
+		return
+	}
+	s.group = group
+	for {
+		// Next blocks until the group is rebalanced. The previous generation ends before the new one is returned
+		gen, err := group.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, kafkago.ErrGroupClosed) {
+				logger.Infof("kafka source done")
+				return
+			}
+			errCh <- fmt.Errorf("%s: kafka source fails to join consumer group %s: %v", errorx.IOErr, s.c.GroupId, err)
+			return
+		}
+		assignments := gen.Assignments[s.topic]
+		logger.Infof("kafka source joins generation %d of group %s with %d partitions assigned", gen.ID, s.c.GroupId, len(assignments))
+		for _, assignment := range assignments {
+			partition, offset := assignment.ID, assignment.Offset
+			// the rewound offset from the checkpoint has priority over the committed offset of the group
+			s.mu.Lock()
+			if o, ok := s.offsets[partition]; ok {
+				offset = o
+			}
+			s.mu.Unlock()
+			gen.Start(func(genCtx context.Context) {
+				s.consume(ctx, genCtx, gen, partition, offset, consumer, errCh)
+			})
+		}
+	}
+}
+
+// consume reads one partition until the generation ends
+func (s *kafkaSource) consume(ctx api.StreamContext, genCtx context.Context, gen *kafkago.Generation, partition int, offset int64, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:   s.brokers,
+		Topic:     s.topic,
+		Partition: partition,
+		Dialer:    s.dialer,
+		MaxBytes:  s.c.MaxBytes,
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(offset); err != nil {
+		errCh <- fmt.Errorf("%s: kafka source fails to seek partition %d to offset %d: %v", errorx.IOErr, partition, offset, err)
+		return
+	}
+	var (
+		next       int64 = -1
+		lastCommit       = time.Now()
+	)
+	commit := func() {
+		if next < 0 {
+			return
+		}
+		if err := gen.CommitOffsets(map[string]map[int]int64{s.topic: {partition: next}}); err != nil {
+			logger.Warnf("kafka source fails to commit offset %d of partition %d: %v", next, partition, err)
+		}
+		lastCommit = time.Now()
+	}
+	defer commit()
+	for {
+		msg, err := reader.ReadMessage(genCtx)
+		if err != nil {
+			if genCtx.Err() == nil {
+				errCh <- fmt.Errorf("%s: kafka source fails to read partition %d: %v", errorx.IOErr, partition, err)
+			}
+			return
+		}
+		rcvTime := conf.GetNow()
+		results, e := ctx.DecodeIntoList(msg.Value)
+		if e != nil {
+			logger.Errorf("Invalid data format, cannot decode %s with error %s", string(msg.Value), e)
+		} else {
+			meta := map[string]interface{}{
+				"topic":     msg.Topic,
+				"partition": msg.Partition,
+				"offset":    msg.Offset,
+				"key":       string(msg.Key),
+			}
+			for _, result := range results {
+				select {
+				case consumer <- api.NewDefaultSourceTupleWithTime(result, meta, rcvTime):
+				case <-genCtx.Done():
+					return
+				}
+			}
+		}
+		next = msg.Offset + 1
+		s.mu.Lock()
+		s.offsets[partition] = next
+		s.mu.Unlock()
+		if time.Since(lastCommit) >= time.Duration(s.c.CommitInterval)*time.Millisecond {
+			commit()
+		}
+	}
+}
+
+// GetOffset returns the next offsets of all the consumed partitions. The partition keys are strings to be saved in the state.
+func (s *kafkaSource) GetOffset() (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(map[string]interface{}, len(s.offsets))
+	for p, o := range s.offsets {
+		result[strconv.Itoa(p)] = o
+	}
+	return result, nil
+}
+
+func (s *kafkaSource) Rewind(offset interface{}) error {
+	m, ok := offset.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("kafka source rewind with invalid offset %v", offset)
+	}
+	offsets := make(map[int]int64, len(m))
+	for k, v := range m {
+		p, err := strconv.Atoi(k)
+		if err != nil {
+			return fmt.Errorf("kafka source rewind with invalid partition %s", k)
+		}
+		o, err := cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return fmt.Errorf("kafka source rewind with invalid offset %v of partition %d", v, p)
+		}
+		offsets[p] = o
+	}
+	s.mu.Lock()
+	s.offsets = offsets
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *kafkaSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing kafka source")
+	if s.group != nil {
+		return s.group.Close()
+	}
+	return nil
+}
+
+func Kafka() api.Source {
+	return &kafkaSource{offsets: make(map[int]int64)}
+}
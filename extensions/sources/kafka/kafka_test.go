@@ -0,0 +1,110 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		topic string
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			topic: "",
+			props: map[string]interface{}{},
+			err:   "kafka source requires the topic as the datasource",
+		}, {
+			topic: "test",
+			props: map[string]interface{}{"brokers": " , "},
+			err:   "brokers can not be empty",
+		}, {
+			topic: "test",
+			props: map[string]interface{}{"groupId": ""},
+			err:   "groupId can not be empty",
+		}, {
+			topic: "test",
+			props: map[string]interface{}{"startOffset": "middle"},
+			err:   "startOffset must be earliest or latest",
+		}, {
+			topic: "test",
+			props: map[string]interface{}{"saslAuthType": "kerberos"},
+			err:   "saslAuthType incorrect",
+		}, {
+			topic: "test",
+			props: map[string]interface{}{"saslAuthType": "plain", "saslUserName": "admin"},
+			err:   "username and password can not be empty",
+		}, {
+			topic: "test",
+			props: map[string]interface{}{"brokers": "127.0.0.1:9092,127.0.0.2:9092", "groupId": "g1", "startOffset": "earliest", "saslAuthType": "scram", "saslUserName": "admin", "saslPassword": "pwd"},
+		},
+	}
+	for i, tt := range tests {
+		s := Kafka()
+		err := s.Configure(tt.topic, tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+	if err := Kafka().Configure("test", map[string]interface{}{"tls": true, "rootCaPath": "not_exist.pem"}); err == nil {
+		t.Errorf("expect error for not existing root ca")
+	}
+	s := Kafka().(*kafkaSource)
+	if err := s.Configure("test", map[string]interface{}{"brokers": "127.0.0.1:9092, 127.0.0.2:9092"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string{"127.0.0.1:9092", "127.0.0.2:9092"}, s.brokers) {
+		t.Errorf("brokers mismatch, got %v", s.brokers)
+	}
+}
+
+func TestRewind(t *testing.T) {
+	s := Kafka().(*kafkaSource)
+	var r api.Rewindable = s
+	// the offset restored from the state may be decoded as other number types
+	err := r.Rewind(map[string]interface{}{"0": int64(10), "2": 25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(map[int]int64{0: 10, 2: 25}, s.offsets) {
+		t.Errorf("offsets mismatch, got %v", s.offsets)
+	}
+	s.offsets[1] = 3
+	offset, err := r.GetOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]interface{}{"0": int64(10), "1": int64(3), "2": int64(25)}
+	if !reflect.DeepEqual(exp, offset) {
+		t.Errorf("offset mismatch, got %v", offset)
+	}
+	if err := r.Rewind(map[string]interface{}{"a": 1}); err == nil || err.Error() != "kafka source rewind with invalid partition a" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := r.Rewind(int64(1)); err == nil || err.Error() != "kafka source rewind with invalid offset 1" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
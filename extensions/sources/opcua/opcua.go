@@ -0,0 +1,369 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+var (
+	securityPolicies = map[string]bool{
+		"None":                  true,
+		"Basic128Rsa15":         true,
+		"Basic256":              true,
+		"Basic256Sha256":        true,
+		"Aes128_Sha256_RsaOaep": true,
+		"Aes256_Sha256_RsaPss":  true,
+	}
+	securityModes = map[string]bool{
+		"None":           true,
+		"Sign":           true,
+		"SignAndEncrypt": true,
+	}
+)
+
+type sourceConf struct {
+	Url string `json:"url"`
+	// NodeIds are the nodes to monitor, the field name is the browse name of the node
+	NodeIds []string `json:"nodeIds"`
+	// BrowsePaths are the patterns of the browse names from the Objects folder such as Line1/*/Temperature,
+	// the field name is the matched path
+	BrowsePaths []string `json:"browsePaths"`
+	// Interval is the publishing interval of the subscription in milliseconds
+	Interval          int    `json:"interval"`
+	SecurityPolicy    string `json:"securityPolicy"`
+	SecurityMode      string `json:"securityMode"`
+	CertificationPath string `json:"certificationPath"`
+	PrivateKeyPath    string `json:"privateKeyPath"`
+	Username          string `json:"username"`
+	Password          string `json:"password"`
+}
+
+type monitoredItem struct {
+	name   string
+	nodeId *ua.NodeID
+}
+
+type opcuaSource struct {
+	c      *sourceConf
+	client *opcua.Client
+	items  []*monitoredItem
+}
+
+func (s *opcuaSource) Configure(_ string, props map[string]interface{}) error {
+	c := &sourceConf{
+		Url:            "opc.tcp://localhost:4840",
+		Interval:       1000,
+		SecurityPolicy: "None",
+		SecurityMode:   "None",
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if !strings.HasPrefix(c.Url, "opc.tcp://") {
+		return fmt.Errorf("url must start with opc.tcp://")
+	}
+	if len(c.NodeIds) == 0 && len(c.BrowsePaths) == 0 {
+		return fmt.Errorf("nodeIds and browsePaths can not be both empty")
+	}
+	for _, n := range c.NodeIds {
+		if _, err := ua.ParseNodeID(n); err != nil {
+			return fmt.Errorf("invalid node id %s: %v", n, err)
+		}
+	}
+	for _, p := range c.BrowsePaths {
+		for _, seg := range splitBrowsePath(p) {
+			if _, err := path.Match(seg, ""); err != nil {
+				return fmt.Errorf("invalid browse path %s: %v", p, err)
+			}
+		}
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if !securityPolicies[c.SecurityPolicy] {
+		return fmt.Errorf("securityPolicy must be one of None, Basic128Rsa15, Basic256, Basic256Sha256, Aes128_Sha256_RsaOaep and Aes256_Sha256_RsaPss")
+	}
+	if !securityModes[c.SecurityMode] {
+		return fmt.Errorf("securityMode must be one of None, Sign and SignAndEncrypt")
+	}
+	if (c.SecurityPolicy == "None") != (c.SecurityMode == "None") {
+		return fmt.Errorf("securityPolicy and securityMode must be both None or both not None")
+	}
+	if c.SecurityMode != "None" && (c.CertificationPath == "" || c.PrivateKeyPath == "") {
+		return fmt.Errorf("certificationPath and privateKeyPath are required for securityMode %s", c.SecurityMode)
+	}
+	s.c = c
+	return nil
+}
+
+func (s *opcuaSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	if err := s.connect(ctx); err != nil {
+		errCh <- fmt.Errorf("%s: opcua source fails to connect %s: %v", errorx.IOErr, s.c.Url, err)
+		return
+	}
+	if err := s.resolve(ctx); err != nil {
+		errCh <- fmt.Errorf("opcua source fails to resolve the nodes: %v", err)
+		return
+	}
+	notifyCh := make(chan *opcua.PublishNotificationData)
+	sub, err := s.client.SubscribeWithContext(ctx, &opcua.SubscriptionParameters{
+		Interval: time.Duration(s.c.Interval) * time.Millisecond,
+	}, notifyCh)
+	if err != nil {
+		errCh <- fmt.Errorf("%s: opcua source fails to subscribe: %v", errorx.IOErr, err)
+		return
+	}
+	defer sub.Cancel(ctx)
+	reqs := make([]*ua.MonitoredItemCreateRequest, len(s.items))
+	for i, item := range s.items {
+		// the index is used as the client handle to find the item of the notification
+		reqs[i] = opcua.NewMonitoredItemCreateRequestWithDefaults(item.nodeId, ua.AttributeIDValue, uint32(i))
+	}
+	res, err := sub.MonitorWithContext(ctx, ua.TimestampsToReturnBoth, reqs...)
+	if err != nil {
+		errCh <- fmt.Errorf("%s: opcua source fails to monitor the nodes: %v", errorx.IOErr, err)
+		return
+	}
+	for i, r := range res.Results {
+		if r.StatusCode != ua.StatusOK {
+			errCh <- fmt.Errorf("opcua source fails to monitor node %s: %v", s.items[i].nodeId, r.StatusCode)
+			return
+		}
+	}
+	logger.Infof("opcua source subscribes %d nodes of %s", len(s.items), s.c.Url)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infof("opcua source done")
+			return
+		case n := <-notifyCh:
+			if n.Error != nil {
+				logger.Warnf("opcua source receives error notification: %v", n.Error)
+				continue
+			}
+			dc, ok := n.Value.(*ua.DataChangeNotification)
+			if !ok {
+				continue
+			}
+			if tuple := s.toTuple(dc); tuple != nil {
+				select {
+				case consumer <- tuple:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *opcuaSource) connect(ctx api.StreamContext) error {
+	endpoints, err := opcua.GetEndpoints(ctx, s.c.Url)
+	if err != nil {
+		return err
+	}
+	ep := opcua.SelectEndpoint(endpoints, s.c.SecurityPolicy, ua.MessageSecurityModeFromString(s.c.SecurityMode))
+	if ep == nil {
+		return fmt.Errorf("no endpoint found for securityPolicy %s and securityMode %s", s.c.SecurityPolicy, s.c.SecurityMode)
+	}
+	opts := []opcua.Option{
+		opcua.SecurityPolicy(s.c.SecurityPolicy),
+		opcua.SecurityModeString(s.c.SecurityMode),
+	}
+	if s.c.CertificationPath != "" {
+		opts = append(opts, opcua.CertificateFile(s.c.CertificationPath), opcua.PrivateKeyFile(s.c.PrivateKeyPath))
+	}
+	if s.c.Username != "" {
+		opts = append(opts, opcua.AuthUsername(s.c.Username, s.c.Password), opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeUserName))
+	} else {
+		opts = append(opts, opcua.AuthAnonymous(), opcua.SecurityFromEndpoint(ep, ua.UserTokenTypeAnonymous))
+	}
+	s.client = opcua.NewClient(ep.EndpointURL, opts...)
+	return s.client.Connect(ctx)
+}
+
+// resolve finds the nodes to monitor by the node ids and browse paths
+func (s *opcuaSource) resolve(ctx api.StreamContext) error {
+	items := make([]*monitoredItem, 0, len(s.c.NodeIds))
+	names := make(map[string]bool)
+	add := func(name string, nodeId *ua.NodeID) error {
+		if names[name] {
+			return fmt.Errorf("duplicate field name %s of node %s", name, nodeId)
+		}
+		names[name] = true
+		items = append(items, &monitoredItem{name: name, nodeId: nodeId})
+		return nil
+	}
+	for _, n := range s.c.NodeIds {
+		nodeId, _ := ua.ParseNodeID(n)
+		bn, err := s.client.Node(nodeId).BrowseNameWithContext(ctx)
+		if err != nil {
+			return fmt.Errorf("read browse name of node %s error: %v", n, err)
+		}
+		if err := add(bn.Name, nodeId); err != nil {
+			return err
+		}
+	}
+	root := s.client.Node(ua.NewNumericNodeID(0, id.ObjectsFolder))
+	for _, p := range s.c.BrowsePaths {
+		matched, err := s.browse(ctx, root, "", splitBrowsePath(p))
+		if err != nil {
+			return err
+		}
+		if len(matched) == 0 {
+			return fmt.Errorf("no variable matches browse path %s", p)
+		}
+		for _, item := range matched {
+			if err := add(item.name, item.nodeId); err != nil {
+				return err
+			}
+		}
+	}
+	s.items = items
+	return nil
+}
+
+// browse matches the hierarchical children of the node by the segments recursively and returns the matched variables
+func (s *opcuaSource) browse(ctx api.StreamContext, node *opcua.Node, prefix string, segments []string) ([]*monitoredItem, error) {
+	children, err := node.ChildrenWithContext(ctx, id.HierarchicalReferences, ua.NodeClassObject|ua.NodeClassVariable)
+	if err != nil {
+		return nil, fmt.Errorf("browse node %s error: %v", node.ID, err)
+	}
+	var result []*monitoredItem
+	for _, child := range children {
+		bn, err := child.BrowseNameWithContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("read browse name of node %s error: %v", child.ID, err)
+		}
+		if ok, _ := path.Match(segments[0], bn.Name); !ok {
+			continue
+		}
+		name := bn.Name
+		if prefix != "" {
+			name = prefix + "/" + bn.Name
+		}
+		if len(segments) > 1 {
+			r, err := s.browse(ctx, child, name, segments[1:])
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, r...)
+			continue
+		}
+		nc, err := child.NodeClassWithContext(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("read node class of node %s error: %v", child.ID, err)
+		}
+		if nc == ua.NodeClassVariable {
+			result = append(result, &monitoredItem{name: name, nodeId: child.ID})
+		}
+	}
+	return result, nil
+}
+
+// toTuple converts the changed values to a tuple. The timestamps and status of each field are in the meta keyed by the field name
+func (s *opcuaSource) toTuple(dc *ua.DataChangeNotification) api.SourceTuple {
+	message := make(map[string]interface{}, len(dc.MonitoredItems))
+	meta := make(map[string]interface{}, len(dc.MonitoredItems))
+	for _, mi := range dc.MonitoredItems {
+		if int(mi.ClientHandle) >= len(s.items) || mi.Value == nil {
+			continue
+		}
+		item := s.items[mi.ClientHandle]
+		if mi.Value.Value != nil {
+			message[item.name] = convertValue(mi.Value.Value.Value())
+		} else {
+			message[item.name] = nil
+		}
+		meta[item.name] = map[string]interface{}{
+			"nodeId":          item.nodeId.String(),
+			"sourceTimestamp": cast.TimeToUnixMilli(mi.Value.SourceTimestamp),
+			"serverTimestamp": cast.TimeToUnixMilli(mi.Value.ServerTimestamp),
+			"status":          uint32(mi.Value.Status),
+		}
+	}
+	if len(message) == 0 {
+		return nil
+	}
+	return api.NewDefaultSourceTupleWithTime(message, meta, conf.GetNow())
+}
+
+// convertValue converts the OPC UA built-in types to the types supported by the rule engine
+func convertValue(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case int8:
+		return int64(vt)
+	case int16:
+		return int64(vt)
+	case int32:
+		return int64(vt)
+	case uint8:
+		return int64(vt)
+	case uint16:
+		return int64(vt)
+	case uint32:
+		return int64(vt)
+	case float32:
+		return float64(vt)
+	case *ua.LocalizedText:
+		return vt.Text
+	case *ua.QualifiedName:
+		return vt.Name
+	case *ua.NodeID:
+		return vt.String()
+	case []byte:
+		return vt
+	default:
+		// arrays of the built-in types are converted element by element
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+			result := make([]interface{}, rv.Len())
+			for i := range result {
+				result[i] = convertValue(rv.Index(i).Interface())
+			}
+			return result
+		}
+		return v
+	}
+}
+
+func splitBrowsePath(p string) []string {
+	return strings.Split(strings.Trim(p, "/"), "/")
+}
+
+func (s *opcuaSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing opcua source")
+	if s.client != nil {
+		return s.client.CloseWithContext(ctx)
+	}
+	return nil
+}
+
+func Opcua() api.Source {
+	return &opcuaSource{}
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"url": "tcp://localhost:4840", "nodeIds": []string{"ns=2;s=Temperature"}},
+			err:   "url must start with opc.tcp://",
+		}, {
+			props: map[string]interface{}{},
+			err:   "nodeIds and browsePaths can not be both empty",
+		}, {
+			props: map[string]interface{}{"browsePaths": []string{"Line1/[/Temperature"}},
+			err:   "invalid browse path Line1/[/Temperature: syntax error in pattern",
+		}, {
+			props: map[string]interface{}{"nodeIds": []string{"ns=2;s=Temperature"}, "interval": 0},
+			err:   "interval must be positive",
+		}, {
+			props: map[string]interface{}{"nodeIds": []string{"ns=2;s=Temperature"}, "securityPolicy": "Basic512"},
+			err:   "securityPolicy must be one of None, Basic128Rsa15, Basic256, Basic256Sha256, Aes128_Sha256_RsaOaep and Aes256_Sha256_RsaPss",
+		}, {
+			props: map[string]interface{}{"nodeIds": []string{"ns=2;s=Temperature"}, "securityMode": "Encrypt"},
+			err:   "securityMode must be one of None, Sign and SignAndEncrypt",
+		}, {
+			props: map[string]interface{}{"nodeIds": []string{"ns=2;s=Temperature"}, "securityPolicy": "Basic256Sha256"},
+			err:   "securityPolicy and securityMode must be both None or both not None",
+		}, {
+			props: map[string]interface{}{"nodeIds": []string{"ns=2;s=Temperature"}, "securityPolicy": "Basic256Sha256", "securityMode": "Sign"},
+			err:   "certificationPath and privateKeyPath are required for securityMode Sign",
+		}, {
+			props: map[string]interface{}{
+				"url":               "opc.tcp://127.0.0.1:4840",
+				"nodeIds":           []string{"ns=2;s=Temperature", "i=2258"},
+				"browsePaths":       []string{"/Line1/*/Temperature"},
+				"interval":          500,
+				"securityPolicy":    "Basic256Sha256",
+				"securityMode":      "SignAndEncrypt",
+				"certificationPath": "cert.pem",
+				"privateKeyPath":    "key.pem",
+			},
+		},
+	}
+	for i, tt := range tests {
+		err := Opcua().Configure("", tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestToTuple(t *testing.T) {
+	ts := time.UnixMilli(1680000000000)
+	s := &opcuaSource{items: []*monitoredItem{
+		{name: "Temperature", nodeId: ua.NewStringNodeID(2, "Temperature")},
+		{name: "Line1/Motor1/Speeds", nodeId: ua.NewNumericNodeID(2, 1001)},
+	}}
+	dc := &ua.DataChangeNotification{MonitoredItems: []*ua.MonitoredItemNotification{
+		{
+			ClientHandle: 0,
+			Value:        &ua.DataValue{Value: ua.MustVariant(float32(21.5)), SourceTimestamp: ts, ServerTimestamp: ts.Add(time.Second)},
+		}, {
+			ClientHandle: 1,
+			Value:        &ua.DataValue{Value: ua.MustVariant([]int32{1, 2}), SourceTimestamp: ts, ServerTimestamp: ts},
+		}, {
+			// unknown handle is ignored
+			ClientHandle: 5,
+			Value:        &ua.DataValue{Value: ua.MustVariant(true)},
+		},
+	}}
+	tuple := s.toTuple(dc)
+	expMsg := map[string]interface{}{
+		"Temperature":         float64(21.5),
+		"Line1/Motor1/Speeds": []interface{}{int64(1), int64(2)},
+	}
+	if !reflect.DeepEqual(expMsg, tuple.Message()) {
+		t.Errorf("message mismatch, got %v", tuple.Message())
+	}
+	expMeta := map[string]interface{}{
+		"Temperature": map[string]interface{}{
+			"nodeId":          "ns=2;s=Temperature",
+			"sourceTimestamp": int64(1680000000000),
+			"serverTimestamp": int64(1680000001000),
+			"status":          uint32(0),
+		},
+		"Line1/Motor1/Speeds": map[string]interface{}{
+			"nodeId":          "ns=2;i=1001",
+			"sourceTimestamp": int64(1680000000000),
+			"serverTimestamp": int64(1680000000000),
+			"status":          uint32(0),
+		},
+	}
+	if !reflect.DeepEqual(expMeta, tuple.Meta()) {
+		t.Errorf("meta mismatch, got %v", tuple.Meta())
+	}
+	if s.toTuple(&ua.DataChangeNotification{}) != nil {
+		t.Errorf("expect nil tuple for empty notification")
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+
+	"github.com/lf-edge/ekuiper/extensions/snmp"
+	"github.com/lf-edge/ekuiper/extensions/snmp/mib"
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+const defaultPort = "161"
+
+type sourceConf struct {
+	// Address is the address of the agent, the port defaults to 161
+	Address string `json:"address"`
+	// Interval is the polling interval in milliseconds
+	Interval int `json:"interval"`
+	// Oids are the objects to get in each polling, the object names in the MIB or numeric OIDs
+	Oids []string `json:"oids"`
+	// Walk is the subtree such as a table to walk in each polling, each row is sent as a tuple
+	Walk string `json:"walk"`
+}
+
+type snmpSource struct {
+	c      *sourceConf
+	client *gosnmp.GoSNMP
+	mibs   *mib.Registry
+	oids   []string
+	walk   string
+}
+
+func (s *snmpSource) Configure(_ string, props map[string]interface{}) error {
+	c := &sourceConf{
+		Interval: 10000,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	pc := &snmp.Conf{
+		Version:   "2c",
+		Community: "public",
+		Timeout:   3000,
+		Retries:   1,
+	}
+	if err := cast.MapToStruct(props, pc); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	host, port, err := splitAddress(c.Address)
+	if err != nil {
+		return err
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if (len(c.Oids) == 0) == (c.Walk == "") {
+		return fmt.Errorf("either oids or walk must be set")
+	}
+	client := &gosnmp.GoSNMP{
+		Target:         host,
+		Port:           port,
+		Transport:      "udp",
+		MaxOids:        gosnmp.MaxOids,
+		MaxRepetitions: 10,
+	}
+	if err := pc.Apply(client); err != nil {
+		return err
+	}
+	mibs, err := pc.LoadMibs()
+	if err != nil {
+		return err
+	}
+	oids := make([]string, len(c.Oids))
+	for i, o := range c.Oids {
+		oid, err := mibs.Resolve(o)
+		if err != nil {
+			return fmt.Errorf("invalid oid %s: %v", o, err)
+		}
+		oids[i] = "." + oid
+	}
+	if c.Walk != "" {
+		oid, err := mibs.Resolve(c.Walk)
+		if err != nil {
+			return fmt.Errorf("invalid walk %s: %v", c.Walk, err)
+		}
+		s.walk = "." + oid
+	}
+	s.c = c
+	s.client = client
+	s.mibs = mibs
+	s.oids = oids
+	return nil
+}
+
+func (s *snmpSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	s.client.Context = ctx
+	if err := s.client.Connect(); err != nil {
+		errCh <- fmt.Errorf("%s: snmp source fails to connect %s: %v", errorx.IOErr, s.c.Address, err)
+		return
+	}
+	logger.Infof("snmp source polls %s every %d ms", s.c.Address, s.c.Interval)
+	ticker := time.NewTicker(time.Duration(s.c.Interval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		rcvTime := conf.GetNow()
+		results, err := s.poll()
+		if err != nil {
+			logger.Errorf("snmp source fails to poll %s: %v", s.c.Address, err)
+		}
+		meta := map[string]interface{}{
+			"address": s.c.Address,
+		}
+		for _, result := range results {
+			select {
+			case consumer <- api.NewDefaultSourceTupleWithTime(result, meta, rcvTime):
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll gets the objects as one tuple, or walks the subtree and groups the objects of each row as a tuple
+func (s *snmpSource) poll() ([]map[string]interface{}, error) {
+	if s.walk == "" {
+		var vars []gosnmp.SnmpPDU
+		// the agent limits the number of objects of a request
+		for i := 0; i < len(s.oids); i += s.client.MaxOids {
+			end := i + s.client.MaxOids
+			if end > len(s.oids) {
+				end = len(s.oids)
+			}
+			packet, err := s.client.Get(s.oids[i:end])
+			if err != nil {
+				return nil, err
+			}
+			if packet.Error != gosnmp.NoError {
+				return nil, fmt.Errorf("agent responses error %v at index %d", packet.Error, packet.ErrorIndex)
+			}
+			vars = append(vars, packet.Variables...)
+		}
+		return []map[string]interface{}{snmp.ToMap(vars, s.mibs)}, nil
+	}
+	var (
+		vars []gosnmp.SnmpPDU
+		err  error
+	)
+	if s.client.Version == gosnmp.Version1 {
+		vars, err = s.client.WalkAll(s.walk)
+	} else {
+		vars, err = s.client.BulkWalkAll(s.walk)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rows(vars, s.mibs), nil
+}
+
+// rows groups the walked objects by the instance index. Each row has an index field and the fields of the columns
+func rows(vars []gosnmp.SnmpPDU, mibs *mib.Registry) []map[string]interface{} {
+	var (
+		results []map[string]interface{}
+		indexes = make(map[string]map[string]interface{})
+	)
+	for _, v := range vars {
+		name, index := mibs.Lookup(v.Name)
+		val, ok := snmp.Value(v, mibs)
+		if !ok {
+			continue
+		}
+		row, ok := indexes[index]
+		if !ok {
+			row = map[string]interface{}{"index": index}
+			indexes[index] = row
+			results = append(results, row)
+		}
+		row[name] = val
+	}
+	return results
+}
+
+func splitAddress(address string) (string, uint16, error) {
+	if address == "" {
+		return "", 0, fmt.Errorf("address is required")
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, defaultPort
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %s", port)
+	}
+	return host, uint16(p), nil
+}
+
+func (s *snmpSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing snmp source")
+	if s.client != nil && s.client.Conn != nil {
+		return s.client.Conn.Close()
+	}
+	return nil
+}
+
+func Snmp() api.Source {
+	return &snmpSource{}
+}
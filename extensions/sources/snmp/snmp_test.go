@@ -0,0 +1,99 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+
+	"github.com/lf-edge/ekuiper/extensions/snmp/mib"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"oids": []string{"sysUpTime.0"}},
+			err:   "address is required",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1:abc", "oids": []string{"sysUpTime.0"}},
+			err:   "invalid port abc",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "oids": []string{"sysUpTime.0"}, "interval": 0},
+			err:   "interval must be positive",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1"},
+			err:   "either oids or walk must be set",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "oids": []string{"sysUpTime.0"}, "walk": "ifTable"},
+			err:   "either oids or walk must be set",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "oids": []string{"sysUpTime.0"}, "version": "2"},
+			err:   "invalid version 2, must be 1, 2c or 3",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "oids": []string{"sysUpTime.0"}, "version": "3"},
+			err:   "userName is required for version 3",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "oids": []string{"sysUpTime.0"}, "version": "3", "userName": "admin", "securityLevel": "authPriv", "authProtocol": "SHA256", "authPassphrase": "password"},
+			err:   "invalid privProtocol ",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "oids": []string{"sysUpTime.0", "acmeTemperature.0"}},
+			err:   "invalid oid acmeTemperature.0: unknown object acmeTemperature",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "walk": "ifTable", "mibPaths": []string{"/not/exist"}},
+			err:   "load mib /not/exist error: stat /not/exist: no such file or directory",
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1:1161", "oids": []string{"sysUpTime.0", "1.3.6.1.2.1.1.5.0"}},
+		}, {
+			props: map[string]interface{}{"address": "127.0.0.1", "walk": "ifTable", "version": "3", "userName": "admin", "securityLevel": "authPriv", "authProtocol": "sha", "authPassphrase": "password", "privProtocol": "aes", "privPassphrase": "password"},
+		},
+	}
+	for i, tt := range tests {
+		err := Snmp().Configure("", tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestRows(t *testing.T) {
+	vars := []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.2.2.1.2.1", Type: gosnmp.OctetString, Value: []byte("lo")},
+		{Name: ".1.3.6.1.2.1.2.2.1.2.2", Type: gosnmp.OctetString, Value: []byte("eth0")},
+		{Name: ".1.3.6.1.2.1.2.2.1.6.1", Type: gosnmp.OctetString, Value: []byte{}},
+		{Name: ".1.3.6.1.2.1.2.2.1.6.2", Type: gosnmp.OctetString, Value: []byte{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}},
+		{Name: ".1.3.6.1.2.1.2.2.1.10.1", Type: gosnmp.Counter32, Value: uint(100)},
+		{Name: ".1.3.6.1.2.1.2.2.1.10.2", Type: gosnmp.Counter32, Value: uint(200)},
+		{Name: ".1.3.6.1.2.1.2.2.1.11.2", Type: gosnmp.NoSuchInstance},
+	}
+	exp := []map[string]interface{}{
+		{"index": "1", "ifDescr": "lo", "ifPhysAddress": "", "ifInOctets": int64(100)},
+		{"index": "2", "ifDescr": "eth0", "ifPhysAddress": "00:1a:2b:3c:4d:5e", "ifInOctets": int64(200)},
+	}
+	result := rows(vars, mib.New())
+	if !reflect.DeepEqual(result, exp) {
+		t.Errorf("expect %v but got %v", exp, result)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/gosnmp/gosnmp"
+
+	"github.com/lf-edge/ekuiper/extensions/snmp"
+	"github.com/lf-edge/ekuiper/extensions/snmp/mib"
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+const (
+	// the OIDs of the varbinds defined in SNMPv2-MIB
+	sysUpTimeOid   = "1.3.6.1.2.1.1.3.0"
+	trapOid        = "1.3.6.1.6.3.1.1.4.1.0"
+	snmpTrapsOid   = "1.3.6.1.6.3.1.1.5"
+	enterpriseTrap = 6
+)
+
+type sourceConf struct {
+	// Addr is the listening address of the traps
+	Addr string `json:"addr"`
+	// Community filters the traps of SNMPv1 and SNMPv2c, empty means accepting any community
+	Community string `json:"community"`
+}
+
+type trapSource struct {
+	c        *sourceConf
+	params   *gosnmp.GoSNMP
+	mibs     *mib.Registry
+	listener *gosnmp.TrapListener
+	// the listener blocks if it is closed more than once
+	closeOnce sync.Once
+}
+
+func (s *trapSource) Configure(_ string, props map[string]interface{}) error {
+	c := &sourceConf{
+		Addr: ":162",
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	// the community is used to filter the traps and does not take effect in the parameters
+	pc := &snmp.Conf{
+		Version: "2c",
+		Timeout: 3000,
+	}
+	if err := cast.MapToStruct(props, pc); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Addr == "" {
+		return fmt.Errorf("addr is required")
+	}
+	params := &gosnmp.GoSNMP{}
+	if err := pc.Apply(params); err != nil {
+		return err
+	}
+	mibs, err := pc.LoadMibs()
+	if err != nil {
+		return err
+	}
+	s.c = c
+	s.params = params
+	s.mibs = mibs
+	return nil
+}
+
+func (s *trapSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	tl := gosnmp.NewTrapListener()
+	tl.Params = s.params
+	tl.OnNewTrap = func(packet *gosnmp.SnmpPacket, addr *net.UDPAddr) {
+		if packet.Version != gosnmp.Version3 && s.c.Community != "" && packet.Community != s.c.Community {
+			logger.Warnf("snmp trap source drops the trap from %s with community %s", addr, packet.Community)
+			return
+		}
+		rcvTime := conf.GetNow()
+		result, meta := s.convert(packet)
+		meta["sourceAddr"] = addr.IP.String()
+		select {
+		case consumer <- api.NewDefaultSourceTupleWithTime(result, meta, rcvTime):
+		case <-ctx.Done():
+		}
+	}
+	s.listener = tl
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// the listener can only be closed after it starts listening
+		select {
+		case <-tl.Listening():
+		case <-done:
+			return
+		}
+		select {
+		case <-ctx.Done():
+			s.stop()
+		case <-done:
+		}
+	}()
+	logger.Infof("snmp trap source listens on %s", s.c.Addr)
+	if err := tl.Listen(s.c.Addr); err != nil && ctx.Err() == nil {
+		errCh <- fmt.Errorf("%s: snmp trap source fails to listen %s: %v", errorx.IOErr, s.c.Addr, err)
+	}
+}
+
+func (s *trapSource) stop() {
+	s.closeOnce.Do(func() {
+		s.listener.Close()
+	})
+}
+
+// convert translates the varbinds of the trap into the tuple. The trap OID and uptime of the SNMPv2 trap, or
+// the translated trap OID of the SNMPv1 trap by RFC 3584 are set in the meta
+func (s *trapSource) convert(packet *gosnmp.SnmpPacket) (map[string]interface{}, map[string]interface{}) {
+	meta := map[string]interface{}{
+		"version": packet.Version.String(),
+	}
+	if packet.Version != gosnmp.Version3 {
+		meta["community"] = packet.Community
+	}
+	vars := packet.Variables
+	if len(vars) == 0 {
+		vars = packet.SnmpTrap.Variables
+	}
+	if packet.Version == gosnmp.Version1 {
+		meta["trapOid"] = s.mibs.Name(v1TrapOid(packet.Enterprise, packet.GenericTrap, packet.SpecificTrap))
+		meta["uptime"] = int64(packet.Timestamp)
+		meta["agentAddr"] = packet.AgentAddress
+	}
+	others := make([]gosnmp.SnmpPDU, 0, len(vars))
+	for _, v := range vars {
+		switch trimDot(v.Name) {
+		case sysUpTimeOid:
+			if val, ok := snmp.Value(v, s.mibs); ok {
+				meta["uptime"] = val
+			}
+		case trapOid:
+			if val, ok := snmp.Value(v, s.mibs); ok {
+				meta["trapOid"] = val
+			}
+		default:
+			others = append(others, v)
+		}
+	}
+	return snmp.ToMap(others, s.mibs), meta
+}
+
+// v1TrapOid translates the SNMPv1 trap into the SNMPv2 trap OID by RFC 3584 section 3.1
+func v1TrapOid(enterprise string, generic, specific int) string {
+	if generic != enterpriseTrap {
+		return snmpTrapsOid + "." + strconv.Itoa(generic+1)
+	}
+	return trimDot(enterprise) + ".0." + strconv.Itoa(specific)
+}
+
+func trimDot(oid string) string {
+	if len(oid) > 0 && oid[0] == '.' {
+		return oid[1:]
+	}
+	return oid
+}
+
+func (s *trapSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing snmp trap source")
+	if s.listener != nil {
+		s.stop()
+	}
+	return nil
+}
+
+func SnmpTrap() api.Source {
+	return &trapSource{}
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+
+	"github.com/lf-edge/ekuiper/extensions/snmp/mib"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"addr": ""},
+			err:   "addr is required",
+		}, {
+			props: map[string]interface{}{"version": "3", "userName": "admin", "securityLevel": "auth"},
+			err:   "invalid securityLevel auth, must be noAuthNoPriv, authNoPriv or authPriv",
+		}, {
+			props: map[string]interface{}{"version": "3", "userName": "admin", "securityLevel": "authNoPriv", "authProtocol": "SHA"},
+			err:   "authPassphrase is required for securityLevel authNoPriv",
+		}, {
+			props: map[string]interface{}{"addr": ":1162", "community": "public"},
+		}, {
+			props: map[string]interface{}{"version": "3", "userName": "admin", "securityLevel": "noAuthNoPriv"},
+		},
+	}
+	for i, tt := range tests {
+		err := SnmpTrap().Configure("", tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	s := &trapSource{mibs: mib.New()}
+	tests := []struct {
+		packet *gosnmp.SnmpPacket
+		result map[string]interface{}
+		meta   map[string]interface{}
+	}{
+		{
+			packet: &gosnmp.SnmpPacket{
+				Version:   gosnmp.Version2c,
+				Community: "public",
+				Variables: []gosnmp.SnmpPDU{
+					{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(12345)},
+					{Name: ".1.3.6.1.6.3.1.1.4.1.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.6.3.1.1.5.3"},
+					{Name: ".1.3.6.1.2.1.2.2.1.1.2", Type: gosnmp.Integer, Value: 2},
+					{Name: ".1.3.6.1.2.1.2.2.1.7.2", Type: gosnmp.Integer, Value: 1},
+					{Name: ".1.3.6.1.2.1.2.2.1.8.2", Type: gosnmp.Integer, Value: 2},
+				},
+			},
+			result: map[string]interface{}{"ifIndex": int64(2), "ifAdminStatus": int64(1), "ifOperStatus": int64(2)},
+			meta:   map[string]interface{}{"version": "2c", "community": "public", "uptime": int64(12345), "trapOid": "linkDown"},
+		}, {
+			packet: &gosnmp.SnmpPacket{
+				Version:   gosnmp.Version1,
+				Community: "public",
+				SnmpTrap: gosnmp.SnmpTrap{
+					Variables: []gosnmp.SnmpPDU{
+						{Name: ".1.3.6.1.4.1.99999.1.1.1.0", Type: gosnmp.Integer, Value: 85},
+					},
+					Enterprise:   ".1.3.6.1.4.1.99999",
+					AgentAddress: "192.168.1.10",
+					GenericTrap:  6,
+					SpecificTrap: 1,
+					Timestamp:    100,
+				},
+			},
+			result: map[string]interface{}{"enterprises.99999.1.1.1.0": int64(85)},
+			meta:   map[string]interface{}{"version": "1", "community": "public", "uptime": int64(100), "trapOid": "enterprises.99999.0.1", "agentAddr": "192.168.1.10"},
+		},
+	}
+	for i, tt := range tests {
+		result, meta := s.convert(tt.packet)
+		if !reflect.DeepEqual(result, tt.result) {
+			t.Errorf("%d: expect result %v but got %v", i, tt.result, result)
+		}
+		if !reflect.DeepEqual(meta, tt.meta) {
+			t.Errorf("%d: expect meta %v but got %v", i, tt.meta, meta)
+		}
+	}
+}
+
+func TestV1TrapOid(t *testing.T) {
+	tests := []struct {
+		enterprise string
+		generic    int
+		specific   int
+		oid        string
+	}{
+		{enterprise: ".1.3.6.1.4.1.99999", generic: 0, oid: "1.3.6.1.6.3.1.1.5.1"},
+		{enterprise: ".1.3.6.1.4.1.99999", generic: 2, oid: "1.3.6.1.6.3.1.1.5.3"},
+		{enterprise: ".1.3.6.1.4.1.99999", generic: 6, specific: 17, oid: "1.3.6.1.4.1.99999.0.17"},
+	}
+	for _, tt := range tests {
+		if oid := v1TrapOid(tt.enterprise, tt.generic, tt.specific); oid != tt.oid {
+			t.Errorf("expect %s but got %s", tt.oid, oid)
+		}
+	}
+}
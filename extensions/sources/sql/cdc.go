@@ -0,0 +1,162 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+const (
+	cdcInsert = "insert"
+	cdcUpdate = "update"
+	cdcDelete = "delete"
+
+	defaultCdcBatchSize = 1000
+)
+
+// cdcConf configures the change data capture mode which reads the changes of the tables from the MySQL binlog or
+// the PostgreSQL logical replication slot instead of polling the query
+type cdcConf struct {
+	// Tables filters the changes by the tables in the format of schema.table or table
+	Tables []string `json:"tables"`
+	// Position is the position to start when there is no saved position. It is binlog file:position for MySQL
+	// such as mysql-bin.000001:4 and LSN for PostgreSQL. It starts from the latest position if not set
+	Position string `json:"position"`
+	// ServerId is the unique server id of the MySQL replica, it is random if not set
+	ServerId uint32 `json:"serverId"`
+	// Slot is the logical replication slot of PostgreSQL with wal2json plugin, it is created if not exists
+	Slot string `json:"slot"`
+	// BatchSize is the max number of changes to read from the slot of PostgreSQL in one batch
+	BatchSize int `json:"batchSize"`
+}
+
+// cdcChange is a row change of a table
+type cdcChange struct {
+	op     string
+	schema string
+	table  string
+	before map[string]interface{}
+	after  map[string]interface{}
+}
+
+// message is the row after the change, or the deleted row
+func (c *cdcChange) message() map[string]interface{} {
+	if c.op == cdcDelete {
+		return c.before
+	}
+	return c.after
+}
+
+func (c *cdcChange) meta() map[string]interface{} {
+	meta := map[string]interface{}{
+		"op":     c.op,
+		"schema": c.schema,
+		"table":  c.table,
+	}
+	if c.op == cdcUpdate && c.before != nil {
+		meta["before"] = c.before
+	}
+	return meta
+}
+
+// cdcReader reads the changes of the database. It calls emit for each change and commit with the position after each
+// transaction, so the reading can resume from the committed position
+type cdcReader interface {
+	read(ctx api.StreamContext, position string, emit func(c *cdcChange) bool, commit func(position string)) error
+	close() error
+}
+
+// cdcReaderFactory creates the reader, the interval is the polling interval for the database without streaming
+type cdcReaderFactory func(url string, db *sql.DB, c *cdcConf, interval time.Duration) (cdcReader, error)
+
+// cdcReaders are the cdc readers by the driver name, the reader of MySQL is only available with the mysql driver
+var cdcReaders = map[string]cdcReaderFactory{}
+
+func newCdcReader(driver string, url string, db *sql.DB, c *cdcConf, interval time.Duration) (cdcReader, error) {
+	f, ok := cdcReaders[driver]
+	if !ok {
+		return nil, fmt.Errorf("cdc is not supported for driver %s", driver)
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultCdcBatchSize
+	}
+	return f(url, db, c, interval)
+}
+
+// tableFilter matches the tables in the format of schema.table or table
+type tableFilter map[string]struct{}
+
+func newTableFilter(tables []string) tableFilter {
+	if len(tables) == 0 {
+		return nil
+	}
+	f := make(tableFilter, len(tables))
+	for _, t := range tables {
+		f[t] = struct{}{}
+	}
+	return f
+}
+
+func (f tableFilter) match(schema, table string) bool {
+	if f == nil {
+		return true
+	}
+	if _, ok := f[table]; ok {
+		return true
+	}
+	_, ok := f[schema+"."+table]
+	return ok
+}
+
+// cdcPosition keeps the committed position which is the offset of the source
+type cdcPosition struct {
+	mu  sync.RWMutex
+	pos string
+}
+
+func (p *cdcPosition) get() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.pos
+}
+
+func (p *cdcPosition) set(pos string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pos = pos
+}
+
+func (m *sqlsource) readCdc(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	logger.Infof("sql source reads the changes from position %s", m.position.get())
+	err := m.cdc.read(ctx, m.position.get(), func(c *cdcChange) bool {
+		select {
+		case consumer <- api.NewDefaultSourceTupleWithTime(c.message(), c.meta(), conf.GetNow()):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}, m.position.set)
+	if err != nil && ctx.Err() == nil {
+		logger.Errorf("read the changes error %v", err)
+		errCh <- err
+	}
+}
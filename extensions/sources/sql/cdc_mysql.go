@@ -0,0 +1,267 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build (!no_base || mysql) && !no_mysql
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func init() {
+	cdcReaders["mysql"] = newMysqlReader
+}
+
+// mysqlReader reads the row events from the binlog as a replica. The binlog format must be ROW
+type mysqlReader struct {
+	db     *sql.DB
+	cfg    replication.BinlogSyncerConfig
+	filter tableFilter
+	// columns caches the column names of the tables when the binlog has no metadata of the columns
+	columns map[string][]string
+}
+
+func newMysqlReader(u string, db *sql.DB, c *cdcConf, _ time.Duration) (cdcReader, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %v", u, err)
+	}
+	port := uint16(3306)
+	if p := pu.Port(); p != "" {
+		pp, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %s", p)
+		}
+		port = uint16(pp)
+	}
+	password, _ := pu.User.Password()
+	serverId := c.ServerId
+	if serverId == 0 {
+		// the server id must be unique among the replicas
+		serverId = uint32(rand.Int31n(1<<30)) + 1000
+	}
+	if c.Position != "" {
+		if _, err := parseBinlogPosition(c.Position); err != nil {
+			return nil, err
+		}
+	}
+	return &mysqlReader{
+		db: db,
+		cfg: replication.BinlogSyncerConfig{
+			ServerID: serverId,
+			Flavor:   mysql.MySQLFlavor,
+			Host:     pu.Hostname(),
+			Port:     port,
+			User:     pu.User.Username(),
+			Password: password,
+		},
+		filter:  newTableFilter(c.Tables),
+		columns: make(map[string][]string),
+	}, nil
+}
+
+func (r *mysqlReader) read(ctx api.StreamContext, position string, emit func(c *cdcChange) bool, commit func(position string)) error {
+	var (
+		pos mysql.Position
+		err error
+	)
+	if position == "" {
+		pos, err = r.masterPosition()
+	} else {
+		pos, err = parseBinlogPosition(position)
+	}
+	if err != nil {
+		return err
+	}
+	syncer := replication.NewBinlogSyncer(r.cfg)
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(pos)
+	if err != nil {
+		return fmt.Errorf("start binlog sync from %s error: %v", pos, err)
+	}
+	for {
+		ev, err := streamer.GetEvent(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("read binlog event error: %v", err)
+		}
+		switch e := ev.Event.(type) {
+		case *replication.RotateEvent:
+			pos = mysql.Position{Name: string(e.NextLogName), Pos: uint32(e.Position)}
+		case *replication.XIDEvent:
+			pos.Pos = ev.Header.LogPos
+			commit(formatBinlogPosition(pos))
+		case *replication.QueryEvent:
+			// the DDL is committed implicitly
+			if string(e.Query) != "BEGIN" {
+				pos.Pos = ev.Header.LogPos
+				commit(formatBinlogPosition(pos))
+			}
+		case *replication.RowsEvent:
+			changes, err := r.convert(ev.Header.EventType, e)
+			if err != nil {
+				return err
+			}
+			for _, c := range changes {
+				if !emit(c) {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// masterPosition gets the current binlog position to start when there is no position
+func (r *mysqlReader) masterPosition() (mysql.Position, error) {
+	rows, err := r.db.Query("SHOW MASTER STATUS")
+	if err != nil {
+		return mysql.Position{}, fmt.Errorf("query the binlog position error: %v", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return mysql.Position{}, fmt.Errorf("binlog is not enabled")
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return mysql.Position{}, err
+	}
+	// the columns are different in versions, the first two are the file and position
+	values := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return mysql.Position{}, err
+	}
+	return parseBinlogPosition(values[0].String + ":" + values[1].String)
+}
+
+func (r *mysqlReader) convert(t replication.EventType, e *replication.RowsEvent) ([]*cdcChange, error) {
+	schema, table := string(e.Table.Schema), string(e.Table.Table)
+	if !r.filter.match(schema, table) {
+		return nil, nil
+	}
+	var op string
+	switch t {
+	case replication.WRITE_ROWS_EVENTv0, replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
+		op = cdcInsert
+	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+		op = cdcUpdate
+	case replication.DELETE_ROWS_EVENTv0, replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
+		op = cdcDelete
+	default:
+		return nil, nil
+	}
+	names, err := r.columnNames(e.Table)
+	if err != nil {
+		return nil, err
+	}
+	var changes []*cdcChange
+	switch op {
+	case cdcUpdate:
+		// the rows are the pairs of the before and after rows
+		for i := 0; i+1 < len(e.Rows); i += 2 {
+			changes = append(changes, &cdcChange{op: op, schema: schema, table: table, before: toRow(names, e.Rows[i]), after: toRow(names, e.Rows[i+1])})
+		}
+	case cdcInsert:
+		for _, row := range e.Rows {
+			changes = append(changes, &cdcChange{op: op, schema: schema, table: table, after: toRow(names, row)})
+		}
+	case cdcDelete:
+		for _, row := range e.Rows {
+			changes = append(changes, &cdcChange{op: op, schema: schema, table: table, before: toRow(names, row)})
+		}
+	}
+	return changes, nil
+}
+
+// columnNames gets the column names from the binlog metadata, which requires binlog_row_metadata=FULL, or from the
+// information schema
+func (r *mysqlReader) columnNames(t *replication.TableMapEvent) ([]string, error) {
+	if names := t.ColumnNameString(); len(names) == int(t.ColumnCount) {
+		return names, nil
+	}
+	key := string(t.Schema) + "." + string(t.Table)
+	if names, ok := r.columns[key]; ok && len(names) == int(t.ColumnCount) {
+		return names, nil
+	}
+	rows, err := r.db.Query("SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", string(t.Schema), string(t.Table))
+	if err != nil {
+		return nil, fmt.Errorf("query the columns of %s error: %v", key, err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if len(names) != int(t.ColumnCount) {
+		return nil, fmt.Errorf("the columns of %s are changed, expect %d columns but got %d", key, t.ColumnCount, len(names))
+	}
+	r.columns[key] = names
+	return names, nil
+}
+
+func toRow(names []string, values []interface{}) map[string]interface{} {
+	row := make(map[string]interface{}, len(names))
+	for i, v := range values {
+		if i >= len(names) {
+			break
+		}
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		row[names[i]] = v
+	}
+	return row
+}
+
+func parseBinlogPosition(s string) (mysql.Position, error) {
+	i := strings.LastIndex(s, ":")
+	if i <= 0 {
+		return mysql.Position{}, fmt.Errorf("invalid binlog position %s, must be file:position", s)
+	}
+	p, err := strconv.ParseUint(s[i+1:], 10, 32)
+	if err != nil {
+		return mysql.Position{}, fmt.Errorf("invalid binlog position %s, must be file:position", s)
+	}
+	return mysql.Position{Name: s[:i], Pos: uint32(p)}, nil
+}
+
+func formatBinlogPosition(p mysql.Position) string {
+	return p.Name + ":" + strconv.FormatUint(uint64(p.Pos), 10)
+}
+
+// close does nothing, the syncer is closed when the reading is done
+func (r *mysqlReader) close() error {
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build (!no_base || mysql) && !no_mysql
+
+package main
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+func TestBinlogPosition(t *testing.T) {
+	tests := []struct {
+		s   string
+		pos mysql.Position
+		err string
+	}{
+		{s: "mysql-bin.000003:1234", pos: mysql.Position{Name: "mysql-bin.000003", Pos: 1234}},
+		{s: "mysql-bin.000003", err: "invalid binlog position mysql-bin.000003, must be file:position"},
+		{s: ":4", err: "invalid binlog position :4, must be file:position"},
+		{s: "mysql-bin.000003:a", err: "invalid binlog position mysql-bin.000003:a, must be file:position"},
+	}
+	for _, tt := range tests {
+		pos, err := parseBinlogPosition(tt.s)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: expect error %s but got %v", tt.s, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.s, err)
+			continue
+		}
+		if pos != tt.pos {
+			t.Errorf("%s: expect %v but got %v", tt.s, tt.pos, pos)
+		}
+		if s := formatBinlogPosition(pos); s != tt.s {
+			t.Errorf("expect %s but got %s", tt.s, s)
+		}
+	}
+}
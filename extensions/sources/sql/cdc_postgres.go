@@ -0,0 +1,232 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func init() {
+	cdcReaders["postgres"] = newPgReader
+	cdcReaders["pgx"] = newPgReader
+}
+
+// pgReader reads the changes from the logical replication slot with wal2json plugin by the SQL functions. The changes are
+// peeked in batches and the slot is advanced after the batch is sent, so the changes are at least once
+type pgReader struct {
+	db       *sql.DB
+	slot     string
+	tables   string
+	batch    int
+	interval time.Duration
+}
+
+// wal2jsonChange is the change in the format version 2 of wal2json
+type wal2jsonChange struct {
+	Action   string           `json:"action"`
+	Schema   string           `json:"schema"`
+	Table    string           `json:"table"`
+	Columns  []wal2jsonColumn `json:"columns"`
+	Identity []wal2jsonColumn `json:"identity"`
+}
+
+type wal2jsonColumn struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+func newPgReader(_ string, db *sql.DB, c *cdcConf, interval time.Duration) (cdcReader, error) {
+	if c.Slot == "" {
+		return nil, fmt.Errorf("cdc slot is required for postgres")
+	}
+	tables := make([]string, len(c.Tables))
+	for i, t := range c.Tables {
+		// wal2json requires the schema, * matches any schema
+		if !strings.Contains(t, ".") {
+			t = "*." + t
+		}
+		tables[i] = t
+	}
+	return &pgReader{
+		db:       db,
+		slot:     c.Slot,
+		tables:   strings.Join(tables, ","),
+		batch:    c.BatchSize,
+		interval: interval,
+	}, nil
+}
+
+func (r *pgReader) read(ctx api.StreamContext, position string, emit func(c *cdcChange) bool, commit func(position string)) error {
+	if err := r.ensureSlot(ctx); err != nil {
+		return err
+	}
+	// the slot cannot go backward, so the position only skips the changes
+	if position != "" {
+		if err := r.advance(ctx, position); err != nil {
+			return err
+		}
+	}
+	for {
+		n, last, err := r.readBatch(ctx, emit, commit)
+		if err != nil {
+			return err
+		}
+		if last != "" {
+			if err := r.advance(ctx, last); err != nil {
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		// there may be more changes if the batch is full
+		if n < r.batch {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(r.interval):
+			}
+		}
+	}
+}
+
+func (r *pgReader) ensureSlot(ctx context.Context) error {
+	var count int
+	if err := r.db.QueryRowContext(ctx, "SELECT count(*) FROM pg_replication_slots WHERE slot_name = $1", r.slot).Scan(&count); err != nil {
+		return fmt.Errorf("query the replication slot %s error: %v", r.slot, err)
+	}
+	if count > 0 {
+		return nil
+	}
+	if _, err := r.db.ExecContext(ctx, "SELECT pg_create_logical_replication_slot($1, 'wal2json')", r.slot); err != nil {
+		return fmt.Errorf("create the replication slot %s error: %v", r.slot, err)
+	}
+	return nil
+}
+
+// readBatch peeks a batch of changes, and returns the number of changes and the LSN of the last commit
+func (r *pgReader) readBatch(ctx api.StreamContext, emit func(c *cdcChange) bool, commit func(position string)) (int, string, error) {
+	query := "SELECT lsn::text, data FROM pg_logical_slot_peek_changes($1, NULL, $2, 'format-version', '2', 'include-transaction', 'true')"
+	args := []interface{}{r.slot, r.batch}
+	if r.tables != "" {
+		query = "SELECT lsn::text, data FROM pg_logical_slot_peek_changes($1, NULL, $2, 'format-version', '2', 'include-transaction', 'true', 'add-tables', $3)"
+		args = append(args, r.tables)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, "", fmt.Errorf("peek the changes of slot %s error: %v", r.slot, err)
+	}
+	defer rows.Close()
+	var (
+		n    int
+		last string
+	)
+	for rows.Next() {
+		var lsn, data string
+		if err := rows.Scan(&lsn, &data); err != nil {
+			return n, last, err
+		}
+		n++
+		c, isCommit, err := parseWal2json([]byte(data))
+		if err != nil {
+			ctx.GetLogger().Warnf("drop the change %s: %v", data, err)
+			continue
+		}
+		if isCommit {
+			last = lsn
+			commit(lsn)
+			continue
+		}
+		if c != nil && !emit(c) {
+			return n, last, nil
+		}
+	}
+	return n, last, rows.Err()
+}
+
+// advance confirms the changes until the LSN so that they are not read again
+func (r *pgReader) advance(ctx context.Context, lsn string) error {
+	_, err := r.db.ExecContext(ctx, "SELECT pg_replication_slot_advance($1, $2::pg_lsn) FROM pg_replication_slots WHERE slot_name = $1 AND confirmed_flush_lsn < $2::pg_lsn", r.slot, lsn)
+	if err != nil {
+		return fmt.Errorf("advance the replication slot %s to %s error: %v", r.slot, lsn, err)
+	}
+	return nil
+}
+
+// parseWal2json parses a change of wal2json. The begin, message and truncate are ignored and nil is returned
+func parseWal2json(data []byte) (*cdcChange, bool, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	w := &wal2jsonChange{}
+	if err := d.Decode(w); err != nil {
+		return nil, false, err
+	}
+	c := &cdcChange{
+		schema: w.Schema,
+		table:  w.Table,
+	}
+	switch w.Action {
+	case "I":
+		c.op = cdcInsert
+		c.after = wal2jsonRow(w.Columns)
+	case "U":
+		c.op = cdcUpdate
+		c.after = wal2jsonRow(w.Columns)
+		// the identity is the replica identity of the old row, which is the primary key by default
+		if len(w.Identity) > 0 {
+			c.before = wal2jsonRow(w.Identity)
+		}
+	case "D":
+		c.op = cdcDelete
+		c.before = wal2jsonRow(w.Identity)
+	case "C":
+		return nil, true, nil
+	case "B", "M", "T":
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("unknown action %s", w.Action)
+	}
+	return c, false, nil
+}
+
+func wal2jsonRow(cols []wal2jsonColumn) map[string]interface{} {
+	row := make(map[string]interface{}, len(cols))
+	for _, col := range cols {
+		v := col.Value
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				v = i
+			} else if f, err := n.Float64(); err == nil {
+				v = f
+			} else {
+				v = n.String()
+			}
+		}
+		row[col.Name] = v
+	}
+	return row
+}
+
+func (r *pgReader) close() error {
+	return nil
+}
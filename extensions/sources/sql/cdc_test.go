@@ -0,0 +1,127 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWal2json(t *testing.T) {
+	tests := []struct {
+		data     string
+		change   *cdcChange
+		isCommit bool
+		err      string
+	}{
+		{
+			data: `{"action":"I","schema":"public","table":"t1","columns":[{"name":"id","type":"integer","value":1},{"name":"name","type":"text","value":"a"},{"name":"price","type":"numeric","value":1.5}]}`,
+			change: &cdcChange{
+				op:     cdcInsert,
+				schema: "public",
+				table:  "t1",
+				after:  map[string]interface{}{"id": int64(1), "name": "a", "price": 1.5},
+			},
+		}, {
+			data: `{"action":"U","schema":"public","table":"t1","columns":[{"name":"id","type":"integer","value":1},{"name":"name","type":"text","value":"b"}],"identity":[{"name":"id","type":"integer","value":1}]}`,
+			change: &cdcChange{
+				op:     cdcUpdate,
+				schema: "public",
+				table:  "t1",
+				before: map[string]interface{}{"id": int64(1)},
+				after:  map[string]interface{}{"id": int64(1), "name": "b"},
+			},
+		}, {
+			data: `{"action":"D","schema":"public","table":"t1","identity":[{"name":"id","type":"integer","value":1}]}`,
+			change: &cdcChange{
+				op:     cdcDelete,
+				schema: "public",
+				table:  "t1",
+				before: map[string]interface{}{"id": int64(1)},
+			},
+		}, {
+			data: `{"action":"B"}`,
+		}, {
+			data:     `{"action":"C"}`,
+			isCommit: true,
+		}, {
+			data: `{"action":"X"}`,
+			err:  "unknown action X",
+		},
+	}
+	for i, tt := range tests {
+		c, isCommit, err := parseWal2json([]byte(tt.data))
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if isCommit != tt.isCommit {
+			t.Errorf("%d: expect commit %v but got %v", i, tt.isCommit, isCommit)
+		}
+		if !reflect.DeepEqual(c, tt.change) {
+			t.Errorf("%d: expect %v but got %v", i, tt.change, c)
+		}
+	}
+}
+
+func TestChangeTuple(t *testing.T) {
+	c := &cdcChange{
+		op:     cdcUpdate,
+		schema: "db",
+		table:  "t1",
+		before: map[string]interface{}{"id": 1, "name": "a"},
+		after:  map[string]interface{}{"id": 1, "name": "b"},
+	}
+	if !reflect.DeepEqual(c.message(), c.after) {
+		t.Errorf("expect message %v but got %v", c.after, c.message())
+	}
+	exp := map[string]interface{}{"op": "update", "schema": "db", "table": "t1", "before": c.before}
+	if !reflect.DeepEqual(c.meta(), exp) {
+		t.Errorf("expect meta %v but got %v", exp, c.meta())
+	}
+	d := &cdcChange{op: cdcDelete, schema: "db", table: "t1", before: map[string]interface{}{"id": 1}}
+	if !reflect.DeepEqual(d.message(), d.before) {
+		t.Errorf("expect message %v but got %v", d.before, d.message())
+	}
+}
+
+func TestTableFilter(t *testing.T) {
+	f := newTableFilter([]string{"t1", "db.t2"})
+	tests := []struct {
+		schema string
+		table  string
+		match  bool
+	}{
+		{schema: "db", table: "t1", match: true},
+		{schema: "other", table: "t1", match: true},
+		{schema: "db", table: "t2", match: true},
+		{schema: "other", table: "t2", match: false},
+		{schema: "db", table: "t3", match: false},
+	}
+	for _, tt := range tests {
+		if m := f.match(tt.schema, tt.table); m != tt.match {
+			t.Errorf("%s.%s: expect %v but got %v", tt.schema, tt.table, tt.match, m)
+		}
+	}
+	if !newTableFilter(nil).match("db", "t3") {
+		t.Errorf("expect empty filter matches all tables")
+	}
+}
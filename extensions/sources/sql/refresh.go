@@ -0,0 +1,110 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// refreshConf configures the full refresh of the scan table. The whole table is loaded at the beginning and reloaded
+// by the interval or the trigger topic, the rows of each load replace the table atomically
+type refreshConf struct {
+	// Interval is the refresh interval in ms, the table is only refreshed by the trigger topic if it is 0
+	Interval int `json:"interval"`
+	// Topic is the memory topic to trigger the refresh, any message of the topic triggers a refresh
+	Topic string `json:"topic"`
+}
+
+func (r *refreshConf) validate(cdc *cdcConf) error {
+	if cdc != nil {
+		return fmt.Errorf("refresh is not supported in cdc mode")
+	}
+	if r.Interval < 0 {
+		return fmt.Errorf("refresh interval must not be negative")
+	}
+	if r.Interval == 0 && r.Topic == "" {
+		return fmt.Errorf("refresh requires interval or topic")
+	}
+	return nil
+}
+
+// readRefresh loads the whole table and sends the rows as a batch ended by EOF in each refresh. If a refresh fails,
+// the table keeps the rows of the last load
+func (m *sqlsource) readRefresh(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	var tick <-chan time.Time
+	if m.conf.Refresh.Interval > 0 {
+		t := time.NewTicker(time.Duration(m.conf.Refresh.Interval) * time.Millisecond)
+		defer t.Stop()
+		tick = t.C
+	}
+	var trigger chan api.SourceTuple
+	if m.conf.Refresh.Topic != "" {
+		sourceId := fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId())
+		trigger = pubsub.CreateSub(m.conf.Refresh.Topic, nil, sourceId, 16)
+		defer pubsub.CloseSourceConsumerChannel(m.conf.Refresh.Topic, sourceId)
+	}
+	// the first load must succeed to build the table
+	if err := m.load(ctx, consumer); err != nil {
+		errCh <- err
+		return
+	}
+	for {
+		select {
+		case <-tick:
+		case <-trigger:
+			// the triggers received during the last load are covered by this load
+			for len(trigger) > 0 {
+				<-trigger
+			}
+		case <-ctx.Done():
+			return
+		}
+		if err := m.load(ctx, consumer); err != nil {
+			logger.Warnf("Refresh the sql table error %v, keep the last rows", err)
+		}
+	}
+}
+
+// load queries the whole table before sending, so that a failed query does not send a part of the table
+func (m *sqlsource) load(ctx api.StreamContext, consumer chan<- api.SourceTuple) error {
+	rcvTime := conf.GetNow()
+	var rows []map[string]interface{}
+	err := m.query(ctx, func(data map[string]interface{}) {
+		rows = append(rows, data)
+	})
+	if err != nil {
+		return err
+	}
+	ctx.GetLogger().Debugf("Refresh the sql table with %d rows", len(rows))
+	for _, data := range rows {
+		select {
+		case consumer <- api.NewDefaultSourceTupleWithTime(data, nil, rcvTime):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	// EOF to swap the table
+	select {
+	case consumer <- api.NewDefaultSourceTupleWithTime(nil, nil, rcvTime):
+	case <-ctx.Done():
+	}
+	return nil
+}
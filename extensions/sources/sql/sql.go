@@ -30,6 +30,13 @@ import (
 type sqlConConfig struct {
 	Interval int    `json:"interval"`
 	Url      string `json:"url"`
+	// ConnectionId is the id of the shared sql connection in the connection pool, the url of the connection is used
+	ConnectionId string `json:"connectionId"`
+	// Cdc reads the changes of the tables instead of polling the query if set
+	Cdc *cdcConf `json:"cdc"`
+	// Refresh reloads the whole table periodically or by the trigger topic when used as a scan table
+	Refresh *refreshConf `json:"refresh"`
+	IsTable bool         `json:"isTable"`
 }
 
 type sqlsource struct {
@@ -37,6 +44,9 @@ type sqlsource struct {
 	Query sqlgen.SqlQueryGenerator
 	// The db connection instance
 	db *sql.DB
+	// cdc reader and the committed position in cdc mode
+	cdc      cdcReader
+	position cdcPosition
 }
 
 func (m *sqlsource) Configure(_ string, props map[string]interface{}) error {
@@ -46,98 +56,167 @@ func (m *sqlsource) Configure(_ string, props map[string]interface{}) error {
 	if err != nil {
 		return fmt.Errorf("read properties %v fail with error: %v", props, err)
 	}
+	if cfg.ConnectionId != "" {
+		if cfg.Url, err = util.ConnectionUrl(cfg.ConnectionId); err != nil {
+			return err
+		}
+	}
 	if cfg.Url == "" {
 		return fmt.Errorf("property Url is required")
 	}
-	if cfg.Interval == 0 {
+	if cfg.Refresh != nil {
+		if !cfg.IsTable {
+			conf.Log.Warnf("sql source refresh is only effective in scan table, ignore it")
+			cfg.Refresh = nil
+		} else if err := cfg.Refresh.validate(cfg.Cdc); err != nil {
+			return err
+		}
+	}
+	// the interval is the polling interval of the slot in cdc mode
+	if cfg.Interval == 0 && cfg.Cdc == nil && cfg.Refresh == nil {
 		return fmt.Errorf("property interval is required")
 	}
+	if cfg.Interval == 0 {
+		cfg.Interval = 1000
+	}
 
 	driver, err := util.ParseDriver(cfg.Url)
 	if err != nil {
 		return fmt.Errorf("dburl.Parse %s fail with error: %v", cfg.Url, err)
 	}
 
-	generator, err := sqlgen.GetQueryGenerator(driver, props)
-	if err != nil {
-		return fmt.Errorf("GetQueryGenerator %s fail with error: %v", cfg.Url, err)
+	if cfg.Cdc == nil {
+		generator, err := sqlgen.GetQueryGenerator(driver, props)
+		if err != nil {
+			return fmt.Errorf("GetQueryGenerator %s fail with error: %v", cfg.Url, err)
+		}
+		m.Query = generator
 	}
 
-	m.Query = generator
 	m.conf = cfg
-	db, err := util.FetchDBToOneNode(util.GlobalPool, m.conf.Url)
+	db, err := util.FetchDB(m.conf.Url, m.conf.ConnectionId)
 	if err != nil {
 		return fmt.Errorf("connection to %s Open with error %v, support build tags are %v", m.conf.Url, err, driver2.KnownBuildTags())
 	}
 	m.db = db
 
+	if cfg.Cdc != nil {
+		reader, err := newCdcReader(driver, cfg.Url, db, cfg.Cdc, time.Duration(cfg.Interval)*time.Millisecond)
+		if err != nil {
+			_ = util.ReturnDB(cfg.Url, cfg.ConnectionId)
+			m.db = nil
+			return err
+		}
+		m.cdc = reader
+		m.position.set(cfg.Cdc.Position)
+	}
+
 	return nil
 }
 
 func (m *sqlsource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
-	logger := ctx.GetLogger()
+	if m.cdc != nil {
+		m.readCdc(ctx, consumer, errCh)
+		return
+	}
+	if m.conf.Refresh != nil {
+		m.readRefresh(ctx, consumer, errCh)
+		return
+	}
 	t := time.NewTicker(time.Duration(m.conf.Interval) * time.Millisecond)
 	defer t.Stop()
 	for {
 		select {
 		case <-t.C:
 			rcvTime := conf.GetNow()
-			query, err := m.Query.SqlQueryStatement()
-			if err != nil {
-				logger.Errorf("Get sql query error %v", err)
-			}
-			logger.Debugf("Query the database with %s", query)
-			rows, err := m.db.Query(query)
-			if err != nil {
-				logger.Errorf("Run sql query(%s) error %v", query, err)
-				errCh <- err
-				return
-			}
-
-			cols, _ := rows.Columns()
-
-			types, err := rows.ColumnTypes()
+			err := m.query(ctx, func(data map[string]interface{}) {
+				m.Query.UpdateMaxIndexValue(data)
+				consumer <- api.NewDefaultSourceTupleWithTime(data, nil, rcvTime)
+			})
 			if err != nil {
-				logger.Errorf("row ColumnTypes error %v", query, err)
 				errCh <- err
 				return
 			}
-			for rows.Next() {
-				data := make(map[string]interface{})
-				columns := make([]interface{}, len(cols))
-				prepareValues(columns, types, cols)
-
-				err := rows.Scan(columns...)
-				if err != nil {
-					logger.Errorf("Run sql scan(%s) error %v", query, err)
-					errCh <- err
-					return
-				}
-
-				scanIntoMap(data, columns, cols)
-				m.Query.UpdateMaxIndexValue(data)
-				consumer <- api.NewDefaultSourceTupleWithTime(data, nil, rcvTime)
-			}
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// query runs the query and calls emit for each row
+func (m *sqlsource) query(ctx api.StreamContext, emit func(data map[string]interface{})) error {
+	logger := ctx.GetLogger()
+	query, err := m.Query.SqlQueryStatement()
+	if err != nil {
+		logger.Errorf("Get sql query error %v", err)
+	}
+	logger.Debugf("Query the database with %s", query)
+	rows, err := m.db.Query(query)
+	if err != nil {
+		logger.Errorf("Run sql query(%s) error %v", query, err)
+		return err
+	}
+	defer rows.Close()
+
+	cols, _ := rows.Columns()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		logger.Errorf("row ColumnTypes(%s) error %v", query, err)
+		return err
+	}
+	for rows.Next() {
+		data := make(map[string]interface{})
+		columns := make([]interface{}, len(cols))
+		prepareValues(columns, types, cols)
+
+		err := rows.Scan(columns...)
+		if err != nil {
+			logger.Errorf("Run sql scan(%s) error %v", query, err)
+			return err
+		}
+
+		scanIntoMap(data, columns, cols)
+		emit(data)
+	}
+	return nil
+}
+
 func (m *sqlsource) GetOffset() (interface{}, error) {
+	if m.cdc != nil {
+		return m.position.get(), nil
+	}
 	return m.Query.GetIndexValue(), nil
 }
 
 func (m *sqlsource) Rewind(offset interface{}) error {
+	if m.cdc != nil {
+		pos, err := cast.ToString(offset, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return fmt.Errorf("invalid cdc position %v: %v", offset, err)
+		}
+		m.position.set(pos)
+		return nil
+	}
 	m.Query.SetIndexValue(offset)
 	return nil
 }
 
+// IsBatchTable returns true if the table is reloaded by refresh, so that the rows of each load replace the table
+func (m *sqlsource) IsBatchTable() bool {
+	return m.conf.Refresh != nil
+}
+
 func (m *sqlsource) Close(ctx api.StreamContext) error {
 	logger := ctx.GetLogger()
 	logger.Debugf("Closing sql stream to %v", m.conf)
+	if m.cdc != nil {
+		if err := m.cdc.close(); err != nil {
+			logger.Warnf("close the cdc reader error %v", err)
+		}
+	}
 	if m.db != nil {
-		return util.ReturnDBFromOneNode(util.GlobalPool, m.conf.Url)
+		return util.ReturnDB(m.conf.Url, m.conf.ConnectionId)
 	}
 
 	return nil
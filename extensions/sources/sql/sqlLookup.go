@@ -26,18 +26,21 @@ import (
 
 type sqlLookupConfig struct {
 	Url string `json:"url"`
+	// ConnectionId is the id of the shared sql connection in the connection pool, the url of the connection is used
+	ConnectionId string `json:"connectionId"`
 }
 
 type sqlLookupSource struct {
-	url   string
-	table string
-	db    *sql.DB
+	url          string
+	connectionId string
+	table        string
+	db           *sql.DB
 }
 
 // Open establish a connection to the database
 func (s *sqlLookupSource) Open(ctx api.StreamContext) error {
 	ctx.GetLogger().Debugf("Opening sql lookup source")
-	db, err := util.FetchDBToOneNode(util.GlobalPool, s.url)
+	db, err := util.FetchDB(s.url, s.connectionId)
 	if err != nil {
 		return fmt.Errorf("connection to %s Open with error %v", s.url, err)
 	}
@@ -51,10 +54,16 @@ func (s *sqlLookupSource) Configure(datasource string, props map[string]interfac
 	if err != nil {
 		return fmt.Errorf("read properties %v fail with error: %v", props, err)
 	}
+	if cfg.ConnectionId != "" {
+		if cfg.Url, err = util.ConnectionUrl(cfg.ConnectionId); err != nil {
+			return err
+		}
+	}
 	if cfg.Url == "" {
 		return fmt.Errorf("property Url is required")
 	}
 	s.url = cfg.Url
+	s.connectionId = cfg.ConnectionId
 	s.table = datasource
 	return nil
 }
@@ -116,7 +125,7 @@ func (s *sqlLookupSource) Close(ctx api.StreamContext) error {
 	ctx.GetLogger().Debugf("Closing sql lookup source")
 	defer func() { s.db = nil }()
 	if s.db != nil {
-		return util.ReturnDBFromOneNode(util.GlobalPool, s.url)
+		return util.ReturnDB(s.url, s.connectionId)
 	}
 	return nil
 }
@@ -0,0 +1,100 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/topo/connection/pool"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// pooledDB is the sql connection shared in the connection pool. The *sql.DB is fetched from the GlobalPool, so it is
+// also shared with the sources and sinks using the same url directly.
+type pooledDB struct {
+	url string
+	db  *sql.DB
+}
+
+func init() {
+	pool.RegisterProvider("sql", newPooledDB)
+}
+
+func newPooledDB(props map[string]interface{}) (pool.Conn, error) {
+	c := &struct {
+		Url string `json:"url"`
+	}{}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return nil, fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Url == "" {
+		return nil, fmt.Errorf("property Url is required")
+	}
+	db, err := FetchDBToOneNode(GlobalPool, c.Url)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledDB{url: c.Url, db: db}, nil
+}
+
+// Ping checks the database. The *sql.DB reconnects by itself, so it does not implement the Reconnector.
+func (p *pooledDB) Ping() error {
+	if p.db == nil {
+		return nil
+	}
+	return p.db.Ping()
+}
+
+func (p *pooledDB) Close() error {
+	return ReturnDBFromOneNode(GlobalPool, p.url)
+}
+
+// ConnectionUrl returns the url of the shared sql connection
+func ConnectionUrl(connectionId string) (string, error) {
+	c, err := pool.GetConnection(connectionId)
+	if err != nil {
+		return "", err
+	}
+	if c.Typ != "sql" {
+		return "", fmt.Errorf("connection %s is of type %s but sql is required", connectionId, c.Typ)
+	}
+	url, ok := c.Props["url"].(string)
+	if !ok || url == "" {
+		return "", fmt.Errorf("connection %s does not have the url", connectionId)
+	}
+	return url, nil
+}
+
+// FetchDB returns the shared db of the connection if connectionId is set, otherwise the db of the url from the GlobalPool
+func FetchDB(url string, connectionId string) (*sql.DB, error) {
+	if connectionId == "" {
+		return FetchDBToOneNode(GlobalPool, url)
+	}
+	c, err := pool.Attach(connectionId, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return c.(*pooledDB).db, nil
+}
+
+// ReturnDB returns the db fetched by FetchDB
+func ReturnDB(url string, connectionId string) error {
+	if connectionId == "" {
+		return ReturnDBFromOneNode(GlobalPool, url)
+	}
+	pool.Detach(connectionId)
+	return nil
+}
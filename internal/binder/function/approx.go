@@ -0,0 +1,176 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	tDigestCompression = 100
+	// the number of the values buffered before merging into the centroids
+	tDigestBufferSize = 500
+	// hllPrecision is the number of bits to index the registers, the standard error is 1.04/sqrt(2^hllPrecision)
+	hllPrecision = 14
+)
+
+type centroid struct {
+	mean  float64
+	count float64
+}
+
+// tDigest is the merging t-digest to estimate the quantiles with bounded memory. The values are buffered and merged
+// into the centroids whose sizes are limited by the quantile, so that the centroids near the tails are small to
+// keep the accuracy of the extreme quantiles
+type tDigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	total       float64
+	min         float64
+	max         float64
+}
+
+func newTDigest(compression float64) *tDigest {
+	return &tDigest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+func (t *tDigest) add(v float64) {
+	t.buffer = append(t.buffer, centroid{mean: v, count: 1})
+	t.total++
+	if v < t.min {
+		t.min = v
+	}
+	if v > t.max {
+		t.max = v
+	}
+	if len(t.buffer) >= tDigestBufferSize {
+		t.compress()
+	}
+}
+
+func (t *tDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	all := append(t.centroids, t.buffer...)
+	t.buffer = t.buffer[:0]
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].mean < all[j].mean
+	})
+	merged := make([]centroid, 0, len(all))
+	cur := all[0]
+	var soFar float64
+	for _, c := range all[1:] {
+		q := (soFar + (cur.count+c.count)/2) / t.total
+		if cur.count+c.count <= 4*t.total*q*(1-q)/t.compression {
+			cur.count += c.count
+			cur.mean += (c.mean - cur.mean) * c.count / cur.count
+		} else {
+			soFar += cur.count
+			merged = append(merged, cur)
+			cur = c
+		}
+	}
+	t.centroids = append(merged, cur)
+}
+
+// quantile interpolates the value of the quantile q between the centers of the adjacent centroids
+func (t *tDigest) quantile(q float64) (float64, bool) {
+	t.compress()
+	n := len(t.centroids)
+	if n == 0 {
+		return 0, false
+	}
+	if n == 1 {
+		return t.centroids[0].mean, true
+	}
+	idx := q * t.total
+	prevCenter, prevMean := 0.0, t.min
+	var soFar float64
+	for _, c := range t.centroids {
+		center := soFar + c.count/2
+		if idx < center {
+			if center == prevCenter {
+				return c.mean, true
+			}
+			return prevMean + (idx-prevCenter)/(center-prevCenter)*(c.mean-prevMean), true
+		}
+		prevCenter, prevMean = center, c.mean
+		soFar += c.count
+	}
+	if t.total == prevCenter {
+		return t.max, true
+	}
+	return prevMean + (idx-prevCenter)/(t.total-prevCenter)*(t.max-prevMean), true
+}
+
+// hyperLogLog estimates the number of the distinct values with a fixed size of registers
+type hyperLogLog struct {
+	registers []uint8
+}
+
+func newHyperLogLog() *hyperLogLog {
+	return &hyperLogLog{registers: make([]uint8, 1<<hllPrecision)}
+}
+
+func (h *hyperLogLog) add(v interface{}) {
+	hs := fnv.New64a()
+	_, _ = hs.Write([]byte(fmt.Sprintf("%v", v)))
+	x := mix64(hs.Sum64())
+	idx := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1))) + 1
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+func (h *hyperLogLog) count() int64 {
+	m := float64(len(h.registers))
+	var (
+		sum   float64
+		zeros int
+	)
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	e := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// linear counting for the small cardinalities
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(e))
+}
+
+// mix64 is the finalizer of murmur3 to spread the bits of the hash
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -16,6 +16,8 @@ package function
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/montanaflynn/stats"
 
@@ -372,6 +374,166 @@ func registerAggFunc() {
 		},
 		val: ValidateTwoNumberArg,
 	}
+	builtins["percentile_approx"] = builtinFunc{
+		fType: ast.FuncTypeAgg,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			if err := ValidateLen(2, len(args)); err != nil {
+				return err, false
+			}
+			arg0 := args[0].([]interface{})
+			arg1 := args[1].([]interface{})
+			if len(arg0) == 0 {
+				return nil, true
+			}
+			p, err := cast.ToFloat64(getFirstValidArg(arg1), cast.CONVERT_SAMEKIND)
+			if err != nil || p < 0 || p > 1 {
+				return fmt.Errorf("the second parameter requires float64 between 0 and 1 but found %[1]T(%[1]v)", getFirstValidArg(arg1)), false
+			}
+			td := newTDigest(tDigestCompression)
+			for _, v := range arg0 {
+				if v == nil {
+					continue
+				}
+				fv, err := cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+				if err != nil {
+					return fmt.Errorf("requires float64 but found %[1]T(%[1]v)", v), false
+				}
+				td.add(fv)
+			}
+			if r, ok := td.quantile(p); ok {
+				return r, true
+			}
+			return nil, true
+		},
+		val: ValidateTwoNumberArg,
+	}
+	builtins["count_distinct_approx"] = builtinFunc{
+		fType: ast.FuncTypeAgg,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			arg0, ok := args[0].([]interface{})
+			if !ok {
+				return fmt.Errorf("Invalid argument type found."), false
+			}
+			h := newHyperLogLog()
+			for _, v := range arg0 {
+				if v != nil {
+					h.add(v)
+				}
+			}
+			return h.count(), true
+		},
+		val: ValidateOneArg,
+	}
+	builtins["top_n"] = builtinFunc{
+		fType: ast.FuncTypeAgg,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			return topNExec(args, true)
+		},
+		val: validateTopN,
+	}
+	builtins["bottom_n"] = builtinFunc{
+		fType: ast.FuncTypeAgg,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			return topNExec(args, false)
+		},
+		val: validateTopN,
+	}
+}
+
+func validateTopN(_ api.FunctionContext, args []ast.Expr) error {
+	if err := ValidateLen(2, len(args)); err != nil {
+		return err
+	}
+	if !ast.IsIntegerArg(args[1]) || args[1].(*ast.IntegerLiteral).Val <= 0 {
+		return ProduceErrInfo(1, "positive int")
+	}
+	return nil
+}
+
+// topNExec the first argument is the rows of the group which is added by the parser, the second is the column to
+// sort and the third is the number of rows to return
+func topNExec(args []interface{}, desc bool) (interface{}, bool) {
+	if err := ValidateLen(3, len(args)); err != nil {
+		return err, false
+	}
+	rows, ok1 := args[0].([]interface{})
+	col, ok2 := args[1].([]interface{})
+	na, ok3 := args[2].([]interface{})
+	if !ok1 || !ok2 || !ok3 {
+		return fmt.Errorf("Invalid argument type found."), false
+	}
+	if len(rows) == 0 {
+		return nil, true
+	}
+	n, err := cast.ToInt(getFirstValidArg(na), cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return fmt.Errorf("the third parameter requires int but found %[1]T(%[1]v)", getFirstValidArg(na)), false
+	}
+	r, err := topN(rows, col, n, desc)
+	if err != nil {
+		return err, false
+	}
+	return r, true
+}
+
+// topN returns the rows of the top n values of the column. The rows are sorted by the values in descending order if
+// desc is true, otherwise in ascending order. The rows with the same value keep their order and the null values are ignored
+func topN(rows []interface{}, col []interface{}, n int, desc bool) ([]interface{}, error) {
+	idx := make([]int, 0, len(col))
+	for i, v := range col {
+		if v != nil {
+			idx = append(idx, i)
+		}
+	}
+	var err error
+	sort.SliceStable(idx, func(i, j int) bool {
+		c, e := compareValue(col[idx[i]], col[idx[j]])
+		if e != nil {
+			err = e
+			return false
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) > n {
+		idx = idx[:n]
+	}
+	result := make([]interface{}, len(idx))
+	for i, k := range idx {
+		result[i] = rows[k]
+	}
+	return result, nil
+}
+
+func compareValue(a, b interface{}) (int, error) {
+	if sa, ok := a.(string); ok {
+		sb, ok := b.(string)
+		if !ok {
+			return 0, fmt.Errorf("cannot compare %[1]T(%[1]v) with %[2]T(%[2]v)", a, b)
+		}
+		return strings.Compare(sa, sb), nil
+	}
+	fa, err := cast.ToFloat64(a, cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return 0, fmt.Errorf("cannot compare %[1]T(%[1]v) with %[2]T(%[2]v)", a, b)
+	}
+	fb, err := cast.ToFloat64(b, cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return 0, fmt.Errorf("cannot compare %[1]T(%[1]v) with %[2]T(%[2]v)", a, b)
+	}
+	switch {
+	case fa < fb:
+		return -1, nil
+	case fa > fb:
+		return 1, nil
+	default:
+		return 0, nil
+	}
 }
 
 func getCount(s []interface{}) int {
@@ -16,6 +16,7 @@ package function
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"testing"
 
@@ -261,3 +262,151 @@ func TestPercentileExec(t *testing.T) {
 		}
 	}
 }
+
+func TestTopNExec(t *testing.T) {
+	fTop, ok := builtins["top_n"]
+	if !ok {
+		t.Fatal("builtin not found")
+	}
+	fBottom, ok := builtins["bottom_n"]
+	if !ok {
+		t.Fatal("builtin not found")
+	}
+	contextLogger := conf.Log.WithField("rule", "testExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	fctx := kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), 2)
+	rows := []interface{}{
+		map[string]interface{}{"id": 1, "temp": 20},
+		map[string]interface{}{"id": 2, "temp": nil},
+		map[string]interface{}{"id": 3, "temp": 25.5},
+		map[string]interface{}{"id": 4, "temp": 20},
+		map[string]interface{}{"id": 5, "temp": 18},
+	}
+	tests := []struct {
+		args   []interface{}
+		top    interface{}
+		bottom interface{}
+	}{
+		{ // 0
+			args: []interface{}{
+				rows,
+				[]interface{}{20, nil, 25.5, 20, 18},
+				[]interface{}{3, 3, 3, 3, 3},
+			},
+			top:    []interface{}{rows[2], rows[0], rows[3]},
+			bottom: []interface{}{rows[4], rows[0], rows[3]},
+		}, { // 1
+			args: []interface{}{
+				rows,
+				[]interface{}{20, nil, 25.5, 20, 18},
+				[]interface{}{10, 10, 10, 10, 10},
+			},
+			top:    []interface{}{rows[2], rows[0], rows[3], rows[4]},
+			bottom: []interface{}{rows[4], rows[0], rows[3], rows[2]},
+		}, { // 2
+			args: []interface{}{
+				rows[:2],
+				[]interface{}{"a", 1},
+				[]interface{}{1, 1},
+			},
+			top:    fmt.Errorf("cannot compare int(1) with string(a)"),
+			bottom: fmt.Errorf("cannot compare int(1) with string(a)"),
+		}, { // 3
+			args: []interface{}{
+				[]interface{}{},
+				[]interface{}{},
+				[]interface{}{},
+			},
+			top:    nil,
+			bottom: nil,
+		},
+	}
+	for i, tt := range tests {
+		rTop, _ := fTop.exec(fctx, tt.args)
+		if !reflect.DeepEqual(rTop, tt.top) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, rTop, tt.top)
+		}
+		rBottom, _ := fBottom.exec(fctx, tt.args)
+		if !reflect.DeepEqual(rBottom, tt.bottom) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, rBottom, tt.bottom)
+		}
+	}
+}
+
+func TestApproxExec(t *testing.T) {
+	pApprox, ok := builtins["percentile_approx"]
+	if !ok {
+		t.Fatal("builtin not found")
+	}
+	cApprox, ok := builtins["count_distinct_approx"]
+	if !ok {
+		t.Fatal("builtin not found")
+	}
+	contextLogger := conf.Log.WithField("rule", "testExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	fctx := kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), 2)
+	tests := []struct {
+		args    []interface{}
+		pApprox interface{}
+		cApprox interface{}
+	}{
+		{ // 0
+			args: []interface{}{
+				[]interface{}{100, 150, nil, 200, 150},
+				[]interface{}{0.5, 0.5, 0.5, 0.5, 0.5},
+			},
+			pApprox: float64(150),
+			cApprox: int64(3),
+		}, { // 1
+			args: []interface{}{
+				[]interface{}{"foo", "bar"},
+				[]interface{}{0.5, 0.5},
+			},
+			pApprox: fmt.Errorf("requires float64 but found string(foo)"),
+			cApprox: int64(2),
+		}, { // 2
+			args: []interface{}{
+				[]interface{}{1, 2},
+				[]interface{}{1.5, 1.5},
+			},
+			pApprox: fmt.Errorf("the second parameter requires float64 between 0 and 1 but found float64(1.5)"),
+			cApprox: int64(2),
+		}, { // 3
+			args: []interface{}{
+				[]interface{}{},
+				[]interface{}{},
+			},
+			pApprox: nil,
+			cApprox: int64(0),
+		},
+	}
+	for i, tt := range tests {
+		rp, _ := pApprox.exec(fctx, tt.args)
+		if !reflect.DeepEqual(rp, tt.pApprox) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, rp, tt.pApprox)
+		}
+		rc, _ := cApprox.exec(fctx, tt.args[:1])
+		if !reflect.DeepEqual(rc, tt.cApprox) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, rc, tt.cApprox)
+		}
+	}
+	// the estimations of the large inputs are within the error bounds
+	var (
+		values []interface{}
+		ps     []interface{}
+	)
+	for i := 1; i <= 100000; i++ {
+		values = append(values, i%50000)
+		ps = append(ps, 0.9)
+	}
+	rp, _ := pApprox.exec(fctx, []interface{}{values, ps})
+	if v, ok := rp.(float64); !ok || math.Abs(v-45000) > 500 {
+		t.Errorf("percentile approx result %v is out of the error bound", rp)
+	}
+	rc, _ := cApprox.exec(fctx, []interface{}{values})
+	if v, ok := rc.(int64); !ok || math.Abs(float64(v)-50000) > 2500 {
+		t.Errorf("count distinct approx result %v is out of the error bound", rc)
+	}
+}
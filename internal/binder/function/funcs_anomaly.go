@@ -0,0 +1,285 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// madScale makes the median absolute deviation comparable to the standard deviation of a normal distribution
+const madScale = 0.6745
+
+func init() {
+	// the states are saved in the checkpoint
+	gob.Register(&ewmaState{})
+	gob.Register(&rollingWindow{})
+}
+
+// registerAnomalyFunc registers the anomaly detection functions. They are analytic functions, so the
+// last two parameters are always the when condition result and the partition key.
+// Each function calculates the score of the current value against the statistics of the previous values,
+// and then updates the statistics with the current value if the when condition is met.
+func registerAnomalyFunc() {
+	builtins["ewma_score"] = builtinFunc{
+		fType: ast.FuncTypeScalar,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			if l := len(args) - 2; l != 2 && l != 3 {
+				return fmt.Errorf("expect two or three args but got %d", l), false
+			}
+			if args[0] == nil {
+				return nil, true
+			}
+			x, err := cast.ToFloat64(args[0], cast.CONVERT_SAMEKIND)
+			if err != nil {
+				return fmt.Errorf("requires float64 but found %[1]T(%[1]v)", args[0]), false
+			}
+			alpha, err := cast.ToFloat64(args[1], cast.CONVERT_SAMEKIND)
+			if err != nil || alpha <= 0 || alpha > 1 {
+				return fmt.Errorf("the second parameter requires float64 between 0 and 1 but found %[1]T(%[1]v)", args[1]), false
+			}
+			key := args[len(args)-1].(string)
+			v, err := ctx.GetState(key)
+			if err != nil {
+				return fmt.Errorf("error getting state for %s: %v", key, err), false
+			}
+			s, _ := v.(*ewmaState)
+			if s == nil {
+				s = &ewmaState{}
+			}
+			var (
+				score  float64
+				scored bool
+			)
+			if s.Count > 0 {
+				score, scored = deviationScore(x-s.Mean, math.Sqrt(s.Var), 1)
+			} else {
+				scored = true
+			}
+			if validData(args) {
+				s.update(x, alpha)
+				if err := ctx.PutState(key, s); err != nil {
+					return fmt.Errorf("error setting state for %s: %v", key, err), false
+				}
+			}
+			return anomalyResult(score, scored, args)
+		},
+		val: validateAnomalyFunc(func(arg ast.Expr) error {
+			if ast.IsStringArg(arg) || ast.IsTimeArg(arg) || ast.IsBooleanArg(arg) {
+				return ProduceErrInfo(1, "float")
+			}
+			if f, ok := arg.(*ast.NumberLiteral); ok && (f.Val <= 0 || f.Val > 1) {
+				return fmt.Errorf("the smoothing factor must be greater than 0 and not greater than 1")
+			}
+			if i, ok := arg.(*ast.IntegerLiteral); ok && i.Val != 1 {
+				return fmt.Errorf("the smoothing factor must be greater than 0 and not greater than 1")
+			}
+			return nil
+		}),
+	}
+	builtins["z_score"] = builtinFunc{
+		fType: ast.FuncTypeScalar,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			return execWindowScore(ctx, args, func(w []float64, x float64) (float64, bool) {
+				var mean, sum float64
+				for _, d := range w {
+					mean += d
+				}
+				mean /= float64(len(w))
+				for _, d := range w {
+					sum += (d - mean) * (d - mean)
+				}
+				return deviationScore(x-mean, math.Sqrt(sum/float64(len(w))), 1)
+			})
+		},
+		val: validateAnomalyFunc(validateAnomalyWindow),
+	}
+	builtins["mad_score"] = builtinFunc{
+		fType: ast.FuncTypeScalar,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			return execWindowScore(ctx, args, func(w []float64, x float64) (float64, bool) {
+				med := median(w)
+				devs := make([]float64, len(w))
+				for i, d := range w {
+					devs[i] = math.Abs(d - med)
+				}
+				return deviationScore(x-med, median(devs), madScale)
+			})
+		},
+		val: validateAnomalyFunc(validateAnomalyWindow),
+	}
+}
+
+// execWindowScore runs the score function against the rolling window of the previous values
+func execWindowScore(ctx api.FunctionContext, args []interface{}, scoreFunc func(w []float64, x float64) (float64, bool)) (interface{}, bool) {
+	if l := len(args) - 2; l != 2 && l != 3 {
+		return fmt.Errorf("expect two or three args but got %d", l), false
+	}
+	if args[0] == nil {
+		return nil, true
+	}
+	x, err := cast.ToFloat64(args[0], cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return fmt.Errorf("requires float64 but found %[1]T(%[1]v)", args[0]), false
+	}
+	size, err := cast.ToInt(args[1], cast.STRICT)
+	if err != nil || size < 2 {
+		return fmt.Errorf("the second parameter requires int greater than 1 but found %[1]T(%[1]v)", args[1]), false
+	}
+	key := args[len(args)-1].(string)
+	v, err := ctx.GetState(key)
+	if err != nil {
+		return fmt.Errorf("error getting state for %s: %v", key, err), false
+	}
+	w, _ := v.(*rollingWindow)
+	if w == nil {
+		w = newRollingWindow(size)
+	}
+	var (
+		score  float64
+		scored = true
+	)
+	// at least two previous values are required to measure the deviation
+	if len(w.Data) >= 2 {
+		score, scored = scoreFunc(w.Data, x)
+	}
+	if validData(args) {
+		w.append(x)
+		if err := ctx.PutState(key, w); err != nil {
+			return fmt.Errorf("error setting state for %s: %v", key, err), false
+		}
+	}
+	return anomalyResult(score, scored, args)
+}
+
+func validateAnomalyFunc(validateParam func(arg ast.Expr) error) funcVal {
+	return func(_ api.FunctionContext, args []ast.Expr) error {
+		l := len(args)
+		if l != 2 && l != 3 {
+			return fmt.Errorf("expect two or three args but got %d", l)
+		}
+		if ast.IsStringArg(args[0]) || ast.IsTimeArg(args[0]) || ast.IsBooleanArg(args[0]) {
+			return ProduceErrInfo(0, "number - float or int")
+		}
+		if err := validateParam(args[1]); err != nil {
+			return err
+		}
+		if l == 3 {
+			if ast.IsStringArg(args[2]) || ast.IsTimeArg(args[2]) || ast.IsBooleanArg(args[2]) {
+				return ProduceErrInfo(2, "number - float or int")
+			}
+		}
+		return nil
+	}
+}
+
+func validateAnomalyWindow(arg ast.Expr) error {
+	if ast.IsFloatArg(arg) || ast.IsTimeArg(arg) || ast.IsBooleanArg(arg) || ast.IsStringArg(arg) || ast.IsFieldRefArg(arg) {
+		return ProduceErrInfo(1, "int")
+	}
+	if i, ok := arg.(*ast.IntegerLiteral); ok && i.Val < 2 {
+		return fmt.Errorf("the window size must be greater than 1")
+	}
+	return nil
+}
+
+// validData returns the when condition result of the analytic function call
+func validData(args []interface{}) bool {
+	b, ok := args[len(args)-2].(bool)
+	return !ok || b
+}
+
+// deviationScore returns the deviation in the unit of the spread. If the spread is 0, the score is only defined
+// when the deviation is 0 too; otherwise, the value is infinitely far from the previous values.
+func deviationScore(deviation, spread, scale float64) (float64, bool) {
+	if spread == 0 {
+		return 0, deviation == 0
+	}
+	return scale * deviation / spread, true
+}
+
+// anomalyResult returns the score, or whether the score exceeds the threshold if the threshold is set
+func anomalyResult(score float64, scored bool, args []interface{}) (interface{}, bool) {
+	if len(args)-2 == 3 {
+		threshold, err := cast.ToFloat64(args[2], cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return fmt.Errorf("the third parameter requires float64 but found %[1]T(%[1]v)", args[2]), false
+		}
+		return !scored || math.Abs(score) > threshold, true
+	}
+	if !scored {
+		return nil, true
+	}
+	return score, true
+}
+
+// ewmaState is the exponentially weighted moving mean and variance
+type ewmaState struct {
+	Mean  float64
+	Var   float64
+	Count int64
+}
+
+func (s *ewmaState) update(x, alpha float64) {
+	if s.Count == 0 {
+		s.Mean = x
+	} else {
+		diff := x - s.Mean
+		incr := alpha * diff
+		s.Mean += incr
+		s.Var = (1 - alpha) * (s.Var + diff*incr)
+	}
+	s.Count++
+}
+
+// rollingWindow keeps the latest values up to the size
+type rollingWindow struct {
+	Data []float64
+	Pos  int
+	Size int
+}
+
+func newRollingWindow(size int) *rollingWindow {
+	return &rollingWindow{
+		Data: make([]float64, 0, size),
+		Size: size,
+	}
+}
+
+func (w *rollingWindow) append(x float64) {
+	if len(w.Data) < w.Size {
+		w.Data = append(w.Data, x)
+		return
+	}
+	w.Data[w.Pos] = x
+	w.Pos = (w.Pos + 1) % w.Size
+}
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,259 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestAnomalyValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		args []ast.Expr
+		err  error
+	}{
+		{
+			name: "ewma_score",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+			},
+			err: fmt.Errorf("expect two or three args but got 1"),
+		}, {
+			name: "ewma_score",
+			args: []ast.Expr{
+				&ast.StringLiteral{Val: "foo"},
+				&ast.NumberLiteral{Val: 0.5},
+			},
+			err: fmt.Errorf("Expect number - float or int type for parameter 1"),
+		}, {
+			name: "ewma_score",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.NumberLiteral{Val: 1.5},
+			},
+			err: fmt.Errorf("the smoothing factor must be greater than 0 and not greater than 1"),
+		}, {
+			name: "ewma_score",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.NumberLiteral{Val: 0.5},
+				&ast.BooleanLiteral{Val: true},
+			},
+			err: fmt.Errorf("Expect number - float or int type for parameter 3"),
+		}, {
+			name: "ewma_score",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.NumberLiteral{Val: 0.5},
+				&ast.IntegerLiteral{Val: 3},
+			},
+		}, {
+			name: "z_score",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.NumberLiteral{Val: 0.5},
+			},
+			err: fmt.Errorf("Expect int type for parameter 2"),
+		}, {
+			name: "z_score",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.IntegerLiteral{Val: 1},
+			},
+			err: fmt.Errorf("the window size must be greater than 1"),
+		}, {
+			name: "z_score",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.IntegerLiteral{Val: 10},
+			},
+		}, {
+			name: "mad_score",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.IntegerLiteral{Val: 10},
+				&ast.NumberLiteral{Val: 3.5},
+			},
+		},
+	}
+	for i, tt := range tests {
+		f, ok := builtins[tt.name]
+		if !ok {
+			t.Fatal("builtin not found")
+		}
+		err := f.val(nil, tt.args)
+		if !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, err, tt.err)
+		}
+	}
+}
+
+func TestEwmaScoreExec(t *testing.T) {
+	f, ok := builtins["ewma_score"]
+	if !ok {
+		t.Fatal("builtin not found")
+	}
+	contextLogger := conf.Log.WithField("rule", "testExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	fctx := kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), 2)
+	tests := []struct {
+		args   []interface{}
+		result interface{}
+	}{
+		{ // 0
+			args:   []interface{}{10, 0.5, true, "self"},
+			result: 0.0,
+		}, { // 1
+			args:   []interface{}{10, 0.5, true, "self"},
+			result: 0.0,
+		}, { // 2
+			args:   []interface{}{12, 0.5, true, "self"},
+			result: nil,
+		}, { // 3
+			args:   []interface{}{nil, 0.5, true, "self"},
+			result: nil,
+		}, { // 4
+			args:   []interface{}{13, 0.5, true, "self"},
+			result: 2.0,
+		}, { // 5
+			args:   []interface{}{10.5, 0.5, false, "self"},
+			result: -1.2247448713915892,
+		}, { // 6
+			args:   []interface{}{12, 0.5, 1, true, "self"},
+			result: false,
+		}, { // 7
+			args:   []interface{}{"foo", 0.5, true, "self"},
+			result: fmt.Errorf("requires float64 but found string(foo)"),
+		},
+	}
+	for i, tt := range tests {
+		result, _ := f.exec(fctx, tt.args)
+		if !reflect.DeepEqual(result, tt.result) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, result, tt.result)
+		}
+	}
+}
+
+func TestZScoreExec(t *testing.T) {
+	f, ok := builtins["z_score"]
+	if !ok {
+		t.Fatal("builtin not found")
+	}
+	contextLogger := conf.Log.WithField("rule", "testExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	fctx := kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), 2)
+	tests := []struct {
+		args   []interface{}
+		result interface{}
+	}{
+		{ // 0
+			args:   []interface{}{10, 2, true, "self"},
+			result: 0.0,
+		}, { // 1
+			args:   []interface{}{12, 2, true, "self"},
+			result: 0.0,
+		}, { // 2
+			args:   []interface{}{14, 2, true, "self"},
+			result: 3.0,
+		}, { // 3
+			args:   []interface{}{nil, 2, true, "self"},
+			result: nil,
+		}, { // 4
+			args:   []interface{}{13, 2, true, "self"},
+			result: 0.0,
+		}, { // 5
+			args:   []interface{}{13, 2, false, "self"},
+			result: -1.0,
+		}, { // 6
+			args:   []interface{}{15, 2, true, "self"},
+			result: 3.0,
+		}, { // 7
+			args:   []interface{}{15, 1, true, "self"},
+			result: fmt.Errorf("the second parameter requires int greater than 1 but found int(1)"),
+		},
+	}
+	for i, tt := range tests {
+		result, _ := f.exec(fctx, tt.args)
+		if !reflect.DeepEqual(result, tt.result) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, result, tt.result)
+		}
+	}
+}
+
+func TestMadScorePartition(t *testing.T) {
+	f, ok := builtins["mad_score"]
+	if !ok {
+		t.Fatal("builtin not found")
+	}
+	contextLogger := conf.Log.WithField("rule", "testExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	fctx := kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), 2)
+	tests := []struct {
+		args   []interface{}
+		result interface{}
+	}{
+		{ // 0
+			args:   []interface{}{10, 3, 3, true, "a"},
+			result: false,
+		}, { // 1
+			args:   []interface{}{11, 3, 3, true, "a"},
+			result: false,
+		}, { // 2
+			args:   []interface{}{12, 3, 3, true, "a"},
+			result: false,
+		}, { // 3
+			args:   []interface{}{30, 3, 3, true, "a"},
+			result: true,
+		}, { // 4
+			args:   []interface{}{30, 3, 3, true, "b"},
+			result: false,
+		}, { // 5
+			args:   []interface{}{11, 3, 3, true, "a"},
+			result: false,
+		}, { // 6
+			args:   []interface{}{5, 3, 3, true, "c"},
+			result: false,
+		}, { // 7
+			args:   []interface{}{5, 3, 3, true, "c"},
+			result: false,
+		}, { // 8
+			args:   []interface{}{5, 3, 3, true, "c"},
+			result: false,
+		}, { // 9
+			args:   []interface{}{6, 3, 3, true, "c"},
+			result: true,
+		}, { // 10
+			args:   []interface{}{12, 3, true, "a"},
+			result: 0.0,
+		},
+	}
+	for i, tt := range tests {
+		result, _ := f.exec(fctx, tt.args)
+		if !reflect.DeepEqual(result, tt.result) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, result, tt.result)
+		}
+	}
+}
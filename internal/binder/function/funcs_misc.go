@@ -487,6 +487,34 @@ func registerMiscFunc() {
 		},
 		val: ValidateJsonFunc,
 	}
+	builtins["jsonata"] = builtinFunc{
+		fType: ast.FuncTypeScalar,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			if args[0] == nil {
+				return nil, true
+			}
+			expr, ok := args[1].(string)
+			if !ok {
+				return fmt.Errorf("invalid jsonata expression, must be a string but got %v", args[1]), false
+			}
+			result, err := evalJsonata(expr, args[0])
+			if err != nil {
+				return err, false
+			}
+			return result, true
+		},
+		val: func(ctx api.FunctionContext, args []ast.Expr) error {
+			if err := ValidateJsonFunc(ctx, args); err != nil {
+				return err
+			}
+			if s, ok := args[1].(*ast.StringLiteral); ok {
+				if _, err := jsonataCache.get(s.Val); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
 	builtins["window_start"] = builtinFunc{
 		fType: ast.FuncTypeScalar,
 		exec:  nil, // directly return in the valuer
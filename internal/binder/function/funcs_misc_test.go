@@ -183,6 +183,78 @@ func TestToJson(t *testing.T) {
 	}
 }
 
+func TestJsonataExec(t *testing.T) {
+	f, ok := builtins["jsonata"]
+	if !ok {
+		t.Fatal("builtin not found")
+	}
+	contextLogger := conf.Log.WithField("rule", "testExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	fctx := kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), 2)
+	order := `{"id":"o1","items":[{"name":"a","price":2,"qty":3},{"name":"b","price":5,"qty":1}]}`
+	tests := []struct {
+		args   []interface{}
+		result interface{}
+	}{
+		{ // 0
+			args:   []interface{}{order, "$sum(items.(price * qty))"},
+			result: float64(11),
+		}, { // 1
+			args:   []interface{}{order, `{"order": id, "count": $count(items)}`},
+			result: map[string]interface{}{"order": "o1", "count": 2},
+		}, { // 2
+			args:   []interface{}{order, "items[price > 3].name"},
+			result: "b",
+		}, { // 3
+			args:   []interface{}{order, "missing"},
+			result: nil,
+		}, { // 4
+			args: []interface{}{
+				map[string]interface{}{
+					"device": map[string]interface{}{"id": "d1"},
+				},
+				"$uppercase(device.id)",
+			},
+			result: "D1",
+		}, { // 5
+			args:   []interface{}{nil, "id"},
+			result: nil,
+		}, { // 6
+			args:   []interface{}{"{foo", "id"},
+			result: fmt.Errorf("data '{foo' is not a valid json string"),
+		}, { // 7
+			args:   []interface{}{order, 1},
+			result: fmt.Errorf("invalid jsonata expression, must be a string but got 1"),
+		},
+	}
+	for i, tt := range tests {
+		result, _ := f.exec(fctx, tt.args)
+		if !reflect.DeepEqual(result, tt.result) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, result, tt.result)
+		}
+	}
+}
+
+func TestJsonataValidation(t *testing.T) {
+	f, ok := builtins["jsonata"]
+	if !ok {
+		t.Fatal("builtin not found")
+	}
+	err := f.val(nil, []ast.Expr{&ast.FieldRef{Name: "foo"}})
+	if !reflect.DeepEqual(err, fmt.Errorf("Expect 2 arguments but found 1.")) {
+		t.Errorf("unexpected error %v", err)
+	}
+	err = f.val(nil, []ast.Expr{&ast.FieldRef{Name: "foo"}, &ast.StringLiteral{Val: "$sum(items.price)"}})
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	err = f.val(nil, []ast.Expr{&ast.FieldRef{Name: "foo"}, &ast.StringLiteral{Val: "items[price >"}})
+	if err == nil {
+		t.Errorf("expect invalid expression error")
+	}
+}
+
 func TestFromJson(t *testing.T) {
 	f, ok := builtins["parse_json"]
 	if !ok {
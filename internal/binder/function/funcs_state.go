@@ -0,0 +1,208 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"encoding/gob"
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const stateKeyPrefix = "$$state_"
+
+func init() {
+	gob.Register(&stateEntry{})
+}
+
+// stateEntry is the value saved by the state functions
+type stateEntry struct {
+	Value interface{}
+	// Expire is the unix milli time when the value expires, 0 means never
+	Expire int64
+}
+
+// registerStateFunc registers the functions to access the keyed values in the state of the rule node.
+// Unlike the other stateful functions, the state is shared by all the state function calls in the node by the key,
+// so that one call can read the value put by another one. The state is checkpointed along with the node state.
+func registerStateFunc() {
+	builtins["state_get"] = builtinFunc{
+		fType: ast.FuncTypeScalar,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			key, err := stateKey(args[0])
+			if err != nil {
+				return err, false
+			}
+			e, err := getStateEntry(ctx, key)
+			if err != nil {
+				return err, false
+			}
+			if e == nil {
+				if len(args) > 1 {
+					return args[1], true
+				}
+				return nil, true
+			}
+			return e.Value, true
+		},
+		val: func(_ api.FunctionContext, args []ast.Expr) error {
+			if len(args) != 1 && len(args) != 2 {
+				return fmt.Errorf("Expect one or two arguments but found %d.", len(args))
+			}
+			return validateStateKey(args[0])
+		},
+	}
+	builtins["state_put"] = builtinFunc{
+		fType: ast.FuncTypeScalar,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			key, err := stateKey(args[0])
+			if err != nil {
+				return err, false
+			}
+			e := &stateEntry{Value: args[1]}
+			if len(args) > 2 && args[2] != nil {
+				ttl, err := cast.ToInt64(args[2], cast.CONVERT_SAMEKIND)
+				if err != nil || ttl < 0 {
+					return fmt.Errorf("the ttl must be a non-negative bigint but got %v", args[2]), false
+				}
+				if ttl > 0 {
+					e.Expire = conf.GetNowInMilli() + ttl
+				}
+			}
+			if err := putStateEntry(ctx, key, e); err != nil {
+				return err, false
+			}
+			return args[1], true
+		},
+		val: func(_ api.FunctionContext, args []ast.Expr) error {
+			if len(args) != 2 && len(args) != 3 {
+				return fmt.Errorf("Expect two or three arguments but found %d.", len(args))
+			}
+			if err := validateStateKey(args[0]); err != nil {
+				return err
+			}
+			if len(args) == 3 {
+				if ast.IsFloatArg(args[2]) || ast.IsStringArg(args[2]) || ast.IsTimeArg(args[2]) || ast.IsBooleanArg(args[2]) {
+					return ProduceErrInfo(2, "int")
+				}
+			}
+			return nil
+		},
+	}
+	builtins["state_incr"] = builtinFunc{
+		fType: ast.FuncTypeScalar,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			key, err := stateKey(args[0])
+			if err != nil {
+				return err, false
+			}
+			var amount int64 = 1
+			if len(args) > 1 {
+				amount, err = cast.ToInt64(args[1], cast.CONVERT_SAMEKIND)
+				if err != nil {
+					return fmt.Errorf("the amount must be a bigint but got %v", args[1]), false
+				}
+			}
+			e, err := getStateEntry(ctx, key)
+			if err != nil {
+				return err, false
+			}
+			if e == nil {
+				e = &stateEntry{Value: int64(0)}
+			}
+			v, err := cast.ToInt64(e.Value, cast.CONVERT_SAMEKIND)
+			if err != nil {
+				return fmt.Errorf("the state %s is not a bigint but %v", key, e.Value), false
+			}
+			// keep the expire time of the value
+			e = &stateEntry{Value: v + amount, Expire: e.Expire}
+			if err := putStateEntry(ctx, key, e); err != nil {
+				return err, false
+			}
+			return e.Value, true
+		},
+		val: func(_ api.FunctionContext, args []ast.Expr) error {
+			if len(args) != 1 && len(args) != 2 {
+				return fmt.Errorf("Expect one or two arguments but found %d.", len(args))
+			}
+			if err := validateStateKey(args[0]); err != nil {
+				return err
+			}
+			if len(args) == 2 {
+				if ast.IsFloatArg(args[1]) || ast.IsStringArg(args[1]) || ast.IsTimeArg(args[1]) || ast.IsBooleanArg(args[1]) {
+					return ProduceErrInfo(1, "int")
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func validateStateKey(arg ast.Expr) error {
+	if ast.IsNumericArg(arg) || ast.IsTimeArg(arg) || ast.IsBooleanArg(arg) {
+		return ProduceErrInfo(0, "string")
+	}
+	return nil
+}
+
+func stateKey(arg interface{}) (string, error) {
+	if arg == nil {
+		return "", fmt.Errorf("the state key must not be null")
+	}
+	key, err := cast.ToString(arg, cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return "", fmt.Errorf("the state key must be a string but got %v", arg)
+	}
+	return key, nil
+}
+
+// nodeContext returns the context of the node without the function id, so that the state is shared by the key
+func nodeContext(ctx api.FunctionContext) api.StreamContext {
+	if fctx, ok := ctx.(*kctx.DefaultFuncContext); ok {
+		return fctx.StreamContext
+	}
+	return ctx
+}
+
+// getStateEntry returns the state of the key, or nil if it does not exist or has expired
+func getStateEntry(ctx api.FunctionContext, key string) (*stateEntry, error) {
+	sctx := nodeContext(ctx)
+	v, err := sctx.GetState(stateKeyPrefix + key)
+	if err != nil {
+		return nil, fmt.Errorf("error getting state for %s: %v", key, err)
+	}
+	e, ok := v.(*stateEntry)
+	if !ok {
+		return nil, nil
+	}
+	if e.Expire > 0 && e.Expire <= conf.GetNowInMilli() {
+		if err := sctx.DeleteState(stateKeyPrefix + key); err != nil {
+			return nil, fmt.Errorf("error deleting state for %s: %v", key, err)
+		}
+		return nil, nil
+	}
+	return e, nil
+}
+
+func putStateEntry(ctx api.FunctionContext, key string, e *stateEntry) error {
+	if err := nodeContext(ctx).PutState(stateKeyPrefix+key, e); err != nil {
+		return fmt.Errorf("error setting state for %s: %v", key, err)
+	}
+	return nil
+}
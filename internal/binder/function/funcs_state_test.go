@@ -0,0 +1,199 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestStateFuncValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		args []ast.Expr
+		err  error
+	}{
+		{
+			name: "state_get",
+			args: []ast.Expr{},
+			err:  fmt.Errorf("Expect one or two arguments but found 0."),
+		}, {
+			name: "state_get",
+			args: []ast.Expr{
+				&ast.IntegerLiteral{Val: 1},
+			},
+			err: fmt.Errorf("Expect string type for parameter 1"),
+		}, {
+			name: "state_get",
+			args: []ast.Expr{
+				&ast.StringLiteral{Val: "foo"},
+				&ast.IntegerLiteral{Val: 0},
+			},
+		}, {
+			name: "state_put",
+			args: []ast.Expr{
+				&ast.StringLiteral{Val: "foo"},
+			},
+			err: fmt.Errorf("Expect two or three arguments but found 1."),
+		}, {
+			name: "state_put",
+			args: []ast.Expr{
+				&ast.StringLiteral{Val: "foo"},
+				&ast.FieldRef{Name: "bar"},
+				&ast.StringLiteral{Val: "1m"},
+			},
+			err: fmt.Errorf("Expect int type for parameter 3"),
+		}, {
+			name: "state_put",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.FieldRef{Name: "bar"},
+				&ast.IntegerLiteral{Val: 60000},
+			},
+		}, {
+			name: "state_incr",
+			args: []ast.Expr{
+				&ast.StringLiteral{Val: "foo"},
+				&ast.NumberLiteral{Val: 1.5},
+			},
+			err: fmt.Errorf("Expect int type for parameter 2"),
+		}, {
+			name: "state_incr",
+			args: []ast.Expr{
+				&ast.StringLiteral{Val: "foo"},
+			},
+		},
+	}
+	for i, tt := range tests {
+		f, ok := builtins[tt.name]
+		if !ok {
+			t.Fatalf("builtin %s not found", tt.name)
+		}
+		err := f.val(nil, tt.args)
+		if !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, err, tt.err)
+		}
+	}
+}
+
+func TestStateFuncExec(t *testing.T) {
+	conf.InitClock()
+	mc := conf.Clock.(*clock.Mock)
+	contextLogger := conf.Log.WithField("rule", "testExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	nctx := ctx.WithMeta("mockRule0", "test", tempStore)
+	// the function calls have different function ids, but they share the state by the key
+	getCtx := kctx.NewDefaultFuncContext(nctx, 1)
+	putCtx := kctx.NewDefaultFuncContext(nctx, 2)
+	incrCtx := kctx.NewDefaultFuncContext(nctx, 3)
+	tests := []struct {
+		name    string
+		ctx     api.FunctionContext
+		args    []interface{}
+		advance time.Duration
+		result  interface{}
+	}{
+		{ // 0
+			name:   "state_get",
+			ctx:    getCtx,
+			args:   []interface{}{"last"},
+			result: nil,
+		}, { // 1
+			name:   "state_get",
+			ctx:    getCtx,
+			args:   []interface{}{"last", "none"},
+			result: "none",
+		}, { // 2
+			name:   "state_put",
+			ctx:    putCtx,
+			args:   []interface{}{"last", "a"},
+			result: "a",
+		}, { // 3
+			name:   "state_get",
+			ctx:    getCtx,
+			args:   []interface{}{"last", "none"},
+			result: "a",
+		}, { // 4
+			name:   "state_incr",
+			ctx:    incrCtx,
+			args:   []interface{}{"count"},
+			result: int64(1),
+		}, { // 5
+			name:   "state_incr",
+			ctx:    incrCtx,
+			args:   []interface{}{"count", 5},
+			result: int64(6),
+		}, { // 6
+			name:   "state_get",
+			ctx:    getCtx,
+			args:   []interface{}{"count"},
+			result: int64(6),
+		}, { // 7
+			name:   "state_incr",
+			ctx:    incrCtx,
+			args:   []interface{}{"last"},
+			result: fmt.Errorf("the state last is not a bigint but a"),
+		}, { // 8
+			name:   "state_put",
+			ctx:    putCtx,
+			args:   []interface{}{"seen", true, 1000},
+			result: true,
+		}, { // 9
+			name:    "state_get",
+			ctx:     getCtx,
+			args:    []interface{}{"seen", false},
+			advance: 500 * time.Millisecond,
+			result:  true,
+		}, { // 10
+			name:    "state_get",
+			ctx:     getCtx,
+			args:    []interface{}{"seen", false},
+			advance: 500 * time.Millisecond,
+			result:  false,
+		}, { // 11
+			name:   "state_put",
+			ctx:    putCtx,
+			args:   []interface{}{nil, 1},
+			result: fmt.Errorf("the state key must not be null"),
+		}, { // 12
+			name:   "state_put",
+			ctx:    putCtx,
+			args:   []interface{}{"seen", true, -1},
+			result: fmt.Errorf("the ttl must be a non-negative bigint but got -1"),
+		},
+	}
+	for i, tt := range tests {
+		f, ok := builtins[tt.name]
+		if !ok {
+			t.Fatalf("builtin %s not found", tt.name)
+		}
+		mc.Add(tt.advance)
+		result, _ := f.exec(tt.ctx, tt.args)
+		if !reflect.DeepEqual(result, tt.result) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, result, tt.result)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
@@ -142,6 +143,13 @@ func registerStrFunc() {
 				return err, false
 			}
 			arg1 := cast.ToStringAlways(args[1])
+			if len(args) > 2 {
+				loc, err := time.LoadLocation(cast.ToStringAlways(args[2]))
+				if err != nil {
+					return fmt.Errorf("invalid time zone %v: %v", args[2], err), false
+				}
+				arg0 = arg0.In(loc)
+			}
 			if s, err := cast.FormatTime(arg0, arg1); err == nil {
 				return s, true
 			} else {
@@ -149,8 +157,8 @@ func registerStrFunc() {
 			}
 		},
 		val: func(_ api.FunctionContext, args []ast.Expr) error {
-			if err := ValidateLen(2, len(args)); err != nil {
-				return err
+			if len(args) != 2 && len(args) != 3 {
+				return fmt.Errorf("Expect two or three arguments but found %d.", len(args))
 			}
 
 			if ast.IsNumericArg(args[0]) || ast.IsStringArg(args[0]) || ast.IsBooleanArg(args[0]) {
@@ -159,6 +167,16 @@ func registerStrFunc() {
 			if ast.IsNumericArg(args[1]) || ast.IsTimeArg(args[1]) || ast.IsBooleanArg(args[1]) {
 				return ProduceErrInfo(1, "string")
 			}
+			if len(args) == 3 {
+				if ast.IsNumericArg(args[2]) || ast.IsTimeArg(args[2]) || ast.IsBooleanArg(args[2]) {
+					return ProduceErrInfo(2, "string")
+				}
+				if s, ok := args[2].(*ast.StringLiteral); ok {
+					if _, err := time.LoadLocation(s.Val); err != nil {
+						return fmt.Errorf("invalid time zone %s: %v", s.Val, err)
+					}
+				}
+			}
 			return nil
 		},
 	}
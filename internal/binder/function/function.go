@@ -46,10 +46,12 @@ func init() {
 	registerStrFunc()
 	registerMiscFunc()
 	registerAnalyticFunc()
+	registerAnomalyFunc()
 	registerColsFunc()
 	registerSetReturningFunc()
 	registerArrayFunc()
 	registerObjectFunc()
+	registerStateFunc()
 }
 
 //var funcWithAsteriskSupportMap = map[string]string{
@@ -62,6 +64,9 @@ var analyticFuncs = map[string]struct{}{
 	"changed_col": {},
 	"had_changed": {},
 	"latest":      {},
+	"ewma_score":  {},
+	"z_score":     {},
+	"mad_score":   {},
 }
 
 const AnalyticPrefix = "$$a"
@@ -0,0 +1,99 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/blues/jsonata-go"
+
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// jsonataCacheSize limits the compiled expressions to keep. The expression is usually a constant, so the cache
+// is only reset when the expressions are generated dynamically from the data.
+const jsonataCacheSize = 1024
+
+var jsonataCache = &exprCache{
+	exprs: make(map[string]*jsonata.Expr),
+}
+
+type exprCache struct {
+	sync.RWMutex
+	exprs map[string]*jsonata.Expr
+}
+
+func (c *exprCache) get(expr string) (*jsonata.Expr, error) {
+	c.RLock()
+	e, ok := c.exprs[expr]
+	c.RUnlock()
+	if ok {
+		return e, nil
+	}
+	e, err := jsonata.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jsonata expression %s: %v", expr, err)
+	}
+	c.Lock()
+	if len(c.exprs) >= jsonataCacheSize {
+		c.exprs = make(map[string]*jsonata.Expr)
+	}
+	c.exprs[expr] = e
+	c.Unlock()
+	return e, nil
+}
+
+// evalJsonata evaluates the jsonata expression against the data which can be a map, an array or a json string.
+// If the expression matches nothing, the result is nil.
+func evalJsonata(expr string, data interface{}) (interface{}, error) {
+	e, err := jsonataCache.get(expr)
+	if err != nil {
+		return nil, err
+	}
+	var input interface{}
+	at := reflect.TypeOf(data)
+	if at == nil {
+		return nil, fmt.Errorf("invalid data nil for jsonata")
+	}
+	switch at.Kind() {
+	case reflect.Map:
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid data %v for jsonata", data)
+		}
+		input = cast.ConvertToInterfaceArr(m)
+	case reflect.Slice:
+		input = cast.ConvertSlice(data)
+	case reflect.String:
+		err := json.Unmarshal([]byte(reflect.ValueOf(data).String()), &input)
+		if err != nil {
+			return nil, fmt.Errorf("data '%v' is not a valid json string", data)
+		}
+	default:
+		return nil, fmt.Errorf("invalid data %v for jsonata", data)
+	}
+	result, err := e.Eval(input)
+	if err != nil {
+		if errors.Is(err, jsonata.ErrUndefined) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return result, nil
+}
@@ -66,6 +66,11 @@ func TestBindings(t *testing.T) {
 			isSource:       false,
 			isLookupSource: true,
 			isSink:         true,
+		}, {
+			name:           "httplookup",
+			isSource:       false,
+			isLookupSource: true,
+			isSink:         false,
 		},
 	}
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
@@ -22,7 +22,7 @@ import (
 )
 
 func BenchmarkCompressor(b *testing.B) {
-	compressors := []string{ZLIB, GZIP, FLATE, ZSTD}
+	compressors := []string{ZLIB, GZIP, FLATE, ZSTD, LZ4}
 
 	data, err := os.ReadFile("test.json")
 	if err != nil {
@@ -56,7 +56,7 @@ func BenchmarkCompressor(b *testing.B) {
 }
 
 func BenchmarkDecompressor(b *testing.B) {
-	compressors := []string{ZLIB, GZIP, FLATE, ZSTD}
+	compressors := []string{ZLIB, GZIP, FLATE, ZSTD, LZ4}
 
 	data, err := os.ReadFile("test.json")
 	if err != nil {
@@ -100,7 +100,7 @@ func TestCompressionRatio(t *testing.T) {
 		t.Fatalf("failed to read test file: %v", err)
 	}
 
-	compressors := []string{ZLIB, GZIP, FLATE, ZSTD}
+	compressors := []string{ZLIB, GZIP, FLATE, ZSTD, LZ4}
 
 	for _, c := range compressors {
 		wc, err := GetCompressor(c)
@@ -141,6 +141,11 @@ func TestGetCompressor(t *testing.T) {
 			compressor:    "zstd",
 			expectedError: false,
 		},
+		{
+			name:          "valid compressor lz4",
+			compressor:    "lz4",
+			expectedError: false,
+		},
 		{
 			name:          "unsupported compressor",
 			compressor:    "invalid",
@@ -181,7 +186,7 @@ func TestCompressAndDecompress(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			for _, name := range []string{ZLIB, GZIP, FLATE, ZSTD} {
+			for _, name := range []string{ZLIB, GZIP, FLATE, ZSTD, LZ4} {
 				compr, err := GetCompressor(name)
 				if err != nil {
 					t.Fatalf("get compressor failed: %v", err)
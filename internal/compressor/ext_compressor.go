@@ -19,6 +19,7 @@ package compressor
 import (
 	"github.com/lf-edge/ekuiper/internal/compressor/flate"
 	"github.com/lf-edge/ekuiper/internal/compressor/gzip"
+	"github.com/lf-edge/ekuiper/internal/compressor/lz4"
 	"github.com/lf-edge/ekuiper/internal/compressor/zlib"
 	"github.com/lf-edge/ekuiper/internal/compressor/zstd"
 	"github.com/lf-edge/ekuiper/pkg/message"
@@ -29,6 +30,7 @@ const (
 	GZIP  = "gzip"
 	FLATE = "flate"
 	ZSTD  = "zstd"
+	LZ4   = "lz4"
 )
 
 func init() {
@@ -44,7 +46,11 @@ func init() {
 	compressors[ZSTD] = func(name string) (message.Compressor, error) {
 		return zstd.NewZstdCompressor()
 	}
+	compressors[LZ4] = func(name string) (message.Compressor, error) {
+		return lz4.NewLz4Compressor()
+	}
 
 	compressWriters[GZIP] = gzip.NewWriter
 	compressWriters[ZSTD] = zstd.NewWriter
+	compressWriters[LZ4] = lz4.NewWriter
 }
@@ -19,6 +19,7 @@ package compressor
 import (
 	"github.com/lf-edge/ekuiper/internal/compressor/flate"
 	"github.com/lf-edge/ekuiper/internal/compressor/gzip"
+	"github.com/lf-edge/ekuiper/internal/compressor/lz4"
 	"github.com/lf-edge/ekuiper/internal/compressor/zlib"
 	"github.com/lf-edge/ekuiper/internal/compressor/zstd"
 	"github.com/lf-edge/ekuiper/pkg/message"
@@ -37,7 +38,11 @@ func init() {
 	decompressors[ZSTD] = func(name string) (message.Decompressor, error) {
 		return zstd.NewzstdDecompressor()
 	}
+	decompressors[LZ4] = func(name string) (message.Decompressor, error) {
+		return lz4.NewLz4Decompressor()
+	}
 
 	decompressReaders[GZIP] = gzip.NewReader
 	decompressReaders[ZSTD] = zstd.NewReader
+	decompressReaders[LZ4] = lz4.NewReader
 }
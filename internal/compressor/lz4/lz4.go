@@ -0,0 +1,73 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+func NewLz4Compressor() (*lz4Compressor, error) {
+	return &lz4Compressor{
+		writer: lz4.NewWriter(nil),
+	}, nil
+}
+
+type lz4Compressor struct {
+	writer *lz4.Writer
+	buffer bytes.Buffer
+}
+
+func (l *lz4Compressor) Compress(data []byte) ([]byte, error) {
+	l.buffer.Reset()
+	l.writer.Reset(&l.buffer)
+	_, err := l.writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = l.writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return l.buffer.Bytes(), nil
+}
+
+func NewLz4Decompressor() (*lz4Decompressor, error) {
+	return &lz4Decompressor{reader: lz4.NewReader(nil)}, nil
+}
+
+type lz4Decompressor struct {
+	reader *lz4.Reader
+}
+
+func (l *lz4Decompressor) Decompress(data []byte) ([]byte, error) {
+	l.reader.Reset(bytes.NewReader(data))
+	result, err := io.ReadAll(l.reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %v", err)
+	}
+	return result, nil
+}
+
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(lz4.NewReader(r)), nil
+}
+
+func NewWriter(w io.Writer) (io.Writer, error) {
+	return lz4.NewWriter(w), nil
+}
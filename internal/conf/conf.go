@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/lestrrat-go/file-rotatelogs"
+	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
 
 	"github.com/lf-edge/ekuiper/pkg/api"
@@ -47,6 +48,10 @@ type SinkConf struct {
 	EnableCache          bool `json:"enableCache" yaml:"enableCache"`
 	ResendInterval       int  `json:"resendInterval" yaml:"resendInterval"`
 	CleanCacheAtStop     bool `json:"cleanCacheAtStop" yaml:"cleanCacheAtStop"`
+	// StoreAndForward saves all the data to a disk queue before sending when the cache is enabled
+	StoreAndForward bool `json:"storeAndForward" yaml:"storeAndForward"`
+	// MaxCacheAge is the max milliseconds the data can stay in the store-and-forward queue, 0 means no limit
+	MaxCacheAge int `json:"maxCacheAge" yaml:"maxCacheAge"`
 }
 
 // Validate the configuration and reset to the default value for invalid values.
@@ -72,6 +77,11 @@ func (sc *SinkConf) Validate() error {
 		Log.Warnf("resendInterval is less than 0, set to 0")
 		errs = errors.Join(errs, errors.New("resendInterval:resendInterval must be positive"))
 	}
+	if sc.MaxCacheAge < 0 {
+		sc.MaxCacheAge = 0
+		Log.Warnf("maxCacheAge is less than 0, set to 0")
+		errs = errors.Join(errs, errors.New("maxCacheAge:maxCacheAge must be positive"))
+	}
 	if sc.BufferPageSize > sc.MemoryCacheThreshold {
 		sc.MemoryCacheThreshold = sc.BufferPageSize
 		Log.Warnf("memoryCacheThreshold is less than bufferPageSize, set to %d", sc.BufferPageSize)
@@ -114,10 +124,195 @@ func (sc *SourceConf) Validate() error {
 	return errs
 }
 
+// CheckpointRemoteConf is the remote storage to mirror the checkpoints, so that the state can be restored on another
+// device which connects to the same storage.
+type CheckpointRemoteConf struct {
+	// Type is s3 or sftp. Empty means the checkpoints are kept locally only.
+	Type string `yaml:"type"`
+	// Prefix is the key prefix or the directory of the checkpoint files in the remote storage
+	Prefix string `yaml:"prefix"`
+	S3     struct {
+		Endpoint  string `yaml:"endpoint"`
+		Region    string `yaml:"region"`
+		Bucket    string `yaml:"bucket"`
+		AccessKey string `yaml:"accessKey"`
+		SecretKey string `yaml:"secretKey"`
+		PathStyle bool   `yaml:"pathStyle"`
+	} `yaml:"s3"`
+	Sftp struct {
+		Host       string `yaml:"host"`
+		Port       int    `yaml:"port"`
+		Username   string `yaml:"username"`
+		Password   string `yaml:"password"`
+		PrivateKey string `yaml:"privateKey"`
+		HostKey    string `yaml:"hostKey"`
+	} `yaml:"sftp"`
+}
+
+// Validate the configuration and disable the remote storage for invalid values.
+func (cc *CheckpointRemoteConf) Validate() error {
+	var errs error
+	switch cc.Type {
+	case "":
+		return nil
+	case "s3":
+		if cc.S3.Bucket == "" {
+			errs = errors.Join(errs, errors.New("invalidCheckpointRemote:s3 bucket is required"))
+		}
+		if cc.S3.Endpoint == "" {
+			cc.S3.Endpoint = "https://s3.amazonaws.com"
+		}
+		if cc.S3.Region == "" {
+			cc.S3.Region = "us-east-1"
+		}
+	case "sftp":
+		if cc.Sftp.Host == "" || cc.Sftp.Username == "" {
+			errs = errors.Join(errs, errors.New("invalidCheckpointRemote:sftp host and username are required"))
+		}
+		if cc.Sftp.Port <= 0 {
+			cc.Sftp.Port = 22
+		}
+	default:
+		errs = errors.Join(errs, fmt.Errorf("invalidCheckpointRemote:unknown checkpoint remote type %s", cc.Type))
+	}
+	if errs != nil {
+		Log.Warnf("invalid store.checkpointRemote configuration, keep the checkpoints locally only: %v", errs)
+		cc.Type = ""
+		return errs
+	}
+	if cc.Prefix == "" {
+		cc.Prefix = "ekuiper/checkpoints"
+	}
+	return nil
+}
+
 type SQLConf struct {
 	MaxConnections int `yaml:"maxConnections"`
 }
 
+// SchemaRegistryConf is the external schema registry to fetch the schemas by subject and version
+type SchemaRegistryConf struct {
+	// Type is confluent or apicurio. Empty means no external registry.
+	Type     string `yaml:"type"`
+	Url      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Timeout is the timeout of the requests in milliseconds
+	Timeout int `yaml:"timeout"`
+}
+
+// Validate the configuration and disable the external registry for invalid values.
+func (sc *SchemaRegistryConf) Validate() error {
+	var errs error
+	switch sc.Type {
+	case "":
+		return nil
+	case "confluent", "apicurio":
+		if sc.Url == "" {
+			errs = errors.Join(errs, errors.New("invalidSchemaRegistry:url is required"))
+		}
+	default:
+		errs = errors.Join(errs, fmt.Errorf("invalidSchemaRegistry:unknown schema registry type %s", sc.Type))
+	}
+	if errs != nil {
+		Log.Warnf("invalid schemaRegistry configuration, disable the external schema registry: %v", errs)
+		sc.Type = ""
+		return errs
+	}
+	if sc.Timeout <= 0 {
+		sc.Timeout = 5000
+	}
+	return nil
+}
+
+// TracerConf is the configuration to export the spans of the rules by OpenTelemetry protocol
+type TracerConf struct {
+	Enable bool `yaml:"enable"`
+	// Endpoint is the base url of the OTLP/HTTP receiver such as http://localhost:4318
+	Endpoint    string `yaml:"endpoint"`
+	ServiceName string `yaml:"serviceName"`
+	// SamplingRatio is the ratio of the operations to record in range (0, 1]
+	SamplingRatio float64 `yaml:"samplingRatio"`
+	BatchSize     int     `yaml:"batchSize"`
+	// ExportInterval is the max interval in milliseconds to export the spans
+	ExportInterval int `yaml:"exportInterval"`
+}
+
+// Validate the configuration and disable the tracer for invalid values.
+func (tc *TracerConf) Validate() error {
+	if !tc.Enable {
+		return nil
+	}
+	var errs error
+	if tc.Endpoint == "" {
+		errs = errors.Join(errs, errors.New("invalidTracer:endpoint is required"))
+	}
+	if tc.SamplingRatio < 0 || tc.SamplingRatio > 1 {
+		errs = errors.Join(errs, fmt.Errorf("invalidTracer:samplingRatio %v must be in range [0, 1]", tc.SamplingRatio))
+	}
+	if errs != nil {
+		Log.Warnf("invalid tracer configuration, disable the tracer: %v", errs)
+		tc.Enable = false
+		return errs
+	}
+	if tc.ServiceName == "" {
+		tc.ServiceName = "ekuiper"
+	}
+	if tc.SamplingRatio == 0 {
+		tc.SamplingRatio = 1
+	}
+	if tc.BatchSize <= 0 {
+		tc.BatchSize = 512
+	}
+	if tc.ExportInterval <= 0 {
+		tc.ExportInterval = 5000
+	}
+	return nil
+}
+
+// AuditConf is the configuration to record the management api calls
+type AuditConf struct {
+	Enable bool `yaml:"enable"`
+	// MaxRecords is the max number of the records to keep, the oldest records are dropped when exceeded
+	MaxRecords int `yaml:"maxRecords"`
+	// Topic is the memory topic to publish the records, so that they can be exported by a rule with the memory source
+	Topic string `yaml:"topic"`
+}
+
+// Validate sets the default values
+func (ac *AuditConf) Validate() error {
+	if ac.MaxRecords <= 0 {
+		ac.MaxRecords = 10000
+	}
+	return nil
+}
+
+// RuleEventConf is the configuration to publish the rule lifecycle events such as started, stopped and exception
+type RuleEventConf struct {
+	Enable bool `yaml:"enable"`
+	// Topic is the memory topic to publish the events, so that a rule can consume them with the memory source
+	Topic string `yaml:"topic"`
+	// MqttTopic is the optional mqtt topic to publish the events to. The connection is defined by the MqttProps such as
+	// server, username and password, which are the same as the mqtt sink properties
+	MqttTopic string                 `yaml:"mqttTopic"`
+	MqttQos   int                    `yaml:"mqttQos"`
+	MqttProps map[string]interface{} `yaml:"mqttProps"`
+}
+
+// Validate sets the default values
+func (rc *RuleEventConf) Validate() error {
+	if rc.Topic == "" {
+		rc.Topic = "$$rule_events"
+	}
+	if rc.MqttQos < 0 || rc.MqttQos > 2 {
+		err := fmt.Errorf("invalid ruleEvent.mqttQos %d, must be 0, 1 or 2", rc.MqttQos)
+		Log.Warnf("%v, set to 0", err)
+		rc.MqttQos = 0
+		return err
+	}
+	return nil
+}
+
 type KuiperConf struct {
 	Basic struct {
 		Debug          bool     `yaml:"debug"`
@@ -136,6 +331,9 @@ type KuiperConf struct {
 		Authentication bool     `yaml:"authentication"`
 		IgnoreCase     bool     `yaml:"ignoreCase"`
 		SQLConf        *SQLConf `yaml:"sql"`
+		// AesKey is the base64 encoded key to encrypt the sink payloads and decrypt the source payloads.
+		// It can refer to an environment variable by env:NAME or a key file by file:PATH
+		AesKey string `yaml:"aesKey"`
 	}
 	Rule   api.RuleOption
 	Sink   *SinkConf
@@ -153,11 +351,16 @@ type KuiperConf struct {
 		Sqlite struct {
 			Name string `yaml:"name"`
 		}
+		CheckpointRemote CheckpointRemoteConf `yaml:"checkpointRemote"`
 	}
 	Portable struct {
 		PythonBin   string `yaml:"pythonBin"`
 		InitTimeout int    `yaml:"initTimeout"`
 	}
+	SchemaRegistry SchemaRegistryConf `yaml:"schemaRegistry"`
+	Tracer         TracerConf         `yaml:"tracer"`
+	Audit          AuditConf          `yaml:"audit"`
+	RuleEvent      RuleEventConf      `yaml:"ruleEvent"`
 }
 
 func InitConf() {
@@ -234,6 +437,11 @@ func InitConf() {
 	if Config.Store.ExtStateType == "" {
 		Config.Store.ExtStateType = "sqlite"
 	}
+	_ = Config.Store.CheckpointRemote.Validate()
+	_ = Config.SchemaRegistry.Validate()
+	_ = Config.Tracer.Validate()
+	_ = Config.Audit.Validate()
+	_ = Config.RuleEvent.Validate()
 
 	if Config.Portable.PythonBin == "" {
 		Config.Portable.PythonBin = "python"
@@ -275,6 +483,11 @@ func ValidateRuleOption(option *api.RuleOption) error {
 		Log.Warnf("lateTol is negative, set to 1000")
 		errs = errors.Join(errs, errors.New("invalidLateTol:lateTol must be greater than 0"))
 	}
+	if option.IdleTimeout < 0 {
+		option.IdleTimeout = 0
+		Log.Warnf("idleTimeout is negative, set to 0")
+		errs = errors.Join(errs, errors.New("invalidIdleTimeout:idleTimeout must be greater than 0"))
+	}
 	if option.Restart != nil {
 		if option.Restart.Multiplier <= 0 {
 			option.Restart.Multiplier = 2
@@ -302,6 +515,76 @@ func ValidateRuleOption(option *api.RuleOption) error {
 			errs = errors.Join(errs, errors.New("invalidRestartJitterFactor:restart jitterFactor must between [0, 1)"))
 		}
 	}
+	if option.Quota != nil {
+		if option.Quota.MaxBufferedTuples < 0 || option.Quota.MaxWindowMemory < 0 || option.Quota.MaxEventsPerSecond < 0 {
+			errs = errors.Join(errs, errors.New("invalidQuota:quota limits must not be negative"))
+		}
+		switch option.Quota.Policy {
+		case "":
+			option.Quota.Policy = api.QuotaPolicyPauseSource
+		case api.QuotaPolicyPauseSource, api.QuotaPolicyDropOldest, api.QuotaPolicyStopRule:
+		default:
+			errs = errors.Join(errs, fmt.Errorf("invalidQuotaPolicy:quota policy must be one of %s, %s and %s", api.QuotaPolicyPauseSource, api.QuotaPolicyDropOldest, api.QuotaPolicyStopRule))
+		}
+	}
+	if option.AutoScale != nil {
+		if option.AutoScale.MaxConcurrency < option.Concurrency {
+			errs = errors.Join(errs, errors.New("invalidAutoScale:autoScale maxConcurrency must not be less than concurrency"))
+		}
+		if option.AutoScale.Interval < 0 {
+			errs = errors.Join(errs, errors.New("invalidAutoScale:autoScale interval must not be negative"))
+		} else if option.AutoScale.Interval == 0 {
+			option.AutoScale.Interval = 1000
+		}
+		if option.AutoScale.MaxCpu < 0 || option.AutoScale.MaxCpu > 100 {
+			errs = errors.Join(errs, errors.New("invalidAutoScale:autoScale maxCpu must between [0, 100]"))
+		} else if option.AutoScale.MaxCpu == 0 {
+			option.AutoScale.MaxCpu = 80
+		}
+	}
+	if option.StateBackend != nil {
+		switch option.StateBackend.Type {
+		case "":
+			option.StateBackend.Type = api.StateBackendMemory
+		case api.StateBackendMemory:
+		case api.StateBackendDisk:
+			if option.Qos >= api.AtLeastOnce {
+				errs = errors.Join(errs, errors.New("invalidStateBackend:disk state backend does not support qos"))
+			}
+		default:
+			errs = errors.Join(errs, fmt.Errorf("invalidStateBackend:state backend type must be %s or %s", api.StateBackendMemory, api.StateBackendDisk))
+		}
+		if option.StateBackend.SpillThreshold < 0 {
+			errs = errors.Join(errs, errors.New("invalidStateBackend:state backend spillThreshold must not be negative"))
+		} else if option.StateBackend.SpillThreshold == 0 {
+			option.StateBackend.SpillThreshold = 10000
+		}
+	}
+	if option.DeadLetter != nil {
+		if len(option.DeadLetter) != 1 {
+			errs = errors.Join(errs, errors.New("invalidDeadLetter:deadLetter must have exactly one sink"))
+		} else {
+			for name, props := range option.DeadLetter {
+				if _, ok := props.(map[string]interface{}); !ok {
+					errs = errors.Join(errs, fmt.Errorf("invalidDeadLetter:expect map[string]interface{} type for the deadLetter sink %s properties", name))
+				}
+			}
+		}
+	}
+	if option.Cron != "" || option.Duration != "" {
+		if option.Cron == "" || option.Duration == "" {
+			errs = errors.Join(errs, errors.New("invalidSchedule:cron and duration must be set together"))
+		} else {
+			if _, err := cron.ParseStandard(option.Cron); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("invalidCron:%v", err))
+			}
+			if d, err := time.ParseDuration(option.Duration); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("invalidDuration:%v", err))
+			} else if d <= 0 {
+				errs = errors.Join(errs, errors.New("invalidDuration:duration must be greater than 0"))
+			}
+		}
+	}
 	return errs
 }
 
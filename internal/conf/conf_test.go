@@ -225,3 +225,228 @@ func TestRuleOptionValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestRuleScheduleValidate(t *testing.T) {
+	tests := []struct {
+		cron     string
+		duration string
+		err      string
+	}{
+		{
+			cron:     "0 9 * * 1-5",
+			duration: "8h",
+		}, {
+			cron: "0 9 * * 1-5",
+			err:  "invalidSchedule:cron and duration must be set together",
+		}, {
+			cron:     "0 9 * *",
+			duration: "8h",
+			err:      "invalidCron:expected exactly 5 fields, found 4: [0 9 * *]",
+		}, {
+			cron:     "0 9 * * 1-5",
+			duration: "8",
+			err:      "invalidDuration:time: missing unit in duration \"8\"",
+		}, {
+			cron:     "0 9 * * 1-5",
+			duration: "-8h",
+			err:      "invalidDuration:duration must be greater than 0",
+		},
+	}
+	for i, tt := range tests {
+		opt := &api.RuleOption{
+			LateTol:      1000,
+			Concurrency:  1,
+			BufferLength: 1024,
+			Cron:         tt.cron,
+			Duration:     tt.duration,
+		}
+		err := ValidateRuleOption(opt)
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != tt.err {
+			t.Errorf("%d: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.err, errStr)
+		}
+	}
+}
+
+func TestRuleAutoScaleValidate(t *testing.T) {
+	tests := []struct {
+		autoScale *api.AutoScale
+		exp       *api.AutoScale
+		err       string
+	}{
+		{
+			autoScale: &api.AutoScale{MaxConcurrency: 4},
+			exp:       &api.AutoScale{MaxConcurrency: 4, Interval: 1000, MaxCpu: 80},
+		}, {
+			autoScale: &api.AutoScale{MaxConcurrency: 4, Interval: 500, MaxCpu: 60},
+			exp:       &api.AutoScale{MaxConcurrency: 4, Interval: 500, MaxCpu: 60},
+		}, {
+			autoScale: &api.AutoScale{MaxConcurrency: 1, Interval: 500, MaxCpu: 60},
+			exp:       &api.AutoScale{MaxConcurrency: 1, Interval: 500, MaxCpu: 60},
+			err:       "invalidAutoScale:autoScale maxConcurrency must not be less than concurrency",
+		}, {
+			autoScale: &api.AutoScale{MaxConcurrency: 4, Interval: -1, MaxCpu: 120},
+			exp:       &api.AutoScale{MaxConcurrency: 4, Interval: -1, MaxCpu: 120},
+			err:       "invalidAutoScale:autoScale interval must not be negative\ninvalidAutoScale:autoScale maxCpu must between [0, 100]",
+		},
+	}
+	for i, tt := range tests {
+		opt := &api.RuleOption{
+			LateTol:      1000,
+			Concurrency:  2,
+			BufferLength: 1024,
+			AutoScale:    tt.autoScale,
+		}
+		err := ValidateRuleOption(opt)
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != tt.err {
+			t.Errorf("%d: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.err, errStr)
+		}
+		if !reflect.DeepEqual(opt.AutoScale, tt.exp) {
+			t.Errorf("%d: autoScale mismatch:\n  exp=%v\n  got=%v\n\n", i, tt.exp, opt.AutoScale)
+		}
+	}
+}
+
+func TestRuleStateBackendValidate(t *testing.T) {
+	tests := []struct {
+		qos          api.Qos
+		stateBackend *api.StateBackend
+		exp          *api.StateBackend
+		err          string
+	}{
+		{
+			stateBackend: &api.StateBackend{},
+			exp:          &api.StateBackend{Type: api.StateBackendMemory, SpillThreshold: 10000},
+		}, {
+			stateBackend: &api.StateBackend{Type: api.StateBackendDisk, SpillThreshold: 500},
+			exp:          &api.StateBackend{Type: api.StateBackendDisk, SpillThreshold: 500},
+		}, {
+			qos:          api.AtLeastOnce,
+			stateBackend: &api.StateBackend{Type: api.StateBackendDisk},
+			exp:          &api.StateBackend{Type: api.StateBackendDisk, SpillThreshold: 10000},
+			err:          "invalidStateBackend:disk state backend does not support qos",
+		}, {
+			stateBackend: &api.StateBackend{Type: "pebble", SpillThreshold: -1},
+			exp:          &api.StateBackend{Type: "pebble", SpillThreshold: -1},
+			err:          "invalidStateBackend:state backend type must be memory or disk\ninvalidStateBackend:state backend spillThreshold must not be negative",
+		},
+	}
+	for i, tt := range tests {
+		opt := &api.RuleOption{
+			LateTol:            1000,
+			Concurrency:        1,
+			BufferLength:       1024,
+			Qos:                tt.qos,
+			CheckpointInterval: 300000,
+			StateBackend:       tt.stateBackend,
+		}
+		err := ValidateRuleOption(opt)
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != tt.err {
+			t.Errorf("%d: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.err, errStr)
+		}
+		if !reflect.DeepEqual(opt.StateBackend, tt.exp) {
+			t.Errorf("%d: stateBackend mismatch:\n  exp=%v\n  got=%v\n\n", i, tt.exp, opt.StateBackend)
+		}
+	}
+}
+
+func TestRuleDeadLetterValidate(t *testing.T) {
+	tests := []struct {
+		deadLetter map[string]interface{}
+		err        string
+	}{
+		{
+			deadLetter: map[string]interface{}{"memory": map[string]interface{}{"topic": "dlq"}},
+		}, {
+			deadLetter: map[string]interface{}{},
+			err:        "invalidDeadLetter:deadLetter must have exactly one sink",
+		}, {
+			deadLetter: map[string]interface{}{"memory": map[string]interface{}{"topic": "dlq"}, "log": map[string]interface{}{}},
+			err:        "invalidDeadLetter:deadLetter must have exactly one sink",
+		}, {
+			deadLetter: map[string]interface{}{"mqtt": "tcp://127.0.0.1:1883"},
+			err:        "invalidDeadLetter:expect map[string]interface{} type for the deadLetter sink mqtt properties",
+		},
+	}
+	for i, tt := range tests {
+		opt := &api.RuleOption{
+			LateTol:            1000,
+			Concurrency:        1,
+			BufferLength:       1024,
+			CheckpointInterval: 300000,
+			DeadLetter:         tt.deadLetter,
+		}
+		err := ValidateRuleOption(opt)
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != tt.err {
+			t.Errorf("%d: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.err, errStr)
+		}
+	}
+}
+
+func TestCheckpointRemoteValidate(t *testing.T) {
+	tests := []struct {
+		c   *CheckpointRemoteConf
+		e   *CheckpointRemoteConf
+		err string
+	}{
+		{
+			c: &CheckpointRemoteConf{},
+			e: &CheckpointRemoteConf{},
+		}, {
+			c:   &CheckpointRemoteConf{Type: "ftp"},
+			e:   &CheckpointRemoteConf{},
+			err: "invalidCheckpointRemote:unknown checkpoint remote type ftp",
+		}, {
+			c: &CheckpointRemoteConf{Type: "s3"},
+			e: func() *CheckpointRemoteConf {
+				c := &CheckpointRemoteConf{}
+				c.S3.Endpoint = "https://s3.amazonaws.com"
+				c.S3.Region = "us-east-1"
+				return c
+			}(),
+			err: "invalidCheckpointRemote:s3 bucket is required",
+		}, {
+			c: func() *CheckpointRemoteConf {
+				c := &CheckpointRemoteConf{Type: "sftp", Prefix: "/backup"}
+				c.Sftp.Host = "192.168.0.1"
+				c.Sftp.Username = "ekuiper"
+				return c
+			}(),
+			e: func() *CheckpointRemoteConf {
+				c := &CheckpointRemoteConf{Type: "sftp", Prefix: "/backup"}
+				c.Sftp.Host = "192.168.0.1"
+				c.Sftp.Username = "ekuiper"
+				c.Sftp.Port = 22
+				return c
+			}(),
+		},
+	}
+	for i, tt := range tests {
+		err := tt.c.Validate()
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+		}
+		if errStr != tt.err {
+			t.Errorf("%d: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.err, errStr)
+		}
+		if !reflect.DeepEqual(tt.c, tt.e) {
+			t.Errorf("%d: conf mismatch:\n  exp=%#v\n  got=%#v\n\n", i, tt.e, tt.c)
+		}
+	}
+}
@@ -0,0 +1,456 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package avro
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/hamba/avro/v2"
+
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// The avro binary encoding is implemented here by walking the parsed schema, so that the values are mapped to the
+// eKuiper types directly: int and long are int64, float and double are float64, timestamp-millis, timestamp-micros and
+// date are time.Time, decimal is float64, record and map are map[string]interface{} and union is the value itself.
+
+type reader struct {
+	b   []byte
+	pos int
+}
+
+func (r *reader) long() (int64, error) {
+	v, n := binary.Varint(r.b[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid avro data, cannot read long at %d", r.pos)
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *reader) next(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.b) {
+		return nil, fmt.Errorf("invalid avro data, unexpected end at %d", r.pos)
+	}
+	result := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return result, nil
+}
+
+func (r *reader) bytes() ([]byte, error) {
+	l, err := r.long()
+	if err != nil {
+		return nil, err
+	}
+	return r.next(int(l))
+}
+
+func decodeValue(r *reader, s avro.Schema) (interface{}, error) {
+	switch s.Type() {
+	case avro.Null:
+		return nil, nil
+	case avro.Boolean:
+		b, err := r.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case avro.Int, avro.Long:
+		v, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		switch logicalType(s) {
+		case avro.Date:
+			return time.Unix(v*86400, 0).UTC(), nil
+		case avro.TimestampMillis:
+			return cast.TimeFromUnixMilli(v), nil
+		case avro.TimestampMicros:
+			return time.UnixMicro(v).UTC(), nil
+		}
+		return v, nil
+	case avro.Float:
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), nil
+	case avro.Double:
+		b, err := r.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case avro.String:
+		b, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case avro.Bytes, avro.Fixed:
+		var (
+			b   []byte
+			err error
+		)
+		if fs, ok := s.(*avro.FixedSchema); ok {
+			b, err = r.next(fs.Size())
+		} else {
+			b, err = r.bytes()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if d, ok := decimalSchema(s); ok {
+			unscaled := new(big.Int).SetBytes(b)
+			// two's complement
+			if len(b) > 0 && b[0]&0x80 != 0 {
+				unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+			}
+			f, _ := new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale())), nil)).Float64()
+			return f, nil
+		}
+		result := make([]byte, len(b))
+		copy(result, b)
+		return result, nil
+	case avro.Enum:
+		i, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		symbols := s.(*avro.EnumSchema).Symbols()
+		if i < 0 || int(i) >= len(symbols) {
+			return nil, fmt.Errorf("invalid avro data, enum index %d out of range", i)
+		}
+		return symbols[i], nil
+	case avro.Record, avro.Error:
+		fields := s.(*avro.RecordSchema).Fields()
+		result := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			v, err := decodeValue(r, f.Type())
+			if err != nil {
+				return nil, fmt.Errorf("decode field %s error: %v", f.Name(), err)
+			}
+			result[f.Name()] = v
+		}
+		return result, nil
+	case avro.Array:
+		items := s.(*avro.ArraySchema).Items()
+		result := make([]interface{}, 0)
+		err := readBlocks(r, func() error {
+			v, err := decodeValue(r, items)
+			if err != nil {
+				return err
+			}
+			result = append(result, v)
+			return nil
+		})
+		return result, err
+	case avro.Map:
+		values := s.(*avro.MapSchema).Values()
+		result := make(map[string]interface{})
+		err := readBlocks(r, func() error {
+			k, err := r.bytes()
+			if err != nil {
+				return err
+			}
+			v, err := decodeValue(r, values)
+			if err != nil {
+				return err
+			}
+			result[string(k)] = v
+			return nil
+		})
+		return result, err
+	case avro.Union:
+		i, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		types := s.(*avro.UnionSchema).Types()
+		if i < 0 || int(i) >= len(types) {
+			return nil, fmt.Errorf("invalid avro data, union index %d out of range", i)
+		}
+		return decodeValue(r, types[i])
+	case avro.Ref:
+		return decodeValue(r, s.(*avro.RefSchema).Schema())
+	default:
+		return nil, fmt.Errorf("unsupported avro type %s", s.Type())
+	}
+}
+
+// readBlocks reads the blocks of array or map. A negative count is followed by the block size in bytes.
+func readBlocks(r *reader, item func() error) error {
+	for {
+		count, err := r.long()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			count = -count
+			if _, err := r.long(); err != nil {
+				return err
+			}
+		}
+		for i := int64(0); i < count; i++ {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func encodeValue(b []byte, s avro.Schema, v interface{}) ([]byte, error) {
+	switch s.Type() {
+	case avro.Null:
+		if v != nil {
+			return nil, fmt.Errorf("expect null but got %v", v)
+		}
+		return b, nil
+	case avro.Boolean:
+		bv, err := cast.ToBool(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, err
+		}
+		if bv {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case avro.Int, avro.Long:
+		var (
+			i   int64
+			err error
+		)
+		// The numbers are regarded as the raw value of the logical types
+		switch t := v.(type) {
+		case time.Time, string:
+			var tt time.Time
+			if tt, err = cast.InterfaceToTime(t, ""); err == nil {
+				switch logicalType(s) {
+				case avro.Date:
+					i = tt.Unix() / 86400
+				case avro.TimestampMillis:
+					i = tt.UnixMilli()
+				case avro.TimestampMicros:
+					i = tt.UnixMicro()
+				default:
+					err = fmt.Errorf("cannot convert %v to %s", v, s.Type())
+				}
+			}
+		default:
+			i, err = cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.Type() == avro.Int && (i > math.MaxInt32 || i < math.MinInt32) {
+			return nil, fmt.Errorf("value %d overflows avro int", i)
+		}
+		return binary.AppendVarint(b, i), nil
+	case avro.Float:
+		f, err := cast.ToFloat32(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, err
+		}
+		return binary.LittleEndian.AppendUint32(b, math.Float32bits(f)), nil
+	case avro.Double:
+		f, err := cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, err
+		}
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(f)), nil
+	case avro.String:
+		str, err := cast.ToString(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, err
+		}
+		b = binary.AppendVarint(b, int64(len(str)))
+		return append(b, str...), nil
+	case avro.Bytes, avro.Fixed:
+		var (
+			bs  []byte
+			err error
+		)
+		if d, ok := decimalSchema(s); ok {
+			bs, err = encodeDecimal(v, d.Scale())
+		} else {
+			bs, err = cast.ToBytes(v, cast.CONVERT_SAMEKIND)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if fs, ok := s.(*avro.FixedSchema); ok {
+			if len(bs) > fs.Size() {
+				return nil, fmt.Errorf("value of %d bytes exceeds fixed size %d", len(bs), fs.Size())
+			}
+			// sign extend the decimal to the fixed size
+			pad := byte(0)
+			if len(bs) > 0 && bs[0]&0x80 != 0 {
+				pad = 0xff
+			}
+			for i := len(bs); i < fs.Size(); i++ {
+				b = append(b, pad)
+			}
+			return append(b, bs...), nil
+		}
+		b = binary.AppendVarint(b, int64(len(bs)))
+		return append(b, bs...), nil
+	case avro.Enum:
+		str, err := cast.ToString(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, err
+		}
+		for i, sym := range s.(*avro.EnumSchema).Symbols() {
+			if sym == str {
+				return binary.AppendVarint(b, int64(i)), nil
+			}
+		}
+		return nil, fmt.Errorf("%s is not a symbol of enum %s", str, s.(*avro.EnumSchema).FullName())
+	case avro.Record, avro.Error:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect map but got %v", v)
+		}
+		var err error
+		for _, f := range s.(*avro.RecordSchema).Fields() {
+			fv, ok := m[f.Name()]
+			if !ok && f.HasDefault() {
+				fv = f.Default()
+			}
+			b, err = encodeValue(b, f.Type(), fv)
+			if err != nil {
+				return nil, fmt.Errorf("encode field %s error: %v", f.Name(), err)
+			}
+		}
+		return b, nil
+	case avro.Array:
+		if v == nil {
+			return append(b, 0), nil
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("expect array but got %v", v)
+		}
+		items := s.(*avro.ArraySchema).Items()
+		var err error
+		if rv.Len() > 0 {
+			b = binary.AppendVarint(b, int64(rv.Len()))
+			for i := 0; i < rv.Len(); i++ {
+				b, err = encodeValue(b, items, rv.Index(i).Interface())
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		return append(b, 0), nil
+	case avro.Map:
+		if v == nil {
+			return append(b, 0), nil
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expect map but got %v", v)
+		}
+		values := s.(*avro.MapSchema).Values()
+		var err error
+		if len(m) > 0 {
+			b = binary.AppendVarint(b, int64(len(m)))
+			for k, mv := range m {
+				b = binary.AppendVarint(b, int64(len(k)))
+				b = append(b, k...)
+				b, err = encodeValue(b, values, mv)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		return append(b, 0), nil
+	case avro.Union:
+		// Use the first type which can encode the value
+		errs := make([]error, 0)
+		for i, t := range s.(*avro.UnionSchema).Types() {
+			if (v == nil) != (t.Type() == avro.Null) {
+				continue
+			}
+			r, err := encodeValue(binary.AppendVarint(b, int64(i)), t, v)
+			if err == nil {
+				return r, nil
+			}
+			errs = append(errs, err)
+		}
+		return nil, fmt.Errorf("value %v does not match any type of the union: %v", v, errs)
+	case avro.Ref:
+		return encodeValue(b, s.(*avro.RefSchema).Schema(), v)
+	default:
+		return nil, fmt.Errorf("unsupported avro type %s", s.Type())
+	}
+}
+
+// encodeDecimal converts the number to the big endian two's complement of the unscaled value
+func encodeDecimal(v interface{}, scale int) ([]byte, error) {
+	f, err := cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := new(big.Rat).SetString(fmt.Sprintf("%.*f", scale, f))
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %v", v)
+	}
+	unscaled := new(big.Int).Mul(r.Num(), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+	unscaled.Quo(unscaled, r.Denom())
+	switch unscaled.Sign() {
+	case 0:
+		return []byte{0}, nil
+	case 1:
+		bs := unscaled.Bytes()
+		if bs[0]&0x80 != 0 {
+			bs = append([]byte{0}, bs...)
+		}
+		return bs, nil
+	default:
+		// two's complement of the negative value
+		l := (unscaled.BitLen() + 8) / 8
+		twos := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), uint(l*8)), unscaled)
+		bs := twos.Bytes()
+		for len(bs) < l {
+			bs = append([]byte{0xff}, bs...)
+		}
+		return bs, nil
+	}
+}
+
+func logicalType(s avro.Schema) avro.LogicalType {
+	if ls, ok := s.(avro.LogicalTypeSchema); ok && ls.Logical() != nil {
+		return ls.Logical().Type()
+	}
+	return ""
+}
+
+func decimalSchema(s avro.Schema) (*avro.DecimalLogicalSchema, bool) {
+	if ls, ok := s.(avro.LogicalTypeSchema); ok && ls.Logical() != nil {
+		d, ok := ls.Logical().(*avro.DecimalLogicalSchema)
+		return d, ok
+	}
+	return nil, false
+}
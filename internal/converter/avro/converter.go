@@ -0,0 +1,129 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package avro
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/hamba/avro/v2"
+
+	"github.com/lf-edge/ekuiper/internal/schema"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+// magicByte is the first byte of the Confluent wire format, followed by the 4 bytes schema id
+const magicByte = 0
+
+type Converter struct {
+	schema avro.Schema
+}
+
+// NewConverter creates the converter by the schema file. The name is the full name or name of a named type in the
+// schema to encode and decode, it is usually the top level record.
+func NewConverter(schemaFile string, name string) (message.Converter, error) {
+	content, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("read schema file %s failed: %s", schemaFile, err)
+	}
+	s, err := schema.ParseAvro(string(content), nil, name)
+	if err != nil {
+		return nil, fmt.Errorf("parse schema file %s failed: %s", schemaFile, err)
+	}
+	return &Converter{schema: s}, nil
+}
+
+func (c *Converter) Encode(d interface{}) ([]byte, error) {
+	return encodeValue(make([]byte, 0, 64), c.schema, d)
+}
+
+func (c *Converter) Decode(b []byte) (interface{}, error) {
+	return decodeValue(&reader{b: b}, c.schema)
+}
+
+// RegistryConverter converts the payload in the wire format of the external schema registry.
+// The payload is decoded by the writer schema of the id in it. The field names must be compatible with the registered
+// schema.
+type RegistryConverter struct {
+	id     int
+	schema avro.Schema
+
+	sync.RWMutex
+	// cache of the writer schemas by id
+	schemas map[int]avro.Schema
+}
+
+func NewRegistryConverter(ref *schema.RegistryRef, name string) (message.Converter, error) {
+	rs, err := schema.FetchSubject(ref.Subject, ref.Version)
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseRemote(rs, name)
+	if err != nil {
+		return nil, err
+	}
+	return &RegistryConverter{
+		id:      rs.ID,
+		schema:  s,
+		schemas: map[int]avro.Schema{rs.ID: s},
+	}, nil
+}
+
+func (c *RegistryConverter) Encode(d interface{}) ([]byte, error) {
+	b := make([]byte, 5, 64)
+	b[0] = magicByte
+	binary.BigEndian.PutUint32(b[1:5], uint32(c.id))
+	return encodeValue(b, c.schema, d)
+}
+
+func (c *RegistryConverter) Decode(b []byte) (interface{}, error) {
+	if len(b) < 5 || b[0] != magicByte {
+		return nil, fmt.Errorf("invalid wire format, the payload must start with the magic byte and schema id")
+	}
+	id := int(binary.BigEndian.Uint32(b[1:5]))
+	c.RLock()
+	s, ok := c.schemas[id]
+	c.RUnlock()
+	if !ok {
+		rs, err := schema.FetchById(id)
+		if err != nil {
+			return nil, err
+		}
+		// The writer schema of another version may have a different name, so just use the top level
+		s, err = parseRemote(rs, "")
+		if err != nil {
+			return nil, err
+		}
+		c.Lock()
+		c.schemas[id] = s
+		c.Unlock()
+	}
+	return decodeValue(&reader{b: b[5:]}, s)
+}
+
+func parseRemote(rs *schema.RemoteSchema, name string) (avro.Schema, error) {
+	if rs.Type != "AVRO" {
+		return nil, fmt.Errorf("schema id %d is %s, but expect AVRO", rs.ID, rs.Type)
+	}
+	s, err := schema.ParseAvro(rs.Schema, rs.Refs, name)
+	if err != nil {
+		return nil, fmt.Errorf("parse schema id %d failed: %s", rs.ID, err)
+	}
+	return s, nil
+}
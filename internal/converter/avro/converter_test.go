@@ -0,0 +1,251 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package avro
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/schema"
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const personSchema = `{
+  "type": "record",
+  "name": "Person",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "name", "type": "string"},
+    {"name": "id", "type": "long"},
+    {"name": "email", "type": ["null", "string"], "default": null}
+  ]
+}`
+
+const allSchema = `{
+  "type": "record",
+  "name": "All",
+  "fields": [
+    {"name": "b", "type": "boolean"},
+    {"name": "i", "type": "int"},
+    {"name": "f", "type": "float"},
+    {"name": "d", "type": "double"},
+    {"name": "bs", "type": "bytes"},
+    {"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 6, "scale": 2}},
+    {"name": "ts", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "day", "type": {"type": "int", "logicalType": "date"}},
+    {"name": "color", "type": {"type": "enum", "name": "Color", "symbols": ["RED", "GREEN"]}},
+    {"name": "tags", "type": {"type": "array", "items": "string"}},
+    {"name": "attrs", "type": {"type": "map", "values": "long"}},
+    {"name": "addr", "type": ["null", {"type": "record", "name": "Address", "fields": [{"name": "city", "type": "string"}]}]},
+    {"name": "code", "type": {"type": "fixed", "name": "Code", "size": 2}}
+  ]
+}`
+
+func writeSchema(t *testing.T, content string) string {
+	p := filepath.Join(t.TempDir(), "test.avsc")
+	if err := os.WriteFile(p, []byte(content), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestEncodeDecode(t *testing.T) {
+	c, err := NewConverter(writeSchema(t, personSchema), "Person")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		m map[string]interface{}
+		r []byte
+		d map[string]interface{}
+		e string
+	}{
+		{
+			m: map[string]interface{}{"name": "test", "id": 1},
+			r: []byte{0x08, 0x74, 0x65, 0x73, 0x74, 0x02, 0x00},
+			d: map[string]interface{}{"name": "test", "id": int64(1), "email": nil},
+		}, {
+			m: map[string]interface{}{"name": "test", "id": float64(-1), "email": "a"},
+			r: []byte{0x08, 0x74, 0x65, 0x73, 0x74, 0x01, 0x02, 0x02, 0x61},
+			d: map[string]interface{}{"name": "test", "id": int64(-1), "email": "a"},
+		}, {
+			m: map[string]interface{}{"name": "test"},
+			e: "encode field id error: cannot convert <nil>(<nil>) to int64",
+		},
+	}
+	for i, tt := range tests {
+		r, err := c.Encode(tt.m)
+		if !reflect.DeepEqual(tt.e, testx.Errstring(err)) {
+			t.Errorf("%d.error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.e, err)
+			continue
+		}
+		if tt.e != "" {
+			continue
+		}
+		if !reflect.DeepEqual(tt.r, r) {
+			t.Errorf("%d. encode mismatch:\n  exp=%x\n  got=%x\n\n", i, tt.r, r)
+		}
+		d, err := c.Decode(r)
+		if err != nil {
+			t.Errorf("%d. decode error: %v", i, err)
+		} else if !reflect.DeepEqual(tt.d, d) {
+			t.Errorf("%d. decode mismatch:\n  exp=%v\n  got=%v\n\n", i, tt.d, d)
+		}
+	}
+	_, err = c.Decode([]byte{0x08, 0x74})
+	if err == nil {
+		t.Errorf("expect error for truncated data")
+	}
+	_, err = NewConverter(writeSchema(t, personSchema), "Animal")
+	if err == nil {
+		t.Errorf("expect error for unknown type")
+	}
+}
+
+func TestLogicalTypes(t *testing.T) {
+	c, err := NewConverter(writeSchema(t, allSchema), "All")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := cast.TimeFromUnixMilli(1700000000123)
+	m := map[string]interface{}{
+		"b":     true,
+		"i":     int64(-3),
+		"f":     float64(1.5),
+		"d":     3.25,
+		"bs":    []byte{0x01, 0x02},
+		"price": -1.5,
+		"ts":    ts,
+		"day":   int64(19675),
+		"color": "GREEN",
+		"tags":  []interface{}{"a", "b"},
+		"attrs": map[string]interface{}{"x": int64(1)},
+		"addr":  map[string]interface{}{"city": "Shanghai"},
+		"code":  []byte{0x0a, 0x0b},
+	}
+	b, err := c.Encode(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]interface{}{
+		"b":     true,
+		"i":     int64(-3),
+		"f":     1.5,
+		"d":     3.25,
+		"bs":    []byte{0x01, 0x02},
+		"price": -1.5,
+		"ts":    ts,
+		"day":   time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC),
+		"color": "GREEN",
+		"tags":  []interface{}{"a", "b"},
+		"attrs": map[string]interface{}{"x": int64(1)},
+		"addr":  map[string]interface{}{"city": "Shanghai"},
+		"code":  []byte{0x0a, 0x0b},
+	}
+	if !reflect.DeepEqual(exp, r) {
+		t.Errorf("result mismatch:\n  exp=%v\n  got=%v\n\n", exp, r)
+	}
+	// decimal in bytes is the two's complement of the unscaled value
+	for _, tt := range []struct {
+		v float64
+		b []byte
+	}{
+		{v: 12.34, b: []byte{0x04, 0xd2}},
+		{v: -1.5, b: []byte{0xff, 0x6a}},
+		{v: 1.28, b: []byte{0x00, 0x80}},
+		{v: 0, b: []byte{0x00}},
+	} {
+		b, err := encodeDecimal(tt.v, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tt.b, b) {
+			t.Errorf("decimal %v mismatch, expect %x but got %x", tt.v, tt.b, b)
+		}
+	}
+}
+
+func TestRegistryConverter(t *testing.T) {
+	testx.InitEnv()
+	responses := map[string]string{
+		"/subjects/person/versions/1":  `{"subject":"person","version":1,"id":31,"schema":"{\"type\":\"record\",\"name\":\"Person\",\"fields\":[{\"name\":\"name\",\"type\":\"string\"},{\"name\":\"addr\",\"type\":\"Address\"}]}","references":[{"name":"Address","subject":"address","version":1}]}`,
+		"/subjects/address/versions/1": `{"subject":"address","version":1,"id":30,"schema":"{\"type\":\"record\",\"name\":\"Address\",\"fields\":[{\"name\":\"city\",\"type\":\"string\"}]}"}`,
+		"/schemas/ids/32":              `{"schema":"{\"type\":\"record\",\"name\":\"Person\",\"fields\":[{\"name\":\"name\",\"type\":\"string\"},{\"name\":\"age\",\"type\":\"int\"}]}"}`,
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	defer s.Close()
+	conf.Config.SchemaRegistry = conf.SchemaRegistryConf{Type: "confluent", Url: s.URL, Timeout: 1000}
+	defer func() {
+		conf.Config.SchemaRegistry = conf.SchemaRegistryConf{}
+	}()
+
+	c, err := NewRegistryConverter(&schema.RegistryRef{Subject: "person", Version: "1"}, "Person")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Encode(map[string]interface{}{"name": "a", "addr": map[string]interface{}{"city": "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []byte{0x00, 0x00, 0x00, 0x00, 0x1f, 0x02, 0x61, 0x02, 0x62}
+	if !reflect.DeepEqual(exp, b) {
+		t.Errorf("encode mismatch, expect %x but got %x", exp, b)
+	}
+	tests := []struct {
+		b []byte
+		r map[string]interface{}
+		e string
+	}{
+		{
+			b: exp,
+			r: map[string]interface{}{"name": "a", "addr": map[string]interface{}{"city": "b"}},
+		}, {
+			// schema id 32 of the other version
+			b: []byte{0x00, 0x00, 0x00, 0x00, 0x20, 0x02, 0x61, 0x04},
+			r: map[string]interface{}{"name": "a", "age": int64(2)},
+		}, {
+			b: []byte{0x02, 0x61, 0x02, 0x62},
+			e: "invalid wire format, the payload must start with the magic byte and schema id",
+		},
+	}
+	for i, tt := range tests {
+		r, err := c.Decode(tt.b)
+		if !reflect.DeepEqual(tt.e, testx.Errstring(err)) {
+			t.Errorf("%d.error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.e, err)
+		} else if tt.e == "" && !reflect.DeepEqual(tt.r, r) {
+			t.Errorf("%d. \n\nresult mismatch:\n\nexp=%v\n\ngot=%v\n\n", i, tt.r, r)
+		}
+	}
+}
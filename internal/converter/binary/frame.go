@@ -0,0 +1,230 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package binary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/lf-edge/ekuiper/internal/schema"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+// FrameConverter decodes and encodes the binary frame by the declarative field layout in the binary schema
+type FrameConverter struct {
+	frame *schema.BinaryFrame
+}
+
+func NewFrameConverter(schemaFile string, name string) (message.Converter, error) {
+	content, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("read schema file %s failed: %s", schemaFile, err)
+	}
+	frame, err := schema.ParseBinaryFrame(string(content), name)
+	if err != nil {
+		return nil, fmt.Errorf("parse schema file %s failed: %s", schemaFile, err)
+	}
+	return &FrameConverter{frame: frame}, nil
+}
+
+func (c *FrameConverter) Decode(b []byte) (interface{}, error) {
+	if len(b) < c.frame.Size {
+		return nil, fmt.Errorf("frame length %d is less than %d", len(b), c.frame.Size)
+	}
+	result := make(map[string]interface{}, len(c.frame.Fields))
+	for _, f := range c.frame.Fields {
+		result[f.Name] = decodeField(b, f)
+	}
+	return result, nil
+}
+
+func decodeField(b []byte, f *schema.BinaryField) interface{} {
+	order := byteOrder(f.Endian)
+	switch f.Type {
+	case "string", "bytes":
+		v := b[f.Offset:]
+		if f.Length > 0 {
+			v = v[:f.Length]
+		}
+		if f.Type == "string" {
+			return string(bytes.TrimRight(v, "\x00"))
+		}
+		r := make([]byte, len(v))
+		copy(r, v)
+		return r
+	case "bool":
+		return b[f.Offset]>>f.BitOffset&1 == 1
+	case "float32":
+		return scale(f, float64(math.Float32frombits(order.Uint32(b[f.Offset:]))))
+	case "float64":
+		return scale(f, math.Float64frombits(order.Uint64(b[f.Offset:])))
+	default:
+		size := f.Size()
+		raw := readUint(b[f.Offset:], size, order)
+		bits := size * 8
+		if f.BitLength > 0 {
+			raw = raw >> f.BitOffset & (1<<f.BitLength - 1)
+			bits = f.BitLength
+		}
+		v := int64(raw)
+		// sign extension
+		if f.Type[0] == 'i' && bits < 64 && raw>>(bits-1)&1 == 1 {
+			v = int64(raw | ^uint64(0)<<bits)
+		}
+		if f.Scaled() {
+			return scale(f, float64(v))
+		}
+		return v
+	}
+}
+
+func scale(f *schema.BinaryField, v float64) float64 {
+	if f.Scaled() {
+		return v*f.Scale + f.Bias
+	}
+	return v
+}
+
+// Encode writes the fields of the map into the frame. The missing fields are zero.
+func (c *FrameConverter) Encode(d interface{}) ([]byte, error) {
+	m, ok := d.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %v, must be a map", d)
+	}
+	b := make([]byte, c.frame.Size)
+	for _, f := range c.frame.Fields {
+		v, ok := m[f.Name]
+		if !ok || v == nil {
+			continue
+		}
+		var err error
+		b, err = encodeField(b, f, v)
+		if err != nil {
+			return nil, fmt.Errorf("encode field %s error: %v", f.Name, err)
+		}
+	}
+	return b, nil
+}
+
+func encodeField(b []byte, f *schema.BinaryField, v interface{}) ([]byte, error) {
+	order := byteOrder(f.Endian)
+	switch f.Type {
+	case "string", "bytes":
+		var (
+			data []byte
+			err  error
+		)
+		if f.Type == "string" {
+			var s string
+			s, err = cast.ToString(v, cast.CONVERT_SAMEKIND)
+			data = []byte(s)
+		} else {
+			data, err = cast.ToBytes(v, cast.CONVERT_SAMEKIND)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if f.Length == 0 {
+			return append(b[:f.Offset], data...), nil
+		}
+		// truncated or padded with zero
+		copy(b[f.Offset:f.Offset+f.Length], data)
+	case "bool":
+		bv, err := cast.ToBool(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, err
+		}
+		if bv {
+			b[f.Offset] |= 1 << f.BitOffset
+		} else {
+			b[f.Offset] &^= 1 << f.BitOffset
+		}
+	case "float32", "float64":
+		fv, err := cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, err
+		}
+		if f.Scaled() {
+			fv = (fv - f.Bias) / f.Scale
+		}
+		if f.Type == "float32" {
+			order.PutUint32(b[f.Offset:], math.Float32bits(float32(fv)))
+		} else {
+			order.PutUint64(b[f.Offset:], math.Float64bits(fv))
+		}
+	default:
+		var raw int64
+		if f.Scaled() {
+			fv, err := cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+			if err != nil {
+				return nil, err
+			}
+			raw = int64(math.Round((fv - f.Bias) / f.Scale))
+		} else {
+			iv, err := cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+			if err != nil {
+				return nil, err
+			}
+			raw = iv
+		}
+		size := f.Size()
+		u := uint64(raw)
+		if f.BitLength > 0 {
+			mask := uint64(1<<f.BitLength-1) << f.BitOffset
+			u = readUint(b[f.Offset:], size, order)&^mask | u<<f.BitOffset&mask
+		}
+		writeUint(b[f.Offset:], size, order, u)
+	}
+	return b, nil
+}
+
+func byteOrder(endian string) binary.ByteOrder {
+	if endian == "little" {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+func readUint(b []byte, size int, order binary.ByteOrder) uint64 {
+	switch size {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(order.Uint16(b))
+	case 4:
+		return uint64(order.Uint32(b))
+	default:
+		return order.Uint64(b)
+	}
+}
+
+func writeUint(b []byte, size int, order binary.ByteOrder, v uint64) {
+	switch size {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		order.PutUint16(b, uint16(v))
+	case 4:
+		order.PutUint32(b, uint32(v))
+	default:
+		order.PutUint64(b, v)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package binary
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func TestFrameConverter(t *testing.T) {
+	c, err := NewFrameConverter(filepath.Join("..", "..", "schema", "test", "frames.json"), "Telemetry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte{
+		0x01, 0x02, // id
+		0x01, 0x90, // temperature 400 * 0.1 - 40
+		0xe7,                   // running 1, mode 3, offset -2
+		0x00, 0x00, 0x80, 0x3f, // pressure 1.0 in little endian
+		'a', 'b', 0, 0, // name
+		0x0a, 0x0b, // payload
+	}
+	exp := map[string]interface{}{
+		"id":          int64(258),
+		"temperature": float64(0),
+		"running":     true,
+		"mode":        int64(3),
+		"offset":      int64(-2),
+		"pressure":    float64(1),
+		"name":        "ab",
+		"payload":     []byte{0x0a, 0x0b},
+	}
+	r, err := c.Decode(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exp, r) {
+		t.Errorf("decode result mismatch:\n  exp=%v\n  got=%v", exp, r)
+	}
+	b, err := c.Encode(exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(payload, b) {
+		t.Errorf("encode result mismatch:\n  exp=%x\n  got=%x", payload, b)
+	}
+	_, err = c.Decode(payload[:10])
+	if !reflect.DeepEqual("frame length 10 is less than 13", testx.Errstring(err)) {
+		t.Errorf("error mismatch, got %v", err)
+	}
+	_, err = c.Encode(map[string]interface{}{"id": "abc"})
+	if err == nil {
+		t.Errorf("expect error for invalid id")
+	}
+
+	c, err = NewFrameConverter(filepath.Join("..", "..", "schema", "test", "frames.json"), "Counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err = c.Encode(map[string]interface{}{"count": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]byte{1, 0, 0, 0, 0, 0, 0, 0}, b) {
+		t.Errorf("encode result mismatch, got %x", b)
+	}
+}
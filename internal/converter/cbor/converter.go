@@ -0,0 +1,94 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cbor
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+type Converter struct {
+	em cbor.EncMode
+}
+
+// Sort the map keys so that the same map is always encoded to the same bytes. The datetime is encoded as RFC3339 string
+// like json.
+var encOptions = cbor.EncOptions{Sort: cbor.SortCanonical, Time: cbor.TimeRFC3339Nano}
+
+func GetConverter() (message.Converter, error) {
+	em, err := encOptions.EncMode()
+	if err != nil {
+		return nil, err
+	}
+	return &Converter{em: em}, nil
+}
+
+func (c *Converter) Encode(d interface{}) ([]byte, error) {
+	return c.em.Marshal(d)
+}
+
+func (c *Converter) Decode(b []byte) (interface{}, error) {
+	var r interface{}
+	if err := cbor.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	r = normalize(r)
+	switch r.(type) {
+	case map[string]interface{}, []interface{}:
+		return r, nil
+	default:
+		return nil, fmt.Errorf("only map and array are supported but got %v", r)
+	}
+}
+
+// normalize converts the decoded value to eKuiper types. The map keys are converted to string and the integers are
+// converted to int64.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		r := make(map[string]interface{}, len(t))
+		for k, mv := range t {
+			r[fmt.Sprintf("%v", k)] = normalize(mv)
+		}
+		return r
+	case []interface{}:
+		for i, av := range t {
+			t[i] = normalize(av)
+		}
+		return t
+	case uint64:
+		if t <= math.MaxInt64 {
+			return int64(t)
+		}
+		return float64(t)
+	case float32:
+		return float64(t)
+	case big.Int:
+		f, _ := new(big.Float).SetInt(&t).Float64()
+		return f
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(t).Float64()
+		return f
+	case cbor.Tag:
+		return normalize(t.Content)
+	default:
+		return v
+	}
+}
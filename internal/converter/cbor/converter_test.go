@@ -0,0 +1,91 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cbor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func TestEncode(t *testing.T) {
+	c, err := GetConverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		m interface{}
+		r []byte
+	}{
+		{
+			m: map[string]interface{}{"b": true, "a": 1},
+			r: []byte{0xa2, 0x61, 0x61, 0x01, 0x61, 0x62, 0xf5},
+		}, {
+			m: []map[string]interface{}{{"a": -1.5}, {"a": "x"}},
+			r: []byte{0x82, 0xa1, 0x61, 0x61, 0xfb, 0xbf, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xa1, 0x61, 0x61, 0x61, 0x78},
+		}, {
+			m: map[string]interface{}{"t": time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+			r: append([]byte{0xa1, 0x61, 0x74, 0x74}, "2023-01-02T03:04:05Z"...),
+		},
+	}
+	for i, tt := range tests {
+		r, err := c.Encode(tt.m)
+		if err != nil {
+			t.Errorf("%d encode error: %v", i, err)
+		} else if !reflect.DeepEqual(tt.r, r) {
+			t.Errorf("%d result mismatch:\n  exp=%x\n  got=%x", i, tt.r, r)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	c, err := GetConverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		b []byte
+		r interface{}
+		e string
+	}{
+		{
+			b: []byte{0xa2, 0x61, 0x61, 0x01, 0x61, 0x62, 0xf5},
+			r: map[string]interface{}{"a": int64(1), "b": true},
+		}, {
+			// integer keys, negative integer, float32, bytes and nested array
+			b: []byte{0xa3, 0x01, 0x20, 0x02, 0xfa, 0x3f, 0xc0, 0x00, 0x00, 0x03, 0x82, 0x42, 0x01, 0x02, 0xa1, 0x61, 0x61, 0x18, 0x64},
+			r: map[string]interface{}{"1": int64(-1), "2": 1.5, "3": []interface{}{[]byte{0x01, 0x02}, map[string]interface{}{"a": int64(100)}}},
+		}, {
+			b: []byte{0x82, 0xa1, 0x61, 0x61, 0x01, 0xa1, 0x61, 0x61, 0x02},
+			r: []interface{}{map[string]interface{}{"a": int64(1)}, map[string]interface{}{"a": int64(2)}},
+		}, {
+			b: []byte{0x01},
+			e: "only map and array are supported but got 1",
+		}, {
+			b: []byte{0xa2, 0x61},
+			e: "unexpected EOF",
+		},
+	}
+	for i, tt := range tests {
+		r, err := c.Decode(tt.b)
+		if !reflect.DeepEqual(tt.e, testx.Errstring(err)) {
+			t.Errorf("%d.error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.e, err)
+		} else if tt.e == "" && !reflect.DeepEqual(tt.r, r) {
+			t.Errorf("%d result mismatch:\n  exp=%v\n  got=%v", i, tt.r, r)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/compressor"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+// decompressConverter decompresses the payload before decoding it with the format converter.
+// The decompressor is not thread safe, so it is guarded by the mutex for the concurrent source instances
+type decompressConverter struct {
+	message.Converter
+	sync.Mutex
+	decompressor message.Decompressor
+}
+
+// WithDecompression wraps the converter to decompress the payload with the compression method before decoding
+func WithDecompression(c message.Converter, decompression string) (message.Converter, error) {
+	if decompression == "" {
+		return c, nil
+	}
+	d, err := compressor.GetDecompressor(decompression)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressConverter{Converter: c, decompressor: d}, nil
+}
+
+func (c *decompressConverter) Decode(b []byte) (interface{}, error) {
+	c.Lock()
+	data, err := c.decompressor.Decompress(b)
+	c.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("decompress payload error: %v", err)
+	}
+	return c.Converter.Decode(data)
+}
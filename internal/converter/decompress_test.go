@@ -0,0 +1,61 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/compressor"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestWithDecompression(t *testing.T) {
+	payload := []byte(`{"id":1,"name":"demo"}`)
+	for _, name := range []string{"gzip", "zlib", "zstd", "lz4"} {
+		c, err := GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		dc, err := WithDecompression(c, name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		cp, err := compressor.GetCompressor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := cp.Compress(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := dc.Decode(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		exp := map[string]interface{}{"id": float64(1), "name": "demo"}
+		if !reflect.DeepEqual(exp, r) {
+			t.Errorf("%s: expect %v but got %v", name, exp, r)
+		}
+		_, err = dc.Decode(payload)
+		if err == nil {
+			t.Errorf("%s: expect error for the uncompressed payload", name)
+		}
+	}
+	_, err := WithDecompression(nil, "rar")
+	if err == nil || err.Error() != "unsupported decompressor: rar" {
+		t.Errorf("expect unsupported error but got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/encryptor"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+// decryptConverter decrypts the payload before decompressing and decoding it
+type decryptConverter struct {
+	message.Converter
+	decryptor message.Decryptor
+}
+
+// WithDecryption wraps the converter to decrypt the payload with the algorithm before decoding
+func WithDecryption(c message.Converter, decryption string) (message.Converter, error) {
+	if decryption == "" {
+		return c, nil
+	}
+	d, err := encryptor.GetDecryptor(decryption)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptConverter{Converter: c, decryptor: d}, nil
+}
+
+func (c *decryptConverter) Decode(b []byte) (interface{}, error) {
+	data, err := c.decryptor.Decrypt(b)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt payload error: %v", err)
+	}
+	return c.Converter.Decode(data)
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package converter
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/compressor"
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/encryptor"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestWithDecryption(t *testing.T) {
+	conf.InitConf()
+	conf.Config.Basic.AesKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	defer func() {
+		conf.Config.Basic.AesKey = ""
+	}()
+	c, err := GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = WithDecompression(c, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = WithDecryption(c, "aes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, _ := compressor.GetCompressor("gzip")
+	en, err := encryptor.GetEncryptor("aes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := cp.Compress([]byte(`{"id":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = en.Encrypt(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := map[string]interface{}{"id": float64(1)}; !reflect.DeepEqual(exp, r) {
+		t.Errorf("expect %v but got %v", exp, r)
+	}
+	_, err = c.Decode([]byte("plain payload without encryption"))
+	if err == nil || err.Error() != "decrypt payload error: fail to decrypt: cipher: message authentication failed" {
+		t.Errorf("expect decrypt error but got %v", err)
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -15,31 +15,105 @@
 package delimited
 
 import (
+	"bytes"
 	"fmt"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
 	"github.com/lf-edge/ekuiper/pkg/message"
 )
 
+// The policies to handle the malformed rows when decoding
+const (
+	MalformedError = "error"
+	MalformedSkip  = "skip"
+	MalformedDlq   = "dlq"
+)
+
+// csvConf is the format properties read from the source properties
+type csvConf struct {
+	Quote        string            `json:"quote"`
+	Escape       string            `json:"escape"`
+	HasHeader    bool              `json:"hasHeader"`
+	Columns      []string          `json:"columns"`
+	ColumnTypes  map[string]string `json:"columnTypes"`
+	MalformedRow string            `json:"malformedRow"`
+}
+
 type Converter struct {
 	delimiter string
 	cols      []string
+	quote     byte
+	escape    byte
+	hasHeader bool
+	// types are the column types to convert the values, the columns without type are kept as string
+	types  map[string]string
+	policy string
 }
 
 func NewConverter(delimiter string) (message.Converter, error) {
 	if delimiter == "" {
 		delimiter = ","
 	}
-	return &Converter{delimiter: delimiter}, nil
+	return &Converter{delimiter: delimiter, quote: '"', escape: '"', policy: MalformedError}, nil
 }
 
 func (c *Converter) SetColumns(cols []string) {
 	c.cols = cols
 }
 
-// Encode If no columns defined, the default order is sort by key
+// Configure reads the csv properties such as the quote, header and column types
+func (c *Converter) Configure(props map[string]interface{}) error {
+	cc := &csvConf{}
+	if err := cast.MapToStruct(props, cc); err != nil {
+		return fmt.Errorf("read delimited format properties error: %v", err)
+	}
+	if cc.Quote != "" {
+		if len(cc.Quote) != 1 {
+			return fmt.Errorf("quote must be a single character but got %s", cc.Quote)
+		}
+		c.quote = cc.Quote[0]
+		c.escape = c.quote
+	}
+	if cc.Escape != "" {
+		if len(cc.Escape) != 1 {
+			return fmt.Errorf("escape must be a single character but got %s", cc.Escape)
+		}
+		c.escape = cc.Escape[0]
+	}
+	c.hasHeader = cc.HasHeader
+	if len(cc.Columns) > 0 {
+		c.cols = cc.Columns
+	}
+	if len(cc.ColumnTypes) > 0 {
+		c.types = make(map[string]string, len(cc.ColumnTypes))
+		for k, t := range cc.ColumnTypes {
+			t = strings.ToUpper(t)
+			switch t {
+			case ast.XBIGINT, ast.XFLOAT, ast.XSTRING, ast.XBOOLEAN, ast.XDATETIME:
+				c.types[k] = t
+			default:
+				return fmt.Errorf("unsupported type %s of column %s, must be bigint, float, string, boolean or datetime", t, k)
+			}
+		}
+	}
+	switch cc.MalformedRow {
+	case "":
+	case MalformedError, MalformedSkip, MalformedDlq:
+		c.policy = cc.MalformedRow
+	default:
+		return fmt.Errorf("invalid malformedRow %s, must be error, skip or dlq", cc.MalformedRow)
+	}
+	return nil
+}
+
+// Encode If no columns defined, the default order is sort by key. The field is quoted if it contains the delimiter,
+// quote or line breaks by RFC4180
 func (c *Converter) Encode(d interface{}) ([]byte, error) {
 	switch m := d.(type) {
 	case map[string]interface{}:
@@ -57,7 +131,7 @@ func (c *Converter) Encode(d interface{}) ([]byte, error) {
 			if i > 0 {
 				sb.WriteString(c.delimiter)
 			}
-			sb.WriteString(fmt.Sprintf("%v", m[v]))
+			c.writeField(&sb, fmt.Sprintf("%v", m[v]))
 		}
 		return []byte(sb.String()), nil
 	default:
@@ -65,21 +139,220 @@ func (c *Converter) Encode(d interface{}) ([]byte, error) {
 	}
 }
 
-// Decode If the cols is not set, the default key name is col1, col2, col3...
-// The return value is always a map
+func (c *Converter) writeField(sb *strings.Builder, f string) {
+	if !strings.Contains(f, c.delimiter) && strings.IndexByte(f, c.quote) < 0 && !strings.ContainsAny(f, "\r\n") {
+		sb.WriteString(f)
+		return
+	}
+	sb.WriteByte(c.quote)
+	for i := 0; i < len(f); i++ {
+		if f[i] == c.quote || f[i] == c.escape {
+			sb.WriteByte(c.escape)
+		}
+		sb.WriteByte(f[i])
+	}
+	sb.WriteByte(c.quote)
+}
+
+// Decode If the cols is not set, the default key name is col0, col1, col2...
+// The payload may have multiple rows separated by line breaks. The return value is a map if there is only one row,
+// otherwise it is a slice of maps. If hasHeader is set, the first row is the column names.
 func (c *Converter) Decode(b []byte) (interface{}, error) {
-	tokens := strings.Split(string(b), c.delimiter)
-	m := make(map[string]interface{})
-	if len(c.cols) == 0 {
-		for i, v := range tokens {
-			m["col"+strconv.Itoa(i)] = v
-		}
-	} else {
-		for i, v := range tokens {
-			if i < len(c.cols) {
-				m[c.cols[i]] = v
+	records := c.readRecords(b)
+	cols := c.cols
+	if c.hasHeader {
+		if len(records) == 0 {
+			return nil, fmt.Errorf("header row is not found")
+		}
+		if records[0].err != nil {
+			return nil, fmt.Errorf("invalid header row: %v", records[0].err)
+		}
+		cols = records[0].fields
+		records = records[1:]
+	}
+	var (
+		result    = make([]map[string]interface{}, 0, len(records))
+		malformed []*message.MalformedRow
+	)
+	for _, r := range records {
+		err := r.err
+		var m map[string]interface{}
+		if err == nil {
+			m, err = c.toMap(cols, r.fields)
+		}
+		if err != nil {
+			switch c.policy {
+			case MalformedSkip:
+				conf.Log.Warnf("skip malformed row %s: %v", r.raw, err)
+			case MalformedDlq:
+				malformed = append(malformed, &message.MalformedRow{Data: r.raw, Err: err})
+			default:
+				return nil, fmt.Errorf("malformed row %s: %v", r.raw, err)
 			}
+			continue
 		}
+		result = append(result, m)
+	}
+	var v interface{} = result
+	if len(result) == 1 {
+		v = result[0]
+	}
+	if len(malformed) > 0 {
+		return nil, &message.PartialDecodeError{Result: v, Malformed: malformed}
+	}
+	return v, nil
+}
+
+func (c *Converter) toMap(cols []string, fields []string) (map[string]interface{}, error) {
+	if len(cols) > 0 && len(fields) != len(cols) {
+		return nil, fmt.Errorf("expect %d fields but got %d", len(cols), len(fields))
+	}
+	m := make(map[string]interface{}, len(fields))
+	for i, f := range fields {
+		k := "col" + strconv.Itoa(i)
+		if len(cols) > 0 {
+			k = cols[i]
+		}
+		v, err := c.convert(k, f)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
 	}
 	return m, nil
 }
+
+// convert converts the value by the column type. The empty value of the typed column is null.
+func (c *Converter) convert(col string, f string) (interface{}, error) {
+	t, ok := c.types[col]
+	if !ok || t == ast.XSTRING {
+		return f, nil
+	}
+	if f == "" {
+		return nil, nil
+	}
+	var (
+		v   interface{}
+		err error
+	)
+	switch t {
+	case ast.XBIGINT:
+		v, err = strconv.ParseInt(f, 10, 64)
+	case ast.XFLOAT:
+		v, err = strconv.ParseFloat(f, 64)
+	case ast.XBOOLEAN:
+		v, err = strconv.ParseBool(f)
+	case ast.XDATETIME:
+		// the datetime is either the unix milli timestamp or the RFC3339 string
+		if ts, e := strconv.ParseInt(f, 10, 64); e == nil {
+			v = cast.TimeFromUnixMilli(ts)
+		} else {
+			v, err = time.Parse(time.RFC3339Nano, f)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert column %s value %s to %s", col, f, strings.ToLower(t))
+	}
+	return v, nil
+}
+
+type record struct {
+	fields []string
+	// raw is the row without the line break, which is sent to the dead letter queue if the row is malformed
+	raw []byte
+	err error
+}
+
+// readRecords splits the payload into records by RFC4180. The empty lines are ignored.
+func (c *Converter) readRecords(b []byte) []*record {
+	var records []*record
+	for pos := 0; pos < len(b); {
+		r, next := c.readRecord(b, pos)
+		if r != nil {
+			records = append(records, r)
+		}
+		pos = next
+	}
+	return records
+}
+
+// readRecord reads the record from the start and returns the start of the next record. The record is nil for the
+// empty line. If the record is malformed, the rest of the line is skipped.
+func (c *Converter) readRecord(b []byte, start int) (*record, int) {
+	if b[start] == '\n' {
+		return nil, start + 1
+	}
+	if b[start] == '\r' && (start+1 == len(b) || b[start+1] == '\n') {
+		return nil, start + 2
+	}
+	r := &record{}
+	for pos := start; ; {
+		f, next, end, err := c.readField(b, pos)
+		if err != nil {
+			lineEnd := len(b)
+			if i := bytes.IndexByte(b[next:], '\n'); i >= 0 {
+				lineEnd = next + i
+			}
+			r.raw = bytes.TrimSuffix(b[start:lineEnd], []byte{'\r'})
+			r.err = err
+			return r, lineEnd + 1
+		}
+		r.fields = append(r.fields, f)
+		if end {
+			r.raw = bytes.TrimRight(b[start:next], "\r\n")
+			return r, next
+		}
+		pos = next
+	}
+}
+
+// readField reads a field from the start. It returns the start of the next field and whether the field is the last
+// one of the record. If there is an error, the position is where the error happens.
+func (c *Converter) readField(b []byte, start int) (string, int, bool, error) {
+	if start < len(b) && b[start] == c.quote {
+		var f []byte
+		for i := start + 1; i < len(b); i++ {
+			ch := b[i]
+			if ch == c.escape && c.escape != c.quote && i+1 < len(b) && (b[i+1] == c.quote || b[i+1] == c.escape) {
+				f = append(f, b[i+1])
+				i++
+				continue
+			}
+			if ch != c.quote {
+				f = append(f, ch)
+				continue
+			}
+			if c.escape == c.quote && i+1 < len(b) && b[i+1] == c.quote {
+				f = append(f, ch)
+				i++
+				continue
+			}
+			// the closing quote
+			i++
+			switch {
+			case i == len(b):
+				return string(f), i, true, nil
+			case bytes.HasPrefix(b[i:], []byte(c.delimiter)):
+				return string(f), i + len(c.delimiter), false, nil
+			case b[i] == '\n':
+				return string(f), i + 1, true, nil
+			case b[i] == '\r' && (i+1 == len(b) || b[i+1] == '\n'):
+				return string(f), i + 2, true, nil
+			default:
+				return "", i, false, fmt.Errorf("extraneous %q after the closing quote", b[i])
+			}
+		}
+		return "", len(b), false, fmt.Errorf("quoted field is not closed")
+	}
+	for i := start; i < len(b); i++ {
+		switch {
+		case bytes.HasPrefix(b[i:], []byte(c.delimiter)):
+			return string(b[start:i]), i + len(c.delimiter), false, nil
+		case b[i] == '\n':
+			return strings.TrimSuffix(string(b[start:i]), "\r"), i + 1, true, nil
+		case b[i] == c.quote:
+			return "", i, false, fmt.Errorf("bare %q in non-quoted field", c.quote)
+		}
+	}
+	return strings.TrimSuffix(string(b[start:]), "\r"), len(b), true, nil
+}
@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -15,11 +15,14 @@
 package delimited
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/pkg/message"
 )
 
 func TestEncode(t *testing.T) {
@@ -48,7 +51,14 @@ func TestEncode(t *testing.T) {
 					},
 				},
 			},
-			r: []byte(`22:map[indoor:[Chess] outdoor:[Basketball]]:7:John Doe`),
+			r: []byte(`22:"map[indoor:[Chess] outdoor:[Basketball]]":7:John Doe`),
+		}, {
+			m: map[string]interface{}{
+				"id":   8,
+				"name": "Doe: \"John\"",
+				"note": "line1\nline2",
+			},
+			r: []byte("8:\"Doe: \"\"John\"\"\":\"line1\nline2\""),
 		},
 	}
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
@@ -114,3 +124,100 @@ func TestDecode(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeCsv(t *testing.T) {
+	ts := time.Date(2022, 12, 4, 16, 15, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		props map[string]interface{}
+		r     []byte
+		m     interface{}
+		e     string
+	}{
+		{
+			name: "quoted",
+			r:    []byte("1,\"a,b\",\"say \"\"hi\"\"\",\"x\ny\"\r\n"),
+			m: map[string]interface{}{
+				"col0": "1",
+				"col1": "a,b",
+				"col2": `say "hi"`,
+				"col3": "x\ny",
+			},
+		}, {
+			name:  "escape",
+			props: map[string]interface{}{"quote": "'", "escape": "\\"},
+			r:     []byte(`'it\'s','a\\b',"c"`),
+			m: map[string]interface{}{
+				"col0": "it's",
+				"col1": `a\b`,
+				"col2": `"c"`,
+			},
+		}, {
+			name:  "header",
+			props: map[string]interface{}{"hasHeader": true, "columnTypes": map[string]interface{}{"id": "bigint", "value": "float", "ok": "boolean", "ts": "datetime"}},
+			r:     []byte("id,value,ok,ts,name\n1,1.5,true,1670170500000,a\n\n2,,false,2022-12-04T16:15:00Z,b\n"),
+			m: []map[string]interface{}{
+				{"id": int64(1), "value": 1.5, "ok": true, "ts": ts, "name": "a"},
+				{"id": int64(2), "value": nil, "ok": false, "ts": ts, "name": "b"},
+			},
+		}, {
+			name:  "columns",
+			props: map[string]interface{}{"columns": []interface{}{"id", "name"}},
+			r:     []byte("1,a"),
+			m:     map[string]interface{}{"id": "1", "name": "a"},
+		}, {
+			name:  "malformed error",
+			props: map[string]interface{}{"hasHeader": true},
+			r:     []byte("id,name\n1,a\n2,b,c"),
+			e:     "malformed row 2,b,c: expect 2 fields but got 3",
+		}, {
+			name:  "malformed skip",
+			props: map[string]interface{}{"hasHeader": true, "columnTypes": map[string]interface{}{"id": "bigint"}, "malformedRow": "skip"},
+			r:     []byte("id,name\nx,a\n2,\"b\"c\n3,\"c\""),
+			m:     map[string]interface{}{"id": int64(3), "name": "c"},
+		}, {
+			name:  "invalid type",
+			props: map[string]interface{}{"columnTypes": map[string]interface{}{"id": "int"}},
+			e:     "unsupported type INT of column id, must be bigint, float, string, boolean or datetime",
+		}, {
+			name:  "invalid policy",
+			props: map[string]interface{}{"malformedRow": "ignore"},
+			e:     "invalid malformedRow ignore, must be error, skip or dlq",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := NewConverter(",")
+			err := c.(message.PropsConfigurer).Configure(tt.props)
+			if err == nil {
+				var a interface{}
+				a, err = c.Decode(tt.r)
+				if err == nil && !reflect.DeepEqual(tt.m, a) {
+					t.Errorf("result mismatch:\n\nexp=%v\n\ngot=%v\n\n", tt.m, a)
+				}
+			}
+			if !reflect.DeepEqual(tt.e, testx.Errstring(err)) {
+				t.Errorf("error mismatch:\n  exp=%s\n  got=%s\n\n", tt.e, err)
+			}
+		})
+	}
+}
+
+func TestDecodeMalformedDlq(t *testing.T) {
+	c, _ := NewConverter(",")
+	err := c.(message.PropsConfigurer).Configure(map[string]interface{}{"columns": []string{"id", "name"}, "malformedRow": "dlq"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Decode([]byte("1,a\n2,\"b\n3,c"))
+	var pe *message.PartialDecodeError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expect partial decode error but got %v", err)
+	}
+	if !reflect.DeepEqual(map[string]interface{}{"id": "1", "name": "a"}, pe.Result) {
+		t.Errorf("result mismatch, got %v", pe.Result)
+	}
+	if len(pe.Malformed) != 1 || string(pe.Malformed[0].Data) != "2,\"b\n3,c" || pe.Malformed[0].Err.Error() != "quoted field is not closed" {
+		t.Errorf("malformed rows mismatch, got %v", pe.Malformed[0])
+	}
+}
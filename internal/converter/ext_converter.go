@@ -17,6 +17,8 @@
 package converter
 
 import (
+	"github.com/lf-edge/ekuiper/internal/converter/avro"
+	"github.com/lf-edge/ekuiper/internal/converter/binary"
 	"github.com/lf-edge/ekuiper/internal/converter/custom"
 	"github.com/lf-edge/ekuiper/internal/converter/protobuf"
 	"github.com/lf-edge/ekuiper/internal/pkg/def"
@@ -30,7 +32,31 @@ func init() {
 		if err != nil {
 			return nil, err
 		}
+		if ffs.Registry != nil {
+			return protobuf.NewRegistryConverter(ffs.Registry, schemaMessageName)
+		}
 		return protobuf.NewConverter(ffs.SchemaFile, ffs.SoFile, schemaMessageName)
 	}
 	converters[message.FormatCustom] = custom.LoadConverter
+	converters[message.FormatAvro] = func(schemaFileName string, schemaMessageName string, _ string) (message.Converter, error) {
+		ffs, err := schema.GetSchemaFile(def.AVRO, schemaFileName)
+		if err != nil {
+			return nil, err
+		}
+		if ffs.Registry != nil {
+			return avro.NewRegistryConverter(ffs.Registry, schemaMessageName)
+		}
+		return avro.NewConverter(ffs.SchemaFile, schemaMessageName)
+	}
+	// the binary format without schema decodes the whole payload into a bytea field
+	converters[message.FormatBinary] = func(schemaFileName string, schemaMessageName string, _ string) (message.Converter, error) {
+		if schemaFileName == "" {
+			return binary.GetConverter()
+		}
+		ffs, err := schema.GetSchemaFile(def.BINARY, schemaFileName)
+		if err != nil {
+			return nil, err
+		}
+		return binary.NewFrameConverter(ffs.SchemaFile, schemaMessageName)
+	}
 }
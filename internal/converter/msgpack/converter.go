@@ -0,0 +1,92 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package msgpack
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+type Converter struct {
+	h *codec.MsgpackHandle
+}
+
+var converter = &Converter{h: newHandle()}
+
+func newHandle() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.SignedInteger = true
+	// Use the str8 and bin types and the timestamp extension of the new spec
+	h.WriteExt = true
+	h.Canonical = true
+	return h
+}
+
+func GetConverter() (message.Converter, error) {
+	return converter, nil
+}
+
+func (c *Converter) Encode(d interface{}) ([]byte, error) {
+	var b []byte
+	if err := codec.NewEncoderBytes(&b, c.h).Encode(d); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (c *Converter) Decode(b []byte) (interface{}, error) {
+	var r interface{}
+	if err := codec.NewDecoderBytes(b, c.h).Decode(&r); err != nil {
+		return nil, err
+	}
+	r = normalize(r)
+	switch r.(type) {
+	case map[string]interface{}, []interface{}:
+		return r, nil
+	default:
+		return nil, fmt.Errorf("only map and array are supported but got %v", r)
+	}
+}
+
+// normalize converts the decoded numbers to eKuiper types
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, mv := range t {
+			t[k] = normalize(mv)
+		}
+		return t
+	case []interface{}:
+		for i, av := range t {
+			t[i] = normalize(av)
+		}
+		return t
+	case uint64:
+		if t <= math.MaxInt64 {
+			return int64(t)
+		}
+		return float64(t)
+	case float32:
+		return float64(t)
+	default:
+		return v
+	}
+}
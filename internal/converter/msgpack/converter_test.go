@@ -0,0 +1,84 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package msgpack
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func TestEncode(t *testing.T) {
+	c, err := GetConverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		m interface{}
+		r []byte
+	}{
+		{
+			m: map[string]interface{}{"b": true, "a": 1},
+			r: []byte{0x82, 0xa1, 0x61, 0x01, 0xa1, 0x62, 0xc3},
+		}, {
+			m: []map[string]interface{}{{"a": -1.5}, {"a": []byte{0x01}}},
+			r: []byte{0x92, 0x81, 0xa1, 0x61, 0xcb, 0xbf, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x81, 0xa1, 0x61, 0xc4, 0x01, 0x01},
+		},
+	}
+	for i, tt := range tests {
+		r, err := c.Encode(tt.m)
+		if err != nil {
+			t.Errorf("%d encode error: %v", i, err)
+		} else if !reflect.DeepEqual(tt.r, r) {
+			t.Errorf("%d result mismatch:\n  exp=%x\n  got=%x", i, tt.r, r)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	c, err := GetConverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		b []byte
+		r interface{}
+		e string
+	}{
+		{
+			b: []byte{0x82, 0xa1, 0x61, 0x01, 0xa1, 0x62, 0xc3},
+			r: map[string]interface{}{"a": int64(1), "b": true},
+		}, {
+			// uint8, float32, bin and nested map
+			b: []byte{0x83, 0xa1, 0x61, 0xcc, 0xc8, 0xa1, 0x62, 0xca, 0x3f, 0xc0, 0x00, 0x00, 0xa1, 0x63, 0x92, 0xc4, 0x01, 0x01, 0x81, 0xa1, 0x64, 0xa1, 0x78},
+			r: map[string]interface{}{"a": int64(200), "b": 1.5, "c": []interface{}{[]byte{0x01}, map[string]interface{}{"d": "x"}}},
+		}, {
+			b: []byte{0x92, 0x81, 0xa1, 0x61, 0x01, 0x81, 0xa1, 0x61, 0x02},
+			r: []interface{}{map[string]interface{}{"a": int64(1)}, map[string]interface{}{"a": int64(2)}},
+		}, {
+			b: []byte{0x01},
+			e: "only map and array are supported but got 1",
+		},
+	}
+	for i, tt := range tests {
+		r, err := c.Decode(tt.b)
+		if !reflect.DeepEqual(tt.e, testx.Errstring(err)) {
+			t.Errorf("%d.error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.e, err)
+		} else if tt.e == "" && !reflect.DeepEqual(tt.r, r) {
+			t.Errorf("%d result mismatch:\n  exp=%v\n  got=%v", i, tt.r, r)
+		}
+	}
+}
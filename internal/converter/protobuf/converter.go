@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -16,48 +16,100 @@ package protobuf
 
 import (
 	"fmt"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/jhump/protoreflect/desc"
-	"github.com/jhump/protoreflect/desc/protoparse"
 
+	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/converter/static"
+	"github.com/lf-edge/ekuiper/internal/schema"
 	"github.com/lf-edge/ekuiper/pkg/message"
 )
 
+// reloadInterval is the min interval to check if the schema file is modified
+var reloadInterval = time.Second
+
 type Converter struct {
+	schemaFile  string
+	messageName string
+
+	sync.RWMutex
 	descriptor *desc.MessageDescriptor
 	fc         *FieldConverter
-}
-
-var protoParser *protoparse.Parser
-
-func init() {
-	protoParser = &protoparse.Parser{}
+	// modTime is the modification time of the loaded schema file
+	modTime   time.Time
+	checkedAt time.Time
 }
 
 func NewConverter(schemaFile string, soFile string, messageName string) (message.Converter, error) {
 	if soFile != "" {
 		return static.LoadStaticConverter(soFile, messageName)
-	} else {
-		if fds, err := protoParser.ParseFiles(schemaFile); err != nil {
-			return nil, fmt.Errorf("parse schema file %s failed: %s", schemaFile, err)
+	}
+	c := &Converter{
+		schemaFile:  schemaFile,
+		messageName: messageName,
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load parses the schema file which is a proto file or a descriptor set. The types in all the files are used to
+// resolve google.protobuf.Any.
+func (c *Converter) load() error {
+	fi, statErr := os.Stat(c.schemaFile)
+	fds, err := schema.ParseProtobuf(c.schemaFile)
+	if err != nil {
+		return err
+	}
+	md := schema.FindProtoMessage(fds, c.messageName)
+	if md == nil {
+		return fmt.Errorf("message type %s not found in schema file %s", c.messageName, c.schemaFile)
+	}
+	c.descriptor = md
+	c.fc = NewFieldConverter(fds...)
+	if statErr == nil {
+		c.modTime = fi.ModTime()
+	}
+	c.checkedAt = time.Now()
+	return nil
+}
+
+// current returns the message descriptor to encode and decode. The schema file is reloaded once it is modified, so
+// that the rules need not restart to use the new schema. The previous schema is kept if the new one is invalid.
+func (c *Converter) current() (*desc.MessageDescriptor, *FieldConverter) {
+	c.RLock()
+	md, fc, checkedAt := c.descriptor, c.fc, c.checkedAt
+	c.RUnlock()
+	if time.Since(checkedAt) < reloadInterval {
+		return md, fc
+	}
+	c.Lock()
+	defer c.Unlock()
+	if time.Since(c.checkedAt) < reloadInterval {
+		return c.descriptor, c.fc
+	}
+	c.checkedAt = time.Now()
+	fi, err := os.Stat(c.schemaFile)
+	if err == nil && !fi.ModTime().Equal(c.modTime) {
+		if err := c.load(); err != nil {
+			c.modTime = fi.ModTime()
+			conf.Log.Warnf("reload schema file %s failed, keep using the previous schema: %v", c.schemaFile, err)
 		} else {
-			messageDescriptor := fds[0].FindMessage(messageName)
-			if messageDescriptor == nil {
-				return nil, fmt.Errorf("message type %s not found in schema file %s", messageName, schemaFile)
-			}
-			return &Converter{
-				descriptor: messageDescriptor,
-				fc:         GetFieldConverter(),
-			}, nil
+			conf.Log.Infof("schema file %s is reloaded", c.schemaFile)
 		}
 	}
+	return c.descriptor, c.fc
 }
 
 func (c *Converter) Encode(d interface{}) ([]byte, error) {
 	switch m := d.(type) {
 	case map[string]interface{}:
-		msg, err := c.fc.encodeMap(c.descriptor, m)
+		md, fc := c.current()
+		msg, err := fc.EncodeMap(md, m)
 		if err != nil {
 			return nil, err
 		}
@@ -68,10 +120,11 @@ func (c *Converter) Encode(d interface{}) ([]byte, error) {
 }
 
 func (c *Converter) Decode(b []byte) (interface{}, error) {
-	result := mf.NewDynamicMessage(c.descriptor)
+	md, fc := c.current()
+	result := mf.NewDynamicMessage(md)
 	err := result.Unmarshal(b)
 	if err != nil {
 		return nil, err
 	}
-	return c.fc.DecodeMessage(result, c.descriptor), nil
+	return fc.DecodeMessage(result, md), nil
 }
@@ -20,6 +20,11 @@ import (
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto" //nolint:staticcheck
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc/protoparse"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/schema"
@@ -196,3 +201,154 @@ func TestStatic(t *testing.T) {
 		}
 	}
 }
+
+func TestOneOfAndAny(t *testing.T) {
+	c, err := NewConverter("../../schema/test/test4.proto", "", "Event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		m map[string]interface{}
+		e string
+	}{
+		{
+			m: map[string]interface{}{
+				"id":      "e1",
+				"reading": map[string]interface{}{"temperature": 25.5, "humidity": int64(60)},
+				"detail":  map[string]interface{}{"@type": "type.googleapis.com/demo.Alarm", "level": "high"},
+				"extras": []map[string]interface{}{
+					{"@type": "type.googleapis.com/demo.Reading", "temperature": 1.5, "humidity": int64(2)},
+					{"@type": "type.googleapis.com/google.protobuf.StringValue", "value": "note"},
+				},
+			},
+		}, {
+			m: map[string]interface{}{
+				"id":     "e2",
+				"text":   "hello",
+				"detail": nil,
+				"extras": []interface{}{},
+			},
+		}, {
+			m: map[string]interface{}{
+				"id":      "e3",
+				"reading": map[string]interface{}{"temperature": 25.5},
+				"text":    "hello",
+			},
+			e: "fields 'reading' and 'text' of oneof 'payload' cannot be both set",
+		}, {
+			m: map[string]interface{}{
+				"id":     "e4",
+				"detail": map[string]interface{}{"@type": "type.googleapis.com/demo.Unknown"},
+			},
+			e: "cannot resolve the type type.googleapis.com/demo.Unknown of google.protobuf.Any field 'detail'",
+		},
+	}
+	for i, tt := range tests {
+		a, err := c.Encode(tt.m)
+		if !reflect.DeepEqual(tt.e, testx.Errstring(err)) {
+			t.Errorf("%d.error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.e, err)
+			continue
+		}
+		if tt.e != "" {
+			continue
+		}
+		m, err := c.Decode(a)
+		if err != nil {
+			t.Errorf("%d.decode error: %v", i, err)
+		} else if !reflect.DeepEqual(tt.m, m) {
+			t.Errorf("%d. \n\nresult mismatch:\n\nexp=%v\n\ngot=%v\n\n", i, tt.m, m)
+		}
+	}
+}
+
+func TestDescriptorSet(t *testing.T) {
+	fds, err := schema.ParseProtobuf("../../schema/test/test4.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The Notice type is not imported by the file of Event, it can only be resolved by the descriptor set
+	notices, err := (&protoparse.Parser{Accessor: protoparse.FileContentsFromMap(map[string]string{
+		"notice.proto": `syntax = "proto3";package other;message Notice {string msg = 1;}`,
+	})}).ParseFiles("notice.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &dpb.FileDescriptorSet{File: []*dpb.FileDescriptorProto{
+		fds[0].GetDependencies()[0].AsFileDescriptorProto(),
+		fds[0].AsFileDescriptorProto(),
+		notices[0].AsFileDescriptorProto(),
+	}}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setFile := filepath.Join(t.TempDir(), "test4.desc")
+	if err := os.WriteFile(setFile, b, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewConverter(setFile, "", "Event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]interface{}{
+		"id":     "e1",
+		"text":   "hello",
+		"detail": map[string]interface{}{"@type": "type.googleapis.com/other.Notice", "msg": "high"},
+		"extras": []interface{}{},
+	}
+	a, err := c.Encode(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := c.Decode(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, r) {
+		t.Errorf("result mismatch:\n\nexp=%v\n\ngot=%v\n\n", m, r)
+	}
+	_, err = NewConverter(setFile, "", "Unknown")
+	if err == nil || err.Error() != fmt.Sprintf("message type Unknown not found in schema file %s", setFile) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	reloadInterval = 0
+	defer func() {
+		reloadInterval = time.Second
+	}()
+	schemaFile := filepath.Join(t.TempDir(), "person.proto")
+	if err := os.WriteFile(schemaFile, []byte(`syntax = "proto3";message Person {string name = 1;}`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewConverter(schemaFile, "", "Person")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte{0x0a, 0x04, 0x74, 0x65, 0x73, 0x74, 0x10, 0x01}
+	exp := []map[string]interface{}{
+		{"name": "test"},
+		{"name": "test", "id": int64(1)},
+		// the invalid schema is not loaded
+		{"name": "test", "id": int64(1)},
+	}
+	for i, content := range []string{"", `syntax = "proto3";message Person {string name = 1;int32 id = 2;}`, `syntax = "proto3";message Person {`} {
+		if content != "" {
+			if err := os.WriteFile(schemaFile, []byte(content), 0o666); err != nil {
+				t.Fatal(err)
+			}
+			mt := time.Now().Add(time.Duration(i) * time.Minute)
+			if err := os.Chtimes(schemaFile, mt, mt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r, err := c.Decode(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(exp[i], r) {
+			t.Errorf("%d. result mismatch:\n\nexp=%v\n\ngot=%v\n\n", i, exp[i], r)
+		}
+	}
+}
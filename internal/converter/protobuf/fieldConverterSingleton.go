@@ -16,6 +16,7 @@ package protobuf
 
 import (
 	"fmt"
+	"strings"
 
 	// TODO: replace with `google.golang.org/protobuf/proto` pkg.
 	"github.com/golang/protobuf/proto" //nolint:staticcheck
@@ -37,8 +38,12 @@ const (
 	WrapperUInt32 = "google.protobuf.UInt32Value"
 	WrapperUInt64 = "google.protobuf.UInt64Value"
 	WrapperVoid   = "google.protobuf.EMPTY"
+	TypeAny       = "google.protobuf.Any"
 )
 
+// AnyTypeKey is the key of the type url in the unpacked map of google.protobuf.Any as the json mapping of protobuf
+const AnyTypeKey = "@type"
+
 var WRAPPER_TYPES = map[string]struct{}{
 	WrapperBool:   {},
 	WrapperBytes:  {},
@@ -56,21 +61,86 @@ var (
 	mf                = dynamic.NewMessageFactoryWithDefaults()
 )
 
-type FieldConverter struct{}
+type FieldConverter struct {
+	// types are the message types by the full name to pack and unpack google.protobuf.Any. The types not found are
+	// searched in the file of the message containing the Any field and its dependencies
+	types map[string]*desc.MessageDescriptor
+}
 
 func GetFieldConverter() *FieldConverter {
 	return fieldConverterIns
 }
 
-func (fc *FieldConverter) encodeMap(im *desc.MessageDescriptor, i interface{}) (*dynamic.Message, error) {
+// NewFieldConverter creates a converter which resolves the types of google.protobuf.Any from the files, such as all
+// the files of a descriptor set
+func NewFieldConverter(fds ...*desc.FileDescriptor) *FieldConverter {
+	fc := &FieldConverter{types: make(map[string]*desc.MessageDescriptor)}
+	visited := make(map[string]struct{})
+	for _, fd := range fds {
+		addFileTypes(fc.types, fd, visited)
+	}
+	return fc
+}
+
+func addFileTypes(types map[string]*desc.MessageDescriptor, fd *desc.FileDescriptor, visited map[string]struct{}) {
+	if _, ok := visited[fd.GetName()]; ok {
+		return
+	}
+	visited[fd.GetName()] = struct{}{}
+	addMessageTypes(types, fd.GetMessageTypes())
+	for _, dep := range fd.GetDependencies() {
+		addFileTypes(types, dep, visited)
+	}
+}
+
+func addMessageTypes(types map[string]*desc.MessageDescriptor, mds []*desc.MessageDescriptor) {
+	for _, md := range mds {
+		types[md.GetFullyQualifiedName()] = md
+		addMessageTypes(types, md.GetNestedMessageTypes())
+	}
+}
+
+// findType finds the message type of the type url such as type.googleapis.com/package.Message
+func (fc *FieldConverter) findType(typeUrl string, owner *desc.MessageDescriptor) *desc.MessageDescriptor {
+	name := typeUrl[strings.LastIndexByte(typeUrl, '/')+1:]
+	if md, ok := fc.types[name]; ok {
+		return md
+	}
+	if owner != nil {
+		types := make(map[string]*desc.MessageDescriptor)
+		addFileTypes(types, owner.GetFile(), make(map[string]struct{}))
+		if md, ok := types[name]; ok {
+			return md
+		}
+	}
+	// The well known types such as the wrappers are always available
+	md, _ := desc.LoadMessageDescriptor(name)
+	return md
+}
+
+func (fc *FieldConverter) EncodeMap(im *desc.MessageDescriptor, i interface{}) (*dynamic.Message, error) {
 	result := mf.NewDynamicMessage(im)
 	fields := im.GetFields()
 	if m, ok := i.(map[string]interface{}); ok {
+		// the field set of each oneof
+		oneofs := make(map[string]string)
 		for _, field := range fields {
 			v, ok := m[field.GetName()]
-			if !ok {
+			if oo := field.GetOneOf(); oo != nil && !oo.IsSynthetic() {
+				// Only the field in the map is set, because setting a field clears the others of the oneof
+				if !ok || v == nil {
+					continue
+				}
+				if other, exists := oneofs[oo.GetName()]; exists {
+					return nil, fmt.Errorf("fields '%s' and '%s' of oneof '%s' cannot be both set", other, field.GetName(), oo.GetName())
+				}
+				oneofs[oo.GetName()] = field.GetName()
+			} else if !ok || (v == nil && field.GetMessageType() != nil) {
 				if field.IsRequired() {
 					return nil, fmt.Errorf("field %s not found", field.GetName())
+				} else if field.GetMessageType() != nil {
+					// The absent or nil message field is not set, it has no default value
+					continue
 				} else {
 					v = field.GetDefaultValue()
 				}
@@ -135,9 +205,9 @@ func (fc *FieldConverter) EncodeField(field *desc.FieldDescriptor, v interface{}
 			result, err = cast.ToBytesSlice(v, cast.STRICT)
 		case dpb.FieldDescriptorProto_TYPE_MESSAGE:
 			result, err = cast.ToTypedSlice(v, func(input interface{}, sn cast.Strictness) (interface{}, error) {
-				r, err := cast.ToStringMap(v)
+				r, err := cast.ToStringMap(input)
 				if err == nil {
-					return fc.encodeMap(field.GetMessageType(), r)
+					return fc.encodeMessage(field, r)
 				} else {
 					return nil, fmt.Errorf("invalid type for map type field '%s': %v", fn, err)
 				}
@@ -223,7 +293,7 @@ func (fc *FieldConverter) encodeSingleField(field *desc.FieldDescriptor, v inter
 	case dpb.FieldDescriptorProto_TYPE_MESSAGE:
 		r, err := cast.ToStringMap(v)
 		if err == nil {
-			return fc.encodeMap(field.GetMessageType(), r)
+			return fc.encodeMessage(field, r)
 		} else {
 			return nil, fmt.Errorf("invalid type for map type field '%s': %v", fn, err)
 		}
@@ -232,6 +302,49 @@ func (fc *FieldConverter) encodeSingleField(field *desc.FieldDescriptor, v inter
 	}
 }
 
+func (fc *FieldConverter) encodeMessage(field *desc.FieldDescriptor, m map[string]interface{}) (*dynamic.Message, error) {
+	if field.GetMessageType().GetFullyQualifiedName() == TypeAny {
+		if typeUrl, ok := m[AnyTypeKey].(string); ok {
+			return fc.encodeAny(field, typeUrl, m)
+		}
+	}
+	return fc.EncodeMap(field.GetMessageType(), m)
+}
+
+// encodeAny packs the map with the type url into google.protobuf.Any. The map without the type url is encoded as
+// the plain type_url and value fields.
+func (fc *FieldConverter) encodeAny(field *desc.FieldDescriptor, typeUrl string, m map[string]interface{}) (*dynamic.Message, error) {
+	md := fc.findType(typeUrl, field.GetOwner())
+	if md == nil {
+		return nil, fmt.Errorf("cannot resolve the type %s of google.protobuf.Any field '%s'", typeUrl, field.GetName())
+	}
+	var (
+		msg *dynamic.Message
+		err error
+	)
+	if _, ok := WRAPPER_TYPES[md.GetFullyQualifiedName()]; ok {
+		msg = mf.NewDynamicMessage(md)
+		v, err := fc.encodeSingleField(md.FindFieldByNumber(1), m["value"])
+		if err != nil {
+			return nil, err
+		}
+		msg.SetFieldByNumber(1, v)
+	} else {
+		msg, err = fc.EncodeMap(md, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	value, err := msg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	result := mf.NewDynamicMessage(field.GetMessageType())
+	result.SetFieldByName("type_url", typeUrl)
+	result.SetFieldByName("value", value)
+	return result, nil
+}
+
 func (fc *FieldConverter) DecodeField(src interface{}, field *desc.FieldDescriptor, sn cast.Strictness) (interface{}, error) {
 	var (
 		r interface{}
@@ -270,12 +383,18 @@ func (fc *FieldConverter) DecodeField(src interface{}, field *desc.FieldDescript
 			r, e = cast.ToBytes(src, sn)
 		}
 	case dpb.FieldDescriptorProto_TYPE_MESSAGE:
+		decode := func(input interface{}, ssn cast.Strictness) (interface{}, error) {
+			return fc.decodeSubMessage(input, field.GetMessageType(), ssn)
+		}
+		if field.GetMessageType().GetFullyQualifiedName() == TypeAny {
+			decode = func(input interface{}, ssn cast.Strictness) (interface{}, error) {
+				return fc.decodeAny(input, field.GetOwner(), ssn)
+			}
+		}
 		if field.IsRepeated() {
-			r, e = cast.ToTypedSlice(src, func(input interface{}, ssn cast.Strictness) (interface{}, error) {
-				return fc.decodeSubMessage(input, field.GetMessageType(), ssn)
-			}, "map", sn)
+			r, e = cast.ToTypedSlice(src, decode, "map", sn)
 		} else {
-			r, e = fc.decodeSubMessage(src, field.GetMessageType(), sn)
+			r, e = decode(src, sn)
 		}
 	default:
 		return nil, fmt.Errorf("unsupported type for %s", fn)
@@ -307,6 +426,58 @@ func (fc *FieldConverter) decodeSubMessage(input interface{}, ft *desc.MessageDe
 	}
 }
 
+// decodeAny unpacks google.protobuf.Any into the map of its fields and the type url in the @type key. The value of
+// the unknown type is kept as the map of type_url and value.
+func (fc *FieldConverter) decodeAny(input interface{}, owner *desc.MessageDescriptor, sn cast.Strictness) (interface{}, error) {
+	var (
+		typeUrl string
+		value   []byte
+	)
+	switch v := input.(type) {
+	case proto.Message:
+		message, err := dynamic.AsDynamicMessage(v)
+		if err != nil {
+			return nil, err
+		}
+		typeUrl, _ = message.GetFieldByName("type_url").(string)
+		value, _ = message.GetFieldByName("value").([]byte)
+	case map[string]interface{}:
+		// The map is already unpacked such as the json result of a rpc
+		if t, ok := v[AnyTypeKey].(string); ok {
+			if md := fc.findType(t, owner); md != nil {
+				r, err := fc.DecodeMap(v, md, sn)
+				if err != nil {
+					return nil, err
+				}
+				r[AnyTypeKey] = t
+				return r, nil
+			}
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("cannot decode %[1]T(%[1]v) to google.protobuf.Any", input)
+	}
+	// The unset Any is decoded as nil
+	if typeUrl == "" && len(value) == 0 {
+		return nil, nil
+	}
+	md := fc.findType(typeUrl, owner)
+	if md == nil {
+		return map[string]interface{}{"type_url": typeUrl, "value": value}, nil
+	}
+	message := mf.NewDynamicMessage(md)
+	if err := message.Unmarshal(value); err != nil {
+		return nil, fmt.Errorf("cannot unpack google.protobuf.Any of %s: %v", typeUrl, err)
+	}
+	r := fc.DecodeMessage(message, md)
+	if m, ok := r.(map[string]interface{}); ok {
+		m[AnyTypeKey] = typeUrl
+		return m, nil
+	}
+	// The wrapper types are unpacked into the value key
+	return map[string]interface{}{AnyTypeKey: typeUrl, "value": r}, nil
+}
+
 func (fc *FieldConverter) DecodeMap(src map[string]interface{}, ft *desc.MessageDescriptor, sn cast.Strictness) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 	for _, field := range ft.GetFields() {
@@ -339,6 +510,10 @@ func (fc *FieldConverter) DecodeMessage(message *dynamic.Message, outputType *de
 	}
 	result := make(map[string]interface{})
 	for _, field := range outputType.GetFields() {
+		// Only the field set of a oneof is decoded
+		if oo := field.GetOneOf(); oo != nil && !oo.IsSynthetic() && !message.HasField(field) {
+			continue
+		}
 		fc.decodeMessageField(message.GetField(field), field, result, cast.STRICT)
 	}
 	return result
@@ -0,0 +1,224 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protobuf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+
+	"github.com/lf-edge/ekuiper/internal/schema"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+// magicByte is the first byte of the Confluent wire format, followed by the 4 bytes schema id and the message indexes
+const magicByte = 0
+
+// RegistryConverter converts the payload in the wire format of the external schema registry.
+// The payload is decoded by the schema of the id in it, so that the producers can evolve the schema freely.
+// The payload without the magic byte is decoded by the registered schema as a plain protobuf message.
+type RegistryConverter struct {
+	// The registered schema which is used to encode
+	id         int
+	descriptor *desc.MessageDescriptor
+	indexes    []int
+	fc         *FieldConverter
+
+	sync.RWMutex
+	// cache of the schema files by id to decode
+	files map[int]*desc.FileDescriptor
+}
+
+func NewRegistryConverter(ref *schema.RegistryRef, messageName string) (message.Converter, error) {
+	rs, err := schema.FetchSubject(ref.Subject, ref.Version)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := parseRemote(rs)
+	if err != nil {
+		return nil, err
+	}
+	md := fd.FindMessage(messageName)
+	if md == nil {
+		return nil, fmt.Errorf("message type %s not found in schema %s", messageName, ref.Subject)
+	}
+	return &RegistryConverter{
+		id:         rs.ID,
+		descriptor: md,
+		indexes:    messageIndexes(md),
+		fc:         NewFieldConverter(fd),
+		files:      map[int]*desc.FileDescriptor{rs.ID: fd},
+	}, nil
+}
+
+func (c *RegistryConverter) Encode(d interface{}) ([]byte, error) {
+	m, ok := d.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %v, must be a map", d)
+	}
+	msg, err := c.fc.EncodeMap(c.descriptor, m)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := msg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]byte, 5, 5+len(c.indexes)+len(payload)+1)
+	result[0] = magicByte
+	binary.BigEndian.PutUint32(result[1:5], uint32(c.id))
+	// The most common case of the first message is optimized to a single 0
+	if len(c.indexes) == 1 && c.indexes[0] == 0 {
+		result = append(result, 0)
+	} else {
+		result = binary.AppendVarint(result, int64(len(c.indexes)))
+		for _, i := range c.indexes {
+			result = binary.AppendVarint(result, int64(i))
+		}
+	}
+	return append(result, payload...), nil
+}
+
+func (c *RegistryConverter) Decode(b []byte) (interface{}, error) {
+	md := c.descriptor
+	// Field number 0 is invalid in protobuf, so a leading 0 must be the magic byte
+	if len(b) > 0 && b[0] == magicByte {
+		if len(b) < 5 {
+			return nil, fmt.Errorf("invalid wire format, the payload is too short")
+		}
+		id := int(binary.BigEndian.Uint32(b[1:5]))
+		rest := b[5:]
+		indexes, n, err := readIndexes(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = rest[n:]
+		md, err = c.findMessage(id, indexes)
+		if err != nil {
+			return nil, err
+		}
+		b = rest
+	}
+	result := mf.NewDynamicMessage(md)
+	err := result.Unmarshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return c.fc.DecodeMessage(result, md), nil
+}
+
+func (c *RegistryConverter) findMessage(id int, indexes []int) (*desc.MessageDescriptor, error) {
+	c.RLock()
+	fd, ok := c.files[id]
+	c.RUnlock()
+	if !ok {
+		rs, err := schema.FetchById(id)
+		if err != nil {
+			return nil, err
+		}
+		fd, err = parseRemote(rs)
+		if err != nil {
+			return nil, err
+		}
+		c.Lock()
+		c.files[id] = fd
+		c.Unlock()
+	}
+	var (
+		md   *desc.MessageDescriptor
+		msgs = fd.GetMessageTypes()
+	)
+	for _, i := range indexes {
+		if i < 0 || i >= len(msgs) {
+			return nil, fmt.Errorf("message index %v not found in schema id %d", indexes, id)
+		}
+		md = msgs[i]
+		msgs = md.GetNestedMessageTypes()
+	}
+	return md, nil
+}
+
+// parseRemote parses the protobuf schema with all its references in memory
+func parseRemote(rs *schema.RemoteSchema) (*desc.FileDescriptor, error) {
+	if rs.Type != "PROTOBUF" {
+		return nil, fmt.Errorf("schema id %d is %s, but expect PROTOBUF", rs.ID, rs.Type)
+	}
+	name := fmt.Sprintf("$registry_%d.proto", rs.ID)
+	files := make(map[string]string, len(rs.Refs)+1)
+	for k, v := range rs.Refs {
+		files[k] = v
+	}
+	files[name] = rs.Schema
+	p := &protoparse.Parser{Accessor: protoparse.FileContentsFromMap(files)}
+	fds, err := p.ParseFiles(name)
+	if err != nil {
+		return nil, fmt.Errorf("parse schema id %d failed: %s", rs.ID, err)
+	}
+	return fds[0], nil
+}
+
+// messageIndexes returns the path of the message in the file, e.g. [1, 0] is the first nested message of the second
+// message
+func messageIndexes(md *desc.MessageDescriptor) []int {
+	var result []int
+	for {
+		var siblings []*desc.MessageDescriptor
+		parent := md.GetParent()
+		switch p := parent.(type) {
+		case *desc.MessageDescriptor:
+			siblings = p.GetNestedMessageTypes()
+		default:
+			siblings = md.GetFile().GetMessageTypes()
+		}
+		for i, s := range siblings {
+			if s == md {
+				result = append([]int{i}, result...)
+				break
+			}
+		}
+		pm, ok := parent.(*desc.MessageDescriptor)
+		if !ok {
+			return result
+		}
+		md = pm
+	}
+}
+
+// readIndexes reads the message indexes in zigzag varint. A single 0 is a shortcut of [0].
+func readIndexes(b []byte) ([]int, int, error) {
+	count, n := binary.Varint(b)
+	if n <= 0 {
+		return nil, 0, fmt.Errorf("invalid wire format, cannot read the message indexes")
+	}
+	if count == 0 {
+		return []int{0}, n, nil
+	}
+	if count < 0 || count > int64(len(b)) {
+		return nil, 0, fmt.Errorf("invalid wire format, message indexes count %d", count)
+	}
+	result := make([]int, count)
+	for i := range result {
+		v, m := binary.Varint(b[n:])
+		if m <= 0 {
+			return nil, 0, fmt.Errorf("invalid wire format, cannot read the message indexes")
+		}
+		result[i] = int(v)
+		n += m
+	}
+	return result, n, nil
+}
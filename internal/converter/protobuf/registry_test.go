@@ -0,0 +1,100 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protobuf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/schema"
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func TestRegistryConverter(t *testing.T) {
+	testx.InitEnv()
+	responses := map[string]string{
+		"/subjects/person/versions/1": `{"subject":"person","version":1,"id":21,"schemaType":"PROTOBUF","schema":"syntax = \"proto3\";message Other {string a = 1;}message Person {string name = 1;int64 id = 2;message Phone {string number = 1;}}"}`,
+		"/schemas/ids/22":             `{"schemaType":"PROTOBUF","schema":"syntax = \"proto3\";message Person {string name = 1;int64 id = 2;string email = 3;}"}`,
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	defer s.Close()
+	conf.Config.SchemaRegistry = conf.SchemaRegistryConf{Type: "confluent", Url: s.URL, Timeout: 1000}
+	defer func() {
+		conf.Config.SchemaRegistry = conf.SchemaRegistryConf{}
+	}()
+
+	c, err := NewRegistryConverter(&schema.RegistryRef{Subject: "person", Version: "1"}, "Person")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Encode(map[string]interface{}{"name": "test", "id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// magic byte, id 21, message indexes [1] which is count 1 and index 1 in zigzag
+	exp := []byte{0x00, 0x00, 0x00, 0x00, 0x15, 0x02, 0x02, 0x0a, 0x04, 0x74, 0x65, 0x73, 0x74, 0x10, 0x01}
+	if !reflect.DeepEqual(exp, b) {
+		t.Errorf("encode mismatch, expect %x but got %x", exp, b)
+	}
+	tests := []struct {
+		b []byte
+		r map[string]interface{}
+		e string
+	}{
+		{
+			b: exp,
+			r: map[string]interface{}{"name": "test", "id": int64(1)},
+		}, {
+			// plain protobuf without the wire format
+			b: []byte{0x0a, 0x04, 0x74, 0x65, 0x73, 0x74, 0x10, 0x01},
+			r: map[string]interface{}{"name": "test", "id": int64(1)},
+		}, {
+			// nested message indexes [1, 0]
+			b: []byte{0x00, 0x00, 0x00, 0x00, 0x15, 0x04, 0x02, 0x00, 0x0a, 0x01, 0x31},
+			r: map[string]interface{}{"number": "1"},
+		}, {
+			// a newer schema id 22 with message indexes [0]
+			b: []byte{0x00, 0x00, 0x00, 0x00, 0x16, 0x00, 0x0a, 0x01, 0x61, 0x10, 0x02, 0x1a, 0x01, 0x62},
+			r: map[string]interface{}{"name": "a", "id": int64(2), "email": "b"},
+		}, {
+			b: []byte{0x00, 0x00, 0x00, 0x00, 0x17, 0x00, 0x0a, 0x01, 0x61},
+			e: "fetch schema id 23 error: registry returns status 404: ",
+		}, {
+			b: []byte{0x00, 0x00, 0x00, 0x00, 0x15, 0x02, 0x06},
+			e: "message index [3] not found in schema id 21",
+		}, {
+			b: []byte{0x00, 0x00, 0x15},
+			e: "invalid wire format, the payload is too short",
+		},
+	}
+	for i, tt := range tests {
+		r, err := c.Decode(tt.b)
+		if !reflect.DeepEqual(tt.e, testx.Errstring(err)) {
+			t.Errorf("%d.error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.e, err)
+		} else if tt.e == "" && !reflect.DeepEqual(tt.r, r) {
+			t.Errorf("%d. \n\nresult mismatch:\n\nexp=%v\n\ngot=%v\n\n", i, tt.r, r)
+		}
+	}
+}
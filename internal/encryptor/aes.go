@@ -0,0 +1,90 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryptor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// aesGcm encrypts the payload with a random nonce which is prepended to the sealed data.
+// The AEAD is safe for concurrent use.
+type aesGcm struct {
+	aead cipher.AEAD
+}
+
+func newAesGcm(key []byte) (*aesGcm, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid aes key: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGcm{aead: aead}, nil
+}
+
+func (a *aesGcm) Encrypt(data []byte) ([]byte, error) {
+	nonce := make([]byte, a.aead.NonceSize(), a.aead.NonceSize()+len(data)+a.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("fail to generate nonce: %v", err)
+	}
+	return a.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (a *aesGcm) Decrypt(data []byte) ([]byte, error) {
+	ns := a.aead.NonceSize()
+	if len(data) < ns+a.aead.Overhead() {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	result, err := a.aead.Open(nil, data[:ns], data[ns:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decrypt: %v", err)
+	}
+	return result, nil
+}
+
+// loadKey reads the base64 encoded key. The key can refer to an environment variable by env:NAME
+// or a key file by file:PATH
+func loadKey(ref string) ([]byte, error) {
+	v := ref
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		var ok bool
+		v, ok = os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("aes key environment variable %s is not set", name)
+		}
+	case strings.HasPrefix(ref, "file:"):
+		b, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return nil, fmt.Errorf("fail to read aes key file: %v", err)
+		}
+		v = string(b)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+	if err != nil {
+		return nil, fmt.Errorf("aes key must be base64 encoded: %v", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryptor
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+func TestAesGcm(t *testing.T) {
+	conf.InitConf()
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	conf.Config.Basic.AesKey = key
+	defer func() {
+		conf.Config.Basic.AesKey = ""
+	}()
+	en, err := GetEncryptor(AES)
+	if err != nil {
+		t.Fatal(err)
+	}
+	de, err := GetDecryptor(AES)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"temperature":23.5}`)
+	e1, err := en.Encrypt(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e2, err := en.Encrypt(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(e1, e2) || bytes.Contains(e1, payload) {
+		t.Errorf("expect different ciphertexts with random nonces")
+	}
+	for _, e := range [][]byte{e1, e2} {
+		r, err := de.Decrypt(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(r, payload) {
+			t.Errorf("expect %s but got %s", payload, r)
+		}
+	}
+	e1[len(e1)-1] ^= 1
+	if _, err := de.Decrypt(e1); err == nil {
+		t.Errorf("expect error for the tampered data")
+	}
+	if _, err := de.Decrypt([]byte("short")); err == nil || err.Error() != "encrypted data is too short" {
+		t.Errorf("expect too short error but got %v", err)
+	}
+	if _, err := GetEncryptor("des"); err == nil || err.Error() != "unsupported encryptor: des" {
+		t.Errorf("expect unsupported error but got %v", err)
+	}
+	conf.Config.Basic.AesKey = ""
+	if _, err := GetEncryptor(AES); err == nil || err.Error() != "aes key is not configured in basic.aesKey" {
+		t.Errorf("expect key error but got %v", err)
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	raw := []byte("0123456789abcdef")
+	key := base64.StdEncoding.EncodeToString(raw)
+	t.Setenv("TEST_AES_KEY", key)
+	fn := filepath.Join(t.TempDir(), "aes.key")
+	if err := os.WriteFile(fn, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ref string
+		err string
+	}{
+		{ref: key},
+		{ref: "env:TEST_AES_KEY"},
+		{ref: "file:" + fn},
+		{ref: "env:TEST_AES_KEY_NOT_EXIST", err: "aes key environment variable TEST_AES_KEY_NOT_EXIST is not set"},
+		{ref: "not base64!", err: "aes key must be base64 encoded: illegal base64 data at input byte 3"},
+	}
+	for i, tt := range tests {
+		k, err := loadKey(tt.ref)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+		} else if !bytes.Equal(k, raw) {
+			t.Errorf("%d: key mismatch", i)
+		}
+	}
+	if _, err := newAesGcm([]byte("short")); err == nil || err.Error() != "invalid aes key: crypto/aes: invalid key size 5" {
+		t.Errorf("expect invalid key error but got %v", err)
+	}
+}
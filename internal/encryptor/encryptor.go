@@ -0,0 +1,50 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryptor
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+// AES is the AES-GCM algorithm with the key configured by basic.aesKey
+const AES = "aes"
+
+func GetEncryptor(name string) (message.Encryptor, error) {
+	if name == AES {
+		return newAesGcmFromConf()
+	}
+	return nil, fmt.Errorf("unsupported encryptor: %s", name)
+}
+
+func GetDecryptor(name string) (message.Decryptor, error) {
+	if name == AES {
+		return newAesGcmFromConf()
+	}
+	return nil, fmt.Errorf("unsupported decryptor: %s", name)
+}
+
+func newAesGcmFromConf() (*aesGcm, error) {
+	if conf.Config == nil || conf.Config.Basic.AesKey == "" {
+		return nil, fmt.Errorf("aes key is not configured in basic.aesKey")
+	}
+	key, err := loadKey(conf.Config.Basic.AesKey)
+	if err != nil {
+		return nil, err
+	}
+	return newAesGcm(key)
+}
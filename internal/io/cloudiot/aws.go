@@ -0,0 +1,198 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cloudiot || !core
+
+package cloudiot
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const (
+	AuthX509  = "x509"
+	AuthSigV4 = "sigv4"
+	// the max lifetime of the presigned url of AWS IoT
+	maxUrlTtl = 604800
+)
+
+type awsConf struct {
+	// Endpoint is the device data endpoint like xxx-ats.iot.us-east-1.amazonaws.com
+	Endpoint string `json:"endpoint"`
+	// Port defaults to 8883 for x509 and 443 for sigv4
+	Port     int    `json:"port"`
+	ClientId string `json:"clientId"`
+	// Topic is the topic to publish, it can be a data template
+	Topic string `json:"topic"`
+	// BasicIngestRule is the rule name to send the messages to the rule directly by the basic ingest topic $aws/rules/<rule>
+	BasicIngestRule string `json:"basicIngestRule"`
+	Qos             int    `json:"qos"`
+	// AuthMode is x509 to authenticate by the certificate or sigv4 to connect by the presigned websocket url
+	AuthMode          string `json:"authMode"`
+	CertificationPath string `json:"certificationPath"`
+	PrivateKeyPath    string `json:"privateKeyPath"`
+	RootCaPath        string `json:"rootCaPath"`
+	// Region is parsed from the endpoint if not set
+	Region string `json:"region"`
+	// AccessKey, SecretKey and SessionToken are read from the AWS environment variables if not set
+	AccessKey    string `json:"accessKey"`
+	SecretKey    string `json:"secretKey"`
+	SessionToken string `json:"sessionToken"`
+	// UrlTtl is the lifetime in seconds of the presigned url
+	UrlTtl int `json:"urlTtl"`
+}
+
+type awsSink struct {
+	iotSink
+	c *awsConf
+}
+
+func (s *awsSink) Configure(props map[string]interface{}) error {
+	c := &awsConf{
+		AuthMode: AuthX509,
+		UrlTtl:   86400,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Endpoint == "" {
+		return fmt.Errorf("endpoint is required")
+	}
+	if c.Topic == "" && c.BasicIngestRule == "" {
+		return fmt.Errorf("either topic or basicIngestRule is required")
+	}
+	switch c.AuthMode {
+	case AuthX509:
+		if c.CertificationPath == "" || c.PrivateKeyPath == "" {
+			return fmt.Errorf("certificationPath and privateKeyPath are required for x509 authMode")
+		}
+		if c.Port == 0 {
+			c.Port = 8883
+		}
+	case AuthSigV4:
+		if c.Region == "" {
+			c.Region = regionOf(c.Endpoint)
+			if c.Region == "" {
+				return fmt.Errorf("region is required")
+			}
+		}
+		if c.AccessKey == "" && c.SecretKey == "" {
+			c.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+			c.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+			c.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+		if c.AccessKey == "" || c.SecretKey == "" {
+			return fmt.Errorf("accessKey and secretKey are required for sigv4 authMode")
+		}
+		if c.UrlTtl <= 0 || c.UrlTtl > maxUrlTtl {
+			return fmt.Errorf("urlTtl must be between 1 and %d", maxUrlTtl)
+		}
+		if c.Port == 0 {
+			c.Port = 443
+		}
+	default:
+		return fmt.Errorf("invalid authMode %s, must be x509 or sigv4", c.AuthMode)
+	}
+	qos, err := parseQos(c.Qos)
+	if err != nil {
+		return err
+	}
+	s.c = c
+	s.iotSink = iotSink{
+		name:    "aws iot",
+		qos:     qos,
+		connect: s.connectProps,
+		topic:   s.buildTopic,
+	}
+	return nil
+}
+
+// regionOf parses the region from the endpoint like xxx-ats.iot.us-east-1.amazonaws.com
+func regionOf(endpoint string) string {
+	parts := strings.Split(endpoint, ".")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "iot" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// connectProps authenticates by the X.509 certificate through mqtt over tls or by the presigned url through mqtt over
+// websocket. The presigned url is only verified when connecting, so it is renewed before expiration for the reconnection
+func (s *awsSink) connectProps(now time.Time) (map[string]interface{}, time.Time, error) {
+	host := net.JoinHostPort(s.c.Endpoint, strconv.Itoa(s.c.Port))
+	props := map[string]interface{}{
+		// AWS IoT only supports MQTT 3.1.1 over websocket
+		"protocolVersion":   "3.1.1",
+		"clientid":          s.c.ClientId,
+		"certificationPath": s.c.CertificationPath,
+		"privateKeyPath":    s.c.PrivateKeyPath,
+		"rootCaPath":        s.c.RootCaPath,
+	}
+	if s.c.AuthMode == AuthX509 {
+		props["server"] = "ssl://" + host
+		return props, time.Time{}, nil
+	}
+	q := presign(&signRequest{
+		host:         s.c.Endpoint,
+		path:         "/mqtt",
+		region:       s.c.Region,
+		service:      "iotdevicegateway",
+		accessKey:    s.c.AccessKey,
+		secretKey:    s.c.SecretKey,
+		expires:      s.c.UrlTtl,
+		payloadHash:  emptyPayloadHash,
+		sessionToken: s.c.SessionToken,
+	}, now)
+	props["server"] = "wss://" + host + "/mqtt?" + q
+	ttl := time.Duration(s.c.UrlTtl) * time.Second
+	return props, now.Add(ttl * 4 / 5), nil
+}
+
+func (s *awsSink) buildTopic(ctx api.StreamContext, item interface{}) (string, error) {
+	var (
+		tpc string
+		err error
+	)
+	if s.c.Topic != "" {
+		tpc, err = ctx.ParseTemplate(s.c.Topic, item)
+		if err != nil {
+			return "", err
+		}
+	}
+	if s.c.BasicIngestRule != "" {
+		prefix := "$aws/rules/" + s.c.BasicIngestRule
+		if tpc == "" {
+			return prefix, nil
+		}
+		return prefix + "/" + strings.TrimPrefix(tpc, "/"), nil
+	}
+	if tpc == "" {
+		return "", fmt.Errorf("the topic is empty")
+	}
+	return tpc, nil
+}
+
+func GetAwsSink() api.Sink {
+	return &awsSink{}
+}
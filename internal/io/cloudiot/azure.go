@@ -0,0 +1,210 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cloudiot || !core
+
+package cloudiot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const azureApiVersion = "2021-04-12"
+
+type azureConf struct {
+	// ConnectionString is the device connection string like HostName=xx.azure-devices.net;DeviceId=xx;SharedAccessKey=xx,
+	// it is an alternative of the hostName, deviceId, moduleId and sharedAccessKey
+	ConnectionString string `json:"connectionString"`
+	HostName         string `json:"hostName"`
+	DeviceId         string `json:"deviceId"`
+	ModuleId         string `json:"moduleId"`
+	// SharedAccessKey is the base64 encoded key to sign the SAS token. The X.509 certificate is used if it is not set
+	SharedAccessKey string `json:"sharedAccessKey"`
+	// TokenTtl is the lifetime in seconds of the SAS token
+	TokenTtl          int    `json:"tokenTtl"`
+	CertificationPath string `json:"certificationPath"`
+	PrivateKeyPath    string `json:"privateKeyPath"`
+	RootCaPath        string `json:"rootCaPath"`
+	Qos               int    `json:"qos"`
+	ContentType       string `json:"contentType"`
+	ContentEncoding   string `json:"contentEncoding"`
+	// Properties are the application properties of the message, the values can be data templates
+	Properties map[string]string `json:"properties"`
+}
+
+func (c *azureConf) parseConnectionString() error {
+	for _, part := range strings.Split(c.ConnectionString, ";") {
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("invalid connectionString part %s", part)
+		}
+		switch k {
+		case "HostName":
+			c.HostName = v
+		case "DeviceId":
+			c.DeviceId = v
+		case "ModuleId":
+			c.ModuleId = v
+		case "SharedAccessKey":
+			c.SharedAccessKey = v
+		}
+	}
+	return nil
+}
+
+func (c *azureConf) identity() string {
+	if c.ModuleId != "" {
+		return c.DeviceId + "/" + c.ModuleId
+	}
+	return c.DeviceId
+}
+
+// resource is the resource uri of the SAS token and also the topic prefix
+func (c *azureConf) resource() string {
+	r := "devices/" + c.DeviceId
+	if c.ModuleId != "" {
+		r += "/modules/" + c.ModuleId
+	}
+	return r
+}
+
+type azureSink struct {
+	iotSink
+	c *azureConf
+	// systemProps is the encoded system properties of the content type and encoding to route by the message body
+	systemProps string
+}
+
+func (s *azureSink) Configure(props map[string]interface{}) error {
+	c := &azureConf{
+		TokenTtl:        3600,
+		ContentType:     "application/json",
+		ContentEncoding: "utf-8",
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.ConnectionString != "" {
+		if err := c.parseConnectionString(); err != nil {
+			return err
+		}
+	}
+	if c.HostName == "" {
+		return fmt.Errorf("hostName is required")
+	}
+	if c.DeviceId == "" {
+		return fmt.Errorf("deviceId is required")
+	}
+	if c.SharedAccessKey != "" {
+		if _, err := base64.StdEncoding.DecodeString(c.SharedAccessKey); err != nil {
+			return fmt.Errorf("invalid sharedAccessKey: %v", err)
+		}
+		if c.TokenTtl <= 0 {
+			return fmt.Errorf("tokenTtl must be positive")
+		}
+	} else if c.CertificationPath == "" || c.PrivateKeyPath == "" {
+		return fmt.Errorf("either sharedAccessKey or certificationPath and privateKeyPath are required")
+	}
+	qos, err := parseQos(c.Qos)
+	if err != nil {
+		return err
+	}
+	var sp []string
+	if c.ContentType != "" {
+		sp = append(sp, "$.ct="+url.QueryEscape(c.ContentType))
+	}
+	if c.ContentEncoding != "" {
+		sp = append(sp, "$.ce="+url.QueryEscape(c.ContentEncoding))
+	}
+	s.systemProps = strings.Join(sp, "&")
+	s.c = c
+	s.iotSink = iotSink{
+		name:    "azure iot hub",
+		qos:     qos,
+		connect: s.connectProps,
+		topic:   s.buildTopic,
+	}
+	return nil
+}
+
+// connectProps authenticates the device by the SAS token as the password or the X.509 certificate
+func (s *azureSink) connectProps(now time.Time) (map[string]interface{}, time.Time, error) {
+	props := map[string]interface{}{
+		"server":            "ssl://" + s.c.HostName + ":8883",
+		"protocolVersion":   "3.1.1",
+		"clientid":          s.c.identity(),
+		"username":          s.c.HostName + "/" + s.c.identity() + "/?api-version=" + azureApiVersion,
+		"certificationPath": s.c.CertificationPath,
+		"privateKeyPath":    s.c.PrivateKeyPath,
+		"rootCaPath":        s.c.RootCaPath,
+	}
+	if s.c.SharedAccessKey == "" {
+		return props, time.Time{}, nil
+	}
+	ttl := time.Duration(s.c.TokenTtl) * time.Second
+	token, err := sasToken(s.c.HostName+"/"+s.c.resource(), s.c.SharedAccessKey, now.Add(ttl))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	props["password"] = token
+	// renew the token before it expires
+	return props, now.Add(ttl * 4 / 5), nil
+}
+
+// buildTopic builds the device to cloud topic with the url encoded properties
+func (s *azureSink) buildTopic(ctx api.StreamContext, item interface{}) (string, error) {
+	tpc := s.c.resource() + "/messages/events/" + s.systemProps
+	if len(s.c.Properties) > 0 {
+		q, err := parseProperties(ctx, s.c.Properties, item)
+		if err != nil {
+			return "", err
+		}
+		if s.systemProps != "" {
+			tpc += "&"
+		}
+		tpc += q
+	}
+	return tpc, nil
+}
+
+// sasToken generates the shared access signature of the resource uri which expires at the given time
+func sasToken(uri string, key string, expiry time.Time) (string, error) {
+	k, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("invalid sharedAccessKey: %v", err)
+	}
+	sr := url.QueryEscape(uri)
+	se := strconv.FormatInt(expiry.Unix(), 10)
+	mac := hmac.New(sha256.New, k)
+	mac.Write([]byte(sr + "\n" + se))
+	sig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return "SharedAccessSignature sr=" + sr + "&sig=" + url.QueryEscape(sig) + "&se=" + se, nil
+}
+
+func GetAzureSink() api.Sink {
+	return &azureSink{}
+}
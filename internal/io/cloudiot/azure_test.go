@@ -0,0 +1,180 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cloudiot || !core
+
+package cloudiot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+)
+
+func TestSasToken(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("secret"))
+	token, err := sasToken("hub1.azure-devices.net/devices/dev1", key, time.Unix(1680000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("hub1.azure-devices.net%2Fdevices%2Fdev1\n1680000000"))
+	sig := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	exp := "SharedAccessSignature sr=hub1.azure-devices.net%2Fdevices%2Fdev1&sig=" + sig + "&se=1680000000"
+	if token != exp {
+		t.Errorf("expect %s but got %s", exp, token)
+	}
+	if _, err := sasToken("hub1", "not base64!", time.Now()); err == nil {
+		t.Errorf("expect error for invalid key")
+	}
+}
+
+func TestAzureConfigure(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("secret"))
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"deviceId": "dev1", "sharedAccessKey": key},
+			err:   "hostName is required",
+		}, {
+			props: map[string]interface{}{"connectionString": "HostName=hub1.azure-devices.net;SharedAccessKey=" + key},
+			err:   "deviceId is required",
+		}, {
+			props: map[string]interface{}{"connectionString": "HostName"},
+			err:   "invalid connectionString part HostName",
+		}, {
+			props: map[string]interface{}{"hostName": "hub1.azure-devices.net", "deviceId": "dev1"},
+			err:   "either sharedAccessKey or certificationPath and privateKeyPath are required",
+		}, {
+			props: map[string]interface{}{"hostName": "hub1.azure-devices.net", "deviceId": "dev1", "sharedAccessKey": key, "tokenTtl": 0},
+			err:   "tokenTtl must be positive",
+		}, {
+			props: map[string]interface{}{"hostName": "hub1.azure-devices.net", "deviceId": "dev1", "sharedAccessKey": key, "qos": 2},
+			err:   "invalid qos 2, the cloud IoT services only support qos 0 or 1",
+		}, {
+			props: map[string]interface{}{"connectionString": "HostName=hub1.azure-devices.net;DeviceId=dev1;SharedAccessKey=" + key},
+		},
+	}
+	for i, tt := range tests {
+		err := GetAzureSink().Configure(tt.props)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%d: unexpected error %v", i, err)
+			}
+		} else if err == nil || err.Error() != tt.err {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestAzureConnectProps(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("secret"))
+	s := &azureSink{}
+	err := s.Configure(map[string]interface{}{
+		"connectionString": "HostName=hub1.azure-devices.net;DeviceId=dev1;ModuleId=mod1;SharedAccessKey=" + key,
+		"tokenTtl":         100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1680000000, 0)
+	props, renewAt, err := s.connectProps(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := sasToken("hub1.azure-devices.net/devices/dev1/modules/mod1", key, now.Add(100*time.Second))
+	exp := map[string]interface{}{
+		"server":            "ssl://hub1.azure-devices.net:8883",
+		"protocolVersion":   "3.1.1",
+		"clientid":          "dev1/mod1",
+		"username":          "hub1.azure-devices.net/dev1/mod1/?api-version=2021-04-12",
+		"password":          token,
+		"certificationPath": "",
+		"privateKeyPath":    "",
+		"rootCaPath":        "",
+	}
+	if !reflect.DeepEqual(props, exp) {
+		t.Errorf("expect %v but got %v", exp, props)
+	}
+	if !renewAt.Equal(now.Add(80 * time.Second)) {
+		t.Errorf("expect renew at 80s later but got %v", renewAt)
+	}
+
+	s = &azureSink{}
+	err = s.Configure(map[string]interface{}{
+		"hostName":          "hub1.azure-devices.net",
+		"deviceId":          "dev1",
+		"certificationPath": "dev1.crt",
+		"privateKeyPath":    "dev1.key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	props, renewAt, err = s.connectProps(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := props["password"]; ok || !renewAt.IsZero() {
+		t.Errorf("expect no password and renewal for x509 but got %v and %v", props, renewAt)
+	}
+}
+
+func TestAzureTopic(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("secret"))
+	tests := []struct {
+		props map[string]interface{}
+		topic string
+	}{
+		{
+			props: map[string]interface{}{},
+			topic: "devices/dev1/messages/events/$.ct=application%2Fjson&$.ce=utf-8",
+		}, {
+			props: map[string]interface{}{"contentType": "", "contentEncoding": ""},
+			topic: "devices/dev1/messages/events/",
+		}, {
+			props: map[string]interface{}{"properties": map[string]interface{}{"level": "{{.level}}", "site": "a b"}},
+			topic: "devices/dev1/messages/events/$.ct=application%2Fjson&$.ce=utf-8&level=high%2F1&site=a+b",
+		}, {
+			props: map[string]interface{}{"contentType": "", "contentEncoding": "", "properties": map[string]interface{}{"level": "{{.level}}"}},
+			topic: "devices/dev1/messages/events/level=high%2F1",
+		},
+	}
+	ctx := mockContext.NewMockContext("rule1", "op1")
+	for i, tt := range tests {
+		tt.props["hostName"] = "hub1.azure-devices.net"
+		tt.props["deviceId"] = "dev1"
+		tt.props["sharedAccessKey"] = key
+		s := &azureSink{}
+		if err := s.Configure(tt.props); err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		tpc, err := s.buildTopic(ctx, map[string]interface{}{"level": "high/1"})
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if tpc != tt.topic {
+			t.Errorf("%d: expect topic %s but got %s", i, tt.topic, tpc)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cloudiot || !core
+
+package cloudiot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	// the hex sha256 of the empty payload
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+type signRequest struct {
+	host        string
+	path        string
+	region      string
+	service     string
+	accessKey   string
+	secretKey   string
+	expires     int
+	payloadHash string
+	// sessionToken of the temporary credential is appended after signing as required by AWS IoT
+	sessionToken string
+}
+
+// presign generates the query of the GET request presigned by AWS signature version 4 with the host header signed
+func presign(r *signRequest, now time.Time) string {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + r.region + "/" + r.service + "/aws4_request"
+	params := map[string]string{
+		"X-Amz-Algorithm":     sigV4Algorithm,
+		"X-Amz-Credential":    r.accessKey + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.Itoa(r.expires),
+		"X-Amz-SignedHeaders": "host",
+	}
+	q := canonicalQuery(params)
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		r.path,
+		q,
+		"host:" + r.host + "\n",
+		"host",
+		r.payloadHash,
+	}, "\n")
+	h := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := sigV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(h[:])
+	key := hmacSha256([]byte("AWS4"+r.secretKey), date)
+	for _, s := range []string{r.region, r.service, "aws4_request"} {
+		key = hmacSha256(key, s)
+	}
+	q += "&X-Amz-Signature=" + hex.EncodeToString(hmacSha256(key, stringToSign))
+	if r.sessionToken != "" {
+		q += "&X-Amz-Security-Token=" + uriEncode(r.sessionToken)
+	}
+	return q
+}
+
+func canonicalQuery(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = uriEncode(k) + "=" + uriEncode(params[k])
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode encodes all the characters except the unreserved characters of RFC 3986
+func uriEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
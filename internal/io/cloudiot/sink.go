@@ -0,0 +1,142 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build cloudiot || !core
+
+package cloudiot
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+// connector generates the mqtt client properties with the provider specific credential. The credential expires
+// and the client must be recreated after the returned renew time, the zero time means the credential never expires
+type connector func(now time.Time) (map[string]interface{}, time.Time, error)
+
+// topicFunc builds the topic of the result by the topic conventions of the provider
+type topicFunc func(ctx api.StreamContext, item interface{}) (string, error)
+
+// iotSink publishes the results to the mqtt broker of the cloud IoT service. The results batched by the batchSize
+// property of the sink are sent as a json array in one message
+type iotSink struct {
+	name    string
+	qos     byte
+	connect connector
+	topic   topicFunc
+
+	cli     api.MessageClient
+	renewAt time.Time
+}
+
+func (s *iotSink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("opening %s sink", s.name)
+	return s.open()
+}
+
+func (s *iotSink) open() error {
+	props, renewAt, err := s.connect(conf.GetNow())
+	if err != nil {
+		return err
+	}
+	cli, err := clients.GetClient("mqtt", props)
+	if err != nil {
+		return fmt.Errorf("%s: %s sink fails to connect: %v", errorx.IOErr, s.name, err)
+	}
+	s.cli = cli
+	s.renewAt = renewAt
+	return nil
+}
+
+// renew recreates the client before the credential expires, because the broker rejects the reconnection or even
+// disconnects the client with the expired credential
+func (s *iotSink) renew(ctx api.StreamContext) error {
+	if s.cli != nil && (s.renewAt.IsZero() || conf.GetNow().Before(s.renewAt)) {
+		return nil
+	}
+	if s.cli != nil {
+		ctx.GetLogger().Infof("%s sink renews the credential", s.name)
+		clients.ReleaseClient(ctx, s.cli)
+		s.cli = nil
+	}
+	return s.open()
+}
+
+func (s *iotSink) Collect(ctx api.StreamContext, item interface{}) error {
+	logger := ctx.GetLogger()
+	payload, _, err := ctx.TransformOutput(item)
+	if err != nil {
+		return err
+	}
+	tpc, err := s.topic(ctx, item)
+	if err != nil {
+		return err
+	}
+	if err := s.renew(ctx); err != nil {
+		return err
+	}
+	logger.Debugf("%s sink publishes to %s: %s", s.name, tpc, payload)
+	para := map[string]interface{}{
+		"qos":      s.qos,
+		"retained": false,
+	}
+	if err := s.cli.Publish(ctx, tpc, payload, para); err != nil {
+		return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+	}
+	return nil
+}
+
+func (s *iotSink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing %s sink", s.name)
+	if s.cli != nil {
+		clients.ReleaseClient(ctx, s.cli)
+		s.cli = nil
+	}
+	return nil
+}
+
+func parseQos(qos int) (byte, error) {
+	if qos != 0 && qos != 1 {
+		return 0, fmt.Errorf("invalid qos %d, the cloud IoT services only support qos 0 or 1", qos)
+	}
+	return byte(qos), nil
+}
+
+// parseProperties parses the property templates of the result and encodes them as the url query in the key order
+func parseProperties(ctx api.StreamContext, props map[string]string, item interface{}) (string, error) {
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	q := ""
+	for i, k := range keys {
+		v, err := ctx.ParseTemplate(props[k], item)
+		if err != nil {
+			return "", fmt.Errorf("fail to parse property %s: %v", k, err)
+		}
+		if i > 0 {
+			q += "&"
+		}
+		q += url.QueryEscape(k) + "=" + url.QueryEscape(v)
+	}
+	return q, nil
+}
@@ -47,6 +47,28 @@ type SinkConf struct {
 	DataTemplate string      `json:"dataTemplate"`
 	Fields       []string    `json:"fields"`
 	DataField    string      `json:"dataField"`
+	// BatchMode decides how to convert the batched results, merge all rows into one event or send each row as an event
+	BatchMode batchMode `json:"batchMode"`
+	// ValueTypes specifies the EdgeX value type of the readings by the field name
+	ValueTypes map[string]string `json:"valueTypes"`
+}
+
+type batchMode string
+
+const (
+	BatchModeMerge batchMode = "merge"
+	BatchModeSplit batchMode = "split"
+)
+
+var valueTypes = map[string]struct{}{
+	v3.ValueTypeBool: {}, v3.ValueTypeString: {}, v3.ValueTypeBinary: {}, v3.ValueTypeObject: {},
+	v3.ValueTypeUint8: {}, v3.ValueTypeUint16: {}, v3.ValueTypeUint32: {}, v3.ValueTypeUint64: {},
+	v3.ValueTypeInt8: {}, v3.ValueTypeInt16: {}, v3.ValueTypeInt32: {}, v3.ValueTypeInt64: {},
+	v3.ValueTypeFloat32: {}, v3.ValueTypeFloat64: {},
+	v3.ValueTypeBoolArray: {}, v3.ValueTypeStringArray: {},
+	v3.ValueTypeUint8Array: {}, v3.ValueTypeUint16Array: {}, v3.ValueTypeUint32Array: {}, v3.ValueTypeUint64Array: {},
+	v3.ValueTypeInt8Array: {}, v3.ValueTypeInt16Array: {}, v3.ValueTypeInt32Array: {}, v3.ValueTypeInt64Array: {},
+	v3.ValueTypeFloat32Array: {}, v3.ValueTypeFloat64Array: {},
 }
 
 type EdgexMsgBusSink struct {
@@ -64,6 +86,7 @@ func (ems *EdgexMsgBusSink) Configure(ps map[string]interface{}) error {
 		ContentType: "application/json",
 		DeviceName:  "ekuiper",
 		ProfileName: "ekuiperProfile",
+		BatchMode:   BatchModeMerge,
 	}
 
 	err := cast.MapToStruct(ps, c)
@@ -82,6 +105,16 @@ func (ems *EdgexMsgBusSink) Configure(ps map[string]interface{}) error {
 	if c.Topic != "" && c.TopicPrefix != "" {
 		return fmt.Errorf("not allow to specify both topic and topicPrefix, please set one only")
 	}
+
+	if c.BatchMode != BatchModeMerge && c.BatchMode != BatchModeSplit {
+		return fmt.Errorf("specified wrong batchMode value %s", c.BatchMode)
+	}
+
+	for k, vt := range c.ValueTypes {
+		if _, ok := valueTypes[vt]; !ok {
+			return fmt.Errorf("specified wrong valueType %s of field %s", vt, k)
+		}
+	}
 	ems.c = c
 	ems.config = ps
 
@@ -115,7 +148,9 @@ func (ems *EdgexMsgBusSink) Open(ctx api.StreamContext) error {
 	return nil
 }
 
-func (ems *EdgexMsgBusSink) produceEvents(ctx api.StreamContext, item interface{}) (*dtos.Event, error) {
+// produceEvents converts the result into the events. The rows of the batched result are merged into one event or
+// converted into an event per row according to the batchMode
+func (ems *EdgexMsgBusSink) produceEvents(ctx api.StreamContext, item interface{}) ([]*dtos.Event, error) {
 	if ems.c.DataTemplate != "" {
 		jsonBytes, _, err := ctx.TransformOutput(item)
 		if err != nil {
@@ -144,6 +179,22 @@ func (ems *EdgexMsgBusSink) produceEvents(ctx api.StreamContext, item interface{
 		// impossible
 		return nil, fmt.Errorf("receive invalid data %v", item)
 	}
+	if ems.c.BatchMode == BatchModeMerge {
+		return []*dtos.Event{ems.createEvent(ctx, m)}, nil
+	}
+	events := make([]*dtos.Event, 0, len(m))
+	for _, row := range m {
+		event := ems.createEvent(ctx, []map[string]interface{}{row})
+		// the row only has the metadata
+		if len(event.Readings) == 0 {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (ems *EdgexMsgBusSink) createEvent(ctx api.StreamContext, m []map[string]interface{}) *dtos.Event {
 	m1 := ems.getMeta(m)
 	event := m1.createEvent()
 	// Override the devicename if user specified the value
@@ -171,6 +222,9 @@ func (ems *EdgexMsgBusSink) produceEvents(ctx api.StreamContext, item interface{
 				if mm1 != nil && mm1.valueType != nil {
 					vt = *mm1.valueType
 					vv, err = getValueByType(v1, vt)
+				} else if t, ok := ems.c.ValueTypes[k1]; ok {
+					vt = t
+					vv, err = getValueByType(v1, vt)
 				} else {
 					vt, vv, err = getValueType(v1)
 				}
@@ -199,7 +253,7 @@ func (ems *EdgexMsgBusSink) produceEvents(ctx api.StreamContext, item interface{
 			}
 		}
 	}
-	return event, nil
+	return event
 }
 
 func getValueType(v interface{}) (string, interface{}, error) {
@@ -482,14 +536,24 @@ func (ems *EdgexMsgBusSink) getMeta(result []map[string]interface{}) *meta {
 }
 
 func (ems *EdgexMsgBusSink) Collect(ctx api.StreamContext, item interface{}) error {
-	logger := ctx.GetLogger()
-	evt, err := ems.produceEvents(ctx, item)
+	evts, err := ems.produceEvents(ctx, item)
 	if err != nil {
 		return fmt.Errorf("Failed to convert to EdgeX event: %s.", err.Error())
 	}
+	for _, evt := range evts {
+		if err := ems.publish(ctx, evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ems *EdgexMsgBusSink) publish(ctx api.StreamContext, evt *dtos.Event) error {
+	logger := ctx.GetLogger()
 	var (
 		data  []byte
 		topic string
+		err   error
 	)
 	if ems.c.MessageType == MessageTypeRequest {
 		req := requests.NewAddEventRequest(*evt)
@@ -82,6 +82,7 @@ func TestConfigure(t *testing.T) {
 				"metadata": "meta",
 			},
 			expected: &SinkConf{
+				BatchMode:   BatchModeMerge,
 				MessageType: MessageTypeEvent,
 				ContentType: "application/json",
 				DeviceName:  "ekuiper",
@@ -102,6 +103,7 @@ func TestConfigure(t *testing.T) {
 				"contentType": "application/json",
 			},
 			expected: &SinkConf{
+				BatchMode:   BatchModeMerge,
 				MessageType: MessageTypeEvent,
 				ContentType: "application/json",
 				DeviceName:  "ekuiper",
@@ -124,6 +126,7 @@ func TestConfigure(t *testing.T) {
 				},
 			},
 			expected: &SinkConf{
+				BatchMode:   BatchModeMerge,
 				MessageType: MessageTypeEvent,
 				ContentType: "application/json",
 				DeviceName:  "ekuiper",
@@ -144,6 +147,7 @@ func TestConfigure(t *testing.T) {
 				"contentType": "application/json",
 			},
 			expected: &SinkConf{
+				BatchMode:   BatchModeMerge,
 				MessageType: MessageTypeRequest,
 				ContentType: "application/json",
 				DeviceName:  "ekuiper",
@@ -175,6 +179,36 @@ func TestConfigure(t *testing.T) {
 			},
 			error: "not allow to specify both topic and topicPrefix, please set one only",
 		},
+		{ // 6
+			conf: map[string]interface{}{
+				"batchMode": "split",
+				"valueTypes": map[string]interface{}{
+					"temperature": "Float32",
+				},
+			},
+			expected: &SinkConf{
+				BatchMode:   BatchModeSplit,
+				MessageType: MessageTypeEvent,
+				ContentType: "application/json",
+				DeviceName:  "ekuiper",
+				ProfileName: "ekuiperProfile",
+				ValueTypes:  map[string]string{"temperature": "Float32"},
+			},
+		},
+		{ // 7
+			conf: map[string]interface{}{
+				"batchMode": "single",
+			},
+			error: "specified wrong batchMode value single",
+		},
+		{ // 8
+			conf: map[string]interface{}{
+				"valueTypes": map[string]interface{}{
+					"temperature": "float",
+				},
+			},
+			error: "specified wrong valueType float of field temperature",
+		},
 	}
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
 	for i, test := range tests {
@@ -497,12 +531,91 @@ func TestProduceEvents(t1 *testing.T) {
 		result, err := ems.produceEvents(ctx, payload)
 		if !reflect.DeepEqual(t.error, testx.Errstring(err)) {
 			t1.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, t.input, t.error, err)
-		} else if t.error == "" && !compareEvent(t.expected, result) {
+		} else if t.error == "" && (len(result) != 1 || !compareEvent(t.expected, result[0])) {
 			t1.Errorf("%d. %q\n\nresult mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, t.input, t.expected, result)
 		}
 	}
 }
 
+func TestProduceSplitEvents(t *testing.T) {
+	ems := EdgexMsgBusSink{}
+	err := ems.Configure(map[string]interface{}{
+		"metadata":  "meta",
+		"batchMode": "split",
+		"valueTypes": map[string]interface{}{
+			"temperature": "Float32",
+			"humidity":    "Int64",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ems.c.SourceName = "ruleTest"
+	input := `[
+				{"meta":{"deviceName":"demo"}},
+				{"temperature":20.5,"humidity":30,"meta":{"deviceName":"device1","humidity":{"valueType":"Uint8"}}},
+				{"temperature":21,"humidity":40.0}
+			]`
+	var payload []map[string]interface{}
+	json.Unmarshal([]byte(input), &payload)
+	expected := []*dtos.Event{
+		{
+			DeviceName:  "device1",
+			ProfileName: "ekuiperProfile",
+			SourceName:  "ruleTest",
+			Readings: []dtos.BaseReading{
+				{
+					ResourceName:  "temperature",
+					DeviceName:    "device1",
+					ProfileName:   "ekuiperProfile",
+					ValueType:     v3.ValueTypeFloat32,
+					SimpleReading: dtos.SimpleReading{Value: "2.050000e+01"},
+				},
+				{
+					ResourceName:  "humidity",
+					DeviceName:    "device1",
+					ProfileName:   "ekuiperProfile",
+					ValueType:     v3.ValueTypeUint8,
+					SimpleReading: dtos.SimpleReading{Value: "30"},
+				},
+			},
+		},
+		{
+			DeviceName:  "ekuiper",
+			ProfileName: "ekuiperProfile",
+			SourceName:  "ruleTest",
+			Readings: []dtos.BaseReading{
+				{
+					ResourceName:  "temperature",
+					DeviceName:    "ekuiper",
+					ProfileName:   "ekuiperProfile",
+					ValueType:     v3.ValueTypeFloat32,
+					SimpleReading: dtos.SimpleReading{Value: "2.100000e+01"},
+				},
+				{
+					ResourceName:  "humidity",
+					DeviceName:    "ekuiper",
+					ProfileName:   "ekuiperProfile",
+					ValueType:     v3.ValueTypeInt64,
+					SimpleReading: dtos.SimpleReading{Value: "40"},
+				},
+			},
+		},
+	}
+	result, err := ems.produceEvents(ctx, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("expect %d events but got %d", len(expected), len(result))
+	}
+	for i, e := range expected {
+		if !compareEvent(e, result[i]) {
+			t.Errorf("%d result mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, e, result[i])
+		}
+	}
+}
+
 func TestEdgeXTemplate_Apply(t1 *testing.T) {
 	tests := []struct {
 		input    string
@@ -607,7 +720,7 @@ func TestEdgeXTemplate_Apply(t1 *testing.T) {
 		result, err := ems.produceEvents(vCtx, payload[0])
 		if !reflect.DeepEqual(t.error, testx.Errstring(err)) {
 			t1.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, t.input, t.error, err)
-		} else if t.error == "" && !compareEvent(t.expected, result) {
+		} else if t.error == "" && (len(result) != 1 || !compareEvent(t.expected, result[0])) {
 			t1.Errorf("%d. %q\n\nresult mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, t.input, t.expected, result)
 		}
 	}
@@ -0,0 +1,127 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build email || !core
+
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+type attachment struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+type message struct {
+	from        string
+	to          []string
+	cc          []string
+	subject     string
+	date        time.Time
+	body        []byte
+	html        bool
+	attachments []attachment
+}
+
+// bytes encodes the message by RFC 5322 and MIME. The message is multipart/mixed if there are attachments
+func (m *message) bytes() ([]byte, error) {
+	var b bytes.Buffer
+	writeHeader(&b, "From", m.from)
+	if len(m.to) > 0 {
+		writeHeader(&b, "To", strings.Join(m.to, ", "))
+	}
+	if len(m.cc) > 0 {
+		writeHeader(&b, "Cc", strings.Join(m.cc, ", "))
+	}
+	writeHeader(&b, "Subject", mime.QEncoding.Encode("utf-8", m.subject))
+	writeHeader(&b, "Date", m.date.Format(time.RFC1123Z))
+	writeHeader(&b, "MIME-Version", "1.0")
+	bodyType := "text/plain; charset=utf-8"
+	if m.html {
+		bodyType = "text/html; charset=utf-8"
+	}
+	if len(m.attachments) == 0 {
+		writeHeader(&b, "Content-Type", bodyType)
+		writeHeader(&b, "Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		if err := writeQuotedPrintable(&b, m.body); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+	mw := multipart.NewWriter(&b)
+	writeHeader(&b, "Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	b.WriteString("\r\n")
+	w, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {bodyType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(w, m.body); err != nil {
+		return nil, err
+	}
+	for _, a := range m.attachments {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.contentType, map[string]string{"name": a.name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		writeBase64(w, a.data)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeHeader(b *bytes.Buffer, key, value string) {
+	// remove the line breaks to avoid the header injection
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	_, _ = fmt.Fprintf(b, "%s: %s\r\n", key, value)
+}
+
+func writeQuotedPrintable(w io.Writer, data []byte) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write(data); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// writeBase64 writes the base64 encoded data in the lines of 76 characters as required by MIME
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		_, _ = w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	_, _ = w.Write([]byte(encoded + "\r\n"))
+}
@@ -0,0 +1,175 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build email || !core
+
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+type parsedMessage struct {
+	header      mail.Header
+	contentType string
+	body        string
+	attachments map[string]string
+}
+
+// parseMessage decodes the message to verify it can be read by the mail clients
+func parseMessage(t *testing.T, raw []byte) *parsedMessage {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &parsedMessage{header: m.Header, attachments: make(map[string]string)}
+	mt, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt != "multipart/mixed" {
+		result.contentType = mt
+		b, err := io.ReadAll(quotedprintable.NewReader(m.Body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result.body = string(b)
+		return result
+	}
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the reader decodes the quoted printable part automatically
+		b, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name := p.FileName(); name != "" {
+			data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(b), "\r\n", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result.attachments[name] = string(data)
+		} else {
+			result.contentType, _, _ = mime.ParseMediaType(p.Header.Get("Content-Type"))
+			result.body = string(b)
+		}
+	}
+	return result
+}
+
+func TestMessage(t *testing.T) {
+	date := time.Date(2023, 4, 1, 8, 0, 0, 0, time.UTC)
+	longData := strings.Repeat("0123456789", 20)
+	tests := []struct {
+		m           *message
+		contentType string
+		body        string
+		attachments map[string]string
+	}{
+		{
+			m: &message{
+				from:    "eKuiper <alert@example.com>",
+				to:      []string{"a@example.com", "b@example.com"},
+				subject: "温度告警",
+				date:    date,
+				body:    []byte("temperature is " + strings.Repeat("high ", 30)),
+			},
+			contentType: "text/plain",
+			body:        "temperature is " + strings.Repeat("high ", 30),
+		}, {
+			m: &message{
+				from:    "alert@example.com",
+				to:      []string{"a@example.com"},
+				cc:      []string{"c@example.com"},
+				subject: "temperature alert",
+				date:    date,
+				body:    []byte("<b>high</b>"),
+				html:    true,
+				attachments: []attachment{
+					{name: "data.json", contentType: "application/json", data: []byte(`{"a":1}`)},
+					{name: "long.txt", contentType: "text/plain", data: []byte(longData)},
+				},
+			},
+			contentType: "text/html",
+			body:        "<b>high</b>",
+			attachments: map[string]string{"data.json": `{"a":1}`, "long.txt": longData},
+		},
+	}
+	for i, tt := range tests {
+		raw, err := tt.m.bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(string(raw), "\r\n") {
+			if len(line) > 998 {
+				t.Errorf("%d: the line exceeds 998 characters", i)
+			}
+		}
+		p := parseMessage(t, raw)
+		subject, err := new(mime.WordDecoder).DecodeHeader(p.header.Get("Subject"))
+		if err != nil || subject != tt.m.subject {
+			t.Errorf("%d: expect subject %s but got %s with error %v", i, tt.m.subject, subject, err)
+		}
+		if p.header.Get("From") != tt.m.from || p.header.Get("To") != strings.Join(tt.m.to, ", ") || p.header.Get("Cc") != strings.Join(tt.m.cc, ", ") {
+			t.Errorf("%d: invalid address headers %v", i, p.header)
+		}
+		if d, err := p.header.Date(); err != nil || !d.Equal(date) {
+			t.Errorf("%d: expect date %v but got %v with error %v", i, date, d, err)
+		}
+		if p.contentType != tt.contentType || p.body != tt.body {
+			t.Errorf("%d: expect %s body %s but got %s body %s", i, tt.contentType, tt.body, p.contentType, p.body)
+		}
+		if len(p.attachments) != len(tt.attachments) {
+			t.Errorf("%d: expect attachments %v but got %v", i, tt.attachments, p.attachments)
+		}
+		for k, v := range tt.attachments {
+			if p.attachments[k] != v {
+				t.Errorf("%d: expect attachment %s to be %s but got %s", i, k, v, p.attachments[k])
+			}
+		}
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	m := &message{
+		from:    "alert@example.com",
+		to:      []string{"a@example.com"},
+		subject: "alert\r\nBcc: evil@example.com",
+		body:    []byte("body"),
+	}
+	raw, err := m.bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parseMessage(t, raw)
+	if p.header.Get("Bcc") != "" {
+		t.Errorf("unexpected header injection %v", p.header)
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build email || !core
+
+package email
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"net"
+	"net/mail"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/cert"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+const (
+	EncryptionNone     = "none"
+	EncryptionStartTLS = "starttls"
+	EncryptionTLS      = "tls"
+	BodyTypeText       = "text"
+	BodyTypeHtml       = "html"
+)
+
+type sinkConf struct {
+	// Server is the address of the smtp server like smtp.example.com:587
+	Server   string `json:"server"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Encryption is none, starttls or tls. The tls is the implicit tls which is usually on port 465
+	Encryption         string `json:"encryption"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	RootCaPath         string `json:"rootCaPath"`
+	// Timeout is the timeout in milliseconds to send an email
+	Timeout int      `json:"timeout"`
+	From    string   `json:"from"`
+	To      []string `json:"to"`
+	Cc      []string `json:"cc"`
+	Bcc     []string `json:"bcc"`
+	// Subject is the subject of the email, it can be a data template
+	Subject string `json:"subject"`
+	// Body is the template of the email body. If not set, the transformed result such as the json is the body
+	Body     string `json:"body"`
+	BodyType string `json:"bodyType"`
+	// AttachData attaches the triggering tuples as a json file
+	AttachData     bool   `json:"attachData"`
+	AttachmentName string `json:"attachmentName"`
+	// RateLimit is the max number of emails sent in the RateInterval milliseconds, 0 means no limit
+	RateLimit    int `json:"rateLimit"`
+	RateInterval int `json:"rateInterval"`
+	// DedupKey is the data template of the key to deduplicate the tuples in the DedupWindow milliseconds
+	DedupKey    string `json:"dedupKey"`
+	DedupWindow int    `json:"dedupWindow"`
+}
+
+type sink struct {
+	c    *sinkConf
+	host string
+	// the envelope addresses
+	from  string
+	rcpts []string
+	html  *htmltemplate.Template
+	send  func(from string, rcpts []string, msg []byte) error
+
+	// the sent times in the rate interval
+	sent []time.Time
+	// the sent time of each deduplication key
+	keys      map[string]time.Time
+	lastPurge time.Time
+}
+
+func (s *sink) Configure(props map[string]interface{}) error {
+	c := &sinkConf{
+		Encryption:     EncryptionStartTLS,
+		Timeout:        10000,
+		BodyType:       BodyTypeText,
+		AttachmentName: "data.json",
+		RateInterval:   60000,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Server == "" {
+		return fmt.Errorf("server is required")
+	}
+	host, _, err := net.SplitHostPort(c.Server)
+	if err != nil {
+		return fmt.Errorf("invalid server %s: %v", c.Server, err)
+	}
+	switch c.Encryption {
+	case EncryptionNone, EncryptionStartTLS, EncryptionTLS:
+	default:
+		return fmt.Errorf("invalid encryption %s, must be none, starttls or tls", c.Encryption)
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	from, err := mail.ParseAddress(c.From)
+	if err != nil {
+		return fmt.Errorf("invalid from %s: %v", c.From, err)
+	}
+	var rcpts []string
+	for _, addrs := range [][]string{c.To, c.Cc, c.Bcc} {
+		for _, a := range addrs {
+			addr, err := mail.ParseAddress(a)
+			if err != nil {
+				return fmt.Errorf("invalid recipient %s: %v", a, err)
+			}
+			rcpts = append(rcpts, addr.Address)
+		}
+	}
+	if len(rcpts) == 0 {
+		return fmt.Errorf("at least one recipient is required in to, cc or bcc")
+	}
+	switch c.BodyType {
+	case BodyTypeText:
+	case BodyTypeHtml:
+		// the html template escapes the values to avoid the injection
+		if c.Body != "" {
+			s.html, err = htmltemplate.New("email").Funcs(htmltemplate.FuncMap(conf.FuncMap)).Parse(c.Body)
+			if err != nil {
+				return fmt.Errorf("invalid body template: %v", err)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid bodyType %s, must be text or html", c.BodyType)
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("rateLimit must not be negative")
+	}
+	if c.RateLimit > 0 && c.RateInterval <= 0 {
+		return fmt.Errorf("rateInterval must be positive")
+	}
+	if c.DedupKey != "" && c.DedupWindow <= 0 {
+		return fmt.Errorf("dedupWindow must be positive if dedupKey is set")
+	}
+	s.c = c
+	s.host = host
+	s.from = from.Address
+	s.rcpts = rcpts
+	s.keys = make(map[string]time.Time)
+	return nil
+}
+
+// EncryptBySelf The encryption property is the transport encryption of the smtp connection
+func (s *sink) EncryptBySelf() bool {
+	return true
+}
+
+func (s *sink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("opening email sink to %s", s.c.Server)
+	if s.send == nil {
+		tlsConfig, err := cert.GenerateTLSForClient(cert.TlsConfigurationOptions{
+			SkipCertVerify: s.c.InsecureSkipVerify,
+			CaFile:         s.c.RootCaPath,
+		})
+		if err != nil {
+			return err
+		}
+		tlsConfig.ServerName = s.host
+		cli := &smtpClient{
+			server:     s.c.Server,
+			host:       s.host,
+			username:   s.c.Username,
+			password:   s.c.Password,
+			encryption: s.c.Encryption,
+			tlsConfig:  tlsConfig,
+			timeout:    time.Duration(s.c.Timeout) * time.Millisecond,
+		}
+		s.send = cli.send
+	}
+	return nil
+}
+
+func (s *sink) Collect(ctx api.StreamContext, item interface{}) error {
+	logger := ctx.GetLogger()
+	now := conf.GetNow()
+	item, keys, err := s.dedup(ctx, item, now)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		logger.Debugf("email sink drops the duplicate tuples")
+		return nil
+	}
+	if !s.allow(now) {
+		logger.Warnf("email sink drops the email for exceeding the rate limit %d in %d ms", s.c.RateLimit, s.c.RateInterval)
+		return nil
+	}
+	msg, err := s.message(ctx, item, now)
+	if err != nil {
+		return err
+	}
+	if err := s.send(s.from, s.rcpts, msg); err != nil {
+		return fmt.Errorf("%s: email sink fails to send: %v", errorx.IOErr, err)
+	}
+	// only count the sent emails so that the dropped alerts can be sent later
+	if s.c.RateLimit > 0 {
+		s.sent = append(s.sent, now)
+	}
+	for _, k := range keys {
+		s.keys[k] = now
+	}
+	return nil
+}
+
+// dedup removes the tuples whose key has been sent in the dedup window. It returns nil if all the tuples are removed
+func (s *sink) dedup(ctx api.StreamContext, item interface{}, now time.Time) (interface{}, []string, error) {
+	if s.c.DedupKey == "" {
+		return item, nil, nil
+	}
+	window := time.Duration(s.c.DedupWindow) * time.Millisecond
+	if now.Sub(s.lastPurge) >= window {
+		for k, t := range s.keys {
+			if now.Sub(t) >= window {
+				delete(s.keys, k)
+			}
+		}
+		s.lastPurge = now
+	}
+	var (
+		keys   []string
+		unique = make(map[string]struct{})
+	)
+	isDup := func(tuple interface{}) (bool, error) {
+		k, err := ctx.ParseTemplate(s.c.DedupKey, tuple)
+		if err != nil {
+			return false, fmt.Errorf("fail to parse dedupKey: %v", err)
+		}
+		if t, ok := s.keys[k]; ok && now.Sub(t) < window {
+			return true, nil
+		}
+		// the same key in one batch is sent together
+		if _, ok := unique[k]; !ok {
+			unique[k] = struct{}{}
+			keys = append(keys, k)
+		}
+		return false, nil
+	}
+	switch v := item.(type) {
+	case []map[string]interface{}:
+		result := make([]map[string]interface{}, 0, len(v))
+		for _, tuple := range v {
+			dup, err := isDup(tuple)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !dup {
+				result = append(result, tuple)
+			}
+		}
+		if len(result) == 0 {
+			return nil, nil, nil
+		}
+		return result, keys, nil
+	default:
+		dup, err := isDup(v)
+		if err != nil || dup {
+			return nil, nil, err
+		}
+		return v, keys, nil
+	}
+}
+
+// allow checks the rate limit by the sent times in the sliding rate interval
+func (s *sink) allow(now time.Time) bool {
+	if s.c.RateLimit == 0 {
+		return true
+	}
+	interval := time.Duration(s.c.RateInterval) * time.Millisecond
+	i := 0
+	for i < len(s.sent) && now.Sub(s.sent[i]) >= interval {
+		i++
+	}
+	s.sent = s.sent[i:]
+	return len(s.sent) < s.c.RateLimit
+}
+
+func (s *sink) message(ctx api.StreamContext, item interface{}, now time.Time) ([]byte, error) {
+	subject := s.c.Subject
+	if subject == "" {
+		subject = fmt.Sprintf("eKuiper alert of rule %s", ctx.GetRuleId())
+	} else {
+		var err error
+		subject, err = ctx.ParseTemplate(subject, item)
+		if err != nil {
+			return nil, fmt.Errorf("fail to parse subject: %v", err)
+		}
+	}
+	var body []byte
+	switch {
+	case s.html != nil:
+		var b bytes.Buffer
+		if err := s.html.Execute(&b, item); err != nil {
+			return nil, fmt.Errorf("fail to execute body template: %v", err)
+		}
+		body = b.Bytes()
+	case s.c.Body != "":
+		b, err := ctx.ParseTemplate(s.c.Body, item)
+		if err != nil {
+			return nil, fmt.Errorf("fail to parse body: %v", err)
+		}
+		body = []byte(b)
+	default:
+		var err error
+		body, _, err = ctx.TransformOutput(item)
+		if err != nil {
+			return nil, err
+		}
+	}
+	m := &message{
+		from:    s.c.From,
+		to:      s.c.To,
+		cc:      s.c.Cc,
+		subject: subject,
+		date:    now,
+		body:    body,
+		html:    s.c.BodyType == BodyTypeHtml,
+	}
+	if s.c.AttachData {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("fail to encode the attachment: %v", err)
+		}
+		m.attachments = []attachment{{name: s.c.AttachmentName, contentType: "application/json", data: data}}
+	}
+	return m.bytes()
+}
+
+func (s *sink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing email sink")
+	return nil
+}
+
+func GetSink() api.Sink {
+	return &sink{}
+}
@@ -0,0 +1,327 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build email || !core
+
+package email
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/topotest/mockclock"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{},
+			err:   "server is required",
+		}, {
+			props: map[string]interface{}{"server": "smtp.example.com"},
+			err:   "invalid server smtp.example.com: address smtp.example.com: missing port in address",
+		}, {
+			props: map[string]interface{}{"server": "smtp.example.com:25", "encryption": "ssl"},
+			err:   "invalid encryption ssl, must be none, starttls or tls",
+		}, {
+			props: map[string]interface{}{"server": "smtp.example.com:25", "from": "alert"},
+			err:   "invalid from alert: mail: missing '@' or angle-addr",
+		}, {
+			props: map[string]interface{}{"server": "smtp.example.com:25", "from": "alert@example.com"},
+			err:   "at least one recipient is required in to, cc or bcc",
+		}, {
+			props: map[string]interface{}{"server": "smtp.example.com:25", "from": "alert@example.com", "to": []string{"a"}},
+			err:   "invalid recipient a: mail: missing '@' or angle-addr",
+		}, {
+			props: map[string]interface{}{"server": "smtp.example.com:25", "from": "alert@example.com", "bcc": []string{"a@example.com"}, "bodyType": "markdown"},
+			err:   "invalid bodyType markdown, must be text or html",
+		}, {
+			props: map[string]interface{}{"server": "smtp.example.com:25", "from": "alert@example.com", "to": []string{"a@example.com"}, "bodyType": "html", "body": "{{.a"},
+			err:   "invalid body template: template: email:1: unclosed action",
+		}, {
+			props: map[string]interface{}{"server": "smtp.example.com:25", "from": "alert@example.com", "to": []string{"a@example.com"}, "rateLimit": -1},
+			err:   "rateLimit must not be negative",
+		}, {
+			props: map[string]interface{}{"server": "smtp.example.com:25", "from": "alert@example.com", "to": []string{"a@example.com"}, "dedupKey": "{{.id}}"},
+			err:   "dedupWindow must be positive if dedupKey is set",
+		}, {
+			props: map[string]interface{}{"server": "smtp.example.com:465", "encryption": "tls", "from": "eKuiper <alert@example.com>", "to": []string{"Admin <a@example.com>"}, "rateLimit": 1},
+		},
+	}
+	for i, tt := range tests {
+		err := GetSink().Configure(tt.props)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%d: unexpected error %v", i, err)
+			}
+		} else if err == nil || err.Error() != tt.err {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+}
+
+type sentMail struct {
+	from  string
+	rcpts []string
+	msg   *parsedMessage
+}
+
+func newSink(t *testing.T, props map[string]interface{}) (*sink, api.StreamContext, *[]sentMail) {
+	mockclock.ResetClock(0)
+	s := &sink{}
+	props["server"] = "smtp.example.com:25"
+	props["from"] = "alert@example.com"
+	if err := s.Configure(props); err != nil {
+		t.Fatal(err)
+	}
+	var mails []sentMail
+	s.send = func(from string, rcpts []string, msg []byte) error {
+		mails = append(mails, sentMail{from: from, rcpts: rcpts, msg: parseMessage(t, msg)})
+		return nil
+	}
+	ctx := mockContext.NewMockContext("rule1", "op1")
+	tf, _ := transform.GenTransform("", "json", "", "", "", []string{})
+	vCtx := context.WithValue(ctx.(*context.DefaultContext), context.TransKey, tf)
+	if err := s.Open(vCtx); err != nil {
+		t.Fatal(err)
+	}
+	return s, vCtx, &mails
+}
+
+func TestCollect(t *testing.T) {
+	s, ctx, mails := newSink(t, map[string]interface{}{
+		"to":         []string{"Admin <a@example.com>"},
+		"cc":         []string{"b@example.com"},
+		"bcc":        []string{"c@example.com"},
+		"subject":    "{{.level}} temperature of {{.id}}",
+		"attachData": true,
+	})
+	if err := s.Collect(ctx, map[string]interface{}{"id": "d1", "level": "high"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*mails) != 1 {
+		t.Fatalf("expect 1 mail but got %d", len(*mails))
+	}
+	m := (*mails)[0]
+	if m.from != "alert@example.com" || !reflect.DeepEqual(m.rcpts, []string{"a@example.com", "b@example.com", "c@example.com"}) {
+		t.Errorf("invalid envelope %s %v", m.from, m.rcpts)
+	}
+	if m.msg.header.Get("Subject") != "high temperature of d1" || m.msg.header.Get("Bcc") != "" {
+		t.Errorf("invalid header %v", m.msg.header)
+	}
+	exp := `{"id":"d1","level":"high"}`
+	if m.msg.contentType != "text/plain" || m.msg.body != exp || m.msg.attachments["data.json"] != exp {
+		t.Errorf("expect body and attachment %s but got %v", exp, m.msg)
+	}
+}
+
+func TestHtmlBody(t *testing.T) {
+	s, ctx, mails := newSink(t, map[string]interface{}{
+		"to":       []string{"a@example.com"},
+		"bodyType": "html",
+		"body":     "<p>{{range .}}<b>{{.name}}</b>{{end}}</p>",
+	})
+	if err := s.Collect(ctx, []map[string]interface{}{{"name": "<script>"}, {"name": "a&b"}}); err != nil {
+		t.Fatal(err)
+	}
+	m := (*mails)[0]
+	exp := "<p><b>&lt;script&gt;</b><b>a&amp;b</b></p>"
+	if m.msg.contentType != "text/html" || m.msg.body != exp {
+		t.Errorf("expect html body %s but got %s %s", exp, m.msg.contentType, m.msg.body)
+	}
+	if m.msg.header.Get("Subject") != "eKuiper alert of rule rule1" {
+		t.Errorf("invalid default subject %s", m.msg.header.Get("Subject"))
+	}
+}
+
+func TestDedup(t *testing.T) {
+	transform.RegisterAdditionalFuncs()
+	s, ctx, mails := newSink(t, map[string]interface{}{
+		"to":          []string{"a@example.com"},
+		"body":        "{{json .}}",
+		"dedupKey":    "{{.id}}",
+		"dedupWindow": 1000,
+	})
+	c := mockclock.GetMockClock()
+	steps := []struct {
+		item interface{}
+		body string
+	}{
+		{item: map[string]interface{}{"id": "d1"}, body: `{"id":"d1"}`},
+		{item: map[string]interface{}{"id": "d1"}},
+		{item: map[string]interface{}{"id": "d2"}, body: `{"id":"d2"}`},
+		// the duplicate tuples in the batch are removed
+		{item: []map[string]interface{}{{"id": "d1"}, {"id": "d3"}, {"id": "d3", "v": 1}}, body: `[{"id":"d3"},{"id":"d3","v":1}]`},
+		{item: []map[string]interface{}{{"id": "d2"}, {"id": "d3"}}},
+	}
+	for i, st := range steps {
+		c.Add(100 * time.Millisecond)
+		n := len(*mails)
+		if err := s.Collect(ctx, st.item); err != nil {
+			t.Fatal(err)
+		}
+		if st.body == "" {
+			if len(*mails) != n {
+				t.Errorf("%d: expect the duplicate dropped", i)
+			}
+			continue
+		}
+		if len(*mails) != n+1 || (*mails)[n].msg.body != st.body {
+			t.Errorf("%d: expect mail %s", i, st.body)
+		}
+	}
+	// d1 is sent again after the window
+	c.Add(600 * time.Millisecond)
+	if err := s.Collect(ctx, map[string]interface{}{"id": "d1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*mails) != 4 {
+		t.Errorf("expect d1 sent after the dedup window but got %d mails", len(*mails))
+	}
+	if _, ok := s.keys["d2"]; !ok {
+		t.Errorf("d2 should not be purged in the window")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s, ctx, mails := newSink(t, map[string]interface{}{
+		"to":           []string{"a@example.com"},
+		"rateLimit":    2,
+		"rateInterval": 1000,
+	})
+	c := mockclock.GetMockClock()
+	fail := true
+	send := s.send
+	s.send = func(from string, rcpts []string, msg []byte) error {
+		if fail {
+			fail = false
+			return errors.New("connection refused")
+		}
+		return send(from, rcpts, msg)
+	}
+	// the failed mail is not counted
+	err := s.Collect(ctx, map[string]interface{}{"a": 0})
+	if err == nil || err.Error() != "io error: email sink fails to send: connection refused" {
+		t.Errorf("unexpected error %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		c.Add(300 * time.Millisecond)
+		if err := s.Collect(ctx, map[string]interface{}{"a": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(*mails) != 2 {
+		t.Errorf("expect 2 mails in the rate interval but got %d", len(*mails))
+	}
+	// the first mail at 300ms expires
+	c.Add(500 * time.Millisecond)
+	if err := s.Collect(ctx, map[string]interface{}{"a": 4}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*mails) != 3 || (*mails)[2].msg.body != `{"a":4}` {
+		t.Errorf("expect the mail sent after the rate interval")
+	}
+}
+
+// serveSmtp runs a minimal smtp server which accepts one mail without authentication
+func serveSmtp(t *testing.T, received chan<- string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var transcript strings.Builder
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				transcript.WriteString(strings.TrimSpace(line) + "\n")
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 Go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					transcript.WriteString(l)
+				}
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				received <- transcript.String()
+				return
+			default:
+				reply("502 Unsupported")
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestSmtpClient(t *testing.T) {
+	received := make(chan string, 1)
+	addr := serveSmtp(t, received)
+	cli := &smtpClient{
+		server:     addr,
+		host:       "127.0.0.1",
+		encryption: EncryptionNone,
+		timeout:    time.Second,
+	}
+	if err := cli.send("alert@example.com", []string{"a@example.com", "b@example.com"}, []byte("Subject: test\r\n\r\nhello\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	exp := "MAIL FROM:<alert@example.com>\nRCPT TO:<a@example.com>\nRCPT TO:<b@example.com>\nSubject: test\r\n\r\nhello\r\n"
+	if r := <-received; r != exp {
+		t.Errorf("expect %q but got %q", exp, r)
+	}
+	// starttls is required by default
+	addr = serveSmtp(t, received)
+	cli.server = addr
+	cli.encryption = EncryptionStartTLS
+	err := cli.send("alert@example.com", []string{"a@example.com"}, []byte("hello"))
+	if err == nil || err.Error() != "server "+addr+" does not support starttls" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
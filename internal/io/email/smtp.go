@@ -0,0 +1,92 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build email || !core
+
+package email
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// smtpClient sends each email in a new connection, because the alerts are sent occasionally and the smtp servers
+// close the idle connections
+type smtpClient struct {
+	server     string
+	host       string
+	username   string
+	password   string
+	encryption string
+	tlsConfig  *tls.Config
+	timeout    time.Duration
+}
+
+func (c *smtpClient) send(from string, rcpts []string, msg []byte) error {
+	dialer := &net.Dialer{Timeout: c.timeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if c.encryption == EncryptionTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.server, c.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", c.server)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(c.timeout))
+	cli, err := smtp.NewClient(conn, c.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer cli.Close()
+	if c.encryption == EncryptionStartTLS {
+		if ok, _ := cli.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("server %s does not support starttls", c.server)
+		}
+		if err := cli.StartTLS(c.tlsConfig); err != nil {
+			return err
+		}
+	}
+	if c.username != "" {
+		if err := cli.Auth(smtp.PlainAuth("", c.username, c.password, c.host)); err != nil {
+			return err
+		}
+	}
+	if err := cli.Mail(from); err != nil {
+		return err
+	}
+	for _, r := range rcpts {
+		if err := cli.Rcpt(r); err != nil {
+			return err
+		}
+	}
+	w, err := cli.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return cli.Quit()
+}
@@ -20,8 +20,14 @@ const (
 	JSON_TYPE  FileType = "json"
 	CSV_TYPE   FileType = "csv"
 	LINES_TYPE FileType = "lines"
+	// PARQUET_TYPE and AVRO_TYPE are registered by the feature files
+	PARQUET_TYPE FileType = "parquet"
+	AVRO_TYPE    FileType = "avro"
 )
 
+// defaultWatchInterval is the polling interval in milliseconds of the watch mode
+const defaultWatchInterval = 1000
+
 const (
 	GZIP = "gzip"
 	ZSTD = "zstd"
@@ -33,6 +39,10 @@ var fileTypes = map[FileType]struct{}{
 	LINES_TYPE: {},
 }
 
+// recordReaders read the self-describing container files such as parquet and avro. They read the file by path instead
+// of the stream to seek the columns, so the decompression and ignore lines are not applicable
+var recordReaders = map[FileType]recordReader{}
+
 var compressionTypes = map[string]struct{}{
 	GZIP: {},
 	ZSTD: {},
@@ -0,0 +1,67 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build avro || !core
+
+package file
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hamba/avro/v2/ocf"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func init() {
+	fileTypes[AVRO_TYPE] = struct{}{}
+	recordReaders[AVRO_TYPE] = readAvro
+}
+
+// readAvro reads the records of the avro object container file. The codec and the schema are read from the file header.
+// Avro is row based, so the records are decoded fully and the unprojected columns are dropped
+func readAvro(ctx api.StreamContext, path string, columns []string, emit func(map[string]interface{}) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec, err := ocf.NewDecoder(f)
+	if err != nil {
+		return fmt.Errorf("read avro file %s error: %v", path, err)
+	}
+	for dec.HasNext() {
+		var record map[string]interface{}
+		if err := dec.Decode(&record); err != nil {
+			return fmt.Errorf("decode avro file %s error: %v", path, err)
+		}
+		m := record
+		if len(columns) > 0 {
+			m = make(map[string]interface{}, len(columns))
+			for _, col := range columns {
+				if v, ok := record[col]; ok {
+					m[col] = v
+				}
+			}
+		}
+		if !emit(m) {
+			return nil
+		}
+	}
+	if err := dec.Error(); err != nil {
+		return fmt.Errorf("read avro file %s error: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build parquet || !core
+
+package file
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/v12/arrow/memory"
+	"github.com/apache/arrow/go/v12/parquet/file"
+	"github.com/apache/arrow/go/v12/parquet/pqarrow"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// parquetBatchSize is the number of rows decoded in each batch
+const parquetBatchSize = 1024
+
+func init() {
+	fileTypes[PARQUET_TYPE] = struct{}{}
+	recordReaders[PARQUET_TYPE] = readParquet
+}
+
+// readParquet reads the row groups of the parquet file batch by batch. Only the column chunks of the projected
+// columns are read and decoded
+func readParquet(ctx api.StreamContext, path string, columns []string, emit func(map[string]interface{}) bool) error {
+	pf, err := file.OpenParquetFile(path, false)
+	if err != nil {
+		return fmt.Errorf("open parquet file %s error: %v", path, err)
+	}
+	defer pf.Close()
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{BatchSize: parquetBatchSize}, memory.DefaultAllocator)
+	if err != nil {
+		return fmt.Errorf("read parquet file %s error: %v", path, err)
+	}
+	var indices []int
+	if len(columns) > 0 {
+		schema, err := fr.Schema()
+		if err != nil {
+			return fmt.Errorf("read parquet schema of %s error: %v", path, err)
+		}
+		for _, col := range columns {
+			fields := schema.FieldIndices(col)
+			if len(fields) == 0 {
+				return fmt.Errorf("column %s not found in parquet file %s", col, path)
+			}
+			// a nested column has multiple leaf columns
+			leaves, err := fr.Manifest.GetFieldIndices(fields[:1])
+			if err != nil {
+				return fmt.Errorf("read parquet column %s error: %v", col, err)
+			}
+			indices = append(indices, leaves...)
+		}
+	}
+	rr, err := fr.GetRecordReader(ctx, indices, nil)
+	if err != nil {
+		return fmt.Errorf("read parquet file %s error: %v", path, err)
+	}
+	defer rr.Release()
+	for rr.Next() {
+		rec := rr.Record()
+		fields := rec.Schema().Fields()
+		for row := 0; row < int(rec.NumRows()); row++ {
+			m := make(map[string]interface{}, len(fields))
+			for i, f := range fields {
+				col := rec.Column(i)
+				if col.IsNull(row) {
+					m[f.Name] = nil
+					continue
+				}
+				m[f.Name] = col.GetOneForMarshal(row)
+			}
+			if !emit(m) {
+				return nil
+			}
+		}
+	}
+	if err := rr.Err(); err != nil {
+		return fmt.Errorf("read parquet file %s error: %v", path, err)
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ package file
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -41,6 +42,7 @@ type sinkConf struct {
 	Format             string   `json:"format"` // only use for validation; transformation is done in sink_node
 	Compression        string   `json:"compression"`
 	Fields             []string `json:"fields"` // only use for extracting header for csv; transformation is done in sink_node
+	ExactlyOnce        bool     `json:"exactlyOnce"`
 }
 
 type fileSink struct {
@@ -48,8 +50,15 @@ type fileSink struct {
 
 	mux sync.Mutex
 	fws map[string]*fileWriter
+	// txnFiles are the files written in the current transaction when exactlyOnce is enabled
+	txnFiles []string
 }
 
+const (
+	inProgressSuffix = ".inprogress"
+	pendingSuffix    = ".pending"
+)
+
 func (m *fileSink) Configure(props map[string]interface{}) error {
 	c := &sinkConf{
 		RollingCount: 1000000,
@@ -98,12 +107,21 @@ func (m *fileSink) Configure(props map[string]interface{}) error {
 	if _, ok := compressionTypes[c.Compression]; !ok && c.Compression != "" {
 		return fmt.Errorf("compression must be one of gzip, zstd")
 	}
+	// Each transaction writes to new files, so the file names must be different
+	if c.ExactlyOnce && c.RollingNamePattern != "prefix" && c.RollingNamePattern != "suffix" {
+		return fmt.Errorf("rollingNamePattern must be prefix or suffix when exactlyOnce is enabled")
+	}
 
 	m.c = c
 	m.fws = make(map[string]*fileWriter)
 	return nil
 }
 
+// CompressBySelf The compression property is to compress the whole file instead of each line
+func (m *fileSink) CompressBySelf() bool {
+	return true
+}
+
 func (m *fileSink) Open(ctx api.StreamContext) error {
 	ctx.GetLogger().Debug("Opening file sink")
 	// Check if the files have opened longer than the rolling interval, if so close it and create a new one
@@ -192,9 +210,110 @@ func (m *fileSink) Close(ctx api.StreamContext) error {
 			errs = append(errs, e)
 		}
 	}
+	// The data of the open transaction will be replayed from the checkpoint
+	for _, fn := range m.txnFiles {
+		if e := removeIfExist(fn + inProgressSuffix); e != nil {
+			errs = append(errs, e)
+		}
+	}
+	m.txnFiles = nil
 	return errors.Join(errs...)
 }
 
+// PreCommit closes the files of the current transaction and renames them to the pending files of the checkpoint.
+// The file names are saved in the state so that the transaction can be committed after restoring.
+func (m *fileSink) PreCommit(ctx api.StreamContext, checkpointId int64) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for k, v := range m.fws {
+		if err := v.Close(ctx); err != nil {
+			return fmt.Errorf("failed to close file %s: %v", k, err)
+		}
+		delete(m.fws, k)
+	}
+	for _, fn := range m.txnFiles {
+		if err := os.Rename(fn+inProgressSuffix, pendingFileName(fn, checkpointId)); err != nil {
+			return err
+		}
+	}
+	err := ctx.PutState(txnStateKey(checkpointId), m.txnFiles)
+	if err == nil {
+		m.txnFiles = nil
+	}
+	return err
+}
+
+// Commit renames the pending files of the checkpoint to the final names. The files already renamed are skipped.
+func (m *fileSink) Commit(ctx api.StreamContext, checkpointId int64) error {
+	files, err := txnFiles(ctx, checkpointId)
+	if err != nil || files == nil {
+		return err
+	}
+	for _, fn := range files {
+		err := os.Rename(pendingFileName(fn, checkpointId), fn)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	ctx.GetLogger().Debugf("file sink commits %d files of checkpoint %d", len(files), checkpointId)
+	return ctx.DeleteState(txnStateKey(checkpointId))
+}
+
+// Abort removes the files of the transaction. If the pre-commit fails, the transaction is not saved in the state yet,
+// so the files of the current transaction are removed.
+func (m *fileSink) Abort(ctx api.StreamContext, checkpointId int64) error {
+	files, err := txnFiles(ctx, checkpointId)
+	if err != nil {
+		return err
+	}
+	if files == nil {
+		m.mux.Lock()
+		files = m.txnFiles
+		m.txnFiles = nil
+		m.mux.Unlock()
+	}
+	var errs []error
+	for _, fn := range files {
+		if e := removeIfExist(pendingFileName(fn, checkpointId)); e != nil {
+			errs = append(errs, e)
+		}
+		if e := removeIfExist(fn + inProgressSuffix); e != nil {
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return ctx.DeleteState(txnStateKey(checkpointId))
+}
+
+func txnStateKey(checkpointId int64) string {
+	return fmt.Sprintf("$$fileTxn_%d", checkpointId)
+}
+
+func txnFiles(ctx api.StreamContext, checkpointId int64) ([]string, error) {
+	v, err := ctx.GetState(txnStateKey(checkpointId))
+	if err != nil || v == nil {
+		return nil, err
+	}
+	files, ok := v.([]string)
+	if !ok {
+		return nil, fmt.Errorf("invalid file transaction state %v", v)
+	}
+	return files, nil
+}
+
+func pendingFileName(fn string, checkpointId int64) string {
+	return fmt.Sprintf("%s.%d%s", fn, checkpointId, pendingSuffix)
+}
+
+func removeIfExist(fn string) error {
+	if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // GetFws returns the file writer for the given file name, if the file writer does not exist, it will create one
 // The item is used to get the csv header if needed
 func (m *fileSink) GetFws(ctx api.StreamContext, fn string, item interface{}) (*fileWriter, error) {
@@ -242,6 +361,11 @@ func (m *fileSink) GetFws(ctx api.StreamContext, fn string, item interface{}) (*
 				nfn = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(fn, ext), conf.GetNowInMilli(), ext)
 			}
 		}
+		// The file is written as in progress until the transaction is committed
+		if m.c.ExactlyOnce {
+			m.txnFiles = append(m.txnFiles, nfn)
+			nfn += inProgressSuffix
+		}
 		fws, e = createFileWriter(ctx, nfn, m.c.FileType, headers, m.c.Compression)
 		if e != nil {
 			return nil, e
@@ -25,6 +25,7 @@ import (
 
 	"github.com/lf-edge/ekuiper/internal/compressor"
 	"github.com/lf-edge/ekuiper/internal/conf"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
 	"github.com/lf-edge/ekuiper/internal/topo/topotest/mockclock"
 	"github.com/lf-edge/ekuiper/internal/topo/transform"
@@ -816,3 +817,92 @@ func TestFileSinkReopen(t *testing.T) {
 		t.Errorf("\nexpected\t %q \nbut got\t\t %q", string(exp), string(contents))
 	}
 }
+
+func TestFileSinkExactlyOnce(t *testing.T) {
+	conf.IsTesting = true
+	dir := t.TempDir()
+	tf, _ := transform.GenTransform("", "json", "", "", "", []string{})
+	vCtx := context.WithValue(mockContext.NewMockContext("testExactlyOnce", "op1").(*context.DefaultContext), context.TransKey, tf)
+
+	sink := &fileSink{}
+	err := sink.Configure(map[string]interface{}{
+		"path":        filepath.Join(dir, "out.log"),
+		"fileType":    LINES_TYPE,
+		"format":      "json",
+		"exactlyOnce": true,
+	})
+	if err == nil {
+		t.Fatal("expect error for exactlyOnce without rolling name pattern")
+	}
+	err = sink.Configure(map[string]interface{}{
+		"path":               filepath.Join(dir, "out.log"),
+		"fileType":           LINES_TYPE,
+		"format":             "json",
+		"rollingNamePattern": "suffix",
+		"exactlyOnce":        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockclock.ResetClock(10)
+	if err := sink.Open(vCtx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := sink.Collect(vCtx, map[string]interface{}{"key": "value" + strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fn := filepath.Join(dir, "out-10.log")
+	if err := sink.PreCommit(vCtx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fn); !os.IsNotExist(err) {
+		t.Errorf("file should not be visible before commit")
+	}
+	if _, err := os.Stat(fn + ".1.pending"); err != nil {
+		t.Errorf("pending file is not found: %v", err)
+	}
+	// The data after the barrier belong to the next transaction
+	mockclock.GetMockClock().Add(10 * time.Millisecond)
+	if err := sink.Collect(vCtx, map[string]interface{}{"key": "value2"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := sink.Commit(vCtx, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	contents, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []byte("{\"key\":\"value0\"}\n{\"key\":\"value1\"}")
+	if !reflect.DeepEqual(contents, exp) {
+		t.Errorf("\nexpected\t %q \nbut got\t\t %q", string(exp), string(contents))
+	}
+	if err := sink.PreCommit(vCtx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Abort(vCtx, 2); err != nil {
+		t.Fatal(err)
+	}
+	// The open transaction is discarded when closing
+	if err := sink.Collect(vCtx, map[string]interface{}{"key": "value3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(vCtx); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "out-10.log" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("expect only the committed file but got %v", names)
+	}
+}
@@ -29,6 +29,7 @@ import (
 
 	"github.com/lf-edge/ekuiper/internal/compressor"
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/ndjson"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
@@ -48,8 +49,18 @@ type FileSourceConfig struct {
 	IgnoreEndLines   int      `json:"ignoreEndLines"`
 	Delimiter        string   `json:"delimiter"`
 	Decompression    string   `json:"decompression"`
+	// Watch polls the directory every interval and reads the new files only
+	Watch bool `json:"watch"`
+	// MaxLineSize is the max size in bytes of a line of the lines file, the longer line is handled by OversizedLine
+	MaxLineSize int `json:"maxLineSize"`
+	// OversizedLine is the policy of the longer line, error to send an error or skip to drop it
+	OversizedLine string `json:"oversizedLine"`
 }
 
+// recordReader reads the rows of the file with the projected columns, all columns are read if the columns are empty.
+// The emit returns false if the reading should stop
+type recordReader func(ctx api.StreamContext, file string, columns []string, emit func(map[string]interface{}) bool) error
+
 // FileSource The BATCH to load data from file at once
 type FileSource struct {
 	file   string
@@ -131,16 +142,39 @@ func (fs *FileSource) Configure(fileName string, props map[string]interface{}) e
 	if cfg.Delimiter == "" {
 		cfg.Delimiter = ","
 	}
+	if cfg.Watch {
+		if fileName != "/$$TEST_CONNECTION$$" && !fs.isDir {
+			return fmt.Errorf("watch mode requires the data source %s to be a directory", fs.file)
+		}
+		if cfg.Interval <= 0 {
+			cfg.Interval = defaultWatchInterval
+		}
+	}
+
+	if cfg.FileType == LINES_TYPE {
+		if _, err := ndjson.NewSplitter(cfg.MaxLineSize, cfg.OversizedLine); err != nil {
+			return err
+		}
+	}
 
 	if _, ok := compressionTypes[cfg.Decompression]; !ok && cfg.Decompression != "" {
 		return fmt.Errorf("decompression must be one of gzip, zstd")
 	}
+	if _, ok := recordReaders[cfg.FileType]; ok {
+		if cfg.Decompression != "" || cfg.IgnoreStartLines > 0 || cfg.IgnoreEndLines > 0 {
+			return fmt.Errorf("decompression and ignore lines are not supported for fileType %s", cfg.FileType)
+		}
+	}
 
 	fs.config = cfg
 	return nil
 }
 
 func (fs *FileSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	if fs.config.Watch {
+		fs.watch(ctx, consumer, errCh)
+		return
+	}
 	err := fs.Load(ctx, consumer)
 	if err != nil {
 		select {
@@ -208,15 +242,116 @@ func (fs *FileSource) Load(ctx api.StreamContext, consumer chan<- api.SourceTupl
 	return nil
 }
 
-func (fs *FileSource) parseFile(ctx api.StreamContext, file string, consumer chan<- api.SourceTuple) (result error) {
-	r, err := fs.prepareFile(ctx, file)
+// fileState identifies a version of the file in the watch mode
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s fileState) same(o fileState) bool {
+	return s.size == o.size && s.modTime.Equal(o.modTime)
+}
+
+// watch polls the directory and reads the new or updated files in the file name order. To avoid reading the files
+// being written, a file is read only after its size and modification time are unchanged in two polls
+func (fs *FileSource) watch(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	logger.Infof("Watch dir %s every %d ms", fs.file, fs.config.Interval)
+	ticker := time.NewTicker(time.Millisecond * time.Duration(fs.config.Interval))
+	defer ticker.Stop()
+	pending := make(map[string]fileState)
+	done := make(map[string]fileState)
+	for {
+		if err := fs.scan(ctx, consumer, pending, done); err != nil {
+			errCh <- err
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scan reads the stable files which are not read yet. The pending and done are the states of the files waiting to be
+// stable and the files already read
+func (fs *FileSource) scan(ctx api.StreamContext, consumer chan<- api.SourceTuple, pending, done map[string]fileState) error {
+	rcvTime := conf.GetNow()
+	entries, err := os.ReadDir(fs.file)
 	if err != nil {
-		ctx.GetLogger().Debugf("prepare file %s error: %v", file, err)
 		return err
 	}
+	exists := make(map[string]struct{}, len(entries))
+	read := false
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		// removed after listed
+		if err != nil {
+			continue
+		}
+		name := entry.Name()
+		exists[name] = struct{}{}
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		if d, ok := done[name]; ok && d.same(state) {
+			continue
+		}
+		if p, ok := pending[name]; !ok || !p.same(state) {
+			pending[name] = state
+			continue
+		}
+		delete(pending, name)
+		done[name] = state
+		read = true
+		file := filepath.Join(fs.file, name)
+		if err := fs.parseFile(ctx, file, consumer); err != nil {
+			ctx.GetLogger().Errorf("parse file %s fail with error: %v", file, err)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	// forget the removed or moved files so that they are read again if created again
+	for name := range pending {
+		if _, ok := exists[name]; !ok {
+			delete(pending, name)
+		}
+	}
+	for name := range done {
+		if _, ok := exists[name]; !ok {
+			delete(done, name)
+		}
+	}
+	if read && fs.config.IsTable {
+		select {
+		case consumer <- api.NewDefaultSourceTupleWithTime(nil, nil, rcvTime):
+		case <-ctx.Done():
+		}
+	}
+	return nil
+}
+
+func (fs *FileSource) parseFile(ctx api.StreamContext, file string, consumer chan<- api.SourceTuple) (result error) {
 	meta := map[string]interface{}{
 		"file": file,
 	}
+	if read, ok := recordReaders[fs.config.FileType]; ok {
+		defer func() {
+			ctx.GetLogger().Debugf("Finish loading from file %s", file)
+			if result == nil {
+				result = fs.afterRead(ctx, file)
+			}
+		}()
+		return fs.publishRecords(ctx, read, file, consumer, meta)
+	}
+	r, err := fs.prepareFile(ctx, file)
+	if err != nil {
+		ctx.GetLogger().Debugf("prepare file %s error: %v", file, err)
+		return err
+	}
 	defer func() {
 		ctx.GetLogger().Debugf("Finish loading from file %s", file)
 		if closer, ok := r.(io.Closer); ok {
@@ -224,24 +359,43 @@ func (fs *FileSource) parseFile(ctx api.StreamContext, file string, consumer cha
 			closer.Close()
 		}
 		if result == nil {
-			switch fs.config.ActionAfterRead {
-			case 1:
-				if err := os.Remove(file); err != nil {
-					result = err
-				}
-				ctx.GetLogger().Debugf("Remove file %s", file)
-			case 2:
-				targetFile := filepath.Join(fs.config.MoveTo, filepath.Base(file))
-				if err := os.Rename(file, targetFile); err != nil {
-					result = err
-				}
-				ctx.GetLogger().Debugf("Move file %s to %s", file, targetFile)
-			}
+			result = fs.afterRead(ctx, file)
 		}
 	}()
 	return fs.publish(ctx, r, consumer, meta)
 }
 
+// afterRead removes or moves the file after it is read successfully
+func (fs *FileSource) afterRead(ctx api.StreamContext, file string) error {
+	switch fs.config.ActionAfterRead {
+	case 1:
+		ctx.GetLogger().Debugf("Remove file %s", file)
+		return os.Remove(file)
+	case 2:
+		targetFile := filepath.Join(fs.config.MoveTo, filepath.Base(file))
+		ctx.GetLogger().Debugf("Move file %s to %s", file, targetFile)
+		return os.Rename(file, targetFile)
+	}
+	return nil
+}
+
+// publishRecords sends the rows read by the record reader. The columns config is the projection of the columns to read
+func (fs *FileSource) publishRecords(ctx api.StreamContext, read recordReader, file string, consumer chan<- api.SourceTuple, meta map[string]interface{}) error {
+	ctx.GetLogger().Debug("Start to load")
+	rcvTime := conf.GetNow()
+	return read(ctx, file, fs.config.Columns, func(m map[string]interface{}) bool {
+		select {
+		case consumer <- api.NewDefaultSourceTupleWithTime(m, meta, rcvTime):
+		case <-ctx.Done():
+			return false
+		}
+		if fs.config.SendInterval > 0 {
+			time.Sleep(time.Millisecond * time.Duration(fs.config.SendInterval))
+		}
+		return true
+	})
+}
+
 func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer chan<- api.SourceTuple, meta map[string]interface{}) error {
 	ctx.GetLogger().Debug("Start to load")
 	rcvTime := conf.GetNow()
@@ -316,14 +470,22 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 			}
 		}
 	case LINES_TYPE:
-		scanner := bufio.NewScanner(file)
-		scanner.Split(bufio.ScanLines)
-		for scanner.Scan() {
+		// the lines are read in chunks so that the long lines and the encodings such as UTF-16 are handled
+		splitter, err := ndjson.NewSplitter(fs.config.MaxLineSize, fs.config.OversizedLine)
+		if err != nil {
+			return err
+		}
+		return ndjson.Read(file, splitter, func(r ndjson.Record) bool {
 			var tuples []api.SourceTuple
-			m, err := ctx.DecodeIntoList(scanner.Bytes())
-			if err != nil {
+			if r.Err != nil {
 				tuples = []api.SourceTuple{&xsql.ErrorSourceTuple{
-					Error: fmt.Errorf("Invalid data format, cannot decode %s with error %s", scanner.Text(), err),
+					Error:   fmt.Errorf("invalid line in file %s: %v", fs.file, r.Err),
+					Payload: r.Data,
+				}}
+			} else if m, err := ctx.DecodeIntoList(r.Data); err != nil {
+				tuples = []api.SourceTuple{&xsql.ErrorSourceTuple{
+					Error:   fmt.Errorf("Invalid data format, cannot decode %s with error %s", string(r.Data), err),
+					Payload: r.Data,
 				}}
 			} else {
 				for _, t := range m {
@@ -334,13 +496,14 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 				select {
 				case consumer <- tuple:
 				case <-ctx.Done():
-					return nil
+					return false
 				}
 			}
 			if fs.config.SendInterval > 0 {
 				time.Sleep(time.Millisecond * time.Duration(fs.config.SendInterval))
 			}
-		}
+			return true
+		})
 	default:
 		return fmt.Errorf("invalid file type %s", fs.config.FileType)
 	}
@@ -233,3 +233,176 @@ func TestJsonLines(t *testing.T) {
 	}
 	mock.TestSourceOpen(r, exp, t)
 }
+
+func TestJsonLinesOversized(t *testing.T) {
+	dir := t.TempDir()
+	// the file has UTF-8 BOM, CRLF line breaks and a line exceeding the max size
+	content := "\xEF\xBB\xBF{\"id\":1}\r\n{\"id\":2,\"name\":\"a very long name exceeds the limit\"}\r\n{\"id\":3}"
+	if err := os.WriteFile(filepath.Join(dir, "a.lines"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	meta := map[string]interface{}{
+		"file": filepath.Join(dir, "a.lines"),
+	}
+	mc := conf.Clock.(*clock.Mock)
+	exp := []api.SourceTuple{
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(1)}, meta, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(3)}, meta, mc.Now()),
+	}
+	p := map[string]interface{}{
+		"path":          dir,
+		"fileType":      "lines",
+		"maxLineSize":   32,
+		"oversizedLine": "skip",
+	}
+	r := &FileSource{}
+	err := r.Configure("a.lines", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.TestSourceOpen(r, exp, t)
+	p["oversizedLine"] = "truncate"
+	err = (&FileSource{}).Configure("a.lines", p)
+	if err == nil || err.Error() != "invalid oversize policy truncate, must be error or skip" {
+		t.Errorf("expect policy error but got %v", err)
+	}
+}
+
+func TestAvroFile(t *testing.T) {
+	path, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := map[string]interface{}{
+		"file": filepath.Join(path, "test", "test.avro"),
+	}
+	mc := conf.Clock.(*clock.Mock)
+	exp := []api.SourceTuple{
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": int64(1), "height": 1.82}, meta, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": int64(2), "height": 1.65}, meta, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": int64(3), "height": 1.76}, meta, mc.Now()),
+	}
+	p := map[string]interface{}{
+		"path":     filepath.Join(path, "test"),
+		"fileType": "avro",
+		"columns":  []string{"id", "height"},
+	}
+	r := &FileSource{}
+	err = r.Configure("test.avro", p)
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	mock.TestSourceOpen(r, exp, t)
+}
+
+func TestRecordFileConfig(t *testing.T) {
+	path, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{
+				"fileType":      "parquet",
+				"decompression": "gzip",
+			},
+			err: "decompression and ignore lines are not supported for fileType parquet",
+		}, {
+			props: map[string]interface{}{
+				"fileType":         "avro",
+				"ignoreStartLines": 1,
+			},
+			err: "decompression and ignore lines are not supported for fileType avro",
+		},
+	}
+	for i, tt := range tests {
+		tt.props["path"] = filepath.Join(path, "test")
+		err := (&FileSource{}).Configure("test.avro", tt.props)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestWatchFolder(t *testing.T) {
+	dir := t.TempDir()
+	staging := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "a.json"), []byte(`[{"id":1},{"id":2}]`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := conf.Clock.(*clock.Mock)
+	exp := []api.SourceTuple{
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(1)}, map[string]interface{}{"file": filepath.Join(dir, "a.json")}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(2)}, map[string]interface{}{"file": filepath.Join(dir, "a.json")}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(3)}, map[string]interface{}{"file": filepath.Join(dir, "b.json")}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(4)}, map[string]interface{}{"file": filepath.Join(dir, "c.json")}, mc.Now()),
+	}
+	p := map[string]interface{}{
+		"path":            dir,
+		"watch":           true,
+		"interval":        50,
+		"actionAfterRead": 1,
+	}
+	r := &FileSource{}
+	err = r.Configure("", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// drop the new files after the existing file is read
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		for _, f := range []struct {
+			name    string
+			content string
+		}{{"b.json", `[{"id":3}]`}, {"c.json", `[{"id":4}]`}} {
+			if err := os.WriteFile(filepath.Join(staging, f.name), []byte(f.content), 0o644); err != nil {
+				t.Error(err)
+			}
+		}
+		// move the complete files in as a drop folder
+		_ = os.Rename(filepath.Join(staging, "b.json"), filepath.Join(dir, "b.json"))
+		_ = os.Rename(filepath.Join(staging, "c.json"), filepath.Join(dir, "c.json"))
+	}()
+	mock.TestSourceOpen(r, exp, t)
+	// wait for file deleted takes effect
+	time.Sleep(100 * time.Millisecond)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expect 0 files in watched folder, but got %d", len(files))
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	path, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &FileSource{}
+	err = r.Configure("test.json", map[string]interface{}{
+		"path":  filepath.Join(path, "test"),
+		"watch": true,
+	})
+	expErr := fmt.Sprintf("watch mode requires the data source %s to be a directory", filepath.Join(path, "test", "test.json"))
+	if err == nil || err.Error() != expErr {
+		t.Errorf("expect error %s but got %v", expErr, err)
+	}
+	r = &FileSource{}
+	err = r.Configure("json", map[string]interface{}{
+		"path":  filepath.Join(path, "test"),
+		"watch": true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.config.Interval != defaultWatchInterval {
+		t.Errorf("expect default interval %d but got %d", defaultWatchInterval, r.config.Interval)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/lf-edge/ekuiper/internal/topo/connection/pool"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// connConf is the properties of the shared grpc connection
+type connConf struct {
+	Address string `json:"address"`
+	// Timeout is the dial timeout in milliseconds
+	Timeout int `json:"timeout"`
+}
+
+// pooledConn is the grpc connection shared in the connection pool
+type pooledConn struct {
+	conn *grpc.ClientConn
+}
+
+func init() {
+	pool.RegisterProvider("grpc", newPooledConn)
+}
+
+func newPooledConn(props map[string]interface{}) (pool.Conn, error) {
+	c := &connConf{Timeout: 5000}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return nil, fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Address == "" {
+		return nil, fmt.Errorf("address is required")
+	}
+	if c.Timeout <= 0 {
+		return nil, fmt.Errorf("timeout must be positive")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.Timeout)*time.Millisecond)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, c.Address, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s error: %v", c.Address, err)
+	}
+	return &pooledConn{conn: conn}, nil
+}
+
+func (c *pooledConn) Ping() error {
+	switch s := c.conn.GetState(); s {
+	case connectivity.TransientFailure, connectivity.Shutdown:
+		return fmt.Errorf("grpc connection to %s is %s", c.conn.Target(), s)
+	default:
+		return nil
+	}
+}
+
+// Reconnect makes the idle or failed connection connect again immediately
+func (c *pooledConn) Reconnect() error {
+	c.conn.Connect()
+	return nil
+}
+
+func (c *pooledConn) Close() error {
+	return c.conn.Close()
+}
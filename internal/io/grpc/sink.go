@@ -0,0 +1,310 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/lf-edge/ekuiper/internal/converter/protobuf"
+	"github.com/lf-edge/ekuiper/internal/pkg/def"
+	"github.com/lf-edge/ekuiper/internal/schema"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/pool"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+type sinkConf struct {
+	// Address is the host:port of the gRPC server
+	Address string `json:"address"`
+	// ConnectionId is the id of the shared grpc connection in the connection pool. The address is not used if it is set
+	ConnectionId string `json:"connectionId"`
+	// SchemaId is the name of the protobuf schema in the schema registry which defines the service
+	SchemaId string `json:"schemaId"`
+	// Method is the full name of the method in the format of package.Service/Method
+	Method string `json:"method"`
+	// Timeout is the deadline of each invocation in milliseconds
+	Timeout int `json:"timeout"`
+	// RetryCount is the max retry times when the invocation fails with a transient error
+	RetryCount int `json:"retryCount"`
+	// RetryInterval is the interval between the retries in milliseconds
+	RetryInterval int      `json:"retryInterval"`
+	DataTemplate  string   `json:"dataTemplate"`
+	DataField     string   `json:"dataField"`
+	Fields        []string `json:"fields"`
+}
+
+type sink struct {
+	c      *sinkConf
+	method *desc.MethodDescriptor
+	fc     *protobuf.FieldConverter
+	conn   *grpc.ClientConn
+	stub   grpcdynamic.Stub
+	// pooled is true if the connection is attached from the connection pool
+	pooled bool
+}
+
+var protoParser = &protoparse.Parser{}
+
+func (s *sink) Configure(props map[string]interface{}) error {
+	c := &sinkConf{
+		Timeout:       5000,
+		RetryInterval: 1000,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Address == "" && c.ConnectionId == "" {
+		return fmt.Errorf("address is required")
+	}
+	if c.SchemaId == "" {
+		return fmt.Errorf("schemaId is required")
+	}
+	if c.Method == "" {
+		return fmt.Errorf("method is required")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	if c.RetryCount < 0 {
+		return fmt.Errorf("retryCount can not be negative")
+	}
+	if c.RetryInterval < 0 {
+		return fmt.Errorf("retryInterval can not be negative")
+	}
+	ffs, err := schema.GetSchemaFile(def.PROTOBUF, c.SchemaId)
+	if err != nil {
+		return err
+	}
+	if ffs.SchemaFile == "" {
+		return fmt.Errorf("schema %s does not have the proto file", c.SchemaId)
+	}
+	md, err := findMethod(ffs.SchemaFile, c.Method)
+	if err != nil {
+		return err
+	}
+	s.c = c
+	s.method = md
+	s.fc = protobuf.GetFieldConverter()
+	return nil
+}
+
+// findMethod finds the method descriptor by the full method name like helloworld.Greeter/SayHello
+func findMethod(schemaFile string, method string) (*desc.MethodDescriptor, error) {
+	fullName := strings.TrimPrefix(method, "/")
+	i := strings.LastIndex(fullName, "/")
+	if i <= 0 || i == len(fullName)-1 {
+		return nil, fmt.Errorf("invalid method %s, the format must be package.Service/Method", method)
+	}
+	serviceName, methodName := fullName[:i], fullName[i+1:]
+	fds, err := protoParser.ParseFiles(schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("parse schema file %s failed: %s", schemaFile, err)
+	}
+	sd := fds[0].FindService(serviceName)
+	if sd == nil {
+		return nil, fmt.Errorf("service %s not found in schema file %s", serviceName, schemaFile)
+	}
+	md := sd.FindMethodByName(methodName)
+	if md == nil {
+		return nil, fmt.Errorf("method %s not found in service %s", methodName, serviceName)
+	}
+	if md.IsServerStreaming() {
+		return nil, fmt.Errorf("server streaming method %s is not supported", method)
+	}
+	return md, nil
+}
+
+func (s *sink) Open(ctx api.StreamContext) error {
+	logger := ctx.GetLogger()
+	if s.c.ConnectionId != "" {
+		logger.Infof("grpc sink using connection %s", s.c.ConnectionId)
+		c, err := pool.Attach(s.c.ConnectionId, "grpc")
+		if err != nil {
+			return fmt.Errorf("%s: %v", errorx.IOErr, err)
+		}
+		s.pooled = true
+		s.stub = grpcdynamic.NewStubWithMessageFactory(c.(*pooledConn).conn, dynamic.NewMessageFactoryWithDefaults())
+		return nil
+	}
+	logger.Infof("grpc sink connecting to %s", s.c.Address)
+	dialCtx, cancel := context.WithTimeout(ctx, time.Duration(s.c.Timeout)*time.Millisecond)
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, s.c.Address, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("%s: connect to %s error: %v", errorx.IOErr, s.c.Address, err)
+	}
+	s.conn = conn
+	s.stub = grpcdynamic.NewStubWithMessageFactory(conn, dynamic.NewMessageFactoryWithDefaults())
+	return nil
+}
+
+func (s *sink) Collect(ctx api.StreamContext, data interface{}) error {
+	logger := ctx.GetLogger()
+	messages, err := s.encode(ctx, data)
+	if err != nil {
+		return err
+	}
+	if s.method.IsClientStreaming() {
+		// all the rows of a result are sent in one stream
+		return s.invokeWithRetry(ctx, func(callCtx context.Context) error {
+			cs, err := s.stub.InvokeRpcClientStream(callCtx, s.method)
+			if err != nil {
+				return err
+			}
+			for _, m := range messages {
+				if err := cs.SendMsg(m); err != nil {
+					return err
+				}
+			}
+			_, err = cs.CloseAndReceive()
+			return err
+		})
+	}
+	for _, m := range messages {
+		err := s.invokeWithRetry(ctx, func(callCtx context.Context) error {
+			_, err := s.stub.InvokeRpc(callCtx, s.method, m)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	logger.Debugf("grpc sink invokes %s with %d messages", s.c.Method, len(messages))
+	return nil
+}
+
+// encode converts the sink data into the input messages of the method
+func (s *sink) encode(ctx api.StreamContext, data interface{}) ([]*dynamic.Message, error) {
+	if s.c.DataTemplate != "" {
+		v, _, err := ctx.TransformOutput(data)
+		if err != nil {
+			return nil, err
+		}
+		var d interface{}
+		if err := json.Unmarshal(v, &d); err != nil {
+			return nil, fmt.Errorf("fail to decode data %s after applying dataTemplate for error %v", string(v), err)
+		}
+		switch dt := d.(type) {
+		case map[string]interface{}:
+			data = dt
+		case []interface{}:
+			rows := make([]map[string]interface{}, 0, len(dt))
+			for _, el := range dt {
+				m, ok := el.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("unrecognized format of %s", string(v))
+				}
+				rows = append(rows, m)
+			}
+			data = rows
+		default:
+			return nil, fmt.Errorf("unrecognized format of %s", string(v))
+		}
+	} else {
+		m, _, err := transform.TransItem(data, s.c.DataField, s.c.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("fail to select fields %v for data %v", s.c.Fields, data)
+		}
+		data = m
+	}
+	var rows []map[string]interface{}
+	switch d := data.(type) {
+	case map[string]interface{}:
+		rows = []map[string]interface{}{d}
+	case []map[string]interface{}:
+		rows = d
+	default:
+		return nil, fmt.Errorf("unrecognized format of %s", data)
+	}
+	result := make([]*dynamic.Message, 0, len(rows))
+	for _, row := range rows {
+		m, err := s.fc.EncodeMap(s.method.GetInputType(), row)
+		if err != nil {
+			return nil, fmt.Errorf("fail to encode %v to %s: %v", row, s.method.GetInputType().GetFullyQualifiedName(), err)
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// invokeWithRetry runs the invocation with the deadline and retries it if the error is transient
+func (s *sink) invokeWithRetry(ctx api.StreamContext, invoke func(callCtx context.Context) error) error {
+	var err error
+	for i := 0; ; i++ {
+		callCtx, cancel := context.WithTimeout(ctx, time.Duration(s.c.Timeout)*time.Millisecond)
+		err = invoke(callCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if i >= s.c.RetryCount || !isRetryable(err) {
+			break
+		}
+		ctx.GetLogger().Warnf("grpc sink fails to invoke %s, retry after %d ms: %v", s.c.Method, s.c.RetryInterval, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(s.c.RetryInterval) * time.Millisecond):
+		}
+	}
+	if isRetryable(err) {
+		return fmt.Errorf("%s: grpc sink fails to invoke %s: %v", errorx.IOErr, s.c.Method, err)
+	}
+	return fmt.Errorf("grpc sink fails to invoke %s: %v", s.c.Method, err)
+}
+
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *sink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing grpc sink")
+	if s.pooled {
+		// the shared connection is kept open by the pool
+		pool.Detach(s.c.ConnectionId)
+		s.pooled = false
+		return nil
+	}
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+func GetSink() api.Sink {
+	return &sink{}
+}
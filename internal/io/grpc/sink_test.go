@@ -0,0 +1,173 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	econf "github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/converter/protobuf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+)
+
+const testSchema = "test/greeter.proto"
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"schemaId": "greeter", "method": "helloworld.Greeter/SayHello"},
+			err:   "address is required",
+		}, {
+			props: map[string]interface{}{"address": "localhost:50051", "method": "helloworld.Greeter/SayHello"},
+			err:   "schemaId is required",
+		}, {
+			props: map[string]interface{}{"connectionId": "conn1", "method": "helloworld.Greeter/SayHello"},
+			err:   "schemaId is required",
+		}, {
+			props: map[string]interface{}{"address": "localhost:50051", "schemaId": "greeter"},
+			err:   "method is required",
+		}, {
+			props: map[string]interface{}{"address": "localhost:50051", "schemaId": "greeter", "method": "helloworld.Greeter/SayHello", "timeout": 0},
+			err:   "timeout must be positive",
+		}, {
+			props: map[string]interface{}{"address": "localhost:50051", "schemaId": "greeter", "method": "helloworld.Greeter/SayHello", "retryCount": -1},
+			err:   "retryCount can not be negative",
+		}, {
+			props: map[string]interface{}{"address": "localhost:50051", "schemaId": "greeter", "method": "helloworld.Greeter/SayHello", "retryInterval": -1},
+			err:   "retryInterval can not be negative",
+		},
+	}
+	for i, tt := range tests {
+		err := GetSink().Configure(tt.props)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestFindMethod(t *testing.T) {
+	tests := []struct {
+		method string
+		stream bool
+		err    string
+	}{
+		{method: "helloworld.Greeter/SayHello"},
+		{method: "/helloworld.Greeter/SayHello"},
+		{method: "helloworld.Greeter/SayHellos", stream: true},
+		{method: "SayHello", err: "invalid method SayHello, the format must be package.Service/Method"},
+		{method: "helloworld.Greeter/", err: "invalid method helloworld.Greeter/, the format must be package.Service/Method"},
+		{method: "Greeter/SayHello", err: "service Greeter not found in schema file test/greeter.proto"},
+		{method: "helloworld.Greeter/SayBye", err: "method SayBye not found in service helloworld.Greeter"},
+		{method: "helloworld.Greeter/ListHellos", err: "server streaming method helloworld.Greeter/ListHellos is not supported"},
+	}
+	for i, tt := range tests {
+		md, err := findMethod(testSchema, tt.method)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if md.IsClientStreaming() != tt.stream {
+			t.Errorf("%d: expect client streaming %v but got %v", i, tt.stream, md.IsClientStreaming())
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	md, err := findMethod(testSchema, "helloworld.Greeter/SayHello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contextLogger := econf.Log.WithField("rule", "test")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	tests := []struct {
+		c    *sinkConf
+		data interface{}
+		exp  []map[string]interface{}
+		err  string
+	}{
+		{
+			c:    &sinkConf{},
+			data: map[string]interface{}{"name": "world", "count": 2, "other": true},
+			exp:  []map[string]interface{}{{"name": "world", "count": int64(2)}},
+		}, {
+			c:    &sinkConf{Fields: []string{"name"}},
+			data: []map[string]interface{}{{"name": "a", "count": 1}, {"name": "b", "count": 2}},
+			exp:  []map[string]interface{}{{"name": "a", "count": int64(0)}, {"name": "b", "count": int64(0)}},
+		}, {
+			c:    &sinkConf{DataField: "greeting"},
+			data: map[string]interface{}{"greeting": map[string]interface{}{"name": "c"}},
+			exp:  []map[string]interface{}{{"name": "c", "count": int64(0)}},
+		}, {
+			c:    &sinkConf{},
+			data: map[string]interface{}{"name": 1},
+			err:  "fail to encode map[name:1] to helloworld.HelloRequest: invalid type for string type field 'name': cannot convert int(1) to string",
+		},
+	}
+	for i, tt := range tests {
+		s := &sink{c: tt.c, method: md, fc: protobuf.GetFieldConverter()}
+		messages, err := s.encode(ctx, tt.data)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		result := make([]map[string]interface{}, 0, len(messages))
+		for _, m := range messages {
+			result = append(result, map[string]interface{}{
+				"name":  m.GetFieldByName("name"),
+				"count": m.GetFieldByName("count"),
+			})
+		}
+		if !reflect.DeepEqual(tt.exp, result) {
+			t.Errorf("%d: expect %v but got %v", i, tt.exp, result)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err error
+		exp bool
+	}{
+		{err: status.Error(codes.Unavailable, "unavailable"), exp: true},
+		{err: status.Error(codes.DeadlineExceeded, "timeout"), exp: true},
+		{err: status.Error(codes.InvalidArgument, "invalid"), exp: false},
+		{err: fmt.Errorf("unknown"), exp: false},
+	}
+	for i, tt := range tests {
+		if r := isRetryable(tt.err); r != tt.exp {
+			t.Errorf("%d: expect %v but got %v", i, tt.exp, r)
+		}
+	}
+}
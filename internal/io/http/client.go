@@ -38,6 +38,7 @@ type ClientConf struct {
 	config      *RawConf
 	accessConf  *AccessTokenConf
 	refreshConf *RefreshTokenConf
+	oauth2      *tokenSource
 
 	tokens map[string]interface{}
 	client *http.Client
@@ -58,6 +59,7 @@ type RawConf struct {
 	// Could be code or body
 	ResponseType string                            `json:"responseType"`
 	OAuth        map[string]map[string]interface{} `json:"oauth"`
+	OAuth2       *OAuth2Conf                       `json:"oauth2"`
 	// source specific properties
 	Interval    int  `json:"interval"`
 	Incremental bool `json:"incremental"`
@@ -176,6 +178,10 @@ func (cc *ClientConf) InitConf(device string, props map[string]interface{}) erro
 	if err != nil {
 		return err
 	}
+	// check before the oauth setting is normalized, in which an empty one may be ignored
+	if c.OAuth != nil && c.OAuth2 != nil {
+		return fmt.Errorf("oauth and oauth2 cannot be set together")
+	}
 	// validate oAuth. In order to adapt to manager, the validation is closed to allow empty value
 	if c.OAuth != nil {
 		// validate access token
@@ -217,6 +223,13 @@ func (cc *ClientConf) InitConf(device string, props map[string]interface{}) erro
 		Transport: tr,
 		Timeout:   time.Duration(c.Timeout) * time.Millisecond,
 	}
+	// the token of oauth2 is fetched in the first request and renewed automatically
+	if c.OAuth2 != nil {
+		cc.oauth2, err = newTokenSource(c.OAuth2, cc.client)
+		if err != nil {
+			return err
+		}
+	}
 	cc.config = c
 
 	// try to get access token
@@ -314,12 +327,33 @@ func (cc *ClientConf) parseHeaders(ctx api.StreamContext, data interface{}) (map
 			return nil, fmt.Errorf("parsed header template is not json: %s", tstr)
 		}
 	}
+	// the Authorization header set explicitly takes precedence
+	if cc.oauth2 != nil && !hasHeader(headers, "Authorization") {
+		token, err := cc.oauth2.Token()
+		if err != nil {
+			return nil, fmt.Errorf("fail to get oauth2 token: %v", err)
+		}
+		headers["Authorization"] = token
+	}
 	return headers, nil
 }
 
+func hasHeader(headers map[string]string, key string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
 // parse the response status. For rest sink, it will not return the body by default if not need to debug
 func (cc *ClientConf) parseResponse(ctx api.StreamContext, resp *http.Response, returnBody bool, omd5 *string) ([]map[string]interface{}, []byte, error) {
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// the token may be revoked before it expires
+		if resp.StatusCode == http.StatusUnauthorized && cc.oauth2 != nil {
+			cc.oauth2.invalidate()
+		}
 		c, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return nil, []byte("fail to read body"),
@@ -0,0 +1,184 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/httpx"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// LookupConf is the configuration of the http lookup table
+type LookupConf struct {
+	// DataField is the path of the records in the response body, the whole body is the records if not set
+	DataField string `json:"dataField"`
+	// BatchSize is the max count of the lookup values in one request. If it is greater than 1, the url, body and
+	// headers are rendered with the list of the values of each key, and the records are matched to the values by the key fields
+	BatchSize int `json:"batchSize"`
+}
+
+// LookupSource resolves the lookup keys by the rest api. The url, body and headers are templates of the lookup keys
+type LookupSource struct {
+	ClientConf
+	lookupConf *LookupConf
+}
+
+func (l *LookupSource) Configure(datasource string, props map[string]interface{}) error {
+	conf.Log.Infof("Initialized http lookup source with configurations %#v.", props)
+	if err := l.InitConf(datasource, props); err != nil {
+		return err
+	}
+	lc := &LookupConf{
+		BatchSize: 1,
+	}
+	if err := cast.MapToStruct(props, lc); err != nil {
+		return fmt.Errorf("fail to parse the properties: %v", err)
+	}
+	if lc.BatchSize <= 0 {
+		return fmt.Errorf("batchSize must be greater than 0")
+	}
+	l.lookupConf = lc
+	return nil
+}
+
+func (l *LookupSource) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Opening http lookup source with conf %+v", l.config)
+	return nil
+}
+
+func (l *LookupSource) Lookup(ctx api.StreamContext, fields []string, keys []string, values []interface{}) ([]api.SourceTuple, error) {
+	// the templates of the batch mode always accept the value list
+	if l.lookupConf.BatchSize > 1 {
+		r, err := l.LookupBatch(ctx, fields, keys, [][]interface{}{values})
+		if err != nil {
+			return nil, err
+		}
+		return r[0], nil
+	}
+	rcvTime := conf.GetNow()
+	data := make(map[string]interface{}, len(keys))
+	for i, k := range keys {
+		data[k] = values[i]
+	}
+	records, err := l.request(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]api.SourceTuple, 0, len(records))
+	for _, r := range records {
+		result = append(result, api.NewDefaultSourceTupleWithTime(r, nil, rcvTime))
+	}
+	return result, nil
+}
+
+// LookupBatch sends the values in the requests of batchSize. Without batching, the values are looked up one by one
+func (l *LookupSource) LookupBatch(ctx api.StreamContext, fields []string, keys []string, values [][]interface{}) ([][]api.SourceTuple, error) {
+	result := make([][]api.SourceTuple, len(values))
+	if l.lookupConf.BatchSize <= 1 {
+		for i, v := range values {
+			r, err := l.Lookup(ctx, fields, keys, v)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = r
+		}
+		return result, nil
+	}
+	rcvTime := conf.GetNow()
+	for start := 0; start < len(values); start += l.lookupConf.BatchSize {
+		end := start + l.lookupConf.BatchSize
+		if end > len(values) {
+			end = len(values)
+		}
+		batch := values[start:end]
+		data := make(map[string]interface{}, len(keys))
+		for i, k := range keys {
+			vs := make([]interface{}, len(batch))
+			for j, v := range batch {
+				vs[j] = v[i]
+			}
+			data[k] = vs
+		}
+		records, err := l.request(ctx, data)
+		if err != nil {
+			return nil, err
+		}
+		// match the records to the values by the string form of the key fields, so that 1 in the rule matches 1.0 in json
+		indexes := make(map[string][]int, len(batch))
+		for j, v := range batch {
+			k := fmt.Sprintf("%v", v)
+			indexes[k] = append(indexes[k], start+j)
+		}
+		for _, r := range records {
+			rv := make([]interface{}, len(keys))
+			for i, k := range keys {
+				rv[i] = r[k]
+			}
+			for _, j := range indexes[fmt.Sprintf("%v", rv)] {
+				result[j] = append(result[j], api.NewDefaultSourceTupleWithTime(r, nil, rcvTime))
+			}
+		}
+	}
+	return result, nil
+}
+
+// request sends the request rendered by the data and returns the records. Not found response means no records
+func (l *LookupSource) request(ctx api.StreamContext, data map[string]interface{}) ([]map[string]interface{}, error) {
+	logger := ctx.GetLogger()
+	// the tokens of the legacy oauth are available in the templates
+	if len(l.tokens) > 0 {
+		for k, v := range l.tokens {
+			if _, ok := data[k]; !ok {
+				data[k] = v
+			}
+		}
+	}
+	u, err := ctx.ParseTemplate(l.config.Url, data)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse the url template %s: %v", l.config.Url, err)
+	}
+	body, err := ctx.ParseTemplate(l.config.Body, data)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse the body template %s: %v", l.config.Body, err)
+	}
+	headers, err := l.parseHeaders(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf("http lookup source sending request url: %s, headers: %v, body %s", u, headers, body)
+	resp, err := httpx.Send(logger, l.client, l.config.BodyType, l.config.Method, u, headers, true, []byte(body))
+	if err != nil {
+		return nil, fmt.Errorf("found error %s when trying to reach %s", err, u)
+	}
+	logger.Debugf("http lookup source got response %v", resp)
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return nil, nil
+	}
+	payloads, _, err := l.parseResponse(ctx, resp, true, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parse response error %v", err)
+	}
+	return extractRecords(payloads, l.lookupConf.DataField)
+}
+
+func (l *LookupSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing http lookup source")
+	return nil
+}
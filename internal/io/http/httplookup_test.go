@@ -0,0 +1,167 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+var lookupDevices = map[string]map[string]interface{}{
+	"1": {"id": 1, "name": "sensor1"},
+	"2": {"id": 2, "name": "sensor2"},
+}
+
+// mockLookupServer serves /devices/{id} for a single device and /devices?ids=1,2 for a batch of devices
+func mockLookupServer(requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if r.URL.Path == "/devices" {
+			var data []map[string]interface{}
+			for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+				if d, ok := lookupDevices[id]; ok {
+					data = append(data, d)
+				}
+			}
+			jsonOut(w, map[string]interface{}{"data": data})
+			return
+		}
+		d, ok := lookupDevices[strings.TrimPrefix(r.URL.Path, "/devices/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		jsonOut(w, d)
+	}))
+}
+
+func lookupMessages(tuples []api.SourceTuple) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(tuples))
+	for _, t := range tuples {
+		result = append(result, t.Message())
+	}
+	return result
+}
+
+func TestLookup(t *testing.T) {
+	var requests int32
+	server := mockLookupServer(&requests)
+	defer server.Close()
+	ctx := mockContext.NewMockContext("ruleLookup", "op1")
+	l := &LookupSource{}
+	err := l.Configure("", map[string]interface{}{
+		"url": server.URL + "/devices/{{.id}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value  interface{}
+		result []map[string]interface{}
+	}{
+		{
+			value:  1,
+			result: []map[string]interface{}{{"id": float64(1), "name": "sensor1"}},
+		}, {
+			value:  "3",
+			result: []map[string]interface{}{},
+		},
+	}
+	for i, tt := range tests {
+		r, err := l.Lookup(ctx, nil, []string{"id"}, []interface{}{tt.value})
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.result, lookupMessages(r)) {
+			t.Errorf("%d: expect %v but got %v", i, tt.result, lookupMessages(r))
+		}
+	}
+	// look up one by one without batching
+	r, err := l.LookupBatch(ctx, nil, []string{"id"}, [][]interface{}{{1}, {2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 2 || len(r[0]) != 1 || len(r[1]) != 1 {
+		t.Errorf("expect one result for each value but got %v", r)
+	}
+	if requests != 4 {
+		t.Errorf("expect 4 requests but got %d", requests)
+	}
+}
+
+func TestLookupBatch(t *testing.T) {
+	transform.RegisterAdditionalFuncs()
+	var requests int32
+	server := mockLookupServer(&requests)
+	defer server.Close()
+	ctx := mockContext.NewMockContext("ruleLookup", "op1")
+	l := &LookupSource{}
+	err := l.Configure("", map[string]interface{}{
+		"url":       server.URL + `/devices?ids={{join "," .id}}`,
+		"dataField": "data",
+		"batchSize": 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := l.LookupBatch(ctx, nil, []string{"id"}, [][]interface{}{{1}, {3}, {2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := [][]map[string]interface{}{
+		{{"id": float64(1), "name": "sensor1"}},
+		{},
+		{{"id": float64(2), "name": "sensor2"}},
+	}
+	if len(r) != len(exp) {
+		t.Fatalf("expect %d results but got %d", len(exp), len(r))
+	}
+	for i := range exp {
+		if !reflect.DeepEqual(exp[i], lookupMessages(r[i])) {
+			t.Errorf("%d: expect %v but got %v", i, exp[i], lookupMessages(r[i]))
+		}
+	}
+	if requests != 2 {
+		t.Errorf("expect 2 requests but got %d", requests)
+	}
+	// the single lookup also renders the value list
+	s, err := l.Lookup(ctx, nil, []string{"id"}, []interface{}{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exp[2], lookupMessages(s)) {
+		t.Errorf("expect %v but got %v", exp[2], lookupMessages(s))
+	}
+}
+
+func TestLookupConfigure(t *testing.T) {
+	l := &LookupSource{}
+	err := l.Configure("", map[string]interface{}{
+		"url":       "http://localhost/devices",
+		"batchSize": 0,
+	})
+	if err == nil || err.Error() != "batchSize must be greater than 0" {
+		t.Errorf("expect batchSize error but got %v", err)
+	}
+}
@@ -15,21 +15,48 @@
 package http
 
 import (
+	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/pkg/httpx"
 	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
 	"github.com/lf-edge/ekuiper/pkg/infra"
 )
 
 type PullSource struct {
 	ClientConf
+	pullConf *PullConf
+
+	// the incremental cursor and etag which are saved as the offset in the rule state
+	mu     sync.Mutex
+	cursor interface{}
+	etag   string
 }
 
 func (hps *PullSource) Configure(device string, props map[string]interface{}) error {
 	conf.Log.Infof("Initialized Httppull source with configurations %#v.", props)
-	return hps.InitConf(device, props)
+	if err := hps.InitConf(device, props); err != nil {
+		return err
+	}
+	pc := &PullConf{}
+	if err := cast.MapToStruct(props, pc); err != nil {
+		return fmt.Errorf("fail to parse the properties: %v", err)
+	}
+	if err := pc.validate(); err != nil {
+		return err
+	}
+	if pc.Pagination != nil && hps.config.Incremental {
+		return fmt.Errorf("incremental cannot be used with pagination, use cursor instead")
+	}
+	if pc.Cursor != nil {
+		hps.cursor = pc.Cursor.Initial
+	}
+	hps.pullConf = pc
+	return nil
 }
 
 func (hps *PullSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
@@ -71,32 +98,22 @@ func (hps *PullSource) initTimerPull(ctx api.StreamContext, consumer chan<- api.
 		select {
 		case <-ticker.C:
 			rcvTime := conf.GetNow()
-			headers, err := hps.parseHeaders(ctx, hps.tokens)
+			results, err := hps.pull(ctx, &omd5)
 			if err != nil {
+				logger.Warnf("Pull error %v", err)
 				continue
 			}
-			ctx.GetLogger().Debugf("rest sink sending request url: %s, headers: %v, body %s", hps.config.Url, headers, hps.config.Body)
-			if resp, e := httpx.Send(logger, hps.client, hps.config.BodyType, hps.config.Method, hps.config.Url, headers, true, []byte(hps.config.Body)); e != nil {
-				logger.Warnf("Found error %s when trying to reach %v ", e, hps)
-			} else {
-				logger.Debugf("rest sink got response %v", resp)
-				results, _, e := hps.parseResponse(ctx, resp, true, &omd5)
-				if e != nil {
-					logger.Errorf("Parse response error %v", e)
-					continue
-				}
-				if results == nil {
-					logger.Debugf("no data to send for incremental")
-					continue
-				}
-				meta := make(map[string]interface{})
-				for _, result := range results {
-					select {
-					case consumer <- api.NewDefaultSourceTupleWithTime(result, meta, rcvTime):
-						logger.Debugf("send data to device node")
-					case <-ctx.Done():
-						return
-					}
+			if len(results) == 0 {
+				logger.Debugf("no data to send for incremental")
+				continue
+			}
+			meta := make(map[string]interface{})
+			for _, result := range results {
+				select {
+				case consumer <- api.NewDefaultSourceTupleWithTime(result, meta, rcvTime):
+					logger.Debugf("send data to device node")
+				case <-ctx.Done():
+					return
 				}
 			}
 		case <-ctx.Done():
@@ -104,3 +121,109 @@ func (hps *PullSource) initTimerPull(ctx api.StreamContext, consumer chan<- api.
 		}
 	}
 }
+
+// pull fetches the records of all the pages in a poll. The cursor and etag are only updated if all the pages are
+// fetched, otherwise the next poll starts over from the same cursor
+func (hps *PullSource) pull(ctx api.StreamContext, omd5 *string) ([]map[string]interface{}, error) {
+	logger := ctx.GetLogger()
+	headers, err := hps.parseHeaders(ctx, hps.tokens)
+	if err != nil {
+		return nil, err
+	}
+	cursor, etag := hps.getCursor()
+	useEtag := hps.pullConf.Cursor != nil && hps.pullConf.Cursor.Etag
+	if useEtag && etag != "" {
+		headers["If-None-Match"] = etag
+	}
+	req, err := hps.pullConf.firstPage(hps.config.Url, cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %v", hps.config.Url, err)
+	}
+	var results []map[string]interface{}
+	for {
+		logger.Debugf("httppull source sending request url: %s, headers: %v, body %s", req.url, headers, hps.config.Body)
+		resp, err := httpx.Send(logger, hps.client, hps.config.BodyType, hps.config.Method, req.url, headers, true, []byte(hps.config.Body))
+		if err != nil {
+			return nil, fmt.Errorf("found error %s when trying to reach %s", err, req.url)
+		}
+		logger.Debugf("httppull source got response %v", resp)
+		if resp.StatusCode == http.StatusNotModified {
+			_ = resp.Body.Close()
+			logger.Debugf("content is not modified since last fetch")
+			break
+		}
+		if useEtag && req.count == 0 {
+			if e := resp.Header.Get("ETag"); e != "" {
+				etag = e
+			}
+		}
+		header := resp.Header
+		payloads, _, err := hps.parseResponse(ctx, resp, true, omd5)
+		if err != nil {
+			return nil, fmt.Errorf("parse response error %v", err)
+		}
+		// incremental content not changed
+		if payloads == nil {
+			break
+		}
+		records, err := hps.pullConf.records(payloads)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, records...)
+		var body map[string]interface{}
+		if len(payloads) == 1 {
+			body = payloads[0]
+		}
+		more, err := hps.pullConf.nextPage(req, header, body, len(records))
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+		// the etag is for the first page only
+		delete(headers, "If-None-Match")
+	}
+	hps.setCursor(hps.pullConf.maxCursor(cursor, results), etag)
+	return results, nil
+}
+
+func (hps *PullSource) getCursor() (interface{}, string) {
+	hps.mu.Lock()
+	defer hps.mu.Unlock()
+	return hps.cursor, hps.etag
+}
+
+func (hps *PullSource) setCursor(cursor interface{}, etag string) {
+	hps.mu.Lock()
+	defer hps.mu.Unlock()
+	hps.cursor = cursor
+	hps.etag = etag
+}
+
+// GetOffset returns the cursor and the etag to save in the rule state
+func (hps *PullSource) GetOffset() (interface{}, error) {
+	if hps.pullConf == nil || hps.pullConf.Cursor == nil {
+		return nil, nil
+	}
+	cursor, etag := hps.getCursor()
+	return map[string]interface{}{
+		"cursor": cursor,
+		"etag":   etag,
+	}, nil
+}
+
+// Rewind restores the cursor and the etag from the rule state
+func (hps *PullSource) Rewind(offset interface{}) error {
+	if hps.pullConf == nil || hps.pullConf.Cursor == nil {
+		return nil
+	}
+	m, ok := offset.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid offset %v of httppull source", offset)
+	}
+	etag, _ := m["etag"].(string)
+	hps.setCursor(m["cursor"], etag)
+	return nil
+}
@@ -179,6 +179,17 @@ func mockAuthServer() *httptest.Server {
 		jsonOut(w, out)
 	}).Methods(http.MethodGet)
 
+	// Return the records with ts greater than since, 2 records per page
+	router.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		since, _ := strconv.Atoi(r.URL.Query().Get("since"))
+		items := make([]map[string]interface{}, 0, 2)
+		for ts := since + 1 + (page-1)*2; ts <= 4 && len(items) < 2; ts++ {
+			items = append(items, map[string]interface{}{"ts": ts})
+		}
+		jsonOut(w, map[string]interface{}{"data": items})
+	}).Methods(http.MethodGet)
+
 	server := httptest.NewUnstartedServer(router)
 	server.Listener.Close()
 	server.Listener = l
@@ -887,3 +898,57 @@ func TestPullJsonList(t *testing.T) {
 	}
 	mock.TestSourceOpen(r, exp, t)
 }
+
+func TestPullPagination(t *testing.T) {
+	r := &PullSource{}
+	server := mockAuthServer()
+	server.Start()
+	defer server.Close()
+	err := r.Configure("page", map[string]interface{}{
+		"url":       "http://localhost:52345/",
+		"interval":  100,
+		"dataField": "data",
+		"pagination": map[string]interface{}{
+			"type": "page",
+		},
+		"cursor": map[string]interface{}{
+			"field": "ts",
+			"param": "since",
+		},
+	})
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	mc := conf.Clock.(*clock.Mock)
+	exp := []api.SourceTuple{
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"ts": float64(1)}, map[string]interface{}{}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"ts": float64(2)}, map[string]interface{}{}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"ts": float64(3)}, map[string]interface{}{}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"ts": float64(4)}, map[string]interface{}{}, mc.Now()),
+	}
+	mock.TestSourceOpen(r, exp, t)
+	offset, err := r.GetOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expOffset := map[string]interface{}{"cursor": float64(4), "etag": ""}
+	if !reflect.DeepEqual(offset, expOffset) {
+		t.Errorf("expect offset %v but got %v", expOffset, offset)
+	}
+	// restart from the saved cursor
+	r2 := &PullSource{}
+	err = r2.Configure("page", map[string]interface{}{
+		"url":    "http://localhost:52345/",
+		"cursor": map[string]interface{}{"field": "ts", "param": "since"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.Rewind(offset); err != nil {
+		t.Fatal(err)
+	}
+	if cursor, _ := r2.getCursor(); cursor != float64(4) {
+		t.Errorf("expect cursor 4 after rewind but got %v", cursor)
+	}
+}
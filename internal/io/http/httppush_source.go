@@ -17,6 +17,7 @@ package http
 import (
 	"fmt"
 	"net/http"
+	"regexp"
 	"strings"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
@@ -32,10 +33,17 @@ type PushConf struct {
 	ContentType  string `json:"contentType"`
 	BufferLength int    `json:"bufferLength"`
 	Endpoint     string `json:"endpoint"`
+	// Endpoints are the additional endpoints of the source besides the datasource
+	Endpoints []string `json:"endpoints"`
+	// Schema validates the messages, the request with invalid messages is rejected
+	Schema *PayloadSchema `json:"schema"`
+	// OAuth2 validates the bearer token of the requests by token introspection
+	OAuth2 *IntrospectionConf `json:"oauth2"`
 }
 
 type PushSource struct {
-	conf *PushConf
+	conf         *PushConf
+	introspector *introspector
 }
 
 func (hps *PushSource) Configure(endpoint string, props map[string]interface{}) error {
@@ -57,6 +65,28 @@ func (hps *PushSource) Configure(endpoint string, props map[string]interface{})
 	if !strings.HasPrefix(endpoint, "/") {
 		return fmt.Errorf("property `endpoint` must start with /")
 	}
+	endpoints := map[string]struct{}{endpoint: {}}
+	for _, e := range cfg.Endpoints {
+		if !strings.HasPrefix(e, "/") {
+			return fmt.Errorf("endpoint %s must start with /", e)
+		}
+		if _, ok := endpoints[e]; ok {
+			return fmt.Errorf("duplicate endpoint %s", e)
+		}
+		endpoints[e] = struct{}{}
+	}
+	if cfg.Schema != nil {
+		if err := cfg.Schema.validateConf(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.OAuth2 != nil {
+		hps.introspector, err = newIntrospector(cfg.OAuth2)
+		if err != nil {
+			return err
+		}
+	}
 
 	cfg.Endpoint = endpoint
 	hps.conf = cfg
@@ -65,14 +95,38 @@ func (hps *PushSource) Configure(endpoint string, props map[string]interface{})
 }
 
 func (hps *PushSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
-	t, done, err := httpserver.RegisterEndpoint(hps.conf.Endpoint, hps.conf.Method, hps.conf.ContentType)
-	if err != nil {
-		infra.DrainError(ctx, err, errCh)
-		return
+	opts := &httpserver.EndpointOptions{}
+	if hps.introspector != nil {
+		opts.Auth = hps.introspector.authorize
+	}
+	if hps.conf.Schema != nil {
+		opts.Validate = hps.conf.Schema.Validate
+	}
+	endpoints := append([]string{hps.conf.Endpoint}, hps.conf.Endpoints...)
+	topics := make([]string, 0, len(endpoints))
+	var done chan struct{}
+	for _, e := range endpoints {
+		t, d, err := httpserver.RegisterEndpointWithOptions(e, hps.conf.Method, hps.conf.ContentType, opts)
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+			return
+		}
+		defer httpserver.UnregisterEndpoint(e)
+		topics = append(topics, t)
+		done = d
+	}
+	// subscribe all the endpoints by regex, the endpoint of a message is in the topic meta
+	var regex *regexp.Regexp
+	if len(topics) > 1 {
+		quoted := make([]string, len(topics))
+		for i, t := range topics {
+			quoted[i] = regexp.QuoteMeta(t)
+		}
+		regex = regexp.MustCompile("^(" + strings.Join(quoted, "|") + ")$")
 	}
-	defer httpserver.UnregisterEndpoint(hps.conf.Endpoint)
-	ch := pubsub.CreateSub(t, nil, fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId()), hps.conf.BufferLength)
-	defer pubsub.CloseSourceConsumerChannel(t, fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId()))
+	sourceId := fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId())
+	ch := pubsub.CreateSub(topics[0], regex, sourceId, hps.conf.BufferLength)
+	defer pubsub.CloseSourceConsumerChannel(topics[0], sourceId)
 	for {
 		select {
 		case <-done: // http data server error
@@ -17,6 +17,7 @@ package httpserver
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"sync"
 	"time"
@@ -71,31 +72,95 @@ func registerInit() error {
 	return nil
 }
 
-func RegisterEndpoint(endpoint string, method string, _ string) (string, chan struct{}, error) {
+// Authorizer checks the incoming request, the request is rejected as unauthorized if it returns error
+type Authorizer func(r *http.Request) error
+
+// Validator checks the decoded message, the request is rejected as bad request if any of its messages is invalid
+type Validator func(m map[string]interface{}) error
+
+type EndpointOptions struct {
+	Auth     Authorizer
+	Validate Validator
+}
+
+func RegisterEndpoint(endpoint string, method string, contentType string) (string, chan struct{}, error) {
+	return RegisterEndpointWithOptions(endpoint, method, contentType, nil)
+}
+
+// RegisterEndpointWithOptions registers the endpoint to publish the messages of the requests to the returned topic.
+// The json object body is a message, and each element of the json array body is a message
+func RegisterEndpointWithOptions(endpoint string, method string, _ string, opts *EndpointOptions) (string, chan struct{}, error) {
 	err := registerInit()
 	if err != nil {
 		return "", nil, err
 	}
+	if opts == nil {
+		opts = &EndpointOptions{}
+	}
 	topic := TopicPrefix + endpoint
 	pubsub.CreatePub(topic)
 	router.HandleFunc(endpoint, func(w http.ResponseWriter, r *http.Request) {
 		sctx.GetLogger().Debugf("receive http request: %s", r.URL.String())
 		defer r.Body.Close()
-		m := make(map[string]interface{})
-		err := json.NewDecoder(r.Body).Decode(&m)
+		if opts.Auth != nil {
+			if err := opts.Auth(r); err != nil {
+				sctx.GetLogger().Debugf("reject unauthorized request: %v", err)
+				http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+		msgs, err := decode(r.Body)
 		if err != nil {
 			handleError(w, err, "Fail to decode data")
 			pubsub.ProduceError(sctx, topic, fmt.Errorf("fail to decode data %s: %v", r.Body, err))
 			return
 		}
-		sctx.GetLogger().Debugf("httppush received message %s", m)
-		pubsub.Produce(sctx, topic, m)
+		if opts.Validate != nil {
+			for i, m := range msgs {
+				if err := opts.Validate(m); err != nil {
+					if len(msgs) > 1 {
+						err = fmt.Errorf("message %d: %v", i, err)
+					}
+					handleError(w, err, "Invalid data")
+					pubsub.ProduceError(sctx, topic, fmt.Errorf("invalid data: %v", err))
+					return
+				}
+			}
+		}
+		for _, m := range msgs {
+			sctx.GetLogger().Debugf("httppush received message %s", m)
+			pubsub.Produce(sctx, topic, m)
+		}
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	}).Methods(method)
 	return topic, done, nil
 }
 
+// decode reads the json object or the json array of objects
+func decode(body io.Reader) ([]map[string]interface{}, error) {
+	var v interface{}
+	if err := json.NewDecoder(body).Decode(&v); err != nil {
+		return nil, err
+	}
+	switch vt := v.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{vt}, nil
+	case []interface{}:
+		msgs := make([]map[string]interface{}, len(vt))
+		for i, e := range vt {
+			m, ok := e.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("element %d of the array is not an object", i)
+			}
+			msgs[i] = m
+		}
+		return msgs, nil
+	default:
+		return nil, fmt.Errorf("must be an object or an array of objects")
+	}
+}
+
 func UnregisterEndpoint(endpoint string) {
 	lock.Lock()
 	defer lock.Unlock()
@@ -16,10 +16,14 @@ package httpserver
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
 	"github.com/lf-edge/ekuiper/internal/testx"
 )
 
@@ -98,3 +102,95 @@ func testHttp(client *http.Client, url string, method string) error {
 	}
 	return nil
 }
+
+func TestEndpointOptions(t *testing.T) {
+	testx.InitEnv()
+	endpoint := "/eo1"
+	topic, _, err := RegisterEndpointWithOptions(endpoint, "POST", "application/json", &EndpointOptions{
+		Auth: func(r *http.Request) error {
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				return errors.New("invalid token")
+			}
+			return nil
+		},
+		Validate: func(m map[string]interface{}) error {
+			if _, ok := m["id"]; !ok {
+				return errors.New("missing required field id")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterEndpoint(endpoint)
+	ch := pubsub.CreateSub(topic, nil, "testEndpointOptions", 10)
+	defer pubsub.CloseSourceConsumerChannel(topic, "testEndpointOptions")
+
+	tests := []struct {
+		token  string
+		body   string
+		status int
+		result []map[string]interface{}
+	}{
+		{
+			token:  "Bearer abc",
+			body:   `{"id":1}`,
+			status: http.StatusOK,
+			result: []map[string]interface{}{{"id": 1.0}},
+		}, {
+			token:  "Bearer abc",
+			body:   `[{"id":2},{"id":3}]`,
+			status: http.StatusOK,
+			result: []map[string]interface{}{{"id": 2.0}, {"id": 3.0}},
+		}, {
+			token:  "Bearer abc",
+			body:   `[]`,
+			status: http.StatusOK,
+		}, {
+			body:   `{"id":1}`,
+			status: http.StatusUnauthorized,
+		}, {
+			token:  "Bearer abc",
+			body:   `[{"id":4},{"name":"a"}]`,
+			status: http.StatusBadRequest,
+		}, {
+			token:  "Bearer abc",
+			body:   `[{"id":4},1]`,
+			status: http.StatusBadRequest,
+		},
+	}
+	for i, tt := range tests {
+		r, err := http.NewRequest("POST", "http://localhost:10081"+endpoint, bytes.NewBufferString(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.token != "" {
+			r.Header.Set("Authorization", tt.token)
+		}
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%d: expect status %d but got %d", i, tt.status, resp.StatusCode)
+		}
+		var result []map[string]interface{}
+	loop:
+		for {
+			select {
+			case v := <-ch:
+				// skip the error tuples of the bad requests
+				if m := v.Message(); m != nil {
+					result = append(result, m)
+				}
+			case <-time.After(100 * time.Millisecond):
+				break loop
+			}
+		}
+		if !reflect.DeepEqual(result, tt.result) {
+			t.Errorf("%d: expect %v but got %v", i, tt.result, result)
+		}
+	}
+}
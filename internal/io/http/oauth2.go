@@ -0,0 +1,285 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+
+	AuthStyleHeader = "header"
+	AuthStyleParams = "params"
+
+	DefaultExpiryDelta = 10
+	DefaultCacheTTL    = 60
+)
+
+// OAuth2Conf is the standard OAuth 2.0 client configuration. The access token is fetched from the token endpoint by the
+// client credentials or the refresh token grant, and renewed before it expires
+type OAuth2Conf struct {
+	// GrantType could be client_credentials or refresh_token
+	GrantType    string   `json:"grantType"`
+	TokenUrl     string   `json:"tokenUrl"`
+	ClientId     string   `json:"clientId"`
+	ClientSecret string   `json:"clientSecret"`
+	Scopes       []string `json:"scopes"`
+	// RefreshToken is the initial refresh token of refresh_token grant, it is replaced if the server rotates it
+	RefreshToken string `json:"refreshToken"`
+	// AuthStyle sends the client credentials by the basic auth header or the form params
+	AuthStyle string `json:"authStyle"`
+	// Params are the additional form params of the token request such as audience
+	Params map[string]string `json:"params"`
+	// ExpiryDelta renews the token earlier than it expires in seconds
+	ExpiryDelta int `json:"expiryDelta"`
+}
+
+type tokenResp struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// tokenSource caches the access token and renews it when it is about to expire or is rejected
+type tokenSource struct {
+	c      *OAuth2Conf
+	client *http.Client
+
+	mu           sync.Mutex
+	token        string
+	refreshToken string
+	// zero expiry means the token does not expire
+	expiry time.Time
+}
+
+func newTokenSource(c *OAuth2Conf, client *http.Client) (*tokenSource, error) {
+	if c.TokenUrl == "" {
+		return nil, fmt.Errorf("oauth2 tokenUrl is required")
+	}
+	if c.AuthStyle == "" {
+		c.AuthStyle = AuthStyleHeader
+	}
+	if c.AuthStyle != AuthStyleHeader && c.AuthStyle != AuthStyleParams {
+		return nil, fmt.Errorf("invalid oauth2 authStyle %s, must be header or params", c.AuthStyle)
+	}
+	if c.ExpiryDelta == 0 {
+		c.ExpiryDelta = DefaultExpiryDelta
+	}
+	switch c.GrantType {
+	case "", GrantClientCredentials:
+		c.GrantType = GrantClientCredentials
+		if c.ClientId == "" {
+			return nil, fmt.Errorf("oauth2 clientId is required for client_credentials grant")
+		}
+	case GrantRefreshToken:
+		if c.RefreshToken == "" {
+			return nil, fmt.Errorf("oauth2 refreshToken is required for refresh_token grant")
+		}
+	default:
+		return nil, fmt.Errorf("invalid oauth2 grantType %s, must be client_credentials or refresh_token", c.GrantType)
+	}
+	return &tokenSource{
+		c:            c,
+		client:       client,
+		refreshToken: c.RefreshToken,
+	}, nil
+}
+
+// Token returns the authorization header value of the valid access token
+func (ts *tokenSource) Token() (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token == "" || (!ts.expiry.IsZero() && !conf.GetNow().Before(ts.expiry)) {
+		if err := ts.fetch(); err != nil {
+			return "", err
+		}
+	}
+	return "Bearer " + ts.token, nil
+}
+
+// invalidate drops the token rejected by the server, so it is renewed in the next request
+func (ts *tokenSource) invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.token = ""
+}
+
+// fetch requests a new token from the token endpoint. Must run inside lock
+func (ts *tokenSource) fetch() error {
+	form := url.Values{}
+	form.Set("grant_type", ts.c.GrantType)
+	if ts.c.GrantType == GrantRefreshToken {
+		form.Set("refresh_token", ts.refreshToken)
+	}
+	if len(ts.c.Scopes) > 0 {
+		form.Set("scope", strings.Join(ts.c.Scopes, " "))
+	}
+	for k, v := range ts.c.Params {
+		form.Set(k, v)
+	}
+	if ts.c.AuthStyle == AuthStyleParams {
+		form.Set("client_id", ts.c.ClientId)
+		if ts.c.ClientSecret != "" {
+			form.Set("client_secret", ts.c.ClientSecret)
+		}
+	}
+	req, err := http.NewRequest(http.MethodPost, ts.c.TokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if ts.c.AuthStyle == AuthStyleHeader && ts.c.ClientId != "" {
+		req.SetBasicAuth(url.QueryEscape(ts.c.ClientId), url.QueryEscape(ts.c.ClientSecret))
+	}
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fail to request the token: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("fail to read the token response: %v", err)
+	}
+	tr := &tokenResp{}
+	if err := json.Unmarshal(body, tr); err != nil {
+		return fmt.Errorf("invalid token response %s with status %d", body, resp.StatusCode)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || tr.Error != "" {
+		return fmt.Errorf("token endpoint returns error %s %s with status %d", tr.Error, tr.ErrorDescription, resp.StatusCode)
+	}
+	if tr.AccessToken == "" {
+		return fmt.Errorf("no access_token in token response %s", body)
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return fmt.Errorf("unsupported token type %s", tr.TokenType)
+	}
+	ts.token = tr.AccessToken
+	if tr.RefreshToken != "" {
+		ts.refreshToken = tr.RefreshToken
+	}
+	ts.expiry = time.Time{}
+	if tr.ExpiresIn > 0 {
+		ts.expiry = conf.GetNow().Add(time.Duration(tr.ExpiresIn-int64(ts.c.ExpiryDelta)) * time.Second)
+	}
+	return nil
+}
+
+// IntrospectionConf validates the bearer tokens of the incoming requests by the token introspection endpoint of RFC 7662
+type IntrospectionConf struct {
+	IntrospectionUrl string `json:"introspectionUrl"`
+	ClientId         string `json:"clientId"`
+	ClientSecret     string `json:"clientSecret"`
+	// CacheTTL caches the active tokens in seconds to avoid introspecting every request
+	CacheTTL int `json:"cacheTtl"`
+}
+
+type introspectResp struct {
+	Active bool  `json:"active"`
+	Exp    int64 `json:"exp"`
+}
+
+type introspector struct {
+	c      *IntrospectionConf
+	client *http.Client
+
+	mu sync.Mutex
+	// the active tokens and the time to introspect again
+	cache map[string]time.Time
+}
+
+func newIntrospector(c *IntrospectionConf) (*introspector, error) {
+	if c.IntrospectionUrl == "" {
+		return nil, fmt.Errorf("oauth2 introspectionUrl is required")
+	}
+	if c.CacheTTL == 0 {
+		c.CacheTTL = DefaultCacheTTL
+	}
+	return &introspector{
+		c:      c,
+		client: &http.Client{Timeout: DefaultTimeout * time.Millisecond},
+		cache:  make(map[string]time.Time),
+	}, nil
+}
+
+// authorize checks the bearer token of the request is active
+func (in *introspector) authorize(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return fmt.Errorf("missing bearer token")
+	}
+	token := auth[7:]
+	now := conf.GetNow()
+	in.mu.Lock()
+	until, ok := in.cache[token]
+	in.mu.Unlock()
+	if ok && now.Before(until) {
+		return nil
+	}
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+	req, err := http.NewRequest(http.MethodPost, in.c.IntrospectionUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if in.c.ClientId != "" {
+		req.SetBasicAuth(url.QueryEscape(in.c.ClientId), url.QueryEscape(in.c.ClientSecret))
+	}
+	resp, err := in.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fail to introspect the token: %v", err)
+	}
+	defer resp.Body.Close()
+	ir := &introspectResp{}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fail to introspect the token with status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(ir); err != nil {
+		return fmt.Errorf("invalid introspection response: %v", err)
+	}
+	if !ir.Active {
+		return fmt.Errorf("inactive token")
+	}
+	until = now.Add(time.Duration(in.c.CacheTTL) * time.Second)
+	if ir.Exp > 0 && time.Unix(ir.Exp, 0).Before(until) {
+		until = time.Unix(ir.Exp, 0)
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for t, u := range in.cache {
+		if !now.Before(u) {
+			delete(in.cache, t)
+		}
+	}
+	in.cache[token] = until
+	return nil
+}
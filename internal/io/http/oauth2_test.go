@@ -0,0 +1,221 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+func mockTokenServer(t *testing.T) (*httptest.Server, *int32) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		n := atomic.AddInt32(&count, 1)
+		switch r.Form.Get("grant_type") {
+		case GrantClientCredentials:
+			id, secret, ok := r.BasicAuth()
+			if !ok {
+				id, secret = r.Form.Get("client_id"), r.Form.Get("client_secret")
+			}
+			if id != "client" || secret != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				jsonOut(w, map[string]interface{}{"error": "invalid_client"})
+				return
+			}
+			if r.Form.Get("scope") != "read write" {
+				t.Errorf("unexpected scope %s", r.Form.Get("scope"))
+			}
+		case GrantRefreshToken:
+			if r.Form.Get("refresh_token") != fmt.Sprintf("r%d", n-1) {
+				w.WriteHeader(http.StatusBadRequest)
+				jsonOut(w, map[string]interface{}{"error": "invalid_grant"})
+				return
+			}
+		}
+		jsonOut(w, map[string]interface{}{
+			"access_token":  fmt.Sprintf("t%d", n),
+			"token_type":    "Bearer",
+			"expires_in":    60,
+			"refresh_token": fmt.Sprintf("r%d", n),
+		})
+	}))
+	return server, &count
+}
+
+func TestTokenSource(t *testing.T) {
+	server, count := mockTokenServer(t)
+	defer server.Close()
+	mc := conf.Clock.(*clock.Mock)
+	tests := []struct {
+		name string
+		c    *OAuth2Conf
+	}{
+		{
+			name: "client credentials",
+			c:    &OAuth2Conf{TokenUrl: server.URL, ClientId: "client", ClientSecret: "secret", Scopes: []string{"read", "write"}},
+		}, {
+			name: "client credentials in params",
+			c:    &OAuth2Conf{TokenUrl: server.URL, ClientId: "client", ClientSecret: "secret", Scopes: []string{"read", "write"}, AuthStyle: AuthStyleParams},
+		}, {
+			name: "refresh token",
+			c:    &OAuth2Conf{TokenUrl: server.URL, GrantType: GrantRefreshToken, RefreshToken: "r0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(count, 0)
+			ts, err := newTokenSource(tt.c, http.DefaultClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expects := []struct {
+				advance    time.Duration
+				invalidate bool
+				token      string
+			}{
+				{token: "Bearer t1"},
+				{advance: 30 * time.Second, token: "Bearer t1"},
+				// renew before the 60 seconds expiry
+				{advance: 21 * time.Second, token: "Bearer t2"},
+				{invalidate: true, token: "Bearer t3"},
+			}
+			for i, e := range expects {
+				mc.Add(e.advance)
+				if e.invalidate {
+					ts.invalidate()
+				}
+				token, err := ts.Token()
+				if err != nil {
+					t.Fatalf("%d: %v", i, err)
+				}
+				if token != e.token {
+					t.Errorf("%d: expect token %s but got %s", i, e.token, token)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenSourceError(t *testing.T) {
+	server, _ := mockTokenServer(t)
+	defer server.Close()
+	tests := []struct {
+		c   *OAuth2Conf
+		err string
+	}{
+		{
+			c:   &OAuth2Conf{ClientId: "client"},
+			err: "oauth2 tokenUrl is required",
+		}, {
+			c:   &OAuth2Conf{TokenUrl: server.URL},
+			err: "oauth2 clientId is required for client_credentials grant",
+		}, {
+			c:   &OAuth2Conf{TokenUrl: server.URL, GrantType: GrantRefreshToken},
+			err: "oauth2 refreshToken is required for refresh_token grant",
+		}, {
+			c:   &OAuth2Conf{TokenUrl: server.URL, GrantType: "password"},
+			err: "invalid oauth2 grantType password, must be client_credentials or refresh_token",
+		}, {
+			c:   &OAuth2Conf{TokenUrl: server.URL, ClientId: "client", AuthStyle: "body"},
+			err: "invalid oauth2 authStyle body, must be header or params",
+		}, {
+			c:   &OAuth2Conf{TokenUrl: server.URL, ClientId: "client", ClientSecret: "wrong", Scopes: []string{"read", "write"}},
+			err: "token endpoint returns error invalid_client  with status 401",
+		},
+	}
+	for i, tt := range tests {
+		ts, err := newTokenSource(tt.c, http.DefaultClient)
+		if err == nil {
+			_, err = ts.Token()
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestIntrospector(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		if id, secret, _ := r.BasicAuth(); id != "rs" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		jsonOut(w, map[string]interface{}{"active": r.FormValue("token") == "good"})
+	}))
+	defer server.Close()
+	in, err := newIntrospector(&IntrospectionConf{IntrospectionUrl: server.URL, ClientId: "rs", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		auth  string
+		err   string
+		count int32
+	}{
+		{auth: "", err: "missing bearer token", count: 0},
+		{auth: "Basic abc", err: "missing bearer token", count: 0},
+		{auth: "Bearer bad", err: "inactive token", count: 1},
+		{auth: "Bearer good", count: 2},
+		// cached
+		{auth: "bearer good", count: 2},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		err := in.authorize(r)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+		if c := atomic.LoadInt32(&count); c != tt.count {
+			t.Errorf("%d: expect %d introspection requests but got %d", i, tt.count, c)
+		}
+	}
+	_, err = newIntrospector(&IntrospectionConf{})
+	if err == nil || err.Error() != "oauth2 introspectionUrl is required" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestOAuthConflict(t *testing.T) {
+	r := &PullSource{}
+	err := r.Configure("", map[string]interface{}{
+		"url": "http://localhost:9090/",
+		"oauth": map[string]interface{}{
+			"access": map[string]interface{}{},
+		},
+		"oauth2": map[string]interface{}{
+			"tokenUrl": "http://localhost:9090/token",
+			"clientId": "client",
+		},
+	})
+	if err == nil || err.Error() != "oauth and oauth2 cannot be set together" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const (
+	PaginationLink   = "link"
+	PaginationPage   = "page"
+	PaginationOffset = "offset"
+
+	DefaultMaxPages = 100
+)
+
+// PullConf is the configuration of httppull to fetch the records across the pages and the polls
+type PullConf struct {
+	// DataField is the path of the records in the response body such as data.items, the whole body is the records if not set
+	DataField  string          `json:"dataField"`
+	Pagination *PaginationConf `json:"pagination"`
+	Cursor     *CursorConf     `json:"cursor"`
+}
+
+// PaginationConf defines how to follow the pages in each poll
+type PaginationConf struct {
+	// Type could be link, page or offset
+	Type string `json:"type"`
+	// NextField is the path of the next page url in the response body for link type. The Link header is used if not set
+	NextField string `json:"nextField"`
+	// PageParam and StartPage are the query parameter and the first number of the page for page type
+	PageParam string `json:"pageParam"`
+	StartPage int    `json:"startPage"`
+	// OffsetParam is the query parameter of the offset for offset type
+	OffsetParam string `json:"offsetParam"`
+	// LimitParam and Limit are the query parameter and the value of the page size. The last page is detected if it
+	// has fewer records than the limit. The limit is required for offset type
+	LimitParam string `json:"limitParam"`
+	Limit      int    `json:"limit"`
+	// MaxPages limits the number of pages fetched in one poll
+	MaxPages int `json:"maxPages"`
+}
+
+// CursorConf defines the incremental cursor which is saved in the rule state
+type CursorConf struct {
+	// Field is the path of the field in the records, the max value of it is the cursor of the next poll
+	Field string `json:"field"`
+	// Param is the query parameter to send the cursor
+	Param string `json:"param"`
+	// Initial is the cursor of the first poll, the cursor is not sent if not set
+	Initial interface{} `json:"initial"`
+	// Etag sends the etag of the last response in the If-None-Match header, the not modified response has no records
+	Etag bool `json:"etag"`
+}
+
+func (c *PullConf) validate() error {
+	if p := c.Pagination; p != nil {
+		if p.MaxPages == 0 {
+			p.MaxPages = DefaultMaxPages
+		}
+		if p.MaxPages < 0 {
+			return fmt.Errorf("pagination maxPages must be greater than 0")
+		}
+		if p.LimitParam == "" {
+			p.LimitParam = "limit"
+		}
+		if p.Limit < 0 {
+			return fmt.Errorf("pagination limit must be greater than or equal to 0")
+		}
+		switch p.Type {
+		case PaginationLink:
+		case PaginationPage:
+			if p.PageParam == "" {
+				p.PageParam = "page"
+			}
+			if p.StartPage == 0 {
+				p.StartPage = 1
+			}
+		case PaginationOffset:
+			if p.OffsetParam == "" {
+				p.OffsetParam = "offset"
+			}
+			if p.Limit == 0 {
+				return fmt.Errorf("pagination limit is required for offset type")
+			}
+		default:
+			return fmt.Errorf("invalid pagination type %s, must be link, page or offset", p.Type)
+		}
+	}
+	if cc := c.Cursor; cc != nil {
+		if (cc.Field == "") != (cc.Param == "") {
+			return fmt.Errorf("cursor field and param must be set together")
+		}
+		if cc.Field == "" && !cc.Etag {
+			return fmt.Errorf("cursor requires field and param or etag")
+		}
+	}
+	return nil
+}
+
+// pageRequest is the state of the pages in a poll
+type pageRequest struct {
+	url    string
+	page   int
+	offset int
+	count  int
+}
+
+// firstPage builds the url of the first page with the cursor. The url is kept as is if no query parameter is added
+func (c *PullConf) firstPage(rawUrl string, cursor interface{}) (*pageRequest, error) {
+	params := make(map[string]string)
+	if c.Cursor != nil && c.Cursor.Param != "" && cursor != nil {
+		params[c.Cursor.Param] = cursorString(cursor)
+	}
+	r := &pageRequest{url: rawUrl}
+	if p := c.Pagination; p != nil {
+		if p.Limit > 0 {
+			params[p.LimitParam] = strconv.Itoa(p.Limit)
+		}
+		switch p.Type {
+		case PaginationPage:
+			r.page = p.StartPage
+			params[p.PageParam] = strconv.Itoa(r.page)
+		case PaginationOffset:
+			params[p.OffsetParam] = "0"
+		}
+	}
+	if len(params) == 0 {
+		return r, nil
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+	r.url = u.String()
+	return r, nil
+}
+
+// nextPage returns the url of the next page, or false if the current page is the last one
+func (c *PullConf) nextPage(r *pageRequest, header http.Header, body map[string]interface{}, records int) (bool, error) {
+	p := c.Pagination
+	if p == nil {
+		return false, nil
+	}
+	r.count++
+	if r.count >= p.MaxPages || records == 0 || (p.Limit > 0 && records < p.Limit) {
+		return false, nil
+	}
+	switch p.Type {
+	case PaginationLink:
+		var next string
+		if p.NextField != "" {
+			if v, ok := getPath(body, p.NextField); ok && v != nil {
+				next = cast.ToStringAlways(v)
+			}
+		} else {
+			next = nextLink(header.Get("Link"))
+		}
+		if next == "" {
+			return false, nil
+		}
+		// the next link may be relative
+		base, err := url.Parse(r.url)
+		if err != nil {
+			return false, err
+		}
+		u, err := base.Parse(next)
+		if err != nil {
+			return false, fmt.Errorf("invalid next page url %s: %v", next, err)
+		}
+		r.url = u.String()
+	case PaginationPage:
+		r.page++
+		r.url = setQuery(r.url, p.PageParam, strconv.Itoa(r.page))
+	case PaginationOffset:
+		r.offset += records
+		r.url = setQuery(r.url, p.OffsetParam, strconv.Itoa(r.offset))
+	}
+	return true, nil
+}
+
+func setQuery(rawUrl, key, value string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+var linkNextReg = regexp.MustCompile(`<([^>]*)>\s*;[^,]*rel="?next"?`)
+
+// nextLink finds the url of rel="next" in the Link header of RFC 8288
+func nextLink(link string) string {
+	for _, l := range strings.Split(link, ",") {
+		if m := linkNextReg.FindStringSubmatch(l); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// records extracts the records from the decoded response by the data field
+func (c *PullConf) records(payloads []map[string]interface{}) ([]map[string]interface{}, error) {
+	return extractRecords(payloads, c.DataField)
+}
+
+// extractRecords gets the records in the data field of each payload. The payloads are the records if the data field is not set
+func extractRecords(payloads []map[string]interface{}, dataField string) ([]map[string]interface{}, error) {
+	if dataField == "" {
+		return payloads, nil
+	}
+	var result []map[string]interface{}
+	for _, payload := range payloads {
+		v, ok := getPath(payload, dataField)
+		if !ok || v == nil {
+			continue
+		}
+		switch rt := v.(type) {
+		case map[string]interface{}:
+			result = append(result, rt)
+		case []interface{}:
+			for _, e := range rt {
+				m, ok := e.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("the element of data field %s is not an object", dataField)
+				}
+				result = append(result, m)
+			}
+		default:
+			return nil, fmt.Errorf("data field %s must be an object or an array of objects", dataField)
+		}
+	}
+	return result, nil
+}
+
+// maxCursor returns the max value of the cursor field in the records and the old cursor
+func (c *PullConf) maxCursor(cursor interface{}, records []map[string]interface{}) interface{} {
+	if c.Cursor == nil || c.Cursor.Field == "" {
+		return cursor
+	}
+	for _, r := range records {
+		v, ok := getPath(r, c.Cursor.Field)
+		if !ok || v == nil {
+			continue
+		}
+		if cursor == nil || greater(v, cursor) {
+			cursor = v
+		}
+	}
+	return cursor
+}
+
+// greater compares the numbers or else the strings such as RFC 3339 time
+func greater(a, b interface{}) bool {
+	fa, ea := cast.ToFloat64(a, cast.CONVERT_SAMEKIND)
+	fb, eb := cast.ToFloat64(b, cast.CONVERT_SAMEKIND)
+	if ea == nil && eb == nil {
+		return fa > fb
+	}
+	return cursorString(a) > cursorString(b)
+}
+
+func cursorString(v interface{}) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return cast.ToStringAlways(v)
+}
+
+// getPath gets the value of the dot separated path in the nested maps
+func getPath(m map[string]interface{}, path string) (interface{}, bool) {
+	var v interface{} = m
+	for _, key := range strings.Split(path, ".") {
+		mv, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		v, ok = mv[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,202 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPullConfValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		c    *PullConf
+		exp  *PullConf
+		err  string
+	}{
+		{
+			name: "page default",
+			c:    &PullConf{Pagination: &PaginationConf{Type: "page"}},
+			exp:  &PullConf{Pagination: &PaginationConf{Type: "page", PageParam: "page", StartPage: 1, LimitParam: "limit", MaxPages: DefaultMaxPages}},
+		}, {
+			name: "offset default",
+			c:    &PullConf{Pagination: &PaginationConf{Type: "offset", Limit: 10}},
+			exp:  &PullConf{Pagination: &PaginationConf{Type: "offset", OffsetParam: "offset", LimitParam: "limit", Limit: 10, MaxPages: DefaultMaxPages}},
+		}, {
+			name: "offset without limit",
+			c:    &PullConf{Pagination: &PaginationConf{Type: "offset"}},
+			err:  "pagination limit is required for offset type",
+		}, {
+			name: "invalid type",
+			c:    &PullConf{Pagination: &PaginationConf{Type: "cursor"}},
+			err:  "invalid pagination type cursor, must be link, page or offset",
+		}, {
+			name: "invalid max pages",
+			c:    &PullConf{Pagination: &PaginationConf{Type: "link", MaxPages: -1}},
+			err:  "pagination maxPages must be greater than 0",
+		}, {
+			name: "cursor without param",
+			c:    &PullConf{Cursor: &CursorConf{Field: "ts"}},
+			err:  "cursor field and param must be set together",
+		}, {
+			name: "empty cursor",
+			c:    &PullConf{Cursor: &CursorConf{}},
+			err:  "cursor requires field and param or etag",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.c.validate()
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("expect error %s but got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.c, tt.exp) {
+				t.Errorf("expect %+v but got %+v", tt.exp, tt.c)
+			}
+		})
+	}
+}
+
+func TestPages(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       *PullConf
+		cursor  interface{}
+		header  http.Header
+		body    map[string]interface{}
+		records []int
+		urls    []string
+	}{
+		{
+			name:    "no pagination",
+			c:       &PullConf{},
+			records: []int{3},
+			urls:    []string{"http://localhost/data?b=1&a=2"},
+		}, {
+			name:    "page until empty",
+			c:       &PullConf{Pagination: &PaginationConf{Type: "page"}, Cursor: &CursorConf{Field: "ts", Param: "since"}},
+			cursor:  float64(1680000000000),
+			records: []int{2, 2, 0},
+			urls: []string{
+				"http://localhost/data?a=2&b=1&page=1&since=1680000000000",
+				"http://localhost/data?a=2&b=1&page=2&since=1680000000000",
+				"http://localhost/data?a=2&b=1&page=3&since=1680000000000",
+			},
+		}, {
+			name:    "offset until fewer",
+			c:       &PullConf{Pagination: &PaginationConf{Type: "offset", Limit: 2}},
+			records: []int{2, 2, 1},
+			urls: []string{
+				"http://localhost/data?a=2&b=1&limit=2&offset=0",
+				"http://localhost/data?a=2&b=1&limit=2&offset=2",
+				"http://localhost/data?a=2&b=1&limit=2&offset=4",
+			},
+		}, {
+			name:    "max pages",
+			c:       &PullConf{Pagination: &PaginationConf{Type: "page", MaxPages: 2}},
+			records: []int{1, 1},
+			urls: []string{
+				"http://localhost/data?a=2&b=1&page=1",
+				"http://localhost/data?a=2&b=1&page=2",
+			},
+		}, {
+			name:    "link header",
+			c:       &PullConf{Pagination: &PaginationConf{Type: "link"}},
+			header:  http.Header{"Link": []string{`</data?cursor=abc>; rel="next", </data?cursor=0>; rel="first"`}},
+			records: []int{1, 1},
+			urls: []string{
+				"http://localhost/data?b=1&a=2",
+				"http://localhost/data?cursor=abc",
+			},
+		}, {
+			name:    "link body",
+			c:       &PullConf{Pagination: &PaginationConf{Type: "link", NextField: "links.next"}},
+			body:    map[string]interface{}{"links": map[string]interface{}{"next": "http://localhost/next"}},
+			records: []int{1, 1},
+			urls: []string{
+				"http://localhost/data?b=1&a=2",
+				"http://localhost/next",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.c.validate(); err != nil {
+				t.Fatal(err)
+			}
+			r, err := tt.c.firstPage("http://localhost/data?b=1&a=2", tt.cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var urls []string
+			for i, n := range tt.records {
+				urls = append(urls, r.url)
+				more, err := tt.c.nextPage(r, tt.header, tt.body, n)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if more != (i < len(tt.records)-1) {
+					t.Fatalf("page %d: expect more pages %v", i, !more)
+				}
+				// the next link of the second page is not set
+				tt.header, tt.body = nil, nil
+			}
+			if !reflect.DeepEqual(urls, tt.urls) {
+				t.Errorf("expect urls %v but got %v", tt.urls, urls)
+			}
+		})
+	}
+}
+
+func TestRecordsAndCursor(t *testing.T) {
+	c := &PullConf{
+		DataField: "data.items",
+		Cursor:    &CursorConf{Field: "ts", Param: "since"},
+	}
+	payloads := []map[string]interface{}{
+		{"data": map[string]interface{}{"items": []interface{}{
+			map[string]interface{}{"id": "a", "ts": float64(3)},
+			map[string]interface{}{"id": "b", "ts": float64(12)},
+			map[string]interface{}{"id": "c"},
+		}}},
+	}
+	records, err := c.records(payloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1]["id"] != "b" {
+		t.Errorf("unexpected records %v", records)
+	}
+	if cursor := c.maxCursor(float64(5), records); cursor != float64(12) {
+		t.Errorf("expect cursor 12 but got %v", cursor)
+	}
+	if cursor := c.maxCursor(float64(20), records); cursor != float64(20) {
+		t.Errorf("expect cursor 20 but got %v", cursor)
+	}
+	if cursor := c.maxCursor("2023-01-02T00:00:00Z", []map[string]interface{}{{"ts": "2023-01-10T00:00:00Z"}, {"ts": "2023-01-01T00:00:00Z"}}); cursor != "2023-01-10T00:00:00Z" {
+		t.Errorf("expect cursor 2023-01-10T00:00:00Z but got %v", cursor)
+	}
+	_, err = c.records([]map[string]interface{}{{"data": map[string]interface{}{"items": "a"}}})
+	if err == nil || err.Error() != "data field data.items must be an object or an array of objects" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+var schemaTypes = map[string]struct{}{
+	"string":  {},
+	"number":  {},
+	"integer": {},
+	"boolean": {},
+	"object":  {},
+	"array":   {},
+	"null":    {},
+}
+
+// PayloadSchema is a subset of the JSON schema of an object to validate the pushed messages
+type PayloadSchema struct {
+	Required   []string                   `json:"required"`
+	Properties map[string]*PropertySchema `json:"properties"`
+	// AdditionalProperties rejects the fields not defined in properties if it is false
+	AdditionalProperties *bool `json:"additionalProperties"`
+}
+
+type PropertySchema struct {
+	// Type could be string, number, integer, boolean, object, array or null
+	Type string `json:"type"`
+}
+
+func (s *PayloadSchema) validateConf() error {
+	for name, p := range s.Properties {
+		if p == nil {
+			return fmt.Errorf("schema property %s is empty", name)
+		}
+		if _, ok := schemaTypes[p.Type]; !ok {
+			return fmt.Errorf("invalid type %s of schema property %s", p.Type, name)
+		}
+	}
+	return nil
+}
+
+// Validate checks the required fields and the types of the fields of the decoded json message
+func (s *PayloadSchema) Validate(m map[string]interface{}) error {
+	for _, name := range s.Required {
+		if _, ok := m[name]; !ok {
+			return fmt.Errorf("missing required field %s", name)
+		}
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	// check in order to get the stable error
+	sort.Strings(keys)
+	for _, k := range keys {
+		p, ok := s.Properties[k]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				return fmt.Errorf("unknown field %s", k)
+			}
+			continue
+		}
+		if !isType(m[k], p.Type) {
+			return fmt.Errorf("field %s must be %s", k, p.Type)
+		}
+	}
+	return nil
+}
+
+func isType(v interface{}, t string) bool {
+	switch vt := v.(type) {
+	case nil:
+		return t == "null"
+	case string:
+		return t == "string"
+	case bool:
+		return t == "boolean"
+	case float64:
+		return t == "number" || (t == "integer" && vt == math.Trunc(vt))
+	case map[string]interface{}:
+		return t == "object"
+	case []interface{}:
+		return t == "array"
+	default:
+		return false
+	}
+}
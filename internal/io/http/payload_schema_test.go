@@ -0,0 +1,91 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"testing"
+)
+
+func TestPayloadSchema(t *testing.T) {
+	f := false
+	s := &PayloadSchema{
+		Required: []string{"id", "temperature"},
+		Properties: map[string]*PropertySchema{
+			"id":          {Type: "integer"},
+			"temperature": {Type: "number"},
+			"name":        {Type: "string"},
+			"online":      {Type: "boolean"},
+			"tags":        {Type: "array"},
+			"extra":       {Type: "object"},
+			"note":        {Type: "null"},
+		},
+	}
+	if err := s.validateConf(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		m     map[string]interface{}
+		extra *bool
+		err   string
+	}{
+		{
+			m: map[string]interface{}{"id": 1.0, "temperature": 23.5, "name": "a", "online": true, "tags": []interface{}{"x"}, "extra": map[string]interface{}{}, "note": nil, "other": 1.0},
+		}, {
+			m:   map[string]interface{}{"id": 1.0},
+			err: "missing required field temperature",
+		}, {
+			m:   map[string]interface{}{"id": 1.5, "temperature": 23.5},
+			err: "field id must be integer",
+		}, {
+			m:   map[string]interface{}{"id": 1.0, "temperature": "23.5"},
+			err: "field temperature must be number",
+		}, {
+			m:   map[string]interface{}{"id": 1.0, "temperature": 23.5, "online": "true", "name": 1.0},
+			err: "field name must be string",
+		}, {
+			m:     map[string]interface{}{"id": 1.0, "temperature": 23.5, "other": 1.0},
+			extra: &f,
+			err:   "unknown field other",
+		},
+	}
+	for i, tt := range tests {
+		s.AdditionalProperties = tt.extra
+		err := s.Validate(tt.m)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestPayloadSchemaConf(t *testing.T) {
+	tests := []struct {
+		s   *PayloadSchema
+		err string
+	}{
+		{
+			s:   &PayloadSchema{Properties: map[string]*PropertySchema{"a": {Type: "int"}}},
+			err: "invalid type int of schema property a",
+		}, {
+			s:   &PayloadSchema{Properties: map[string]*PropertySchema{"a": nil}},
+			err: "schema property a is empty",
+		},
+	}
+	for i, tt := range tests {
+		err := tt.s.validateConf()
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build influx2 || !core
+
+package influx2
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// precisions are the supported precisions of the timestamp
+var precisions = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// encodeLine encodes a point into the line protocol. The tags are sorted by the key and the empty tags are omitted.
+// The nil fields are omitted and the timestamp is in the precision
+func encodeLine(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time, precision time.Duration) (string, error) {
+	var b strings.Builder
+	b.WriteString(measurementEscaper.Replace(measurement))
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if k != "" && v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(',')
+		b.WriteString(keyEscaper.Replace(k))
+		b.WriteByte('=')
+		b.WriteString(keyEscaper.Replace(tags[k]))
+	}
+	keys = keys[:0]
+	for k, v := range fields {
+		if v != nil {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("no fields in the point of %s", measurement)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(keyEscaper.Replace(k))
+		b.WriteByte('=')
+		v, err := fieldValue(fields[k])
+		if err != nil {
+			return "", fmt.Errorf("invalid field %s: %v", k, err)
+		}
+		b.WriteString(v)
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(ts.UnixNano()/int64(precision), 10))
+	return b.String(), nil
+}
+
+func fieldValue(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			return "", fmt.Errorf("unsupported float %v", t)
+		}
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case float32:
+		return fieldValue(float64(t))
+	case int:
+		return strconv.FormatInt(int64(t), 10) + "i", nil
+	case int8:
+		return strconv.FormatInt(int64(t), 10) + "i", nil
+	case int16:
+		return strconv.FormatInt(int64(t), 10) + "i", nil
+	case int32:
+		return strconv.FormatInt(int64(t), 10) + "i", nil
+	case int64:
+		return strconv.FormatInt(t, 10) + "i", nil
+	case uint:
+		return strconv.FormatUint(uint64(t), 10) + "u", nil
+	case uint8:
+		return strconv.FormatUint(uint64(t), 10) + "u", nil
+	case uint16:
+		return strconv.FormatUint(uint64(t), 10) + "u", nil
+	case uint32:
+		return strconv.FormatUint(uint64(t), 10) + "u", nil
+	case uint64:
+		return strconv.FormatUint(t, 10) + "u", nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case string:
+		return `"` + stringEscaper.Replace(t) + `"`, nil
+	case []byte:
+		return `"` + stringEscaper.Replace(string(t)) + `"`, nil
+	default:
+		// the nested values are written as json strings
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return `"` + stringEscaper.Replace(string(b)) + `"`, nil
+	}
+}
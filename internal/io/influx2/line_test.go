@@ -0,0 +1,87 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build influx2 || !core
+
+package influx2
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncodeLine(t *testing.T) {
+	ts := time.UnixMilli(1680000000123)
+	tests := []struct {
+		name        string
+		measurement string
+		tags        map[string]string
+		fields      map[string]interface{}
+		precision   time.Duration
+		line        string
+		err         string
+	}{
+		{
+			name:        "types",
+			measurement: "m1",
+			tags:        map[string]string{"device": "d1", "area": "a 1"},
+			fields: map[string]interface{}{
+				"temperature": 23.5,
+				"count":       int64(3),
+				"total":       uint32(7),
+				"ok":          true,
+				"msg":         `say "hi"`,
+				"nothing":     nil,
+			},
+			precision: time.Millisecond,
+			line:      `m1,area=a\ 1,device=d1 count=3i,msg="say \"hi\"",ok=true,temperature=23.5,total=7u 1680000000123`,
+		}, {
+			name:        "escape",
+			measurement: "m,1 x",
+			tags:        map[string]string{"k=1": "v,1", "empty": ""},
+			fields:      map[string]interface{}{"f 1": 1.0},
+			precision:   time.Second,
+			line:        `m\,1\ x,k\=1=v\,1 f\ 1=1 1680000000`,
+		}, {
+			name:        "nested",
+			measurement: "m1",
+			fields:      map[string]interface{}{"obj": map[string]interface{}{"a": 1}},
+			precision:   time.Nanosecond,
+			line:        `m1 obj="{\"a\":1}" 1680000000123000000`,
+		}, {
+			name:        "no fields",
+			measurement: "m1",
+			fields:      map[string]interface{}{"a": nil},
+			precision:   time.Millisecond,
+			err:         "no fields in the point of m1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := encodeLine(tt.measurement, tt.tags, tt.fields, ts, tt.precision)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("expect error %s but got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line != tt.line {
+				t.Errorf("expect\n%s\nbut got\n%s", tt.line, line)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build influx2 || !core
+
+package influx2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+type sinkConf struct {
+	// Addr is the address of the InfluxDB server such as http://127.0.0.1:8086
+	Addr        string `json:"addr"`
+	Token       string `json:"token"`
+	Org         string `json:"org"`
+	Bucket      string `json:"bucket"`
+	Measurement string `json:"measurement"`
+	// Tags are the tags of the points, the values can be data templates such as {{.device}}
+	Tags map[string]string `json:"tags"`
+	// TagKey and TagValue are a static tag which is compatible with the influx2 sink plugin
+	TagKey   string `json:"tagKey"`
+	TagValue string `json:"tagValue"`
+	// TagFields are the fields of the data written as the tags instead of the fields
+	TagFields []string `json:"tagFields"`
+	// TsFieldName is the field of the timestamp in milliseconds, the current time is used if not set
+	TsFieldName string `json:"tsFieldName"`
+	// Precision is the precision of the timestamp written, ns, us, ms or s
+	Precision string `json:"precision"`
+	// BatchSize is the number of points to write in a request
+	BatchSize int `json:"batchSize"`
+	// FlushInterval is the max interval in milliseconds to write the buffered points
+	FlushInterval int `json:"flushInterval"`
+	// Timeout is the timeout in milliseconds of the request
+	Timeout      int      `json:"timeout"`
+	DataTemplate string   `json:"dataTemplate"`
+	Fields       []string `json:"fields"`
+	DataField    string   `json:"dataField"`
+}
+
+type sink struct {
+	c         *sinkConf
+	writeUrl  string
+	precision time.Duration
+	cli       *http.Client
+
+	mu sync.Mutex
+	// lines are the buffered points in line protocol
+	lines []string
+}
+
+func (s *sink) Configure(props map[string]interface{}) error {
+	c := &sinkConf{
+		Addr:          "http://127.0.0.1:8086",
+		Precision:     "ms",
+		BatchSize:     1000,
+		FlushInterval: 1000,
+		Timeout:       5000,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Addr == "" {
+		return fmt.Errorf("addr is required")
+	}
+	if c.Org == "" {
+		return fmt.Errorf("org is required")
+	}
+	if c.Bucket == "" {
+		return fmt.Errorf("bucket is required")
+	}
+	if c.Measurement == "" {
+		return fmt.Errorf("measurement is required")
+	}
+	precision, ok := precisions[c.Precision]
+	if !ok {
+		return fmt.Errorf("invalid precision %s, must be ns, us, ms or s", c.Precision)
+	}
+	if c.BatchSize <= 0 {
+		return fmt.Errorf("batchSize must be positive")
+	}
+	if c.FlushInterval <= 0 {
+		return fmt.Errorf("flushInterval must be positive")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	if c.TagKey != "" {
+		if c.Tags == nil {
+			c.Tags = make(map[string]string)
+		}
+		if _, ok := c.Tags[c.TagKey]; !ok {
+			c.Tags[c.TagKey] = c.TagValue
+		}
+	}
+	u, err := url.Parse(c.Addr)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid addr %s", c.Addr)
+	}
+	q := url.Values{}
+	q.Set("org", c.Org)
+	q.Set("bucket", c.Bucket)
+	q.Set("precision", c.Precision)
+	s.writeUrl = strings.TrimSuffix(c.Addr, "/") + "/api/v2/write?" + q.Encode()
+	s.precision = precision
+	s.c = c
+	return nil
+}
+
+func (s *sink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("opening influx2 sink to %s", s.c.Addr)
+	s.cli = &http.Client{Timeout: time.Duration(s.c.Timeout) * time.Millisecond}
+	resp, err := s.cli.Get(strings.TrimSuffix(s.c.Addr, "/") + "/ping")
+	if err != nil {
+		return fmt.Errorf("influx2 sink fails to connect %s: %v", s.c.Addr, err)
+	}
+	_ = resp.Body.Close()
+	go s.run(ctx)
+	return nil
+}
+
+// run flushes the buffered points in the interval so that the points are not delayed too long in low traffic
+func (s *sink) run(ctx api.StreamContext) {
+	ticker := conf.GetTicker(s.c.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Flush(ctx); err != nil {
+				ctx.GetLogger().Errorf("influx2 sink fails to write: %v", err)
+			}
+		}
+	}
+}
+
+func (s *sink) Collect(ctx api.StreamContext, item interface{}) error {
+	var data interface{}
+	if s.c.DataTemplate != "" {
+		b, _, err := ctx.TransformOutput(item)
+		if err != nil {
+			return err
+		}
+		var m interface{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			return fmt.Errorf("fail to decode data %s after applying dataTemplate for error %v", string(b), err)
+		}
+		data = m
+	} else {
+		m, _, err := transform.TransItem(item, s.c.DataField, s.c.Fields)
+		if err != nil {
+			return fmt.Errorf("fail to select fields %v for data %v", s.c.Fields, item)
+		}
+		data = m
+	}
+	var rows []map[string]interface{}
+	switch d := data.(type) {
+	case map[string]interface{}:
+		rows = []map[string]interface{}{d}
+	case []map[string]interface{}:
+		rows = d
+	case []interface{}:
+		for _, el := range d {
+			m, ok := el.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("unsupported type %T", el)
+			}
+			rows = append(rows, m)
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", data)
+	}
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		line, err := s.toLine(ctx, row)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines = append(s.lines, lines...)
+	if len(s.lines) >= s.c.BatchSize {
+		return s.flush(ctx)
+	}
+	return nil
+}
+
+// toLine converts the data into a point. The tag fields and the timestamp field are not written as the fields
+func (s *sink) toLine(ctx api.StreamContext, data map[string]interface{}) (string, error) {
+	tags := make(map[string]string, len(s.c.Tags)+len(s.c.TagFields))
+	for k, v := range s.c.Tags {
+		tv, err := ctx.ParseTemplate(v, data)
+		if err != nil {
+			return "", fmt.Errorf("parse template for tag %s error: %v", k, err)
+		}
+		tags[k] = tv
+	}
+	fields := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		fields[k] = v
+	}
+	for _, f := range s.c.TagFields {
+		if v, ok := fields[f]; ok {
+			delete(fields, f)
+			if v != nil {
+				tags[f] = cast.ToStringAlways(v)
+			}
+		}
+	}
+	ts := conf.GetNow()
+	if s.c.TsFieldName != "" {
+		v, ok := fields[s.c.TsFieldName]
+		if !ok {
+			return "", fmt.Errorf("timestamp field %s not found in data %v", s.c.TsFieldName, data)
+		}
+		t, err := cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return "", fmt.Errorf("timestamp field %s must be an int of milliseconds but got %v", s.c.TsFieldName, v)
+		}
+		ts = time.UnixMilli(t)
+		delete(fields, s.c.TsFieldName)
+	}
+	return encodeLine(s.c.Measurement, tags, fields, ts, s.precision)
+}
+
+// Flush writes the buffered points. It is called before the checkpoint so that the points before the checkpoint
+// are all written
+func (s *sink) Flush(ctx api.StreamContext) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush(ctx)
+}
+
+func (s *sink) flush(ctx api.StreamContext) error {
+	if len(s.lines) == 0 {
+		return nil
+	}
+	for len(s.lines) > 0 {
+		n := len(s.lines)
+		if n > s.c.BatchSize {
+			n = s.c.BatchSize
+		}
+		if err := s.write(s.lines[:n]); err != nil {
+			// keep the points to retry and drop the oldest if too many points are buffered
+			if limit := s.c.BatchSize * 10; len(s.lines) > limit {
+				ctx.GetLogger().Warnf("influx2 sink drops %d points for too many points are buffered", len(s.lines)-limit)
+				s.lines = s.lines[len(s.lines)-limit:]
+			}
+			return err
+		}
+		ctx.GetLogger().Debugf("influx2 sink writes %d points", n)
+		s.lines = s.lines[n:]
+	}
+	s.lines = nil
+	return nil
+}
+
+func (s *sink) write(lines []string) error {
+	req, err := http.NewRequest(http.MethodPost, s.writeUrl, bytes.NewBufferString(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.c.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.c.Token)
+	}
+	resp, err := s.cli.Do(req)
+	if err != nil {
+		return fmt.Errorf("write to %s error: %v", s.c.Addr, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("write to %s error with status %d: %s", s.c.Addr, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (s *sink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing influx2 sink")
+	if s.cli == nil {
+		return nil
+	}
+	return s.Flush(ctx)
+}
+
+func GetSink() api.Sink {
+	return &sink{}
+}
@@ -0,0 +1,200 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build influx2 || !core
+
+package influx2
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"bucket": "b1", "measurement": "m1"},
+			err:   "org is required",
+		}, {
+			props: map[string]interface{}{"org": "o1", "measurement": "m1"},
+			err:   "bucket is required",
+		}, {
+			props: map[string]interface{}{"org": "o1", "bucket": "b1"},
+			err:   "measurement is required",
+		}, {
+			props: map[string]interface{}{"org": "o1", "bucket": "b1", "measurement": "m1", "precision": "m"},
+			err:   "invalid precision m, must be ns, us, ms or s",
+		}, {
+			props: map[string]interface{}{"org": "o1", "bucket": "b1", "measurement": "m1", "batchSize": 0},
+			err:   "batchSize must be positive",
+		}, {
+			props: map[string]interface{}{"org": "o1", "bucket": "b1", "measurement": "m1", "addr": "127.0.0.1"},
+			err:   "invalid addr 127.0.0.1",
+		}, {
+			props: map[string]interface{}{"org": "o1", "bucket": "b1", "measurement": "m1", "tagKey": "t", "tagValue": "v"},
+		},
+	}
+	for i, tt := range tests {
+		err := GetSink().Configure(tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+type mockServer struct {
+	sync.Mutex
+	status int
+	urls   []string
+	auths  []string
+	bodies []string
+}
+
+func (m *mockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/ping" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	b, _ := io.ReadAll(r.Body)
+	m.Lock()
+	defer m.Unlock()
+	if m.status != 0 {
+		w.WriteHeader(m.status)
+		_, _ = w.Write([]byte("bucket not found"))
+		return
+	}
+	m.urls = append(m.urls, r.URL.String())
+	m.auths = append(m.auths, r.Header.Get("Authorization"))
+	m.bodies = append(m.bodies, string(b))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestCollect(t *testing.T) {
+	conf.InitClock()
+	ms := &mockServer{}
+	server := httptest.NewServer(ms)
+	defer server.Close()
+	s := GetSink()
+	err := s.Configure(map[string]interface{}{
+		"addr":          server.URL,
+		"token":         "t1",
+		"org":           "o1",
+		"bucket":        "b1",
+		"measurement":   "m1",
+		"tags":          map[string]interface{}{"device": "{{.device}}", "site": "s1"},
+		"tagFields":     []interface{}{"area"},
+		"tsFieldName":   "ts",
+		"batchSize":     2,
+		"flushInterval": 100000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := mockContext.NewMockContext("rule1", "op1").WithCancel()
+	defer cancel()
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	data := []map[string]interface{}{
+		{"device": "d1", "area": "a1", "temperature": 20.5, "ts": int64(1680000000000)},
+		{"device": "d2", "area": "a2", "temperature": 21.5, "ts": int64(1680000001000)},
+		{"device": "d3", "temperature": 22.5, "ts": int64(1680000002000)},
+	}
+	for _, d := range data {
+		if err := s.Collect(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ms.Lock()
+	if len(ms.bodies) != 1 {
+		t.Errorf("expect 1 write before flush but got %d", len(ms.bodies))
+	}
+	ms.Unlock()
+	if err := s.(*sink).Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{
+		"m1,area=a1,device=d1,site=s1 device=\"d1\",temperature=20.5 1680000000000\nm1,area=a2,device=d2,site=s1 device=\"d2\",temperature=21.5 1680000001000",
+		"m1,device=d3,site=s1 device=\"d3\",temperature=22.5 1680000002000",
+	}
+	ms.Lock()
+	defer ms.Unlock()
+	if !reflect.DeepEqual(ms.bodies, exp) {
+		t.Errorf("expect %v but got %v", exp, ms.bodies)
+	}
+	if ms.urls[0] != "/api/v2/write?bucket=b1&org=o1&precision=ms" {
+		t.Errorf("unexpected url %s", ms.urls[0])
+	}
+	if ms.auths[0] != "Token t1" {
+		t.Errorf("unexpected authorization %s", ms.auths[0])
+	}
+}
+
+func TestFlushError(t *testing.T) {
+	conf.InitClock()
+	ms := &mockServer{status: http.StatusNotFound}
+	server := httptest.NewServer(ms)
+	defer server.Close()
+	s := GetSink()
+	err := s.Configure(map[string]interface{}{
+		"addr":        server.URL,
+		"org":         "o1",
+		"bucket":      "b1",
+		"measurement": "m1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := mockContext.NewMockContext("rule1", "op1").WithCancel()
+	defer cancel()
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Collect(ctx, map[string]interface{}{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	err = s.(*sink).Flush(ctx)
+	expErr := "write to " + server.URL + " error with status 404: bucket not found"
+	if err == nil || err.Error() != expErr {
+		t.Errorf("expect error %s but got %v", expErr, err)
+	}
+	// the points are kept to retry
+	ms.Lock()
+	ms.status = 0
+	ms.Unlock()
+	if err := s.(*sink).Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ms.Lock()
+	defer ms.Unlock()
+	if len(ms.bodies) != 1 {
+		t.Errorf("expect the point is written after retry but got %v", ms.bodies)
+	}
+}
@@ -110,6 +110,8 @@ func RemovePub(topic string) {
 	}
 }
 
+// Produce broadcasts the data to all the subscribers without copying. The data is shared by the subscribers, so it must
+// not be modified after producing and the subscribers must copy it before modifying
 func Produce(ctx api.StreamContext, topic string, data map[string]interface{}) {
 	doProduce(ctx, topic, api.NewDefaultSourceTupleWithTime(data, map[string]interface{}{"topic": topic}, conf.GetNow()))
 }
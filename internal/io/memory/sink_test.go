@@ -86,3 +86,34 @@ func TestUpdate(t *testing.T) {
 		t.Errorf("expect %v but got %v", expects, actual)
 	}
 }
+
+func TestZeroCopy(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "testZeroCopy")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	ms := GetSink()
+	err := ms.Configure(map[string]interface{}{"topic": "testzerocopy"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = ms.Open(ctx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	c1 := pubsub.CreateSub("testzerocopy", nil, "testSource1", 100)
+	c2 := pubsub.CreateSub("testzerocopy", nil, "testSource2", 100)
+	data := map[string]interface{}{"id": "1", "name": "test1"}
+	err = ms.Collect(ctx, data)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// all subscribers share the same map without encoding
+	for i, c := range []chan api.SourceTuple{c1, c2} {
+		d := <-c
+		if reflect.ValueOf(d.Message()).Pointer() != reflect.ValueOf(data).Pointer() {
+			t.Errorf("%d: expect the same map %v but got a copy %v", i, data, d.Message())
+		}
+	}
+}
@@ -63,6 +63,19 @@ func (s *source) Configure(datasource string, props map[string]interface{}) erro
 	return nil
 }
 
+// MatchTopic returns whether the topic published by a memory sink is subscribed by the source topic, which may
+// contain wildcards
+func MatchTopic(wildcard, topic string) bool {
+	if !strings.ContainsAny(wildcard, "+#") {
+		return wildcard == topic
+	}
+	r, err := getRegexp(wildcard)
+	if err != nil {
+		return false
+	}
+	return r.MatchString(topic)
+}
+
 func getRegexp(topic string) (*regexp.Regexp, error) {
 	if len(topic) == 0 {
 		return nil, fmt.Errorf("invalid empty topic")
@@ -16,9 +16,12 @@ package mqtt
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/lf-edge/ekuiper/internal/compressor"
 	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+	mqttClient "github.com/lf-edge/ekuiper/internal/topo/connection/clients/mqtt"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
@@ -31,10 +34,18 @@ type AdConf struct {
 	Qos         byte   `json:"qos"`
 	Retained    bool   `json:"retained"`
 	Compression string `json:"compression"`
+	// MessageExpiry is the lifetime of the message in seconds in MQTT 5
+	MessageExpiry uint32 `json:"messageExpiry"`
+	// UserProperties are sent with the message in MQTT 5, the values can be templates
+	UserProperties map[string]string `json:"userProperties"`
 }
 
 type MQTTSink struct {
-	adconf     *AdConf
+	adconf *AdConf
+	// the data templates of qos and retained to get the values from the result
+	qosTemplate      string
+	retainedTemplate string
+
 	config     map[string]interface{}
 	cli        api.MessageClient
 	compressor message.Compressor
@@ -51,7 +62,36 @@ func (ms *MQTTSink) hasKeys(str []string, ps map[string]interface{}) bool {
 
 func (ms *MQTTSink) Configure(ps map[string]interface{}) error {
 	adconf := &AdConf{}
-	cast.MapToStruct(ps, adconf)
+	props := make(map[string]interface{}, len(ps))
+	for k, v := range ps {
+		props[k] = v
+	}
+	// qos and retained could be data templates
+	if v, ok := ps["qos"].(string); ok {
+		delete(props, "qos")
+		if isTemplate(v) {
+			ms.qosTemplate = v
+		} else {
+			qos, err := parseQos(v)
+			if err != nil {
+				return err
+			}
+			adconf.Qos = qos
+		}
+	}
+	if v, ok := ps["retained"].(string); ok {
+		delete(props, "retained")
+		if isTemplate(v) {
+			ms.retainedTemplate = v
+		} else {
+			retained, err := parseRetained(v)
+			if err != nil {
+				return err
+			}
+			adconf.Retained = retained
+		}
+	}
+	cast.MapToStruct(props, adconf)
 
 	if adconf.Tpc == "" {
 		return fmt.Errorf("mqtt sink is missing property topic")
@@ -71,6 +111,11 @@ func (ms *MQTTSink) Configure(ps map[string]interface{}) error {
 	return nil
 }
 
+// CompressBySelf The mqtt sink compresses the payload with the compression property by itself
+func (ms *MQTTSink) CompressBySelf() bool {
+	return true
+}
+
 func (ms *MQTTSink) Open(ctx api.StreamContext) error {
 	log := ctx.GetLogger()
 	cli, err := clients.GetClient("mqtt", ms.config)
@@ -102,9 +147,46 @@ func (ms *MQTTSink) Collect(ctx api.StreamContext, item interface{}) error {
 		return err
 	}
 
+	qos, retained := ms.adconf.Qos, ms.adconf.Retained
+	if ms.qosTemplate != "" {
+		v, err := ctx.ParseTemplate(ms.qosTemplate, item)
+		if err != nil {
+			return err
+		}
+		qos, err = parseQos(v)
+		if err != nil {
+			return err
+		}
+	}
+	if ms.retainedTemplate != "" {
+		v, err := ctx.ParseTemplate(ms.retainedTemplate, item)
+		if err != nil {
+			return err
+		}
+		retained, err = parseRetained(v)
+		if err != nil {
+			return err
+		}
+	}
+
 	para := map[string]interface{}{
-		"qos":      ms.adconf.Qos,
-		"retained": ms.adconf.Retained,
+		"qos":      qos,
+		"retained": retained,
+	}
+	if ms.adconf.MessageExpiry > 0 || len(ms.adconf.UserProperties) > 0 {
+		props := &mqttClient.PublishProperties{
+			MessageExpiry: ms.adconf.MessageExpiry,
+		}
+		if len(ms.adconf.UserProperties) > 0 {
+			props.UserProperties = make(map[string]string, len(ms.adconf.UserProperties))
+			for k, v := range ms.adconf.UserProperties {
+				props.UserProperties[k], err = ctx.ParseTemplate(v, item)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		para["properties"] = props
 	}
 
 	if err := ms.cli.Publish(ctx, tpc, jsonBytes, para); err != nil {
@@ -113,6 +195,26 @@ func (ms *MQTTSink) Collect(ctx api.StreamContext, item interface{}) error {
 	return nil
 }
 
+func isTemplate(v string) bool {
+	return strings.Contains(v, "{{")
+}
+
+func parseQos(v string) (byte, error) {
+	qos, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || qos < 0 || qos > 2 {
+		return 0, fmt.Errorf("invalid qos value %v, the value could be only int 0 or 1 or 2", v)
+	}
+	return byte(qos), nil
+}
+
+func parseRetained(v string) (bool, error) {
+	retained, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return false, fmt.Errorf("invalid retained value %v, the value could be only true or false", v)
+	}
+	return retained, nil
+}
+
 func (ms *MQTTSink) Close(ctx api.StreamContext) error {
 	logger := ctx.GetLogger()
 	logger.Infof("Closing mqtt sink")
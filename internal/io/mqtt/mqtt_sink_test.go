@@ -95,3 +95,67 @@ func TestSinkConfigure(t *testing.T) {
 		})
 	}
 }
+
+func TestSinkDynamicProps(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    map[string]interface{}
+		err      string
+		qos      byte
+		retained bool
+		qosTpl   string
+		retTpl   string
+	}{
+		{
+			name: "templates",
+			input: map[string]interface{}{
+				"topic":    "{{.topic}}",
+				"qos":      "{{.qos}}",
+				"retained": "{{.retained}}",
+			},
+			qosTpl: "{{.qos}}",
+			retTpl: "{{.retained}}",
+		}, {
+			name: "static strings",
+			input: map[string]interface{}{
+				"topic":    "test",
+				"qos":      "2",
+				"retained": "true",
+			},
+			qos:      2,
+			retained: true,
+		}, {
+			name: "invalid qos",
+			input: map[string]interface{}{
+				"topic": "test",
+				"qos":   "3",
+			},
+			err: "invalid qos value 3, the value could be only int 0 or 1 or 2",
+		}, {
+			name: "invalid retained",
+			input: map[string]interface{}{
+				"topic":    "test",
+				"retained": "yes",
+			},
+			err: "invalid retained value yes, the value could be only true or false",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := &MQTTSink{}
+			err := ms.Configure(tt.input)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("expect error %s but got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ms.adconf.Qos != tt.qos || ms.adconf.Retained != tt.retained || ms.qosTemplate != tt.qosTpl || ms.retainedTemplate != tt.retTpl {
+				t.Errorf("expect qos %d retained %v templates %s %s but got %d %v %s %s", tt.qos, tt.retained, tt.qosTpl, tt.retTpl, ms.adconf.Qos, ms.adconf.Retained, ms.qosTemplate, ms.retainedTemplate)
+			}
+		})
+	}
+}
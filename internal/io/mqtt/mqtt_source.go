@@ -18,12 +18,14 @@ import (
 	"fmt"
 	"path"
 	"strconv"
+	"strings"
 
 	pahoMqtt "github.com/eclipse/paho.mqtt.golang"
 
 	"github.com/lf-edge/ekuiper/internal/compressor"
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+	mqttClient "github.com/lf-edge/ekuiper/internal/topo/connection/clients/mqtt"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
@@ -35,6 +37,8 @@ type MQTTSource struct {
 	format string
 	tpc    string
 	buflen int
+	// bpTimeout is the max milliseconds to pause the subscription when the rule enables the back pressure
+	bpTimeout int
 
 	config map[string]interface{}
 	model  modelVersion
@@ -50,6 +54,10 @@ type MQTTConfig struct {
 	KubeedgeModelFile string `json:"kubeedgeModelFile"`
 	KubeedgeVersion   string `json:"kubeedgeVersion"`
 	Decompression     string `json:"decompression"`
+	// ShareGroup subscribes the topic as a shared subscription of the group to balance the messages among the subscribers
+	ShareGroup string `json:"shareGroup"`
+	// BackPressureTimeout is the max milliseconds to wait for the rule with back pressure to consume a message
+	BackPressureTimeout int `json:"backPressureTimeout"`
 }
 
 func (ms *MQTTSource) WithSchema(_ string) *MQTTSource {
@@ -58,7 +66,8 @@ func (ms *MQTTSource) WithSchema(_ string) *MQTTSource {
 
 func (ms *MQTTSource) Configure(topic string, props map[string]interface{}) error {
 	cfg := &MQTTConfig{
-		BufferLen: 1024,
+		BufferLen:           1024,
+		BackPressureTimeout: 5000,
 	}
 	err := cast.MapToStruct(props, cfg)
 	if err != nil {
@@ -68,6 +77,13 @@ func (ms *MQTTSource) Configure(topic string, props map[string]interface{}) erro
 		cfg.BufferLen = 1024
 	}
 	ms.buflen = cfg.BufferLen
+	ms.bpTimeout = cfg.BackPressureTimeout
+	if cfg.ShareGroup != "" {
+		if strings.ContainsAny(cfg.ShareGroup, "/+#") {
+			return fmt.Errorf("invalid shareGroup %s, must not contain /, + or #", cfg.ShareGroup)
+		}
+		topic = "$share/" + cfg.ShareGroup + "/" + topic
+	}
 	ms.tpc = topic
 	ms.format = cfg.Format
 	ms.qos = cfg.Qos
@@ -115,7 +131,8 @@ func subscribe(ms *MQTTSource, ctx api.StreamContext, consumer chan<- api.Source
 	err := make(chan error, len(topics))
 
 	para := map[string]interface{}{
-		"qos": byte(ms.qos),
+		"qos":                 byte(ms.qos),
+		"backPressureTimeout": ms.bpTimeout,
 	}
 	if e := ms.cli.Subscribe(ctx, topics, err, para); e != nil {
 		log.Errorf("Failed to subscribe to mqtt topic %s, error %s\n", ms.tpc, e.Error())
@@ -170,7 +187,8 @@ func getTuples(ctx api.StreamContext, ms *MQTTSource, env interface{}) []api.Sou
 		if err != nil {
 			return []api.SourceTuple{
 				&xsql.ErrorSourceTuple{
-					Error: fmt.Errorf("can not decompress mqtt message %v.", err),
+					Error:   fmt.Errorf("can not decompress mqtt message %v.", err),
+					Payload: msg.Payload(),
 				},
 			}
 		}
@@ -180,13 +198,20 @@ func getTuples(ctx api.StreamContext, ms *MQTTSource, env interface{}) []api.Sou
 	if e != nil {
 		return []api.SourceTuple{
 			&xsql.ErrorSourceTuple{
-				Error: fmt.Errorf("Invalid data format, cannot decode %s with error %s", string(msg.Payload()), e),
+				Error:   fmt.Errorf("Invalid data format, cannot decode %s with error %s", string(msg.Payload()), e),
+				Payload: payload,
 			},
 		}
 	}
 	meta := make(map[string]interface{})
 	meta["topic"] = msg.Topic()
 	meta["messageid"] = strconv.Itoa(int(msg.MessageID()))
+	// the properties of MQTT 5 such as the user properties
+	if pm, ok := msg.(mqttClient.PropertiesMessage); ok {
+		for k, v := range pm.Properties() {
+			meta[k] = v
+		}
+	}
 
 	tuples := make([]api.SourceTuple, 0, len(results))
 	for _, result := range results {
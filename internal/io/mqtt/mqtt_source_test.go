@@ -101,3 +101,42 @@ func (MockMessage) MessageID() uint16 {
 func (MockMessage) Ack() {
 	panic("function not expected to be invoked")
 }
+
+type MockMessage5 struct {
+	MockMessage
+	props map[string]interface{}
+}
+
+func (mm MockMessage5) Properties() map[string]interface{} {
+	return mm.props
+}
+
+func TestGetTupleWithProperties(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestGetTupleWithProperties")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	cv, _ := converter.GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+	ctx = context.WithValue(ctx, context.DecodeKey, cv)
+	msg := MockMessage5{
+		MockMessage: MockMessage{
+			payload: []byte(`{"key": "value"}`),
+			topic:   "test/topic",
+		},
+		props: map[string]interface{}{
+			"messageExpiry":  int64(60),
+			"userProperties": map[string]interface{}{"k1": "v1"},
+		},
+	}
+	results := getTuples(ctx, &MQTTSource{}, msg)
+	if len(results) != 1 {
+		t.Fatalf("expect 1 tuple but got %d", len(results))
+	}
+	exp := map[string]interface{}{
+		"topic":          "test/topic",
+		"messageid":      "1",
+		"messageExpiry":  int64(60),
+		"userProperties": map[string]interface{}{"k1": "v1"},
+	}
+	if !reflect.DeepEqual(results[0].Meta(), exp) {
+		t.Errorf("expect meta %v but got %v", exp, results[0].Meta())
+	}
+}
@@ -52,6 +52,32 @@ var (
 	sendTimeout   = 100
 )
 
+// getUrl returns the url of the neuron instance. The instance can be selected by name with the connection selector
+// such as neuron.instance1, which refers to the url defined in connections/connection.yaml
+func getUrl(url string, selector string) (string, error) {
+	if selector == "" {
+		return url, nil
+	}
+	sel := &conf.ConSelector{
+		ConnSelectorStr: selector,
+	}
+	if err := sel.Init(); err != nil {
+		return "", err
+	}
+	if sel.Type != "neuron" {
+		return "", fmt.Errorf("connection selector %s is not a neuron connection", selector)
+	}
+	props, err := sel.ReadCfgFromYaml()
+	if err != nil {
+		return "", err
+	}
+	u, ok := props["url"].(string)
+	if !ok || u == "" {
+		return "", fmt.Errorf("url is not set in the connection %s", selector)
+	}
+	return u, nil
+}
+
 // createOrGetNeuronConnection creates a new neuron connection or returns an existing one
 // This is the entry function for creating a neuron connection singleton
 // The context is from a rule, but the singleton will server for multiple rules
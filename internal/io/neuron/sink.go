@@ -37,6 +37,8 @@ type c struct {
 	// If sent with the raw converted string or let us range over the result map
 	Raw bool   `json:"raw"`
 	Url string `json:"url"`
+	// ConnectionSelector selects the neuron instance by name in connections/connection.yaml
+	ConnectionSelector string `json:"connectionSelector"`
 }
 
 type neuronTemplate struct {
@@ -55,6 +57,10 @@ func (s *sink) Configure(props map[string]interface{}) error {
 	if err != nil {
 		return err
 	}
+	cc.Url, err = getUrl(cc.Url, cc.ConnectionSelector)
+	if err != nil {
+		return err
+	}
 	if !cc.Raw {
 		if cc.NodeName == "" {
 			return fmt.Errorf("node name is required if raw is not set")
@@ -18,18 +18,32 @@ import (
 	"fmt"
 
 	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 	"github.com/lf-edge/ekuiper/pkg/infra"
 )
 
 type sc struct {
-	Url          string `json:"url,omitempty"`
-	BufferLength int    `json:"bufferLength,omitempty"`
+	Url                string `json:"url,omitempty"`
+	BufferLength       int    `json:"bufferLength,omitempty"`
+	ConnectionSelector string `json:"connectionSelector,omitempty"`
+	// Groups are the node groups to subscribe, all the groups are subscribed if not set
+	Groups []*groupConf `json:"groups,omitempty"`
+}
+
+type groupConf struct {
+	Node string `json:"node"`
+	// Group is the group name in the node, all the groups of the node are subscribed if not set
+	Group string `json:"group"`
+	// Tags are the tags to keep in the group, all the tags are kept if not set
+	Tags []string `json:"tags"`
 }
 
 type source struct {
 	c *sc
+	// groups is the index of the subscribed groups by node name and group name
+	groups map[string]map[string]map[string]struct{}
 }
 
 func (s *source) Configure(_ string, props map[string]interface{}) error {
@@ -41,6 +55,29 @@ func (s *source) Configure(_ string, props map[string]interface{}) error {
 	if err != nil {
 		return err
 	}
+	cc.Url, err = getUrl(cc.Url, cc.ConnectionSelector)
+	if err != nil {
+		return err
+	}
+	if len(cc.Groups) > 0 {
+		s.groups = make(map[string]map[string]map[string]struct{})
+		for _, g := range cc.Groups {
+			if g.Node == "" {
+				return fmt.Errorf("node is required in groups")
+			}
+			if _, ok := s.groups[g.Node]; !ok {
+				s.groups[g.Node] = make(map[string]map[string]struct{})
+			}
+			var tags map[string]struct{}
+			if len(g.Tags) > 0 {
+				tags = make(map[string]struct{}, len(g.Tags))
+				for _, tag := range g.Tags {
+					tags[tag] = struct{}{}
+				}
+			}
+			s.groups[g.Node][g.Group] = tags
+		}
+	}
 	s.c = cc
 	return nil
 }
@@ -60,13 +97,75 @@ func (s *source) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, er
 			if !opened {
 				return
 			}
-			consumer <- v
+			if t, ok := s.filter(v); ok {
+				consumer <- t
+			}
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// filter drops the events of the unsubscribed groups and the unsubscribed tags, and sets the quality code of each tag
+// in the meta. The quality code is 0 for the tags in values, otherwise it is the error code of neuron
+func (s *source) filter(v api.SourceTuple) (api.SourceTuple, bool) {
+	if _, ok := v.(*xsql.ErrorSourceTuple); ok {
+		return v, true
+	}
+	msg := v.Message()
+	var tags map[string]struct{}
+	if s.groups != nil {
+		node, _ := msg["node_name"].(string)
+		group, _ := msg["group_name"].(string)
+		groups, ok := s.groups[node]
+		if !ok {
+			return nil, false
+		}
+		if tags, ok = groups[group]; !ok {
+			if tags, ok = groups[""]; !ok {
+				return nil, false
+			}
+		}
+	}
+	quality := make(map[string]interface{})
+	values, _ := msg["values"].(map[string]interface{})
+	errors, _ := msg["errors"].(map[string]interface{})
+	if tags != nil {
+		// the message is shared by all the sources of the connection, so copy it before filtering
+		fv := make(map[string]interface{}, len(tags))
+		fe := make(map[string]interface{})
+		for tag := range tags {
+			if val, ok := values[tag]; ok {
+				fv[tag] = val
+			} else if code, ok := errors[tag]; ok {
+				fe[tag] = code
+			}
+		}
+		if len(fv) == 0 && len(fe) == 0 {
+			return nil, false
+		}
+		nm := make(map[string]interface{}, len(msg))
+		for k, val := range msg {
+			nm[k] = val
+		}
+		nm["values"] = fv
+		nm["errors"] = fe
+		msg, values, errors = nm, fv, fe
+	}
+	for tag := range values {
+		quality[tag] = 0
+	}
+	for tag, code := range errors {
+		quality[tag] = code
+	}
+	meta := make(map[string]interface{}, len(v.Meta())+1)
+	for k, val := range v.Meta() {
+		meta[k] = val
+	}
+	meta["quality"] = quality
+	return api.NewDefaultSourceTupleWithTime(msg, meta, v.Timestamp()), true
+}
+
 func (s *source) Close(ctx api.StreamContext) error {
 	ctx.GetLogger().Infof("closing neuron source")
 	return nil
@@ -33,9 +33,9 @@ import (
 func TestRun(t *testing.T) {
 	mc := conf.Clock.(*clock.Mock)
 	exp := []api.SourceTuple{
-		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"group_name": "group1", "timestamp": 1646125996000.0, "node_name": "node1", "values": map[string]interface{}{"tag_name1": 11.22, "tag_name2": "yellow"}, "errors": map[string]interface{}{"tag_name3": 122.0}}, map[string]interface{}{"topic": "$$neuron_ipc:///tmp/neuron-ekuiper.ipc"}, mc.Now()),
-		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"group_name": "group1", "timestamp": 1646125996000.0, "node_name": "node1", "values": map[string]interface{}{"tag_name1": 11.22, "tag_name2": "green", "tag_name3": 60.0}, "errors": map[string]interface{}{}}, map[string]interface{}{"topic": "$$neuron_ipc:///tmp/neuron-ekuiper.ipc"}, mc.Now()),
-		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"group_name": "group1", "timestamp": 1646125996000.0, "node_name": "node1", "values": map[string]interface{}{"tag_name1": 15.4, "tag_name2": "green", "tag_name3": 70.0}, "errors": map[string]interface{}{}}, map[string]interface{}{"topic": "$$neuron_ipc:///tmp/neuron-ekuiper.ipc"}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"group_name": "group1", "timestamp": 1646125996000.0, "node_name": "node1", "values": map[string]interface{}{"tag_name1": 11.22, "tag_name2": "yellow"}, "errors": map[string]interface{}{"tag_name3": 122.0}}, map[string]interface{}{"topic": "$$neuron_ipc:///tmp/neuron-ekuiper.ipc", "quality": map[string]interface{}{"tag_name1": 0, "tag_name2": 0, "tag_name3": 122.0}}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"group_name": "group1", "timestamp": 1646125996000.0, "node_name": "node1", "values": map[string]interface{}{"tag_name1": 11.22, "tag_name2": "green", "tag_name3": 60.0}, "errors": map[string]interface{}{}}, map[string]interface{}{"topic": "$$neuron_ipc:///tmp/neuron-ekuiper.ipc", "quality": map[string]interface{}{"tag_name1": 0, "tag_name2": 0, "tag_name3": 0}}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"group_name": "group1", "timestamp": 1646125996000.0, "node_name": "node1", "values": map[string]interface{}{"tag_name1": 15.4, "tag_name2": "green", "tag_name3": 70.0}, "errors": map[string]interface{}{}}, map[string]interface{}{"topic": "$$neuron_ipc:///tmp/neuron-ekuiper.ipc", "quality": map[string]interface{}{"tag_name1": 0, "tag_name2": 0, "tag_name3": 0}}, mc.Now()),
 	}
 	s := GetSource()
 	err := s.Configure("new", nil)
@@ -75,3 +75,95 @@ func connectFailTest(t *testing.T) {
 	server.Close()
 	time.Sleep(1 * time.Second)
 }
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		name  string
+		props map[string]interface{}
+		url   string
+		err   string
+	}{
+		{
+			name:  "default",
+			props: map[string]interface{}{},
+			url:   DefaultNeuronUrl,
+		}, {
+			name:  "selector",
+			props: map[string]interface{}{"connectionSelector": "neuron.remote"},
+			url:   "tcp://127.0.0.1:7081",
+		}, {
+			name:  "selector not found",
+			props: map[string]interface{}{"connectionSelector": "neuron.none"},
+			err:   "not found connection Type and Selector:  neuron.none",
+		}, {
+			name:  "selector of other type",
+			props: map[string]interface{}{"connectionSelector": "mqtt.localConnection"},
+			err:   "connection selector mqtt.localConnection is not a neuron connection",
+		}, {
+			name:  "group without node",
+			props: map[string]interface{}{"groups": []map[string]interface{}{{"group": "group1"}}},
+			err:   "node is required in groups",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := GetSource()
+			err := s.Configure("new", tt.props)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("expect error %s but got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+				return
+			}
+			if s.c.Url != tt.url {
+				t.Errorf("expect url %s but got %s", tt.url, s.c.Url)
+			}
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	mc := conf.Clock.(*clock.Mock)
+	meta := map[string]interface{}{"topic": "$$neuron_" + DefaultNeuronUrl}
+	input := []map[string]interface{}{
+		{"node_name": "node1", "group_name": "group1", "values": map[string]interface{}{"tag1": 1.0, "tag2": 2.0}, "errors": map[string]interface{}{"tag3": 2014.0}},
+		{"node_name": "node1", "group_name": "group2", "values": map[string]interface{}{"tag1": 1.0}, "errors": map[string]interface{}{}},
+		{"node_name": "node2", "group_name": "group1", "values": map[string]interface{}{"tag4": 4.0}, "errors": map[string]interface{}{"tag5": 3002.0}},
+		{"node_name": "node3", "group_name": "group1", "values": map[string]interface{}{"tag1": 1.0}, "errors": map[string]interface{}{}},
+	}
+	s := GetSource()
+	err := s.Configure("new", map[string]interface{}{
+		"groups": []map[string]interface{}{
+			{"node": "node1", "group": "group1", "tags": []string{"tag2", "tag3"}},
+			{"node": "node2"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []api.SourceTuple{
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"node_name": "node1", "group_name": "group1", "values": map[string]interface{}{"tag2": 2.0}, "errors": map[string]interface{}{"tag3": 2014.0}}, map[string]interface{}{"topic": "$$neuron_" + DefaultNeuronUrl, "quality": map[string]interface{}{"tag2": 0, "tag3": 2014.0}}, mc.Now()),
+		api.NewDefaultSourceTupleWithTime(map[string]interface{}{"node_name": "node2", "group_name": "group1", "values": map[string]interface{}{"tag4": 4.0}, "errors": map[string]interface{}{"tag5": 3002.0}}, map[string]interface{}{"topic": "$$neuron_" + DefaultNeuronUrl, "quality": map[string]interface{}{"tag4": 0, "tag5": 3002.0}}, mc.Now()),
+		&xsql.ErrorSourceTuple{Error: fmt.Errorf("neuron connection detached")},
+	}
+	var result []api.SourceTuple
+	for _, m := range input {
+		if r, ok := s.filter(api.NewDefaultSourceTupleWithTime(m, meta, mc.Now())); ok {
+			result = append(result, r)
+		}
+	}
+	if r, ok := s.filter(&xsql.ErrorSourceTuple{Error: fmt.Errorf("neuron connection detached")}); ok {
+		result = append(result, r)
+	}
+	if !reflect.DeepEqual(exp, result) {
+		t.Errorf("result mismatch:\n\nexp=%v\n\ngot=%v\n\n", exp, result)
+	}
+	// the shared message must not be changed
+	if len(input[0]["values"].(map[string]interface{})) != 2 {
+		t.Errorf("the input message is changed: %v", input[0])
+	}
+}
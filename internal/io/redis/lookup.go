@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 
@@ -29,12 +30,19 @@ import (
 	"github.com/lf-edge/ekuiper/pkg/cast"
 )
 
+const (
+	dataTypeString = "string"
+	dataTypeList   = "list"
+	dataTypeHash   = "hash"
+)
+
 type conf struct {
 	// host:port address.
 	Addr     string `json:"addr,omitempty"`
 	Username string `json:"username,omitempty"`
 	// Optional password. Must match the password specified in the
 	Password string `json:"password,omitempty"`
+	// DataType is the type of the values, string and list values are json, hash values are the fields
 	DataType string `json:"dataType,omitempty"`
 }
 
@@ -62,8 +70,8 @@ func (s *lookupSource) Configure(datasource string, props map[string]interface{}
 	if cfg.Addr == "" {
 		return errors.New("redis addr is null")
 	}
-	if cfg.DataType != "string" && cfg.DataType != "list" {
-		return errors.New("redis dataType must be string or list")
+	if cfg.DataType != dataTypeString && cfg.DataType != dataTypeList && cfg.DataType != dataTypeHash {
+		return errors.New("redis dataType must be string, list or hash")
 	}
 	s.c = cfg
 	s.cli = redis.NewClient(&redis.Options{
@@ -87,9 +95,53 @@ func (s *lookupSource) Lookup(ctx api.StreamContext, _ []string, keys []string,
 	if len(keys) != 1 {
 		return nil, fmt.Errorf("redis lookup only support one key, but got %v", keys)
 	}
-	v := fmt.Sprintf("%v", values[0])
-	if s.c.DataType == "string" {
-		res, err := s.cli.Get(ctx, v).Result()
+	return s.convert(s.command(ctx, s.cli, values[0]), rcvTime)
+}
+
+// LookupBatch sends the commands of all the values in one pipeline
+func (s *lookupSource) LookupBatch(ctx api.StreamContext, _ []string, keys []string, values [][]interface{}) ([][]api.SourceTuple, error) {
+	rcvTime := cnf.GetNow()
+	ctx.GetLogger().Debugf("Lookup redis %v in batch of %d", keys, len(values))
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("redis lookup only support one key, but got %v", keys)
+	}
+	pipe := s.cli.Pipeline()
+	cmds := make([]redis.Cmder, len(values))
+	for i, v := range values {
+		cmds[i] = s.command(ctx, pipe, v[0])
+	}
+	// the missing keys are not errors, the error of each command is checked when converting
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	result := make([][]api.SourceTuple, len(cmds))
+	for i, cmd := range cmds {
+		result[i], err = s.convert(cmd, rcvTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (s *lookupSource) command(ctx api.StreamContext, c redis.Cmdable, value interface{}) redis.Cmder {
+	key := fmt.Sprintf("%v", value)
+	switch s.c.DataType {
+	case dataTypeList:
+		return c.LRange(ctx, key, 0, -1)
+	case dataTypeHash:
+		return c.HGetAll(ctx, key)
+	default:
+		return c.Get(ctx, key)
+	}
+}
+
+// convert the result of the command to tuples. The missing key results in empty tuples
+func (s *lookupSource) convert(cmd redis.Cmder, rcvTime time.Time) ([]api.SourceTuple, error) {
+	switch c := cmd.(type) {
+	case *redis.StringCmd:
+		res, err := c.Result()
 		if err != nil {
 			if err == redis.Nil {
 				return []api.SourceTuple{}, nil
@@ -102,8 +154,8 @@ func (s *lookupSource) Lookup(ctx api.StreamContext, _ []string, keys []string,
 			return nil, err
 		}
 		return []api.SourceTuple{api.NewDefaultSourceTupleWithTime(m, nil, rcvTime)}, nil
-	} else {
-		res, err := s.cli.LRange(ctx, v, 0, -1).Result()
+	case *redis.StringSliceCmd:
+		res, err := c.Result()
 		if err != nil {
 			if err == redis.Nil {
 				return []api.SourceTuple{}, nil
@@ -120,6 +172,22 @@ func (s *lookupSource) Lookup(ctx api.StreamContext, _ []string, keys []string,
 			ret = append(ret, api.NewDefaultSourceTupleWithTime(m, nil, rcvTime))
 		}
 		return ret, nil
+	case *redis.MapStringStringCmd:
+		res, err := c.Result()
+		if err != nil {
+			return nil, err
+		}
+		// hash of a missing key is empty
+		if len(res) == 0 {
+			return []api.SourceTuple{}, nil
+		}
+		m := make(map[string]interface{}, len(res))
+		for k, v := range res {
+			m[k] = v
+		}
+		return []api.SourceTuple{api.NewDefaultSourceTupleWithTime(m, nil, rcvTime)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported redis command %v", cmd)
 	}
 }
 
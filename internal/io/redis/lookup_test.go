@@ -47,6 +47,9 @@ func init() {
 	s.Lpush("group1", `{"id":2,"name":"Susan"}`)
 	s.Lpush("group2", `{"id":3,"name":"Nancy"}`)
 	s.Lpush("group3", `{"id":4,"name":"Tom"}`)
+	// Mock hash data
+	s.HSet("device1", "name", "John", "kind", "sensor")
+	s.HSet("device2", "name", "Susan", "kind", "meter")
 	mr = s
 }
 
@@ -146,6 +149,114 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestHash(t *testing.T) {
+	contextLogger := econf.Log.WithField("rule", "test")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	ls := GetLookupSource()
+	err := ls.Configure("0", map[string]interface{}{"addr": addr, "datatype": "hash"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = ls.Open(ctx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	mc := econf.Clock.(*clock.Mock)
+	tests := []struct {
+		value  string
+		result []api.SourceTuple
+	}{
+		{
+			value: "device1",
+			result: []api.SourceTuple{
+				api.NewDefaultSourceTupleWithTime(map[string]interface{}{"name": "John", "kind": "sensor"}, nil, mc.Now()),
+			},
+		}, {
+			value:  "device3",
+			result: []api.SourceTuple{},
+		},
+	}
+	for i, tt := range tests {
+		actual, err := ls.Lookup(ctx, []string{}, []string{"id"}, []interface{}{tt.value})
+		if err != nil {
+			t.Errorf("Test %d: %v", i, err)
+			continue
+		}
+		if len(actual) != len(tt.result) || !deepEqual(actual, tt.result) {
+			t.Errorf("Test %d: expected %v, actual %v", i, tt.result, actual)
+			continue
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	contextLogger := econf.Log.WithField("rule", "test")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	mc := econf.Clock.(*clock.Mock)
+	tests := []struct {
+		dataType string
+		values   [][]interface{}
+		result   [][]api.SourceTuple
+	}{
+		{
+			dataType: "string",
+			values:   [][]interface{}{{1}, {3}, {2}},
+			result: [][]api.SourceTuple{
+				{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(1), "name": "John", "address": float64(34), "mobile": "334433"}, nil, mc.Now())},
+				{},
+				{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(2), "name": "Susan", "address": float64(22), "mobile": "666433"}, nil, mc.Now())},
+			},
+		}, {
+			dataType: "list",
+			values:   [][]interface{}{{"group2"}, {"group4"}},
+			result: [][]api.SourceTuple{
+				{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": float64(3), "name": "Nancy"}, nil, mc.Now())},
+				{},
+			},
+		}, {
+			dataType: "hash",
+			values:   [][]interface{}{{"device3"}, {"device2"}},
+			result: [][]api.SourceTuple{
+				{},
+				{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"name": "Susan", "kind": "meter"}, nil, mc.Now())},
+			},
+		},
+	}
+	for i, tt := range tests {
+		ls := &lookupSource{}
+		err := ls.Configure("0", map[string]interface{}{"addr": addr, "datatype": tt.dataType})
+		if err != nil {
+			t.Errorf("Test %d: %v", i, err)
+			continue
+		}
+		actual, err := ls.LookupBatch(ctx, []string{}, []string{"id"}, tt.values)
+		if err != nil {
+			t.Errorf("Test %d: %v", i, err)
+			continue
+		}
+		if len(actual) != len(tt.result) {
+			t.Errorf("Test %d: expected %v, actual %v", i, tt.result, actual)
+			continue
+		}
+		for j := range actual {
+			if len(actual[j]) != len(tt.result[j]) || !deepEqual(actual[j], tt.result[j]) {
+				t.Errorf("Test %d: result %d expected %v, actual %v", i, j, tt.result[j], actual[j])
+			}
+		}
+		_ = ls.Close(ctx)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	ls := GetLookupSource()
+	err := ls.Configure("0", map[string]interface{}{"addr": addr, "datatype": "set"})
+	if err == nil || err.Error() != "redis dataType must be string, list or hash" {
+		t.Errorf("expect dataType error but got %v", err)
+	}
+}
+
 func deepEqual(a []api.SourceTuple, b []api.SourceTuple) bool {
 	for i, val := range a {
 		if !reflect.DeepEqual(val.Message(), b[i].Message()) || !reflect.DeepEqual(val.Meta(), b[i].Meta()) {
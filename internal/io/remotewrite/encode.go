@@ -0,0 +1,99 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build remotewrite || !core
+
+package remotewrite
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/s2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const nameLabel = "__name__"
+
+type label struct {
+	name  string
+	value string
+}
+
+// series is a time series with one sample
+type series struct {
+	labels []label
+	value  float64
+	// ts is the timestamp in milliseconds
+	ts int64
+}
+
+// encode marshals the series into the WriteRequest of the remote write protocol and compresses it by snappy.
+// The messages are defined in https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto as below
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encode(ss []series) []byte {
+	var b []byte
+	for _, s := range ss {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, encodeSeries(s))
+	}
+	return s2.EncodeSnappy(nil, b)
+}
+
+func encodeSeries(s series) []byte {
+	var b []byte
+	for _, l := range s.labels {
+		var lb []byte
+		lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.name)
+		lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.value)
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+	var sb []byte
+	sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+	sb = protowire.AppendFixed64(sb, math.Float64bits(s.value))
+	sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+	sb = protowire.AppendVarint(sb, uint64(s.ts))
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	return protowire.AppendBytes(b, sb)
+}
+
+// sortLabels sorts the labels by name as required by the protocol
+func sortLabels(labels []label) {
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].name < labels[j].name
+	})
+}
+
+// sanitize replaces the invalid characters of the metric name or label name with underscores. The metric name
+// matches [a-zA-Z_:][a-zA-Z0-9_:]* and the label name matches [a-zA-Z_][a-zA-Z0-9_]*
+func sanitize(name string, isMetric bool) string {
+	var sb strings.Builder
+	for i, c := range name {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') || (isMetric && c == ':')
+		if valid {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
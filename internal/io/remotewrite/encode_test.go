@@ -0,0 +1,139 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build remotewrite || !core
+
+package remotewrite
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/s2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decode parses the compressed WriteRequest into the series
+func decode(body []byte) ([]series, error) {
+	b, err := s2.Decode(nil, body)
+	if err != nil {
+		return nil, err
+	}
+	var result []series
+	err = consumeMessages(b, func(num protowire.Number, v []byte) error {
+		if num != 1 {
+			return fmt.Errorf("unexpected field %d of write request", num)
+		}
+		var s series
+		err := consumeMessages(v, func(num protowire.Number, v []byte) error {
+			switch num {
+			case 1:
+				var l label
+				err := consumeMessages(v, func(num protowire.Number, v []byte) error {
+					if num == 1 {
+						l.name = string(v)
+					} else {
+						l.value = string(v)
+					}
+					return nil
+				})
+				s.labels = append(s.labels, l)
+				return err
+			case 2:
+				for len(v) > 0 {
+					num, typ, n := protowire.ConsumeTag(v)
+					v = v[n:]
+					if num == 1 && typ == protowire.Fixed64Type {
+						f, n := protowire.ConsumeFixed64(v)
+						s.value = math.Float64frombits(f)
+						v = v[n:]
+					} else if num == 2 && typ == protowire.VarintType {
+						ts, n := protowire.ConsumeVarint(v)
+						s.ts = int64(ts)
+						v = v[n:]
+					} else {
+						return fmt.Errorf("invalid sample")
+					}
+				}
+				return nil
+			default:
+				return fmt.Errorf("unexpected field %d of time series", num)
+			}
+		})
+		result = append(result, s)
+		return err
+	})
+	return result, err
+}
+
+func consumeMessages(b []byte, f func(num protowire.Number, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 || typ != protowire.BytesType {
+			return fmt.Errorf("invalid message")
+		}
+		b = b[n:]
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return fmt.Errorf("invalid message")
+		}
+		b = b[n:]
+		if err := f(num, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestEncode(t *testing.T) {
+	ss := []series{
+		{
+			labels: []label{{name: nameLabel, value: "temperature"}, {name: "device", value: "d1"}},
+			value:  20.5,
+			ts:     1680000000000,
+		}, {
+			labels: []label{{name: nameLabel, value: "up"}},
+			value:  -1,
+			ts:     0,
+		},
+	}
+	result, err := decode(encode(ss))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, ss) {
+		t.Errorf("expect %v but got %v", ss, result)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name     string
+		isMetric bool
+		exp      string
+	}{
+		{name: "http_requests_total", isMetric: true, exp: "http_requests_total"},
+		{name: "job:rate5m", isMetric: true, exp: "job:rate5m"},
+		{name: "job:rate5m", exp: "job_rate5m"},
+		{name: "1st.temp-value", isMetric: true, exp: "_st_temp_value"},
+		{name: "温度", exp: "__"},
+	}
+	for _, tt := range tests {
+		if r := sanitize(tt.name, tt.isMetric); r != tt.exp {
+			t.Errorf("%s: expect %s but got %s", tt.name, tt.exp, r)
+		}
+	}
+}
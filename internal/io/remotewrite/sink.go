@@ -0,0 +1,245 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build remotewrite || !core
+
+package remotewrite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+type sinkConf struct {
+	// Url is the remote write endpoint such as http://127.0.0.1:9090/api/v1/write
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	// Timeout is the timeout in milliseconds of the request
+	Timeout int `json:"timeout"`
+	// Metric is the metric name, it can be a data template such as {{.name}}. If there are multiple value fields,
+	// the name of each series is the metric name and the field name joined by an underscore
+	Metric string `json:"metric"`
+	// ValueFields are the fields of the sample values, default to the value field
+	ValueFields []string `json:"valueFields"`
+	// LabelFields are the fields of the data written as the labels
+	LabelFields []string `json:"labelFields"`
+	// Labels are the static labels, the values can be data templates such as {{.device}}
+	Labels map[string]string `json:"labels"`
+	// TimestampField is the field of the timestamp in milliseconds, the current time is used if not set
+	TimestampField string   `json:"timestampField"`
+	DataTemplate   string   `json:"dataTemplate"`
+	Fields         []string `json:"fields"`
+	DataField      string   `json:"dataField"`
+}
+
+type sink struct {
+	c   *sinkConf
+	cli *http.Client
+}
+
+func (s *sink) Configure(props map[string]interface{}) error {
+	c := &sinkConf{
+		Timeout: 5000,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Url == "" {
+		return fmt.Errorf("url is required")
+	}
+	if u, err := url.Parse(c.Url); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid url %s", c.Url)
+	}
+	if c.Metric == "" {
+		return fmt.Errorf("metric is required")
+	}
+	if len(c.ValueFields) == 0 {
+		c.ValueFields = []string{"value"}
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	for k := range c.Labels {
+		if k == "" || sanitize(k, false) != k || k == nameLabel {
+			return fmt.Errorf("invalid label name %s", k)
+		}
+	}
+	s.c = c
+	return nil
+}
+
+func (s *sink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("opening remote write sink to %s", s.c.Url)
+	s.cli = &http.Client{Timeout: time.Duration(s.c.Timeout) * time.Millisecond}
+	return nil
+}
+
+func (s *sink) Collect(ctx api.StreamContext, item interface{}) error {
+	var data interface{}
+	if s.c.DataTemplate != "" {
+		b, _, err := ctx.TransformOutput(item)
+		if err != nil {
+			return err
+		}
+		var m interface{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			return fmt.Errorf("fail to decode data %s after applying dataTemplate for error %v", string(b), err)
+		}
+		data = m
+	} else {
+		m, _, err := transform.TransItem(item, s.c.DataField, s.c.Fields)
+		if err != nil {
+			return fmt.Errorf("fail to select fields %v for data %v", s.c.Fields, item)
+		}
+		data = m
+	}
+	var rows []map[string]interface{}
+	switch d := data.(type) {
+	case map[string]interface{}:
+		rows = []map[string]interface{}{d}
+	case []map[string]interface{}:
+		rows = d
+	case []interface{}:
+		for _, el := range d {
+			m, ok := el.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("unsupported type %T", el)
+			}
+			rows = append(rows, m)
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", data)
+	}
+	var ss []series
+	for _, row := range rows {
+		rs, err := s.toSeries(ctx, row)
+		if err != nil {
+			return err
+		}
+		ss = append(ss, rs...)
+	}
+	if len(ss) == 0 {
+		ctx.GetLogger().Debugf("remote write sink ignores the data without values %v", data)
+		return nil
+	}
+	return s.write(encode(ss))
+}
+
+// toSeries converts the data into a series of each value field. The fields of nil values are ignored
+func (s *sink) toSeries(ctx api.StreamContext, data map[string]interface{}) ([]series, error) {
+	metric, err := ctx.ParseTemplate(s.c.Metric, data)
+	if err != nil {
+		return nil, fmt.Errorf("parse template for metric %s error: %v", s.c.Metric, err)
+	}
+	labels := make([]label, 0, len(s.c.Labels)+len(s.c.LabelFields)+1)
+	for k, v := range s.c.Labels {
+		lv, err := ctx.ParseTemplate(v, data)
+		if err != nil {
+			return nil, fmt.Errorf("parse template for label %s error: %v", k, err)
+		}
+		labels = append(labels, label{name: k, value: lv})
+	}
+	for _, f := range s.c.LabelFields {
+		if v, ok := data[f]; ok && v != nil {
+			labels = append(labels, label{name: sanitize(f, false), value: cast.ToStringAlways(v)})
+		}
+	}
+	ts := conf.GetNowInMilli()
+	if s.c.TimestampField != "" {
+		v, ok := data[s.c.TimestampField]
+		if !ok {
+			return nil, fmt.Errorf("timestamp field %s not found in data %v", s.c.TimestampField, data)
+		}
+		ts, err = cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, fmt.Errorf("timestamp field %s must be an int of milliseconds but got %v", s.c.TimestampField, v)
+		}
+	}
+	result := make([]series, 0, len(s.c.ValueFields))
+	for _, f := range s.c.ValueFields {
+		v, ok := data[f]
+		if !ok || v == nil {
+			continue
+		}
+		var value float64
+		if b, ok := v.(bool); ok {
+			if b {
+				value = 1
+			}
+		} else {
+			value, err = cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+			if err != nil {
+				return nil, fmt.Errorf("value field %s must be a number but got %v", f, v)
+			}
+		}
+		name := metric
+		if len(s.c.ValueFields) > 1 {
+			name = metric + "_" + f
+		}
+		ls := make([]label, len(labels), len(labels)+1)
+		copy(ls, labels)
+		ls = append(ls, label{name: nameLabel, value: sanitize(name, true)})
+		sortLabels(ls)
+		result = append(result, series{labels: ls, value: value, ts: ts})
+	}
+	return result, nil
+}
+
+// write sends the request. The network errors, the server errors and the rate limit are reported as IO errors so
+// that they can be retried by the cache
+func (s *sink) write(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.c.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.c.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := s.cli.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: write to %s error: %v", errorx.IOErr, s.c.Url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("%s: write to %s error with status %d: %s", errorx.IOErr, s.c.Url, resp.StatusCode, string(msg))
+	}
+	return fmt.Errorf("write to %s error with status %d: %s", s.c.Url, resp.StatusCode, string(msg))
+}
+
+func (s *sink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing remote write sink")
+	return nil
+}
+
+func GetSink() api.Sink {
+	return &sink{}
+}
@@ -0,0 +1,209 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build remotewrite || !core
+
+package remotewrite
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"metric": "m1"},
+			err:   "url is required",
+		}, {
+			props: map[string]interface{}{"url": "127.0.0.1:9090", "metric": "m1"},
+			err:   "invalid url 127.0.0.1:9090",
+		}, {
+			props: map[string]interface{}{"url": "http://127.0.0.1:9090/api/v1/write"},
+			err:   "metric is required",
+		}, {
+			props: map[string]interface{}{"url": "http://127.0.0.1:9090/api/v1/write", "metric": "m1", "labels": map[string]interface{}{"a-b": "c"}},
+			err:   "invalid label name a-b",
+		}, {
+			props: map[string]interface{}{"url": "http://127.0.0.1:9090/api/v1/write", "metric": "m1", "labels": map[string]interface{}{"site": "s1"}},
+		},
+	}
+	for i, tt := range tests {
+		err := GetSink().Configure(tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+type mockServer struct {
+	sync.Mutex
+	status  int
+	headers []http.Header
+	series  [][]series
+}
+
+func (m *mockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	m.Lock()
+	defer m.Unlock()
+	if m.status != 0 {
+		w.WriteHeader(m.status)
+		_, _ = w.Write([]byte("out of order sample"))
+		return
+	}
+	ss, err := decode(b)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	m.headers = append(m.headers, r.Header)
+	m.series = append(m.series, ss)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestCollect(t *testing.T) {
+	conf.InitClock()
+	ms := &mockServer{}
+	server := httptest.NewServer(ms)
+	defer server.Close()
+	s := GetSink()
+	err := s.Configure(map[string]interface{}{
+		"url":            server.URL,
+		"headers":        map[string]interface{}{"Authorization": "Bearer t1"},
+		"metric":         "{{.name}}",
+		"valueFields":    []interface{}{"avg", "max"},
+		"labelFields":    []interface{}{"device"},
+		"labels":         map[string]interface{}{"site": "s1", "job": "{{.name}}_job"},
+		"timestampField": "ts",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := mockContext.NewMockContext("rule1", "op1").WithCancel()
+	defer cancel()
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	data := []map[string]interface{}{
+		{"name": "temperature", "device": "d1", "avg": 20.5, "max": int64(25), "ts": int64(1680000000000)},
+		{"name": "humidity", "device": "d2", "avg": 60.0, "max": nil, "ts": int64(1680000001000)},
+	}
+	if err := s.Collect(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+	exp := []series{
+		{
+			labels: []label{{name: nameLabel, value: "temperature_avg"}, {name: "device", value: "d1"}, {name: "job", value: "temperature_job"}, {name: "site", value: "s1"}},
+			value:  20.5,
+			ts:     1680000000000,
+		}, {
+			labels: []label{{name: nameLabel, value: "temperature_max"}, {name: "device", value: "d1"}, {name: "job", value: "temperature_job"}, {name: "site", value: "s1"}},
+			value:  25,
+			ts:     1680000000000,
+		}, {
+			labels: []label{{name: nameLabel, value: "humidity_avg"}, {name: "device", value: "d2"}, {name: "job", value: "humidity_job"}, {name: "site", value: "s1"}},
+			value:  60,
+			ts:     1680000001000,
+		},
+	}
+	ms.Lock()
+	defer ms.Unlock()
+	if len(ms.series) != 1 {
+		t.Fatalf("expect 1 request but got %d", len(ms.series))
+	}
+	if !reflect.DeepEqual(ms.series[0], exp) {
+		t.Errorf("expect %v but got %v", exp, ms.series[0])
+	}
+	h := ms.headers[0]
+	if h.Get("Authorization") != "Bearer t1" || h.Get("Content-Encoding") != "snappy" || h.Get("Content-Type") != "application/x-protobuf" || h.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+		t.Errorf("unexpected headers %v", h)
+	}
+}
+
+func TestCollectError(t *testing.T) {
+	conf.InitClock()
+	ms := &mockServer{}
+	server := httptest.NewServer(ms)
+	defer server.Close()
+	s := GetSink()
+	err := s.Configure(map[string]interface{}{
+		"url":    server.URL,
+		"metric": "m1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := mockContext.NewMockContext("rule1", "op1").WithCancel()
+	defer cancel()
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		status int
+		data   map[string]interface{}
+		err    string
+	}{
+		{
+			data: map[string]interface{}{"value": "a"},
+			err:  "value field value must be a number but got a",
+		}, {
+			status: http.StatusBadRequest,
+			data:   map[string]interface{}{"value": 1},
+			err:    "write to " + server.URL + " error with status 400: out of order sample",
+		}, {
+			status: http.StatusServiceUnavailable,
+			data:   map[string]interface{}{"value": 1},
+			err:    "io error: write to " + server.URL + " error with status 503: out of order sample",
+		}, {
+			data: map[string]interface{}{"other": 1},
+		},
+	}
+	for i, tt := range tests {
+		ms.Lock()
+		ms.status = tt.status
+		ms.Unlock()
+		err := s.Collect(ctx, tt.data)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%d: unexpected error %v", i, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+	ms.Lock()
+	defer ms.Unlock()
+	if len(ms.series) != 0 {
+		t.Errorf("expect no series written but got %v", ms.series)
+	}
+}
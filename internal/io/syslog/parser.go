@@ -0,0 +1,220 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build syslog || !core
+
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatRFC5424 = "rfc5424"
+	FormatRFC3164 = "rfc3164"
+	nilValue      = "-"
+)
+
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// parse parses the syslog message of RFC 5424 or RFC 3164 into the fields. The format is detected by the version after the priority.
+// The now time is used to complete the year of the RFC 3164 timestamp
+func parse(msg []byte, now time.Time) (map[string]interface{}, string, error) {
+	msg = bytes.TrimRight(msg, "\r\n\x00")
+	pri, rest, err := parsePriority(msg)
+	if err != nil {
+		return nil, "", err
+	}
+	result := map[string]interface{}{
+		"priority": pri,
+		"facility": pri / 8,
+		"severity": pri % 8,
+	}
+	// RFC 5424 has the version 1 after the priority
+	if len(rest) > 1 && rest[0] == '1' && rest[1] == ' ' {
+		err = parse5424(rest[2:], result)
+		return result, FormatRFC5424, err
+	}
+	parse3164(rest, now, result)
+	return result, FormatRFC3164, nil
+}
+
+func parsePriority(msg []byte) (int, []byte, error) {
+	if len(msg) == 0 || msg[0] != '<' {
+		return 0, nil, fmt.Errorf("missing priority")
+	}
+	end := bytes.IndexByte(msg, '>')
+	// the priority has at most 3 digits
+	if end < 2 || end > 4 {
+		return 0, nil, fmt.Errorf("invalid priority")
+	}
+	pri, err := strconv.Atoi(string(msg[1:end]))
+	if err != nil || pri > 191 {
+		return 0, nil, fmt.Errorf("invalid priority %s", msg[1:end])
+	}
+	return pri, msg[end+1:], nil
+}
+
+// parse5424 parses TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP STRUCTURED-DATA [SP MSG]
+func parse5424(msg []byte, result map[string]interface{}) error {
+	result["version"] = 1
+	names := []string{"timestamp", "hostname", "appName", "procId", "msgId"}
+	for _, name := range names {
+		var field string
+		field, msg = nextField(msg)
+		if field == "" {
+			return fmt.Errorf("missing %s", name)
+		}
+		if field == nilValue {
+			continue
+		}
+		if name == "timestamp" {
+			t, err := time.Parse(time.RFC3339Nano, field)
+			if err != nil {
+				return fmt.Errorf("invalid timestamp %s", field)
+			}
+			result[name] = t.UnixMilli()
+			continue
+		}
+		result[name] = field
+	}
+	if len(msg) == 0 {
+		return fmt.Errorf("missing structured data")
+	}
+	if msg[0] == '-' {
+		msg = msg[1:]
+	} else {
+		sd, rest, err := parseStructuredData(msg)
+		if err != nil {
+			return err
+		}
+		result["structuredData"] = sd
+		msg = rest
+	}
+	if len(msg) > 0 {
+		if msg[0] != ' ' {
+			return fmt.Errorf("invalid structured data")
+		}
+		msg = bytes.TrimPrefix(msg[1:], bom)
+		result["message"] = string(msg)
+	}
+	return nil
+}
+
+func nextField(msg []byte) (string, []byte) {
+	i := bytes.IndexByte(msg, ' ')
+	if i < 0 {
+		return string(msg), nil
+	}
+	return string(msg[:i]), msg[i+1:]
+}
+
+// parseStructuredData parses the SD-ELEMENTs like [id param="value"][id2 param="value"] into the map of the SD-ID and the params
+func parseStructuredData(msg []byte) (map[string]interface{}, []byte, error) {
+	sd := make(map[string]interface{})
+	for len(msg) > 0 && msg[0] == '[' {
+		i := 1
+		for i < len(msg) && msg[i] != ' ' && msg[i] != ']' {
+			i++
+		}
+		if i == 1 || i >= len(msg) {
+			return nil, nil, fmt.Errorf("invalid structured data")
+		}
+		id := string(msg[1:i])
+		params := make(map[string]interface{})
+		for i < len(msg) && msg[i] == ' ' {
+			// PARAM-NAME="PARAM-VALUE"
+			j := i + 1
+			for j < len(msg) && msg[j] != '=' {
+				j++
+			}
+			if j+1 >= len(msg) || msg[j+1] != '"' {
+				return nil, nil, fmt.Errorf("invalid structured data param of %s", id)
+			}
+			name := string(msg[i+1 : j])
+			var value strings.Builder
+			k := j + 2
+			for ; k < len(msg) && msg[k] != '"'; k++ {
+				// only ", \ and ] are escaped
+				if msg[k] == '\\' && k+1 < len(msg) && (msg[k+1] == '"' || msg[k+1] == '\\' || msg[k+1] == ']') {
+					k++
+				}
+				value.WriteByte(msg[k])
+			}
+			if k >= len(msg) {
+				return nil, nil, fmt.Errorf("unclosed structured data param %s of %s", name, id)
+			}
+			params[name] = value.String()
+			i = k + 1
+		}
+		if i >= len(msg) || msg[i] != ']' {
+			return nil, nil, fmt.Errorf("unclosed structured data %s", id)
+		}
+		sd[id] = params
+		msg = msg[i+1:]
+	}
+	return sd, msg, nil
+}
+
+// parse3164 parses TIMESTAMP SP HOSTNAME SP TAG[PID]: MSG. Per RFC 3164 section 4.3, the whole content is
+// taken as the message if the header is not valid
+func parse3164(msg []byte, now time.Time, result map[string]interface{}) {
+	// Mmm dd hh:mm:ss
+	if len(msg) < len(time.Stamp)+1 || msg[len(time.Stamp)] != ' ' {
+		result["message"] = string(msg)
+		return
+	}
+	t, err := time.ParseInLocation(time.Stamp, string(msg[:len(time.Stamp)]), now.Location())
+	if err != nil {
+		result["message"] = string(msg)
+		return
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	// the message of the last year received in the new year
+	if t.After(now.AddDate(0, 0, 1)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	result["timestamp"] = t.UnixMilli()
+	hostname, rest := nextField(msg[len(time.Stamp)+1:])
+	result["hostname"] = hostname
+	// the tag is alphanumeric and terminated by [ or :
+	i := 0
+	for i < len(rest) && i <= 32 && rest[i] != '[' && rest[i] != ':' && rest[i] != ' ' {
+		i++
+	}
+	if i == 0 || i >= len(rest) || rest[i] == ' ' {
+		result["message"] = string(rest)
+		return
+	}
+	appName, content := string(rest[:i]), rest[i:]
+	if content[0] == '[' {
+		end := bytes.IndexByte(content, ']')
+		if end < 0 || end+1 >= len(content) || content[end+1] != ':' {
+			result["message"] = string(rest)
+			return
+		}
+		result["procId"] = string(content[1:end])
+		content = content[end+1:]
+	}
+	if content[0] != ':' {
+		result["message"] = string(rest)
+		return
+	}
+	result["appName"] = appName
+	result["message"] = string(bytes.TrimPrefix(content[1:], []byte(" ")))
+}
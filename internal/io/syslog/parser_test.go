@@ -0,0 +1,171 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syslog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Date(2023, 1, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		msg    string
+		result map[string]interface{}
+		format string
+		err    string
+	}{
+		{
+			msg: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"] An application event log entry`,
+			result: map[string]interface{}{
+				"priority":  165,
+				"facility":  20,
+				"severity":  5,
+				"version":   1,
+				"timestamp": int64(1065910455003),
+				"hostname":  "mymachine.example.com",
+				"appName":   "evntslog",
+				"msgId":     "ID47",
+				"structuredData": map[string]interface{}{
+					"exampleSDID@32473":     map[string]interface{}{"iut": "3", "eventSource": "Application", "eventID": "1011"},
+					"examplePriority@32473": map[string]interface{}{"class": "high"},
+				},
+				"message": "An application event log entry",
+			},
+			format: FormatRFC5424,
+		}, {
+			msg: "<34>1 2003-10-11T22:14:15.003+08:00 mymachine su 1234 - - \xEF\xBB\xBF'su root' failed for lonvick on /dev/pts/8\n",
+			result: map[string]interface{}{
+				"priority":  34,
+				"facility":  4,
+				"severity":  2,
+				"version":   1,
+				"timestamp": int64(1065881655003),
+				"hostname":  "mymachine",
+				"appName":   "su",
+				"procId":    "1234",
+				"message":   "'su root' failed for lonvick on /dev/pts/8",
+			},
+			format: FormatRFC5424,
+		}, {
+			msg: `<13>1 - - - - - [meta escaped="a\"b\\c\]d"]`,
+			result: map[string]interface{}{
+				"priority": 13,
+				"facility": 1,
+				"severity": 5,
+				"version":  1,
+				"structuredData": map[string]interface{}{
+					"meta": map[string]interface{}{"escaped": `a"b\c]d`},
+				},
+			},
+			format: FormatRFC5424,
+		}, {
+			msg: `<34>Oct 11 22:14:15 mymachine su[1234]: 'su root' failed for lonvick on /dev/pts/8`,
+			result: map[string]interface{}{
+				"priority":  34,
+				"facility":  4,
+				"severity":  2,
+				"timestamp": time.Date(2022, 10, 11, 22, 14, 15, 0, time.UTC).UnixMilli(),
+				"hostname":  "mymachine",
+				"appName":   "su",
+				"procId":    "1234",
+				"message":   "'su root' failed for lonvick on /dev/pts/8",
+			},
+			format: FormatRFC3164,
+		}, {
+			msg: `<13>Jan  1 07:59:00 host app: hello`,
+			result: map[string]interface{}{
+				"priority":  13,
+				"facility":  1,
+				"severity":  5,
+				"timestamp": time.Date(2023, 1, 1, 7, 59, 0, 0, time.UTC).UnixMilli(),
+				"hostname":  "host",
+				"appName":   "app",
+				"message":   "hello",
+			},
+			format: FormatRFC3164,
+		}, {
+			msg: `<13>Use the BFG!`,
+			result: map[string]interface{}{
+				"priority": 13,
+				"facility": 1,
+				"severity": 5,
+				"message":  "Use the BFG!",
+			},
+			format: FormatRFC3164,
+		}, {
+			msg: `<13>Jan  1 07:59:00 host no tag here`,
+			result: map[string]interface{}{
+				"priority":  13,
+				"facility":  1,
+				"severity":  5,
+				"timestamp": time.Date(2023, 1, 1, 7, 59, 0, 0, time.UTC).UnixMilli(),
+				"hostname":  "host",
+				"message":   "no tag here",
+			},
+			format: FormatRFC3164,
+		}, {
+			msg: `hello`,
+			err: "missing priority",
+		}, {
+			msg: `<1234>hello`,
+			err: "invalid priority",
+		}, {
+			msg: `<192>hello`,
+			err: "invalid priority 192",
+		}, {
+			msg: `<13>1 2003-10-11 host app - - -`,
+			err: "invalid timestamp 2003-10-11",
+		}, {
+			msg: `<13>1 - host app -`,
+			err: "missing msgId",
+		}, {
+			msg: `<13>1 - host app - -`,
+			err: "missing structured data",
+		}, {
+			msg: `<13>1 - host app - - [id a="1"`,
+			err: "unclosed structured data id",
+		}, {
+			msg: `<13>1 - host app - - [id a="1]`,
+			err: "unclosed structured data param a of id",
+		}, {
+			msg: `<13>1 - host app - - [id a=1]`,
+			err: "invalid structured data param of id",
+		}, {
+			msg: `<13>1 - host app - - [id]msg`,
+			err: "invalid structured data",
+		},
+	}
+	for i, tt := range tests {
+		result, format, err := parse([]byte(tt.msg), now)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if format != tt.format {
+			t.Errorf("%d: expect format %s but got %s", i, tt.format, format)
+		}
+		if !reflect.DeepEqual(result, tt.result) {
+			t.Errorf("%d: expect %v but got %v", i, tt.result, result)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build syslog || !core
+
+package syslog
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+const (
+	ProtocolUDP = "udp"
+	ProtocolTCP = "tcp"
+	ProtocolTLS = "tls"
+	// maxLengthDigits limits the length prefix of the octet counting frames
+	maxLengthDigits = 10
+)
+
+type sourceConf struct {
+	// Protocol is udp, tcp or tls
+	Protocol string `json:"protocol"`
+	// Addr is the listening address
+	Addr string `json:"addr"`
+	// MaxMessageSize is the max size of a message in bytes, the longer messages are truncated in udp or rejected in tcp and tls
+	MaxMessageSize    int    `json:"maxMessageSize"`
+	CertificationPath string `json:"certificationPath"`
+	PrivateKeyPath    string `json:"privateKeyPath"`
+	// RootCaPath is used to verify the client certificates in tls. The client certificates are not required if it is not set
+	RootCaPath string `json:"rootCaPath"`
+}
+
+type source struct {
+	c         *sourceConf
+	tlsConfig *tls.Config
+
+	mu    sync.Mutex
+	pc    net.PacketConn
+	ln    net.Listener
+	conns map[net.Conn]struct{}
+}
+
+// Configure the source. The datasource is not used
+func (s *source) Configure(_ string, props map[string]interface{}) error {
+	c := &sourceConf{
+		Protocol:       ProtocolUDP,
+		Addr:           ":514",
+		MaxMessageSize: 8192,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Addr == "" {
+		return fmt.Errorf("addr is required")
+	}
+	if c.MaxMessageSize <= 0 {
+		return fmt.Errorf("maxMessageSize must be positive")
+	}
+	switch c.Protocol {
+	case ProtocolUDP, ProtocolTCP:
+	case ProtocolTLS:
+		if c.CertificationPath == "" || c.PrivateKeyPath == "" {
+			return fmt.Errorf("certificationPath and privateKeyPath are required for tls")
+		}
+		tlsConfig, err := serverTLS(c)
+		if err != nil {
+			return err
+		}
+		s.tlsConfig = tlsConfig
+	default:
+		return fmt.Errorf("invalid protocol %s, must be udp, tcp or tls", c.Protocol)
+	}
+	s.c = c
+	return nil
+}
+
+func serverTLS(c *sourceConf) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertificationPath, c.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("load the certificate error: %v", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if c.RootCaPath != "" {
+		ca, err := os.ReadFile(c.RootCaPath)
+		if err != nil {
+			return nil, fmt.Errorf("load the root ca error: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid root ca %s", c.RootCaPath)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+func (s *source) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	var err error
+	if s.c.Protocol == ProtocolUDP {
+		err = s.serveUDP(ctx, consumer)
+	} else {
+		err = s.serveStream(ctx, consumer)
+	}
+	if err != nil {
+		infra.DrainError(ctx, err, errCh)
+	}
+}
+
+// serveUDP receives a message in each datagram
+func (s *source) serveUDP(ctx api.StreamContext, consumer chan<- api.SourceTuple) error {
+	logger := ctx.GetLogger()
+	pc, err := net.ListenPacket("udp", s.c.Addr)
+	if err != nil {
+		return fmt.Errorf("syslog source fails to listen %s: %v", s.c.Addr, err)
+	}
+	s.mu.Lock()
+	s.pc = pc
+	s.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		_ = pc.Close()
+	}()
+	logger.Infof("syslog source listens on udp %s", pc.LocalAddr())
+	buf := make([]byte, s.c.MaxMessageSize)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("syslog source fails to read: %v", err)
+		}
+		s.emit(ctx, consumer, buf[:n], addr.String())
+	}
+}
+
+// serveStream accepts the tcp or tls connections, each connection may send multiple messages
+func (s *source) serveStream(ctx api.StreamContext, consumer chan<- api.SourceTuple) error {
+	logger := ctx.GetLogger()
+	var (
+		ln  net.Listener
+		err error
+	)
+	if s.c.Protocol == ProtocolTLS {
+		ln, err = tls.Listen("tcp", s.c.Addr, s.tlsConfig)
+	} else {
+		ln, err = net.Listen("tcp", s.c.Addr)
+	}
+	if err != nil {
+		return fmt.Errorf("syslog source fails to listen %s: %v", s.c.Addr, err)
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.conns = make(map[net.Conn]struct{})
+	s.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		s.closeAll()
+	}()
+	logger.Infof("syslog source listens on %s %s", s.c.Protocol, ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("syslog source fails to accept: %v", err)
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.handle(ctx, conn, consumer)
+	}
+}
+
+func (s *source) handle(ctx api.StreamContext, conn net.Conn, consumer chan<- api.SourceTuple) {
+	logger := ctx.GetLogger()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+	remoteAddr := conn.RemoteAddr().String()
+	r := bufio.NewReaderSize(conn, s.c.MaxMessageSize)
+	for {
+		msg, err := readFrame(r, s.c.MaxMessageSize)
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				logger.Warnf("syslog source closes the connection from %s: %v", remoteAddr, err)
+			}
+			return
+		}
+		if len(msg) > 0 {
+			s.emit(ctx, consumer, msg, remoteAddr)
+		}
+	}
+}
+
+// readFrame reads a message framed by octet counting or by the trailing LF as RFC 6587. The framing of each message
+// is detected by the first byte, the octet counting frames start with the length
+func readFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] >= '1' && b[0] <= '9' {
+		n := 0
+		for i := 0; ; i++ {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if c == ' ' {
+				break
+			}
+			if c < '0' || c > '9' || i >= maxLengthDigits {
+				return nil, fmt.Errorf("invalid frame length")
+			}
+			n = n*10 + int(c-'0')
+		}
+		if n > maxSize {
+			return nil, fmt.Errorf("message size %d exceeds maxMessageSize %d", n, maxSize)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, fmt.Errorf("message exceeds maxMessageSize %d", maxSize)
+	}
+	// the last message may not end with LF
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return nil, err
+	}
+	msg := make([]byte, len(line))
+	copy(msg, line)
+	return msg, nil
+}
+
+func (s *source) emit(ctx api.StreamContext, consumer chan<- api.SourceTuple, msg []byte, remoteAddr string) {
+	rcvTime := conf.GetNow()
+	var t api.SourceTuple
+	result, format, err := parse(msg, rcvTime)
+	if err != nil {
+		t = &xsql.ErrorSourceTuple{
+			Error: fmt.Errorf("invalid syslog message %s with error %s", string(msg), err),
+		}
+	} else {
+		meta := map[string]interface{}{
+			"protocol":   s.c.Protocol,
+			"remoteAddr": remoteAddr,
+			"format":     format,
+		}
+		t = api.NewDefaultSourceTupleWithTime(result, meta, rcvTime)
+	}
+	select {
+	case consumer <- t:
+	case <-ctx.Done():
+	}
+}
+
+func (s *source) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pc != nil {
+		_ = s.pc.Close()
+	}
+	if s.ln != nil {
+		_ = s.ln.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+func (s *source) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing syslog source")
+	s.closeAll()
+	return nil
+}
+
+func GetSource() api.Source {
+	return &source{}
+}
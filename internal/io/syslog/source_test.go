@@ -0,0 +1,151 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syslog
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"addr": ""},
+			err:   "addr is required",
+		}, {
+			props: map[string]interface{}{"maxMessageSize": 0},
+			err:   "maxMessageSize must be positive",
+		}, {
+			props: map[string]interface{}{"protocol": "http"},
+			err:   "invalid protocol http, must be udp, tcp or tls",
+		}, {
+			props: map[string]interface{}{"protocol": "tls"},
+			err:   "certificationPath and privateKeyPath are required for tls",
+		}, {
+			props: map[string]interface{}{"protocol": "tls", "certificationPath": "not_exist.pem", "privateKeyPath": "not_exist.key"},
+			err:   "load the certificate error: open not_exist.pem: no such file or directory",
+		}, {
+			props: map[string]interface{}{"protocol": "tcp", "addr": ":6514"},
+		},
+	}
+	for i, tt := range tests {
+		err := GetSource().Configure("", tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	r := bufio.NewReaderSize(strings.NewReader("11 <13>1 - - -<13>hello\n<13>world\n5 <13>a<13>last"), 32)
+	exp := []string{"<13>1 - - -", "<13>hello\n", "<13>world\n", "<13>a", "<13>last"}
+	for i, e := range exp {
+		msg, err := readFrame(r, 32)
+		if err != nil {
+			t.Fatalf("%d: unexpected error %v", i, err)
+		}
+		if string(msg) != e {
+			t.Errorf("%d: expect %q but got %q", i, e, msg)
+		}
+	}
+	tests := []struct {
+		data string
+		err  string
+	}{
+		{data: "100 <13>hello", err: "message size 100 exceeds maxMessageSize 32"},
+		{data: "1x <13>hello", err: "invalid frame length"},
+		{data: "<13>" + strings.Repeat("a", 40) + "\n", err: "message exceeds maxMessageSize 32"},
+	}
+	for i, tt := range tests {
+		_, err := readFrame(bufio.NewReaderSize(strings.NewReader(tt.data), 32), 32)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, protocol := range []string{ProtocolUDP, ProtocolTCP} {
+		s := GetSource().(*source)
+		if err := s.Configure("", map[string]interface{}{"protocol": protocol, "addr": "127.0.0.1:0"}); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := mockContext.NewMockContext("ruleSyslog", "op1").WithCancel()
+		consumer := make(chan api.SourceTuple)
+		errCh := make(chan error)
+		go s.Open(ctx, consumer, errCh)
+		addr := waitListening(t, s)
+		conn, err := net.Dial(protocol, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if protocol == ProtocolUDP {
+			_, err = conn.Write([]byte("<13>1 - host app - - - hello"))
+		} else {
+			_, err = conn.Write([]byte("<13>1 - host app - - - hello\n"))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case tuple := <-consumer:
+			if tuple.Message()["message"] != "hello" || tuple.Message()["hostname"] != "host" {
+				t.Errorf("%s: unexpected message %v", protocol, tuple.Message())
+			}
+			if tuple.Meta()["protocol"] != protocol || tuple.Meta()["format"] != FormatRFC5424 || tuple.Meta()["remoteAddr"] != conn.LocalAddr().String() {
+				t.Errorf("%s: unexpected meta %v", protocol, tuple.Meta())
+			}
+		case err := <-errCh:
+			t.Errorf("%s: unexpected error %v", protocol, err)
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: receive nothing", protocol)
+		}
+		_ = conn.Close()
+		cancel()
+		_ = s.Close(ctx)
+	}
+}
+
+func waitListening(t *testing.T, s *source) string {
+	for i := 0; i < 50; i++ {
+		s.mu.Lock()
+		pc, ln := s.pc, s.ln
+		s.mu.Unlock()
+		if pc != nil {
+			return pc.LocalAddr().String()
+		}
+		if ln != nil {
+			return ln.Addr().String()
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("source fails to listen")
+	return ""
+}
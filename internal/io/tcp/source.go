@@ -0,0 +1,177 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build tcp || !core
+
+package tcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/ndjson"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+type sourceConf struct {
+	// Addr is the listening address
+	Addr string `json:"addr"`
+	// MaxLineSize is the max size of a line in bytes, the longer line is handled by OversizedLine
+	MaxLineSize int `json:"maxLineSize"`
+	// OversizedLine is the policy of the longer line, error to send an error or skip to drop it
+	OversizedLine string `json:"oversizedLine"`
+}
+
+// source listens on a tcp address. Each connection sends the payloads separated by line breaks such as NDJSON
+type source struct {
+	c *sourceConf
+
+	mu    sync.Mutex
+	ln    net.Listener
+	conns map[net.Conn]struct{}
+}
+
+// Configure the source. The datasource is not used
+func (s *source) Configure(_ string, props map[string]interface{}) error {
+	c := &sourceConf{
+		Addr:        ":9000",
+		MaxLineSize: ndjson.DefaultMaxSize,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Addr == "" {
+		return fmt.Errorf("addr is required")
+	}
+	if _, err := ndjson.NewSplitter(c.MaxLineSize, c.OversizedLine); err != nil {
+		return err
+	}
+	s.c = c
+	return nil
+}
+
+func (s *source) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	if err := s.serve(ctx, consumer); err != nil {
+		infra.DrainError(ctx, err, errCh)
+	}
+}
+
+func (s *source) serve(ctx api.StreamContext, consumer chan<- api.SourceTuple) error {
+	logger := ctx.GetLogger()
+	ln, err := net.Listen("tcp", s.c.Addr)
+	if err != nil {
+		return fmt.Errorf("tcp source fails to listen %s: %v", s.c.Addr, err)
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.conns = make(map[net.Conn]struct{})
+	s.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		s.closeAll()
+	}()
+	logger.Infof("tcp source listens on %s", ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("tcp source fails to accept: %v", err)
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.handle(ctx, conn, consumer)
+	}
+}
+
+// handle reads the lines of a connection. A line may be split across the reads, and the last line without the line
+// break is sent when the connection is closed by the client
+func (s *source) handle(ctx api.StreamContext, conn net.Conn, consumer chan<- api.SourceTuple) {
+	logger := ctx.GetLogger()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+	remoteAddr := conn.RemoteAddr().String()
+	splitter, _ := ndjson.NewSplitter(s.c.MaxLineSize, s.c.OversizedLine)
+	err := ndjson.Read(conn, splitter, func(r ndjson.Record) bool {
+		for _, t := range s.getTuples(ctx, r, remoteAddr) {
+			select {
+			case consumer <- t:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil && ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+		logger.Warnf("tcp source closes the connection from %s: %v", remoteAddr, err)
+	}
+}
+
+func (s *source) getTuples(ctx api.StreamContext, r ndjson.Record, remoteAddr string) []api.SourceTuple {
+	if r.Err != nil {
+		return []api.SourceTuple{&xsql.ErrorSourceTuple{
+			Error:   fmt.Errorf("invalid line from %s: %v", remoteAddr, r.Err),
+			Payload: r.Data,
+		}}
+	}
+	rcvTime := conf.GetNow()
+	results, err := ctx.DecodeIntoList(r.Data)
+	if err != nil {
+		return []api.SourceTuple{&xsql.ErrorSourceTuple{
+			Error:   fmt.Errorf("Invalid data format, cannot decode %s with error %s", string(r.Data), err),
+			Payload: r.Data,
+		}}
+	}
+	meta := map[string]interface{}{
+		"remoteAddr": remoteAddr,
+	}
+	tuples := make([]api.SourceTuple, 0, len(results))
+	for _, result := range results {
+		tuples = append(tuples, api.NewDefaultSourceTupleWithTime(result, meta, rcvTime))
+	}
+	return tuples
+}
+
+func (s *source) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln != nil {
+		_ = s.ln.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+func (s *source) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing tcp source")
+	s.closeAll()
+	return nil
+}
+
+func GetSource() api.Source {
+	return &source{}
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tcp
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/converter"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"addr": ""},
+			err:   "addr is required",
+		}, {
+			props: map[string]interface{}{"oversizedLine": "truncate"},
+			err:   "invalid oversize policy truncate, must be error or skip",
+		}, {
+			props: map[string]interface{}{"addr": ":9001", "maxLineSize": 1024, "oversizedLine": "skip"},
+		},
+	}
+	for i, tt := range tests {
+		err := GetSource().Configure("", tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	s := GetSource().(*source)
+	if err := s.Configure("", map[string]interface{}{"addr": "127.0.0.1:0", "maxLineSize": 32}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := mockContext.NewMockContext("ruleTcp", "op1").WithCancel()
+	defer cancel()
+	cv, _ := converter.GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+	ctx = context.WithValue(ctx.(*context.DefaultContext), context.DecodeKey, cv)
+	consumer := make(chan api.SourceTuple)
+	errCh := make(chan error)
+	go s.Open(ctx, consumer, errCh)
+	addr := waitListening(t, s)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first record is split across the writes and the last one has no line break
+	for _, chunk := range []string{"{\"a\":", "1}\r\n{\"a\":\"" + strings.Repeat("x", 40) + "\"}\n", "{\"a\":2}"} {
+		if _, err := conn.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = conn.Close()
+	var result []interface{}
+	for i := 0; i < 3; i++ {
+		select {
+		case tuple := <-consumer:
+			if et, ok := tuple.(*xsql.ErrorSourceTuple); ok {
+				result = append(result, et.Error.Error())
+				continue
+			}
+			result = append(result, tuple.Message()["a"])
+			if tuple.Meta()["remoteAddr"] != conn.LocalAddr().String() {
+				t.Errorf("unexpected meta %v", tuple.Meta())
+			}
+		case err := <-errCh:
+			t.Fatalf("unexpected error %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("receive nothing after %v", result)
+		}
+	}
+	exp := []interface{}{float64(1), "invalid line from " + conn.LocalAddr().String() + ": line exceeds the max size 32", float64(2)}
+	if !reflect.DeepEqual(exp, result) {
+		t.Errorf("expect %v but got %v", exp, result)
+	}
+	_ = s.Close(ctx)
+}
+
+func waitListening(t *testing.T, s *source) string {
+	for i := 0; i < 50; i++ {
+		s.mu.Lock()
+		ln := s.ln
+		s.mu.Unlock()
+		if ln != nil {
+			return ln.Addr().String()
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatal("source fails to listen")
+	return ""
+}
@@ -0,0 +1,134 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build webhook || !core
+
+package webhook
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+// Delivery is a request to the webhook, it is saved in the store until it is finished
+type Delivery struct {
+	// Id is sent in the header so that the receiver can deduplicate the retried requests
+	Id       string
+	Method   string
+	Url      string
+	Headers  map[string]string
+	Body     []byte
+	Attempts int
+
+	seq int64
+	// nextAt is the time in milliseconds of the next attempt, the restored deliveries are retried immediately
+	nextAt int64
+	// retryAfter is the interval in milliseconds required by the server
+	retryAfter int64
+}
+
+// queue is the retry queue of the deliveries in order. Each delivery is saved in the store by the sequence key so
+// that the queue survives restarts
+type queue struct {
+	mu    sync.Mutex
+	store kv.KeyValue
+	items []*Delivery
+	seq   int64
+}
+
+// openQueue restores the queue of the sink instance. The store is dropped with the sink cache when the rule is deleted
+func openQueue(ctx api.StreamContext) (*queue, error) {
+	table := path.Join("sink", ctx.GetRuleId()+ctx.GetOpId()+strconv.Itoa(ctx.GetInstanceId())+"_webhook")
+	st, err := store.GetCacheKV(table)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open the retry queue: %v", err)
+	}
+	keys, err := st.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("fail to read the retry queue: %v", err)
+	}
+	q := &queue{store: st}
+	for _, k := range keys {
+		seq, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			continue
+		}
+		d := &Delivery{}
+		if ok, err := st.Get(k, d); err != nil || !ok {
+			ctx.GetLogger().Warnf("webhook sink drops the invalid delivery %s in the retry queue: %v", k, err)
+			_ = st.Delete(k)
+			continue
+		}
+		d.seq = seq
+		q.items = append(q.items, d)
+		if seq >= q.seq {
+			q.seq = seq + 1
+		}
+	}
+	sort.Slice(q.items, func(i, j int) bool {
+		return q.items[i].seq < q.items[j].seq
+	})
+	return q, nil
+}
+
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *queue) push(d *Delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	d.seq = q.seq
+	if err := q.store.Set(strconv.FormatInt(d.seq, 10), d); err != nil {
+		return fmt.Errorf("fail to save delivery %s: %v", d.Id, err)
+	}
+	q.seq++
+	q.items = append(q.items, d)
+	return nil
+}
+
+func (q *queue) peek() (*Delivery, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return nil, false
+	}
+	return q.items[0], true
+}
+
+// update saves the attempts of the head delivery
+func (q *queue) update(d *Delivery) error {
+	return q.store.Set(strconv.FormatInt(d.seq, 10), d)
+}
+
+func (q *queue) pop() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return nil
+	}
+	d := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	return q.store.Delete(strconv.FormatInt(d.seq, 10))
+}
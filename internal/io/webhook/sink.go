@@ -0,0 +1,388 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build webhook || !core
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/internal/pkg/cert"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const (
+	HeaderDelivery  = "X-Ekuiper-Delivery"
+	HeaderTimestamp = "X-Ekuiper-Timestamp"
+	HeaderAttempt   = "X-Ekuiper-Attempt"
+
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+	StatusDropped   = "dropped"
+)
+
+type sinkConf struct {
+	Url string `json:"url"`
+	// Method is the http method, it can be a data template
+	Method string `json:"method"`
+	// Headers are the request headers, the values can be data templates
+	Headers map[string]string `json:"headers"`
+	// Timeout is the timeout in milliseconds of each request
+	Timeout            int    `json:"timeout"`
+	CertificationPath  string `json:"certificationPath"`
+	PrivateKeyPath     string `json:"privateKeyPath"`
+	RootCaPath         string `json:"rootCaPath"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	// Secret is the key to sign the payload by HMAC-SHA256, the payload is not signed if it is not set
+	Secret          string `json:"secret"`
+	SignatureHeader string `json:"signatureHeader"`
+	// MaxRetries is the max number of retries of a delivery, 0 means retrying until delivered
+	MaxRetries int `json:"maxRetries"`
+	// RetryInterval is the initial backoff in milliseconds, it is multiplied by the BackoffFactor after each retry
+	RetryInterval    int     `json:"retryInterval"`
+	MaxRetryInterval int     `json:"maxRetryInterval"`
+	BackoffFactor    float64 `json:"backoffFactor"`
+	// MaxQueueSize is the max number of the deliveries in the retry queue
+	MaxQueueSize int `json:"maxQueueSize"`
+	// ReceiptTopic is the memory topic to publish the delivery receipts
+	ReceiptTopic string `json:"receiptTopic"`
+}
+
+type sink struct {
+	c   *sinkConf
+	cli *http.Client
+
+	// q is the retry queue which is persisted in the store
+	q      *queue
+	notify chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (s *sink) Configure(props map[string]interface{}) error {
+	c := &sinkConf{
+		Method:           http.MethodPost,
+		Timeout:          5000,
+		SignatureHeader:  "X-Ekuiper-Signature",
+		MaxRetries:       10,
+		RetryInterval:    1000,
+		MaxRetryInterval: 60000,
+		BackoffFactor:    2,
+		MaxQueueSize:     10000,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if c.Url == "" {
+		return fmt.Errorf("url is required")
+	}
+	if !strings.Contains(c.Url, "{{") {
+		if u, err := url.Parse(c.Url); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid url %s", c.Url)
+		}
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	if c.Secret != "" && c.SignatureHeader == "" {
+		return fmt.Errorf("signatureHeader is required to sign the payload")
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("maxRetries must not be negative")
+	}
+	if c.RetryInterval <= 0 || c.MaxRetryInterval < c.RetryInterval {
+		return fmt.Errorf("retryInterval must be positive and not larger than maxRetryInterval")
+	}
+	if c.BackoffFactor < 1 {
+		return fmt.Errorf("backoffFactor must not be less than 1")
+	}
+	if c.MaxQueueSize <= 0 {
+		return fmt.Errorf("maxQueueSize must be positive")
+	}
+	s.c = c
+	return nil
+}
+
+func (s *sink) Open(ctx api.StreamContext) error {
+	logger := ctx.GetLogger()
+	logger.Infof("opening webhook sink to %s", s.c.Url)
+	tlsConfig, err := cert.GenerateTLSForClient(cert.TlsConfigurationOptions{
+		SkipCertVerify: s.c.InsecureSkipVerify,
+		CertFile:       s.c.CertificationPath,
+		KeyFile:        s.c.PrivateKeyPath,
+		CaFile:         s.c.RootCaPath,
+	})
+	if err != nil {
+		return err
+	}
+	s.cli = &http.Client{
+		Timeout:   time.Duration(s.c.Timeout) * time.Millisecond,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	s.q, err = openQueue(ctx)
+	if err != nil {
+		return err
+	}
+	if n := s.q.len(); n > 0 {
+		logger.Infof("webhook sink restores %d deliveries to retry", n)
+	}
+	if s.c.ReceiptTopic != "" {
+		pubsub.CreatePub(s.c.ReceiptTopic)
+	}
+	s.notify = make(chan struct{}, 1)
+	wctx, cancel := ctx.WithCancel()
+	s.cancel = cancel
+	s.wg.Add(1)
+	go s.retry(wctx)
+	return nil
+}
+
+func (s *sink) Collect(ctx api.StreamContext, item interface{}) error {
+	logger := ctx.GetLogger()
+	d, err := s.newDelivery(ctx, item)
+	if err != nil {
+		return err
+	}
+	// keep the order by sending after the queued deliveries
+	if s.q.len() == 0 {
+		retryable, err := s.deliver(ctx, d)
+		if err == nil || !retryable {
+			return err
+		}
+		backoff := s.schedule(d)
+		logger.Warnf("webhook sink fails to deliver %s and will retry in %d ms: %v", d.Id, backoff, err)
+	}
+	if s.q.len() >= s.c.MaxQueueSize {
+		s.receipt(ctx, d, StatusDropped, 0, "the retry queue is full")
+		return fmt.Errorf("webhook sink drops delivery %s for the retry queue is full", d.Id)
+	}
+	if err := s.q.push(d); err != nil {
+		return err
+	}
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *sink) newDelivery(ctx api.StreamContext, item interface{}) (*Delivery, error) {
+	body, _, err := ctx.TransformOutput(item)
+	if err != nil {
+		return nil, err
+	}
+	u, err := ctx.ParseTemplate(s.c.Url, item)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse url: %v", err)
+	}
+	method, err := ctx.ParseTemplate(s.c.Method, item)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse method: %v", err)
+	}
+	headers := make(map[string]string, len(s.c.Headers))
+	for k, v := range s.c.Headers {
+		headers[k], err = ctx.ParseTemplate(v, item)
+		if err != nil {
+			return nil, fmt.Errorf("fail to parse header %s: %v", k, err)
+		}
+	}
+	return &Delivery{
+		Id:      uuid.NewString(),
+		Method:  strings.ToUpper(method),
+		Url:     u,
+		Headers: headers,
+		Body:    body,
+	}, nil
+}
+
+// deliver sends the delivery once and returns whether the error is retryable. The receipt is published if the
+// delivery is finished, that is, delivered, failed for a non-retryable error or failed for exceeding the max retries
+func (s *sink) deliver(ctx api.StreamContext, d *Delivery) (bool, error) {
+	d.Attempts++
+	code, retryAfter, err := s.send(ctx, d)
+	// the request is cancelled by closing, it will be retried after restarting
+	if ctx.Err() != nil {
+		return true, err
+	}
+	if err == nil {
+		s.receipt(ctx, d, StatusDelivered, code, "")
+		return false, nil
+	}
+	retryable := code == 0 || code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+	if retryable && (s.c.MaxRetries == 0 || d.Attempts <= s.c.MaxRetries) {
+		if retryAfter > 0 {
+			d.retryAfter = retryAfter
+		}
+		return true, err
+	}
+	s.receipt(ctx, d, StatusFailed, code, err.Error())
+	return false, fmt.Errorf("webhook sink fails to deliver %s after %d attempts: %v", d.Id, d.Attempts, err)
+}
+
+// send requests the webhook and returns the status code, which is 0 for the network error, and the Retry-After
+// in milliseconds if the server requires
+func (s *sink) send(ctx api.StreamContext, d *Delivery) (int, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, d.Method, d.Url, bytes.NewReader(d.Body))
+	if err != nil {
+		return http.StatusBadRequest, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range d.Headers {
+		req.Header.Set(k, v)
+	}
+	ts := strconv.FormatInt(conf.GetNowInMilli(), 10)
+	req.Header.Set(HeaderDelivery, d.Id)
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderAttempt, strconv.Itoa(d.Attempts))
+	if s.c.Secret != "" {
+		req.Header.Set(s.c.SignatureHeader, sign(s.c.Secret, ts, d.Body))
+	}
+	resp, err := s.cli.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var retryAfter int64
+		if v, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && v > 0 {
+			retryAfter = int64(v) * 1000
+		}
+		return resp.StatusCode, retryAfter, fmt.Errorf("status %d: %s", resp.StatusCode, b)
+	}
+	return resp.StatusCode, 0, nil
+}
+
+// sign signs the timestamp and the payload joined by a dot so that the receiver can reject the replayed requests
+func sign(secret string, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns the interval in milliseconds before the next attempt
+func (s *sink) backoff(attempts int) int64 {
+	b := float64(s.c.RetryInterval) * math.Pow(s.c.BackoffFactor, float64(attempts-1))
+	if b > float64(s.c.MaxRetryInterval) {
+		return int64(s.c.MaxRetryInterval)
+	}
+	return int64(b)
+}
+
+// schedule sets the time of the next attempt by the backoff or the Retry-After of the server if it is longer
+func (s *sink) schedule(d *Delivery) int64 {
+	backoff := s.backoff(d.Attempts)
+	if d.retryAfter > backoff {
+		backoff = d.retryAfter
+	}
+	d.retryAfter = 0
+	d.nextAt = conf.GetNowInMilli() + backoff
+	return backoff
+}
+
+// retry delivers the queued deliveries in order. A delivery blocks the following ones until it is finished
+func (s *sink) retry(ctx api.StreamContext) {
+	defer s.wg.Done()
+	logger := ctx.GetLogger()
+	for {
+		d, ok := s.q.peek()
+		if !ok {
+			select {
+			case <-s.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if wait := d.nextAt - conf.GetNowInMilli(); wait > 0 {
+			select {
+			case <-time.After(time.Duration(wait) * time.Millisecond):
+			case <-ctx.Done():
+				return
+			}
+		}
+		retryable, err := s.deliver(ctx, d)
+		if ctx.Err() != nil {
+			return
+		}
+		if retryable {
+			backoff := s.schedule(d)
+			logger.Warnf("webhook sink fails to deliver %s in attempt %d and will retry in %d ms: %v", d.Id, d.Attempts, backoff, err)
+			if err := s.q.update(d); err != nil {
+				logger.Errorf("webhook sink fails to save delivery %s: %v", d.Id, err)
+			}
+			continue
+		}
+		if err != nil {
+			logger.Errorf("%v", err)
+		}
+		if err := s.q.pop(); err != nil {
+			logger.Errorf("webhook sink fails to remove delivery %s: %v", d.Id, err)
+		}
+	}
+}
+
+// receipt publishes the result of the delivery to the receipt topic
+func (s *sink) receipt(ctx api.StreamContext, d *Delivery, status string, code int, errMsg string) {
+	ctx.GetLogger().Debugf("webhook sink delivery %s is %s with status code %d after %d attempts", d.Id, status, code, d.Attempts)
+	if s.c.ReceiptTopic == "" {
+		return
+	}
+	pubsub.Produce(ctx, s.c.ReceiptTopic, map[string]interface{}{
+		"deliveryId": d.Id,
+		"status":     status,
+		"statusCode": code,
+		"attempts":   d.Attempts,
+		"error":      errMsg,
+		"url":        d.Url,
+		"timestamp":  conf.GetNowInMilli(),
+	})
+}
+
+func (s *sink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing webhook sink")
+	if s.cancel != nil {
+		s.cancel()
+		s.wg.Wait()
+	}
+	if s.c.ReceiptTopic != "" {
+		pubsub.RemovePub(s.c.ReceiptTopic)
+	}
+	return nil
+}
+
+func GetSink() api.Sink {
+	return &sink{}
+}
@@ -0,0 +1,288 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build webhook || !core
+
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func init() {
+	testx.InitEnv()
+}
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{},
+			err:   "url is required",
+		}, {
+			props: map[string]interface{}{"url": "localhost"},
+			err:   "invalid url localhost",
+		}, {
+			props: map[string]interface{}{"url": "http://localhost", "timeout": 0},
+			err:   "timeout must be positive",
+		}, {
+			props: map[string]interface{}{"url": "http://localhost", "secret": "s", "signatureHeader": ""},
+			err:   "signatureHeader is required to sign the payload",
+		}, {
+			props: map[string]interface{}{"url": "http://localhost", "maxRetries": -1},
+			err:   "maxRetries must not be negative",
+		}, {
+			props: map[string]interface{}{"url": "http://localhost", "retryInterval": 2000, "maxRetryInterval": 1000},
+			err:   "retryInterval must be positive and not larger than maxRetryInterval",
+		}, {
+			props: map[string]interface{}{"url": "http://localhost", "backoffFactor": 0.5},
+			err:   "backoffFactor must not be less than 1",
+		}, {
+			props: map[string]interface{}{"url": "http://localhost", "maxQueueSize": 0},
+			err:   "maxQueueSize must be positive",
+		}, {
+			props: map[string]interface{}{"url": "http://{{.host}}/hook"},
+		},
+	}
+	for i, tt := range tests {
+		err := GetSink().Configure(tt.props)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%d: unexpected error %v", i, err)
+			}
+		} else if err == nil || err.Error() != tt.err {
+			t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	exp := "sha256=8c00d5274dec14b4692d5b878ac83cc157c0f9a7707c171fa1138f4f355fa78b"
+	if s := sign("secret", "1680000000000", []byte(`{"a":1}`)); s != exp {
+		t.Errorf("expect %s but got %s", exp, s)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	s := &sink{}
+	if err := s.Configure(map[string]interface{}{"url": "http://localhost", "retryInterval": 100, "maxRetryInterval": 1000, "backoffFactor": 3}); err != nil {
+		t.Fatal(err)
+	}
+	var r []int64
+	for i := 1; i <= 4; i++ {
+		r = append(r, s.backoff(i))
+	}
+	exp := []int64{100, 300, 900, 1000}
+	if !reflect.DeepEqual(r, exp) {
+		t.Errorf("expect %v but got %v", exp, r)
+	}
+}
+
+type request struct {
+	body    string
+	attempt string
+	id      string
+}
+
+// hookServer responds the status codes in order and then 200
+type hookServer struct {
+	sync.Mutex
+	*httptest.Server
+	codes    []int
+	requests []request
+	received chan struct{}
+}
+
+func newHookServer(t *testing.T, secret string, codes ...int) *hookServer {
+	h := &hookServer{codes: codes, received: make(chan struct{}, 100)}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if secret != "" && r.Header.Get("X-Ekuiper-Signature") != sign(secret, r.Header.Get(HeaderTimestamp), b) {
+			t.Errorf("invalid signature %s", r.Header.Get("X-Ekuiper-Signature"))
+		}
+		h.Lock()
+		h.requests = append(h.requests, request{body: string(b), attempt: r.Header.Get(HeaderAttempt), id: r.Header.Get(HeaderDelivery)})
+		code := http.StatusOK
+		if len(h.codes) > 0 {
+			code = h.codes[0]
+			h.codes = h.codes[1:]
+		}
+		h.Unlock()
+		w.WriteHeader(code)
+		h.received <- struct{}{}
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func (h *hookServer) wait(t *testing.T, n int) []request {
+	for i := 0; i < n; i++ {
+		select {
+		case <-h.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for request %d", i)
+		}
+	}
+	h.Lock()
+	defer h.Unlock()
+	return h.requests
+}
+
+func newSink(t *testing.T, rule string, props map[string]interface{}) (*sink, api.StreamContext) {
+	s := &sink{}
+	if err := s.Configure(props); err != nil {
+		t.Fatal(err)
+	}
+	ctx := mockContext.NewMockContext(rule, "op1")
+	tf, _ := transform.GenTransform("", "json", "", "", "", []string{})
+	vCtx := context.WithValue(ctx.(*context.DefaultContext), context.TransKey, tf)
+	if err := s.Open(vCtx); err != nil {
+		t.Fatal(err)
+	}
+	return s, vCtx
+}
+
+func receipts(t *testing.T, ch chan api.SourceTuple, n int) []map[string]interface{} {
+	var r []map[string]interface{}
+	for i := 0; i < n; i++ {
+		select {
+		case tuple := <-ch:
+			m := tuple.Message()
+			delete(m, "timestamp")
+			delete(m, "url")
+			r = append(r, m)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for receipt %d", i)
+		}
+	}
+	return r
+}
+
+func TestDeliver(t *testing.T) {
+	h := newHookServer(t, "secret", http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	ch := pubsub.CreateSub("receipt1", nil, "test1", 10)
+	s, ctx := newSink(t, "TestDeliver", map[string]interface{}{
+		"url":           h.URL,
+		"secret":        "secret",
+		"retryInterval": 10,
+		"receiptTopic":  "receipt1",
+	})
+	defer s.Close(ctx)
+	for i := 0; i < 2; i++ {
+		if err := s.Collect(ctx, map[string]interface{}{"a": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reqs := h.wait(t, 4)
+	exp := []request{
+		{body: `{"a":0}`, attempt: "1", id: reqs[0].id},
+		{body: `{"a":0}`, attempt: "2", id: reqs[0].id},
+		{body: `{"a":0}`, attempt: "3", id: reqs[0].id},
+		{body: `{"a":1}`, attempt: "1", id: reqs[3].id},
+	}
+	if !reflect.DeepEqual(reqs, exp) || reqs[0].id == reqs[3].id {
+		t.Errorf("expect requests %v but got %v", exp, reqs)
+	}
+	r := receipts(t, ch, 2)
+	expReceipts := []map[string]interface{}{
+		{"deliveryId": reqs[0].id, "status": StatusDelivered, "statusCode": 200, "attempts": 3, "error": ""},
+		{"deliveryId": reqs[3].id, "status": StatusDelivered, "statusCode": 200, "attempts": 1, "error": ""},
+	}
+	if !reflect.DeepEqual(r, expReceipts) {
+		t.Errorf("expect receipts %v but got %v", expReceipts, r)
+	}
+}
+
+func TestDeliverFail(t *testing.T) {
+	h := newHookServer(t, "", http.StatusBadRequest, http.StatusInternalServerError, http.StatusInternalServerError)
+	ch := pubsub.CreateSub("receipt2", nil, "test2", 10)
+	s, ctx := newSink(t, "TestDeliverFail", map[string]interface{}{
+		"url":           h.URL,
+		"retryInterval": 10,
+		"maxRetries":    1,
+		"receiptTopic":  "receipt2",
+	})
+	defer s.Close(ctx)
+	// the client error is not retried
+	err := s.Collect(ctx, map[string]interface{}{"a": 0})
+	if err == nil {
+		t.Errorf("expect error for the status 400")
+	}
+	if err := s.Collect(ctx, map[string]interface{}{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	h.wait(t, 3)
+	r := receipts(t, ch, 2)
+	if r[0]["status"] != StatusFailed || r[0]["statusCode"] != 400 || r[0]["attempts"] != 1 {
+		t.Errorf("invalid receipt %v", r[0])
+	}
+	if r[1]["status"] != StatusFailed || r[1]["statusCode"] != 500 || r[1]["attempts"] != 2 {
+		t.Errorf("invalid receipt %v", r[1])
+	}
+}
+
+func TestRestore(t *testing.T) {
+	h := newHookServer(t, "", http.StatusServiceUnavailable)
+	props := map[string]interface{}{
+		"url":           h.URL,
+		"retryInterval": 60000,
+	}
+	s, ctx := newSink(t, "TestRestore", props)
+	for i := 0; i < 3; i++ {
+		if err := s.Collect(ctx, map[string]interface{}{"a": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.wait(t, 1)
+	if n := s.q.len(); n != 3 {
+		t.Errorf("expect 3 deliveries in the queue but got %d", n)
+	}
+	if err := s.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the restored deliveries are retried immediately after restarting
+	s, ctx = newSink(t, "TestRestore", props)
+	defer s.Close(ctx)
+	reqs := h.wait(t, 3)
+	for i, r := range reqs[1:] {
+		if r.body != `{"a":`+strconv.Itoa(i)+`}` {
+			t.Errorf("expect request %d in order but got %s", i, r.body)
+		}
+	}
+	if reqs[1].id != reqs[0].id || reqs[1].attempt != "2" {
+		t.Errorf("expect the first delivery retried but got %v", reqs[1])
+	}
+	for i := 0; s.q.len() > 0 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := s.q.len(); n != 0 {
+		t.Errorf("expect the queue empty but got %d", n)
+	}
+}
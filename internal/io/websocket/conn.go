@@ -0,0 +1,400 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build websocket || !core
+
+package websocket
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/cert"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const (
+	ModeClient = "client"
+	ModeServer = "server"
+)
+
+// wsConf is the connection properties shared by the source and sink
+type wsConf struct {
+	// Mode is client to connect to a websocket server or server to serve the websocket clients
+	Mode string `json:"mode"`
+	// Url is the address of the websocket server in client mode such as ws://127.0.0.1:8080
+	Url string `json:"url"`
+	// Addr is the listening address of the embedded server in server mode such as :10081
+	Addr string `json:"addr"`
+	// Headers are sent in the handshake request in client mode
+	Headers map[string]string `json:"headers"`
+	// Timeout is the timeout of the handshake and each write in milliseconds
+	Timeout int `json:"timeout"`
+	// ReconnectInterval is the interval to reconnect in client mode in milliseconds
+	ReconnectInterval  int    `json:"reconnectInterval"`
+	CertificationPath  string `json:"certificationPath"`
+	PrivateKeyPath     string `json:"privateKeyPath"`
+	RootCaPath         string `json:"rootCaPath"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+
+	tlsConfig *tls.Config
+}
+
+func parseConf(props map[string]interface{}, path string) (*wsConf, error) {
+	c := &wsConf{
+		Mode:              ModeClient,
+		Timeout:           5000,
+		ReconnectInterval: 3000,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return nil, fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path must start with /")
+	}
+	switch c.Mode {
+	case ModeClient:
+		if !strings.HasPrefix(c.Url, "ws://") && !strings.HasPrefix(c.Url, "wss://") {
+			return nil, fmt.Errorf("url must start with ws:// or wss:// in client mode")
+		}
+		if strings.HasPrefix(c.Url, "wss://") {
+			tlsConfig, err := cert.GenerateTLSForClient(cert.TlsConfigurationOptions{
+				SkipCertVerify: c.InsecureSkipVerify,
+				CertFile:       c.CertificationPath,
+				KeyFile:        c.PrivateKeyPath,
+				CaFile:         c.RootCaPath,
+			})
+			if err != nil {
+				return nil, err
+			}
+			c.tlsConfig = tlsConfig
+		}
+	case ModeServer:
+		if c.Addr == "" {
+			return nil, fmt.Errorf("addr is required in server mode")
+		}
+		if (c.CertificationPath == "") != (c.PrivateKeyPath == "") {
+			return nil, fmt.Errorf("certificationPath and privateKeyPath must be both set to enable tls in server mode")
+		}
+	default:
+		return nil, fmt.Errorf("invalid mode %s, must be client or server", c.Mode)
+	}
+	if c.Timeout <= 0 {
+		return nil, fmt.Errorf("timeout must be positive")
+	}
+	if c.ReconnectInterval <= 0 {
+		return nil, fmt.Errorf("reconnectInterval must be positive")
+	}
+	return c, nil
+}
+
+func (c *wsConf) key(path string) string {
+	if c.Mode == ModeServer {
+		return c.Mode + "|" + c.Addr + path
+	}
+	return c.Mode + "|" + strings.TrimSuffix(c.Url, "/") + path
+}
+
+type frame struct {
+	messageType int
+	payload     []byte
+	remoteAddr  string
+	// closed notifies the connection is closed without payload
+	closed bool
+}
+
+type conn struct {
+	*ws.Conn
+	// gorilla websocket only supports one concurrent writer
+	wmu sync.Mutex
+}
+
+func (c *conn) write(messageType int, data []byte, timeout time.Duration) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.SetWriteDeadline(time.Now().Add(timeout))
+	return c.WriteMessage(messageType, data)
+}
+
+// endpoint manages the websocket connections of a path. The sources and sinks of the same endpoint share it,
+// so that the data is received and sent through the same connections
+type endpoint struct {
+	key  string
+	path string
+	c    *wsConf
+	// refCount is guarded by the global lock
+	refCount int
+	done     chan struct{}
+
+	mu    sync.RWMutex
+	conns map[*conn]struct{}
+	subs  map[string]chan *frame
+}
+
+// server is the embedded http server of an address, which may serve multiple endpoints
+type server struct {
+	addr string
+	srv  *http.Server
+	ln   net.Listener
+	// paths is guarded by the global lock
+	paths map[string]*endpoint
+}
+
+var (
+	lock      sync.Mutex
+	endpoints = make(map[string]*endpoint)
+	servers   = make(map[string]*server)
+	upgrader  = ws.Upgrader{
+		// allow the browsers of any origin to connect
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+)
+
+// acquire gets the shared endpoint or creates a new one. The properties of the first one take effect
+func acquire(c *wsConf, path string) (*endpoint, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	key := c.key(path)
+	if e, ok := endpoints[key]; ok {
+		e.refCount++
+		return e, nil
+	}
+	e := &endpoint{
+		key:      key,
+		path:     path,
+		c:        c,
+		refCount: 1,
+		done:     make(chan struct{}),
+		conns:    make(map[*conn]struct{}),
+		subs:     make(map[string]chan *frame),
+	}
+	if c.Mode == ModeServer {
+		s, ok := servers[c.Addr]
+		if !ok {
+			var err error
+			s, err = startServer(c)
+			if err != nil {
+				return nil, err
+			}
+			servers[c.Addr] = s
+		}
+		s.paths[path] = e
+	} else {
+		go e.run()
+	}
+	endpoints[key] = e
+	return e, nil
+}
+
+func release(e *endpoint) {
+	lock.Lock()
+	defer lock.Unlock()
+	e.refCount--
+	if e.refCount > 0 {
+		return
+	}
+	delete(endpoints, e.key)
+	close(e.done)
+	e.mu.Lock()
+	for c := range e.conns {
+		_ = c.Close()
+	}
+	e.mu.Unlock()
+	if e.c.Mode == ModeServer {
+		if s, ok := servers[e.c.Addr]; ok {
+			delete(s.paths, e.path)
+			if len(s.paths) == 0 {
+				conf.Log.Infof("shutting down websocket server %s", s.addr)
+				_ = s.srv.Close()
+				delete(servers, s.addr)
+			}
+		}
+	}
+}
+
+// startServer listens the address synchronously to report the error. Must run inside lock
+func startServer(c *wsConf) (*server, error) {
+	ln, err := net.Listen("tcp", c.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("websocket server fails to listen %s: %v", c.Addr, err)
+	}
+	s := &server{
+		addr:  c.Addr,
+		ln:    ln,
+		paths: make(map[string]*endpoint),
+	}
+	s.srv = &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: time.Duration(c.Timeout) * time.Millisecond,
+	}
+	go func() {
+		var err error
+		if c.CertificationPath != "" {
+			err = s.srv.ServeTLS(ln, c.CertificationPath, c.PrivateKeyPath)
+		} else {
+			err = s.srv.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			conf.Log.Errorf("websocket server %s error: %v", s.addr, err)
+		}
+	}()
+	conf.Log.Infof("serving websocket server on %s", ln.Addr())
+	return s, nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lock.Lock()
+	e, ok := s.paths[r.URL.Path]
+	lock.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	wc, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has replied the error
+		conf.Log.Warnf("websocket server fails to upgrade the request from %s: %v", r.RemoteAddr, err)
+		return
+	}
+	conf.Log.Infof("websocket client %s connected to %s", r.RemoteAddr, e.path)
+	e.serve(wc)
+}
+
+// run connects to the websocket server in client mode and reconnects if the connection is broken
+func (e *endpoint) run() {
+	dialer := &ws.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: time.Duration(e.c.Timeout) * time.Millisecond,
+		TLSClientConfig:  e.c.tlsConfig,
+	}
+	header := make(http.Header)
+	for k, v := range e.c.Headers {
+		header.Set(k, v)
+	}
+	u := strings.TrimSuffix(e.c.Url, "/") + e.path
+	for {
+		wc, _, err := dialer.Dial(u, header)
+		if err != nil {
+			conf.Log.Warnf("websocket client fails to connect %s: %v", u, err)
+		} else {
+			conf.Log.Infof("websocket client connected to %s", u)
+			e.serve(wc)
+		}
+		select {
+		case <-e.done:
+			return
+		case <-time.After(time.Duration(e.c.ReconnectInterval) * time.Millisecond):
+		}
+	}
+}
+
+// serve reads the frames of the connection until it is closed
+func (e *endpoint) serve(wc *ws.Conn) {
+	c := &conn{Conn: wc}
+	e.mu.Lock()
+	select {
+	case <-e.done:
+		e.mu.Unlock()
+		_ = wc.Close()
+		return
+	default:
+	}
+	e.conns[c] = struct{}{}
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.conns, c)
+		e.mu.Unlock()
+		_ = wc.Close()
+	}()
+	remoteAddr := wc.RemoteAddr().String()
+	defer e.dispatch(&frame{remoteAddr: remoteAddr, closed: true})
+	for {
+		mt, data, err := wc.ReadMessage()
+		if err != nil {
+			select {
+			case <-e.done:
+			default:
+				conf.Log.Warnf("websocket connection %s of %s is closed: %v", remoteAddr, e.path, err)
+			}
+			return
+		}
+		if mt != ws.TextMessage && mt != ws.BinaryMessage {
+			continue
+		}
+		e.dispatch(&frame{messageType: mt, payload: data, remoteAddr: remoteAddr})
+	}
+}
+
+// dispatch broadcasts the frame to all the subscribed sources. The frame is dropped if the source is busy
+func (e *endpoint) dispatch(f *frame) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for id, ch := range e.subs {
+		select {
+		case ch <- f:
+		default:
+			conf.Log.Errorf("websocket endpoint %s drop message to %s", e.path, id)
+		}
+	}
+}
+
+func (e *endpoint) subscribe(id string, bufferLength int) <-chan *frame {
+	ch := make(chan *frame, bufferLength)
+	e.mu.Lock()
+	e.subs[id] = ch
+	e.mu.Unlock()
+	return ch
+}
+
+func (e *endpoint) unsubscribe(id string) {
+	e.mu.Lock()
+	delete(e.subs, id)
+	e.mu.Unlock()
+}
+
+// broadcast writes the data to all the connections and returns the count of the connections written
+func (e *endpoint) broadcast(messageType int, data []byte) (int, error) {
+	e.mu.RLock()
+	conns := make([]*conn, 0, len(e.conns))
+	for c := range e.conns {
+		conns = append(conns, c)
+	}
+	e.mu.RUnlock()
+	var (
+		count int
+		errs  []string
+	)
+	for _, c := range conns {
+		if err := c.write(messageType, data, time.Duration(e.c.Timeout)*time.Millisecond); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", c.RemoteAddr(), err))
+			// the read loop will remove the connection
+			_ = c.Close()
+			continue
+		}
+		count++
+	}
+	if len(errs) > 0 {
+		return count, fmt.Errorf("fail to write to %s", strings.Join(errs, ", "))
+	}
+	return count, nil
+}
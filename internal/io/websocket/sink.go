@@ -0,0 +1,108 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build websocket || !core
+
+package websocket
+
+import (
+	"fmt"
+
+	ws "github.com/gorilla/websocket"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+type sinkConf struct {
+	// Path is the path of the websocket endpoint
+	Path string `json:"path"`
+	// MessageType is the frame type to send, text or binary
+	MessageType string `json:"messageType"`
+}
+
+type sink struct {
+	c           *wsConf
+	path        string
+	messageType int
+	e           *endpoint
+}
+
+func (s *sink) Configure(props map[string]interface{}) error {
+	sc := &sinkConf{MessageType: "text"}
+	if err := cast.MapToStruct(props, sc); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	c, err := parseConf(props, sc.Path)
+	if err != nil {
+		return err
+	}
+	switch sc.MessageType {
+	case "text":
+		s.messageType = ws.TextMessage
+	case "binary":
+		s.messageType = ws.BinaryMessage
+	default:
+		return fmt.Errorf("invalid messageType %s, must be text or binary", sc.MessageType)
+	}
+	s.c = c
+	s.path = sc.Path
+	return nil
+}
+
+func (s *sink) Open(ctx api.StreamContext) error {
+	e, err := acquire(s.c, s.path)
+	if err != nil {
+		return err
+	}
+	s.e = e
+	ctx.GetLogger().Infof("websocket sink publishes to %s", e.key)
+	return nil
+}
+
+func (s *sink) Collect(ctx api.StreamContext, item interface{}) error {
+	logger := ctx.GetLogger()
+	data, _, err := ctx.TransformOutput(item)
+	if err != nil {
+		return err
+	}
+	count, err := s.e.broadcast(s.messageType, data)
+	if err != nil {
+		return fmt.Errorf("%s: websocket sink %v", errorx.IOErr, err)
+	}
+	if count == 0 {
+		// the server has no client is normal, while the client must have a connection
+		if s.c.Mode == ModeClient {
+			return fmt.Errorf("%s: websocket sink is not connected to %s", errorx.IOErr, s.c.Url)
+		}
+		logger.Debugf("websocket sink has no client on %s, drop %s", s.path, data)
+		return nil
+	}
+	logger.Debugf("websocket sink sends %s to %d connections", data, count)
+	return nil
+}
+
+func (s *sink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing websocket sink")
+	if s.e != nil {
+		release(s.e)
+		s.e = nil
+	}
+	return nil
+}
+
+func GetSink() api.Sink {
+	return &sink{}
+}
@@ -0,0 +1,174 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build websocket || !core
+
+package websocket
+
+import (
+	"fmt"
+
+	ws "github.com/gorilla/websocket"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/ndjson"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+const (
+	// FramingMessage decodes each message as a payload
+	FramingMessage = "message"
+	// FramingLines decodes each line as a payload, the line can be split across the messages of a connection
+	FramingLines = "lines"
+)
+
+type sourceConf struct {
+	BufferLength  int    `json:"bufferLength"`
+	Framing       string `json:"framing"`
+	MaxLineSize   int    `json:"maxLineSize"`
+	OversizedLine string `json:"oversizedLine"`
+}
+
+type source struct {
+	c            *wsConf
+	path         string
+	bufferLength int
+	sc           *sourceConf
+}
+
+// Configure the source. The datasource is the path of the websocket endpoint
+func (s *source) Configure(datasource string, props map[string]interface{}) error {
+	c, err := parseConf(props, datasource)
+	if err != nil {
+		return err
+	}
+	sc := &sourceConf{BufferLength: 1024, Framing: FramingMessage}
+	if err := cast.MapToStruct(props, sc); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if sc.BufferLength <= 0 {
+		return fmt.Errorf("bufferLength must be positive")
+	}
+	switch sc.Framing {
+	case FramingMessage:
+	case FramingLines:
+		if _, err := ndjson.NewSplitter(sc.MaxLineSize, sc.OversizedLine); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid framing %s, must be message or lines", sc.Framing)
+	}
+	s.c = c
+	s.sc = sc
+	s.path = datasource
+	s.bufferLength = sc.BufferLength
+	return nil
+}
+
+func (s *source) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	e, err := acquire(s.c, s.path)
+	if err != nil {
+		infra.DrainError(ctx, err, errCh)
+		return
+	}
+	defer release(e)
+	id := fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId())
+	ch := e.subscribe(id, s.bufferLength)
+	defer e.unsubscribe(id)
+	logger.Infof("websocket source subscribes %s", e.key)
+	// the splitters of each connection to buffer the lines split across the messages
+	splitters := make(map[string]*ndjson.Splitter)
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infof("websocket source done")
+			return
+		case f := <-ch:
+			var tuples []api.SourceTuple
+			if s.sc.Framing == FramingLines {
+				sp, ok := splitters[f.remoteAddr]
+				if !ok {
+					sp, _ = ndjson.NewSplitter(s.sc.MaxLineSize, s.sc.OversizedLine)
+					splitters[f.remoteAddr] = sp
+				}
+				var records []ndjson.Record
+				if f.closed {
+					// the last line without line break is complete when the connection is closed
+					records = sp.Flush()
+					delete(splitters, f.remoteAddr)
+				} else {
+					records = sp.Feed(f.payload)
+				}
+				for _, r := range records {
+					if r.Err != nil {
+						tuples = append(tuples, &xsql.ErrorSourceTuple{
+							Error:   fmt.Errorf("invalid line from %s: %v", f.remoteAddr, r.Err),
+							Payload: r.Data,
+						})
+						continue
+					}
+					tuples = append(tuples, getTuples(ctx, s.path, &frame{messageType: f.messageType, payload: r.Data, remoteAddr: f.remoteAddr})...)
+				}
+			} else if !f.closed {
+				tuples = getTuples(ctx, s.path, f)
+			}
+			for _, t := range tuples {
+				select {
+				case consumer <- t:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+func getTuples(ctx api.StreamContext, path string, f *frame) []api.SourceTuple {
+	rcvTime := conf.GetNow()
+	results, err := ctx.DecodeIntoList(f.payload)
+	if err != nil {
+		return []api.SourceTuple{
+			&xsql.ErrorSourceTuple{
+				Error: fmt.Errorf("invalid data format, cannot decode %s with error %s", string(f.payload), err),
+			},
+		}
+	}
+	messageType := "text"
+	if f.messageType == ws.BinaryMessage {
+		messageType = "binary"
+	}
+	meta := map[string]interface{}{
+		"path":        path,
+		"remoteAddr":  f.remoteAddr,
+		"messageType": messageType,
+	}
+	tuples := make([]api.SourceTuple, 0, len(results))
+	for _, result := range results {
+		tuples = append(tuples, api.NewDefaultSourceTupleWithTime(result, meta, rcvTime))
+	}
+	return tuples
+}
+
+func (s *source) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("closing websocket source")
+	return nil
+}
+
+func GetSource() api.Source {
+	return &source{}
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package websocket
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+func TestSourceConfigure(t *testing.T) {
+	tests := []struct {
+		path  string
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			path:  "ws",
+			props: map[string]interface{}{"url": "ws://127.0.0.1:8080"},
+			err:   "path must start with /",
+		}, {
+			path:  "/ws",
+			props: map[string]interface{}{"url": "http://127.0.0.1:8080"},
+			err:   "url must start with ws:// or wss:// in client mode",
+		}, {
+			path:  "/ws",
+			props: map[string]interface{}{"mode": "server"},
+			err:   "addr is required in server mode",
+		}, {
+			path:  "/ws",
+			props: map[string]interface{}{"mode": "server", "addr": ":10081", "certificationPath": "cert.pem"},
+			err:   "certificationPath and privateKeyPath must be both set to enable tls in server mode",
+		}, {
+			path:  "/ws",
+			props: map[string]interface{}{"mode": "both"},
+			err:   "invalid mode both, must be client or server",
+		}, {
+			path:  "/ws",
+			props: map[string]interface{}{"url": "ws://127.0.0.1:8080", "timeout": 0},
+			err:   "timeout must be positive",
+		}, {
+			path:  "/ws",
+			props: map[string]interface{}{"url": "ws://127.0.0.1:8080", "reconnectInterval": -1},
+			err:   "reconnectInterval must be positive",
+		}, {
+			path:  "/ws",
+			props: map[string]interface{}{"url": "ws://127.0.0.1:8080", "bufferLength": 0},
+			err:   "bufferLength must be positive",
+		}, {
+			path:  "/ws",
+			props: map[string]interface{}{"url": "ws://127.0.0.1:8080", "framing": "json"},
+			err:   "invalid framing json, must be message or lines",
+		}, {
+			path:  "/ws",
+			props: map[string]interface{}{"url": "ws://127.0.0.1:8080", "framing": "lines", "oversizedLine": "truncate"},
+			err:   "invalid oversize policy truncate, must be error or skip",
+		}, {
+			path:  "/ws",
+			props: map[string]interface{}{"mode": "server", "addr": ":10081"},
+		}, {
+			path:  "/ws",
+			props: map[string]interface{}{"mode": "server", "addr": ":10081", "framing": "lines", "maxLineSize": 1024, "oversizedLine": "skip"},
+		},
+	}
+	for i, tt := range tests {
+		err := GetSource().Configure(tt.path, tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestSinkConfigure(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"url": "ws://127.0.0.1:8080"},
+			err:   "path must start with /",
+		}, {
+			props: map[string]interface{}{"url": "ws://127.0.0.1:8080", "path": "/ws", "messageType": "json"},
+			err:   "invalid messageType json, must be text or binary",
+		}, {
+			props: map[string]interface{}{"url": "wss://127.0.0.1:8080", "path": "/ws", "messageType": "binary", "insecureSkipVerify": true},
+		},
+	}
+	for i, tt := range tests {
+		err := GetSink().Configure(tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	sc, err := parseConf(map[string]interface{}{"mode": "server", "addr": "127.0.0.1:0"}, "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	se, err := acquire(sc, "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the source and sink of the same endpoint share it
+	se2, err := acquire(sc, "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if se != se2 || se.refCount != 2 {
+		t.Fatalf("expect shared endpoint with ref count 2")
+	}
+	release(se2)
+	addr := servers["127.0.0.1:0"].ln.Addr().String()
+
+	cc, err := parseConf(map[string]interface{}{"url": "ws://" + addr, "reconnectInterval": 100}, "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ce, err := acquire(cc, "/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCh := se.subscribe("server", 1)
+	clientCh := ce.subscribe("client", 1)
+
+	// wait for the client to connect
+	var count int
+	for i := 0; i < 50 && count == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+		count, err = ce.broadcast(ws.TextMessage, []byte(`{"a":1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count != 1 {
+		t.Fatalf("client fails to connect")
+	}
+	select {
+	case f := <-serverCh:
+		if f.messageType != ws.TextMessage || !bytes.Equal(f.payload, []byte(`{"a":1}`)) {
+			t.Errorf("server receives unexpected frame %v", f)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("server receives nothing")
+	}
+	count, err = se.broadcast(ws.BinaryMessage, []byte{0x01, 0x02})
+	if err != nil || count != 1 {
+		t.Fatalf("server broadcast to %d clients with error %v", count, err)
+	}
+	select {
+	case f := <-clientCh:
+		if f.messageType != ws.BinaryMessage || !bytes.Equal(f.payload, []byte{0x01, 0x02}) {
+			t.Errorf("client receives unexpected frame %v", f)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("client receives nothing")
+	}
+
+	release(ce)
+	release(se)
+	if len(endpoints) != 0 || len(servers) != 0 {
+		t.Errorf("expect all endpoints released but got %d endpoints and %d servers", len(endpoints), len(servers))
+	}
+}
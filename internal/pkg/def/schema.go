@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -19,9 +19,13 @@ type SchemaType string
 const (
 	PROTOBUF SchemaType = "protobuf"
 	CUSTOM   SchemaType = "custom"
+	AVRO     SchemaType = "avro"
+	BINARY   SchemaType = "binary"
 )
 
 var SchemaTypes = []SchemaType{
 	PROTOBUF,
 	CUSTOM,
+	AVRO,
+	BINARY,
 }
@@ -0,0 +1,243 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ndjson splits the newline delimited records such as NDJSON from a byte stream which is received in chunks.
+package ndjson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The policies to handle the line exceeding the max size
+const (
+	OversizeError = "error"
+	OversizeSkip  = "skip"
+)
+
+// DefaultMaxSize is the default max size of a line in bytes
+const DefaultMaxSize = 1024 * 1024
+
+type encoding int
+
+const (
+	encUnknown encoding = iota
+	encUTF8
+	encUTF16LE
+	encUTF16BE
+)
+
+// Record is a line without the line break. If the line exceeds the max size, Data is the head of the line and Err is
+// set
+type Record struct {
+	Data []byte
+	Err  error
+}
+
+// Splitter splits the stream into lines. The chunks can be fed in any size, the line split across the chunks is
+// buffered until its line break arrives. The encoding is detected by the BOM or the first bytes as RFC 4627, and the
+// UTF-16 stream is transcoded into UTF-8. It is not thread safe, each stream such as a connection has its own splitter.
+type Splitter struct {
+	maxSize int
+	policy  string
+
+	enc encoding
+	// head keeps the first bytes until the encoding is detected
+	head []byte
+	// pending keeps the odd byte and the high surrogate of UTF-16 split across the chunks
+	pending []byte
+	buf     []byte
+	// discarding is true when the current line is too long and the rest of it is dropped
+	discarding bool
+}
+
+func NewSplitter(maxSize int, policy string) (*Splitter, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	switch policy {
+	case "":
+		policy = OversizeError
+	case OversizeError, OversizeSkip:
+	default:
+		return nil, fmt.Errorf("invalid oversize policy %s, must be error or skip", policy)
+	}
+	return &Splitter{maxSize: maxSize, policy: policy}, nil
+}
+
+// Feed appends the chunk and returns the complete lines. The empty lines are ignored.
+func (s *Splitter) Feed(chunk []byte) []Record {
+	if s.enc == encUnknown {
+		s.head = append(s.head, chunk...)
+		if !s.detect(false) {
+			return nil
+		}
+		chunk, s.head = s.head, nil
+	}
+	return s.split(s.transcode(chunk), nil)
+}
+
+// Flush returns the last line without the line break at the end of the stream
+func (s *Splitter) Flush() []Record {
+	var result []Record
+	if s.enc == encUnknown {
+		s.detect(true)
+		chunk := s.head
+		s.head = nil
+		result = s.split(s.transcode(chunk), result)
+	}
+	if len(s.pending) > 0 {
+		// an incomplete UTF-16 unit at the end
+		s.buf = append(s.buf, string(utf8.RuneError)...)
+		s.pending = nil
+	}
+	if !s.discarding {
+		result = s.emit(s.buf, result)
+	}
+	s.buf = s.buf[:0]
+	s.discarding = false
+	return result
+}
+
+// detect detects the encoding by the BOM or the null bytes of the first two ASCII characters. It returns false if
+// more bytes are needed.
+func (s *Splitter) detect(eof bool) bool {
+	h := s.head
+	switch {
+	case bytes.HasPrefix(h, []byte{0xEF, 0xBB, 0xBF}):
+		s.enc, s.head = encUTF8, h[3:]
+	case bytes.HasPrefix(h, []byte{0xFF, 0xFE}):
+		s.enc, s.head = encUTF16LE, h[2:]
+	case bytes.HasPrefix(h, []byte{0xFE, 0xFF}):
+		s.enc, s.head = encUTF16BE, h[2:]
+	case !eof && (len(h) < 2 || (len(h) < 3 && h[0] == 0xEF)):
+		return false
+	case len(h) >= 2 && h[0] != 0 && h[1] == 0:
+		s.enc = encUTF16LE
+	case len(h) >= 2 && h[0] == 0 && h[1] != 0:
+		s.enc = encUTF16BE
+	default:
+		s.enc = encUTF8
+	}
+	return true
+}
+
+func (s *Splitter) transcode(chunk []byte) []byte {
+	if s.enc == encUTF8 {
+		return chunk
+	}
+	if len(s.pending) > 0 {
+		chunk = append(s.pending, chunk...)
+		s.pending = nil
+	}
+	n := len(chunk) / 2
+	units := make([]uint16, 0, n)
+	for i := 0; i < n; i++ {
+		if s.enc == encUTF16LE {
+			units = append(units, uint16(chunk[2*i])|uint16(chunk[2*i+1])<<8)
+		} else {
+			units = append(units, uint16(chunk[2*i])<<8|uint16(chunk[2*i+1]))
+		}
+	}
+	rest := chunk[2*n:]
+	// keep the high surrogate until its pair arrives
+	if n > 0 && utf16.IsSurrogate(rune(units[n-1])) && units[n-1] < 0xDC00 {
+		rest = chunk[2*(n-1):]
+		units = units[:n-1]
+	}
+	if len(rest) > 0 {
+		s.pending = append([]byte(nil), rest...)
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+func (s *Splitter) split(data []byte, result []Record) []Record {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			if !s.discarding {
+				s.buf = append(s.buf, data...)
+				result = s.checkSize(result)
+			}
+			return result
+		}
+		if !s.discarding {
+			s.buf = append(s.buf, data[:i]...)
+			result = s.checkSize(result)
+			if !s.discarding {
+				result = s.emit(s.buf, result)
+			}
+		}
+		s.discarding = false
+		s.buf = s.buf[:0]
+		data = data[i+1:]
+	}
+	return result
+}
+
+// checkSize drops the buffered line if it exceeds the max size, and the rest of the line is discarded
+func (s *Splitter) checkSize(result []Record) []Record {
+	if len(bytes.TrimSuffix(s.buf, []byte{'\r'})) <= s.maxSize {
+		return result
+	}
+	if s.policy == OversizeError {
+		head := make([]byte, s.maxSize)
+		copy(head, s.buf)
+		result = append(result, Record{Data: head, Err: fmt.Errorf("line exceeds the max size %d", s.maxSize)})
+	}
+	s.buf = s.buf[:0]
+	s.discarding = true
+	return result
+}
+
+func (s *Splitter) emit(line []byte, result []Record) []Record {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	if len(bytes.TrimSpace(line)) == 0 {
+		return result
+	}
+	data := make([]byte, len(line))
+	copy(data, line)
+	return append(result, Record{Data: data})
+}
+
+// Read reads the stream in chunks and calls the emit function for each line until the end of the stream or the emit
+// function returns false
+func Read(r io.Reader, s *Splitter, emit func(Record) bool) error {
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(chunk)
+		if n > 0 {
+			for _, rec := range s.Feed(chunk[:n]) {
+				if !emit(rec) {
+					return nil
+				}
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return err
+			}
+			for _, rec := range s.Flush() {
+				if !emit(rec) {
+					return nil
+				}
+			}
+			return nil
+		}
+	}
+}
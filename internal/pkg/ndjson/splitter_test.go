@@ -0,0 +1,162 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ndjson
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func lines(records []Record) []string {
+	result := make([]string, 0, len(records))
+	for _, r := range records {
+		if r.Err != nil {
+			result = append(result, "ERR:"+string(r.Data))
+		} else {
+			result = append(result, string(r.Data))
+		}
+	}
+	return result
+}
+
+func feedAll(s *Splitter, chunks ...[]byte) []Record {
+	var result []Record
+	for _, c := range chunks {
+		result = append(result, s.Feed(c)...)
+	}
+	return append(result, s.Flush()...)
+}
+
+func TestSplitChunks(t *testing.T) {
+	data := []byte("{\"a\":1}\n{\"a\":2}\r\n\n{\"b\":\"中文\"}\n{\"a\":3}")
+	exp := []string{`{"a":1}`, `{"a":2}`, `{"b":"中文"}`, `{"a":3}`}
+	// feed in all chunk sizes to split the records and the multibyte characters at any position
+	for size := 1; size <= len(data); size++ {
+		s, err := NewSplitter(0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var chunks [][]byte
+		for i := 0; i < len(data); i += size {
+			end := i + size
+			if end > len(data) {
+				end = len(data)
+			}
+			chunks = append(chunks, data[i:end])
+		}
+		if got := lines(feedAll(s, chunks...)); !reflect.DeepEqual(got, exp) {
+			t.Errorf("chunk size %d: expect %v but got %v", size, exp, got)
+		}
+	}
+}
+
+func TestOversize(t *testing.T) {
+	data := []byte("{\"a\":1}\n{\"long\":\"abcdefghijk\"}\n{\"a\":2}\n{\"last\":\"abcdefghijk\"}")
+	tests := []struct {
+		policy string
+		exp    []string
+	}{
+		{policy: OversizeError, exp: []string{`{"a":1}`, `ERR:{"long":"abcde`, `{"a":2}`, `ERR:{"last":"abcde`}},
+		{policy: OversizeSkip, exp: []string{`{"a":1}`, `{"a":2}`}},
+	}
+	for _, tt := range tests {
+		for _, size := range []int{3, 7, len(data)} {
+			s, err := NewSplitter(14, tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var chunks [][]byte
+			for i := 0; i < len(data); i += size {
+				end := i + size
+				if end > len(data) {
+					end = len(data)
+				}
+				chunks = append(chunks, data[i:end])
+			}
+			if got := lines(feedAll(s, chunks...)); !reflect.DeepEqual(got, tt.exp) {
+				t.Errorf("%s chunk size %d: expect %v but got %v", tt.policy, size, tt.exp, got)
+			}
+		}
+	}
+	if _, err := NewSplitter(10, "truncate"); err == nil || err.Error() != "invalid oversize policy truncate, must be error or skip" {
+		t.Errorf("expect policy error but got %v", err)
+	}
+}
+
+func encodeUTF16(s string, bigEndian bool, bom bool) []byte {
+	var b bytes.Buffer
+	if bom {
+		if bigEndian {
+			b.Write([]byte{0xFE, 0xFF})
+		} else {
+			b.Write([]byte{0xFF, 0xFE})
+		}
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			b.Write([]byte{byte(u >> 8), byte(u)})
+		} else {
+			b.Write([]byte{byte(u), byte(u >> 8)})
+		}
+	}
+	return b.Bytes()
+}
+
+func TestEncodingDetection(t *testing.T) {
+	text := "{\"emoji\":\"😀\"}\n{\"a\":1}\n"
+	exp := []string{`{"emoji":"😀"}`, `{"a":1}`}
+	inputs := map[string][]byte{
+		"utf8":           []byte(text),
+		"utf8 bom":       append([]byte{0xEF, 0xBB, 0xBF}, text...),
+		"utf16le bom":    encodeUTF16(text, false, true),
+		"utf16be bom":    encodeUTF16(text, true, true),
+		"utf16le no bom": encodeUTF16(text, false, false),
+		"utf16be no bom": encodeUTF16(text, true, false),
+	}
+	for name, data := range inputs {
+		for _, size := range []int{1, 3, len(data)} {
+			s, _ := NewSplitter(0, "")
+			var chunks [][]byte
+			for i := 0; i < len(data); i += size {
+				end := i + size
+				if end > len(data) {
+					end = len(data)
+				}
+				chunks = append(chunks, data[i:end])
+			}
+			if got := lines(feedAll(s, chunks...)); !reflect.DeepEqual(got, exp) {
+				t.Errorf("%s chunk size %d: expect %v but got %v", name, size, exp, got)
+			}
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	s, _ := NewSplitter(0, "")
+	var got []string
+	err := Read(strings.NewReader("{\"a\":1}\n{\"a\":2}\n{\"a\":3}"), s, func(r Record) bool {
+		got = append(got, string(r.Data))
+		return len(got) < 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{`{"a":1}`, `{"a":2}`}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("expect %v but got %v", exp, got)
+	}
+}
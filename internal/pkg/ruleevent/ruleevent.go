@@ -0,0 +1,173 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ruleevent publishes the rule lifecycle events so that the rule health can be monitored by eKuiper rules.
+package ruleevent
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// The event types
+const (
+	Started             = "started"
+	Stopped             = "stopped"
+	Restarting          = "restarting"
+	Exception           = "exception"
+	CheckpointCompleted = "checkpoint_completed"
+)
+
+type Event struct {
+	RuleId    string `json:"ruleId"`
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+	// Message is the error message of the exception event or the reason of the stopped event
+	Message string `json:"message,omitempty"`
+	// Attempt and Delay are the restart attempt and the delay in milliseconds of the restarting event
+	Attempt      int   `json:"attempt,omitempty"`
+	Delay        int   `json:"delay,omitempty"`
+	CheckpointId int64 `json:"checkpointId,omitempty"`
+}
+
+func (e *Event) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"ruleId":    e.RuleId,
+		"type":      e.Type,
+		"timestamp": e.Timestamp,
+	}
+	if e.Message != "" {
+		m["message"] = e.Message
+	}
+	if e.Attempt > 0 {
+		m["attempt"] = e.Attempt
+		m["delay"] = e.Delay
+	}
+	if e.CheckpointId > 0 {
+		m["checkpointId"] = e.CheckpointId
+	}
+	return m
+}
+
+type publisher struct {
+	topic     string
+	ctx       api.StreamContext
+	mqttTopic string
+	qos       byte
+	cli       api.MessageClient
+	// the events to publish to mqtt, drop the events if it is full to never block the rules
+	queue chan []byte
+	wg    sync.WaitGroup
+	// protect the queue from sending after closed
+	mu     sync.RWMutex
+	closed bool
+}
+
+var current atomic.Pointer[publisher]
+
+// Init starts to publish the events if enabled. The mqtt client is created by the registered client factory, so it
+// must be called after the client factory is initialized. The ctx is the background context to publish the events.
+func Init(c *conf.RuleEventConf, ctx api.StreamContext) {
+	if c == nil || !c.Enable {
+		return
+	}
+	p := &publisher{
+		topic: c.Topic,
+		ctx:   ctx,
+	}
+	pubsub.CreatePub(p.topic)
+	if c.MqttTopic != "" {
+		props := make(map[string]interface{}, len(c.MqttProps))
+		for k, v := range c.MqttProps {
+			props[k] = v
+		}
+		cli, err := clients.GetClient("mqtt", props)
+		if err != nil {
+			conf.Log.Warnf("cannot connect to mqtt to publish the rule events, only publish to memory topic %s: %v", p.topic, err)
+		} else {
+			p.cli = cli
+			p.mqttTopic = c.MqttTopic
+			p.qos = byte(c.MqttQos)
+			p.queue = make(chan []byte, 1024)
+			p.wg.Add(1)
+			go p.run()
+		}
+	}
+	if old := current.Swap(p); old != nil {
+		old.close()
+	}
+	conf.Log.Infof("publish the rule events to memory topic %s", p.topic)
+}
+
+// Close stops publishing the events
+func Close() {
+	if p := current.Swap(nil); p != nil {
+		p.close()
+	}
+}
+
+// Emit publishes the event of the rule. It never blocks.
+func Emit(e *Event) {
+	p := current.Load()
+	if p == nil {
+		return
+	}
+	if e.Timestamp == 0 {
+		e.Timestamp = conf.GetNowInMilli()
+	}
+	pubsub.Produce(p.ctx, p.topic, e.toMap())
+	if p.queue != nil {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return
+		}
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		if p.closed {
+			return
+		}
+		select {
+		case p.queue <- b:
+		default:
+			conf.Log.Warnf("rule event queue is full, drop %s event of rule %s", e.Type, e.RuleId)
+		}
+	}
+}
+
+func (p *publisher) run() {
+	defer p.wg.Done()
+	for b := range p.queue {
+		if err := p.cli.Publish(p.ctx, p.mqttTopic, b, map[string]interface{}{"qos": p.qos}); err != nil {
+			conf.Log.Warnf("publish rule event to mqtt topic %s error: %v", p.mqttTopic, err)
+		}
+	}
+}
+
+func (p *publisher) close() {
+	if p.queue != nil {
+		p.mu.Lock()
+		p.closed = true
+		close(p.queue)
+		p.mu.Unlock()
+		p.wg.Wait()
+		clients.ReleaseClient(p.ctx, p.cli)
+	}
+	pubsub.RemovePub(p.topic)
+}
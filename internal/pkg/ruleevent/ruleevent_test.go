@@ -0,0 +1,62 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruleevent_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/internal/pkg/ruleevent"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+)
+
+func TestEmit(t *testing.T) {
+	// Not enabled, should not panic
+	ruleevent.Emit(&ruleevent.Event{RuleId: "rule1", Type: ruleevent.Started})
+	c := &conf.RuleEventConf{Enable: true}
+	_ = c.Validate()
+	ruleevent.Init(c, context.Background())
+	defer ruleevent.Close()
+	ch := pubsub.CreateSub(c.Topic, nil, "testRuleEvent", 10)
+	defer pubsub.CloseSourceConsumerChannel(c.Topic, "testRuleEvent")
+	events := []*ruleevent.Event{
+		{RuleId: "rule1", Type: ruleevent.Started, Timestamp: 1},
+		{RuleId: "rule1", Type: ruleevent.Exception, Timestamp: 2, Message: "connection lost"},
+		{RuleId: "rule1", Type: ruleevent.Restarting, Timestamp: 3, Attempt: 1, Delay: 1000},
+		{RuleId: "rule1", Type: ruleevent.CheckpointCompleted, Timestamp: 4, CheckpointId: 100},
+	}
+	exp := []map[string]interface{}{
+		{"ruleId": "rule1", "type": "started", "timestamp": int64(1)},
+		{"ruleId": "rule1", "type": "exception", "timestamp": int64(2), "message": "connection lost"},
+		{"ruleId": "rule1", "type": "restarting", "timestamp": int64(3), "attempt": 1, "delay": 1000},
+		{"ruleId": "rule1", "type": "checkpoint_completed", "timestamp": int64(4), "checkpointId": int64(100)},
+	}
+	for _, e := range events {
+		ruleevent.Emit(e)
+	}
+	for i, e := range exp {
+		select {
+		case r := <-ch:
+			if !reflect.DeepEqual(e, r.Message()) {
+				t.Errorf("%d event mismatch\nexp=%v\ngot=%v", i, e, r.Message())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%d event not received", i)
+		}
+	}
+}
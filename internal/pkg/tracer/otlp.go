@@ -0,0 +1,232 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+const statusError = 2
+
+// exporter sends the spans in batch to the OTLP/HTTP receiver with the JSON encoding. The spans are dropped if the
+// queue is full to never block the rules.
+type exporter struct {
+	url         string
+	serviceName string
+	client      *http.Client
+	batchSize   int
+	interval    time.Duration
+	spans       chan *Span
+	done        chan struct{}
+	wg          sync.WaitGroup
+	closeOnce   sync.Once
+}
+
+func newExporter(c *conf.TracerConf, serviceName string) *exporter {
+	e := &exporter{
+		url:         strings.TrimSuffix(c.Endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		batchSize:   c.BatchSize,
+		interval:    time.Duration(c.ExportInterval) * time.Millisecond,
+		spans:       make(chan *Span, c.BatchSize*4),
+		done:        make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.run()
+	return e
+}
+
+func (e *exporter) add(s *Span) {
+	select {
+	case e.spans <- s:
+	default:
+		conf.Log.Debugf("drop span %s because the export queue is full", s.name)
+	}
+}
+
+func (e *exporter) run() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, e.batchSize)
+	for {
+		select {
+		case s := <-e.spans:
+			batch = append(batch, s)
+			if len(batch) >= e.batchSize {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-e.done:
+			for {
+				select {
+				case s := <-e.spans:
+					batch = append(batch, s)
+				default:
+					if len(batch) > 0 {
+						e.export(batch)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *exporter) close() {
+	e.closeOnce.Do(func() {
+		close(e.done)
+	})
+	e.wg.Wait()
+}
+
+func (e *exporter) export(spans []*Span) {
+	body, err := json.Marshal(e.toRequest(spans))
+	if err != nil {
+		conf.Log.Warnf("encode spans error: %v", err)
+		return
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		conf.Log.Warnf("export %d spans to %s error: %v", len(spans), e.url, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(resp.Body)
+		conf.Log.Warnf("export %d spans to %s error: %s %s", len(spans), e.url, resp.Status, msg)
+	}
+}
+
+// The OTLP JSON encoding of the trace service request
+
+type otlpRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceId           string      `json:"traceId"`
+	SpanId            string      `json:"spanId"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []keyValue  `json:"attributes,omitempty"`
+	Status            *spanStatus `json:"status,omitempty"`
+}
+
+type spanStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *exporter) toRequest(spans []*Span) *otlpRequest {
+	result := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		span := otlpSpan{
+			TraceId:           hex.EncodeToString(s.traceId[:]),
+			SpanId:            hex.EncodeToString(s.spanId[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		for _, a := range s.attrs {
+			span.Attributes = append(span.Attributes, keyValue{Key: a.key, Value: toAnyValue(a.value)})
+		}
+		if s.err != "" {
+			span.Status = &spanStatus{Code: statusError, Message: s.err}
+		}
+		result[i] = span
+	}
+	return &otlpRequest{
+		ResourceSpans: []resourceSpans{{
+			Resource: resource{
+				Attributes: []keyValue{{Key: "service.name", Value: toAnyValue(e.serviceName)}},
+			},
+			ScopeSpans: []scopeSpans{{
+				Scope: scope{Name: "github.com/lf-edge/ekuiper"},
+				Spans: result,
+			}},
+		}},
+	}
+}
+
+func toAnyValue(v interface{}) anyValue {
+	switch t := v.(type) {
+	case string:
+		return anyValue{StringValue: &t}
+	case bool:
+		return anyValue{BoolValue: &t}
+	case int:
+		s := strconv.Itoa(t)
+		return anyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(t, 10)
+		return anyValue{IntValue: &s}
+	case float64:
+		return anyValue{DoubleValue: &t}
+	default:
+		s := fmt.Sprintf("%v", t)
+		return anyValue{StringValue: &s}
+	}
+}
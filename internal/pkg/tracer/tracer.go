@@ -0,0 +1,136 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracer records the spans of the rule operations and exports them by OpenTelemetry protocol over HTTP,
+// so that a slow stage of a rule can be found in the tracing backends such as Jaeger and Tempo.
+package tracer
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+// The span kinds defined by OpenTelemetry
+const (
+	KindInternal = 1
+	KindProducer = 4
+	KindConsumer = 5
+)
+
+// Span is an operation of a rule such as decoding a payload, applying an operator or sending to a sink.
+// A nil span is a no-op, so the callers do not need to check whether the tracer is enabled.
+type Span struct {
+	tracer  *tracer
+	traceId [16]byte
+	spanId  [8]byte
+	name    string
+	kind    int
+	start   time.Time
+	end     time.Time
+	attrs   []attribute
+	err     string
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+type tracer struct {
+	serviceName string
+	ratio       float64
+	exporter    *exporter
+}
+
+var current atomic.Pointer[tracer]
+
+// Init starts to export the spans if the tracer is enabled
+func Init(c *conf.TracerConf) {
+	if c == nil || !c.Enable {
+		return
+	}
+	t := &tracer{
+		serviceName: c.ServiceName,
+		ratio:       c.SamplingRatio,
+	}
+	t.exporter = newExporter(c, t.serviceName)
+	if old := current.Swap(t); old != nil {
+		old.exporter.close()
+	}
+	conf.Log.Infof("export the spans to %s", t.exporter.url)
+}
+
+// Shutdown exports the pending spans and stops the tracer
+func Shutdown() {
+	if t := current.Swap(nil); t != nil {
+		t.exporter.close()
+	}
+}
+
+// Enabled returns whether the spans are recorded
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// Start records a span if the tracer is enabled and the operation is sampled, otherwise it returns nil
+func Start(kind int, name string) *Span {
+	t := current.Load()
+	if t == nil || (t.ratio < 1 && rand.Float64() >= t.ratio) {
+		return nil
+	}
+	s := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+	}
+	putUint64(s.traceId[:8], rand.Uint64())
+	putUint64(s.traceId[8:], rand.Uint64())
+	putUint64(s.spanId[:], rand.Uint64())
+	return s
+}
+
+// SetAttribute sets the attribute whose value is a string, bool, integer or float
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, attribute{key: key, value: value})
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.err = err.Error()
+}
+
+// End finishes the span and queues it to export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.tracer.exporter.add(s)
+}
+
+func putUint64(b []byte, v uint64) {
+	for i := 0; i < 8; i++ {
+		b[i] = byte(v >> (56 - 8*i))
+	}
+}
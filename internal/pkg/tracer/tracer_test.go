@@ -0,0 +1,115 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+func TestExport(t *testing.T) {
+	received := make(chan *otlpRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		req := &otlpRequest{}
+		if err := json.Unmarshal(body, req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- req
+	}))
+	defer server.Close()
+
+	if Enabled() || Start(KindInternal, "noop") != nil {
+		t.Fatal("the tracer should be disabled before init")
+	}
+	c := &conf.TracerConf{Enable: true, Endpoint: server.URL + "/"}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	Init(c)
+	s := Start(KindProducer, "sink log")
+	s.SetAttribute("rule.id", "rule1")
+	s.SetAttribute("tuple.count", 3)
+	s.SetError(errors.New("sink error"))
+	s.End()
+	s = Start(KindInternal, "op project")
+	s.SetAttribute("ok", true)
+	s.End()
+	Shutdown()
+
+	if Enabled() {
+		t.Error("the tracer should be disabled after shutdown")
+	}
+	var req *otlpRequest
+	select {
+	case req = <-received:
+	default:
+		t.Fatal("no spans are exported")
+	}
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("invalid request %v", req)
+	}
+	if v := req.ResourceSpans[0].Resource.Attributes[0]; v.Key != "service.name" || *v.Value.StringValue != "ekuiper" {
+		t.Errorf("invalid service name %v", v)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expect 2 spans but got %d", len(spans))
+	}
+	for _, s := range spans {
+		if len(s.TraceId) != 32 || len(s.SpanId) != 16 || s.StartTimeUnixNano > s.EndTimeUnixNano {
+			t.Errorf("invalid span %v", s)
+		}
+	}
+	rule, count, ok := "rule1", "3", true
+	exp := []keyValue{{Key: "rule.id", Value: anyValue{StringValue: &rule}}, {Key: "tuple.count", Value: anyValue{IntValue: &count}}}
+	if spans[0].Name != "sink log" || spans[0].Kind != KindProducer || !reflect.DeepEqual(spans[0].Attributes, exp) {
+		t.Errorf("span mismatch, got %v", spans[0])
+	}
+	if spans[0].Status == nil || spans[0].Status.Code != statusError || spans[0].Status.Message != "sink error" {
+		t.Errorf("status mismatch, got %v", spans[0].Status)
+	}
+	exp = []keyValue{{Key: "ok", Value: anyValue{BoolValue: &ok}}}
+	if spans[1].Name != "op project" || spans[1].Status != nil || !reflect.DeepEqual(spans[1].Attributes, exp) {
+		t.Errorf("span mismatch, got %v", spans[1])
+	}
+}
+
+func TestSampling(t *testing.T) {
+	c := &conf.TracerConf{Enable: true, Endpoint: "http://127.0.0.1:4318", SamplingRatio: 0.000001, BatchSize: 1, ExportInterval: 60000}
+	Init(c)
+	defer Shutdown()
+	n := 0
+	for i := 0; i < 100; i++ {
+		if Start(KindInternal, "op") != nil {
+			n++
+		}
+	}
+	if n > 1 {
+		t.Errorf("expect almost no sampled spans but got %d", n)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+// AuditRecord is a management api call. The before and after are the definitions of the resource before and after
+// the call, such as the rule json or the stream sql.
+type AuditRecord struct {
+	Id         string `json:"id"`
+	Timestamp  int64  `json:"timestamp"`
+	User       string `json:"user"`
+	RemoteAddr string `json:"remoteAddr"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	// Resource is the type of the resource like rule, stream, table and plugin
+	Resource string `json:"resource"`
+	Name     string `json:"name,omitempty"`
+	// Action is create, update, delete or the operation like start and stop
+	Action string `json:"action"`
+	Status int    `json:"status"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+	// Error is the response of the failed call
+	Error string `json:"error,omitempty"`
+}
+
+// AuditFilter filters the records by the non-empty fields. From and To are the unix milli time range.
+type AuditFilter struct {
+	User     string
+	Resource string
+	Name     string
+	Action   string
+	From     int64
+	To       int64
+	Limit    int
+}
+
+func (f *AuditFilter) match(r *AuditRecord) bool {
+	return (f.User == "" || f.User == r.User) &&
+		(f.Resource == "" || f.Resource == r.Resource) &&
+		(f.Name == "" || f.Name == r.Name) &&
+		(f.Action == "" || f.Action == r.Action) &&
+		(f.From <= 0 || r.Timestamp >= f.From) &&
+		(f.To <= 0 || r.Timestamp <= f.To)
+}
+
+type AuditProcessor struct {
+	sync.Mutex
+	db         kv.KeyValue
+	maxRecords int
+	seq        int64
+}
+
+func NewAuditProcessor(maxRecords int) *AuditProcessor {
+	db, err := store.GetKV("audit")
+	if err != nil {
+		panic(fmt.Sprintf("Can not initialize store for the audit processor at path 'audit': %v", err))
+	}
+	return &AuditProcessor{
+		db:         db,
+		maxRecords: maxRecords,
+	}
+}
+
+// Save assigns the id of the record and saves it. The oldest records are dropped if exceeding the max records.
+func (p *AuditProcessor) Save(r *AuditRecord) error {
+	p.Lock()
+	defer p.Unlock()
+	if r.Timestamp == 0 {
+		r.Timestamp = conf.GetNowInMilli()
+	}
+	p.seq = (p.seq + 1) % 1000000
+	// the id is sortable by time
+	r.Id = fmt.Sprintf("%013d%06d", r.Timestamp, p.seq)
+	s, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := p.db.Set(r.Id, string(s)); err != nil {
+		return fmt.Errorf("save audit record error: %v", err)
+	}
+	keys, err := p.db.Keys()
+	if err != nil {
+		return err
+	}
+	if len(keys) > p.maxRecords {
+		sort.Strings(keys)
+		for _, k := range keys[:len(keys)-p.maxRecords] {
+			_ = p.db.Delete(k)
+		}
+	}
+	return nil
+}
+
+// Query returns the matched records from the latest to the oldest
+func (p *AuditProcessor) Query(f *AuditFilter) ([]*AuditRecord, error) {
+	all, err := p.db.All()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		keys = append(keys, k)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	result := make([]*AuditRecord, 0)
+	for _, k := range keys {
+		r := &AuditRecord{}
+		if err := json.Unmarshal([]byte(all[k]), r); err != nil {
+			conf.Log.Warnf("invalid audit record %s: %v", k, err)
+			continue
+		}
+		if !f.match(r) {
+			continue
+		}
+		result = append(result, r)
+		if f.Limit > 0 && len(result) >= f.Limit {
+			break
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	p := NewAuditProcessor(3)
+	defer p.db.Clean()
+	records := []*AuditRecord{
+		{Timestamp: 1000, User: "admin", Resource: "rule", Name: "rule1", Action: "create", Status: 201, After: "{}"},
+		{Timestamp: 2000, User: "admin", Resource: "stream", Name: "demo", Action: "delete", Status: 200, Before: "CREATE STREAM demo"},
+		{Timestamp: 3000, User: "dev", Resource: "rule", Name: "rule1", Action: "stop", Status: 200},
+		{Timestamp: 3000, User: "dev", Resource: "rule", Name: "rule1", Action: "start", Status: 200},
+	}
+	for _, r := range records {
+		if err := p.Save(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		filter *AuditFilter
+		result []*AuditRecord
+	}{
+		{
+			filter: &AuditFilter{},
+			result: []*AuditRecord{records[3], records[2], records[1]},
+		}, {
+			filter: &AuditFilter{Resource: "rule", Name: "rule1"},
+			result: []*AuditRecord{records[3], records[2]},
+		}, {
+			filter: &AuditFilter{User: "admin"},
+			result: []*AuditRecord{records[1]},
+		}, {
+			filter: &AuditFilter{Limit: 1},
+			result: []*AuditRecord{records[3]},
+		}, {
+			filter: &AuditFilter{From: 1500, To: 2500},
+			result: []*AuditRecord{records[1]},
+		}, {
+			filter: &AuditFilter{Action: "update"},
+			result: []*AuditRecord{},
+		},
+	}
+	for i, tt := range tests {
+		r, err := p.Query(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tt.result, r) {
+			t.Errorf("%d result mismatch\nexp=%v\ngot=%v", i, tt.result, r)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+// Pipeline is a DAG of rules. Each stage is a rule, and a stage can select from the other stages by their names
+// like streams. The stages are connected by the memory topics which are created when deploying the pipeline.
+type Pipeline struct {
+	Id     string           `json:"id"`
+	Stages []*PipelineStage `json:"stages"`
+	// Triggered is whether to start the pipeline after creation, default to true
+	Triggered *bool `json:"triggered,omitempty"`
+	// Options are the default rule options of all stages
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+type PipelineStage struct {
+	Name    string                   `json:"name"`
+	Sql     string                   `json:"sql"`
+	Actions []map[string]interface{} `json:"actions,omitempty"`
+	// Options override the pipeline options for the stage rule
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// PipelinePlan is the deployment plan of a pipeline
+type PipelinePlan struct {
+	// Stages are sorted in topological order, so the upstream stages always come first
+	Stages []*StagePlan `json:"stages"`
+}
+
+type StagePlan struct {
+	Name   string `json:"name"`
+	RuleId string `json:"ruleId"`
+	// Inputs are the upstream stages, and Sources are the streams or tables outside the pipeline
+	Inputs  []string `json:"inputs,omitempty"`
+	Sources []string `json:"sources,omitempty"`
+	// Outputs are the downstream stages
+	Outputs []string `json:"outputs,omitempty"`
+	// Sinks are the actions of the stage named by the type and index, excluding the memory sink to the downstream stages
+	Sinks []string `json:"sinks,omitempty"`
+	// StreamSql creates the stream for the downstream stages to consume, it is empty for the leaf stages
+	StreamSql string `json:"-"`
+	RuleJson  string `json:"-"`
+}
+
+type PipelineProcessor struct {
+	db kv.KeyValue
+}
+
+func NewPipelineProcessor() *PipelineProcessor {
+	db, err := store.GetKV("pipeline")
+	if err != nil {
+		panic(fmt.Sprintf("Can not initialize store for the pipeline processor at path 'pipeline': %v", err))
+	}
+	return &PipelineProcessor{
+		db: db,
+	}
+}
+
+func (p *PipelineProcessor) ExecCreate(pl *Pipeline) error {
+	b, err := json.Marshal(pl)
+	if err != nil {
+		return fmt.Errorf("Marshal pipeline %s error : %s.", pl.Id, err)
+	}
+	err = p.db.Setnx(pl.Id, string(b))
+	if err != nil {
+		return err
+	}
+	log.Infof("Pipeline %s is created.", pl.Id)
+	return nil
+}
+
+func (p *PipelineProcessor) GetPipeline(id string) (*Pipeline, error) {
+	var s string
+	f, _ := p.db.Get(id, &s)
+	if !f {
+		return nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("Pipeline %s is not found.", id))
+	}
+	pl := &Pipeline{}
+	if err := json.Unmarshal([]byte(s), pl); err != nil {
+		return nil, fmt.Errorf("Parse pipeline %s error : %s.", s, err)
+	}
+	return pl, nil
+}
+
+func (p *PipelineProcessor) GetAllPipelines() ([]string, error) {
+	return p.db.Keys()
+}
+
+func (p *PipelineProcessor) ExecDrop(id string) error {
+	err := p.db.Delete(id)
+	if err != nil {
+		return err
+	}
+	log.Infof("Pipeline %s is dropped.", id)
+	return nil
+}
+
+// GetPipelineByJson parses and validates the pipeline json
+func GetPipelineByJson(id, pipelineJson string) (*Pipeline, error) {
+	pl := &Pipeline{}
+	if err := json.Unmarshal([]byte(pipelineJson), pl); err != nil {
+		return nil, fmt.Errorf("Parse pipeline %s error : %s.", pipelineJson, err)
+	}
+	if pl.Id == "" && id == "" {
+		return nil, fmt.Errorf("Missing pipeline id.")
+	}
+	if id != "" && pl.Id != "" && id != pl.Id {
+		return nil, fmt.Errorf("Pipeline id is not consistent with the id in the path.")
+	}
+	if pl.Id == "" {
+		pl.Id = id
+	}
+	if _, err := pl.Plan(); err != nil {
+		return nil, err
+	}
+	return pl, nil
+}
+
+// Plan validates the pipeline as a whole and generates the streams and rules to deploy
+func (pl *Pipeline) Plan() (*PipelinePlan, error) {
+	if len(pl.Stages) == 0 {
+		return nil, fmt.Errorf("Pipeline %s has no stages.", pl.Id)
+	}
+	stages := make(map[string]*StagePlan, len(pl.Stages))
+	for _, s := range pl.Stages {
+		if s.Name == "" {
+			return nil, fmt.Errorf("Pipeline %s has a stage without name.", pl.Id)
+		}
+		if _, ok := stages[s.Name]; ok {
+			return nil, fmt.Errorf("Pipeline %s has duplicate stage %s.", pl.Id, s.Name)
+		}
+		stages[s.Name] = &StagePlan{Name: s.Name, RuleId: pl.Id + "_" + s.Name}
+	}
+	for _, s := range pl.Stages {
+		stmt, err := xsql.GetStatementFromSql(s.Sql)
+		if err != nil {
+			return nil, fmt.Errorf("Stage %s has invalid sql: %s", s.Name, err)
+		}
+		sp := stages[s.Name]
+		for _, name := range xsql.GetStreams(stmt) {
+			if up, ok := stages[name]; ok {
+				if name == s.Name {
+					return nil, fmt.Errorf("Stage %s cannot select from itself.", s.Name)
+				}
+				sp.Inputs = append(sp.Inputs, name)
+				up.Outputs = append(up.Outputs, s.Name)
+			} else {
+				sp.Sources = append(sp.Sources, name)
+			}
+		}
+		for i, action := range s.Actions {
+			for k := range action {
+				sp.Sinks = append(sp.Sinks, fmt.Sprintf("%s_%d", k, i))
+			}
+		}
+	}
+	sorted, err := sortStages(pl.Stages, stages)
+	if err != nil {
+		return nil, fmt.Errorf("Pipeline %s is invalid: %s", pl.Id, err)
+	}
+	for i, sp := range sorted {
+		s := pl.Stages[indexOfStage(pl.Stages, sp.Name)]
+		actions := make([]map[string]interface{}, 0, len(s.Actions)+1)
+		actions = append(actions, s.Actions...)
+		if len(sp.Outputs) > 0 {
+			topic := fmt.Sprintf("pipeline/%s/%s", pl.Id, sp.Name)
+			actions = append(actions, map[string]interface{}{
+				"memory": map[string]interface{}{
+					"topic": topic,
+				},
+			})
+			sp.StreamSql = fmt.Sprintf(`CREATE STREAM %s () WITH (TYPE="memory", DATASOURCE="%s", FORMAT="JSON")`, sp.Name, topic)
+		} else if len(s.Actions) == 0 {
+			return nil, fmt.Errorf("Stage %s has no actions, the last stages of the pipeline must have actions.", sp.Name)
+		}
+		options := make(map[string]interface{}, len(pl.Options)+len(s.Options))
+		for k, v := range pl.Options {
+			options[k] = v
+		}
+		for k, v := range s.Options {
+			options[k] = v
+		}
+		r := map[string]interface{}{
+			"id":        sp.RuleId,
+			"name":      fmt.Sprintf("%s/%s", pl.Id, sp.Name),
+			"sql":       s.Sql,
+			"actions":   actions,
+			"options":   options,
+			"triggered": false,
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, fmt.Errorf("Marshal stage %s error : %s.", sp.Name, err)
+		}
+		sp.RuleJson = string(b)
+		sorted[i] = sp
+	}
+	return &PipelinePlan{Stages: sorted}, nil
+}
+
+// Topo returns the graph of the pipeline in the same format as the rule topo. The external streams are the sources,
+// and the nodes are prefixed by their kinds like "stage_" and "sink_"
+func (p *PipelinePlan) Topo() *api.PrintableTopo {
+	topo := &api.PrintableTopo{
+		Sources: make([]string, 0),
+		Edges:   make(map[string][]interface{}),
+	}
+	for _, sp := range p.Stages {
+		stageName := "stage_" + sp.Name
+		for _, src := range sp.Sources {
+			srcName := "source_" + src
+			if _, ok := topo.Edges[srcName]; !ok {
+				topo.Sources = append(topo.Sources, srcName)
+			}
+			topo.Edges[srcName] = append(topo.Edges[srcName], stageName)
+		}
+		for _, out := range sp.Outputs {
+			topo.Edges[stageName] = append(topo.Edges[stageName], "stage_"+out)
+		}
+		for _, sink := range sp.Sinks {
+			topo.Edges[stageName] = append(topo.Edges[stageName], fmt.Sprintf("sink_%s_%s", sp.Name, sink))
+		}
+	}
+	return topo
+}
+
+// sortStages sorts the stages in topological order and detects the cycles
+func sortStages(stages []*PipelineStage, plans map[string]*StagePlan) ([]*StagePlan, error) {
+	inDegrees := make(map[string]int, len(stages))
+	var queue []string
+	for _, s := range stages {
+		inDegrees[s.Name] = len(plans[s.Name].Inputs)
+		if inDegrees[s.Name] == 0 {
+			queue = append(queue, s.Name)
+		}
+	}
+	result := make([]*StagePlan, 0, len(stages))
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		result = append(result, plans[name])
+		for _, out := range plans[name].Outputs {
+			inDegrees[out]--
+			if inDegrees[out] == 0 {
+				queue = append(queue, out)
+			}
+		}
+	}
+	if len(result) != len(stages) {
+		return nil, fmt.Errorf("the stages have cycle")
+	}
+	return result, nil
+}
+
+func indexOfStage(stages []*PipelineStage, name string) int {
+	for i, s := range stages {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestPipelineValidation(t *testing.T) {
+	tests := []struct {
+		id   string
+		json string
+		err  error
+	}{
+		{
+			json: `{"stages":[{"name":"a","sql":"SELECT * FROM demo","actions":[{"log":{}}]}]}`,
+			err:  errors.New("Missing pipeline id."),
+		}, {
+			id:   "p1",
+			json: `{"id":"p2","stages":[{"name":"a","sql":"SELECT * FROM demo","actions":[{"log":{}}]}]}`,
+			err:  errors.New("Pipeline id is not consistent with the id in the path."),
+		}, {
+			id:   "p1",
+			json: `{"stages":[]}`,
+			err:  errors.New("Pipeline p1 has no stages."),
+		}, {
+			id:   "p1",
+			json: `{"stages":[{"name":"a","sql":"SELECT * FROM demo","actions":[{"log":{}}]},{"name":"a","sql":"SELECT * FROM demo","actions":[{"log":{}}]}]}`,
+			err:  errors.New("Pipeline p1 has duplicate stage a."),
+		}, {
+			id:   "p1",
+			json: `{"stages":[{"name":"a","sql":"SELECT * FROM a","actions":[{"log":{}}]}]}`,
+			err:  errors.New("Stage a cannot select from itself."),
+		}, {
+			id:   "p1",
+			json: `{"stages":[{"name":"a","sql":"SELECT * FROM b"},{"name":"b","sql":"SELECT * FROM a"},{"name":"c","sql":"SELECT * FROM b","actions":[{"log":{}}]}]}`,
+			err:  errors.New("Pipeline p1 is invalid: the stages have cycle"),
+		}, {
+			id:   "p1",
+			json: `{"stages":[{"name":"a","sql":"SELECT * FROM demo","actions":[{"log":{}}]},{"name":"b","sql":"SELECT * FROM a"}]}`,
+			err:  errors.New("Stage b has no actions, the last stages of the pipeline must have actions."),
+		}, {
+			id:   "p1",
+			json: `{"stages":[{"name":"a","sql":"SELECT * FROM demo"},{"name":"b","sql":"SELECT * FROM a","actions":[{"log":{}}]}]}`,
+		},
+	}
+	for i, tt := range tests {
+		_, err := GetPipelineByJson(tt.id, tt.json)
+		if !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("%d error mismatch,\ngot:\t%v \nwant:\t%v", i, err, tt.err)
+		}
+	}
+}
+
+func TestPipelinePlan(t *testing.T) {
+	pl, err := GetPipelineByJson("demoPipeline", `{
+		"stages": [
+			{"name": "alert", "sql": "SELECT * FROM cleaned WHERE temperature > 30", "actions": [{"mqtt": {"server": "tcp://127.0.0.1:1883", "topic": "alert"}}]},
+			{"name": "stat", "sql": "SELECT avg(temperature) AS t FROM cleaned GROUP BY TumblingWindow(ss, 10)", "actions": [{"log": {}}], "options": {"qos": 1}},
+			{"name": "cleaned", "sql": "SELECT temperature FROM demo WHERE isNull(temperature) = false"}
+		],
+		"options": {"isEventTime": false, "qos": 0}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := pl.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, sp := range plan.Stages {
+		names = append(names, sp.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"cleaned", "alert", "stat"}) {
+		t.Errorf("stage order mismatch, got %v", names)
+	}
+	cleaned := plan.Stages[0]
+	if cleaned.RuleId != "demoPipeline_cleaned" {
+		t.Errorf("rule id mismatch, got %s", cleaned.RuleId)
+	}
+	expStream := `CREATE STREAM cleaned () WITH (TYPE="memory", DATASOURCE="pipeline/demoPipeline/cleaned", FORMAT="JSON")`
+	if cleaned.StreamSql != expStream {
+		t.Errorf("stream sql mismatch,\ngot:\t%s \nwant:\t%s", cleaned.StreamSql, expStream)
+	}
+	if plan.Stages[1].StreamSql != "" || plan.Stages[2].StreamSql != "" {
+		t.Errorf("leaf stages should not create streams")
+	}
+	rules := []map[string]interface{}{
+		{
+			"id":        "demoPipeline_cleaned",
+			"name":      "demoPipeline/cleaned",
+			"sql":       "SELECT temperature FROM demo WHERE isNull(temperature) = false",
+			"actions":   []interface{}{map[string]interface{}{"memory": map[string]interface{}{"topic": "pipeline/demoPipeline/cleaned"}}},
+			"options":   map[string]interface{}{"isEventTime": false, "qos": float64(0)},
+			"triggered": false,
+		}, {
+			"id":        "demoPipeline_alert",
+			"name":      "demoPipeline/alert",
+			"sql":       "SELECT * FROM cleaned WHERE temperature > 30",
+			"actions":   []interface{}{map[string]interface{}{"mqtt": map[string]interface{}{"server": "tcp://127.0.0.1:1883", "topic": "alert"}}},
+			"options":   map[string]interface{}{"isEventTime": false, "qos": float64(0)},
+			"triggered": false,
+		}, {
+			"id":        "demoPipeline_stat",
+			"name":      "demoPipeline/stat",
+			"sql":       "SELECT avg(temperature) AS t FROM cleaned GROUP BY TumblingWindow(ss, 10)",
+			"actions":   []interface{}{map[string]interface{}{"log": map[string]interface{}{}}},
+			"options":   map[string]interface{}{"isEventTime": false, "qos": float64(1)},
+			"triggered": false,
+		},
+	}
+	for i, sp := range plan.Stages {
+		var r map[string]interface{}
+		if err := json.Unmarshal([]byte(sp.RuleJson), &r); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(r, rules[i]) {
+			t.Errorf("%d rule mismatch,\ngot:\t%v \nwant:\t%v", i, r, rules[i])
+		}
+	}
+	expTopo := &api.PrintableTopo{
+		Sources: []string{"source_demo"},
+		Edges: map[string][]interface{}{
+			"source_demo":   {"stage_cleaned"},
+			"stage_cleaned": {"stage_alert", "stage_stat"},
+			"stage_alert":   {"sink_alert_mqtt_0"},
+			"stage_stat":    {"sink_stat_log_0"},
+		},
+	}
+	if topo := plan.Topo(); !reflect.DeepEqual(topo, expTopo) {
+		t.Errorf("topo mismatch,\ngot:\t%v \nwant:\t%v", topo, expTopo)
+	}
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
@@ -28,8 +29,10 @@ import (
 )
 
 type RuleProcessor struct {
-	db           kv.KeyValue
-	ruleStatusDb kv.KeyValue
+	db            kv.KeyValue
+	ruleStatusDb  kv.KeyValue
+	ruleHistoryDb kv.KeyValue
+	savepointDb   kv.KeyValue
 }
 
 func NewRuleProcessor() *RuleProcessor {
@@ -41,9 +44,19 @@ func NewRuleProcessor() *RuleProcessor {
 	if err != nil {
 		panic(fmt.Sprintf("Can not initialize store for the rule processor at path 'rule': %v", err))
 	}
+	ruleHistoryDb, err := store.GetKV("ruleHistory")
+	if err != nil {
+		panic(fmt.Sprintf("Can not initialize store for the rule processor at path 'ruleHistory': %v", err))
+	}
+	savepointDb, err := store.GetKV("savepoint")
+	if err != nil {
+		panic(fmt.Sprintf("Can not initialize store for the rule processor at path 'savepoint': %v", err))
+	}
 	processor := &RuleProcessor{
-		db:           db,
-		ruleStatusDb: ruleStatusDb,
+		db:            db,
+		ruleStatusDb:  ruleStatusDb,
+		ruleHistoryDb: ruleHistoryDb,
+		savepointDb:   savepointDb,
 	}
 	return processor
 }
@@ -60,6 +73,7 @@ func (p *RuleProcessor) ExecCreateWithValidation(name, ruleJson string) (*api.Ru
 	} else {
 		log.Infof("Rule %s is created.", rule.Id)
 	}
+	p.recordVersion(rule.Id, ruleJson)
 
 	return rule, nil
 }
@@ -71,6 +85,7 @@ func (p *RuleProcessor) ExecCreate(name, ruleJson string) error {
 	} else {
 		log.Infof("Rule %s is created.", name)
 	}
+	p.recordVersion(name, ruleJson)
 
 	return nil
 }
@@ -81,12 +96,19 @@ func (p *RuleProcessor) ExecUpdate(name, ruleJson string) (*api.Rule, error) {
 		return nil, err
 	}
 
+	// The rules created before the history is introduced have no versions, save the current one as the first version
+	if versions, _ := p.getHistory(rule.Id); len(versions) == 0 {
+		if old, err := p.GetRuleJson(rule.Id); err == nil {
+			p.recordVersion(rule.Id, old)
+		}
+	}
 	err = p.db.Set(rule.Id, ruleJson)
 	if err != nil {
 		return nil, err
 	} else {
 		log.Infof("Rule %s is update.", rule.Id)
 	}
+	p.recordVersion(rule.Id, ruleJson)
 
 	return rule, nil
 }
@@ -209,11 +231,14 @@ func (p *RuleProcessor) GetRuleByJson(id, ruleJson string) (*api.Rule, error) {
 }
 
 func clone(opt api.RuleOption) *api.RuleOption {
-	return &api.RuleOption{
+	result := &api.RuleOption{
 		IsEventTime:        opt.IsEventTime,
 		LateTol:            opt.LateTol,
+		WatermarkByKey:     opt.WatermarkByKey,
+		IdleTimeout:        opt.IdleTimeout,
 		Concurrency:        opt.Concurrency,
 		BufferLength:       opt.BufferLength,
+		BackPressure:       opt.BackPressure,
 		SendMetaToSink:     opt.SendMetaToSink,
 		SendError:          opt.SendError,
 		Qos:                opt.Qos,
@@ -226,6 +251,25 @@ func clone(opt api.RuleOption) *api.RuleOption {
 			JitterFactor: opt.Restart.JitterFactor,
 		},
 	}
+	if opt.Quota != nil {
+		quota := *opt.Quota
+		result.Quota = &quota
+	}
+	if opt.AutoScale != nil {
+		autoScale := *opt.AutoScale
+		result.AutoScale = &autoScale
+	}
+	if opt.StateBackend != nil {
+		stateBackend := *opt.StateBackend
+		result.StateBackend = &stateBackend
+	}
+	if opt.DeadLetter != nil {
+		result.DeadLetter = make(map[string]interface{}, len(opt.DeadLetter))
+		for k, v := range opt.DeadLetter {
+			result.DeadLetter[k] = v
+		}
+	}
+	return result
 }
 
 func (p *RuleProcessor) ExecDesc(name string) (string, error) {
@@ -262,6 +306,9 @@ func (p *RuleProcessor) ExecDrop(name string) (string, error) {
 		}
 
 	}
+	if err := p.dropHistory(name); err != nil {
+		result = fmt.Sprintf("%s. Clean history faile: %s.", result, err)
+	}
 	err := p.db.Delete(name)
 	if err != nil {
 		return "", err
@@ -275,7 +322,7 @@ func cleanCheckpoint(name string) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return state.DropRemoteCheckpoint(name)
 }
 
 func cleanSinkCache(name string) error {
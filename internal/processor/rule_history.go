@@ -0,0 +1,214 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+// ruleHistorySize is the max versions to keep for each rule, the oldest versions are dropped when exceeded
+const ruleHistorySize = 20
+
+// RuleVersion is a version of the rule definition. A new version is saved whenever the rule is created or updated.
+type RuleVersion struct {
+	Version int `json:"version"`
+	// Timestamp is the unix milli time when the version is saved
+	Timestamp int64  `json:"timestamp"`
+	Rule      string `json:"rule,omitempty"`
+}
+
+// RuleChange is a difference between two rule versions. The path is the json path of the changed property like
+// "actions[0].mqtt.topic"
+type RuleChange struct {
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+const (
+	RuleChangeAdd     = "add"
+	RuleChangeRemove  = "remove"
+	RuleChangeReplace = "replace"
+)
+
+func (p *RuleProcessor) getHistory(id string) ([]*RuleVersion, error) {
+	var s string
+	f, _ := p.ruleHistoryDb.Get(id, &s)
+	if !f {
+		return nil, nil
+	}
+	var versions []*RuleVersion
+	if err := json.Unmarshal([]byte(s), &versions); err != nil {
+		return nil, fmt.Errorf("Parse rule %s history error : %s.", id, err)
+	}
+	return versions, nil
+}
+
+// saveVersion appends the rule definition as the latest version. Nothing is saved if it is the same as the latest one.
+func (p *RuleProcessor) saveVersion(id, ruleJson string) error {
+	versions, err := p.getHistory(id)
+	if err != nil {
+		return err
+	}
+	next := 1
+	if l := len(versions); l > 0 {
+		if versions[l-1].Rule == ruleJson {
+			return nil
+		}
+		next = versions[l-1].Version + 1
+	}
+	versions = append(versions, &RuleVersion{
+		Version:   next,
+		Timestamp: conf.GetNowInMilli(),
+		Rule:      ruleJson,
+	})
+	if len(versions) > ruleHistorySize {
+		versions = versions[len(versions)-ruleHistorySize:]
+	}
+	b, err := json.Marshal(versions)
+	if err != nil {
+		return fmt.Errorf("Marshal rule %s history error : %s.", id, err)
+	}
+	return p.ruleHistoryDb.Set(id, string(b))
+}
+
+// recordVersion saves the version after the rule is stored. The failure only affects the history, so just log it.
+func (p *RuleProcessor) recordVersion(id, ruleJson string) {
+	if err := p.saveVersion(id, ruleJson); err != nil {
+		log.Warnf("Save rule %s version error: %v", id, err)
+	}
+}
+
+func (p *RuleProcessor) dropHistory(id string) error {
+	if f, _ := p.ruleHistoryDb.Get(id, new(string)); !f {
+		return nil
+	}
+	return p.ruleHistoryDb.Delete(id)
+}
+
+// GetRuleVersions returns the versions of the rule without the definitions, from the oldest to the latest
+func (p *RuleProcessor) GetRuleVersions(id string) ([]*RuleVersion, error) {
+	versions, err := p.getHistory(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("Rule %s has no history.", id))
+	}
+	result := make([]*RuleVersion, len(versions))
+	for i, v := range versions {
+		result[i] = &RuleVersion{Version: v.Version, Timestamp: v.Timestamp}
+	}
+	return result, nil
+}
+
+// GetRuleVersion returns the rule definition of the version. The version 0 means the latest one.
+func (p *RuleProcessor) GetRuleVersion(id string, version int) (*RuleVersion, error) {
+	versions, err := p.getHistory(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > 0 && version == 0 {
+		return versions[len(versions)-1], nil
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("Rule %s version %d is not found.", id, version))
+}
+
+// DiffRuleVersions returns the changes from one version to another. The version 0 means the latest one.
+func (p *RuleProcessor) DiffRuleVersions(id string, from, to int) ([]*RuleChange, error) {
+	fv, err := p.GetRuleVersion(id, from)
+	if err != nil {
+		return nil, err
+	}
+	tv, err := p.GetRuleVersion(id, to)
+	if err != nil {
+		return nil, err
+	}
+	var fm, tm map[string]interface{}
+	if err := json.Unmarshal([]byte(fv.Rule), &fm); err != nil {
+		return nil, fmt.Errorf("Parse rule %s version %d error : %s.", id, fv.Version, err)
+	}
+	if err := json.Unmarshal([]byte(tv.Rule), &tm); err != nil {
+		return nil, fmt.Errorf("Parse rule %s version %d error : %s.", id, tv.Version, err)
+	}
+	changes := make([]*RuleChange, 0)
+	return diffValue("", fm, tm, changes), nil
+}
+
+func diffValue(path string, from, to interface{}, changes []*RuleChange) []*RuleChange {
+	switch ft := from.(type) {
+	case map[string]interface{}:
+		if tt, ok := to.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(ft)+len(tt))
+			for k := range ft {
+				keys = append(keys, k)
+			}
+			for k := range tt {
+				if _, ok := ft[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				fv, fok := ft[k]
+				tv, tok := tt[k]
+				switch {
+				case !fok:
+					changes = append(changes, &RuleChange{Op: RuleChangeAdd, Path: p, To: tv})
+				case !tok:
+					changes = append(changes, &RuleChange{Op: RuleChangeRemove, Path: p, From: fv})
+				default:
+					changes = diffValue(p, fv, tv, changes)
+				}
+			}
+			return changes
+		}
+	case []interface{}:
+		if tt, ok := to.([]interface{}); ok {
+			for i := 0; i < len(ft) || i < len(tt); i++ {
+				p := fmt.Sprintf("%s[%d]", path, i)
+				switch {
+				case i >= len(ft):
+					changes = append(changes, &RuleChange{Op: RuleChangeAdd, Path: p, To: tt[i]})
+				case i >= len(tt):
+					changes = append(changes, &RuleChange{Op: RuleChangeRemove, Path: p, From: ft[i]})
+				default:
+					changes = diffValue(p, ft[i], tt[i], changes)
+				}
+			}
+			return changes
+		}
+	}
+	if !reflect.DeepEqual(from, to) {
+		changes = append(changes, &RuleChange{Op: RuleChangeReplace, Path: path, From: from, To: to})
+	}
+	return changes
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRuleHistory(t *testing.T) {
+	p := NewRuleProcessor()
+	const id = "historyRule"
+	defer p.ExecDrop(id)
+	v1 := `{"id":"historyRule","sql":"SELECT * FROM demo","actions":[{"log":{}}]}`
+	v2 := `{"id":"historyRule","sql":"SELECT * FROM demo WHERE temperature > 20","actions":[{"log":{}},{"mqtt":{"server":"tcp://127.0.0.1:1883","topic":"demo"}}]}`
+	v3 := `{"id":"historyRule","sql":"SELECT * FROM demo","actions":[{"mqtt":{"server":"tcp://127.0.0.1:1883","topic":"demo2"}}],"options":{"qos":1}}`
+	if err := p.ExecCreate(id, v1); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []string{v2, v2, v3} {
+		if _, err := p.ExecUpdate(id, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions, err := p.GetRuleVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("expect 3 versions but got %d", len(versions))
+	}
+	for i, v := range versions {
+		if v.Version != i+1 || v.Rule != "" {
+			t.Errorf("%d version mismatch, got %v", i, v)
+		}
+	}
+	v, err := p.GetRuleVersion(id, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Rule != v2 {
+		t.Errorf("version 2 mismatch, got %s", v.Rule)
+	}
+	v, err = p.GetRuleVersion(id, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 3 || v.Rule != v3 {
+		t.Errorf("latest version mismatch, got %v", v)
+	}
+	_, err = p.GetRuleVersion(id, 4)
+	if !reflect.DeepEqual(err.Error(), "Rule historyRule version 4 is not found.") {
+		t.Errorf("error mismatch, got %v", err)
+	}
+	changes, err := p.DiffRuleVersions(id, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []*RuleChange{
+		{Op: RuleChangeRemove, Path: "actions[0].log", From: map[string]interface{}{}},
+		{Op: RuleChangeAdd, Path: "actions[0].mqtt", To: map[string]interface{}{"server": "tcp://127.0.0.1:1883", "topic": "demo2"}},
+		{Op: RuleChangeRemove, Path: "actions[1]", From: map[string]interface{}{"mqtt": map[string]interface{}{"server": "tcp://127.0.0.1:1883", "topic": "demo"}}},
+		{Op: RuleChangeAdd, Path: "options", To: map[string]interface{}{"qos": float64(1)}},
+		{Op: RuleChangeReplace, Path: "sql", From: "SELECT * FROM demo WHERE temperature > 20", To: "SELECT * FROM demo"},
+	}
+	if !reflect.DeepEqual(changes, exp) {
+		t.Errorf("diff mismatch,\ngot:\t%s \nwant:\t%s", printChanges(changes), printChanges(exp))
+	}
+	if _, err := p.ExecDrop(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetRuleVersions(id); err == nil {
+		t.Errorf("expect no history after the rule is dropped")
+	}
+}
+
+func TestRuleDiff(t *testing.T) {
+	tests := []struct {
+		from    map[string]interface{}
+		to      map[string]interface{}
+		changes []*RuleChange
+	}{
+		{
+			from:    map[string]interface{}{"sql": "SELECT * FROM demo"},
+			to:      map[string]interface{}{"sql": "SELECT * FROM demo"},
+			changes: []*RuleChange{},
+		}, {
+			from: map[string]interface{}{"triggered": true, "options": map[string]interface{}{"qos": float64(1), "isEventTime": true}},
+			to:   map[string]interface{}{"triggered": false, "options": map[string]interface{}{"qos": float64(2)}},
+			changes: []*RuleChange{
+				{Op: RuleChangeRemove, Path: "options.isEventTime", From: true},
+				{Op: RuleChangeReplace, Path: "options.qos", From: float64(1), To: float64(2)},
+				{Op: RuleChangeReplace, Path: "triggered", From: true, To: false},
+			},
+		}, {
+			from: map[string]interface{}{"actions": []interface{}{map[string]interface{}{"mqtt": map[string]interface{}{"topic": "a"}}}},
+			to:   map[string]interface{}{"actions": []interface{}{map[string]interface{}{"mqtt": map[string]interface{}{"topic": "b"}}, map[string]interface{}{"log": map[string]interface{}{}}}},
+			changes: []*RuleChange{
+				{Op: RuleChangeReplace, Path: "actions[0].mqtt.topic", From: "a", To: "b"},
+				{Op: RuleChangeAdd, Path: "actions[1]", To: map[string]interface{}{"log": map[string]interface{}{}}},
+			},
+		},
+	}
+	for i, tt := range tests {
+		changes := diffValue("", tt.from, tt.to, make([]*RuleChange, 0))
+		if !reflect.DeepEqual(changes, tt.changes) {
+			t.Errorf("%d diff mismatch,\ngot:\t%s \nwant:\t%s", i, printChanges(changes), printChanges(tt.changes))
+		}
+	}
+}
+
+func printChanges(changes []*RuleChange) string {
+	result := ""
+	for _, c := range changes {
+		result += fmt.Sprintf("%v;", *c)
+	}
+	return result
+}
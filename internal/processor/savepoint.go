@@ -0,0 +1,105 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+// Savepoint is a checkpoint of a rule saved manually with a name. Unlike the checkpoints, the savepoints are kept
+// until deleted explicitly even if the rule is dropped, so that a rule can be created or started from it.
+type Savepoint struct {
+	Name         string `json:"name"`
+	RuleId       string `json:"ruleId"`
+	CheckpointId int64  `json:"checkpointId"`
+	// Timestamp is the unix milli time when the savepoint is saved
+	Timestamp int64 `json:"timestamp"`
+	// Topo is the json of the rule topo to check the compatibility when restoring
+	Topo  string                 `json:"-"`
+	State map[string]interface{} `json:"-"`
+}
+
+func savepointKey(ruleId, name string) string {
+	return ruleId + "/" + name
+}
+
+func ValidateSavepointName(name string) error {
+	if name == "" {
+		return fmt.Errorf("Missing savepoint name.")
+	}
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("Savepoint name %s must not contain '/'.", name)
+	}
+	return nil
+}
+
+func (p *RuleProcessor) ExecCreateSavepoint(sp *Savepoint) error {
+	if err := ValidateSavepointName(sp.Name); err != nil {
+		return err
+	}
+	if err := p.savepointDb.Setnx(savepointKey(sp.RuleId, sp.Name), sp); err != nil {
+		return fmt.Errorf("Save savepoint %s of rule %s error: %v.", sp.Name, sp.RuleId, err)
+	}
+	return nil
+}
+
+// GetSavepoint returns the savepoint with the state
+func (p *RuleProcessor) GetSavepoint(ruleId, name string) (*Savepoint, error) {
+	sp := &Savepoint{}
+	f, err := p.savepointDb.Get(savepointKey(ruleId, name), sp)
+	if err != nil {
+		return nil, fmt.Errorf("Get savepoint %s of rule %s error: %v.", name, ruleId, err)
+	}
+	if !f {
+		return nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("Savepoint %s of rule %s is not found.", name, ruleId))
+	}
+	return sp, nil
+}
+
+// GetSavepoints returns the savepoints of the rule without the state, from the oldest to the latest
+func (p *RuleProcessor) GetSavepoints(ruleId string) ([]*Savepoint, error) {
+	keys, err := p.savepointDb.Keys()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Savepoint, 0)
+	prefix := ruleId + "/"
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		sp, err := p.GetSavepoint(ruleId, strings.TrimPrefix(k, prefix))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &Savepoint{Name: sp.Name, RuleId: sp.RuleId, CheckpointId: sp.CheckpointId, Timestamp: sp.Timestamp})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+	return result, nil
+}
+
+func (p *RuleProcessor) ExecDropSavepoint(ruleId, name string) error {
+	key := savepointKey(ruleId, name)
+	if f, _ := p.savepointDb.Get(key, &Savepoint{}); !f {
+		return errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("Savepoint %s of rule %s is not found.", name, ruleId))
+	}
+	return p.savepointDb.Delete(key)
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSavepoint(t *testing.T) {
+	p := NewRuleProcessor()
+	const id = "savepointRule"
+	sp1 := &Savepoint{Name: "sp1", RuleId: id, CheckpointId: 10, Timestamp: 100, Topo: "{}", State: map[string]interface{}{"op1": map[string]interface{}{"count": 1}}}
+	sp2 := &Savepoint{Name: "sp2", RuleId: id, CheckpointId: 20, Timestamp: 200, Topo: "{}", State: map[string]interface{}{"op1": map[string]interface{}{"count": 2}}}
+	for _, sp := range []*Savepoint{sp2, sp1} {
+		if err := p.ExecCreateSavepoint(sp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		_ = p.ExecDropSavepoint(id, "sp1")
+		_ = p.ExecDropSavepoint(id, "sp2")
+	}()
+	if err := p.ExecCreateSavepoint(sp1); err == nil {
+		t.Errorf("expect error for duplicate savepoint")
+	}
+	if err := p.ExecCreateSavepoint(&Savepoint{Name: "a/b", RuleId: id}); err == nil || err.Error() != "Savepoint name a/b must not contain '/'." {
+		t.Errorf("name error mismatch, got %v", err)
+	}
+	sps, err := p.GetSavepoints(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []*Savepoint{
+		{Name: "sp1", RuleId: id, CheckpointId: 10, Timestamp: 100},
+		{Name: "sp2", RuleId: id, CheckpointId: 20, Timestamp: 200},
+	}
+	if !reflect.DeepEqual(sps, exp) {
+		t.Errorf("savepoints mismatch, got %v", sps)
+	}
+	sp, err := p.GetSavepoint(id, "sp2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sp, sp2) {
+		t.Errorf("savepoint mismatch, got %v", sp)
+	}
+	if err := p.ExecDropSavepoint(id, "sp2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.GetSavepoint(id, "sp2"); err == nil || err.Error() != "Savepoint sp2 of rule savepointRule is not found." {
+		t.Errorf("not found error mismatch, got %v", err)
+	}
+	if err := p.ExecDropSavepoint(id, "sp2"); err == nil {
+		t.Errorf("expect error for dropping non existing savepoint")
+	}
+}
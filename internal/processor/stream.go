@@ -313,6 +313,12 @@ func printOptions(opts *ast.Options, buff *bytes.Buffer) {
 	if opts.SCHEMAID != "" {
 		buff.WriteString(fmt.Sprintf("SCHEMAID: %s\n", opts.SCHEMAID))
 	}
+	if opts.DECOMPRESSION != "" {
+		buff.WriteString(fmt.Sprintf("DECOMPRESSION: %s\n", opts.DECOMPRESSION))
+	}
+	if opts.DECRYPTION != "" {
+		buff.WriteString(fmt.Sprintf("DECRYPTION: %s\n", opts.DECRYPTION))
+	}
 	if opts.KEY != "" {
 		buff.WriteString(fmt.Sprintf("KEY: %s\n", opts.KEY))
 	}
@@ -0,0 +1,97 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/schema"
+	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/node"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// SampleSchema reads at most count tuples from the source of the stream and infers the schema from the sampled data.
+// The sampling stops when the timeout exceeds. It is an error if no data is received in the timeout.
+func (p *StreamProcessor) SampleSchema(name string, st ast.StreamType, count int, timeout time.Duration) (map[string]*ast.JsonStreamField, error) {
+	statement, err := p.GetStream(name, st)
+	if err != nil {
+		return nil, err
+	}
+	parser := xsql.NewParser(strings.NewReader(statement))
+	stream, err := xsql.Language.Parse(parser)
+	if err != nil {
+		return nil, err
+	}
+	stmt, ok := stream.(*ast.StreamStmt)
+	if !ok {
+		return nil, fmt.Errorf("Sample %s fails, cannot parse the data \"%s\" to a stream statement", ast.StreamTypeMap[st], statement)
+	}
+	samples, err := sampleSource(stmt, count, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no data received from %s %s in %v", ast.StreamTypeMap[st], name, timeout)
+	}
+	sfs, err := schema.InferFromSamples(samples)
+	if err != nil {
+		return nil, err
+	}
+	return sfs.ToJsonSchema(), nil
+}
+
+// sampleSource runs the source node of the stream alone like a rule without any operator and sink.
+// The source is decoded schemaless so that all the fields in the data can be sampled.
+func sampleSource(stmt *ast.StreamStmt, count int, timeout time.Duration) ([]map[string]interface{}, error) {
+	name := string(stmt.Name)
+	ruleId := "$$sample_" + name
+	contextLogger := conf.Log.WithField("rule", ruleId)
+	ctx, cancel := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger).WithCancel()
+	defer cancel()
+	store, err := state.CreateStore(ruleId, api.AtMostOnce)
+	if err != nil {
+		return nil, fmt.Errorf("sample %s create store error %v", name, err)
+	}
+	src := node.NewSourceNode(name, stmt.StreamType, nil, stmt.Options, false, nil)
+	defer src.RemoveMetrics(ruleId)
+	output := make(chan interface{}, count)
+	_ = src.AddOutput(output, ruleId)
+	errCh := make(chan error, 1)
+	src.Open(ctx.WithMeta(ruleId, src.GetName(), store), errCh)
+
+	samples := make([]map[string]interface{}, 0, count)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for len(samples) < count {
+		select {
+		case data := <-output:
+			if t, ok := data.(*xsql.Tuple); ok {
+				samples = append(samples, t.Message)
+			}
+		case err := <-errCh:
+			return nil, fmt.Errorf("sample %s error: %v", name, err)
+		case <-timer.C:
+			return samples, nil
+		}
+	}
+	return samples, nil
+}
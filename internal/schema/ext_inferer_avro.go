@@ -0,0 +1,186 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package schema
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hamba/avro/v2"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/def"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+func init() {
+	inferes[message.FormatAvro] = InferAvro
+}
+
+// InferAvro infers the stream fields from the top level record of the avro schema. If any field cannot be
+// mapped to the eKuiper types such as map or union of multiple types, the stream is regarded as schemaless.
+func InferAvro(schemaFile string, name string) (ast.StreamFields, error) {
+	ffs, err := GetSchemaFile(def.AVRO, schemaFile)
+	if err != nil {
+		return nil, err
+	}
+	var s avro.Schema
+	if ffs.Registry != nil {
+		rs, err := FetchSubject(ffs.Registry.Subject, ffs.Registry.Version)
+		if err != nil {
+			return nil, err
+		}
+		s, err = ParseAvro(rs.Schema, rs.Refs, name)
+		if err != nil {
+			return nil, fmt.Errorf("parse schema %s failed: %s", schemaFile, err)
+		}
+	} else {
+		content, err := os.ReadFile(ffs.SchemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("read schema file %s failed: %s", ffs.SchemaFile, err)
+		}
+		s, err = ParseAvro(string(content), nil, name)
+		if err != nil {
+			return nil, fmt.Errorf("parse schema file %s failed: %s", ffs.SchemaFile, err)
+		}
+	}
+	rs, ok := s.(*avro.RecordSchema)
+	if !ok {
+		return nil, fmt.Errorf("type %s in schema %s is not a record", name, schemaFile)
+	}
+	result, err := convertAvroRecord(rs)
+	if err != nil {
+		conf.Log.Infof("avro schema %s is regarded as schemaless: %v", schemaFile, err)
+		return nil, nil
+	}
+	return result, nil
+}
+
+func convertAvroRecord(rs *avro.RecordSchema) (ast.StreamFields, error) {
+	result := make(ast.StreamFields, 0, len(rs.Fields()))
+	for _, f := range rs.Fields() {
+		ft, err := convertAvroType(f.Type())
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.Name(), err)
+		}
+		result = append(result, ast.StreamField{Name: f.Name(), FieldType: ft})
+	}
+	return result, nil
+}
+
+func convertAvroType(s avro.Schema) (ast.FieldType, error) {
+	if ls, ok := s.(avro.LogicalTypeSchema); ok && ls.Logical() != nil {
+		switch ls.Logical().Type() {
+		case avro.Date, avro.TimestampMillis, avro.TimestampMicros:
+			return &ast.BasicType{Type: ast.DATETIME}, nil
+		case avro.Decimal:
+			return &ast.BasicType{Type: ast.FLOAT}, nil
+		}
+	}
+	switch s.Type() {
+	case avro.Boolean:
+		return &ast.BasicType{Type: ast.BOOLEAN}, nil
+	case avro.Int, avro.Long:
+		return &ast.BasicType{Type: ast.BIGINT}, nil
+	case avro.Float, avro.Double:
+		return &ast.BasicType{Type: ast.FLOAT}, nil
+	case avro.String, avro.Enum:
+		return &ast.BasicType{Type: ast.STRINGS}, nil
+	case avro.Bytes, avro.Fixed:
+		return &ast.BasicType{Type: ast.BYTEA}, nil
+	case avro.Record, avro.Error:
+		sfs, err := convertAvroRecord(s.(*avro.RecordSchema))
+		if err != nil {
+			return nil, err
+		}
+		return &ast.RecType{StreamFields: sfs}, nil
+	case avro.Array:
+		ft, err := convertAvroType(s.(*avro.ArraySchema).Items())
+		if err != nil {
+			return nil, err
+		}
+		switch t := ft.(type) {
+		case *ast.BasicType:
+			return &ast.ArrayType{Type: t.Type}, nil
+		case *ast.RecType:
+			return &ast.ArrayType{Type: ast.STRUCT, FieldType: t}, nil
+		default:
+			return &ast.ArrayType{Type: ast.ARRAY, FieldType: t}, nil
+		}
+	case avro.Union:
+		// Only the nullable union of one type is supported
+		var single avro.Schema
+		for _, t := range s.(*avro.UnionSchema).Types() {
+			if t.Type() == avro.Null {
+				continue
+			}
+			if single != nil {
+				return nil, fmt.Errorf("union of multiple types is not supported")
+			}
+			single = t
+		}
+		if single == nil {
+			return nil, fmt.Errorf("null type is not supported")
+		}
+		return convertAvroType(single)
+	case avro.Ref:
+		return convertAvroType(s.(*avro.RefSchema).Schema())
+	default:
+		return nil, fmt.Errorf("type %s is not supported", s.Type())
+	}
+}
+
+// ParseAvro parses the avro schema with its referenced named types. The name selects a named type in the schema,
+// empty means the schema itself.
+func ParseAvro(content string, refs map[string]string, name string) (avro.Schema, error) {
+	cache := &avro.SchemaCache{}
+	// The references may depend on each other, parse until no more can be resolved
+	pending := make(map[string]string, len(refs))
+	for k, v := range refs {
+		pending[k] = v
+	}
+	n := len(pending)
+	for len(pending) > 0 {
+		var lastErr error
+		for k, v := range pending {
+			if _, err := avro.ParseWithCache(v, "", cache); err != nil {
+				lastErr = err
+				continue
+			}
+			delete(pending, k)
+		}
+		if lastErr != nil && len(pending) == n {
+			return nil, fmt.Errorf("parse references error: %v", lastErr)
+		}
+		n = len(pending)
+	}
+	s, err := avro.ParseWithCache(content, "", cache)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return s, nil
+	}
+	if ns, ok := s.(avro.NamedSchema); ok && (ns.Name() == name || ns.FullName() == name) {
+		return s, nil
+	}
+	if ns := cache.Get(name); ns != nil {
+		return ns, nil
+	}
+	return nil, fmt.Errorf("type %s not found in schema", name)
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestInferAvro(t *testing.T) {
+	testx.InitEnv()
+	etcDir, err := conf.GetDataLoc()
+	if err != nil {
+		t.Fatal(err)
+	}
+	etcDir = filepath.Join(etcDir, "schemas", "avro")
+	err = os.MkdirAll(etcDir, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"test1.avsc", "test2.avsc"} {
+		bytesRead, err := os.ReadFile(filepath.Join("test", f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(etcDir, f), bytesRead, 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		err = os.RemoveAll(etcDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = InitRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := InferAvro("test1", "Person")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ast.StreamFields{
+		{Name: "name", FieldType: &ast.BasicType{Type: ast.STRINGS}},
+		{Name: "id", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+		{Name: "email", FieldType: &ast.BasicType{Type: ast.STRINGS}},
+		{Name: "ts", FieldType: &ast.BasicType{Type: ast.DATETIME}},
+		{Name: "code", FieldType: &ast.ArrayType{
+			Type: ast.STRUCT,
+			FieldType: &ast.RecType{StreamFields: []ast.StreamField{
+				{Name: "doubles", FieldType: &ast.ArrayType{Type: ast.FLOAT}},
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("InferAvro result is not expected, got %v, expected %v", result, expected)
+	}
+	// map is not supported, so regarded as schemaless
+	result, err = InferAvro("test2", "Device")
+	if err != nil || result != nil {
+		t.Errorf("expect schemaless but got %v, %v", result, err)
+	}
+	_, err = InferAvro("test1", "Device")
+	if err == nil {
+		t.Errorf("expect error for unknown type")
+	}
+}
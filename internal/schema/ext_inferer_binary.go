@@ -0,0 +1,231 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/def"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+func init() {
+	inferes[message.FormatBinary] = InferBinary
+}
+
+// The byte sizes of the fixed size field types in the binary frame
+var binaryTypeSizes = map[string]int{
+	"int8":    1,
+	"uint8":   1,
+	"bool":    1,
+	"int16":   2,
+	"uint16":  2,
+	"int32":   4,
+	"uint32":  4,
+	"float32": 4,
+	"int64":   8,
+	"uint64":  8,
+	"float64": 8,
+}
+
+// binarySpec is the content of the binary schema file. The frames are referred by name like the messages in protobuf.
+type binarySpec struct {
+	Endian string                  `json:"endian"`
+	Frames map[string]*BinaryFrame `json:"frames"`
+}
+
+// BinaryFrame is the declarative layout of a binary frame
+type BinaryFrame struct {
+	Endian string `json:"endian"`
+	// Size is the min length of the frame, it is at least the end of the last fixed size field
+	Size   int            `json:"size"`
+	Fields []*BinaryField `json:"fields"`
+}
+
+// BinaryField is a field at the byte offset of the frame. The integer field can be a bit field by BitOffset and
+// BitLength which are counted from the least significant bit. The numeric value is converted to the physical value by
+// value = raw * Scale + Bias if any of them is set.
+type BinaryField struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	// Length is the bytes of the string or bytes field, 0 means till the end of the frame
+	Length    int     `json:"length"`
+	Endian    string  `json:"endian"`
+	BitOffset int     `json:"bitOffset"`
+	BitLength int     `json:"bitLength"`
+	Scale     float64 `json:"scale"`
+	Bias      float64 `json:"bias"`
+}
+
+// Scaled returns whether the raw value is converted by the scale and bias
+func (f *BinaryField) Scaled() bool {
+	return f.Scale != 0 || f.Bias != 0
+}
+
+// Size returns the byte size of the field, 0 means till the end of the frame
+func (f *BinaryField) Size() int {
+	if s, ok := binaryTypeSizes[f.Type]; ok {
+		return s
+	}
+	return f.Length
+}
+
+// ParseBinaryFrame parses the binary schema and returns the validated frame of the name. The endian of each field is
+// resolved from the field, frame or the schema, and it is big endian by default.
+func ParseBinaryFrame(content string, name string) (*BinaryFrame, error) {
+	spec := &binarySpec{}
+	d := json.NewDecoder(bytes.NewReader([]byte(content)))
+	d.DisallowUnknownFields()
+	if err := d.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid binary schema: %v", err)
+	}
+	frame, ok := spec.Frames[name]
+	if !ok {
+		return nil, fmt.Errorf("frame %s is not found", name)
+	}
+	if len(frame.Fields) == 0 {
+		return nil, fmt.Errorf("frame %s has no fields", name)
+	}
+	endian := frame.Endian
+	if endian == "" {
+		endian = spec.Endian
+	}
+	names := make(map[string]struct{}, len(frame.Fields))
+	var rest *BinaryField
+	for _, f := range frame.Fields {
+		if f.Name == "" {
+			return nil, fmt.Errorf("field name is required")
+		}
+		if _, ok := names[f.Name]; ok {
+			return nil, fmt.Errorf("duplicate field %s", f.Name)
+		}
+		names[f.Name] = struct{}{}
+		if f.Endian == "" {
+			f.Endian = endian
+		}
+		if err := validateBinaryField(f); err != nil {
+			return nil, fmt.Errorf("invalid field %s: %v", f.Name, err)
+		}
+		if f.Size() == 0 {
+			if rest != nil {
+				return nil, fmt.Errorf("fields %s and %s cannot both read till the end of the frame", rest.Name, f.Name)
+			}
+			rest = f
+		} else if end := f.Offset + f.Size(); end > frame.Size {
+			frame.Size = end
+		}
+	}
+	if rest != nil {
+		if frame.Size > rest.Offset {
+			return nil, fmt.Errorf("field %s reads till the end of the frame but other fields are after its offset", rest.Name)
+		}
+		frame.Size = rest.Offset
+	}
+	return frame, nil
+}
+
+func validateBinaryField(f *BinaryField) error {
+	switch f.Endian {
+	case "":
+		f.Endian = "big"
+	case "big", "little":
+	default:
+		return fmt.Errorf("endian must be big or little but got %s", f.Endian)
+	}
+	if f.Offset < 0 {
+		return fmt.Errorf("offset must not be negative")
+	}
+	switch f.Type {
+	case "string", "bytes":
+		if f.Length < 0 {
+			return fmt.Errorf("length must not be negative")
+		}
+		if f.BitLength != 0 || f.BitOffset != 0 || f.Scaled() {
+			return fmt.Errorf("%s field cannot have bits, scale or bias", f.Type)
+		}
+		return nil
+	case "bool":
+		if f.BitOffset < 0 || f.BitOffset > 7 {
+			return fmt.Errorf("bitOffset of bool field must be 0 to 7")
+		}
+		if f.BitLength > 1 || f.Scaled() {
+			return fmt.Errorf("bool field cannot have bitLength, scale or bias")
+		}
+		return nil
+	case "float32", "float64":
+		if f.BitLength != 0 || f.BitOffset != 0 {
+			return fmt.Errorf("float field cannot have bits")
+		}
+	default:
+		size, ok := binaryTypeSizes[f.Type]
+		if !ok {
+			return fmt.Errorf("unsupported type %s", f.Type)
+		}
+		if f.BitOffset < 0 || f.BitLength < 0 || f.BitOffset+f.BitLength > size*8 {
+			return fmt.Errorf("bits %d to %d exceed the %d bits of %s", f.BitOffset, f.BitOffset+f.BitLength, size*8, f.Type)
+		}
+		if f.BitLength == 0 && f.BitOffset != 0 {
+			return fmt.Errorf("bitLength is required with bitOffset")
+		}
+	}
+	if f.Scale == 0 && f.Bias != 0 {
+		f.Scale = 1
+	}
+	return nil
+}
+
+// InferBinary infers the stream fields from the frame. The scaled numeric fields are float.
+func InferBinary(schemaFile string, name string) (ast.StreamFields, error) {
+	ffs, err := GetSchemaFile(def.BINARY, schemaFile)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(ffs.SchemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("read schema file %s failed: %s", ffs.SchemaFile, err)
+	}
+	frame, err := ParseBinaryFrame(string(content), name)
+	if err != nil {
+		return nil, fmt.Errorf("parse schema file %s failed: %s", ffs.SchemaFile, err)
+	}
+	result := make(ast.StreamFields, 0, len(frame.Fields))
+	for _, f := range frame.Fields {
+		var t ast.DataType
+		switch f.Type {
+		case "string":
+			t = ast.STRINGS
+		case "bytes":
+			t = ast.BYTEA
+		case "bool":
+			t = ast.BOOLEAN
+		case "float32", "float64":
+			t = ast.FLOAT
+		default:
+			t = ast.BIGINT
+			if f.Scaled() {
+				t = ast.FLOAT
+			}
+		}
+		result = append(result, ast.StreamField{Name: f.Name, FieldType: &ast.BasicType{Type: t}})
+	}
+	return result, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build schema || !core
+
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestInferBinary(t *testing.T) {
+	testx.InitEnv()
+	etcDir, err := conf.GetDataLoc()
+	if err != nil {
+		t.Fatal(err)
+	}
+	etcDir = filepath.Join(etcDir, "schemas", "binary")
+	err = os.MkdirAll(etcDir, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytesRead, err := os.ReadFile(filepath.Join("test", "frames.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(etcDir, "frames.json"), bytesRead, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = os.RemoveAll(etcDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = InitRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := InferBinary("frames", "Telemetry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ast.StreamFields{
+		{Name: "id", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+		{Name: "temperature", FieldType: &ast.BasicType{Type: ast.FLOAT}},
+		{Name: "running", FieldType: &ast.BasicType{Type: ast.BOOLEAN}},
+		{Name: "mode", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+		{Name: "offset", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+		{Name: "pressure", FieldType: &ast.BasicType{Type: ast.FLOAT}},
+		{Name: "name", FieldType: &ast.BasicType{Type: ast.STRINGS}},
+		{Name: "payload", FieldType: &ast.BasicType{Type: ast.BYTEA}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("InferBinary result is not expected, got %v, expected %v", result, expected)
+	}
+	_, err = InferBinary("frames", "Unknown")
+	if err == nil {
+		t.Errorf("expect error for unknown frame")
+	}
+}
+
+func TestParseBinaryFrame(t *testing.T) {
+	tests := []struct {
+		content string
+		size    int
+		err     string
+	}{
+		{
+			content: `{"frames":{"f":{"fields":[{"name":"a","type":"uint32","offset":2},{"name":"b","type":"bytes","offset":6}]}}}`,
+			size:    6,
+		}, {
+			content: `{"frames":{"f":{"size":10,"fields":[{"name":"a","type":"string","offset":0,"length":4}]}}}`,
+			size:    10,
+		}, {
+			content: `{"frames":{"f":{"fields":[{"name":"a","type":"int24","offset":0}]}}}`,
+			err:     "invalid field a: unsupported type int24",
+		}, {
+			content: `{"frames":{"f":{"fields":[{"name":"a","type":"uint8","offset":0,"bitOffset":6,"bitLength":4}]}}}`,
+			err:     "invalid field a: bits 6 to 10 exceed the 8 bits of uint8",
+		}, {
+			content: `{"frames":{"f":{"fields":[{"name":"a","type":"uint8","offset":0},{"name":"a","type":"uint8","offset":1}]}}}`,
+			err:     "duplicate field a",
+		}, {
+			content: `{"frames":{"f":{"fields":[{"name":"a","type":"bytes","offset":0},{"name":"b","type":"uint8","offset":1}]}}}`,
+			err:     "field a reads till the end of the frame but other fields are after its offset",
+		}, {
+			content: `{"frames":{"f":{"endian":"middle","fields":[{"name":"a","type":"uint16","offset":0}]}}}`,
+			err:     "invalid field a: endian must be big or little but got middle",
+		}, {
+			content: `{"frames":{"f":{"fields":[{"name":"a","type":"uint16","offst":0}]}}}`,
+			err:     "invalid binary schema: json: unknown field \"offst\"",
+		}, {
+			content: `{"frames":{"g":{"fields":[{"name":"a","type":"uint16","offset":0}]}}}`,
+			err:     "frame f is not found",
+		},
+	}
+	for i, tt := range tests {
+		frame, err := ParseBinaryFrame(tt.content, "f")
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d error mismatch:\n  exp=%s\n  got=%s", i, tt.err, err)
+		} else if err == nil && frame.Size != tt.size {
+			t.Errorf("%d size mismatch, exp %d, got %d", i, tt.size, frame.Size)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -21,18 +21,14 @@ import (
 
 	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/jhump/protoreflect/desc"
-	"github.com/jhump/protoreflect/desc/protoparse"
 
 	"github.com/lf-edge/ekuiper/internal/pkg/def"
 	"github.com/lf-edge/ekuiper/pkg/ast"
 	"github.com/lf-edge/ekuiper/pkg/message"
 )
 
-var protoParser *protoparse.Parser
-
 func init() {
 	inferes[message.FormatProtobuf] = InferProtobuf
-	protoParser = &protoparse.Parser{}
 }
 
 // InferProtobuf infers the schema from a protobuf file dynamically in case the schema file changed
@@ -41,10 +37,10 @@ func InferProtobuf(schemaFile string, messageName string) (ast.StreamFields, err
 	if err != nil {
 		return nil, err
 	}
-	if fds, err := protoParser.ParseFiles(ffs.SchemaFile); err != nil {
-		return nil, fmt.Errorf("parse schema file %s failed: %s", ffs.SchemaFile, err)
+	if fds, err := ParseProtobuf(ffs.SchemaFile); err != nil {
+		return nil, err
 	} else {
-		messageDescriptor := fds[0].FindMessage(messageName)
+		messageDescriptor := FindProtoMessage(fds, messageName)
 		if messageDescriptor == nil {
 			return nil, fmt.Errorf("message type %s not found in schema file %s", messageName, schemaFile)
 		}
@@ -117,6 +113,11 @@ func convertFieldType(tt dpb.FieldDescriptorProto_Type, f *desc.FieldDescriptor)
 	case dpb.FieldDescriptorProto_TYPE_BYTES:
 		ft = &ast.BasicType{Type: ast.BYTEA}
 	case dpb.FieldDescriptorProto_TYPE_MESSAGE:
+		// The type of google.protobuf.Any is only known at runtime by the @type key
+		if f.GetMessageType().GetFullyQualifiedName() == "google.protobuf.Any" {
+			ft = &ast.RecType{StreamFields: ast.StreamFields{{Name: "@type", FieldType: &ast.BasicType{Type: ast.STRINGS}}}}
+			break
+		}
 		sfs, err := convertMessage(f.GetMessageType())
 		if err != nil {
 			return nil, fmt.Errorf("invalid struct field type: %v", err)
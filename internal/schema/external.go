@@ -0,0 +1,201 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+// RegistryRef refers to a schema in the external schema registry like Confluent or Apicurio
+type RegistryRef struct {
+	Subject string `json:"subject"`
+	// Version is the version number or "latest". Default to latest
+	Version string `json:"version,omitempty"`
+}
+
+// RemoteSchema is a schema fetched from the external registry
+type RemoteSchema struct {
+	ID      int
+	Subject string
+	Version int
+	// Type is AVRO, PROTOBUF or JSON
+	Type   string
+	Schema string
+	// Refs is the content of all the referenced schemas, recursively. The key is the reference name like the import
+	// path of protobuf
+	Refs map[string]string
+}
+
+type remoteRef struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+type remoteResp struct {
+	ID         int         `json:"id"`
+	Subject    string      `json:"subject"`
+	Version    int         `json:"version"`
+	SchemaType string      `json:"schemaType"`
+	Schema     string      `json:"schema"`
+	References []remoteRef `json:"references"`
+}
+
+// The cache of the fetched schemas. A schema of an id or a subject version never changes in the registry so that it
+// is cached forever. The latest version is always fetched.
+var (
+	remoteLock     sync.RWMutex
+	remoteById     = make(map[int]*RemoteSchema)
+	remoteBySubVer = make(map[string]*RemoteSchema)
+)
+
+// FetchSubject gets the schema of the subject version from the external registry.
+func FetchSubject(subject, version string) (*RemoteSchema, error) {
+	if version == "" {
+		version = "latest"
+	}
+	key := subject + "/" + version
+	remoteLock.RLock()
+	rs, ok := remoteBySubVer[key]
+	remoteLock.RUnlock()
+	if ok {
+		return rs, nil
+	}
+	resp := &remoteResp{}
+	if err := registryGet(fmt.Sprintf("/subjects/%s/versions/%s", url.PathEscape(subject), url.PathEscape(version)), resp); err != nil {
+		return nil, fmt.Errorf("fetch schema %s version %s error: %v", subject, version, err)
+	}
+	rs, err := toRemoteSchema(resp)
+	if err != nil {
+		return nil, err
+	}
+	remoteLock.Lock()
+	remoteById[rs.ID] = rs
+	if _, err := strconv.Atoi(version); err == nil {
+		remoteBySubVer[key] = rs
+	}
+	remoteLock.Unlock()
+	return rs, nil
+}
+
+// FetchById gets the schema by the global id which is usually carried in the wire format of the payload.
+func FetchById(id int) (*RemoteSchema, error) {
+	remoteLock.RLock()
+	rs, ok := remoteById[id]
+	remoteLock.RUnlock()
+	if ok {
+		return rs, nil
+	}
+	resp := &remoteResp{}
+	if err := registryGet(fmt.Sprintf("/schemas/ids/%d", id), resp); err != nil {
+		return nil, fmt.Errorf("fetch schema id %d error: %v", id, err)
+	}
+	resp.ID = id
+	rs, err := toRemoteSchema(resp)
+	if err != nil {
+		return nil, err
+	}
+	remoteLock.Lock()
+	remoteById[id] = rs
+	remoteLock.Unlock()
+	return rs, nil
+}
+
+func toRemoteSchema(resp *remoteResp) (*RemoteSchema, error) {
+	rs := &RemoteSchema{
+		ID:      resp.ID,
+		Subject: resp.Subject,
+		Version: resp.Version,
+		Type:    resp.SchemaType,
+		Schema:  resp.Schema,
+	}
+	// Confluent omits the type for avro
+	if rs.Type == "" {
+		rs.Type = "AVRO"
+	}
+	if len(resp.References) > 0 {
+		rs.Refs = make(map[string]string, len(resp.References))
+		for _, ref := range resp.References {
+			if _, ok := rs.Refs[ref.Name]; ok {
+				continue
+			}
+			r, err := FetchSubject(ref.Subject, strconv.Itoa(ref.Version))
+			if err != nil {
+				return nil, fmt.Errorf("fetch reference %s error: %v", ref.Name, err)
+			}
+			rs.Refs[ref.Name] = r.Schema
+			for k, v := range r.Refs {
+				rs.Refs[k] = v
+			}
+		}
+	}
+	return rs, nil
+}
+
+func registryUrl() (string, error) {
+	c := conf.Config.SchemaRegistry
+	switch c.Type {
+	case "":
+		return "", fmt.Errorf("schema registry is not configured")
+	case "apicurio":
+		u := strings.TrimSuffix(c.Url, "/")
+		if !strings.Contains(u, "/apis/ccompat") {
+			u += "/apis/ccompat/v7"
+		}
+		return u, nil
+	default:
+		return strings.TrimSuffix(c.Url, "/"), nil
+	}
+}
+
+func registryGet(path string, result interface{}) error {
+	base, err := registryUrl()
+	if err != nil {
+		return err
+	}
+	c := conf.Config.SchemaRegistry
+	req, err := http.NewRequest(http.MethodGet, base+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	client := &http.Client{Timeout: time.Duration(c.Timeout) * time.Millisecond}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry returns status %d: %s", resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, result)
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+const (
+	refProto    = `syntax = "proto3";message Address {string city = 1;}`
+	personProto = `syntax = "proto3";import "address.proto";message Person {string name = 1;Address addr = 2;}`
+)
+
+func mockRegistry(prefix string, hits map[string]int) *httptest.Server {
+	responses := map[string]string{
+		"/subjects/address/versions/1":     `{"subject":"address","version":1,"id":1,"schemaType":"PROTOBUF","schema":"syntax = \"proto3\";message Address {string city = 1;}"}`,
+		"/subjects/person/versions/latest": `{"subject":"person","version":2,"id":3,"schemaType":"PROTOBUF","schema":"syntax = \"proto3\";import \"address.proto\";message Person {string name = 1;Address addr = 2;}","references":[{"name":"address.proto","subject":"address","version":1}]}`,
+		"/subjects/avro/versions/1":        `{"subject":"avro","version":1,"id":4,"schema":"\"string\""}`,
+		"/schemas/ids/3":                   `{"schemaType":"PROTOBUF","schema":"syntax = \"proto3\";import \"address.proto\";message Person {string name = 1;Address addr = 2;}","references":[{"name":"address.proto","subject":"address","version":1}]}`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		hits[r.URL.Path]++
+		resp, ok := responses[r.URL.Path[len(prefix):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40401,"message":"Subject not found."}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+		_, _ = w.Write([]byte(resp))
+	}))
+}
+
+func resetRemoteCache() {
+	remoteLock.Lock()
+	remoteById = make(map[int]*RemoteSchema)
+	remoteBySubVer = make(map[string]*RemoteSchema)
+	remoteLock.Unlock()
+}
+
+func TestFetchRemote(t *testing.T) {
+	testx.InitEnv()
+	hits := make(map[string]int)
+	s := mockRegistry("", hits)
+	defer s.Close()
+	conf.Config.SchemaRegistry = conf.SchemaRegistryConf{Type: "confluent", Url: s.URL, Username: "user", Password: "pass", Timeout: 1000}
+	defer func() {
+		conf.Config.SchemaRegistry = conf.SchemaRegistryConf{}
+		resetRemoteCache()
+	}()
+	resetRemoteCache()
+
+	exp := &RemoteSchema{ID: 3, Subject: "person", Version: 2, Type: "PROTOBUF", Schema: personProto, Refs: map[string]string{"address.proto": refProto}}
+	for i := 0; i < 2; i++ {
+		rs, err := FetchSubject("person", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(exp, rs) {
+			t.Errorf("fetch subject mismatch, expect %v but got %v", exp, rs)
+		}
+	}
+	rs, err := FetchById(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exp, rs) {
+		t.Errorf("fetch by id mismatch, expect %v but got %v", exp, rs)
+	}
+	rs, err = FetchSubject("avro", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Type != "AVRO" {
+		t.Errorf("expect default type AVRO but got %s", rs.Type)
+	}
+	_, err = FetchSubject("avro", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = FetchSubject("unknown", "1")
+	if err == nil {
+		t.Errorf("expect error for unknown subject")
+	}
+	// latest is always fetched, the numeric version and id are cached
+	expHits := map[string]int{
+		"/subjects/person/versions/latest": 2,
+		"/subjects/address/versions/1":     1,
+		"/subjects/avro/versions/1":        1,
+		"/subjects/unknown/versions/1":     1,
+	}
+	if !reflect.DeepEqual(expHits, hits) {
+		t.Errorf("hits mismatch, expect %v but got %v", expHits, hits)
+	}
+}
+
+func TestApicurioRegistry(t *testing.T) {
+	testx.InitEnv()
+	hits := make(map[string]int)
+	s := mockRegistry("/apis/ccompat/v7", hits)
+	defer s.Close()
+	conf.Config.SchemaRegistry = conf.SchemaRegistryConf{Type: "apicurio", Url: s.URL + "/", Username: "user", Password: "pass", Timeout: 1000}
+	defer func() {
+		conf.Config.SchemaRegistry = conf.SchemaRegistryConf{}
+		resetRemoteCache()
+	}()
+	resetRemoteCache()
+	rs, err := FetchById(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.Schema != personProto || rs.Refs["address.proto"] != refProto {
+		t.Errorf("fetch by id mismatch, got %v", rs)
+	}
+}
+
+func TestRegistrySchema(t *testing.T) {
+	testx.InitEnv()
+	hits := make(map[string]int)
+	s := mockRegistry("", hits)
+	defer s.Close()
+	conf.Config.SchemaRegistry = conf.SchemaRegistryConf{Type: "confluent", Url: s.URL, Username: "user", Password: "pass", Timeout: 1000}
+	defer func() {
+		conf.Config.SchemaRegistry = conf.SchemaRegistryConf{}
+		resetRemoteCache()
+	}()
+	resetRemoteCache()
+	etcDir, err := conf.GetDataLoc()
+	if err != nil {
+		t.Fatal(err)
+	}
+	etcDir = filepath.Join(etcDir, "schemas", "protobuf")
+	defer os.RemoveAll(etcDir)
+	if err := InitRegistry(); err != nil {
+		t.Fatal(err)
+	}
+	info := &Info{Name: "remote", Type: "protobuf", Registry: &RegistryRef{Subject: "person"}}
+	if err := info.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(info); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteSchema("protobuf", "remote")
+	got, err := GetSchema("protobuf", "remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Content != personProto || !reflect.DeepEqual(got.Registry, info.Registry) {
+		t.Errorf("schema mismatch, got %v", got)
+	}
+	// The registry ref is restored after restart
+	if err := InitRegistry(); err != nil {
+		t.Fatal(err)
+	}
+	ffs, err := GetSchemaFile("protobuf", "remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ffs.Registry, info.Registry) {
+		t.Errorf("registry ref is not restored, got %v", ffs.Registry)
+	}
+	err = Register(&Info{Name: "remoteAvro", Type: "protobuf", Registry: &RegistryRef{Subject: "avro", Version: "1"}})
+	if err == nil || err.Error() != "schema avro in registry is AVRO, but expect protobuf" {
+		t.Errorf("expect type mismatch error but got %v", err)
+	}
+}
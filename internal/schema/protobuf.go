@@ -0,0 +1,89 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	// TODO: replace with `google.golang.org/protobuf/proto` pkg.
+	"github.com/golang/protobuf/proto" //nolint:staticcheck
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+)
+
+// descriptorSetExts are the extensions of the protobuf schema files which are binary descriptor sets, such as the
+// output of `protoc --include_imports --descriptor_set_out`
+var descriptorSetExts = map[string]struct{}{
+	".desc":  {},
+	".pb":    {},
+	".binpb": {},
+}
+
+// IsDescriptorSet tells if the protobuf schema file is a binary descriptor set by the extension
+func IsDescriptorSet(schemaFile string) bool {
+	_, ok := descriptorSetExts[filepath.Ext(schemaFile)]
+	return ok
+}
+
+// ParseProtobuf parses the protobuf schema file, which is a proto file or a binary descriptor set. The result is the
+// proto file or all the files in the descriptor set.
+func ParseProtobuf(schemaFile string) ([]*desc.FileDescriptor, error) {
+	if !IsDescriptorSet(schemaFile) {
+		fds, err := (&protoparse.Parser{}).ParseFiles(schemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("parse schema file %s failed: %s", schemaFile, err)
+		}
+		return fds, nil
+	}
+	b, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("read descriptor set %s failed: %s", schemaFile, err)
+	}
+	set := &dpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("parse descriptor set %s failed: %s", schemaFile, err)
+	}
+	files, err := desc.CreateFileDescriptorsFromSet(set)
+	if err != nil {
+		return nil, fmt.Errorf("parse descriptor set %s failed: %s", schemaFile, err)
+	}
+	result := make([]*desc.FileDescriptor, 0, len(files))
+	for _, f := range set.GetFile() {
+		result = append(result, files[f.GetName()])
+	}
+	return result, nil
+}
+
+// FindProtoMessage finds the message by the fully qualified name. The package of the file can be omitted because
+// the schema id cannot contain dots.
+func FindProtoMessage(fds []*desc.FileDescriptor, name string) *desc.MessageDescriptor {
+	for _, fd := range fds {
+		if md := fd.FindMessage(name); md != nil {
+			return md
+		}
+	}
+	for _, fd := range fds {
+		if fd.GetPackage() == "" {
+			continue
+		}
+		if md := fd.FindMessage(fd.GetPackage() + "." + name); md != nil {
+			return md
+		}
+	}
+	return nil
+}
@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -39,6 +39,8 @@ var (
 type Files struct {
 	SchemaFile string
 	SoFile     string
+	// Registry is set if the schema is fetched from the external registry. The payload may be in its wire format.
+	Registry *RegistryRef
 }
 
 // Registry is a global registry for schemas
@@ -102,9 +104,30 @@ func InitRegistry() error {
 		schemaInstallWhenReboot()
 		clearInstallFlag()
 	}
+	restoreRegistryRefs()
 	return nil
 }
 
+// restoreRegistryRefs sets the registry refs of the schemas from the external registry which cannot be told by the files
+func restoreRegistryRefs() {
+	all, err := schemaDb.All()
+	if err != nil {
+		return
+	}
+	for k, v := range all {
+		if k == BOOT_INSTALL {
+			continue
+		}
+		info := &Info{}
+		if err := json.Unmarshal([]byte(v), info); err != nil || info.Registry == nil {
+			continue
+		}
+		if ffs, ok := registry.schemas[info.Type][info.Name]; ok {
+			ffs.Registry = info.Registry
+		}
+	}
+}
+
 func GetAllForType(schemaType def.SchemaType) ([]string, error) {
 	registry.RLock()
 	defer registry.RUnlock()
@@ -143,8 +166,29 @@ func CreateOrUpdateSchema(info *Info) error {
 		return err
 	}
 	ffs := &Files{}
-	if info.Content != "" || info.FilePath != "" {
-		schemaFile := filepath.Join(etcDir, info.Name+schemaExt[info.Type])
+	content := info.Content
+	if info.Registry != nil {
+		rs, err := FetchSubject(info.Registry.Subject, info.Registry.Version)
+		if err != nil {
+			return err
+		}
+		if def.SchemaType(strings.ToLower(rs.Type)) != info.Type {
+			return fmt.Errorf("schema %s in registry is %s, but expect %s", info.Registry.Subject, rs.Type, info.Type)
+		}
+		content = rs.Schema
+		ffs.Registry = info.Registry
+	}
+	if content != "" || info.FilePath != "" {
+		ext := schemaExt[info.Type]
+		// The binary descriptor set of protobuf can only be downloaded, it keeps its extension to be parsed properly
+		if info.Type == def.PROTOBUF && content == "" && IsDescriptorSet(info.FilePath) {
+			ext = filepath.Ext(info.FilePath)
+		}
+		schemaFile := filepath.Join(etcDir, info.Name+ext)
+		// Remove the file of the previous version in another format, otherwise both are loaded after restart
+		if old, ok := registry.schemas[info.Type][info.Name]; ok && old.SchemaFile != "" && old.SchemaFile != schemaFile {
+			_ = os.Remove(old.SchemaFile)
+		}
 		if _, err := os.Stat(schemaFile); os.IsNotExist(err) {
 			file, err := os.Create(schemaFile)
 			if err != nil {
@@ -152,8 +196,8 @@ func CreateOrUpdateSchema(info *Info) error {
 			}
 			defer file.Close()
 		}
-		if info.Content != "" {
-			err := os.WriteFile(schemaFile, []byte(info.Content), 0o666)
+		if content != "" {
+			err := os.WriteFile(schemaFile, []byte(content), 0o666)
 			if err != nil {
 				return err
 			}
@@ -187,7 +231,7 @@ func GetSchema(schemaType def.SchemaType, name string) (*Info, error) {
 	if schemaFile.SchemaFile != "" {
 		content, err := os.ReadFile(schemaFile.SchemaFile)
 		if err != nil {
-			return nil, fmt.Errorf("cannot read schema file %s: %s", schemaFile, err)
+			return nil, fmt.Errorf("cannot read schema file %s: %s", schemaFile.SchemaFile, err)
 		}
 		return &Info{
 			Type:     schemaType,
@@ -195,6 +239,7 @@ func GetSchema(schemaType def.SchemaType, name string) (*Info, error) {
 			Content:  string(content),
 			FilePath: schemaFile.SchemaFile,
 			SoPath:   schemaFile.SoFile,
+			Registry: schemaFile.Registry,
 		}, nil
 	} else {
 		return &Info{
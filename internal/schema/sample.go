@@ -0,0 +1,185 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// sampleType is the type inferred so far for a field. The properties are the fields of a struct
+// and the items is the element type of an array.
+type sampleType struct {
+	dataType   ast.DataType
+	properties map[string]*sampleType
+	items      *sampleType
+}
+
+// InferFromSamples infers the stream fields from the decoded sample data. The type of each field is merged
+// from all the samples: a bigint field becomes float if any sample has a decimal value. Null values and empty
+// arrays are ignored, so fields that are always null are not included. Fields with conflicting types among
+// the samples are reported as error.
+func InferFromSamples(samples []map[string]interface{}) (ast.StreamFields, error) {
+	root := &sampleType{dataType: ast.STRUCT, properties: make(map[string]*sampleType)}
+	for _, sample := range samples {
+		if err := root.mergeStruct(sample, ""); err != nil {
+			return nil, err
+		}
+	}
+	return root.toStreamFields(), nil
+}
+
+func (t *sampleType) mergeStruct(m map[string]interface{}, prefix string) error {
+	for k, v := range m {
+		name := prefix + k
+		p, ok := t.properties[k]
+		if !ok {
+			p = &sampleType{}
+		}
+		if err := p.merge(v, name); err != nil {
+			return err
+		}
+		if !ok && p.dataType != ast.UNKNOWN {
+			t.properties[k] = p
+		}
+	}
+	return nil
+}
+
+func (t *sampleType) merge(v interface{}, name string) error {
+	var dt ast.DataType
+	switch vt := v.(type) {
+	case nil:
+		return nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		dt = ast.BIGINT
+	case float32:
+		dt = floatType(float64(vt))
+	case float64:
+		dt = floatType(vt)
+	case string:
+		dt = ast.STRINGS
+	case bool:
+		dt = ast.BOOLEAN
+	case []byte:
+		dt = ast.BYTEA
+	case time.Time:
+		dt = ast.DATETIME
+	case map[string]interface{}:
+		if err := t.setType(ast.STRUCT, name); err != nil {
+			return err
+		}
+		if t.properties == nil {
+			t.properties = make(map[string]*sampleType)
+		}
+		return t.mergeStruct(vt, name+".")
+	case []map[string]interface{}:
+		arr := make([]interface{}, len(vt))
+		for i, e := range vt {
+			arr[i] = e
+		}
+		return t.mergeArray(arr, name)
+	case []interface{}:
+		return t.mergeArray(vt, name)
+	default:
+		return fmt.Errorf("field %s has unsupported type %T", name, v)
+	}
+	// bigint and float are compatible, promote to float
+	if (t.dataType == ast.FLOAT && dt == ast.BIGINT) || (t.dataType == ast.BIGINT && dt == ast.FLOAT) {
+		t.dataType = ast.FLOAT
+		return nil
+	}
+	return t.setType(dt, name)
+}
+
+func (t *sampleType) mergeArray(arr []interface{}, name string) error {
+	if len(arr) == 0 {
+		return nil
+	}
+	if err := t.setType(ast.ARRAY, name); err != nil {
+		return err
+	}
+	if t.items == nil {
+		t.items = &sampleType{}
+	}
+	for _, e := range arr {
+		if err := t.items.merge(e, name+"[]"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *sampleType) setType(dt ast.DataType, name string) error {
+	if t.dataType != ast.UNKNOWN && t.dataType != dt {
+		return fmt.Errorf("field %s has conflicting types %s and %s in the samples", name, t.dataType, dt)
+	}
+	t.dataType = dt
+	return nil
+}
+
+func (t *sampleType) toStreamFields() ast.StreamFields {
+	names := make([]string, 0, len(t.properties))
+	for k := range t.properties {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	result := make(ast.StreamFields, 0, len(names))
+	for _, k := range names {
+		if ft := t.properties[k].toFieldType(); ft != nil {
+			result = append(result, ast.StreamField{Name: k, FieldType: ft})
+		}
+	}
+	return result
+}
+
+func (t *sampleType) toFieldType() ast.FieldType {
+	switch t.dataType {
+	case ast.UNKNOWN:
+		return nil
+	case ast.STRUCT:
+		return &ast.RecType{StreamFields: t.toStreamFields()}
+	case ast.ARRAY:
+		// all elements are null
+		if t.items == nil || t.items.dataType == ast.UNKNOWN {
+			return nil
+		}
+		switch t.items.dataType {
+		case ast.STRUCT, ast.ARRAY:
+			ft := t.items.toFieldType()
+			if ft == nil {
+				return nil
+			}
+			return &ast.ArrayType{Type: t.items.dataType, FieldType: ft}
+		default:
+			return &ast.ArrayType{Type: t.items.dataType}
+		}
+	default:
+		return &ast.BasicType{Type: t.dataType}
+	}
+}
+
+// floatType infers the decoded number as bigint if it has no decimal part.
+// The json decoder decodes all numbers as float64.
+func floatType(f float64) ast.DataType {
+	if f == math.Trunc(f) && !math.IsInf(f, 0) {
+		return ast.BIGINT
+	}
+	return ast.FLOAT
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestInferFromSamples(t *testing.T) {
+	tests := []struct {
+		samples []map[string]interface{}
+		result  ast.StreamFields
+		err     string
+	}{
+		{
+			samples: []map[string]interface{}{
+				{"id": 1.0, "name": "a", "temp": 20.0, "ok": true, "ts": time.UnixMilli(0), "raw": []byte("a"), "tags": []interface{}{}, "none": nil},
+				{"id": 2.0, "name": "b", "temp": 20.5, "tags": []interface{}{"x", "y"}},
+			},
+			result: ast.StreamFields{
+				{Name: "id", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+				{Name: "name", FieldType: &ast.BasicType{Type: ast.STRINGS}},
+				{Name: "ok", FieldType: &ast.BasicType{Type: ast.BOOLEAN}},
+				{Name: "raw", FieldType: &ast.BasicType{Type: ast.BYTEA}},
+				{Name: "tags", FieldType: &ast.ArrayType{Type: ast.STRINGS}},
+				{Name: "temp", FieldType: &ast.BasicType{Type: ast.FLOAT}},
+				{Name: "ts", FieldType: &ast.BasicType{Type: ast.DATETIME}},
+			},
+		}, {
+			samples: []map[string]interface{}{
+				{"device": map[string]interface{}{"id": int64(1), "loc": map[string]interface{}{"lat": 31.2}}},
+				{"device": map[string]interface{}{"model": "x1"}, "readings": []interface{}{map[string]interface{}{"v": 1}, map[string]interface{}{"v": 1.5}}},
+				{"matrix": []interface{}{[]interface{}{1.0, 2.0}, []interface{}{3.0}}},
+			},
+			result: ast.StreamFields{
+				{Name: "device", FieldType: &ast.RecType{StreamFields: ast.StreamFields{
+					{Name: "id", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+					{Name: "loc", FieldType: &ast.RecType{StreamFields: ast.StreamFields{
+						{Name: "lat", FieldType: &ast.BasicType{Type: ast.FLOAT}},
+					}}},
+					{Name: "model", FieldType: &ast.BasicType{Type: ast.STRINGS}},
+				}}},
+				{Name: "matrix", FieldType: &ast.ArrayType{Type: ast.ARRAY, FieldType: &ast.ArrayType{Type: ast.BIGINT}}},
+				{Name: "readings", FieldType: &ast.ArrayType{Type: ast.STRUCT, FieldType: &ast.RecType{StreamFields: ast.StreamFields{
+					{Name: "v", FieldType: &ast.BasicType{Type: ast.FLOAT}},
+				}}}},
+			},
+		}, {
+			samples: []map[string]interface{}{
+				{"device": map[string]interface{}{"id": 1}},
+				{"device": map[string]interface{}{"id": "a"}},
+			},
+			err: "field device.id has conflicting types bigint and string in the samples",
+		}, {
+			samples: []map[string]interface{}{
+				{"v": struct{}{}},
+			},
+			err: "field v has unsupported type struct {}",
+		},
+	}
+	for i, tt := range tests {
+		result, err := InferFromSamples(tt.samples)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: expect error %s but got %v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.result, result) {
+			t.Errorf("%d: result mismatch\nexp\t%v\ngot\t%v", i, tt.result, result)
+		}
+	}
+}
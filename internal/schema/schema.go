@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -27,6 +27,8 @@ type Info struct {
 	Content  string         `json:"content"`
 	FilePath string         `json:"file"`
 	SoPath   string         `json:"soFile"`
+	// Registry refers to the schema in the external schema registry instead of the content or file
+	Registry *RegistryRef `json:"registry,omitempty"`
 }
 
 func (i *Info) InstallScript() string {
@@ -44,8 +46,23 @@ func (i *Info) Validate() error {
 	if i.Content != "" && i.FilePath != "" {
 		return fmt.Errorf("cannot specify both content and file")
 	}
+	if i.Registry != nil {
+		if i.Content != "" || i.FilePath != "" {
+			return fmt.Errorf("cannot specify registry with content or file")
+		}
+		if i.Registry.Subject == "" {
+			return fmt.Errorf("registry subject is required")
+		}
+	}
 	switch i.Type {
-	case def.PROTOBUF:
+	case def.PROTOBUF, def.AVRO:
+		if i.Content == "" && i.FilePath == "" && i.Registry == nil {
+			return fmt.Errorf("must specify content, file or registry")
+		}
+	case def.BINARY:
+		if i.Registry != nil {
+			return fmt.Errorf("registry is not supported for binary schema")
+		}
 		if i.Content == "" && i.FilePath == "" {
 			return fmt.Errorf("must specify content or file")
 		}
@@ -61,4 +78,6 @@ func (i *Info) Validate() error {
 
 var schemaExt = map[def.SchemaType]string{
 	def.PROTOBUF: ".proto",
+	def.AVRO:     ".avsc",
+	def.BINARY:   ".json",
 }
@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -59,7 +59,32 @@ func TestSchemaInfo(t *testing.T) {
 				Name:   "aa",
 				SoPath: "dd",
 			},
-			err: errors.New("must specify content or file"),
+			err: errors.New("must specify content, file or registry"),
+		},
+		{
+			i: &Info{
+				Type:     "protobuf",
+				Name:     "aa",
+				Content:  "bb",
+				Registry: &RegistryRef{Subject: "cc"},
+			},
+			err: errors.New("cannot specify registry with content or file"),
+		},
+		{
+			i: &Info{
+				Type:     "protobuf",
+				Name:     "aa",
+				Registry: &RegistryRef{Version: "1"},
+			},
+			err: errors.New("registry subject is required"),
+		},
+		{
+			i: &Info{
+				Type:     "protobuf",
+				Name:     "aa",
+				Registry: &RegistryRef{Subject: "cc"},
+			},
+			err: nil,
 		},
 		{
 			i: &Info{
@@ -78,6 +103,22 @@ func TestSchemaInfo(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			i: &Info{
+				Type:     "binary",
+				Name:     "aa",
+				Registry: &RegistryRef{Subject: "cc"},
+			},
+			err: errors.New("registry is not supported for binary schema"),
+		},
+		{
+			i: &Info{
+				Type:     "binary",
+				Name:     "aa",
+				FilePath: "file:///tmp/frames.json",
+			},
+			err: nil,
+		},
 		{
 			i: &Info{
 				Type:   "custom",
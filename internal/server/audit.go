@@ -0,0 +1,232 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/internal/server/middleware"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+var auditProcessor *processor.AuditProcessor
+
+// auditWriter records the status and the error message of the response
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.status >= http.StatusBadRequest {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// auditMiddleware records all the mutating management api calls. The read only calls are not audited.
+func auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tpl, _ := route.GetPathTemplate()
+		resource, action := parseAuditRoute(tpl, r.Method)
+		if resource == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var body []byte
+		if r.Body != nil && !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			body, _ = io.ReadAll(r.Body)
+			_ = r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		vars := mux.Vars(r)
+		name := vars["name"]
+		if name == "" {
+			name = vars["id"]
+		}
+		if name == "" && action == "create" {
+			name = nameFromBody(resource, body)
+		}
+		record := &processor.AuditRecord{
+			User:       middleware.GetUser(r),
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Resource:   resource,
+			Name:       name,
+			Action:     action,
+		}
+		if name != "" && action != "create" {
+			record.Before = getDefinition(resource, name)
+		}
+		aw := &auditWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(aw, r)
+		record.Status = aw.status
+		if aw.status >= http.StatusBadRequest {
+			record.Error = strings.TrimSpace(aw.body.String())
+		} else if action != "delete" {
+			if name != "" {
+				record.After = getDefinition(resource, name)
+			}
+			if record.After == "" && (action == "create" || action == "update") {
+				record.After = string(body)
+			}
+		}
+		if err := auditProcessor.Save(record); err != nil {
+			logger.Warnf("save audit record for %s %s error: %v", r.Method, r.URL.Path, err)
+		}
+		if conf.Config.Audit.Topic != "" {
+			m := make(map[string]interface{})
+			if b, err := json.Marshal(record); err == nil && json.Unmarshal(b, &m) == nil {
+				pubsub.Produce(context.Background(), conf.Config.Audit.Topic, m)
+			}
+		}
+	})
+}
+
+// parseAuditRoute gets the resource type and the action from the route template like /rules/{name}/start.
+// The resource is the singular of the first segment. The literal last segment after a variable is the operation
+// such as start and stop. Otherwise, the action is decided by the method.
+func parseAuditRoute(tpl string, method string) (string, string) {
+	segs := strings.Split(strings.Trim(tpl, "/"), "/")
+	resource := strings.TrimSuffix(segs[0], "s")
+	last := segs[len(segs)-1]
+	// the collection or the sub collection like /plugins/sources
+	if len(segs) > 1 && !strings.HasPrefix(last, "{") && (strings.HasPrefix(segs[len(segs)-2], "{") || !strings.HasSuffix(last, "s")) {
+		return resource, last
+	}
+	switch method {
+	case http.MethodPost:
+		return resource, "create"
+	case http.MethodPut:
+		return resource, "update"
+	default:
+		return resource, "delete"
+	}
+}
+
+// nameFromBody gets the resource name of the create request
+func nameFromBody(resource string, body []byte) string {
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(body, &m); err != nil {
+		return ""
+	}
+	switch resource {
+	case "stream", "table":
+		sql, _ := m["sql"].(string)
+		stmt, err := xsql.NewParser(strings.NewReader(sql)).ParseCreateStmt()
+		if err != nil {
+			return ""
+		}
+		if s, ok := stmt.(*ast.StreamStmt); ok {
+			return string(s.Name)
+		}
+	default:
+		for _, k := range []string{"id", "name"} {
+			if s, ok := m[k].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// getDefinition gets the definition of the rule, stream, table or pipeline to record the change
+func getDefinition(resource string, name string) string {
+	switch resource {
+	case "rule":
+		s, _ := ruleProcessor.GetRuleJson(name)
+		return s
+	case "stream":
+		s, _ := streamProcessor.GetStream(name, ast.TypeStream)
+		return s
+	case "table":
+		s, _ := streamProcessor.GetStream(name, ast.TypeTable)
+		return s
+	case "pipeline":
+		pl, err := pipelineProcessor.GetPipeline(name)
+		if err != nil {
+			return ""
+		}
+		b, _ := json.Marshal(pl)
+		return string(b)
+	}
+	return ""
+}
+
+// query the audit records, the from and to are unix milli timestamps
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	q := r.URL.Query()
+	f := &processor.AuditFilter{
+		User:     q.Get("user"),
+		Resource: q.Get("resource"),
+		Name:     q.Get("name"),
+		Action:   q.Get("action"),
+		Limit:    100,
+	}
+	for k, p := range map[string]*int64{"from": &f.From, "to": &f.To} {
+		if s := q.Get(k); s != "" {
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				handleError(w, err, "invalid "+k, logger)
+				return
+			}
+			*p = v
+		}
+	}
+	if s := q.Get("limit"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 {
+			handleError(w, fmt.Errorf("invalid limit %s, must be a positive integer", s), "", logger)
+			return
+		}
+		f.Limit = v
+	}
+	records, err := auditProcessor.Query(f)
+	if err != nil {
+		handleError(w, err, "query audit records error", logger)
+		return
+	}
+	jsonResponse(records, w, logger)
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -9,6 +10,14 @@ import (
 
 var notAuth = []string{"/", "/ping"}
 
+type userKey struct{}
+
+// GetUser returns the issuer of the token which identifies the caller, or empty if the request is not authenticated
+func GetUser(r *http.Request) string {
+	u, _ := r.Context().Value(userKey{}).(string)
+	return u
+}
+
 var Auth = func(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestPath := r.URL.Path
@@ -35,6 +44,6 @@ var Auth = func(next http.Handler) http.Handler {
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, tk.StandardClaims.Issuer)))
 	})
 }
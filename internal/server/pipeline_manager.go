@@ -0,0 +1,218 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+// A pipeline is deployed as the generated streams and rules, which are stored and recovered like the normal ones.
+// The pipeline manager only makes sure they are created, started, stopped and deleted together.
+
+const (
+	pipelineRunning = "running"
+	pipelineStopped = "stopped"
+	pipelinePartial = "partial"
+)
+
+func getPipelinePlan(id string) (*processor.PipelinePlan, error) {
+	pl, err := pipelineProcessor.GetPipeline(id)
+	if err != nil {
+		return nil, err
+	}
+	return pl.Plan()
+}
+
+// createPipeline validates the pipeline as a whole and then deploys all the streams and rules. If any of them fails,
+// the deployed ones are rolled back.
+func createPipeline(id, pipelineJson string) (string, error) {
+	pl, err := processor.GetPipelineByJson(id, pipelineJson)
+	if err != nil {
+		return "", fmt.Errorf("invalid pipeline json: %v", err)
+	}
+	plan, err := pl.Plan()
+	if err != nil {
+		return pl.Id, err
+	}
+	err = pipelineProcessor.ExecCreate(pl)
+	if err != nil {
+		return pl.Id, fmt.Errorf("store the pipeline error: %v", err)
+	}
+	var (
+		streams []string
+		rules   []string
+	)
+	rollback := func() {
+		for _, r := range rules {
+			deleteRule(r)
+			_, _ = ruleProcessor.ExecDrop(r)
+		}
+		for _, s := range streams {
+			_, _ = streamProcessor.DropStream(s, ast.TypeStream)
+		}
+		_ = pipelineProcessor.ExecDrop(pl.Id)
+	}
+	for _, sp := range plan.Stages {
+		if sp.StreamSql != "" {
+			if _, err := streamProcessor.ExecStmt(sp.StreamSql); err != nil {
+				rollback()
+				return pl.Id, fmt.Errorf("create stream for stage %s error: %v", sp.Name, err)
+			}
+			streams = append(streams, sp.Name)
+		}
+		if _, err := createRule(sp.RuleId, sp.RuleJson); err != nil {
+			rollback()
+			return pl.Id, fmt.Errorf("create rule for stage %s error: %v", sp.Name, err)
+		}
+		rules = append(rules, sp.RuleId)
+	}
+	if pl.Triggered == nil || *pl.Triggered {
+		if err := startPipeline(pl.Id); err != nil {
+			return pl.Id, fmt.Errorf("pipeline %s is created but fails to start: %v", pl.Id, err)
+		}
+	}
+	return pl.Id, nil
+}
+
+// startPipeline starts the stages from the downstream ones so that no data is lost in the memory topics.
+// If any stage fails to start, the started stages are stopped.
+func startPipeline(id string) error {
+	plan, err := getPipelinePlan(id)
+	if err != nil {
+		return err
+	}
+	for i := len(plan.Stages) - 1; i >= 0; i-- {
+		if err := startRule(plan.Stages[i].RuleId); err != nil {
+			for j := i + 1; j < len(plan.Stages); j++ {
+				stopRule(plan.Stages[j].RuleId)
+			}
+			return fmt.Errorf("start stage %s error: %v", plan.Stages[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// stopPipeline stops the stages from the upstream ones
+func stopPipeline(id string) (string, error) {
+	plan, err := getPipelinePlan(id)
+	if err != nil {
+		return "", err
+	}
+	for _, sp := range plan.Stages {
+		stopRule(sp.RuleId)
+	}
+	return fmt.Sprintf("Pipeline %s was stopped.", id), nil
+}
+
+func deletePipeline(id string) (string, error) {
+	plan, err := getPipelinePlan(id)
+	if err != nil {
+		return "", err
+	}
+	for _, sp := range plan.Stages {
+		deleteRule(sp.RuleId)
+		if _, err := ruleProcessor.ExecDrop(sp.RuleId); err != nil {
+			logger.Warnf("drop rule %s of pipeline %s error: %v", sp.RuleId, id, err)
+		}
+	}
+	for _, sp := range plan.Stages {
+		if sp.StreamSql != "" {
+			if _, err := streamProcessor.DropStream(sp.Name, ast.TypeStream); err != nil {
+				logger.Warnf("drop stream %s of pipeline %s error: %v", sp.Name, id, err)
+			}
+		}
+	}
+	err = pipelineProcessor.ExecDrop(id)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Pipeline %s was deleted.", id), nil
+}
+
+// getPipelineStatus returns the status of the pipeline and the state of each stage. The pipeline is running only if
+// all the stages are running.
+func getPipelineStatus(id string) (map[string]interface{}, error) {
+	plan, err := getPipelinePlan(id)
+	if err != nil {
+		return nil, err
+	}
+	stages := make(map[string]interface{}, len(plan.Stages))
+	running := 0
+	for _, sp := range plan.Stages {
+		s, err := getRuleState(sp.RuleId)
+		if err != nil {
+			s = fmt.Sprintf("error: %s", err)
+		}
+		if s == "Running" {
+			running++
+		}
+		stages[sp.Name] = map[string]interface{}{
+			"rule":   sp.RuleId,
+			"status": s,
+		}
+	}
+	status := pipelinePartial
+	switch running {
+	case 0:
+		status = pipelineStopped
+	case len(plan.Stages):
+		status = pipelineRunning
+	}
+	return map[string]interface{}{
+		"status": status,
+		"stages": stages,
+	}, nil
+}
+
+func getAllPipelinesWithStatus() ([]map[string]interface{}, error) {
+	ids, err := pipelineProcessor.GetAllPipelines()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	result := make([]map[string]interface{}, len(ids))
+	for i, id := range ids {
+		var s interface{}
+		status, err := getPipelineStatus(id)
+		if err != nil {
+			s = fmt.Sprintf("error: %s", err)
+		} else {
+			s = status["status"]
+		}
+		result[i] = map[string]interface{}{
+			"id":     id,
+			"status": s,
+		}
+	}
+	return result, nil
+}
+
+func getPipelineTopo(id string) (string, error) {
+	plan, err := getPipelinePlan(id)
+	if err != nil {
+		return "", err
+	}
+	bs, err := json.Marshal(plan.Topo())
+	if err != nil {
+		return "", errorx.New(fmt.Sprintf("Fail to encode pipeline %s's topo", id))
+	}
+	return string(bs), nil
+}
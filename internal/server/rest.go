@@ -38,6 +38,7 @@ import (
 	"github.com/lf-edge/ekuiper/internal/pkg/httpx"
 	"github.com/lf-edge/ekuiper/internal/processor"
 	"github.com/lf-edge/ekuiper/internal/server/middleware"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/pool"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/ast"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
@@ -129,16 +130,36 @@ func createRestServer(ip string, port int, needToken bool) *http.Server {
 	r.HandleFunc("/streams", streamsHandler).Methods(http.MethodGet, http.MethodPost)
 	r.HandleFunc("/streams/{name}", streamHandler).Methods(http.MethodGet, http.MethodDelete, http.MethodPut)
 	r.HandleFunc("/streams/{name}/schema", streamSchemaHandler).Methods(http.MethodGet)
+	r.HandleFunc("/streams/{name}/infer", streamInferHandler).Methods(http.MethodPost)
 	r.HandleFunc("/tables", tablesHandler).Methods(http.MethodGet, http.MethodPost)
 	r.HandleFunc("/tables/{name}", tableHandler).Methods(http.MethodGet, http.MethodDelete, http.MethodPut)
 	r.HandleFunc("/tables/{name}/schema", tableSchemaHandler).Methods(http.MethodGet)
 	r.HandleFunc("/rules", rulesHandler).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/rules/dependencies", ruleDependenciesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/rules/validate", validateRuleHandler).Methods(http.MethodPost)
 	r.HandleFunc("/rules/{name}", ruleHandler).Methods(http.MethodDelete, http.MethodGet, http.MethodPut)
 	r.HandleFunc("/rules/{name}/status", getStatusRuleHandler).Methods(http.MethodGet)
 	r.HandleFunc("/rules/{name}/start", startRuleHandler).Methods(http.MethodPost)
 	r.HandleFunc("/rules/{name}/stop", stopRuleHandler).Methods(http.MethodPost)
 	r.HandleFunc("/rules/{name}/restart", restartRuleHandler).Methods(http.MethodPost)
 	r.HandleFunc("/rules/{name}/topo", getTopoRuleHandler).Methods(http.MethodGet)
+	r.HandleFunc("/rules/{name}/debug", debugRuleHandler).Methods(http.MethodGet)
+	r.HandleFunc("/rules/{name}/explain", explainRuleHandler).Methods(http.MethodGet)
+	r.HandleFunc("/rules/{name}/versions", ruleVersionsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/rules/{name}/versions/{version}", ruleVersionHandler).Methods(http.MethodGet)
+	r.HandleFunc("/rules/{name}/versions/{version}/rollback", rollbackRuleHandler).Methods(http.MethodPost)
+	r.HandleFunc("/rules/{name}/diff", diffRuleHandler).Methods(http.MethodGet)
+	r.HandleFunc("/rules/{name}/savepoints", ruleSavepointsHandler).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/rules/{name}/savepoints/{savepoint}", ruleSavepointHandler).Methods(http.MethodDelete)
+	r.HandleFunc("/ruletest", ruleTestHandler).Methods(http.MethodPost)
+	r.HandleFunc("/pipelines", pipelinesHandler).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/pipelines/{name}", pipelineHandler).Methods(http.MethodDelete, http.MethodGet)
+	r.HandleFunc("/pipelines/{name}/status", getStatusPipelineHandler).Methods(http.MethodGet)
+	r.HandleFunc("/pipelines/{name}/start", startPipelineHandler).Methods(http.MethodPost)
+	r.HandleFunc("/pipelines/{name}/stop", stopPipelineHandler).Methods(http.MethodPost)
+	r.HandleFunc("/pipelines/{name}/topo", getTopoPipelineHandler).Methods(http.MethodGet)
+	r.HandleFunc("/connections", connectionsHandler).Methods(http.MethodGet, http.MethodPost)
+	r.HandleFunc("/connections/{id}", connectionHandler).Methods(http.MethodGet, http.MethodPut, http.MethodDelete)
 	r.HandleFunc("/ruleset/export", exportHandler).Methods(http.MethodPost)
 	r.HandleFunc("/ruleset/import", importHandler).Methods(http.MethodPost)
 	r.HandleFunc("/config/uploads", fileUploadHandler).Methods(http.MethodPost, http.MethodGet)
@@ -155,6 +176,10 @@ func createRestServer(ip string, port int, needToken bool) *http.Server {
 	if needToken {
 		r.Use(middleware.Auth)
 	}
+	if auditProcessor != nil {
+		r.HandleFunc("/audit", auditHandler).Methods(http.MethodGet)
+		r.Use(auditMiddleware)
+	}
 
 	server := &http.Server{
 		Addr: fmt.Sprintf("%s:%d", ip, port),
@@ -415,6 +440,42 @@ func sourceSchemaHandler(w http.ResponseWriter, r *http.Request, st ast.StreamTy
 	jsonResponse(content, w, logger)
 }
 
+type inferDescriptor struct {
+	// Count is the max number of tuples to sample
+	Count int `json:"count"`
+	// Timeout is the max duration in milliseconds to wait for the samples
+	Timeout int `json:"timeout"`
+}
+
+// infer the schema of a stream by sampling its source
+func streamInferHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+	d := &inferDescriptor{Count: 10, Timeout: 10000}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		handleError(w, err, "Invalid body", logger)
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, d); err != nil {
+			handleError(w, err, "Invalid body", logger)
+			return
+		}
+	}
+	if d.Count <= 0 || d.Timeout <= 0 {
+		handleError(w, fmt.Errorf("count and timeout must be positive"), "Invalid body", logger)
+		return
+	}
+	content, err := streamProcessor.SampleSchema(name, ast.TypeStream, d.Count, time.Duration(d.Timeout)*time.Millisecond)
+	if err != nil {
+		handleError(w, err, "infer schema of stream error", logger)
+		return
+	}
+	jsonResponse(content, w, logger)
+}
+
 // list or create rules
 func rulesHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
@@ -425,7 +486,8 @@ func rulesHandler(w http.ResponseWriter, r *http.Request) {
 			handleError(w, err, "Invalid body", logger)
 			return
 		}
-		id, err := createRule("", string(body))
+		q := r.URL.Query()
+		id, err := createRuleFromSavepoint("", string(body), q.Get("from"), q.Get("savepoint"))
 		if err != nil {
 			handleError(w, err, "", logger)
 			return
@@ -479,7 +541,11 @@ func ruleHandler(w http.ResponseWriter, r *http.Request) {
 			handleError(w, err, "Invalid body", logger)
 			return
 		}
-		err = updateRule(name, string(body))
+		strategy := r.URL.Query().Get("strategy")
+		if strategy == "" {
+			strategy = updateStrategyRestart
+		}
+		err = updateRuleWithStrategy(name, string(body), strategy)
 		if err != nil {
 			handleError(w, err, "Update rule error", logger)
 			return
@@ -520,7 +586,12 @@ func startRuleHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
 
-	err := startRule(name)
+	var err error
+	if sp := r.URL.Query().Get("savepoint"); sp != "" {
+		err = startRuleFromSavepoint(name, r.URL.Query().Get("from"), sp)
+	} else {
+		err = startRule(name)
+	}
 	if err != nil {
 		handleError(w, err, "start rule error", logger)
 		return
@@ -570,6 +641,381 @@ func getTopoRuleHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(content))
 }
 
+// list the history versions of a rule
+func ruleVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	versions, err := ruleProcessor.GetRuleVersions(name)
+	if err != nil {
+		handleError(w, err, "get rule versions error", logger)
+		return
+	}
+	jsonResponse(versions, w, logger)
+}
+
+func parseVersion(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid version %s", s)
+	}
+	return v, nil
+}
+
+// describe a history version of a rule
+func ruleVersionHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+	version, err := parseVersion(vars["version"])
+	if err != nil {
+		handleError(w, err, "", logger)
+		return
+	}
+
+	v, err := ruleProcessor.GetRuleVersion(name, version)
+	if err != nil {
+		handleError(w, err, "get rule version error", logger)
+		return
+	}
+	w.Header().Add(ContentType, ContentTypeJSON)
+	w.Write([]byte(v.Rule))
+}
+
+// roll back a rule to a history version
+func rollbackRuleHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+	version, err := parseVersion(vars["version"])
+	if err != nil {
+		handleError(w, err, "", logger)
+		return
+	}
+
+	err = rollbackRule(name, version)
+	if err != nil {
+		handleError(w, err, "rollback rule error", logger)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("Rule %s was rolled back to version %d.", name, version)))
+}
+
+// list or create the savepoints of a rule
+func ruleSavepointsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	switch r.Method {
+	case http.MethodGet:
+		savepoints, err := ruleProcessor.GetSavepoints(name)
+		if err != nil {
+			handleError(w, err, "get rule savepoints error", logger)
+			return
+		}
+		jsonResponse(savepoints, w, logger)
+	case http.MethodPost:
+		sp := &struct {
+			Name string `json:"name"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(sp); err != nil {
+			handleError(w, err, "Invalid body", logger)
+			return
+		}
+		if err := createSavepoint(name, sp.Name); err != nil {
+			handleError(w, err, "create rule savepoint error", logger)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(fmt.Sprintf("Savepoint %s of rule %s was created.", sp.Name, name)))
+	}
+}
+
+// delete a savepoint of a rule
+func ruleSavepointHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+	savepoint := vars["savepoint"]
+
+	if err := ruleProcessor.ExecDropSavepoint(name, savepoint); err != nil {
+		handleError(w, err, "delete rule savepoint error", logger)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("Savepoint %s of rule %s was deleted.", savepoint, name)))
+}
+
+// diff two history versions of a rule, the versions default to the previous one and the latest one
+func diffRuleHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	var from, to int
+	if s := r.URL.Query().Get("to"); s != "" {
+		v, err := parseVersion(s)
+		if err != nil {
+			handleError(w, err, "", logger)
+			return
+		}
+		to = v
+	}
+	if s := r.URL.Query().Get("from"); s != "" {
+		v, err := parseVersion(s)
+		if err != nil {
+			handleError(w, err, "", logger)
+			return
+		}
+		from = v
+	} else {
+		tv, err := ruleProcessor.GetRuleVersion(name, to)
+		if err != nil {
+			handleError(w, err, "diff rule error", logger)
+			return
+		}
+		if tv.Version <= 1 {
+			handleError(w, fmt.Errorf("rule %s has no version before %d", name, tv.Version), "diff rule error", logger)
+			return
+		}
+		from = tv.Version - 1
+	}
+	changes, err := ruleProcessor.DiffRuleVersions(name, from, to)
+	if err != nil {
+		handleError(w, err, "diff rule error", logger)
+		return
+	}
+	jsonResponse(changes, w, logger)
+}
+
+// explain the physical plan of a rule with the runtime metrics
+func explainRuleHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	nodes, err := explainRule(name)
+	if err != nil {
+		handleError(w, err, "explain rule error", logger)
+		return
+	}
+	jsonResponse(nodes, w, logger)
+}
+
+// dry run the rule to validate it without creating
+func validateRuleHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		handleError(w, err, "Invalid body", logger)
+		return
+	}
+	jsonResponse(validateRule(string(body)), w, logger)
+}
+
+// run the rule with the input fixtures and compare the outputs with the expected
+func ruleTestHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		handleError(w, err, "Invalid body", logger)
+		return
+	}
+	result, err := runRuleTest(string(body))
+	if err != nil {
+		handleError(w, err, "rule test error", logger)
+		return
+	}
+	jsonResponse(result, w, logger)
+}
+
+// get the dependencies of the rules connected by memory topics
+func ruleDependenciesHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	deps, err := getAllRuleDependencies()
+	if err != nil {
+		handleError(w, err, "get rule dependencies error", logger)
+		return
+	}
+	jsonResponse(deps, w, logger)
+}
+
+// list or create pipelines
+func pipelinesHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	switch r.Method {
+	case http.MethodPost:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			handleError(w, err, "Invalid body", logger)
+			return
+		}
+		id, err := createPipeline("", string(body))
+		if err != nil {
+			handleError(w, err, "", logger)
+			return
+		}
+		result := fmt.Sprintf("Pipeline %s was created successfully.", id)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(result))
+	case http.MethodGet:
+		content, err := getAllPipelinesWithStatus()
+		if err != nil {
+			handleError(w, err, "Show pipelines error", logger)
+			return
+		}
+		jsonResponse(content, w, logger)
+	}
+}
+
+// describe or delete a pipeline
+func pipelineHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	switch r.Method {
+	case http.MethodGet:
+		pl, err := pipelineProcessor.GetPipeline(name)
+		if err != nil {
+			handleError(w, err, "Describe pipeline error", logger)
+			return
+		}
+		jsonResponse(pl, w, logger)
+	case http.MethodDelete:
+		content, err := deletePipeline(name)
+		if err != nil {
+			handleError(w, err, "Delete pipeline error", logger)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(content))
+	}
+}
+
+// get status of a pipeline and its stages
+func getStatusPipelineHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	content, err := getPipelineStatus(name)
+	if err != nil {
+		handleError(w, err, "get pipeline status error", logger)
+		return
+	}
+	jsonResponse(content, w, logger)
+}
+
+// start a pipeline
+func startPipelineHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	err := startPipeline(name)
+	if err != nil {
+		handleError(w, err, "start pipeline error", logger)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("Pipeline %s was started", name)))
+}
+
+// stop a pipeline
+func stopPipelineHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	result, err := stopPipeline(name)
+	if err != nil {
+		handleError(w, err, "stop pipeline error", logger)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(result))
+}
+
+// get topo of a pipeline
+func getTopoPipelineHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+
+	content, err := getPipelineTopo(name)
+	if err != nil {
+		handleError(w, err, "get pipeline topo error", logger)
+		return
+	}
+	w.Header().Set(ContentType, ContentTypeJSON)
+	w.Write([]byte(content))
+}
+
+// list or create shared connections
+func connectionsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	switch r.Method {
+	case http.MethodPost:
+		c := &pool.Connection{}
+		if err := json.NewDecoder(r.Body).Decode(c); err != nil {
+			handleError(w, err, "Invalid body: Error decoding json", logger)
+			return
+		}
+		if err := pool.CreateConnection(c); err != nil {
+			handleError(w, err, "Create connection error", logger)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(fmt.Sprintf("Connection %s was created successfully.", c.ID)))
+	case http.MethodGet:
+		jsonResponse(pool.GetAllConnectionStatus(), w, logger)
+	}
+}
+
+// describe, update or delete a shared connection
+func connectionHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	switch r.Method {
+	case http.MethodGet:
+		s, err := pool.GetConnectionStatus(id)
+		if err != nil {
+			handleError(w, err, "Describe connection error", logger)
+			return
+		}
+		jsonResponse(s, w, logger)
+	case http.MethodPut:
+		c := &pool.Connection{}
+		if err := json.NewDecoder(r.Body).Decode(c); err != nil {
+			handleError(w, err, "Invalid body: Error decoding json", logger)
+			return
+		}
+		if c.ID != id {
+			handleError(w, fmt.Errorf("connection id %s is not consistent with %s in the url", c.ID, id), "Update connection error", logger)
+			return
+		}
+		if err := pool.UpdateConnection(c); err != nil {
+			handleError(w, err, "Update connection error", logger)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf("Connection %s was updated successfully.", id)))
+	case http.MethodDelete:
+		if err := pool.DeleteConnection(id); err != nil {
+			handleError(w, err, "Delete connection error", logger)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf("Connection %s is deleted.", id)))
+	}
+}
+
 type rulesetInfo struct {
 	Content  string `json:"content"`
 	FilePath string `json:"file"`
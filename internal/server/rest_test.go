@@ -24,12 +24,15 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
 	"github.com/lf-edge/ekuiper/internal/processor"
 	"github.com/lf-edge/ekuiper/internal/testx"
 	"github.com/lf-edge/ekuiper/internal/topo/rule"
@@ -61,6 +64,7 @@ func (suite *RestTestSuite) SetupTest() {
 	r.HandleFunc("/streams", streamsHandler).Methods(http.MethodGet, http.MethodPost)
 	r.HandleFunc("/streams/{name}", streamHandler).Methods(http.MethodGet, http.MethodDelete, http.MethodPut)
 	r.HandleFunc("/streams/{name}/schema", streamSchemaHandler).Methods(http.MethodGet)
+	r.HandleFunc("/streams/{name}/infer", streamInferHandler).Methods(http.MethodPost)
 	r.HandleFunc("/tables", tablesHandler).Methods(http.MethodGet, http.MethodPost)
 	r.HandleFunc("/tables/{name}", tableHandler).Methods(http.MethodGet, http.MethodDelete, http.MethodPut)
 	r.HandleFunc("/tables/{name}/schema", tableSchemaHandler).Methods(http.MethodGet)
@@ -186,6 +190,53 @@ func (suite *RestTestSuite) Test_sourcesManageHandler() {
 	assert.Equal(suite.T(), http.StatusOK, w.Code)
 }
 
+func (suite *RestTestSuite) Test_streamInferHandler() {
+	buf := bytes.NewBuffer([]byte(`{"sql":"CREATE stream inferStream() WITH (DATASOURCE=\"test/infer\", TYPE=\"memory\")"}`))
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost:8080/streams", buf)
+	w := httptest.NewRecorder()
+	suite.r.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusCreated, w.Code)
+	defer func() {
+		req, _ := http.NewRequest(http.MethodDelete, "http://localhost:8080/streams/inferStream", bytes.NewBufferString("any"))
+		suite.r.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	// the memory source only receives the data after it subscribes, so keep producing
+	pubsub.CreatePub("test/infer")
+	defer pubsub.RemovePub("test/infer")
+	ctx, cancel := mockContext.NewMockContext("ruleInfer", "op1").WithCancel()
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			select {
+			case <-ticker.C:
+				pubsub.Produce(ctx, "test/infer", map[string]interface{}{"id": i, "temperature": 20.5, "tags": []interface{}{"a"}})
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	req, _ = http.NewRequest(http.MethodPost, "http://localhost:8080/streams/inferStream/infer", bytes.NewBufferString(`{"count":2,"timeout":5000}`))
+	w = httptest.NewRecorder()
+	suite.r.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	returnVal, _ := io.ReadAll(w.Result().Body)
+	assert.JSONEq(suite.T(), `{"id":{"type":"bigint"},"tags":{"type":"array","items":{"type":"string"}},"temperature":{"type":"float"}}`, string(returnVal))
+
+	req, _ = http.NewRequest(http.MethodPost, "http://localhost:8080/streams/inferStream/infer", bytes.NewBufferString(`{"count":0}`))
+	w = httptest.NewRecorder()
+	suite.r.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	req, _ = http.NewRequest(http.MethodPost, "http://localhost:8080/streams/notExist/infer", bytes.NewBufferString(""))
+	w = httptest.NewRecorder()
+	suite.r.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
 func (suite *RestTestSuite) Test_rulesManageHandler() {
 	// Start rules
 	if rules, err := ruleProcessor.GetAllRules(); err != nil {
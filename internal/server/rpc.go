@@ -247,6 +247,108 @@ func (t *Server) DropRule(name string, reply *string) error {
 	return nil
 }
 
+func (t *Server) TestRule(testJson string, reply *string) error {
+	r, err := runRuleTest(testJson)
+	if err != nil {
+		return fmt.Errorf("Test rule error : %s.", err)
+	}
+	result, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Test rule error : %s.", err)
+	}
+	if r.Pass {
+		*reply = fmt.Sprintf("Rule test passed.\n%s", result)
+	} else {
+		*reply = fmt.Sprintf("Rule test failed.\n%s", result)
+	}
+	return nil
+}
+
+func (t *Server) CreatePipeline(arg *model.RPCArgDesc, reply *string) error {
+	id, err := createPipeline(arg.Name, arg.Json)
+	if err != nil {
+		return fmt.Errorf("Create pipeline %s error : %s.", id, err)
+	} else {
+		*reply = fmt.Sprintf("Pipeline %s was created successfully, please use 'bin/kuiper getstatus pipeline %s' command to get pipeline status.", id, id)
+	}
+	return nil
+}
+
+func (t *Server) GetStatusPipeline(name string, reply *string) error {
+	r, err := getPipelineStatus(name)
+	if err != nil {
+		return err
+	}
+	*reply, err = marshalDesc(r)
+	return err
+}
+
+func (t *Server) GetTopoPipeline(name string, reply *string) error {
+	if r, err := getPipelineTopo(name); err != nil {
+		return err
+	} else {
+		dst := &bytes.Buffer{}
+		if err = json.Indent(dst, []byte(r), "", "  "); err != nil {
+			*reply = r
+		} else {
+			*reply = dst.String()
+		}
+	}
+	return nil
+}
+
+func (t *Server) StartPipeline(name string, reply *string) error {
+	if err := startPipeline(name); err != nil {
+		return err
+	} else {
+		*reply = fmt.Sprintf("Pipeline %s was started", name)
+	}
+	return nil
+}
+
+func (t *Server) StopPipeline(name string, reply *string) error {
+	r, err := stopPipeline(name)
+	if err != nil {
+		return err
+	}
+	*reply = r
+	return nil
+}
+
+func (t *Server) DescPipeline(name string, reply *string) error {
+	pl, err := pipelineProcessor.GetPipeline(name)
+	if err != nil {
+		return fmt.Errorf("Desc pipeline error : %s.", err)
+	}
+	*reply, err = marshalDesc(pl)
+	return err
+}
+
+func (t *Server) ShowPipelines(_ int, reply *string) error {
+	r, err := getAllPipelinesWithStatus()
+	if err != nil {
+		return fmt.Errorf("Show pipeline error : %s.", err)
+	}
+	if len(r) == 0 {
+		*reply = "No pipeline definitions are found."
+	} else {
+		*reply, err = marshalDesc(r)
+		if err != nil {
+			return fmt.Errorf("Show pipeline error : %s.", err)
+		}
+	}
+	return nil
+}
+
+func (t *Server) DropPipeline(name string, reply *string) error {
+	r, err := deletePipeline(name)
+	if err != nil {
+		return fmt.Errorf("Drop pipeline error : %s.", err)
+	}
+	*reply = r
+	return nil
+}
+
 func (t *Server) Import(file string, reply *string) error {
 	f, err := os.Open(file)
 	if err != nil {
@@ -0,0 +1,163 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	ws "github.com/gorilla/websocket"
+
+	"github.com/lf-edge/ekuiper/internal/topo/node"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+const (
+	// the max duration of a debug session which must be shorter than the write timeout of the rest server
+	maxDebugDuration = 5 * time.Minute
+	maxDebugRate     = 100
+)
+
+var debugUpgrader = ws.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// debugRuleHandler taps the outputs of an operator of a running rule. The outputs are streamed as json lines or
+// websocket messages if the request is a websocket upgrade, until the duration or the limit is reached.
+func debugRuleHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	name := mux.Vars(r)["name"]
+	q := r.URL.Query()
+	op := q.Get("operator")
+	if op == "" {
+		handleError(w, fmt.Errorf("operator is required"), "debug rule error", logger)
+		return
+	}
+	var (
+		sample   = 1.0
+		rate     = 10
+		duration = time.Minute
+		limit    = 0
+	)
+	if s := q.Get("sample"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 || v > 1 {
+			handleError(w, fmt.Errorf("invalid sample %s, must be in (0, 1]", s), "debug rule error", logger)
+			return
+		}
+		sample = v
+	}
+	if s := q.Get("rate"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 || v > maxDebugRate {
+			handleError(w, fmt.Errorf("invalid rate %s, must be in [1, %d]", s, maxDebugRate), "debug rule error", logger)
+			return
+		}
+		rate = v
+	}
+	if s := q.Get("duration"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v <= 0 || time.Duration(v)*time.Second > maxDebugDuration {
+			handleError(w, fmt.Errorf("invalid duration %s, must be in [1, %d] seconds", s, int(maxDebugDuration.Seconds())), "debug rule error", logger)
+			return
+		}
+		duration = time.Duration(v) * time.Second
+	}
+	if s := q.Get("limit"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 {
+			handleError(w, fmt.Errorf("invalid limit %s, must be a non-negative integer", s), "debug rule error", logger)
+			return
+		}
+		limit = v
+	}
+	rs, ok := registry.Load(name)
+	if !ok {
+		handleError(w, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("Rule %s is not found", name)), "debug rule error", logger)
+		return
+	}
+	tap := node.NewTap(sample, rate, rate)
+	stop, err := rs.Tap(op, tap)
+	if err != nil {
+		handleError(w, err, "debug rule error", logger)
+		return
+	}
+	defer stop()
+	logger.Infof("start to debug operator %s of rule %s", op, name)
+
+	var (
+		send func(b []byte) error
+		done = r.Context().Done()
+	)
+	if ws.IsWebSocketUpgrade(r) {
+		conn, err := debugUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has replied the error
+			logger.Errorf("upgrade debug session of rule %s error: %v", name, err)
+			return
+		}
+		defer conn.Close()
+		// read to process the close message from the client
+		closed := make(chan struct{})
+		done = closed
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+		send = func(b []byte) error {
+			return conn.WriteMessage(ws.TextMessage, b)
+		}
+	} else {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			handleError(w, fmt.Errorf("streaming is not supported"), "debug rule error", logger)
+			return
+		}
+		w.Header().Set(ContentType, "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		send = func(b []byte) error {
+			if _, err := w.Write(append(b, '\n')); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		}
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	for count := 0; limit == 0 || count < limit; count++ {
+		select {
+		case b := <-tap.Data():
+			if err := send(b); err != nil {
+				logger.Infof("stop debugging operator %s of rule %s: %v", op, name, err)
+				return
+			}
+		case <-timer.C:
+			logger.Infof("debug operator %s of rule %s timeout, %d outputs dropped", op, name, tap.Dropped())
+			return
+		case <-done:
+			return
+		}
+	}
+}
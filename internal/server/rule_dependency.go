@@ -0,0 +1,218 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/internal/io/memory"
+	store2 "github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+// Rules are connected by memory topics: a rule produces a topic by a memory sink and other rules consume it by a
+// memory stream or table. The memory topics drop the data when there is no subscriber, so the consumer rules must be
+// started before the producer rules to avoid missing the early events.
+
+// ruleEndpoints is the memory topics which a rule consumes and produces
+type ruleEndpoints struct {
+	consumes []string
+	produces []string
+}
+
+// ruleDependencies is the dependency graph of the rules
+type ruleDependencies struct {
+	// Order is the startup order of the rules, the consumers are started before their producers
+	Order []string `json:"order"`
+	// Edges maps the producer rule to its consumer rules
+	Edges map[string][]string `json:"edges"`
+}
+
+func getRuleEndpoints(r *api.Rule, store kv.KeyValue) *ruleEndpoints {
+	e := &ruleEndpoints{}
+	addSource := func(options *ast.Options) {
+		if options != nil && options.TYPE == "memory" && options.DATASOURCE != "" {
+			e.consumes = append(e.consumes, options.DATASOURCE)
+		}
+	}
+	addSink := func(sinkType string, props interface{}) {
+		if sinkType != "memory" {
+			return
+		}
+		m, _ := props.(map[string]interface{})
+		// Dynamic topics are decided by the data, so they cannot be known in advance
+		if topic, ok := m["topic"].(string); ok && topic != "" && !strings.Contains(topic, "{{") {
+			e.produces = append(e.produces, topic)
+		}
+	}
+	addStream := func(name string) {
+		if streamStmt, err := xsql.GetDataSource(store, name); err == nil {
+			addSource(streamStmt.Options)
+		}
+	}
+	if r.Sql != "" {
+		stmt, err := xsql.GetStatementFromSql(r.Sql)
+		if err != nil {
+			return e
+		}
+		for _, s := range xsql.GetStreams(stmt) {
+			addStream(s)
+		}
+		for _, m := range r.Actions {
+			for name, action := range m {
+				addSink(name, action)
+			}
+		}
+	} else if r.Graph != nil {
+		for _, gn := range r.Graph.Nodes {
+			switch gn.Type {
+			case "source":
+				sourceMeta := &api.SourceMeta{}
+				if err := cast.MapToStruct(gn.Props, sourceMeta); err == nil && sourceMeta.SourceName != "" {
+					addStream(sourceMeta.SourceName)
+				} else if gn.NodeType == "memory" {
+					options := &ast.Options{}
+					if err := cast.MapToStruct(gn.Props, options); err == nil {
+						options.TYPE = gn.NodeType
+						addSource(options)
+					}
+				}
+			case "sink":
+				addSink(gn.NodeType, gn.Props)
+			}
+		}
+	}
+	return e
+}
+
+// buildRuleDependencies links the rules by their memory topics and sorts them so that each rule is after all its
+// consumers. The rules in a cycle are appended at last by their ids.
+func buildRuleDependencies(endpoints map[string]*ruleEndpoints) *ruleDependencies {
+	ids := make([]string, 0, len(endpoints))
+	for id := range endpoints {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	edges := make(map[string][]string)
+	producers := make(map[string][]string)
+	for _, p := range ids {
+		for _, c := range ids {
+			if p != c && consumesAny(endpoints[c].consumes, endpoints[p].produces) {
+				edges[p] = append(edges[p], c)
+				producers[c] = append(producers[c], p)
+			}
+		}
+	}
+	// The number of consumers which are not started yet
+	pending := make(map[string]int, len(ids))
+	var ready []string
+	for _, id := range ids {
+		pending[id] = len(edges[id])
+		if pending[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+	order := make([]string, 0, len(ids))
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+		for _, p := range producers[id] {
+			pending[p]--
+			if pending[p] == 0 {
+				ready = append(ready, p)
+			}
+		}
+	}
+	if len(order) < len(ids) {
+		var cycle []string
+		for _, id := range ids {
+			if pending[id] > 0 {
+				cycle = append(cycle, id)
+			}
+		}
+		logger.Warnf("rules %v depend on each other by memory topics, start them by id", cycle)
+		order = append(order, cycle...)
+	}
+	return &ruleDependencies{
+		Order: order,
+		Edges: edges,
+	}
+}
+
+func consumesAny(subs []string, topics []string) bool {
+	for _, sub := range subs {
+		for _, topic := range topics {
+			if memory.MatchTopic(sub, topic) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getRuleDependencies(rules []*api.Rule) (*ruleDependencies, error) {
+	store, err := store2.GetKV("stream")
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make(map[string]*ruleEndpoints, len(rules))
+	for _, r := range rules {
+		endpoints[r.Id] = getRuleEndpoints(r, store)
+	}
+	return buildRuleDependencies(endpoints), nil
+}
+
+// getAllRuleDependencies computes the dependencies of all the stored rules. The rules which fail to load are ignored.
+func getAllRuleDependencies() (*ruleDependencies, error) {
+	ids, err := ruleProcessor.GetAllRules()
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*api.Rule, 0, len(ids))
+	for _, id := range ids {
+		r, err := ruleProcessor.GetRuleById(id)
+		if err != nil {
+			logger.Warnf("load rule %s for dependencies error: %v", id, err)
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return getRuleDependencies(rules)
+}
+
+// sortRulesForStartup returns the rules in the startup order. If the dependencies cannot be computed, the original
+// order is kept.
+func sortRulesForStartup(rules []*api.Rule) []*api.Rule {
+	deps, err := getRuleDependencies(rules)
+	if err != nil {
+		logger.Warnf("compute rule dependencies error: %v, start the rules by id", err)
+		return rules
+	}
+	ruleMap := make(map[string]*api.Rule, len(rules))
+	for _, r := range rules {
+		ruleMap[r.Id] = r
+	}
+	result := make([]*api.Rule, 0, len(rules))
+	for _, id := range deps.Order {
+		result = append(result, ruleMap[id])
+	}
+	return result
+}
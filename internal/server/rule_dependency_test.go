@@ -0,0 +1,90 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildRuleDependencies(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints map[string]*ruleEndpoints
+		exp       *ruleDependencies
+	}{
+		{
+			name: "independent",
+			endpoints: map[string]*ruleEndpoints{
+				"r2": {},
+				"r1": {},
+			},
+			exp: &ruleDependencies{
+				Order: []string{"r1", "r2"},
+				Edges: map[string][]string{},
+			},
+		}, {
+			name: "chain",
+			endpoints: map[string]*ruleEndpoints{
+				"a": {produces: []string{"topic/a"}},
+				"b": {consumes: []string{"topic/a"}, produces: []string{"topic/b"}},
+				"c": {consumes: []string{"topic/b"}},
+			},
+			exp: &ruleDependencies{
+				Order: []string{"c", "b", "a"},
+				Edges: map[string][]string{
+					"a": {"b"},
+					"b": {"c"},
+				},
+			},
+		}, {
+			name: "wildcard",
+			endpoints: map[string]*ruleEndpoints{
+				"all":   {consumes: []string{"topic/#"}},
+				"dev1":  {produces: []string{"topic/dev1"}},
+				"dev2":  {produces: []string{"topic/dev2"}},
+				"other": {produces: []string{"other/dev1"}},
+			},
+			exp: &ruleDependencies{
+				Order: []string{"all", "other", "dev1", "dev2"},
+				Edges: map[string][]string{
+					"dev1": {"all"},
+					"dev2": {"all"},
+				},
+			},
+		}, {
+			name: "cycle",
+			endpoints: map[string]*ruleEndpoints{
+				"a": {consumes: []string{"topic/b"}, produces: []string{"topic/a"}},
+				"b": {consumes: []string{"topic/a"}, produces: []string{"topic/b"}},
+				"c": {consumes: []string{"topic/a"}},
+				"d": {consumes: []string{"topic/d"}, produces: []string{"topic/d"}},
+			},
+			exp: &ruleDependencies{
+				Order: []string{"c", "d", "a", "b"},
+				Edges: map[string][]string{
+					"a": {"b", "c"},
+					"b": {"a"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.exp, buildRuleDependencies(tt.endpoints))
+		})
+	}
+}
@@ -19,11 +19,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/internal/topo/planner"
 	"github.com/lf-edge/ekuiper/internal/topo/rule"
+	"github.com/lf-edge/ekuiper/internal/topo/ruletest"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
 	"github.com/lf-edge/ekuiper/pkg/infra"
@@ -71,6 +76,12 @@ func (rr *RuleRegistry) Delete(key string) (*rule.RuleState, bool) {
 }
 
 func createRule(name, ruleJson string) (string, error) {
+	return createRuleFromSavepoint(name, ruleJson, "", "")
+}
+
+// createRuleFromSavepoint creates the rule and restores its state from the savepoint of the rule fromRule before
+// starting it. No state is restored if the savepoint is empty.
+func createRuleFromSavepoint(name, ruleJson string, fromRule, savepoint string) (string, error) {
 	var rs *rule.RuleState = nil
 	var err error = nil
 
@@ -96,6 +107,13 @@ func createRule(name, ruleJson string) (string, error) {
 		_, _ = ruleProcessor.ExecDrop(r.Id)
 		return r.Id, fmt.Errorf("create rule topo error: %v", panicOrError)
 	}
+	if savepoint != "" {
+		if err := restoreSavepoint(r, rs.Topology.GetTopo(), fromRule, savepoint); err != nil {
+			deleteRule(r.Id)
+			_, _ = ruleProcessor.ExecDrop(r.Id)
+			return r.Id, err
+		}
+	}
 	// Start the rule asyncly
 	if r.Triggered {
 		go func() {
@@ -151,14 +169,33 @@ func recoverRule(r *api.Rule) string {
 	return fmt.Sprintf("Rule %s was started.", r.Id)
 }
 
+// The strategies to update a running rule
+const (
+	// updateStrategyRestart stops the old topo and then starts the new one
+	updateStrategyRestart = "restart"
+	// updateStrategyGraceful drains the old topo and transfers the state to the new one
+	updateStrategyGraceful = "graceful"
+)
+
 func updateRule(ruleId, ruleJson string) error {
+	return updateRuleWithStrategy(ruleId, ruleJson, updateStrategyRestart)
+}
+
+func updateRuleWithStrategy(ruleId, ruleJson string, strategy string) error {
+	if strategy != updateStrategyRestart && strategy != updateStrategyGraceful {
+		return fmt.Errorf("Invalid update strategy %s, must be %s or %s", strategy, updateStrategyRestart, updateStrategyGraceful)
+	}
 	// Validate the rule json
 	r, err := ruleProcessor.GetRuleByJson(ruleId, ruleJson)
 	if err != nil {
 		return fmt.Errorf("Invalid rule json: %v", err)
 	}
 	if rs, ok := registry.Load(r.Id); ok {
-		err := rs.UpdateTopo(r)
+		if strategy == updateStrategyGraceful {
+			err = rs.UpdateTopoGracefully(r)
+		} else {
+			err = rs.UpdateTopo(r)
+		}
 		if err != nil {
 			return err
 		}
@@ -169,6 +206,21 @@ func updateRule(ruleId, ruleJson string) error {
 	}
 }
 
+// rollbackRule updates the rule to the definition of a history version. Like updating, the new topo is planned before
+// stopping the old one so that the rule keeps running if the version is invalid. The rollback is saved as a new version.
+func rollbackRule(ruleId string, version int) error {
+	v, err := ruleProcessor.GetRuleVersion(ruleId, version)
+	if err != nil {
+		return err
+	}
+	err = updateRule(ruleId, v.Rule)
+	if err != nil {
+		return err
+	}
+	_, err = ruleProcessor.ExecUpdate(ruleId, v.Rule)
+	return err
+}
+
 func deleteRule(name string) (result string) {
 	if rs, ok := registry.Delete(name); ok {
 		rs.Close()
@@ -242,6 +294,8 @@ func getRuleStatus(name string) (string, error) {
 			} else {
 				result = dst.String()
 			}
+		} else if strings.HasPrefix(result, "Scheduled") {
+			result = fmt.Sprintf(`{"status": "scheduled", "message": "%s"}`, result)
 		} else {
 			result = fmt.Sprintf(`{"status": "stopped", "message": "%s"}`, result)
 		}
@@ -251,6 +305,101 @@ func getRuleStatus(name string) (string, error) {
 	}
 }
 
+// explainRule returns the physical plan of the rule. If the rule is running, each node is annotated with the metrics
+// of its instances and the parallelism is the running instances.
+func explainRule(name string) ([]*planner.ExplainNode, error) {
+	r, err := ruleProcessor.GetRuleById(name)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := planner.Explain(r)
+	if err != nil {
+		return nil, err
+	}
+	rs, ok := registry.Load(name)
+	if !ok {
+		return nodes, nil
+	}
+	if state, err := rs.GetState(); err != nil || state != "Running" {
+		return nodes, nil
+	}
+	keys, values := (*rs.Topology).GetMetrics()
+	metricIndex := make(map[string]bool, len(metric.MetricNames))
+	for _, mn := range metric.MetricNames {
+		metricIndex[mn] = true
+	}
+	for _, n := range nodes {
+		prefix := n.Id + "_"
+		for i, key := range keys {
+			// the key is like op_2_project_0_records_in_total
+			rest, found := strings.CutPrefix(key, prefix)
+			if !found {
+				continue
+			}
+			ins, mn, found := strings.Cut(rest, "_")
+			if !found || !metricIndex[mn] {
+				continue
+			}
+			instance, err := strconv.Atoi(ins)
+			if err != nil {
+				continue
+			}
+			for len(n.Metrics) <= instance {
+				n.Metrics = append(n.Metrics, make(map[string]interface{}))
+			}
+			n.Metrics[instance][mn] = values[i]
+		}
+		if len(n.Metrics) > 0 {
+			n.Parallelism = len(n.Metrics)
+		}
+	}
+	return nodes, nil
+}
+
+// validateRule dry runs the rule json without creating it. The rule id is not required.
+func validateRule(ruleJson string) *planner.ValidateResult {
+	r, err := ruleProcessor.GetRuleByJsonValidated(ruleJson)
+	if err != nil {
+		return &planner.ValidateResult{Errors: []*planner.ValidateError{{Stage: planner.ValidateStageRule, Message: err.Error()}}}
+	}
+	if r.Id == "" {
+		r.Id = "validate"
+	}
+	result := planner.Validate(r)
+	// the syntax error is reported with the position by the planner, the other rule level checks are still required
+	if len(result.Errors) > 0 && result.Errors[0].Stage == planner.ValidateStageParse {
+		return result
+	}
+	if _, err := ruleProcessor.GetRuleByJson(r.Id, ruleJson); err != nil {
+		result.Errors = append([]*planner.ValidateError{{Stage: planner.ValidateStageRule, Message: err.Error()}}, result.Errors...)
+		result.Valid = false
+	}
+	return result
+}
+
+// runRuleTest runs the rule with the fixtures in the test json and compares the outputs. The rule id and actions are
+// not required.
+func runRuleTest(testJson string) (*ruletest.Result, error) {
+	t := &ruletest.Test{}
+	if err := json.Unmarshal([]byte(testJson), t); err != nil {
+		return nil, fmt.Errorf("invalid rule test json: %v", err)
+	}
+	if len(t.Rule) == 0 {
+		return nil, fmt.Errorf("missing rule")
+	}
+	r, err := ruleProcessor.GetRuleByJsonValidated(string(t.Rule))
+	if err != nil {
+		return nil, err
+	}
+	if r.Id == "" {
+		r.Id = "test"
+	}
+	if err := conf.ValidateRuleOption(r.Options); err != nil {
+		return nil, fmt.Errorf("Rule %s has invalid options: %s.", r.Id, err)
+	}
+	return ruletest.Run(r, t)
+}
+
 func getAllRulesWithStatus() ([]map[string]interface{}, error) {
 	ruleIds, err := ruleProcessor.GetAllRules()
 	if err != nil {
@@ -0,0 +1,113 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/processor"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+// savepointTimeout is the max milliseconds to wait for the checkpoint of a savepoint
+const savepointTimeout = 60000
+
+// createSavepoint triggers a checkpoint of the running rule and saves it as a named savepoint
+func createSavepoint(ruleId, name string) error {
+	if err := processor.ValidateSavepointName(name); err != nil {
+		return err
+	}
+	rs, ok := registry.Load(ruleId)
+	if !ok {
+		return errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("Rule %s is not found in registry, please check if it is created", ruleId))
+	}
+	if s, _ := rs.GetState(); s != "Running" {
+		return fmt.Errorf("rule %s is not running", ruleId)
+	}
+	rs.RLock()
+	tp := rs.Topology
+	rs.RUnlock()
+	if err := tp.Savepoint(savepointTimeout); err != nil {
+		return fmt.Errorf("save checkpoint of rule %s error: %v", ruleId, err)
+	}
+	id, st, err := state.GetLatestCheckpoint(ruleId)
+	if err != nil {
+		return err
+	}
+	if id == 0 {
+		return fmt.Errorf("no checkpoint is found for rule %s", ruleId)
+	}
+	topo, err := json.Marshal(tp.GetTopo())
+	if err != nil {
+		return err
+	}
+	return ruleProcessor.ExecCreateSavepoint(&processor.Savepoint{
+		Name:         name,
+		RuleId:       ruleId,
+		CheckpointId: id,
+		Timestamp:    conf.GetNowInMilli(),
+		Topo:         string(topo),
+		State:        st,
+	})
+}
+
+// restoreSavepoint replaces the checkpoints of the rule by the savepoint of the rule fromRule, which defaults to the
+// rule itself. The states are saved by the operator names, so the topo must be the same as the one of the savepoint.
+func restoreSavepoint(r *api.Rule, topo *api.PrintableTopo, fromRule, name string) error {
+	if fromRule == "" {
+		fromRule = r.Id
+	}
+	if r.Options.Qos < api.AtLeastOnce {
+		return fmt.Errorf("rule %s must set qos to restore from a savepoint", r.Id)
+	}
+	sp, err := ruleProcessor.GetSavepoint(fromRule, name)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(topo)
+	if err != nil {
+		return err
+	}
+	if string(b) != sp.Topo {
+		return fmt.Errorf("the topo of rule %s is incompatible with savepoint %s of rule %s", r.Id, name, fromRule)
+	}
+	return state.ReplaceCheckpoints(r.Id, sp.State)
+}
+
+// startRuleFromSavepoint restores the state of the rule from the savepoint and starts it. The running rule is
+// stopped first.
+func startRuleFromSavepoint(ruleId, fromRule, name string) error {
+	rs, ok := registry.Load(ruleId)
+	if !ok {
+		return errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("Rule %s is not found in registry, please check if it is created", ruleId))
+	}
+	if s, _ := rs.GetState(); s == "Running" {
+		stopRule(ruleId)
+		// wait a little to make sure the old topo is stopped
+		time.Sleep(1 * time.Millisecond)
+	}
+	rs.RLock()
+	r := rs.Rule
+	rs.RUnlock()
+	if err := restoreSavepoint(r, rs.GetTopoGraph(), fromRule, name); err != nil {
+		return err
+	}
+	return startRule(ruleId)
+}
@@ -32,10 +32,14 @@ import (
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/keyedstate"
 	meta2 "github.com/lf-edge/ekuiper/internal/meta"
+	"github.com/lf-edge/ekuiper/internal/pkg/ruleevent"
 	"github.com/lf-edge/ekuiper/internal/pkg/store"
 	"github.com/lf-edge/ekuiper/internal/processor"
 	"github.com/lf-edge/ekuiper/internal/topo/connection/factory"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/pool"
+	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
 	"github.com/lf-edge/ekuiper/internal/topo/rule"
+	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/ast"
 )
 
@@ -46,6 +50,7 @@ var (
 	ruleProcessor          *processor.RuleProcessor
 	streamProcessor        *processor.StreamProcessor
 	rulesetProcessor       *processor.RulesetProcessor
+	pipelineProcessor      *processor.PipelineProcessor
 	ruleMigrationProcessor *RuleMigrationProcessor
 )
 
@@ -97,7 +102,11 @@ func StartUp(Version, LoadFileType string) {
 	ruleProcessor = processor.NewRuleProcessor()
 	streamProcessor = processor.NewStreamProcessor()
 	rulesetProcessor = processor.NewRulesetProcessor(ruleProcessor, streamProcessor)
+	pipelineProcessor = processor.NewPipelineProcessor()
 	ruleMigrationProcessor = NewRuleMigrationProcessor(ruleProcessor, streamProcessor)
+	if conf.Config.Audit.Enable {
+		auditProcessor = processor.NewAuditProcessor(conf.Config.Audit.MaxRecords)
+	}
 
 	// register all extensions
 	for k, v := range components {
@@ -115,6 +124,13 @@ func StartUp(Version, LoadFileType string) {
 	if err != nil {
 		panic(err)
 	}
+	// Connect the shared connections before the rules start
+	err = pool.InitManager()
+	if err != nil {
+		panic(err)
+	}
+	// Publish the rule events before the rules start
+	ruleevent.Init(&conf.Config.RuleEvent, kctx.Background())
 	meta.Bind()
 	initRuleset()
 
@@ -127,12 +143,16 @@ func StartUp(Version, LoadFileType string) {
 	} else {
 		logger.Info("Starting rules")
 		var reply string
+		all := make([]*api.Rule, 0, len(rules))
 		for _, name := range rules {
 			rule, err := ruleProcessor.GetRuleById(name)
 			if err != nil {
 				logger.Error(err)
 				continue
 			}
+			all = append(all, rule)
+		}
+		for _, rule := range sortRulesForStartup(all) {
 			// err = server.StartRule(rule, &reply)
 			reply = recoverRule(rule)
 			if 0 != len(reply) {
@@ -185,6 +205,7 @@ func StartUp(Version, LoadFileType string) {
 		logger.Infof("close service %s", k)
 		v.close()
 	}
+	ruleevent.Close()
 
 	os.Exit(0)
 }
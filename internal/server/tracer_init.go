@@ -0,0 +1,49 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build tracer || !core
+
+package server
+
+import (
+	"github.com/gorilla/mux"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
+)
+
+func init() {
+	t := tracerComp{}
+	components["tracer"] = t
+	servers["tracer"] = t
+}
+
+type tracerComp struct{}
+
+// register starts the tracer before the rules so that all the rule operations are traced
+func (t tracerComp) register() {
+	tracer.Init(&conf.Config.Tracer)
+}
+
+func (t tracerComp) rest(_ *mux.Router) {
+	// Do nothing
+}
+
+func (t tracerComp) serve() {
+	// Do nothing
+}
+
+func (t tracerComp) close() {
+	tracer.Shutdown()
+}
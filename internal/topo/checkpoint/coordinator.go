@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -15,11 +15,14 @@
 package checkpoint
 
 import (
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/benbjohnson/clock"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 	"github.com/lf-edge/ekuiper/pkg/infra"
@@ -29,10 +32,13 @@ type pendingCheckpoint struct {
 	checkpointId   int64
 	isDiscarded    bool
 	notYetAckTasks map[string]bool
+	start          time.Time
+	// done is notified when the checkpoint is completed or canceled, only set for the snapshot
+	done chan error
 }
 
 func newPendingCheckpoint(checkpointId int64, tasksToWaitFor []Responder) *pendingCheckpoint {
-	pc := &pendingCheckpoint{checkpointId: checkpointId}
+	pc := &pendingCheckpoint{checkpointId: checkpointId, start: conf.GetNow()}
 	nyat := make(map[string]bool)
 	for _, r := range tasksToWaitFor {
 		nyat[r.GetName()] = true
@@ -61,6 +67,14 @@ func (c *pendingCheckpoint) finalize() *completedCheckpoint {
 
 func (c *pendingCheckpoint) dispose(_ bool) {
 	c.isDiscarded = true
+	c.notify(fmt.Errorf("checkpoint %d is canceled", c.checkpointId))
+}
+
+func (c *pendingCheckpoint) notify(err error) {
+	if c.done != nil {
+		c.done <- err
+		c.done = nil
+	}
 }
 
 type completedCheckpoint struct {
@@ -98,9 +112,12 @@ type Coordinator struct {
 	advanceToEndOfEventTime bool
 	ticker                  *clock.Ticker // For processing time only
 	signal                  chan *Signal
+	snapshot                chan chan error
 	store                   api.Store
 	ctx                     api.StreamContext
 	activated               bool
+	// onComplete is called with the checkpoint id after a checkpoint is completed
+	onComplete func(checkpointId int64)
 }
 
 func NewCoordinator(ruleId string, sources []StreamTask, operators []NonSourceTask, sinks []SinkTask, qos api.Qos, store api.Store, interval int, ctx api.StreamContext) *Coordinator {
@@ -142,6 +159,7 @@ func NewCoordinator(ruleId string, sources []StreamTask, operators []NonSourceTa
 		},
 		ruleId:         ruleId,
 		signal:         signal,
+		snapshot:       make(chan chan error),
 		baseInterval:   interval,
 		store:          store,
 		ctx:            ctx,
@@ -159,6 +177,11 @@ func createBarrierHandler(re Responder, inputCount int, qos api.Qos) BarrierHand
 	}
 }
 
+// OnComplete sets the callback to be notified when a checkpoint is completed
+func (c *Coordinator) OnComplete(f func(checkpointId int64)) {
+	c.onComplete = f
+}
+
 func (c *Coordinator) Activate() error {
 	logger := c.ctx.GetLogger()
 	logger.Infof("Start checkpoint coordinator for rule %s at %d", c.ruleId, conf.GetNowInMilli())
@@ -178,26 +201,14 @@ func (c *Coordinator) Activate() error {
 					// TODO pose max attempt and min pause check for consequent pendingCheckpoints
 
 					// TODO Check if all tasks are running
-
-					// Create a pending checkpoint
-					checkpointId := cast.TimeToUnixMilli(n)
-					checkpoint := newPendingCheckpoint(checkpointId, c.tasksToWaitFor)
-					logger.Debugf("Create checkpoint %d", checkpointId)
-					c.pendingCheckpoints.Store(checkpointId, checkpoint)
-					// Let the sources send out a barrier
-					for _, r := range c.tasksToTrigger {
-						go func(t Responder) {
-							if err := t.TriggerCheckpoint(checkpointId); err != nil {
-								logger.Infof("Fail to trigger checkpoint for source %s with error %v, cancel it", t.GetName(), err)
-								c.cancel(checkpointId)
-							}
-						}(r)
-					}
+					c.trigger(cast.TimeToUnixMilli(n), nil)
 					toBeClean++
 					if toBeClean >= c.cleanThreshold {
 						c.store.Clean()
 						toBeClean = 0
 					}
+				case done := <-c.snapshot:
+					c.trigger(conf.GetNowInMilli(), done)
 				case s := <-c.signal:
 					switch s.Message {
 					case STOP:
@@ -236,6 +247,49 @@ func (c *Coordinator) Activate() error {
 	return nil
 }
 
+func (c *Coordinator) trigger(checkpointId int64, done chan error) {
+	logger := c.ctx.GetLogger()
+	// Create a pending checkpoint
+	checkpoint := newPendingCheckpoint(checkpointId, c.tasksToWaitFor)
+	checkpoint.done = done
+	logger.Debugf("Create checkpoint %d", checkpointId)
+	c.pendingCheckpoints.Store(checkpointId, checkpoint)
+	// Let the sources send out a barrier
+	for _, r := range c.tasksToTrigger {
+		go func(t Responder) {
+			if err := t.TriggerCheckpoint(checkpointId); err != nil {
+				logger.Infof("Fail to trigger checkpoint for source %s with error %v, cancel it", t.GetName(), err)
+				c.cancel(checkpointId)
+			}
+		}(r)
+	}
+}
+
+// Snapshot triggers a checkpoint immediately and waits until it is completed, so that the latest state is saved.
+// It is used before stopping the rule gracefully, when the sources are supposed to be stopped so that the barrier
+// is behind all the data. It is also used to save a savepoint of the running rule.
+func (c *Coordinator) Snapshot(timeout int) error {
+	if !c.activated {
+		return fmt.Errorf("checkpoint coordinator for rule %s is not activated", c.ruleId)
+	}
+	done := make(chan error, 1)
+	timer := conf.GetTimer(timeout)
+	defer timer.Stop()
+	select {
+	case c.snapshot <- done:
+	case <-c.ctx.Done():
+		return fmt.Errorf("rule %s is cancelled", c.ruleId)
+	case <-timer.C:
+		return fmt.Errorf("snapshot for rule %s timeout", c.ruleId)
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("snapshot for rule %s timeout", c.ruleId)
+	}
+}
+
 func (c *Coordinator) Deactivate() error {
 	if c.ticker != nil {
 		c.ticker.Stop()
@@ -248,7 +302,9 @@ func (c *Coordinator) cancel(checkpointId int64) {
 	logger := c.ctx.GetLogger()
 	if checkpoint, ok := c.pendingCheckpoints.Load(checkpointId); ok {
 		c.pendingCheckpoints.Delete(checkpointId)
-		checkpoint.(*pendingCheckpoint).dispose(true)
+		pc := checkpoint.(*pendingCheckpoint)
+		pc.dispose(true)
+		metric.ObserveCheckpoint(c.ruleId, conf.GetNow().Sub(pc.start), false)
 	} else {
 		logger.Debugf("Cancel for non existing checkpoint %d. Just ignored", checkpointId)
 	}
@@ -261,11 +317,21 @@ func (c *Coordinator) complete(checkpointId int64) {
 		err := c.store.SaveCheckpoint(checkpointId)
 		if err != nil {
 			logger.Infof("Cannot save checkpoint %d due to storage error: %v", checkpointId, err)
+			metric.ObserveCheckpoint(c.ruleId, conf.GetNow().Sub(ccp.(*pendingCheckpoint).start), false)
 			// TODO handle checkpoint error
 			return
 		}
 		c.completedCheckpoints.add(ccp.(*pendingCheckpoint).finalize())
+		metric.ObserveCheckpoint(c.ruleId, conf.GetNow().Sub(ccp.(*pendingCheckpoint).start), true)
+		if c.onComplete != nil {
+			c.onComplete(checkpointId)
+		}
 		c.pendingCheckpoints.Delete(checkpointId)
+		// Notify the sinks before the snapshot is done, so that the transactions are committed before the rule stops
+		for _, t := range c.sinkTasks {
+			t.NotifyCheckpointComplete(checkpointId)
+		}
+		ccp.(*pendingCheckpoint).notify(nil)
 		// Drop the previous pendingCheckpoints
 		c.pendingCheckpoints.Range(func(a1 interface{}, a2 interface{}) bool {
 			cid := a1.(int64)
@@ -273,6 +339,8 @@ func (c *Coordinator) complete(checkpointId int64) {
 			if cid < checkpointId {
 				// TODO revisit how to abort a checkpoint, discard callback
 				cp.isDiscarded = true
+				// the completed checkpoint is later so that it covers the state of the discarded one
+				cp.notify(nil)
 				c.pendingCheckpoints.Delete(cid)
 			}
 			return true
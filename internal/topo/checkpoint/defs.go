@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -35,6 +35,8 @@ type NonSourceTask interface {
 
 type SinkTask interface {
 	NonSourceTask
+	// NotifyCheckpointComplete is called by the coordinator when the checkpoint is completed. It must not block.
+	NotifyCheckpointComplete(checkpointId int64)
 }
 
 type BufferOrEvent struct {
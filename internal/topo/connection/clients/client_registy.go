@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -20,6 +20,7 @@ import (
 	"sync"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/pool"
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
@@ -28,6 +29,8 @@ type clientRegistry struct {
 	supportedClientType []string
 	clientFactory       map[string]ClientFactoryFunc
 	shareClientStore    map[string]ClientWrapper
+	// pooledClients is the clients from the connection pool to their connection ids
+	pooledClients map[ClientWrapper]string
 }
 
 var gClientRegistry = clientRegistry{
@@ -35,6 +38,7 @@ var gClientRegistry = clientRegistry{
 	Lock:                sync.Mutex{},
 	supportedClientType: make([]string, 0),
 	shareClientStore:    make(map[string]ClientWrapper),
+	pooledClients:       make(map[ClientWrapper]string),
 }
 
 func RegisterClientFactory(clientType string, creatorFunc ClientFactoryFunc) {
@@ -57,10 +61,42 @@ func getConnectionSelector(props map[string]interface{}) (ConnectionSelector str
 	return "", nil
 }
 
+func getConnectionId(props map[string]interface{}) (string, error) {
+	for key, v := range props {
+		if strings.EqualFold(key, "connectionId") {
+			if id, ok := v.(string); ok {
+				return id, nil
+			} else {
+				return "", fmt.Errorf("connectionId value: %v is not string", v)
+			}
+		}
+	}
+	return "", nil
+}
+
 func GetClient(connectionType string, props map[string]interface{}) (api.MessageClient, error) {
 	gClientRegistry.Lock.Lock()
 	defer gClientRegistry.Lock.Unlock()
 
+	connectionId, err := getConnectionId(props)
+	if err != nil {
+		return nil, err
+	}
+	if connectionId != "" {
+		c, err := pool.Attach(connectionId, connectionType)
+		if err != nil {
+			return nil, err
+		}
+		cliWpr, ok := c.(ClientWrapper)
+		if !ok {
+			pool.Detach(connectionId)
+			return nil, fmt.Errorf("connection %s is not a %s client", connectionId, connectionType)
+		}
+		cliWpr.AddRef()
+		gClientRegistry.pooledClients[cliWpr] = connectionId
+		return cliWpr, nil
+	}
+
 	connectSelector, err := getConnectionSelector(props)
 	if err != nil {
 		return nil, err
@@ -116,6 +152,15 @@ func ReleaseClient(ctx api.StreamContext, cli api.MessageClient) {
 	sel := wrapper.GetConnectionSelector()
 	ok := wrapper.Release(ctx)
 
+	gClientRegistry.Lock.Lock()
+	connectionId, pooled := gClientRegistry.pooledClients[wrapper]
+	gClientRegistry.Lock.Unlock()
+	if pooled {
+		// the pool keeps the client open, so it is never released here
+		pool.Detach(connectionId)
+		return
+	}
+
 	if sel != "" && ok {
 		log.Infof("remove mqtt client wrapper for connection selector %s", sel)
 		gClientRegistry.Lock.Lock()
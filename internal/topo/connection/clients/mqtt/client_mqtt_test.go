@@ -17,6 +17,12 @@ package mqtt
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	pahoMqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 )
 
 func TestMQTTClient_CfgValidate(t *testing.T) {
@@ -134,3 +140,149 @@ func TestMQTTClient_CfgResult(t *testing.T) {
 		t.Errorf("result mismatch:\n\n got=%#v\n\n", ms.pVersion)
 	}
 }
+
+func TestMQTTClient_CfgV5(t *testing.T) {
+	ms := &MQTTClient{}
+	err := ms.CfgValidate(map[string]interface{}{
+		"server":            "tcp://127.0.0.1:1883",
+		"protocolVersion":   "5",
+		"topicAliasMaximum": 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms.pVersion != 5 || ms.topicAliasMax != 10 {
+		t.Errorf("expect version 5 and topic alias maximum 10 but got %d and %d", ms.pVersion, ms.topicAliasMax)
+	}
+	err = ms.CfgValidate(map[string]interface{}{
+		"server":            "tcp://127.0.0.1:1883",
+		"protocolVersion":   "5",
+		"topicAliasMaximum": 70000,
+	})
+	if err == nil || err.Error() != "invalid topicAliasMaximum 70000, must be between 0 and 65535" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestTopicMatch(t *testing.T) {
+	tests := []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{filter: "a/b", topic: "a/b", match: true},
+		{filter: "a/b", topic: "a/c", match: false},
+		{filter: "a/+", topic: "a/b", match: true},
+		{filter: "a/+", topic: "a/b/c", match: false},
+		{filter: "a/#", topic: "a", match: true},
+		{filter: "a/#", topic: "a/b/c", match: true},
+		{filter: "+/b", topic: "a/b", match: true},
+		{filter: "#", topic: "$SYS/a", match: false},
+		{filter: "$share/g1/a/+", topic: "a/b", match: true},
+		{filter: "$share/g1/a/+", topic: "g1/a/b", match: false},
+		{filter: "$share/g1", topic: "g1", match: false},
+	}
+	for _, tt := range tests {
+		if m := topicMatch(tt.filter, tt.topic); m != tt.match {
+			t.Errorf("%s %s: expect %v but got %v", tt.filter, tt.topic, tt.match, m)
+		}
+	}
+}
+
+func TestTopicAlias(t *testing.T) {
+	c := &mqtt5Conn{}
+	c.reset(2)
+	tests := []struct {
+		topic string
+		alias uint16
+		known bool
+	}{
+		{topic: "a", alias: 1},
+		{topic: "b", alias: 2},
+		{topic: "a", alias: 1, known: true},
+		// exceed the maximum
+		{topic: "c", alias: 0},
+		{topic: "b", alias: 2, known: true},
+	}
+	for i, tt := range tests {
+		alias, known := c.alias(tt.topic)
+		if alias != tt.alias || known != tt.known {
+			t.Errorf("%d: expect alias %d known %v but got %d %v", i, tt.alias, tt.known, alias, known)
+		}
+	}
+	// the aliases are cleared after reconnection
+	c.reset(0)
+	if alias, known := c.alias("a"); alias != 0 || known {
+		t.Errorf("expect no alias after reset but got %d %v", alias, known)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	c := &mqtt5Conn{handlers: make(map[string]pahoMqtt.MessageHandler)}
+	c.reset(0)
+	var received []pahoMqtt.Message
+	c.handlers["$share/g/a/+"] = func(_ pahoMqtt.Client, m pahoMqtt.Message) {
+		received = append(received, m)
+	}
+	alias := uint16(1)
+	expiry := uint32(60)
+	c.dispatch(&paho.Publish{Topic: "a/b", Payload: []byte("1"), Properties: &paho.PublishProperties{
+		TopicAlias:    &alias,
+		MessageExpiry: &expiry,
+		ContentType:   "application/json",
+		User:          paho.UserProperties{{Key: "k1", Value: "v1"}, {Key: "k2", Value: "v2"}},
+	}})
+	// the topic is omitted by the alias
+	c.dispatch(&paho.Publish{Payload: []byte("2"), Properties: &paho.PublishProperties{TopicAlias: &alias}})
+	c.dispatch(&paho.Publish{Topic: "b/c", Payload: []byte("3")})
+	if len(received) != 2 {
+		t.Fatalf("expect 2 messages but got %d", len(received))
+	}
+	if received[1].Topic() != "a/b" || string(received[1].Payload()) != "2" {
+		t.Errorf("expect the message 2 of a/b but got %s of %s", received[1].Payload(), received[1].Topic())
+	}
+	exp := map[string]interface{}{
+		"messageExpiry": int64(60),
+		"contentType":   "application/json",
+		"userProperties": map[string]interface{}{
+			"k1": "v1",
+			"k2": "v2",
+		},
+	}
+	if props := received[0].(PropertiesMessage).Properties(); !reflect.DeepEqual(props, exp) {
+		t.Errorf("expect properties %v but got %v", exp, props)
+	}
+}
+
+func TestSendWithBackPressure(t *testing.T) {
+	ch := make(chan interface{})
+	var waits []time.Duration
+	consumer := &clients.ConsumerInfo{
+		ConsumerId:   "test",
+		ConsumerChan: ch,
+		Done:         make(chan struct{}),
+		BlockTimeout: 20 * time.Millisecond,
+		OnBlock: func(wait time.Duration) {
+			waits = append(waits, wait)
+		},
+	}
+	// dropped after the timeout
+	sendWithBackPressure(consumer, nil)
+	// sent once consumed in the timeout
+	consumer.BlockTimeout = time.Second
+	received := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-ch
+		close(received)
+	}()
+	sendWithBackPressure(consumer, nil)
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("the message is not sent")
+	}
+	if len(waits) != 2 || waits[0] < 20*time.Millisecond || waits[1] < 10*time.Millisecond {
+		t.Errorf("unexpected waits %v", waits)
+	}
+}
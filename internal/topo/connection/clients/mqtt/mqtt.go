@@ -17,6 +17,7 @@ package mqtt
 import (
 	"crypto/tls"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -39,6 +40,8 @@ type MQTTConnectionConfig struct {
 	PrivateKPath       string `json:"privateKeyPath"`
 	RootCaPath         string `json:"rootCaPath"`
 	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	// TopicAliasMaximum is the max number of the topic aliases in MQTT 5, 0 disables the topic alias
+	TopicAliasMaximum int `json:"topicAliasMaximum"`
 }
 
 type MQTTClient struct {
@@ -48,8 +51,11 @@ type MQTTClient struct {
 	uName    string
 	password string
 	tls      *tls.Config
+	// the topic alias maximum of MQTT 5
+	topicAliasMax uint16
 
-	conn MQTT.Client
+	conn  MQTT.Client
+	conn5 *mqtt5Conn
 }
 
 func (ms *MQTTClient) CfgValidate(props map[string]interface{}) error {
@@ -77,9 +83,16 @@ func (ms *MQTTClient) CfgValidate(props map[string]interface{}) error {
 	}
 	// Default to MQTT 3.1.1 or NanoMQ cannot connect
 	ms.pVersion = 4
-	if cfg.PVersion == "3.1" {
+	switch cfg.PVersion {
+	case "3.1":
 		ms.pVersion = 3
+	case "5":
+		ms.pVersion = 5
 	}
+	if cfg.TopicAliasMaximum < 0 || cfg.TopicAliasMaximum > math.MaxUint16 {
+		return fmt.Errorf("invalid topicAliasMaximum %d, must be between 0 and %d", cfg.TopicAliasMaximum, math.MaxUint16)
+	}
+	ms.topicAliasMax = uint16(cfg.TopicAliasMaximum)
 
 	tlsOpts := cert.TlsConfigurationOptions{
 		SkipCertVerify: cfg.InsecureSkipVerify,
@@ -104,6 +117,9 @@ func (ms *MQTTClient) Connect(connHandler MQTT.OnConnectHandler, lostHandler MQT
 		MQTT.DEBUG = conf.Log
 		MQTT.ERROR = conf.Log
 	}
+	if ms.pVersion == 5 {
+		return ms.connect5(connHandler, lostHandler)
+	}
 	opts := MQTT.NewClientOptions().AddBroker(ms.srv).SetProtocolVersion(4)
 
 	opts = opts.SetTLSConfig(ms.tls)
@@ -138,13 +154,28 @@ func (ms *MQTTClient) Connect(connHandler MQTT.OnConnectHandler, lostHandler MQT
 }
 
 func (ms *MQTTClient) Subscribe(topic string, qos byte, handler MQTT.MessageHandler) error {
+	if ms.conn5 != nil {
+		return ms.conn5.subscribe(topic, qos, handler)
+	}
 	if token := ms.conn.Subscribe(topic, qos, handler); token.WaitTimeout(5*time.Second) && token.Error() != nil {
 		return fmt.Errorf("%s: %s", errorx.IOErr, token.Error())
 	}
 	return nil
 }
 
-func (ms *MQTTClient) Publish(topic string, qos byte, retained bool, message []byte) error {
+func (ms *MQTTClient) Unsubscribe(topic string) {
+	if ms.conn5 != nil {
+		ms.conn5.unsubscribe(topic)
+		return
+	}
+	ms.conn.Unsubscribe(topic)
+}
+
+// Publish sends the message, the properties only take effect in MQTT 5
+func (ms *MQTTClient) Publish(topic string, qos byte, retained bool, message []byte, props *PublishProperties) error {
+	if ms.conn5 != nil {
+		return ms.conn5.publish(topic, qos, retained, message, props)
+	}
 	if token := ms.conn.Publish(topic, qos, retained, message); token.WaitTimeout(5*time.Second) && token.Error() != nil {
 		return fmt.Errorf("%s: %s", errorx.IOErr, token.Error())
 	}
@@ -153,6 +184,9 @@ func (ms *MQTTClient) Publish(topic string, qos byte, retained bool, message []b
 
 func (ms *MQTTClient) Disconnect() error {
 	conf.Log.Infof("Closing the connection to mqtt broker for %s", ms.srv)
+	if ms.conn5 != nil {
+		return ms.conn5.disconnect()
+	}
 	if ms.conn != nil && ms.conn.IsConnected() {
 		ms.conn.Disconnect(5000)
 	}
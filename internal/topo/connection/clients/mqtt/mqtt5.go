@@ -0,0 +1,345 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+// PublishProperties are the MQTT 5 properties of the published message, they are ignored in MQTT 3
+type PublishProperties struct {
+	// MessageExpiry is the lifetime of the message in seconds, 0 means no expiry
+	MessageExpiry  uint32
+	UserProperties map[string]string
+}
+
+// PropertiesMessage is the received MQTT 5 message with the properties
+type PropertiesMessage interface {
+	MQTT.Message
+	Properties() map[string]interface{}
+}
+
+// mqtt5Conn is the MQTT 5 connection. It dispatches the messages to the handlers of the subscriptions
+// and manages the topic aliases of the connection
+type mqtt5Conn struct {
+	cm *autopaho.ConnectionManager
+
+	mu       sync.RWMutex
+	handlers map[string]MQTT.MessageHandler
+	// the topic aliases set by the broker
+	inAliases map[uint16]string
+	// the topic aliases set by the client, limited by the topic alias maximum of the broker
+	outAliases map[string]uint16
+	aliasMax   uint16
+}
+
+func (ms *MQTTClient) connect5(connHandler MQTT.OnConnectHandler, lostHandler MQTT.ConnectionLostHandler) error {
+	u, err := url.Parse(ms.srv)
+	if err != nil {
+		return fmt.Errorf("invalid server %s: %v", ms.srv, err)
+	}
+	c := &mqtt5Conn{
+		handlers: make(map[string]MQTT.MessageHandler),
+	}
+	c.reset(0)
+	lost := func(err error) {
+		c.reset(0)
+		if lostHandler != nil {
+			lostHandler(nil, err)
+		}
+	}
+	cfg := autopaho.ClientConfig{
+		BrokerUrls:        []*url.URL{u},
+		TlsCfg:            ms.tls,
+		KeepAlive:         30,
+		ConnectRetryDelay: 5 * time.Second,
+		ConnectTimeout:    5 * time.Second,
+		OnConnectionUp: func(_ *autopaho.ConnectionManager, connAck *paho.Connack) {
+			var aliasMax uint16
+			if connAck.Properties != nil && connAck.Properties.TopicAliasMaximum != nil && ms.topicAliasMax > 0 {
+				aliasMax = *connAck.Properties.TopicAliasMaximum
+			}
+			c.reset(aliasMax)
+			if connHandler != nil {
+				connHandler(nil)
+			}
+		},
+		OnConnectError: func(err error) {
+			conf.Log.Warnf("The connection to mqtt broker %s client id %s failed: %v", ms.srv, ms.clientid, err)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: ms.clientid,
+			Router:   paho.NewSingleHandlerRouter(c.dispatch),
+			OnClientError: func(err error) {
+				lost(err)
+			},
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				reason := fmt.Sprintf("server disconnect with reason code %d", d.ReasonCode)
+				if d.Properties != nil && d.Properties.ReasonString != "" {
+					reason += ": " + d.Properties.ReasonString
+				}
+				lost(errors.New(reason))
+			},
+		},
+	}
+	if ms.uName != "" || ms.password != "" {
+		cfg.SetUsernamePassword(ms.uName, []byte(ms.password))
+	}
+	if ms.topicAliasMax > 0 {
+		cfg.SetConnectPacketConfigurator(func(cp *paho.Connect) *paho.Connect {
+			if cp.Properties == nil {
+				cp.Properties = &paho.ConnectProperties{}
+			}
+			tam := ms.topicAliasMax
+			cp.Properties.TopicAliasMaximum = &tam
+			return cp
+		})
+	}
+	cm, err := autopaho.NewConnection(context.Background(), cfg)
+	if err != nil {
+		return fmt.Errorf("found error when connecting for %s: %s", ms.srv, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cm.AwaitConnection(ctx); err != nil {
+		_ = cm.Disconnect(context.Background())
+		conf.Log.Errorf("The connection to mqtt broker %s failed: %s", ms.srv, err)
+		return fmt.Errorf("found error when connecting for %s: %s", ms.srv, err)
+	}
+	c.cm = cm
+	ms.conn5 = c
+	conf.Log.Infof("The mqtt 5 connection to mqtt broker is established successfully for %s.", ms.srv)
+	return nil
+}
+
+// reset clears the topic aliases which are only valid in a connection
+func (c *mqtt5Conn) reset(aliasMax uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inAliases = make(map[uint16]string)
+	c.outAliases = make(map[string]uint16)
+	c.aliasMax = aliasMax
+}
+
+func (c *mqtt5Conn) dispatch(p *paho.Publish) {
+	topic := p.Topic
+	c.mu.Lock()
+	if p.Properties != nil && p.Properties.TopicAlias != nil {
+		alias := *p.Properties.TopicAlias
+		if topic == "" {
+			topic = c.inAliases[alias]
+		} else {
+			c.inAliases[alias] = topic
+		}
+	}
+	var handlers []MQTT.MessageHandler
+	for filter, h := range c.handlers {
+		if topicMatch(filter, topic) {
+			handlers = append(handlers, h)
+		}
+	}
+	c.mu.Unlock()
+	if topic == "" {
+		conf.Log.Warnf("drop the mqtt message with unknown topic alias")
+		return
+	}
+	msg := &message5{p: p, topic: topic}
+	for _, h := range handlers {
+		h(nil, msg)
+	}
+}
+
+func (c *mqtt5Conn) subscribe(topic string, qos byte, handler MQTT.MessageHandler) error {
+	c.mu.Lock()
+	c.handlers[topic] = handler
+	c.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := c.cm.Subscribe(ctx, &paho.Subscribe{
+		Subscriptions: map[string]paho.SubscribeOptions{topic: {QoS: qos}},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorx.IOErr, err)
+	}
+	return nil
+}
+
+func (c *mqtt5Conn) unsubscribe(topic string) {
+	c.mu.Lock()
+	delete(c.handlers, topic)
+	c.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.cm.Unsubscribe(ctx, &paho.Unsubscribe{Topics: []string{topic}}); err != nil {
+		conf.Log.Warnf("unsubscribe mqtt topic %s error: %v", topic, err)
+	}
+}
+
+func (c *mqtt5Conn) publish(topic string, qos byte, retained bool, message []byte, props *PublishProperties) error {
+	p := &paho.Publish{
+		QoS:        qos,
+		Retain:     retained,
+		Payload:    message,
+		Properties: &paho.PublishProperties{},
+	}
+	if props != nil {
+		if props.MessageExpiry > 0 {
+			expiry := props.MessageExpiry
+			p.Properties.MessageExpiry = &expiry
+		}
+		for k, v := range props.UserProperties {
+			p.Properties.User.Add(k, v)
+		}
+	}
+	alias, known := c.alias(topic)
+	if alias > 0 {
+		p.Properties.TopicAlias = &alias
+	}
+	// the topic can be omitted after the alias is sent
+	if !known {
+		p.Topic = topic
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := c.cm.Publish(ctx, p); err != nil {
+		return fmt.Errorf("%s: %s", errorx.IOErr, err)
+	}
+	return nil
+}
+
+// alias returns the topic alias of the topic and whether the alias is already sent. A new alias is assigned
+// until the topic alias maximum is reached, 0 means no alias
+func (c *mqtt5Conn) alias(topic string) (uint16, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if a, ok := c.outAliases[topic]; ok {
+		return a, true
+	}
+	if len(c.outAliases) >= int(c.aliasMax) {
+		return 0, false
+	}
+	a := uint16(len(c.outAliases) + 1)
+	c.outAliases[topic] = a
+	return a, false
+}
+
+func (c *mqtt5Conn) disconnect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.cm.Disconnect(ctx)
+}
+
+// topicMatch checks if the topic matches the filter with wildcards. The $share/{group}/ prefix of the shared subscription is ignored
+func topicMatch(filter string, topic string) bool {
+	if strings.HasPrefix(filter, "$share/") {
+		parts := strings.SplitN(filter, "/", 3)
+		if len(parts) < 3 {
+			return false
+		}
+		filter = parts[2]
+	}
+	fs := strings.Split(filter, "/")
+	ts := strings.Split(topic, "/")
+	// the wildcards do not match the topics starting with $
+	if len(topic) > 0 && topic[0] == '$' && len(fs) > 0 && (fs[0] == "#" || fs[0] == "+") {
+		return false
+	}
+	for i, f := range fs {
+		if f == "#" {
+			return true
+		}
+		if i >= len(ts) {
+			return false
+		}
+		if f != "+" && f != ts[i] {
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
+
+type message5 struct {
+	p     *paho.Publish
+	topic string
+}
+
+func (m *message5) Duplicate() bool {
+	return false
+}
+
+func (m *message5) Qos() byte {
+	return m.p.QoS
+}
+
+func (m *message5) Retained() bool {
+	return m.p.Retain
+}
+
+func (m *message5) Topic() string {
+	return m.topic
+}
+
+func (m *message5) MessageID() uint16 {
+	return m.p.PacketID
+}
+
+func (m *message5) Payload() []byte {
+	return m.p.Payload
+}
+
+// Ack is done by the client automatically
+func (m *message5) Ack() {}
+
+// Properties returns the set properties of the message. The user properties are a map, the last value is kept for the duplicate keys
+func (m *message5) Properties() map[string]interface{} {
+	result := make(map[string]interface{})
+	props := m.p.Properties
+	if props == nil {
+		return result
+	}
+	if props.MessageExpiry != nil {
+		result["messageExpiry"] = int64(*props.MessageExpiry)
+	}
+	if props.ContentType != "" {
+		result["contentType"] = props.ContentType
+	}
+	if props.ResponseTopic != "" {
+		result["responseTopic"] = props.ResponseTopic
+	}
+	if len(props.CorrelationData) > 0 {
+		result["correlationData"] = string(props.CorrelationData)
+	}
+	if len(props.User) > 0 {
+		up := make(map[string]interface{}, len(props.User))
+		for _, u := range props.User {
+			up[u.Key] = u.Value
+		}
+		result["userProperties"] = up
+	}
+	return result
+}
@@ -18,11 +18,13 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	pahoMqtt "github.com/eclipse/paho.mqtt.golang"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
+	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
 )
@@ -88,11 +90,11 @@ func (mc *mqttClientWrapper) onConnectHandler(_ pahoMqtt.Client) {
 	defer mc.subLock.Unlock()
 	mc.connected = true
 	for topic, subscription := range mc.topicSubscriptions {
-		token := mc.cli.conn.Subscribe(topic, subscription.qos, subscription.topicHandler)
-		if token.Error() != nil {
+		err := mc.cli.Subscribe(topic, subscription.qos, subscription.topicHandler)
+		if err != nil {
 			for _, con := range subscription.topicConsumers {
 				select {
-				case con.SubErrors <- token.Error():
+				case con.SubErrors <- err:
 					break
 				default:
 					conf.Log.Warnf("consumer SubErrors channel full for request id %s", con.ConsumerId)
@@ -132,13 +134,36 @@ func (mc *mqttClientWrapper) newMessageHandler(sub *mqttSubscriptionInfo) pahoMq
 				case consumer.ConsumerChan <- message:
 					break
 				default:
-					conf.Log.Warnf("consumer chan full for request id %s", consumer.ConsumerId)
+					if consumer.BlockTimeout > 0 {
+						sendWithBackPressure(consumer, message)
+					} else {
+						conf.Log.Warnf("consumer chan full for request id %s", consumer.ConsumerId)
+					}
 				}
 			}
 		}
 	}
 }
 
+// sendWithBackPressure blocks the handler to pause the consumption of the client so that the back pressure propagates
+// to the broker. It cannot block too long or the keepalive of the connection will fail, so the message is dropped
+// after the BlockTimeout of the consumer.
+func sendWithBackPressure(consumer *clients.ConsumerInfo, message pahoMqtt.Message) {
+	conf.Log.Debugf("consumer chan full for request id %s, pause consuming", consumer.ConsumerId)
+	start := time.Now()
+	timer := time.NewTimer(consumer.BlockTimeout)
+	defer timer.Stop()
+	select {
+	case consumer.ConsumerChan <- message:
+	case <-consumer.Done:
+	case <-timer.C:
+		conf.Log.Warnf("consumer chan full for request id %s in %v, drop the message", consumer.ConsumerId, consumer.BlockTimeout)
+	}
+	if consumer.OnBlock != nil {
+		consumer.OnBlock(time.Since(start))
+	}
+}
+
 func (mc *mqttClientWrapper) Publish(_ api.StreamContext, topic string, message []byte, params map[string]interface{}) error {
 	err := mc.checkConn()
 	if err != nil {
@@ -157,7 +182,14 @@ func (mc *mqttClientWrapper) Publish(_ api.StreamContext, topic string, message
 		}
 	}
 
-	err = mc.cli.Publish(topic, Qos, retained, message)
+	var props *PublishProperties
+	if pp, ok := params["properties"]; ok {
+		if v, ok := pp.(*PublishProperties); ok {
+			props = v
+		}
+	}
+
+	err = mc.cli.Publish(topic, Qos, retained, message, props)
 	if err != nil {
 		return err
 	}
@@ -191,6 +223,17 @@ func (mc *mqttClientWrapper) Subscribe(c api.StreamContext, subChan []api.TopicC
 			Qos = v
 		}
 	}
+	// only wait for the consumer whose rule enables the back pressure
+	var (
+		blockTimeout time.Duration
+		onBlock      func(wait time.Duration)
+	)
+	if r, ok := c.Value(kctx.BackPressureKey).(kctx.BackPressureRecorder); ok {
+		if bt, ok := params["backPressureTimeout"].(int); ok && bt > 0 {
+			blockTimeout = time.Duration(bt) * time.Millisecond
+			onBlock = r.IncBackPressure
+		}
+	}
 
 	subTopics := clients.SubscribedTopics{
 		Topics: make([]string, 0),
@@ -205,6 +248,9 @@ func (mc *mqttClientWrapper) Subscribe(c api.StreamContext, subChan []api.TopicC
 				ConsumerId:   subId,
 				ConsumerChan: tpChan.Messages,
 				SubErrors:    messageErrors,
+				Done:         c.Done(),
+				BlockTimeout: blockTimeout,
+				OnBlock:      onBlock,
 			})
 			log.Infof("subscription for topic %s already exists, reqId is %s, total subs %d", tpc, subId, len(sub.topicConsumers))
 		} else {
@@ -216,14 +262,16 @@ func (mc *mqttClientWrapper) Subscribe(c api.StreamContext, subChan []api.TopicC
 						ConsumerId:   subId,
 						ConsumerChan: tpChan.Messages,
 						SubErrors:    messageErrors,
+						Done:         c.Done(),
+						BlockTimeout: blockTimeout,
+						OnBlock:      onBlock,
 					},
 				},
 			}
 			sub.topicHandler = mc.newMessageHandler(sub)
 			log.Infof("new subscription for topic %s, reqId is %s", tpc, subId)
-			token := mc.cli.conn.Subscribe(tpc, Qos, sub.topicHandler)
-			if token.Error() != nil {
-				return token.Error()
+			if err := mc.cli.Subscribe(tpc, Qos, sub.topicHandler); err != nil {
+				return err
 			}
 			mc.topicSubscriptions[tpc] = sub
 		}
@@ -256,7 +304,7 @@ func (mc *mqttClientWrapper) unsubscribe(c api.StreamContext) {
 			if 0 == len(sub.topicConsumers) {
 				delete(mc.topicSubscriptions, tpc)
 				log.Infof("delete subscription for topic %s", tpc)
-				mc.cli.conn.Unsubscribe(tpc)
+				mc.cli.Unsubscribe(tpc)
 			}
 		}
 	}
@@ -268,6 +316,17 @@ func (mc *mqttClientWrapper) Release(c api.StreamContext) bool {
 	return mc.deRef(c)
 }
 
+// Ping checks the connection for the health check of the connection pool
+func (mc *mqttClientWrapper) Ping() error {
+	return mc.checkConn()
+}
+
+// Close disconnects the client when the connection is removed from the connection pool. The client reconnects by
+// itself, so it does not implement the Reconnector.
+func (mc *mqttClientWrapper) Close() error {
+	return mc.cli.Disconnect()
+}
+
 func (mc *mqttClientWrapper) SetConnectionSelector(conSelector string) {
 	mc.conSelector = conSelector
 }
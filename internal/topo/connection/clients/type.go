@@ -15,6 +15,8 @@
 package clients
 
 import (
+	"time"
+
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
@@ -22,6 +24,12 @@ type ConsumerInfo struct {
 	ConsumerId   string
 	ConsumerChan chan<- interface{}
 	SubErrors    chan error
+	// Done is closed when the consumer exits, so that the client will not wait for it to consume
+	Done <-chan struct{}
+	// BlockTimeout is the max time to wait when the ConsumerChan is full. If it is 0, the message is dropped at once
+	BlockTimeout time.Duration
+	// OnBlock records the time waiting for the consumer if the BlockTimeout is set
+	OnBlock func(wait time.Duration)
 }
 
 type SubscribedTopics struct {
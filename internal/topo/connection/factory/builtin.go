@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -17,10 +17,18 @@ package factory
 import (
 	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
 	"github.com/lf-edge/ekuiper/internal/topo/connection/clients/mqtt"
+	"github.com/lf-edge/ekuiper/internal/topo/connection/pool"
 )
 
 func init() {
 	clientsFactory["mqtt"] = func(props map[string]interface{}) (clients.ClientWrapper, error) {
 		return mqtt.NewMqttClientWrapper(props)
 	}
+	pool.RegisterProvider("mqtt", func(props map[string]interface{}) (pool.Conn, error) {
+		cw, err := mqtt.NewMqttClientWrapper(props)
+		if err != nil {
+			return nil, err
+		}
+		return cw.(pool.Conn), nil
+	})
 }
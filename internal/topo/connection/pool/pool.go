@@ -0,0 +1,438 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pool manages the connection resources which are defined once and shared by the sources and sinks of all
+// rules. The manager creates the connections, checks their health periodically, reconnects them and counts their
+// references so that a connection in use cannot be changed or deleted.
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+// healthCheckInterval is the interval to check the health of all connections
+const healthCheckInterval = 10 * time.Second
+
+const (
+	StatusConnected    = "connected"
+	StatusDisconnected = "disconnected"
+)
+
+// Conn is the physical connection created by the provider
+type Conn interface {
+	// Ping checks the health of the connection
+	Ping() error
+	Close() error
+}
+
+// Reconnector is implemented by the connections which do not reconnect by themselves. The manager calls it after the
+// health check fails.
+type Reconnector interface {
+	Reconnect() error
+}
+
+// Provider creates the connection of a type by the properties
+type Provider func(props map[string]interface{}) (Conn, error)
+
+// Connection is the definition of a shared connection
+type Connection struct {
+	ID    string                 `json:"id"`
+	Typ   string                 `json:"type"`
+	Props map[string]interface{} `json:"props"`
+}
+
+// Status is the runtime status and the metrics of a connection
+type Status struct {
+	ID    string                 `json:"id"`
+	Typ   string                 `json:"type"`
+	Props map[string]interface{} `json:"props,omitempty"`
+	// Status is connected or disconnected
+	Status string `json:"status"`
+	// RefCount is the number of the sources and sinks using the connection
+	RefCount  int    `json:"refCount"`
+	LastError string `json:"lastError,omitempty"`
+	// LastCheckTime is the unix milli time of the last health check
+	LastCheckTime int64 `json:"lastCheckTime"`
+	// ConnectedTime is the unix milli time since when the connection is healthy
+	ConnectedTime int64 `json:"connectedTime"`
+	CheckTotal    int64 `json:"checkTotal"`
+	CheckFailures int64 `json:"checkFailures"`
+	// ReconnectTotal is the times the manager reconnects after the health check fails. The connections which reconnect
+	// by themselves like MQTT are not counted.
+	ReconnectTotal int64 `json:"reconnectTotal"`
+}
+
+type managedConn struct {
+	def *Connection
+	// conn is nil if it fails to create, then it is created again in the health check
+	conn   Conn
+	status *Status
+}
+
+type manager struct {
+	sync.RWMutex
+	providers map[string]Provider
+	conns     map[string]*managedConn
+	db        kv.KeyValue
+	started   bool
+}
+
+var m = &manager{
+	providers: make(map[string]Provider),
+	conns:     make(map[string]*managedConn),
+}
+
+// RegisterProvider registers the provider of a connection type. The types provided by the plugins are registered when
+// the plugins are loaded, then the saved connections of the type are connected.
+func RegisterProvider(typ string, p Provider) {
+	m.Lock()
+	defer m.Unlock()
+	m.providers[typ] = p
+	for _, mc := range m.conns {
+		if mc.def.Typ == typ && mc.conn == nil {
+			go m.check(mc)
+		}
+	}
+}
+
+// InitManager loads the saved connections and starts the health check. The connections failing to create are kept and
+// retried in the health check.
+func InitManager() error {
+	db, err := store.GetKV("connection")
+	if err != nil {
+		return fmt.Errorf("can not initialize connection store: %v", err)
+	}
+	all, err := db.All()
+	if err != nil {
+		return fmt.Errorf("load connections error: %v", err)
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.db = db
+	for id, s := range all {
+		def := &Connection{}
+		if err := json.Unmarshal([]byte(s), def); err != nil {
+			conf.Log.Errorf("parse connection %s error: %v", id, err)
+			continue
+		}
+		mc := newManagedConn(def)
+		if _, ok := m.providers[def.Typ]; !ok {
+			mc.status.LastError = fmt.Sprintf("connection type %s is not loaded", def.Typ)
+		} else if err := m.connect(mc); err != nil {
+			conf.Log.Warnf("connect %s error, will retry in the health check: %v", id, err)
+		}
+		m.conns[id] = mc
+	}
+	if !m.started {
+		m.started = true
+		go m.runHealthCheck()
+	}
+	return nil
+}
+
+func newManagedConn(def *Connection) *managedConn {
+	return &managedConn{
+		def: def,
+		status: &Status{
+			ID:     def.ID,
+			Typ:    def.Typ,
+			Status: StatusDisconnected,
+		},
+	}
+}
+
+// newConn creates the managed connection. The connection whose type is not registered yet is kept disconnected until
+// the provider is registered. Must be called with the lock.
+func (m *manager) newConn(c *Connection) (*managedConn, error) {
+	mc := newManagedConn(c)
+	if _, ok := m.providers[c.Typ]; !ok {
+		mc.status.LastError = fmt.Sprintf("connection type %s is not loaded", c.Typ)
+		return mc, nil
+	}
+	if err := m.connect(mc); err != nil {
+		return nil, fmt.Errorf("connect %s error: %v", c.ID, err)
+	}
+	return mc, nil
+}
+
+// connect creates the connection by the provider. Must be called with the lock.
+func (m *manager) connect(mc *managedConn) error {
+	p, ok := m.providers[mc.def.Typ]
+	if !ok {
+		err := fmt.Errorf("connection type %s is not loaded", mc.def.Typ)
+		mc.status.LastError = err.Error()
+		return err
+	}
+	c, err := p(mc.def.Props)
+	if err != nil {
+		mc.status.LastError = err.Error()
+		return err
+	}
+	mc.conn = c
+	mc.status.Status = StatusConnected
+	mc.status.LastError = ""
+	mc.status.ConnectedTime = conf.GetNowInMilli()
+	return nil
+}
+
+func validate(c *Connection) error {
+	if c.ID == "" {
+		return fmt.Errorf("connection id is required")
+	}
+	if c.Typ == "" {
+		return fmt.Errorf("connection type is required")
+	}
+	return nil
+}
+
+// CreateConnection connects and saves the connection. It fails if the connection cannot be created.
+func CreateConnection(c *Connection) error {
+	m.Lock()
+	defer m.Unlock()
+	if err := validate(c); err != nil {
+		return err
+	}
+	if _, ok := m.conns[c.ID]; ok {
+		return fmt.Errorf("connection %s already exists", c.ID)
+	}
+	mc, err := m.newConn(c)
+	if err != nil {
+		return err
+	}
+	if err := m.save(c); err != nil {
+		if mc.conn != nil {
+			_ = mc.conn.Close()
+		}
+		return err
+	}
+	m.conns[c.ID] = mc
+	return nil
+}
+
+// UpdateConnection replaces the connection. The connection in use cannot be updated.
+func UpdateConnection(c *Connection) error {
+	m.Lock()
+	defer m.Unlock()
+	if err := validate(c); err != nil {
+		return err
+	}
+	old, ok := m.conns[c.ID]
+	if !ok {
+		return errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("connection %s is not found", c.ID))
+	}
+	if old.status.RefCount > 0 {
+		return fmt.Errorf("connection %s is used by %d sources or sinks", c.ID, old.status.RefCount)
+	}
+	mc, err := m.newConn(c)
+	if err != nil {
+		return err
+	}
+	if err := m.save(c); err != nil {
+		if mc.conn != nil {
+			_ = mc.conn.Close()
+		}
+		return err
+	}
+	if old.conn != nil {
+		_ = old.conn.Close()
+	}
+	m.conns[c.ID] = mc
+	return nil
+}
+
+// DeleteConnection closes and removes the connection. The connection in use cannot be deleted.
+func DeleteConnection(id string) error {
+	m.Lock()
+	defer m.Unlock()
+	mc, ok := m.conns[id]
+	if !ok {
+		return errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("connection %s is not found", id))
+	}
+	if mc.status.RefCount > 0 {
+		return fmt.Errorf("connection %s is used by %d sources or sinks", id, mc.status.RefCount)
+	}
+	if err := m.db.Delete(id); err != nil {
+		return fmt.Errorf("delete connection %s error: %v", id, err)
+	}
+	if mc.conn != nil {
+		_ = mc.conn.Close()
+	}
+	delete(m.conns, id)
+	return nil
+}
+
+func (m *manager) save(c *Connection) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal connection %s error: %v", c.ID, err)
+	}
+	if err := m.db.Set(c.ID, string(b)); err != nil {
+		return fmt.Errorf("save connection %s error: %v", c.ID, err)
+	}
+	return nil
+}
+
+// GetConnectionStatus returns the definition and the status of the connection. The passwords are hidden.
+func GetConnectionStatus(id string) (*Status, error) {
+	m.RLock()
+	defer m.RUnlock()
+	mc, ok := m.conns[id]
+	if !ok {
+		return nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("connection %s is not found", id))
+	}
+	s := *mc.status
+	s.Props = conf.Printable(mc.def.Props)
+	return &s, nil
+}
+
+// GetConnection returns the definition of the connection for the sources and sinks which need the properties
+func GetConnection(id string) (*Connection, error) {
+	m.RLock()
+	defer m.RUnlock()
+	mc, ok := m.conns[id]
+	if !ok {
+		return nil, fmt.Errorf("connection %s is not found", id)
+	}
+	c := *mc.def
+	return &c, nil
+}
+
+// GetAllConnectionStatus returns the status of all connections sorted by id without the properties
+func GetAllConnectionStatus() []*Status {
+	m.RLock()
+	defer m.RUnlock()
+	result := make([]*Status, 0, len(m.conns))
+	for _, mc := range m.conns {
+		s := *mc.status
+		result = append(result, &s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// Attach returns the connection and adds a reference. The connection is created if it failed before. Call Detach
+// when the connection is no longer used.
+func Attach(id string, typ string) (Conn, error) {
+	m.Lock()
+	defer m.Unlock()
+	mc, ok := m.conns[id]
+	if !ok {
+		return nil, fmt.Errorf("connection %s is not found", id)
+	}
+	if mc.def.Typ != typ {
+		return nil, fmt.Errorf("connection %s is of type %s but %s is required", id, mc.def.Typ, typ)
+	}
+	if mc.conn == nil {
+		if err := m.connect(mc); err != nil {
+			return nil, fmt.Errorf("connect %s error: %v", id, err)
+		}
+	}
+	mc.status.RefCount++
+	return mc.conn, nil
+}
+
+// Detach removes a reference of the connection. The connection is kept open until it is deleted.
+func Detach(id string) {
+	m.Lock()
+	defer m.Unlock()
+	if mc, ok := m.conns[id]; ok && mc.status.RefCount > 0 {
+		mc.status.RefCount--
+	}
+}
+
+func (m *manager) runHealthCheck() {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.checkAll()
+	}
+}
+
+// checkAll pings all connections without the lock because the ping may block
+func (m *manager) checkAll() {
+	m.RLock()
+	all := make([]*managedConn, 0, len(m.conns))
+	for _, mc := range m.conns {
+		all = append(all, mc)
+	}
+	m.RUnlock()
+	for _, mc := range all {
+		m.check(mc)
+	}
+}
+
+func (m *manager) check(mc *managedConn) {
+	m.RLock()
+	c := mc.conn
+	p := m.providers[mc.def.Typ]
+	m.RUnlock()
+	var (
+		err error
+		// whether the manager tries to reconnect
+		reconnect bool
+	)
+	switch {
+	case c == nil:
+		reconnect = true
+		if p == nil {
+			err = fmt.Errorf("connection type %s is not loaded", mc.def.Typ)
+		} else if c, err = p(mc.def.Props); err == nil {
+			m.Lock()
+			if m.conns[mc.def.ID] == mc && mc.conn == nil {
+				mc.conn = c
+			} else { // replaced or connected by others during the creation
+				_ = c.Close()
+			}
+			m.Unlock()
+		}
+	default:
+		if err = c.Ping(); err != nil {
+			if r, ok := c.(Reconnector); ok {
+				reconnect = true
+				if e := r.Reconnect(); e != nil {
+					conf.Log.Warnf("reconnect %s error: %v", mc.def.ID, e)
+				}
+			}
+		}
+	}
+	m.Lock()
+	defer m.Unlock()
+	mc.status.CheckTotal++
+	if reconnect {
+		mc.status.ReconnectTotal++
+	}
+	mc.status.LastCheckTime = conf.GetNowInMilli()
+	if err != nil {
+		if mc.status.Status == StatusConnected {
+			conf.Log.Warnf("connection %s is unhealthy: %v", mc.def.ID, err)
+		}
+		mc.status.CheckFailures++
+		mc.status.Status = StatusDisconnected
+		mc.status.LastError = err.Error()
+	} else if mc.status.Status != StatusConnected {
+		conf.Log.Infof("connection %s is healthy", mc.def.ID)
+		mc.status.Status = StatusConnected
+		mc.status.LastError = ""
+		mc.status.ConnectedTime = conf.GetNowInMilli()
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+type mockConn struct {
+	healthy     bool
+	closed      bool
+	reconnected int
+}
+
+func (c *mockConn) Ping() error {
+	if !c.healthy {
+		return errors.New("mock disconnected")
+	}
+	return nil
+}
+
+func (c *mockConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *mockConn) Reconnect() error {
+	c.reconnected++
+	c.healthy = true
+	return nil
+}
+
+func TestConnectionManager(t *testing.T) {
+	testx.InitEnv()
+	var created []*mockConn
+	RegisterProvider("mock", func(props map[string]interface{}) (Conn, error) {
+		if props["fail"] == true {
+			return nil, errors.New("mock connect error")
+		}
+		c := &mockConn{healthy: true}
+		created = append(created, c)
+		return c, nil
+	})
+	if err := InitManager(); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteConnection("mock1")
+
+	tests := []struct {
+		c   *Connection
+		err string
+	}{
+		{c: &Connection{Typ: "mock"}, err: "connection id is required"},
+		{c: &Connection{ID: "mock1"}, err: "connection type is required"},
+		{c: &Connection{ID: "mock1", Typ: "mock", Props: map[string]interface{}{"fail": true}}, err: "connect mock1 error: mock connect error"},
+		{c: &Connection{ID: "mock1", Typ: "mock", Props: map[string]interface{}{"password": "secret"}}},
+		{c: &Connection{ID: "mock1", Typ: "mock"}, err: "connection mock1 already exists"},
+	}
+	for i, tt := range tests {
+		err := CreateConnection(tt.c)
+		if (err == nil && tt.err != "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("%d error mismatch, expect %s but got %v", i, tt.err, err)
+		}
+	}
+	if _, err := Attach("mock1", "mqtt"); err == nil || err.Error() != "connection mock1 is of type mock but mqtt is required" {
+		t.Errorf("attach type mismatch error is expected, got %v", err)
+	}
+	c, err := Attach("mock1", "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != created[0] {
+		t.Errorf("attach should return the shared connection")
+	}
+	if err := DeleteConnection("mock1"); err == nil || err.Error() != "connection mock1 is used by 1 sources or sinks" {
+		t.Errorf("delete in use error is expected, got %v", err)
+	}
+	if err := UpdateConnection(&Connection{ID: "mock1", Typ: "mock"}); err == nil || err.Error() != "connection mock1 is used by 1 sources or sinks" {
+		t.Errorf("update in use error is expected, got %v", err)
+	}
+
+	// health check fails and reconnects
+	created[0].healthy = false
+	m.checkAll()
+	s, err := GetConnectionStatus("mock1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := &Status{
+		ID:             "mock1",
+		Typ:            "mock",
+		Props:          map[string]interface{}{"password": "***"},
+		Status:         StatusDisconnected,
+		RefCount:       1,
+		LastError:      "mock disconnected",
+		LastCheckTime:  s.LastCheckTime,
+		ConnectedTime:  s.ConnectedTime,
+		CheckTotal:     1,
+		CheckFailures:  1,
+		ReconnectTotal: 1,
+	}
+	if !reflect.DeepEqual(s, exp) {
+		t.Errorf("status mismatch\nexpect\t%+v\nbut got\t%+v", exp, s)
+	}
+	m.checkAll()
+	all := GetAllConnectionStatus()
+	if len(all) != 1 || all[0].Status != StatusConnected || all[0].CheckTotal != 2 || all[0].LastError != "" || all[0].Props != nil {
+		t.Errorf("status after reconnection mismatch, got %+v", all)
+	}
+
+	Detach("mock1")
+	if err := UpdateConnection(&Connection{ID: "mock1", Typ: "mock"}); err != nil {
+		t.Fatal(err)
+	}
+	if !created[0].closed || len(created) != 2 {
+		t.Errorf("the old connection should be closed and replaced")
+	}
+	if err := DeleteConnection("mock1"); err != nil {
+		t.Fatal(err)
+	}
+	if !created[1].closed {
+		t.Errorf("the connection should be closed after deletion")
+	}
+	if _, err := GetConnectionStatus("mock1"); err == nil {
+		t.Errorf("connection should be deleted")
+	}
+
+	// the type registered later like the plugins
+	if err := CreateConnection(&Connection{ID: "later1", Typ: "later"}); err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteConnection("later1")
+	if _, err := Attach("later1", "later"); err == nil || err.Error() != "connect later1 error: connection type later is not loaded" {
+		t.Errorf("attach not loaded error is expected, got %v", err)
+	}
+	RegisterProvider("later", func(props map[string]interface{}) (Conn, error) {
+		return &mockConn{healthy: true}, nil
+	})
+	m.checkAll()
+	s, err = GetConnectionStatus("later1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Status != StatusConnected || s.LastError != "" {
+		t.Errorf("connection should be connected after the provider is registered, got %+v", s)
+	}
+}
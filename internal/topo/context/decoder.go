@@ -17,6 +17,7 @@ package context
 import (
 	"fmt"
 
+	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/message"
 )
 
@@ -24,6 +25,12 @@ const (
 	DecodeKey = "$$decode"
 )
 
+// DeadLetterSender is implemented by the dead letter queue of the rule which is put into the context by DeadLetterKey
+type DeadLetterSender interface {
+	// SendDecodeError sends the data failing to decode and returns false if it is not sent
+	SendDecodeError(ctx api.StreamContext, err error, data interface{}) bool
+}
+
 func (c *DefaultContext) Decode(data []byte) (map[string]interface{}, error) {
 	v := c.Value(DecodeKey)
 	f, ok := v.(message.Converter)
@@ -47,7 +54,12 @@ func (c *DefaultContext) DecodeIntoList(data []byte) ([]map[string]interface{},
 	if ok {
 		t, err := f.Decode(data)
 		if err != nil {
-			return nil, fmt.Errorf("decode failed: %v", err)
+			pe, ok := err.(*message.PartialDecodeError)
+			if !ok {
+				return nil, fmt.Errorf("decode failed: %v", err)
+			}
+			c.sendMalformed(pe.Malformed)
+			t = pe.Result
 		}
 		typeErr := fmt.Errorf("only map[string]interface{} and []map[string]interface{} is supported but got: %v", t)
 		switch r := t.(type) {
@@ -70,3 +82,13 @@ func (c *DefaultContext) DecodeIntoList(data []byte) ([]map[string]interface{},
 	}
 	return nil, fmt.Errorf("no decoder configured")
 }
+
+// sendMalformed sends the malformed rows to the dead letter queue, or drops them if the rule has no dead letter queue
+func (c *DefaultContext) sendMalformed(rows []*message.MalformedRow) {
+	dl, _ := c.Value(DeadLetterKey).(DeadLetterSender)
+	for _, r := range rows {
+		if dl == nil || !dl.SendDecodeError(c, r.Err, r.Data) {
+			c.GetLogger().Warnf("drop malformed row %s: %v", r.Data, r.Err)
+		}
+	}
+}
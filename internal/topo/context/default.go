@@ -31,7 +31,18 @@ import (
 	"github.com/lf-edge/ekuiper/pkg/cast"
 )
 
-const LoggerKey = "$$logger"
+const (
+	LoggerKey = "$$logger"
+	// DeadLetterKey is the key of the dead letter queue of the rule, which is shared by all the nodes
+	DeadLetterKey = "$$deadLetter"
+	// BackPressureKey is the key of the BackPressureRecorder of a source instance whose rule enables the back pressure
+	BackPressureKey = "$$backPressure"
+)
+
+// BackPressureRecorder records the time that the source connector waits for the rule to consume
+type BackPressureRecorder interface {
+	IncBackPressure(d time.Duration)
+}
 
 type DefaultContext struct {
 	ruleId     string
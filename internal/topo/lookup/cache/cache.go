@@ -15,6 +15,7 @@
 package cache
 
 import (
+	"container/list"
 	"context"
 	"sync"
 
@@ -23,23 +24,31 @@ import (
 )
 
 type item struct {
+	key        string
 	data       []api.SourceTuple
 	expiration int64
 }
 
+// Cache is the lookup result cache with the optional expiration. If the max size is set, the least recently used
+// items are evicted when the cache is full
 type Cache struct {
 	expireTime      int
 	cacheMissingKey bool
+	maxSize         int
 	cancel          context.CancelFunc
-	items           map[string]*item
-	sync.RWMutex
+	items           map[string]*list.Element
+	// the front is the most recently used
+	lru *list.List
+	sync.Mutex
 }
 
-func NewCache(expireTime int, cacheMissingKey bool) *Cache {
+func NewCache(expireTime int, cacheMissingKey bool, maxSize int) *Cache {
 	c := &Cache{
 		expireTime:      expireTime,
 		cacheMissingKey: cacheMissingKey,
-		items:           make(map[string]*item),
+		maxSize:         maxSize,
+		items:           make(map[string]*list.Element),
+		lru:             list.New(),
 	}
 	if expireTime > 0 {
 		ctx, cancel := context.WithCancel(context.Background())
@@ -66,8 +75,10 @@ func (c *Cache) run(ctx context.Context) {
 func (c *Cache) deleteExpired() {
 	now := conf.GetNowInMilli()
 	c.Lock()
-	for k, v := range c.items {
+	for k, e := range c.items {
+		v := e.Value.(*item)
 		if v.expiration > 0 && now > v.expiration {
+			c.lru.Remove(e)
 			delete(c.items, k)
 		}
 	}
@@ -80,20 +91,32 @@ func (c *Cache) Set(key string, value []api.SourceTuple) {
 	}
 	c.Lock()
 	defer c.Unlock()
+	v := &item{key: key, data: value}
 	if c.expireTime > 0 {
-		c.items[key] = &item{data: value, expiration: conf.GetNowInMilli() + int64(c.expireTime*1000)}
-	} else {
-		c.items[key] = &item{data: value}
+		v.expiration = conf.GetNowInMilli() + int64(c.expireTime*1000)
+	}
+	if e, ok := c.items[key]; ok {
+		e.Value = v
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.items[key] = c.lru.PushFront(v)
+	if c.maxSize > 0 && c.lru.Len() > c.maxSize {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.items, e.Value.(*item).key)
 	}
 }
 
 func (c *Cache) Get(key string) ([]api.SourceTuple, bool) {
-	c.RLock()
-	defer c.RUnlock()
-	if v, ok := c.items[key]; ok {
+	c.Lock()
+	defer c.Unlock()
+	if e, ok := c.items[key]; ok {
+		v := e.Value.(*item)
 		if v.expiration > 0 && conf.GetNowInMilli() > v.expiration {
 			return nil, false
 		}
+		c.lru.MoveToFront(e)
 		return v.data, true
 	}
 	return nil, false
@@ -104,4 +127,5 @@ func (c *Cache) Close() {
 		c.cancel()
 	}
 	c.items = nil
+	c.lru = nil
 }
@@ -26,7 +26,7 @@ import (
 )
 
 func TestExpiration(t *testing.T) {
-	c := NewCache(20, false)
+	c := NewCache(20, false, 0)
 	defer c.Close()
 	clock := conf.Clock.(*clock.Mock)
 	expects := [][]api.SourceTuple{
@@ -84,7 +84,7 @@ func TestExpiration(t *testing.T) {
 }
 
 func TestNoExpiration(t *testing.T) {
-	c := NewCache(0, true)
+	c := NewCache(0, true, 0)
 	defer c.Close()
 	clock := conf.Clock.(*clock.Mock)
 	expects := [][]api.SourceTuple{
@@ -120,3 +120,36 @@ func TestNoExpiration(t *testing.T) {
 		return
 	}
 }
+
+func TestLRU(t *testing.T) {
+	c := NewCache(0, false, 2)
+	defer c.Close()
+	clock := conf.Clock.(*clock.Mock)
+	expects := [][]api.SourceTuple{
+		{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"a": 1}, nil, clock.Now())},
+		{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"a": 2}, nil, clock.Now())},
+		{api.NewDefaultSourceTupleWithTime(map[string]interface{}{"a": 3}, nil, clock.Now())},
+	}
+	c.Set("a", expects[0])
+	c.Set("b", expects[1])
+	// a is used recently so b is evicted
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a should exist")
+		return
+	}
+	c.Set("c", expects[2])
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should be evicted")
+		return
+	}
+	for k, i := range map[string]int{"a": 0, "c": 2} {
+		r, ok := c.Get(k)
+		if !ok {
+			t.Errorf("%s should exist", k)
+			return
+		}
+		if !reflect.DeepEqual(r, expects[i]) {
+			t.Errorf("expect %v but get %v", expects[i], r)
+		}
+	}
+}
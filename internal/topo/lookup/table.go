@@ -30,6 +30,14 @@ import (
 // Table is a lookup table runtime instance. It will run once the table is created.
 // It will only stop once the table is dropped.
 
+// BatchLookupSource is the lookup source which can look up the values of multiple rows in one round trip.
+// The lookup node uses it to look up the rows of a window in a batch
+type BatchLookupSource interface {
+	api.LookupSource
+	// LookupBatch looks up each value set in values. The result of each value set is in the same index.
+	LookupBatch(ctx api.StreamContext, fields []string, keys []string, values [][]interface{}) ([][]api.SourceTuple, error)
+}
+
 type info struct {
 	ls    api.LookupSource
 	count int32
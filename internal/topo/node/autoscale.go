@@ -0,0 +1,275 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+const (
+	// scaleUpRatio is the usage of the operator buffer to add an instance
+	scaleUpRatio = 0.5
+	// scaleDownChecks is the count of continuous checks with an empty buffer to remove an instance
+	scaleDownChecks = 3
+	// keyedBufferLength is the buffer length of each instance when keeping the order of keys. It is small so that the
+	// instances can finish the dispatched data quickly when rescaling.
+	keyedBufferLength = 16
+)
+
+type scaleWorker struct {
+	cancel context.CancelFunc
+	// input is only used when keeping the order of keys, otherwise the workers share the operator input
+	input chan interface{}
+	// pending is the count of dispatched data which are not processed yet
+	pending int64
+}
+
+// autoScaler adjusts the instances of a stateless unary operator by the usage of its buffer and the cpu. Without order
+// keys, the instances share the operator input. Otherwise, the data are dispatched to the instances by the hash of the
+// order keys so that the data of the same key are processed by the same instance in order.
+type autoScaler struct {
+	o          *UnaryOperator
+	min        int
+	max        int
+	maxCpu     float64
+	workers    []*scaleWorker
+	stats      []metric.StatManager
+	idleChecks int
+	cpu        *cpuSampler
+}
+
+func newAutoScaler(o *UnaryOperator) *autoScaler {
+	return &autoScaler{
+		o:      o,
+		min:    o.concurrency,
+		max:    o.autoScale.MaxConcurrency,
+		maxCpu: o.autoScale.MaxCpu,
+		cpu:    newCpuSampler(),
+	}
+}
+
+func (s *autoScaler) run(ctx api.StreamContext, errCh chan<- error) {
+	for i := 0; i < s.min; i++ {
+		if err := s.scaleUp(ctx, errCh); err != nil {
+			infra.DrainError(ctx, err, errCh)
+			return
+		}
+	}
+	go func() {
+		err := infra.SafeRun(func() error {
+			return s.watch(ctx, errCh)
+		})
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+		}
+	}()
+}
+
+// watch checks the load periodically. When keeping the order of keys, it also dispatches the data to the instances.
+func (s *autoScaler) watch(ctx api.StreamContext, errCh chan<- error) error {
+	keyed := len(s.o.orderKeys) > 0
+	input := s.o.input
+	if !keyed {
+		// the instances consume the input directly
+		input = nil
+	}
+	fv, _ := xsql.NewFunctionValuersForOp(ctx)
+	ticker := conf.GetTicker(s.o.autoScale.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case item := <-input:
+			processed := false
+			if item, processed = s.o.preprocess(item); processed {
+				break
+			}
+			w := s.workers[s.partition(item, fv)]
+			atomic.AddInt64(&w.pending, 1)
+			select {
+			case w.input <- item:
+			case <-ctx.Done():
+				return nil
+			}
+		case <-ticker.C:
+			delta := s.decide(len(s.o.input), cap(s.o.input), s.cpu.usage())
+			if delta == 0 {
+				break
+			}
+			// finish the dispatched data before rescaling so that the data of the same key won't be reordered
+			if keyed && !s.drain(ctx) {
+				return nil
+			}
+			if delta > 0 {
+				if err := s.scaleUp(ctx, errCh); err != nil {
+					return err
+				}
+			} else {
+				s.scaleDown(ctx)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// decide returns 1 to add an instance, -1 to remove an instance and 0 to keep the instances
+func (s *autoScaler) decide(buffered, capacity int, cpu float64) int {
+	if buffered == 0 {
+		s.idleChecks++
+	} else {
+		s.idleChecks = 0
+	}
+	n := len(s.workers)
+	if capacity > 0 && float64(buffered) >= float64(capacity)*scaleUpRatio {
+		if n < s.max && cpu < s.maxCpu {
+			return 1
+		}
+		return 0
+	}
+	if n > s.min && (cpu >= s.maxCpu || s.idleChecks >= scaleDownChecks) {
+		s.idleChecks = 0
+		return -1
+	}
+	return 0
+}
+
+func (s *autoScaler) scaleUp(ctx api.StreamContext, errCh chan<- error) error {
+	instance := len(s.workers)
+	insCtx := ctx.WithInstance(instance)
+	// reuse the metrics of the instance if it has been scaled down before
+	if instance == len(s.stats) {
+		stats, err := metric.NewStatManager(insCtx, "op")
+		if err != nil {
+			return err
+		}
+		s.stats = append(s.stats, stats)
+		s.o.mutex.Lock()
+		s.o.statManagers = append(s.o.statManagers, stats)
+		s.o.mutex.Unlock()
+	}
+	stats := s.stats[instance]
+	workerCtx, cancel := insCtx.WithCancel()
+	w := &scaleWorker{cancel: cancel}
+	var (
+		input       <-chan interface{} = s.o.input
+		preprocess                     = true
+		onProcessed func()
+	)
+	if len(s.o.orderKeys) > 0 {
+		w.input = make(chan interface{}, keyedBufferLength)
+		input, preprocess = w.input, false
+		onProcessed = func() {
+			atomic.AddInt64(&w.pending, -1)
+		}
+	}
+	s.workers = append(s.workers, w)
+	go func() {
+		err := infra.SafeRun(func() error {
+			s.o.work(workerCtx, input, preprocess, stats, onProcessed)
+			return nil
+		})
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+		}
+	}()
+	ctx.GetLogger().Infof("scale up operator %s to %d instances", s.o.name, len(s.workers))
+	return nil
+}
+
+func (s *autoScaler) scaleDown(ctx api.StreamContext) {
+	last := len(s.workers) - 1
+	s.workers[last].cancel()
+	s.workers = s.workers[:last]
+	ctx.GetLogger().Infof("scale down operator %s to %d instances", s.o.name, len(s.workers))
+}
+
+// partition returns the instance index to process the data by the hash of the order keys
+func (s *autoScaler) partition(item interface{}, fv *xsql.FunctionValuer) int {
+	row, ok := item.(xsql.TupleRow)
+	if !ok {
+		return 0
+	}
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(row, fv)}
+	h := fnv.New32a()
+	for _, d := range s.o.orderKeys {
+		_, _ = fmt.Fprintf(h, "%v,", ve.Eval(d.Expr))
+	}
+	return int(h.Sum32() % uint32(len(s.workers)))
+}
+
+// drain waits until all the dispatched data are processed. Return false if the rule is stopped.
+func (s *autoScaler) drain(ctx api.StreamContext) bool {
+	for _, w := range s.workers {
+		for atomic.LoadInt64(&w.pending) > 0 {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}
+	return true
+}
+
+// cpuSampler calculates the cpu usage of the process by the runtime metrics
+type cpuSampler struct {
+	samples []metrics.Sample
+	total   float64
+	idle    float64
+}
+
+func newCpuSampler() *cpuSampler {
+	c := &cpuSampler{
+		samples: []metrics.Sample{
+			{Name: "/cpu/classes/total:cpu-seconds"},
+			{Name: "/cpu/classes/idle:cpu-seconds"},
+		},
+	}
+	c.total, c.idle = c.read()
+	return c
+}
+
+func (c *cpuSampler) read() (total float64, idle float64) {
+	metrics.Read(c.samples)
+	if c.samples[0].Value.Kind() == metrics.KindFloat64 {
+		total = c.samples[0].Value.Float64()
+	}
+	if c.samples[1].Value.Kind() == metrics.KindFloat64 {
+		idle = c.samples[1].Value.Float64()
+	}
+	return
+}
+
+// usage returns the cpu usage percentage of the process since the last call
+func (c *cpuSampler) usage() float64 {
+	total, idle := c.read()
+	dt, di := total-c.total, idle-c.idle
+	c.total, c.idle = total, idle
+	if dt <= 0 {
+		return 0
+	}
+	return (1 - di/dt) * 100
+}
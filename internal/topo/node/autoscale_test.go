@@ -0,0 +1,124 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestAutoScaleDecide(t *testing.T) {
+	s := &autoScaler{min: 1, max: 3, maxCpu: 80}
+	tests := []struct {
+		workers  int
+		buffered int
+		cpu      float64
+		exp      int
+	}{
+		{workers: 1, buffered: 60, cpu: 10, exp: 1},
+		{workers: 3, buffered: 60, cpu: 10, exp: 0},
+		{workers: 2, buffered: 60, cpu: 90, exp: 0},
+		{workers: 2, buffered: 10, cpu: 90, exp: -1},
+		{workers: 2, buffered: 10, cpu: 10, exp: 0},
+		{workers: 2, buffered: 0, cpu: 10, exp: 0},
+		{workers: 2, buffered: 0, cpu: 10, exp: 0},
+		{workers: 2, buffered: 0, cpu: 10, exp: -1},
+		{workers: 1, buffered: 0, cpu: 10, exp: 0},
+		{workers: 1, buffered: 0, cpu: 90, exp: 0},
+	}
+	for i, tt := range tests {
+		s.workers = make([]*scaleWorker, tt.workers)
+		if r := s.decide(tt.buffered, 100, tt.cpu); r != tt.exp {
+			t.Errorf("%d: expect %d but got %d", i, tt.exp, r)
+		}
+	}
+}
+
+func TestCpuSampler(t *testing.T) {
+	c := newCpuSampler()
+	for i := 0; i < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if u := c.usage(); u < 0 || u > 100 {
+			t.Errorf("invalid cpu usage %f", u)
+		}
+	}
+}
+
+type slowOp struct{}
+
+func (slowOp) Apply(_ api.StreamContext, data interface{}, _ *xsql.FunctionValuer, _ *xsql.AggregateFunctionValuer) interface{} {
+	time.Sleep(5 * time.Millisecond)
+	return data
+}
+
+func TestAutoScaleKeyed(t *testing.T) {
+	mc := conf.Clock.(*clock.Mock)
+	// the mock clock is shared by the package, set it back for the following tests
+	defer mc.Set(mc.Now())
+	contextLogger := conf.Log.WithField("rule", "TestAutoScaleKeyed")
+	ctx, cancel := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithCancel()
+	defer cancel()
+	o := New("test", &api.RuleOption{BufferLength: 100})
+	o.SetOperation(slowOp{})
+	o.SetAutoScale(&api.AutoScale{MaxConcurrency: 3, Interval: 100, MaxCpu: 100}, ast.Dimensions{
+		{Expr: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "id"}},
+	})
+	output := make(chan interface{}, 100)
+	_ = o.AddOutput(output, "output")
+	errCh := make(chan error)
+	o.Exec(ctx, errCh)
+	for i := 0; i < 100; i++ {
+		o.input <- &xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"id": i % 5, "seq": i}}
+	}
+	// wait for the dispatcher to start
+	for len(o.input) == cap(o.input) {
+		time.Sleep(time.Millisecond)
+	}
+	mc.Add(100 * time.Millisecond)
+	scaled := false
+	for i := 0; i < 100 && !scaled; i++ {
+		time.Sleep(5 * time.Millisecond)
+		o.mutex.RLock()
+		scaled = len(o.statManagers) > 1
+		o.mutex.RUnlock()
+	}
+	if !scaled {
+		t.Errorf("expect to scale up when the buffer is busy")
+	}
+	last := make(map[interface{}]int)
+	for i := 0; i < 100; i++ {
+		select {
+		case err := <-errCh:
+			t.Fatal(err)
+		case d := <-output:
+			tuple := d.(*xsql.Tuple)
+			id, seq := tuple.Message["id"], tuple.Message["seq"].(int)
+			if l, ok := last[id]; ok && l > seq {
+				t.Errorf("data of key %v out of order: %d after %d", id, seq, l)
+			}
+			last[id] = seq
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for data %d", i)
+		}
+	}
+}
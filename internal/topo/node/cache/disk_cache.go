@@ -0,0 +1,184 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+// checkInterval is the interval in millisecond to drop the expired data, update the metrics and retry the failed send
+const checkInterval = 1000
+
+// DiskCache is the sink cache in the store-and-forward mode. All the data are saved to the disk queue before sending,
+// and only removed after a successful ack. The data are sent one by one in the arriving order, so the order is kept
+// even across the disconnections and the rule restarts.
+type DiskCache struct {
+	in        <-chan []map[string]interface{}
+	Out       chan []map[string]interface{}
+	Ack       chan bool
+	errorCh   chan<- error
+	stats     metric.StatManager
+	cacheConf *conf.SinkConf
+	queue     *diskQueue
+}
+
+func NewDiskCache(ctx api.StreamContext, in <-chan []map[string]interface{}, errCh chan<- error, stats metric.StatManager, cacheConf *conf.SinkConf, bufferLength int) *DiskCache {
+	c := &DiskCache{
+		in:        in,
+		Out:       make(chan []map[string]interface{}, bufferLength),
+		Ack:       make(chan bool, 10),
+		errorCh:   errCh,
+		stats:     stats,
+		cacheConf: cacheConf,
+	}
+	go func() {
+		err := infra.SafeRun(func() error {
+			return c.run(ctx)
+		})
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+		}
+	}()
+	return c
+}
+
+func (c *DiskCache) run(ctx api.StreamContext) error {
+	dir, err := queueDir(ctx)
+	if err != nil {
+		return err
+	}
+	if c.cacheConf.CleanCacheAtStop {
+		ctx.GetLogger().Infof("cleaning sink queue %s", dir)
+		_ = os.RemoveAll(dir)
+	}
+	c.queue, err = openDiskQueue(dir, c.cacheConf.BufferPageSize, c.cacheConf.MaxDiskCache, int64(c.cacheConf.MaxCacheAge))
+	if err != nil {
+		return err
+	}
+	defer c.onClose(ctx)
+	ctx.GetLogger().Infof("restored %d data from sink queue %s", c.queue.len(), dir)
+	ticker := conf.GetTicker(checkInterval)
+	defer ticker.Stop()
+	var (
+		// out is set to c.Out only when the first entry is ready to send
+		out      chan []map[string]interface{}
+		inflight *queueEntry
+		// sending means the entry is sent out and waiting for the ack
+		sending bool
+		// failed means the last send fails, retry after new data arrives or the next check
+		failed bool
+	)
+	for {
+		if out == nil && !sending && !failed {
+			e, err := c.queue.peek()
+			if err != nil {
+				ctx.GetLogger().Errorf("drop the corrupted data in sink queue: %v", err)
+				if err := c.queue.pop(); err != nil {
+					return err
+				}
+				continue
+			}
+			if e != nil {
+				inflight = e
+				out = c.Out
+			}
+		}
+		c.setMetrics()
+		var data []map[string]interface{}
+		if inflight != nil {
+			data = inflight.Data
+		}
+		select {
+		case item := <-c.in:
+			dropped, err := c.queue.push(conf.GetNowInMilli(), item)
+			if err != nil {
+				ctx.GetLogger().Errorf("fail to save data to sink queue: %v", err)
+			} else if dropped {
+				ctx.GetLogger().Warnf("sink queue is full, drop the oldest data")
+			}
+			failed = false
+			if !sending { // the first entry may be dropped, peek again
+				out = nil
+			}
+		case out <- data:
+			out = nil
+			sending = true
+		case isSuccess := <-c.Ack:
+			sending = false
+			if !isSuccess {
+				failed = true
+				break
+			}
+			// the entry may be dropped during sending
+			if e, _ := c.queue.peek(); e == inflight {
+				if err := c.queue.pop(); err != nil {
+					return err
+				}
+			}
+			inflight = nil
+			if c.queue.len() > 0 && c.cacheConf.ResendInterval > 0 {
+				time.Sleep(time.Duration(c.cacheConf.ResendInterval) * time.Millisecond)
+			}
+		case <-ticker.C:
+			if !sending {
+				if n, err := c.queue.expire(conf.GetNowInMilli()); err != nil {
+					ctx.GetLogger().Errorf("fail to drop the expired data in sink queue: %v", err)
+				} else if n > 0 {
+					ctx.GetLogger().Warnf("drop %d expired data in sink queue", n)
+					out = nil
+				}
+			}
+			failed = false
+		case <-ctx.Done():
+			ctx.GetLogger().Infof("sink node %s instance cache %d done", ctx.GetOpId(), ctx.GetInstanceId())
+			return nil
+		}
+	}
+}
+
+func (c *DiskCache) setMetrics() {
+	l := int64(c.queue.len())
+	c.stats.SetBufferLength(int64(len(c.in)) + l)
+	c.stats.SetCacheStatus(l, c.queue.oldestAge(conf.GetNowInMilli()))
+}
+
+func (c *DiskCache) onClose(ctx api.StreamContext) {
+	ctx.GetLogger().Infof("sink node %s instance cache %d closing", ctx.GetOpId(), ctx.GetInstanceId())
+	var err error
+	if c.cacheConf.CleanCacheAtStop {
+		err = c.queue.drop()
+	} else {
+		err = c.queue.close()
+	}
+	if err != nil {
+		ctx.GetLogger().Warnf("fail to close sink queue: %v", err)
+	}
+}
+
+func queueDir(ctx api.StreamContext) (string, error) {
+	dataDir, err := conf.GetDataLoc()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "sinkqueue", ctx.GetRuleId(), ctx.GetOpId()+"_"+strconv.Itoa(ctx.GetInstanceId())), nil
+}
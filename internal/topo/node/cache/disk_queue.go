@@ -0,0 +1,393 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register([]map[string]interface{}{})
+	gob.Register(time.Time{})
+}
+
+const (
+	segmentExt = ".seg"
+	headFile   = "head"
+	// the record header is the length of the encoded data and the timestamp
+	recordHeaderLen = 12
+)
+
+// diskQueue is a bounded FIFO queue persisted in append-only segment files. Each segment holds at most segmentSize
+// entries, and each entry is a record of the timestamp when it is queued and the gob encoded data. The read position
+// is saved in the head file after each pop so that the queue resumes after restart. The segments are removed once all
+// their entries are consumed, which compacts the queue. Not thread safe!
+type diskQueue struct {
+	dir         string
+	segmentSize int
+	// maxLength is the max entries of the queue, the oldest entries are dropped when exceeded. 0 means no limit
+	maxLength int
+	// maxAge is the max milliseconds an entry can stay in the queue. 0 means no limit
+	maxAge   int64
+	segments []*segment
+	length   int
+	// head caches the decoded first entry to avoid reading it repeatedly
+	head *queueEntry
+	// tail is the write handle of the last segment
+	tail *os.File
+}
+
+type segment struct {
+	id int64
+	// count is the written entries and read is the consumed entries
+	count int
+	read  int
+	// offset is the position of the next entry to read and size is the end of the file
+	offset int64
+	size   int64
+}
+
+type queueEntry struct {
+	Timestamp int64
+	Data      []map[string]interface{}
+	size      int64
+}
+
+func openDiskQueue(dir string, segmentSize, maxLength int, maxAge int64) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create sink queue dir %s error: %v", dir, err)
+	}
+	q := &diskQueue{
+		dir:         dir,
+		segmentSize: segmentSize,
+		maxLength:   maxLength,
+		maxAge:      maxAge,
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// load scans the segments to restore the queue. The partial record at the end of a segment, which is written when
+// crashing, is truncated.
+func (q *diskQueue) load() error {
+	files, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("read sink queue dir %s error: %v", q.dir, err)
+	}
+	var ids []int64
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(f.Name(), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	headId, read, offset, err := q.readPosition()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if id < headId { // consumed but not removed before exit
+			_ = os.Remove(q.segmentPath(id))
+			continue
+		}
+		s, err := q.scanSegment(id)
+		if err != nil {
+			return err
+		}
+		if id == headId && read <= s.count && offset <= s.size {
+			s.read = read
+			s.offset = offset
+		}
+		q.segments = append(q.segments, s)
+		q.length += s.count - s.read
+	}
+	q.compact()
+	if l := len(q.segments); l > 0 && q.segments[l-1].count < q.segmentSize {
+		f, err := os.OpenFile(q.segmentPath(q.segments[l-1].id), os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("open sink queue segment error: %v", err)
+		}
+		q.tail = f
+	}
+	return nil
+}
+
+func (q *diskQueue) scanSegment(id int64) (*segment, error) {
+	p := q.segmentPath(id)
+	f, err := os.OpenFile(p, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open sink queue segment %s error: %v", p, err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat sink queue segment %s error: %v", p, err)
+	}
+	s := &segment{id: id}
+	h := make([]byte, recordHeaderLen)
+	for s.size < fi.Size() {
+		if _, err := f.ReadAt(h, s.size); err != nil {
+			break
+		}
+		next := s.size + recordHeaderLen + int64(binary.BigEndian.Uint32(h))
+		if next > fi.Size() {
+			break
+		}
+		s.size = next
+		s.count++
+	}
+	if s.size < fi.Size() {
+		if err := f.Truncate(s.size); err != nil {
+			return nil, fmt.Errorf("truncate sink queue segment %s error: %v", p, err)
+		}
+	}
+	return s, nil
+}
+
+// push appends the data to the end of the queue. It returns true if the oldest entry is dropped because the queue is full.
+func (q *diskQueue) push(timestamp int64, data []map[string]interface{}) (bool, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, recordHeaderLen))
+	if err := gob.NewEncoder(&buf).Encode(data); err != nil {
+		return false, fmt.Errorf("encode sink queue data error: %v", err)
+	}
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b, uint32(len(b)-recordHeaderLen))
+	binary.BigEndian.PutUint64(b[4:], uint64(timestamp))
+	dropped := false
+	if q.maxLength > 0 && q.length >= q.maxLength {
+		if err := q.pop(); err != nil {
+			return false, err
+		}
+		dropped = true
+	}
+	if q.tail == nil {
+		if err := q.newSegment(); err != nil {
+			return dropped, err
+		}
+	}
+	s := q.segments[len(q.segments)-1]
+	if _, err := q.tail.Write(b); err != nil {
+		// drop the partial record
+		_ = q.tail.Truncate(s.size)
+		return dropped, fmt.Errorf("write sink queue segment error: %v", err)
+	}
+	s.size += int64(len(b))
+	s.count++
+	q.length++
+	if s.count >= q.segmentSize {
+		_ = q.tail.Close()
+		q.tail = nil
+	}
+	return dropped, nil
+}
+
+func (q *diskQueue) newSegment() error {
+	var id int64
+	if l := len(q.segments); l > 0 {
+		id = q.segments[l-1].id + 1
+	}
+	f, err := os.OpenFile(q.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("create sink queue segment error: %v", err)
+	}
+	q.tail = f
+	q.segments = append(q.segments, &segment{id: id})
+	return nil
+}
+
+// peek returns the first entry without removing it. It returns nil if the queue is empty.
+func (q *diskQueue) peek() (*queueEntry, error) {
+	if q.length == 0 {
+		return nil, nil
+	}
+	if q.head != nil {
+		return q.head, nil
+	}
+	e, err := q.readHead()
+	if err != nil {
+		return nil, err
+	}
+	q.head = e
+	return e, nil
+}
+
+// readHead reads the first entry. The entry is returned with its size even if the data cannot be decoded, so that the
+// corrupted entry can be popped.
+func (q *diskQueue) readHead() (*queueEntry, error) {
+	s := q.segments[0]
+	p := q.segmentPath(s.id)
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("open sink queue segment %s error: %v", p, err)
+	}
+	defer f.Close()
+	h := make([]byte, recordHeaderLen)
+	if _, err := f.ReadAt(h, s.offset); err != nil {
+		return nil, fmt.Errorf("read sink queue segment %s error: %v", p, err)
+	}
+	l := int64(binary.BigEndian.Uint32(h))
+	e := &queueEntry{
+		Timestamp: int64(binary.BigEndian.Uint64(h[4:])),
+		size:      recordHeaderLen + l,
+	}
+	if err := gob.NewDecoder(io.NewSectionReader(f, s.offset+recordHeaderLen, l)).Decode(&e.Data); err != nil {
+		return e, fmt.Errorf("decode sink queue data error: %v", err)
+	}
+	return e, nil
+}
+
+// pop removes the first entry and saves the read position
+func (q *diskQueue) pop() error {
+	if q.length == 0 {
+		return nil
+	}
+	e := q.head
+	if e == nil {
+		var err error
+		if e, err = q.readHead(); e == nil {
+			return err
+		}
+	}
+	s := q.segments[0]
+	s.read++
+	s.offset += e.size
+	q.head = nil
+	q.length--
+	q.compact()
+	return q.savePosition()
+}
+
+// expire drops the entries which exceed the max age and returns the dropped count
+func (q *diskQueue) expire(now int64) (int, error) {
+	if q.maxAge <= 0 {
+		return 0, nil
+	}
+	n := 0
+	for q.length > 0 {
+		e, err := q.peek()
+		if err == nil && now-e.Timestamp <= q.maxAge {
+			break
+		}
+		if err := q.pop(); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// oldestAge returns the milliseconds since the first entry is queued
+func (q *diskQueue) oldestAge(now int64) int64 {
+	e, err := q.peek()
+	if err != nil || e == nil {
+		return 0
+	}
+	return now - e.Timestamp
+}
+
+func (q *diskQueue) len() int {
+	return q.length
+}
+
+// compact removes the segments whose entries are all consumed. The last segment is kept if it is still writable.
+func (q *diskQueue) compact() {
+	for len(q.segments) > 0 {
+		s := q.segments[0]
+		if s.read < s.count || (len(q.segments) == 1 && s.count < q.segmentSize) {
+			return
+		}
+		_ = os.Remove(q.segmentPath(s.id))
+		q.segments = q.segments[1:]
+	}
+}
+
+func (q *diskQueue) readPosition() (int64, int, int64, error) {
+	b, err := os.ReadFile(filepath.Join(q.dir, headFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("read sink queue head error: %v", err)
+	}
+	if len(b) != 24 {
+		return 0, 0, 0, nil
+	}
+	return int64(binary.BigEndian.Uint64(b)), int(binary.BigEndian.Uint64(b[8:])), int64(binary.BigEndian.Uint64(b[16:])), nil
+}
+
+// savePosition writes the read position of the first segment. It is written to a temp file then renamed to avoid
+// partial write.
+func (q *diskQueue) savePosition() error {
+	p := filepath.Join(q.dir, headFile)
+	if len(q.segments) == 0 {
+		// no segment, the next segment starts from 0
+		return os.RemoveAll(p)
+	}
+	s := q.segments[0]
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint64(b, uint64(s.id))
+	binary.BigEndian.PutUint64(b[8:], uint64(s.read))
+	binary.BigEndian.PutUint64(b[16:], uint64(s.offset))
+	if err := os.WriteFile(p+".tmp", b, 0o644); err != nil {
+		return fmt.Errorf("save sink queue head error: %v", err)
+	}
+	if err := os.Rename(p+".tmp", p); err != nil {
+		return fmt.Errorf("save sink queue head error: %v", err)
+	}
+	return nil
+}
+
+func (q *diskQueue) segmentPath(id int64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%016d%s", id, segmentExt))
+}
+
+func (q *diskQueue) close() error {
+	if q.tail != nil {
+		err := q.tail.Close()
+		q.tail = nil
+		return err
+	}
+	return nil
+}
+
+// drop removes all the entries and the files of the queue
+func (q *diskQueue) drop() error {
+	_ = q.close()
+	q.segments = nil
+	q.head = nil
+	q.length = 0
+	return os.RemoveAll(q.dir)
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiskQueue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openDiskQueue(dir, 2, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := q.push(int64(i), []map[string]interface{}{{"a": int64(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if q.len() != 5 || len(q.segments) != 3 {
+		t.Fatalf("expect 5 entries in 3 segments but got %d in %d", q.len(), len(q.segments))
+	}
+	for i := 0; i < 3; i++ {
+		e, err := q.peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e.Data, []map[string]interface{}{{"a": int64(i)}}) || e.Timestamp != int64(i) {
+			t.Errorf("%d entry mismatch, got %v", i, e)
+		}
+		if err := q.pop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the consumed segment is compacted
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(files) != 2 {
+		t.Errorf("expect 2 segments left but got %v", files)
+	}
+	if err := q.close(); err != nil {
+		t.Fatal(err)
+	}
+	// write a partial record as if crashing
+	f, err := os.OpenFile(q.segmentPath(2), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte{0, 0, 1})
+	_ = f.Close()
+
+	q, err = openDiskQueue(dir, 2, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.len() != 2 {
+		t.Fatalf("expect 2 entries restored but got %d", q.len())
+	}
+	if _, err := q.push(5, []map[string]interface{}{{"a": int64(5)}}); err != nil {
+		t.Fatal(err)
+	}
+	var result []interface{}
+	for q.len() > 0 {
+		e, err := q.peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, e.Data[0]["a"])
+		if err := q.pop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(result, []interface{}{int64(3), int64(4), int64(5)}) {
+		t.Errorf("restored entries mismatch, got %v", result)
+	}
+	if e, err := q.peek(); e != nil || err != nil {
+		t.Errorf("expect empty queue but got %v, %v", e, err)
+	}
+	if err := q.drop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expect the queue dir removed")
+	}
+}
+
+func TestDiskQueueLimit(t *testing.T) {
+	q, err := openDiskQueue(t.TempDir(), 2, 3, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.drop()
+	for i := 0; i < 5; i++ {
+		dropped, err := q.push(int64(i*50), []map[string]interface{}{{"a": int64(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dropped != (i >= 3) {
+			t.Errorf("%d dropped mismatch, got %v", i, dropped)
+		}
+	}
+	if q.len() != 3 {
+		t.Fatalf("expect 3 entries but got %d", q.len())
+	}
+	if age := q.oldestAge(250); age != 150 {
+		t.Errorf("expect oldest age 150 but got %d", age)
+	}
+	n, err := q.expire(250)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || q.len() != 2 {
+		t.Errorf("expect 1 expired and 2 left but got %d and %d", n, q.len())
+	}
+	e, _ := q.peek()
+	if !reflect.DeepEqual(e.Data, []map[string]interface{}{{"a": int64(3)}}) {
+		t.Errorf("first entry mismatch, got %v", e.Data)
+	}
+}
@@ -195,6 +195,7 @@ func (c *SyncCache) run(ctx api.StreamContext) {
 				ctx.GetLogger().Errorf("unknown cache control command %v", data)
 			}
 			c.stats.SetBufferLength(int64(len(c.in) + c.cacheLength))
+			c.stats.SetCacheStatus(int64(c.cacheLength), 0)
 			if c.sendStatus == 0 {
 				c.send(ctx)
 			}
@@ -253,6 +253,65 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestDiskCacheRun(t *testing.T) {
+	testx.InitEnv()
+	sconf := &conf.SinkConf{
+		MaxDiskCache:    3,
+		BufferPageSize:  2,
+		EnableCache:     true,
+		StoreAndForward: true,
+	}
+	tempStore, _ := state.CreateStore("mock", api.AtMostOnce)
+	contextLogger := conf.Log.WithField("rule", "TestDiskCacheRun")
+	ctx, cancel := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("diskCacheRule", "op1", tempStore).WithCancel()
+	dir, err := queueDir(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+	stats, err := metric.NewStatManager(ctx, "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make(chan []map[string]interface{})
+	errCh := make(chan error, 1)
+	c := NewDiskCache(ctx, in, errCh, stats, sconf, 10)
+	for i := 1; i <= 4; i++ {
+		in <- []map[string]interface{}{{"a": i}}
+	}
+	// the first data is sent before the queue is full
+	r := <-c.Out
+	if !reflect.DeepEqual(r, []map[string]interface{}{{"a": 1}}) {
+		t.Errorf("first data mismatch, got %v", r)
+	}
+	// failed to send, the data are kept
+	c.Ack <- false
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel = context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("diskCacheRule", "op1", tempStore).WithCancel()
+	defer cancel()
+	c = NewDiskCache(ctx, in, errCh, stats, sconf, 10)
+	in <- []map[string]interface{}{{"a": 5}}
+	var result []interface{}
+	for i := 0; i < 3; i++ {
+		select {
+		case r := <-c.Out:
+			result = append(result, r[0]["a"])
+			c.Ack <- true
+		case err := <-errCh:
+			t.Fatal(err)
+		case <-time.After(time.Second):
+			t.Fatalf("no data after %v", result)
+		}
+	}
+	// the oldest data 1 and 2 are dropped because the queue is full
+	if !reflect.DeepEqual(result, []interface{}{3, 4, 5}) {
+		t.Errorf("data mismatch, got %v", result)
+	}
+}
+
 func deleteCachedb() {
 	loc, err := conf.GetDataLoc()
 	if err != nil {
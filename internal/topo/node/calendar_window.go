@@ -0,0 +1,68 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+const dayMillis = int64(24 * time.Hour / time.Millisecond)
+
+// getCalendarWindowEndTime returns the first window end after n for the tumbling window aligned to the calendar of
+// the window location. The length of the calendar window varies by the daylight saving time and the month, so the
+// window ends are calculated by the local date instead of adding the fixed length.
+//   - Monthly windows end at the local midnight of the first day of the month
+//   - Daily windows end at the local midnight
+//   - Shorter windows are aligned to the local midnight, and the last window of the day ends at the next midnight
+func getCalendarWindowEndTime(n int64, w *WindowConfig) time.Time {
+	t := time.UnixMilli(n).In(w.location)
+	y, m, d := t.Date()
+	if w.Months > 0 {
+		months := (y*12+int(m)-1)/w.Months*w.Months + w.Months
+		return time.Date(months/12, time.Month(months%12+1), 1, 0, 0, 0, 0, w.location)
+	}
+	length := int64(w.Length)
+	if length%dayMillis == 0 {
+		days := int(length / dayMillis)
+		// count the days of the local date from the epoch, so that windows of several days are aligned
+		epochDays := int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+		return time.Date(1970, 1, 1+epochDays/days*days+days, 0, 0, 0, 0, w.location)
+	}
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, w.location).UnixMilli()
+	end := midnight + ((n-midnight)/length+1)*length
+	if nextMidnight := time.Date(y, m, d+1, 0, 0, 0, 0, w.location); end > nextMidnight.UnixMilli() {
+		return nextMidnight
+	}
+	return time.UnixMilli(end)
+}
+
+func getCalendarTimer(ctx api.StreamContext, w *WindowConfig, n int64) (int64, *clock.Timer) {
+	next := getCalendarWindowEndTime(n, w)
+	ctx.GetLogger().Infof("align calendar window timer to %v(%d)", next, next.UnixMilli())
+	return next.UnixMilli(), conf.GetTimerByTime(next)
+}
+
+// nextWindowEnd returns the window end next to the current one for the tumbling window and hopping window
+func nextWindowEnd(w *WindowConfig, current int64, interval int) int64 {
+	if w.location != nil {
+		return getCalendarWindowEndTime(current, w).UnixMilli()
+	}
+	return current + int64(interval)
+}
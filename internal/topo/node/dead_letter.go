@@ -0,0 +1,104 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// The stages where the data fail
+const (
+	DeadLetterDecode  = "decode"
+	DeadLetterRuntime = "runtime"
+	DeadLetterSink    = "sink"
+)
+
+// DeadLetterQueue routes the data failing to decode, process or sink to the dead letter sink of the rule, instead of
+// dropping them or sending the errors to the normal sinks. It is put into the rule context by DeadLetterKey.
+type DeadLetterQueue struct {
+	sink  *SinkNode
+	input chan<- interface{}
+}
+
+func NewDeadLetterQueue(sink *SinkNode) *DeadLetterQueue {
+	input, _ := sink.GetInput()
+	return &DeadLetterQueue{
+		sink:  sink,
+		input: input,
+	}
+}
+
+func (q *DeadLetterQueue) GetSink() *SinkNode {
+	return q.sink
+}
+
+// sendDeadLetter wraps the failed data with the error metadata and sends it to the dead letter queue. It never blocks
+// the caller, the letter is dropped with a warning if the queue is full. It returns false if the rule has no dead
+// letter queue so that the caller handles the error as usual.
+func sendDeadLetter(ctx api.StreamContext, stage string, err error, data interface{}) bool {
+	if ctx == nil {
+		return false
+	}
+	q, ok := ctx.Value(context.DeadLetterKey).(*DeadLetterQueue)
+	if !ok || q == nil {
+		return false
+	}
+	return q.send(ctx, stage, err, data)
+}
+
+// SendDecodeError implements context.DeadLetterSender for the converters which decode part of the payload
+func (q *DeadLetterQueue) SendDecodeError(ctx api.StreamContext, err error, data interface{}) bool {
+	return q.send(ctx, DeadLetterDecode, err, data)
+}
+
+func (q *DeadLetterQueue) send(ctx api.StreamContext, stage string, err error, data interface{}) bool {
+	if ctx.GetOpId() == q.sink.GetName() {
+		return false
+	}
+	now := conf.GetNowInMilli()
+	letter := map[string]interface{}{
+		"ruleId":    ctx.GetRuleId(),
+		"opId":      ctx.GetOpId(),
+		"stage":     stage,
+		"error":     err.Error(),
+		"timestamp": now,
+	}
+	if data != nil {
+		letter["data"] = deadLetterData(data)
+	}
+	select {
+	case q.input <- &xsql.Tuple{Emitter: context.DeadLetterKey, Message: letter, Timestamp: now}:
+	default:
+		ctx.GetLogger().Warnf("dead letter queue is full, drop the letter of %s: %s", stage, err)
+	}
+	return true
+}
+
+// deadLetterData converts the failed data to the format which can be encoded by the sink
+func deadLetterData(data interface{}) interface{} {
+	switch d := data.(type) {
+	case xsql.Collection:
+		return d.ToMaps()
+	case xsql.Row:
+		return d.ToMap()
+	case []byte:
+		return string(d)
+	default:
+		return d
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/converter/delimited"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+func TestSendDeadLetter(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestSendDeadLetter")
+	store, _ := state.CreateStore("TestSendDeadLetter", api.AtMostOnce)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	opCtx := ctx.WithMeta("TestSendDeadLetter", "op1", store)
+	if sendDeadLetter(opCtx, DeadLetterRuntime, errors.New("no dlq"), nil) {
+		t.Errorf("expect false without dead letter queue")
+	}
+
+	sink := NewSinkNode("deadLetter_memory", "memory", map[string]interface{}{"bufferLength": 2})
+	ctx = context.WithValue(ctx, context.DeadLetterKey, NewDeadLetterQueue(sink))
+	opCtx = ctx.WithMeta("TestSendDeadLetter", "op1", store)
+	row := &xsql.Tuple{Emitter: "demo", Message: xsql.Message{"a": 1}}
+	if !sendDeadLetter(opCtx, DeadLetterRuntime, errors.New("divided by zero"), row) {
+		t.Fatal("expect the letter is sent")
+	}
+	if !sendDeadLetter(opCtx, DeadLetterDecode, errors.New("invalid json"), []byte("{a")) {
+		t.Fatal("expect the letter is sent")
+	}
+	// The queue is full, the letter is dropped without blocking
+	if !sendDeadLetter(opCtx, DeadLetterSink, errors.New("dropped"), nil) {
+		t.Fatal("expect the letter is handled")
+	}
+	exps := []map[string]interface{}{
+		{"ruleId": "TestSendDeadLetter", "opId": "op1", "stage": "runtime", "error": "divided by zero", "data": map[string]interface{}{"a": 1}},
+		{"ruleId": "TestSendDeadLetter", "opId": "op1", "stage": "decode", "error": "invalid json", "data": "{a"},
+	}
+	for i, exp := range exps {
+		v := <-sink.input
+		letter := v.(*xsql.Tuple).ToMap()
+		if _, ok := letter["timestamp"]; !ok {
+			t.Errorf("%d: letter has no timestamp", i)
+		}
+		delete(letter, "timestamp")
+		if !reflect.DeepEqual(letter, exp) {
+			t.Errorf("%d: letter mismatch\nexp\t%v\ngot\t%v", i, exp, letter)
+		}
+	}
+	// The dead letter sink itself does not send to the queue
+	if sendDeadLetter(ctx.WithMeta("TestSendDeadLetter", "deadLetter_memory", store), DeadLetterSink, errors.New("loop"), nil) {
+		t.Errorf("expect false for the dead letter sink")
+	}
+}
+
+func TestDecodeMalformedDeadLetter(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestDecodeMalformedDeadLetter")
+	store, _ := state.CreateStore("TestDecodeMalformedDeadLetter", api.AtMostOnce)
+	c, _ := delimited.NewConverter(",")
+	_ = c.(message.PropsConfigurer).Configure(map[string]interface{}{"hasHeader": true, "malformedRow": "dlq"})
+	sink := NewSinkNode("deadLetter_memory", "memory", map[string]interface{}{"bufferLength": 2})
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	ctx = context.WithValue(ctx, context.DeadLetterKey, NewDeadLetterQueue(sink))
+	ctx = context.WithValue(ctx, context.DecodeKey, c)
+	opCtx := ctx.WithMeta("TestDecodeMalformedDeadLetter", "source", store)
+	r, err := opCtx.DecodeIntoList([]byte("a,b\n1,2\n3\n4,5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []map[string]interface{}{{"a": "1", "b": "2"}, {"a": "4", "b": "5"}}
+	if !reflect.DeepEqual(r, exp) {
+		t.Errorf("result mismatch\nexp\t%v\ngot\t%v", exp, r)
+	}
+	letter := (<-sink.input).(*xsql.Tuple).ToMap()
+	delete(letter, "timestamp")
+	expLetter := map[string]interface{}{"ruleId": "TestDecodeMalformedDeadLetter", "opId": "source", "stage": "decode", "error": "expect 2 fields but got 1", "data": "3"}
+	if !reflect.DeepEqual(letter, expLetter) {
+		t.Errorf("letter mismatch\nexp\t%v\ngot\t%v", expLetter, letter)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"encoding/gob"
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+// DedupState is the expiry time of each seen key. NextPurge is the time to remove the expired keys
+type DedupState struct {
+	NextPurge int64
+	Keys      map[string]int64
+}
+
+const DedupStateKey = "$$dedup"
+
+func init() {
+	gob.Register(&DedupState{})
+}
+
+// DedupNode drops the rows whose key is seen within the TTL. The TTL starts from the first row of the key and is
+// not extended by the duplicates. The time of a row is its event time in event time mode, otherwise the processing time
+type DedupNode struct {
+	*defaultSinkNode
+	dedup       *ast.Deduplicate
+	statManager metric.StatManager
+	state       *DedupState
+}
+
+func NewDedupNode(name string, dedup *ast.Deduplicate, options *api.RuleOption) (*DedupNode, error) {
+	n := &DedupNode{
+		dedup: dedup,
+	}
+	n.defaultSinkNode = &defaultSinkNode{
+		input: make(chan interface{}, options.BufferLength),
+		defaultNode: &defaultNode{
+			outputs:      make(map[string]chan<- interface{}),
+			name:         name,
+			sendError:    options.SendError,
+			backPressure: options.BackPressure,
+		},
+	}
+	return n, nil
+}
+
+func (n *DedupNode) Exec(ctx api.StreamContext, errCh chan<- error) {
+	n.ctx = ctx
+	log := ctx.GetLogger()
+	log.Debugf("DedupNode %s is started", n.name)
+
+	if len(n.outputs) <= 0 {
+		infra.DrainError(ctx, fmt.Errorf("no output channel found"), errCh)
+		return
+	}
+	stats, err := metric.NewStatManager(ctx, "op")
+	if err != nil {
+		infra.DrainError(ctx, fmt.Errorf("fail to create stat manager"), errCh)
+		return
+	}
+	n.statManager = stats
+	n.statManagers = []metric.StatManager{stats}
+	fv, _ := xsql.NewFunctionValuersForOp(ctx)
+	go func() {
+		err := infra.SafeRun(func() error {
+			n.state = &DedupState{Keys: make(map[string]int64)}
+			if s, err := ctx.GetState(DedupStateKey); err == nil && s != nil {
+				if st, ok := s.(*DedupState); ok {
+					if st.Keys == nil {
+						st.Keys = make(map[string]int64)
+					}
+					n.state = st
+				} else {
+					return fmt.Errorf("restore dedup state %v error, invalid type", s)
+				}
+			}
+			for {
+				log.Debugf("DedupNode %s is looping", n.name)
+				select {
+				case item, opened := <-n.input:
+					processed := false
+					if item, processed = n.preprocess(item); processed {
+						break
+					}
+					n.statManager.IncTotalRecordsIn()
+					n.statManager.ProcessTimeStart()
+					if !opened {
+						n.statManager.IncTotalExceptions("input channel closed")
+						break
+					}
+					switch d := item.(type) {
+					case error:
+						n.Broadcast(d)
+						n.statManager.IncTotalExceptions(d.Error())
+					case *xsql.Tuple:
+						log.Debugf("DedupNode receive tuple input %s", d)
+						if dup, err := n.isDuplicate(d, fv); err != nil {
+							n.Broadcast(err)
+							n.statManager.IncTotalExceptions(err.Error())
+						} else if dup {
+							log.Debugf("DedupNode drop duplicate tuple %s", d)
+						} else {
+							n.Broadcast(d)
+							n.statManager.IncTotalRecordsOut()
+						}
+						_ = ctx.PutState(DedupStateKey, n.state)
+					default:
+						e := fmt.Errorf("run DedupNode error: invalid input type but got %[1]T(%[1]v)", d)
+						n.Broadcast(e)
+						n.statManager.IncTotalExceptions(e.Error())
+					}
+					n.statManager.ProcessTimeEnd()
+					n.statManager.SetBufferLength(int64(len(n.input)))
+				case <-ctx.Done():
+					log.Infoln("Cancelling dedup node....")
+					return nil
+				}
+			}
+		})
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+		}
+	}()
+}
+
+// isDuplicate checks if the key of the row is seen and not expired. The key is recorded if not
+func (n *DedupNode) isDuplicate(row *xsql.Tuple, fv *xsql.FunctionValuer) (bool, error) {
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(row, fv)}
+	var key string
+	for _, k := range n.dedup.Keys {
+		r := ve.Eval(k)
+		if e, ok := r.(error); ok {
+			return false, fmt.Errorf("evaluate deduplicate key %s error: %v", k, e)
+		}
+		key += fmt.Sprintf("%v,", r)
+	}
+	ts := row.Timestamp
+	n.purge(ts)
+	if expire, ok := n.state.Keys[key]; ok && expire > ts {
+		return true, nil
+	}
+	n.state.Keys[key] = ts + n.dedup.TTL
+	return false, nil
+}
+
+// purge removes the expired keys at most once per TTL to bound the state size
+func (n *DedupNode) purge(ts int64) {
+	if ts < n.state.NextPurge {
+		return
+	}
+	for k, expire := range n.state.Keys {
+		if expire <= ts {
+			delete(n.state.Keys, k)
+		}
+	}
+	n.state.NextPurge = ts + n.dedup.TTL
+}
+
+func (n *DedupNode) GetMetrics() [][]interface{} {
+	if n.statManager != nil {
+		return [][]interface{}{
+			n.statManager.GetMetrics(),
+		}
+	} else {
+		return nil
+	}
+}
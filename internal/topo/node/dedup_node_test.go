@@ -0,0 +1,63 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestDedupIsDuplicate(t *testing.T) {
+	n, err := NewDedupNode("dedup", &ast.Deduplicate{
+		Keys: []ast.Expr{&ast.FieldRef{StreamName: ast.DefaultStream, Name: "id"}},
+		TTL:  1000,
+	}, &api.RuleOption{BufferLength: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.state = &DedupState{Keys: make(map[string]int64)}
+	fv, _ := xsql.NewFunctionValuersForOp(nil)
+	tests := []struct {
+		id     int
+		ts     int64
+		result bool
+		size   int
+	}{
+		{id: 1, ts: 100, result: false, size: 1},
+		{id: 1, ts: 500, result: true, size: 1},
+		{id: 2, ts: 600, result: false, size: 2},
+		// the ttl is not extended by the duplicate
+		{id: 1, ts: 1100, result: false, size: 2},
+		{id: 2, ts: 1599, result: true, size: 2},
+		// the expired keys are purged
+		{id: 3, ts: 2100, result: false, size: 1},
+	}
+	for i, tt := range tests {
+		r, err := n.isDuplicate(&xsql.Tuple{Emitter: "demo", Message: xsql.Message{"id": tt.id}, Timestamp: tt.ts}, fv)
+		if err != nil {
+			t.Errorf("%d: unexpected error %v", i, err)
+			continue
+		}
+		if r != tt.result {
+			t.Errorf("%d: expect duplicate %v but got %v", i, tt.result, r)
+		}
+		if len(n.state.Keys) != tt.size {
+			t.Errorf("%d: expect %d keys but got %d", i, tt.size, len(n.state.Keys))
+		}
+	}
+}
@@ -26,16 +26,22 @@ type DynamicChannelBuffer struct {
 	Out    chan api.SourceTuple
 	buffer []api.SourceTuple
 	done   chan bool
+	// policy is the action when the buffer reaches the limit. Default to pause reading the input.
+	policy atomic.Value
+	// Overflow is notified when the buffer reaches the limit with the stopRule policy
+	Overflow chan struct{}
 }
 
 func NewDynamicChannelBuffer() *DynamicChannelBuffer {
 	buffer := &DynamicChannelBuffer{
-		In:     make(chan api.SourceTuple, 1024),
-		Out:    make(chan api.SourceTuple),
-		buffer: make([]api.SourceTuple, 0),
-		limit:  102400,
-		done:   make(chan bool, 1),
+		In:       make(chan api.SourceTuple, 1024),
+		Out:      make(chan api.SourceTuple),
+		buffer:   make([]api.SourceTuple, 0),
+		limit:    102400,
+		done:     make(chan bool, 1),
+		Overflow: make(chan struct{}, 1),
 	}
+	buffer.policy.Store(api.QuotaPolicyPauseSource)
 	go buffer.run()
 	return buffer
 }
@@ -46,10 +52,33 @@ func (b *DynamicChannelBuffer) SetLimit(limit int) {
 	}
 }
 
+func (b *DynamicChannelBuffer) SetPolicy(policy string) {
+	if policy != "" {
+		b.policy.Store(policy)
+	}
+}
+
 func (b *DynamicChannelBuffer) run() {
 	for {
 		l := len(b.buffer)
 		if int64(l) >= atomic.LoadInt64(&b.limit) {
+			switch b.policy.Load() {
+			case api.QuotaPolicyDropOldest:
+				select {
+				case b.Out <- b.buffer[0]:
+					b.buffer = b.buffer[1:]
+				case value := <-b.In:
+					b.buffer = append(b.buffer[1:], value)
+				case <-b.done:
+					return
+				}
+				continue
+			case api.QuotaPolicyStopRule:
+				select {
+				case b.Overflow <- struct{}{}:
+				default:
+				}
+			}
 			select {
 			case b.Out <- b.buffer[0]:
 				b.buffer = b.buffer[1:]
@@ -52,3 +52,38 @@ func TestBuffer(t *testing.T) {
 		t.Errorf("Expect buffer length 50, but got %d", l)
 	}
 }
+
+func TestBufferPolicy(t *testing.T) {
+	mc := conf.Clock.(*clock.Mock)
+	t.Run("dropOldest", func(t *testing.T) {
+		b := NewDynamicChannelBuffer()
+		defer b.Close()
+		b.SetLimit(10)
+		b.SetPolicy(api.QuotaPolicyDropOldest)
+		for i := 0; i < 20; i++ {
+			b.In <- api.NewDefaultSourceTupleWithTime(map[string]interface{}{"a": i}, nil, mc.Now())
+		}
+		// wait for the buffer to read all the inputs
+		time.Sleep(10 * time.Millisecond)
+		for i := 10; i < 20; i++ {
+			v := <-b.Out
+			if v.Message()["a"] != i {
+				t.Errorf("expect %d but got %v", i, v.Message()["a"])
+			}
+		}
+	})
+	t.Run("stopRule", func(t *testing.T) {
+		b := NewDynamicChannelBuffer()
+		defer b.Close()
+		b.SetLimit(10)
+		b.SetPolicy(api.QuotaPolicyStopRule)
+		for i := 0; i < 20; i++ {
+			b.In <- api.NewDefaultSourceTupleWithTime(map[string]interface{}{"a": i}, nil, mc.Now())
+		}
+		select {
+		case <-b.Overflow:
+		case <-time.After(time.Second):
+			t.Error("expect overflow notification")
+		}
+	})
+}
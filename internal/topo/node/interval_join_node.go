@@ -0,0 +1,278 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+// IntervalJoinConf is the time bound of the interval join like right.ts BETWEEN left.ts + Lower AND left.ts + Upper.
+// Left and Right are the names of the streams
+type IntervalJoinConf struct {
+	Left  string
+	Right string
+	Lower int64
+	Upper int64
+}
+
+// IntervalJoinNode joins two streams by time proximity without a window. Each tuple is joined with the buffered
+// tuples of the other stream by the join operation, and the buffered tuples are removed once the watermark passes
+// the time they can still be matched. The time of the tuples is the event time or the processing time
+type IntervalJoinNode struct {
+	*defaultSinkNode
+	conf        *IntervalJoinConf
+	op          UnOperation
+	isEventTime bool
+	lateTol     int64
+	statManager metric.StatManager
+	// states
+	inputs map[string][]*xsql.Tuple
+	// the max event time of each stream to calculate the watermark
+	maxTs map[string]int64
+}
+
+const (
+	IntervalJoinInputsKey = "$$intervalJoinInputs"
+	IntervalJoinMaxTsKey  = "$$intervalJoinMaxTs"
+)
+
+func init() {
+	gob.Register(map[string][]*xsql.Tuple{})
+	gob.Register(map[string]int64{})
+}
+
+func NewIntervalJoinNode(name string, c *IntervalJoinConf, op UnOperation, options *api.RuleOption) (*IntervalJoinNode, error) {
+	if c.Lower > c.Upper {
+		return nil, fmt.Errorf("invalid interval join bound, the lower bound %d is greater than the upper bound %d", c.Lower, c.Upper)
+	}
+	n := &IntervalJoinNode{
+		conf:        c,
+		op:          op,
+		isEventTime: options.IsEventTime,
+		lateTol:     options.LateTol,
+	}
+	n.defaultSinkNode = &defaultSinkNode{
+		input: make(chan interface{}, options.BufferLength),
+		defaultNode: &defaultNode{
+			outputs:      make(map[string]chan<- interface{}),
+			name:         name,
+			sendError:    options.SendError,
+			backPressure: options.BackPressure,
+		},
+	}
+	return n, nil
+}
+
+func (n *IntervalJoinNode) Exec(ctx api.StreamContext, errCh chan<- error) {
+	n.ctx = ctx
+	log := ctx.GetLogger()
+	log.Debugf("IntervalJoinNode %s is started", n.name)
+
+	if len(n.outputs) <= 0 {
+		infra.DrainError(ctx, fmt.Errorf("no output channel found"), errCh)
+		return
+	}
+	stats, err := metric.NewStatManager(ctx, "op")
+	if err != nil {
+		infra.DrainError(ctx, fmt.Errorf("fail to create stat manager"), errCh)
+		return
+	}
+	n.statManager = stats
+	n.statManagers = []metric.StatManager{stats}
+	fv, afv := xsql.NewFunctionValuersForOp(ctx)
+	go func() {
+		err := infra.SafeRun(func() error {
+			if err := n.restore(ctx); err != nil {
+				return err
+			}
+			for {
+				log.Debugf("IntervalJoinNode %s is looping", n.name)
+				select {
+				case item, opened := <-n.input:
+					processed := false
+					if item, processed = n.preprocess(item); processed {
+						break
+					}
+					n.statManager.IncTotalRecordsIn()
+					n.statManager.ProcessTimeStart()
+					if !opened {
+						n.statManager.IncTotalExceptions("input channel closed")
+						break
+					}
+					switch d := item.(type) {
+					case error:
+						n.Broadcast(d)
+						n.statManager.IncTotalExceptions(d.Error())
+					case *xsql.Tuple:
+						log.Debugf("IntervalJoinNode receive tuple input %s", d)
+						n.join(ctx, d, fv, afv)
+					default:
+						e := fmt.Errorf("run IntervalJoinNode error: invalid input type but got %[1]T(%[1]v)", d)
+						n.Broadcast(e)
+						n.statManager.IncTotalExceptions(e.Error())
+					}
+					n.statManager.ProcessTimeEnd()
+					n.statManager.SetBufferLength(int64(len(n.input)))
+				case <-ctx.Done():
+					log.Infoln("Cancelling interval join node....")
+					return nil
+				}
+			}
+		})
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+		}
+	}()
+}
+
+func (n *IntervalJoinNode) restore(ctx api.StreamContext) error {
+	log := ctx.GetLogger()
+	n.inputs = make(map[string][]*xsql.Tuple)
+	n.maxTs = make(map[string]int64)
+	if s, err := ctx.GetState(IntervalJoinInputsKey); err == nil {
+		switch st := s.(type) {
+		case map[string][]*xsql.Tuple:
+			n.inputs = st
+			log.Infof("Restore interval join state %+v", st)
+		case nil:
+			log.Debugf("Restore interval join state, nothing")
+		default:
+			return fmt.Errorf("restore interval join state %v error, invalid type", st)
+		}
+	} else {
+		log.Warnf("Restore interval join state fails: %s", err)
+	}
+	if s, err := ctx.GetState(IntervalJoinMaxTsKey); err == nil && s != nil {
+		if st, ok := s.(map[string]int64); ok {
+			n.maxTs = st
+		} else {
+			return fmt.Errorf("restore interval join state `maxTs` %v error, invalid type", s)
+		}
+	}
+	return nil
+}
+
+// join the tuple with the buffered tuples of the other stream and buffer it
+func (n *IntervalJoinNode) join(ctx api.StreamContext, d *xsql.Tuple, fv *xsql.FunctionValuer, afv *xsql.AggregateFunctionValuer) {
+	if d.Emitter != n.conf.Left && d.Emitter != n.conf.Right {
+		e := fmt.Errorf("run IntervalJoinNode error: receive tuple from unknown emitter %s", d.Emitter)
+		n.Broadcast(e)
+		n.statManager.IncTotalExceptions(e.Error())
+		return
+	}
+	if n.isEventTime {
+		if d.Timestamp < n.watermark() {
+			ctx.GetLogger().Debugf("IntervalJoinNode drops late tuple %s at %d", d, d.Timestamp)
+			return
+		}
+		if d.Timestamp > n.maxTs[d.Emitter] {
+			n.maxTs[d.Emitter] = d.Timestamp
+		}
+	}
+	// the collection of the tuple and the buffered tuples of the other stream so that the join only matches the new tuple
+	var content []xsql.TupleRow
+	if d.Emitter == n.conf.Left {
+		content = make([]xsql.TupleRow, 0, len(n.inputs[n.conf.Right])+1)
+		content = append(content, d)
+		for _, t := range n.inputs[n.conf.Right] {
+			content = append(content, t)
+		}
+	} else {
+		content = make([]xsql.TupleRow, 0, len(n.inputs[n.conf.Left])+1)
+		for _, t := range n.inputs[n.conf.Left] {
+			content = append(content, t)
+		}
+		content = append(content, d)
+	}
+	switch r := n.op.Apply(ctx, &xsql.WindowTuples{Content: content}, fv, afv).(type) {
+	case nil:
+	case error:
+		n.Broadcast(r)
+		n.statManager.IncTotalExceptions(r.Error())
+	default:
+		n.Broadcast(r)
+		n.statManager.IncTotalRecordsOut()
+	}
+	n.inputs[d.Emitter] = append(n.inputs[d.Emitter], d)
+	n.cleanup()
+	_ = ctx.PutState(IntervalJoinInputsKey, n.inputs)
+	if n.isEventTime {
+		_ = ctx.PutState(IntervalJoinMaxTsKey, n.maxTs)
+	}
+}
+
+// watermark is the min of the max event time of the streams minus the late tolerance for event time,
+// or the current time for processing time
+func (n *IntervalJoinNode) watermark() int64 {
+	if !n.isEventTime {
+		return conf.GetNowInMilli()
+	}
+	l, ok := n.maxTs[n.conf.Left]
+	if !ok {
+		return math.MinInt64
+	}
+	r, ok := n.maxTs[n.conf.Right]
+	if !ok {
+		return math.MinInt64
+	}
+	if r < l {
+		l = r
+	}
+	return l - n.lateTol
+}
+
+// cleanup removes the tuples which cannot be matched by the tuples after the watermark. A left tuple at t matches
+// the right tuples in [t + lower, t + upper] and a right tuple at t matches the left tuples in [t - upper, t - lower]
+func (n *IntervalJoinNode) cleanup() {
+	wm := n.watermark()
+	if wm == math.MinInt64 {
+		return
+	}
+	n.inputs[n.conf.Left] = expire(n.inputs[n.conf.Left], wm-n.conf.Upper)
+	n.inputs[n.conf.Right] = expire(n.inputs[n.conf.Right], wm+n.conf.Lower)
+}
+
+// expire removes the tuples before the ts. The tuples are nearly in time order, so scan all of them
+func expire(tuples []*xsql.Tuple, ts int64) []*xsql.Tuple {
+	i := 0
+	for _, t := range tuples {
+		if t.Timestamp >= ts {
+			tuples[i] = t
+			i++
+		}
+	}
+	for j := i; j < len(tuples); j++ {
+		tuples[j] = nil
+	}
+	return tuples[:i]
+}
+
+func (n *IntervalJoinNode) GetMetrics() [][]interface{} {
+	if n.statManager != nil {
+		return [][]interface{}{
+			n.statManager.GetMetrics(),
+		}
+	} else {
+		return nil
+	}
+}
@@ -45,9 +45,10 @@ func NewJoinAlignNode(name string, emitters []string, options *api.RuleOption) (
 	n.defaultSinkNode = &defaultSinkNode{
 		input: make(chan interface{}, options.BufferLength),
 		defaultNode: &defaultNode{
-			outputs:   make(map[string]chan<- interface{}),
-			name:      name,
-			sendError: options.SendError,
+			outputs:      make(map[string]chan<- interface{}),
+			name:         name,
+			sendError:    options.SendError,
+			backPressure: options.BackPressure,
 		},
 	}
 	return n, nil
@@ -68,6 +69,7 @@ func (n *JoinAlignNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 		return
 	}
 	n.statManager = stats
+	n.statManagers = []metric.StatManager{stats}
 	go func() {
 		err := infra.SafeRun(func() error {
 			// restore batch state
@@ -0,0 +1,239 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+// KeyWindowState is the window state of a key when the watermark is generated by key
+type KeyWindowState struct {
+	// MaxTs is the max event time of the key, the watermark of the key is MaxTs - lateTolerance
+	MaxTs int64
+	// NextEnd is the end of the next window to fire, 0 means not calculated yet
+	NextEnd int64
+	// LastActive is the processing time of the last event to detect idle keys
+	LastActive int64
+	Tuples     []*xsql.Tuple
+}
+
+const KEYED_WINDOWS_KEY = "$$keyedWindows"
+
+func init() {
+	gob.Register(map[string]*KeyWindowState{})
+}
+
+// keyedWindow runs tumbling or hopping windows for each key of the group by dimensions by the watermark of the key,
+// so that the keys with different event rates or clock skews won't stall or drop the events of each other
+type keyedWindow struct {
+	o        *WindowOperator
+	fv       *xsql.FunctionValuer
+	lateTol  int64
+	idle     int64
+	interval int64
+	length   int64
+	states   map[string]*KeyWindowState
+}
+
+func (k *keyedWindow) add(ctx api.StreamContext, tuple *xsql.Tuple) error {
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(tuple, k.fv)}
+	var key string
+	for _, d := range k.o.window.Keys {
+		r := ve.Eval(d.Expr)
+		if e, ok := r.(error); ok {
+			return fmt.Errorf("evaluate window key %s error: %v", d.Expr, e)
+		}
+		key += fmt.Sprintf("%v,", r)
+	}
+	ks, ok := k.states[key]
+	if !ok {
+		ks = &KeyWindowState{MaxTs: math.MinInt64}
+		k.states[key] = ks
+	}
+	ks.LastActive = conf.GetNowInMilli()
+	if ks.MaxTs != math.MinInt64 && tuple.Timestamp < ks.MaxTs-k.lateTol {
+		ctx.GetLogger().Debugf("drop late event %d of key %s with watermark %d", tuple.Timestamp, key, ks.MaxTs-k.lateTol)
+		return nil
+	}
+	ks.Tuples = append(ks.Tuples, tuple)
+	if tuple.Timestamp > ks.MaxTs {
+		ks.MaxTs = tuple.Timestamp
+	}
+	k.fire(ctx, ks, ks.MaxTs-k.lateTol)
+	return nil
+}
+
+// fire emits the windows of the key which end before the watermark. The windows without events are skipped
+func (k *keyedWindow) fire(ctx api.StreamContext, ks *KeyWindowState, watermark int64) {
+	for len(ks.Tuples) > 0 {
+		if ks.NextEnd == 0 {
+			ks.NextEnd = getAlignedWindowEndTime(earliestTs(ks.Tuples), k.interval).UnixMilli()
+		}
+		if ks.NextEnd > watermark {
+			return
+		}
+		start := ks.NextEnd - k.length
+		results := &xsql.WindowTuples{
+			Content: make([]xsql.TupleRow, 0),
+		}
+		// the events before the start of the next window are expired
+		expire := ks.NextEnd + k.interval - k.length
+		i := 0
+		for _, t := range ks.Tuples {
+			if t.Timestamp > start && t.Timestamp <= ks.NextEnd {
+				results = results.AddTuple(t)
+			}
+			if t.Timestamp > expire {
+				ks.Tuples[i] = t
+				i++
+			}
+		}
+		for j := i; j < len(ks.Tuples); j++ {
+			ks.Tuples[j] = nil
+		}
+		ks.Tuples = ks.Tuples[:i]
+		if len(results.Content) > 0 {
+			results.WindowRange = xsql.NewWindowRange(start, ks.NextEnd)
+			results.Sort()
+			ctx.GetLogger().Debugf("Sent: %v", results)
+			k.o.Broadcast(results)
+			k.o.statManager.IncTotalRecordsOut()
+		}
+		ks.NextEnd += k.interval
+		// skip the empty windows
+		if len(ks.Tuples) > 0 {
+			if next := getAlignedWindowEndTime(earliestTs(ks.Tuples)-1, k.interval).UnixMilli(); next > ks.NextEnd {
+				ks.NextEnd = next
+			}
+		} else {
+			ks.NextEnd = 0
+		}
+	}
+}
+
+// flushIdle fires the windows of the idle keys by the max watermark of all keys and removes the idle keys without events
+func (k *keyedWindow) flushIdle(ctx api.StreamContext) {
+	now := conf.GetNowInMilli()
+	var watermark int64 = math.MinInt64
+	for _, ks := range k.states {
+		if ks.MaxTs-k.lateTol > watermark {
+			watermark = ks.MaxTs - k.lateTol
+		}
+	}
+	for key, ks := range k.states {
+		if now-ks.LastActive < k.idle {
+			continue
+		}
+		k.fire(ctx, ks, watermark)
+		if len(ks.Tuples) == 0 {
+			delete(k.states, key)
+		}
+	}
+}
+
+func earliestTs(tuples []*xsql.Tuple) int64 {
+	var r int64 = math.MaxInt64
+	for _, t := range tuples {
+		if t.Timestamp < r {
+			r = t.Timestamp
+		}
+	}
+	return r
+}
+
+// execKeyedEventWindow runs the event time window with the watermark of each key
+func (o *WindowOperator) execKeyedEventWindow(ctx api.StreamContext, errCh chan<- error) {
+	log := ctx.GetLogger()
+	fv, _ := xsql.NewFunctionValuersForOp(ctx)
+	kw := &keyedWindow{
+		o:       o,
+		fv:      fv,
+		lateTol: o.watermarkGenerator.lateTolerance,
+		idle:    o.watermarkGenerator.idleTimeout,
+		length:  int64(o.window.Length),
+		states:  make(map[string]*KeyWindowState),
+	}
+	if o.window.Type == ast.HOPPING_WINDOW {
+		kw.interval = int64(o.window.Interval)
+	} else {
+		kw.interval = int64(o.window.Length)
+	}
+	if s, err := ctx.GetState(KEYED_WINDOWS_KEY); err == nil && s != nil {
+		if st, ok := s.(map[string]*KeyWindowState); ok {
+			kw.states = st
+		} else {
+			infra.DrainError(ctx, fmt.Errorf("restore window state `keyedWindows` %v error, invalid type", s), errCh)
+			return
+		}
+	}
+	var idleC <-chan time.Time
+	if kw.idle > 0 {
+		idleTicker := conf.GetTicker(int(kw.idle))
+		defer idleTicker.Stop()
+		idleC = idleTicker.C
+	}
+	for {
+		select {
+		case item, opened := <-o.input:
+			processed := false
+			if item, processed = o.preprocess(item); processed {
+				break
+			}
+			o.statManager.ProcessTimeStart()
+			if !opened {
+				o.statManager.IncTotalExceptions("input channel closed")
+				break
+			}
+			switch d := item.(type) {
+			case error:
+				o.statManager.IncTotalRecordsIn()
+				o.Broadcast(d)
+				o.statManager.IncTotalExceptions(d.Error())
+			case *xsql.Tuple:
+				o.statManager.IncTotalRecordsIn()
+				log.Debugf("keyed window receive tuple %s", d.Message)
+				if err := kw.add(ctx, d); err != nil {
+					o.Broadcast(err)
+					o.statManager.IncTotalExceptions(err.Error())
+				}
+				_ = ctx.PutState(KEYED_WINDOWS_KEY, kw.states)
+			default:
+				o.statManager.IncTotalRecordsIn()
+				e := fmt.Errorf("run Window error: expect xsql.Tuple type but got %[1]T(%[1]v)", d)
+				o.Broadcast(e)
+				o.statManager.IncTotalExceptions(e.Error())
+			}
+			o.statManager.ProcessTimeEnd()
+			o.statManager.SetBufferLength(int64(len(o.input)))
+		case <-idleC:
+			o.statManager.ProcessTimeStart()
+			kw.flushIdle(ctx)
+			o.statManager.ProcessTimeEnd()
+			_ = ctx.PutState(KEYED_WINDOWS_KEY, kw.states)
+		case <-ctx.Done():
+			log.Infoln("Cancelling window....")
+			return
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const LATE_RETAINED_KEY = "$$lateRetained"
+
+// lateHandler handles the events later than the watermark for the tumbling and hopping window in event time.
+// The events of the fired windows are retained for the allowed lateness, so that a late event re-fires the updated
+// windows it belongs to. The events later than the allowed lateness are sent to the side output topic if set
+type lateHandler struct {
+	o        *WindowOperator
+	lateness int64
+	topic    string
+	length   int64
+	interval int64
+	// the events of the windows which may be re-fired
+	retained []*xsql.Tuple
+}
+
+func newLateHandler(o *WindowOperator) *lateHandler {
+	h := &lateHandler{
+		o:        o,
+		lateness: o.window.AllowedLateness,
+		topic:    o.window.SideOutput,
+		length:   int64(o.window.Length),
+	}
+	if o.window.Type == ast.HOPPING_WINDOW {
+		h.interval = int64(o.window.Interval)
+	} else {
+		h.interval = int64(o.window.Length)
+	}
+	return h
+}
+
+// retain keeps the accepted event and expires the events whose windows all end before the allowed lateness
+func (h *lateHandler) retain(tuple *xsql.Tuple, watermark int64) {
+	if h.lateness <= 0 {
+		return
+	}
+	i := 0
+	for _, t := range h.retained {
+		if t.Timestamp+h.length > watermark-h.lateness {
+			h.retained[i] = t
+			i++
+		}
+	}
+	for j := i; j < len(h.retained); j++ {
+		h.retained[j] = nil
+	}
+	h.retained = append(h.retained[:i], tuple)
+}
+
+// handle processes the late event. It returns true if the event belongs to a window not fired yet, so that it is
+// added to the inputs as usual
+func (h *lateHandler) handle(ctx api.StreamContext, tuple *xsql.Tuple, lastFired int64, watermark int64) bool {
+	var (
+		pending bool
+		refire  []int64
+	)
+	// the windows (end - length, end] including the event
+	for end := getAlignedWindowEndTime(tuple.Timestamp-1, h.interval).UnixMilli(); end-h.length < tuple.Timestamp; end += h.interval {
+		if end > lastFired {
+			pending = true
+		} else if end+h.lateness > watermark {
+			refire = append(refire, end)
+		}
+	}
+	if !pending && len(refire) == 0 {
+		ctx.GetLogger().Debugf("event at %d is later than the allowed lateness", tuple.Timestamp)
+		if h.topic != "" {
+			pubsub.Produce(ctx, h.topic, tuple.Message)
+		}
+		return false
+	}
+	h.retain(tuple, watermark)
+	for _, end := range refire {
+		results := &xsql.WindowTuples{
+			Content: make([]xsql.TupleRow, 0),
+		}
+		for _, t := range h.retained {
+			if t.Timestamp > end-h.length && t.Timestamp <= end {
+				results = results.AddTuple(t)
+			}
+		}
+		results.WindowRange = xsql.NewWindowRange(end-h.length, end)
+		results.Sort()
+		ctx.GetLogger().Debugf("Re-fire window at %d by late event: %v", end, results)
+		h.o.Broadcast(results)
+		h.o.statManager.IncTotalRecordsOut()
+	}
+	return pending
+}
@@ -33,6 +33,8 @@ type LookupConf struct {
 	Cache           bool `json:"cache"`
 	CacheTTL        int  `json:"cacheTtl"`
 	CacheMissingKey bool `json:"cacheMissingKey"`
+	// CacheSize is the max count of the cached keys, the least recently used keys are evicted. 0 means no limit
+	CacheSize int `json:"cacheSize"`
 }
 
 // LookupNode will look up the data from the external source when receiving an event
@@ -74,9 +76,10 @@ func NewLookupNode(name string, fields []string, keys []string, joinType ast.Joi
 	n.defaultSinkNode = &defaultSinkNode{
 		input: make(chan interface{}, options.BufferLength),
 		defaultNode: &defaultNode{
-			outputs:   make(map[string]chan<- interface{}),
-			name:      name,
-			sendError: options.SendError,
+			outputs:      make(map[string]chan<- interface{}),
+			name:         name,
+			sendError:    options.SendError,
+			backPressure: options.BackPressure,
 		},
 	}
 	return n, nil
@@ -97,6 +100,7 @@ func (n *LookupNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 		return
 	}
 	n.statManager = stats
+	n.statManagers = []metric.StatManager{stats}
 	go func() {
 		err := infra.SafeRun(func() error {
 			ns, err := lookup.Attach(n.name)
@@ -107,7 +111,7 @@ func (n *LookupNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 			fv, _ := xsql.NewFunctionValuersForOp(ctx)
 			var c *cache.Cache
 			if n.conf.Cache {
-				c = cache.NewCache(n.conf.CacheTTL, n.conf.CacheMissingKey)
+				c = cache.NewCache(n.conf.CacheTTL, n.conf.CacheMissingKey, n.conf.CacheSize)
 				defer c.Close()
 			}
 			// Start the lookup source loop
@@ -148,17 +152,22 @@ func (n *LookupNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 						log.Debugf("Lookup Node receive window input %s", d)
 						n.statManager.ProcessTimeStart()
 						sets := &xsql.JoinTuples{Content: make([]*xsql.JoinTuple, 0)}
-						err := d.Range(func(i int, r xsql.ReadonlyRow) (bool, error) {
-							tr, ok := r.(xsql.TupleRow)
-							if !ok {
-								return false, fmt.Errorf("Invalid window element, must be a tuple row but got %v", r)
-							}
-							err := n.lookup(ctx, tr, fv, ns, sets, c)
-							if err != nil {
-								return false, err
-							}
-							return true, nil
-						})
+						var err error
+						if bs, ok := ns.(lookup.BatchLookupSource); ok {
+							err = n.lookupBatch(ctx, d, fv, bs, sets, c)
+						} else {
+							err = d.Range(func(i int, r xsql.ReadonlyRow) (bool, error) {
+								tr, ok := r.(xsql.TupleRow)
+								if !ok {
+									return false, fmt.Errorf("Invalid window element, must be a tuple row but got %v", r)
+								}
+								err := n.lookup(ctx, tr, fv, ns, sets, c)
+								if err != nil {
+									return false, err
+								}
+								return true, nil
+							})
+						}
 						if err != nil {
 							n.Broadcast(err)
 							n.statManager.IncTotalExceptions(err.Error())
@@ -187,15 +196,7 @@ func (n *LookupNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 
 // lookup will lookup the cache firstly, if expires, read the external source
 func (n *LookupNode) lookup(ctx api.StreamContext, d xsql.TupleRow, fv *xsql.FunctionValuer, ns api.LookupSource, tuples *xsql.JoinTuples, c *cache.Cache) error {
-	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(d, fv)}
-	cvs := make([]interface{}, len(n.vals))
-	hasNil := false
-	for i, val := range n.vals {
-		cvs[i] = ve.Eval(val)
-		if cvs[i] == nil {
-			hasNil = true
-		}
-	}
+	cvs, hasNil := n.evalValues(d, fv)
 	var (
 		r  []api.SourceTuple
 		e  error
@@ -218,30 +219,109 @@ func (n *LookupNode) lookup(ctx api.StreamContext, d xsql.TupleRow, fv *xsql.Fun
 	}
 	if e != nil {
 		return e
-	} else {
-		if len(r) == 0 {
-			if n.joinType == ast.LEFT_JOIN {
-				merged := &xsql.JoinTuple{}
-				merged.AddTuple(d)
-				tuples.Content = append(tuples.Content, merged)
-			} else {
-				ctx.GetLogger().Debugf("Lookup Node %s no result found for tuple %s", n.name, d)
-				return nil
+	}
+	n.join(ctx, d, r, tuples)
+	return nil
+}
+
+// lookupBatch looks up the rows of the window in one batch. The cached values and the duplicate values are not looked up again
+func (n *LookupNode) lookupBatch(ctx api.StreamContext, w *xsql.WindowTuples, fv *xsql.FunctionValuer, ns lookup.BatchLookupSource, tuples *xsql.JoinTuples, c *cache.Cache) error {
+	var (
+		rows    []xsql.TupleRow
+		results [][]api.SourceTuple
+		values  [][]interface{}
+		keys    []string
+		// the indexes of the rows to look up for each key
+		pending = make(map[string][]int)
+	)
+	err := w.Range(func(i int, r xsql.ReadonlyRow) (bool, error) {
+		tr, ok := r.(xsql.TupleRow)
+		if !ok {
+			return false, fmt.Errorf("Invalid window element, must be a tuple row but got %v", r)
+		}
+		rows = append(rows, tr)
+		results = append(results, nil)
+		cvs, hasNil := n.evalValues(tr, fv)
+		if hasNil {
+			return true, nil
+		}
+		k := fmt.Sprintf("%v", cvs)
+		if c != nil {
+			if cr, ok := c.Get(k); ok {
+				results[len(results)-1] = cr
+				return true, nil
 			}
 		}
-		for _, v := range r {
+		if _, ok := pending[k]; !ok {
+			keys = append(keys, k)
+			values = append(values, cvs)
+		}
+		pending[k] = append(pending[k], len(rows)-1)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(values) > 0 {
+		rs, err := ns.LookupBatch(ctx, n.fields, n.keys, values)
+		if err != nil {
+			return err
+		}
+		if len(rs) != len(values) {
+			return fmt.Errorf("lookup %d values but got %d results", len(values), len(rs))
+		}
+		for i, k := range keys {
+			if c != nil {
+				c.Set(k, rs[i])
+			}
+			for _, j := range pending[k] {
+				results[j] = rs[i]
+			}
+		}
+	}
+	for i, d := range rows {
+		n.join(ctx, d, results[i], tuples)
+	}
+	return nil
+}
+
+// evalValues evaluates the lookup values of the row and returns whether any of the values is nil
+func (n *LookupNode) evalValues(d xsql.TupleRow, fv *xsql.FunctionValuer) ([]interface{}, bool) {
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(d, fv)}
+	cvs := make([]interface{}, len(n.vals))
+	hasNil := false
+	for i, val := range n.vals {
+		cvs[i] = ve.Eval(val)
+		if cvs[i] == nil {
+			hasNil = true
+		}
+	}
+	return cvs, hasNil
+}
+
+// join merges the row with each of the lookup results
+func (n *LookupNode) join(ctx api.StreamContext, d xsql.TupleRow, r []api.SourceTuple, tuples *xsql.JoinTuples) {
+	if len(r) == 0 {
+		if n.joinType == ast.LEFT_JOIN {
 			merged := &xsql.JoinTuple{}
 			merged.AddTuple(d)
-			t := &xsql.Tuple{
-				Emitter:   n.name,
-				Message:   v.Message(),
-				Metadata:  v.Meta(),
-				Timestamp: conf.GetNowInMilli(),
-			}
-			merged.AddTuple(t)
 			tuples.Content = append(tuples.Content, merged)
+		} else {
+			ctx.GetLogger().Debugf("Lookup Node %s no result found for tuple %s", n.name, d)
 		}
-		return nil
+		return
+	}
+	for _, v := range r {
+		merged := &xsql.JoinTuple{}
+		merged.AddTuple(d)
+		t := &xsql.Tuple{
+			Emitter:   n.name,
+			Message:   v.Message(),
+			Metadata:  v.Meta(),
+			Timestamp: conf.GetNowInMilli(),
+		}
+		merged.AddTuple(t)
+		tuples.Content = append(tuples.Content, merged)
 	}
 }
 
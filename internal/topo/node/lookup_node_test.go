@@ -105,6 +105,21 @@ func (m *mockLookupSrc) Close(_ api.StreamContext) error {
 	return nil
 }
 
+// mockBatchLookupSrc records the values of each batch
+type mockBatchLookupSrc struct {
+	mockLookupSrc
+	batches [][][]interface{}
+}
+
+func (m *mockBatchLookupSrc) LookupBatch(ctx api.StreamContext, fields []string, keys []string, values [][]interface{}) ([][]api.SourceTuple, error) {
+	m.batches = append(m.batches, values)
+	result := make([][]api.SourceTuple, len(values))
+	for i, v := range values {
+		result[i], _ = m.Lookup(ctx, fields, keys, v)
+	}
+	return result, nil
+}
+
 type mockFac struct{}
 
 func (m *mockFac) Source(_ string) (api.Source, error) {
@@ -119,6 +134,9 @@ func (m *mockFac) LookupSource(name string) (api.LookupSource, error) {
 	if name == "mock" {
 		return &mockLookupSrc{}, nil
 	}
+	if name == "mockBatch" {
+		return &mockBatchLookupSrc{}, nil
+	}
 	return nil, nil
 }
 
@@ -535,3 +553,78 @@ func TestCachedLookup(t *testing.T) {
 		return
 	}
 }
+
+func TestBatchLookup(t *testing.T) {
+	options := &ast.Options{
+		DATASOURCE:        "mockBatch",
+		TYPE:              "mockBatch",
+		STRICT_VALIDATION: true,
+		KIND:              "lookup",
+	}
+	err := lookup.CreateInstance("mockBatch", "mockBatch", options)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ls, _ := lookup.Attach("mockBatch")
+	src := ls.(*mockBatchLookupSrc)
+	contextLogger := conf.Log.WithField("rule", "TestBatchLookup")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	l, _ := NewLookupNode("mockBatch", []string{}, []string{"a"}, ast.LEFT_JOIN, []ast.Expr{&ast.FieldRef{
+		StreamName: "",
+		Name:       "a",
+	}}, options, &api.RuleOption{
+		BufferLength: 0,
+	})
+	l.conf = &LookupConf{
+		Cache:     true,
+		CacheTTL:  20,
+		CacheSize: 10,
+	}
+	errCh := make(chan error)
+	outputCh := make(chan interface{}, 1)
+	l.outputs["mock"] = outputCh
+	l.Exec(ctx, errCh)
+	input := &xsql.WindowTuples{
+		Content: []xsql.TupleRow{
+			&xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 1}},
+			&xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 6}},
+			&xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"a": 1}},
+			&xsql.Tuple{Emitter: "demo", Message: map[string]interface{}{"b": "no key"}},
+		},
+	}
+	// the second run gets all the values from the cache
+	expBatches := [][][]interface{}{{{1}, {6}}}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errCh:
+			t.Error(err)
+			return
+		case l.input <- input:
+		case <-time.After(1 * time.Second):
+			t.Error("send message timeout")
+			return
+		}
+		select {
+		case err := <-errCh:
+			t.Error(err)
+			return
+		case output := <-outputCh:
+			sets, ok := output.(*xsql.JoinTuples)
+			if !ok {
+				t.Errorf("case %d: expect join tuples but got %v", i, output)
+				return
+			}
+			// 4 results for 1, 2 results for 6 and the row without key is kept by left join
+			if len(sets.Content) != 11 {
+				t.Errorf("case %d: expect 11 results but got %d", i, len(sets.Content))
+			}
+		case <-time.After(1 * time.Second):
+			t.Error("send message timeout")
+			return
+		}
+		if !reflect.DeepEqual(expBatches, src.batches) {
+			t.Errorf("case %d: expect batches %v but got %v", i, expBatches, src.batches)
+		}
+	}
+}
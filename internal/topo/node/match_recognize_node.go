@@ -0,0 +1,400 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"encoding/gob"
+	"fmt"
+	"sort"
+
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+// PatternRun is a partial match of the pattern. Rows are the consumed rows and Vars are the pattern variables
+// they are mapped to. Pos is the index of the current pattern term and Count is the number of rows mapped to it.
+// Start and Last are the sequence numbers of the first and the last consumed rows
+type PatternRun struct {
+	Start int64
+	Last  int64
+	Pos   int
+	Count int
+	Rows  []*xsql.Tuple
+	Vars  []string
+}
+
+// MatchRecognizeState is the partial matches of each partition. Seq is the sequence number of the last row
+type MatchRecognizeState struct {
+	Seq  int64
+	Runs map[string][]*PatternRun
+}
+
+const MatchRecognizeStateKey = "$$matchRecognize"
+
+func init() {
+	gob.Register(&MatchRecognizeState{})
+}
+
+// MatchRecognizeNode finds the sequences of rows matching the pattern in each partition. The rows are matched in the
+// receiving order and each match is emitted as a row of the partition fields and the measures once it cannot be
+// extended by the next row of the partition
+type MatchRecognizeNode struct {
+	*defaultSinkNode
+	mr      *ast.MatchRecognize
+	defines map[string]ast.Expr
+	// scopes are the pattern variables whose rows are aggregated by the measures, empty means all rows
+	scopes      []string
+	statManager metric.StatManager
+	state       *MatchRecognizeState
+}
+
+func NewMatchRecognizeNode(name string, mr *ast.MatchRecognize, options *api.RuleOption) (*MatchRecognizeNode, error) {
+	n := &MatchRecognizeNode{
+		mr:      mr,
+		defines: make(map[string]ast.Expr, len(mr.Defines)),
+		scopes:  make([]string, len(mr.Measures)),
+	}
+	for _, d := range mr.Defines {
+		n.defines[d.Name] = d.Condition
+	}
+	for i, f := range mr.Measures {
+		if !xsql.IsAggregate(f.Expr) {
+			continue
+		}
+		vars := make(map[ast.StreamName]struct{})
+		ast.WalkFunc(f.Expr, func(nd ast.Node) bool {
+			if fr, ok := nd.(*ast.FieldRef); ok && fr.StreamName != ast.DefaultStream {
+				vars[fr.StreamName] = struct{}{}
+			}
+			return true
+		})
+		if len(vars) == 1 {
+			for v := range vars {
+				n.scopes[i] = string(v)
+			}
+		}
+	}
+	n.defaultSinkNode = &defaultSinkNode{
+		input: make(chan interface{}, options.BufferLength),
+		defaultNode: &defaultNode{
+			outputs:      make(map[string]chan<- interface{}),
+			name:         name,
+			sendError:    options.SendError,
+			backPressure: options.BackPressure,
+		},
+	}
+	return n, nil
+}
+
+func (n *MatchRecognizeNode) Exec(ctx api.StreamContext, errCh chan<- error) {
+	n.ctx = ctx
+	log := ctx.GetLogger()
+	log.Debugf("MatchRecognizeNode %s is started", n.name)
+
+	if len(n.outputs) <= 0 {
+		infra.DrainError(ctx, fmt.Errorf("no output channel found"), errCh)
+		return
+	}
+	stats, err := metric.NewStatManager(ctx, "op")
+	if err != nil {
+		infra.DrainError(ctx, fmt.Errorf("fail to create stat manager"), errCh)
+		return
+	}
+	n.statManager = stats
+	n.statManagers = []metric.StatManager{stats}
+	fv, afv := xsql.NewFunctionValuersForOp(ctx)
+	go func() {
+		err := infra.SafeRun(func() error {
+			n.state = &MatchRecognizeState{Runs: make(map[string][]*PatternRun)}
+			if s, err := ctx.GetState(MatchRecognizeStateKey); err == nil && s != nil {
+				if st, ok := s.(*MatchRecognizeState); ok {
+					if st.Runs == nil {
+						st.Runs = make(map[string][]*PatternRun)
+					}
+					n.state = st
+				} else {
+					return fmt.Errorf("restore match recognize state %v error, invalid type", s)
+				}
+			}
+			for {
+				log.Debugf("MatchRecognizeNode %s is looping", n.name)
+				select {
+				case item, opened := <-n.input:
+					processed := false
+					if item, processed = n.preprocess(item); processed {
+						break
+					}
+					n.statManager.IncTotalRecordsIn()
+					n.statManager.ProcessTimeStart()
+					if !opened {
+						n.statManager.IncTotalExceptions("input channel closed")
+						break
+					}
+					switch d := item.(type) {
+					case error:
+						n.Broadcast(d)
+						n.statManager.IncTotalExceptions(d.Error())
+					case *xsql.Tuple:
+						log.Debugf("MatchRecognizeNode receive tuple input %s", d)
+						n.match(ctx, d, fv, afv)
+						_ = ctx.PutState(MatchRecognizeStateKey, n.state)
+					default:
+						e := fmt.Errorf("run MatchRecognizeNode error: invalid input type but got %[1]T(%[1]v)", d)
+						n.Broadcast(e)
+						n.statManager.IncTotalExceptions(e.Error())
+					}
+					n.statManager.ProcessTimeEnd()
+					n.statManager.SetBufferLength(int64(len(n.input)))
+				case <-ctx.Done():
+					log.Infoln("Cancelling match recognize node....")
+					return nil
+				}
+			}
+		})
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+		}
+	}()
+}
+
+// match feeds the row to the partial matches of its partition and a new run starting from it. The matches which
+// are complete are emitted in the order of their first rows, and the runs overlapped with them are skipped
+func (n *MatchRecognizeNode) match(ctx api.StreamContext, row *xsql.Tuple, fv *xsql.FunctionValuer, afv *xsql.AggregateFunctionValuer) {
+	var key string
+	for _, p := range n.mr.PartitionBy {
+		v, _ := row.Value(p.Name, "")
+		key += fmt.Sprintf("%v,", v)
+	}
+	n.state.Seq++
+	seq := n.state.Seq
+	var (
+		next    []*PatternRun
+		matches []*PatternRun
+	)
+	seen := make(map[[3]int64]bool)
+	for _, run := range append(n.state.Runs[key], &PatternRun{Start: seq}) {
+		if n.mr.Within > 0 && len(run.Rows) > 0 && row.Timestamp-run.Rows[0].Timestamp > n.mr.Within {
+			if n.accepting(run) {
+				matches = append(matches, run)
+			}
+			continue
+		}
+		succ, err := n.step(run, row, seq, fv)
+		if err != nil {
+			n.Broadcast(err)
+			n.statManager.IncTotalExceptions(err.Error())
+			continue
+		}
+		if len(succ) == 0 {
+			if len(run.Rows) > 0 && n.accepting(run) {
+				matches = append(matches, run)
+			}
+			continue
+		}
+		for _, r := range succ {
+			if n.final(r) {
+				matches = append(matches, r)
+				continue
+			}
+			// the runs in the same state are equivalent for the future rows, keep the first one
+			k := [3]int64{r.Start, int64(r.Pos), int64(r.Count)}
+			if !seen[k] {
+				seen[k] = true
+				next = append(next, r)
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Start < matches[j].Start
+	})
+	for len(matches) > 0 {
+		m := matches[0]
+		n.emit(ctx, m, fv, afv)
+		skip := func(r *PatternRun) bool {
+			if n.mr.AfterMatchSkip == ast.SKIP_TO_NEXT_ROW {
+				return r.Start == m.Start
+			}
+			return r.Start <= m.Last
+		}
+		matches = filterRuns(matches[1:], skip)
+		next = filterRuns(next, skip)
+	}
+	if len(next) > 0 {
+		n.state.Runs[key] = next
+	} else {
+		delete(n.state.Runs, key)
+	}
+}
+
+func filterRuns(runs []*PatternRun, skip func(r *PatternRun) bool) []*PatternRun {
+	i := 0
+	for _, r := range runs {
+		if !skip(r) {
+			runs[i] = r
+			i++
+		}
+	}
+	for j := i; j < len(runs); j++ {
+		runs[j] = nil
+	}
+	return runs[:i]
+}
+
+// step returns the runs after consuming the row. The row can be mapped to the current term if its quantifier allows
+// more rows, or to the following terms once the previous terms have got enough rows
+func (n *MatchRecognizeNode) step(run *PatternRun, row *xsql.Tuple, seq int64, fv *xsql.FunctionValuer) ([]*PatternRun, error) {
+	var r []*PatternRun
+	pos, count := run.Pos, run.Count
+	for pos < len(n.mr.Pattern) {
+		t := n.mr.Pattern[pos]
+		if t.Max < 0 || count < t.Max {
+			ok, err := n.define(t.Name, run, row, fv)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				r = append(r, &PatternRun{
+					Start: run.Start,
+					Last:  seq,
+					Pos:   pos,
+					Count: count + 1,
+					Rows:  append(run.Rows[:len(run.Rows):len(run.Rows)], row),
+					Vars:  append(run.Vars[:len(run.Vars):len(run.Vars)], t.Name),
+				})
+			}
+		}
+		if count < t.Min {
+			break
+		}
+		pos++
+		count = 0
+	}
+	return r, nil
+}
+
+// accepting returns true if the run has got enough rows for all the pattern terms
+func (n *MatchRecognizeNode) accepting(run *PatternRun) bool {
+	if run.Count < n.mr.Pattern[run.Pos].Min {
+		return false
+	}
+	for _, t := range n.mr.Pattern[run.Pos+1:] {
+		if t.Min > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// final returns true if the run is complete and cannot consume more rows
+func (n *MatchRecognizeNode) final(run *PatternRun) bool {
+	t := n.mr.Pattern[run.Pos]
+	return run.Pos == len(n.mr.Pattern)-1 && t.Max >= 0 && run.Count == t.Max
+}
+
+func (n *MatchRecognizeNode) define(name string, run *PatternRun, row *xsql.Tuple, fv *xsql.FunctionValuer) (bool, error) {
+	cond, ok := n.defines[name]
+	if !ok {
+		return true, nil
+	}
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(&patternValuer{run: run, current: row, name: name}, fv)}
+	switch r := ve.Eval(cond).(type) {
+	case error:
+		return false, fmt.Errorf("evaluate pattern variable %s error: %v", name, r)
+	case bool:
+		return r, nil
+	default:
+		return false, nil
+	}
+}
+
+func (n *MatchRecognizeNode) emit(ctx api.StreamContext, m *PatternRun, fv *xsql.FunctionValuer, afv *xsql.AggregateFunctionValuer) {
+	last := m.Rows[len(m.Rows)-1]
+	msg := make(map[string]interface{}, len(n.mr.PartitionBy)+len(n.mr.Measures))
+	for _, p := range n.mr.PartitionBy {
+		msg[p.Name], _ = last.Value(p.Name, "")
+	}
+	wr := xsql.NewWindowRange(m.Rows[0].Timestamp, last.Timestamp)
+	pv := &patternValuer{run: m, current: last}
+	for i, f := range n.mr.Measures {
+		data := &xsql.WindowTuples{Content: make([]xsql.TupleRow, 0, len(m.Rows)), WindowRange: wr}
+		for j, t := range m.Rows {
+			if n.scopes[i] == "" || m.Vars[j] == n.scopes[i] {
+				data = data.AddTuple(t)
+			}
+		}
+		afv.SetData(data)
+		ve := &xsql.ValuerEval{Valuer: xsql.MultiAggregateValuer(data, fv, pv, &xsql.WindowRangeValuer{WindowRange: wr}, fv, afv, &xsql.WildcardValuer{Data: last})}
+		r := ve.Eval(f.Expr)
+		if e, ok := r.(error); ok {
+			err := fmt.Errorf("evaluate measure %s error: %v", f.AName, e)
+			n.Broadcast(err)
+			n.statManager.IncTotalExceptions(err.Error())
+			return
+		}
+		msg[f.AName] = r
+	}
+	ctx.GetLogger().Debugf("MatchRecognizeNode matches rows %d to %d", m.Start, m.Last)
+	n.Broadcast(&xsql.Tuple{Emitter: last.Emitter, Message: msg, Timestamp: last.Timestamp, Metadata: last.Metadata})
+	n.statManager.IncTotalRecordsOut()
+}
+
+// patternValuer gets the value of the field refs in MATCH_RECOGNIZE. The refs of a pattern variable get the value
+// of the last row mapped to the variable. The refs without variable and the refs of the variable being defined get
+// the value of the current row
+type patternValuer struct {
+	run     *PatternRun
+	current *xsql.Tuple
+	name    string
+}
+
+func (v *patternValuer) row(table string) *xsql.Tuple {
+	if table == "" || table == v.name {
+		return v.current
+	}
+	for i := len(v.run.Rows) - 1; i >= 0; i-- {
+		if v.run.Vars[i] == table {
+			return v.run.Rows[i]
+		}
+	}
+	return nil
+}
+
+func (v *patternValuer) Value(key, table string) (interface{}, bool) {
+	if r := v.row(table); r != nil {
+		return r.Value(key, "")
+	}
+	// the variable has no row yet
+	return nil, true
+}
+
+func (v *patternValuer) Meta(key, table string) (interface{}, bool) {
+	if r := v.row(table); r != nil {
+		return r.Meta(key, "")
+	}
+	return nil, true
+}
+
+func (n *MatchRecognizeNode) GetMetrics() [][]interface{} {
+	if n.statManager != nil {
+		return [][]interface{}{
+			n.statManager.GetMetrics(),
+		}
+	} else {
+		return nil
+	}
+}
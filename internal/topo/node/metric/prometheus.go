@@ -42,10 +42,24 @@ type MetricGroup struct {
 	TotalExceptions *prometheus.CounterVec
 	ProcessLatency  *prometheus.GaugeVec
 	BufferLength    *prometheus.GaugeVec
+	BackPressure    *prometheus.CounterVec
+	BackPressureUs  *prometheus.CounterVec
+	CacheLength     *prometheus.GaugeVec
+	CacheOldestAge  *prometheus.GaugeVec
+	// ProcessLatencyHist is the histogram of the process latency in seconds
+	ProcessLatencyHist *prometheus.HistogramVec
+	Retry              *prometheus.CounterVec
+}
+
+// RuleMetrics are the metrics of the whole rule instead of a node
+type RuleMetrics struct {
+	CheckpointDuration *prometheus.HistogramVec
+	CheckpointFailures *prometheus.CounterVec
 }
 
 type PrometheusMetrics struct {
 	vecs []*MetricGroup
+	rule *RuleMetrics
 }
 
 func newPrometheusMetrics() *PrometheusMetrics {
@@ -76,16 +90,66 @@ func newPrometheusMetrics() *PrometheusMetrics {
 			Name: prefix + "_" + BufferLength,
 			Help: "The length of the plan buffer which is shared by all instances of " + prefix,
 		}, labelNames)
-		prometheus.MustRegister(totalRecordsIn, totalRecordsOut, totalExceptions, processLatency, bufferLength)
+		backPressure := prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prefix + "_" + BackPressureTotal,
+			Help: "Total number of waits for the downstream to consume of " + prefix,
+		}, labelNames)
+		backPressureUs := prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prefix + "_" + BackPressureTimeUs,
+			Help: "Total time in microsecond waiting for the downstream to consume of " + prefix,
+		}, labelNames)
+		cacheLength := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prefix + "_" + CacheLength,
+			Help: "The length of the sink cache of " + prefix,
+		}, labelNames)
+		cacheOldestAge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: prefix + "_" + CacheOldestAgeMs,
+			Help: "The age in millisecond of the oldest data in the sink cache of " + prefix,
+		}, labelNames)
+		processLatencyHist := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: prefix + "_process_latency_seconds",
+			Help: "The distribution of the process latency in second of " + prefix,
+			// from 10us to about 40s
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 12),
+		}, labelNames)
+		retry := prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: prefix + "_" + RetryTotal,
+			Help: "Total number of failed sendings to be resent from the cache of " + prefix,
+		}, labelNames)
+		prometheus.MustRegister(totalRecordsIn, totalRecordsOut, totalExceptions, processLatency, bufferLength, backPressure, backPressureUs, cacheLength, cacheOldestAge, processLatencyHist, retry)
 		vecs = append(vecs, &MetricGroup{
 			TotalRecordsIn:  totalRecordsIn,
 			TotalRecordsOut: totalRecordsOut,
 			TotalExceptions: totalExceptions,
 			ProcessLatency:  processLatency,
 			BufferLength:    bufferLength,
+			BackPressure:    backPressure,
+			BackPressureUs:  backPressureUs,
+			CacheLength:     cacheLength,
+			CacheOldestAge:  cacheOldestAge,
+
+			ProcessLatencyHist: processLatencyHist,
+			Retry:              retry,
 		})
 	}
-	return &PrometheusMetrics{vecs: vecs}
+	checkpointDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "kuiper_rule_checkpoint_duration_seconds",
+		Help: "The duration in second from triggering to completing a checkpoint of the rule",
+		// from 1ms to about 260s
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"rule"})
+	checkpointFailures := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kuiper_rule_checkpoint_failures_total",
+		Help: "Total number of the canceled or failed checkpoints of the rule",
+	}, []string{"rule"})
+	prometheus.MustRegister(checkpointDuration, checkpointFailures)
+	return &PrometheusMetrics{
+		vecs: vecs,
+		rule: &RuleMetrics{
+			CheckpointDuration: checkpointDuration,
+			CheckpointFailures: checkpointFailures,
+		},
+	}
 }
 
 func (m *PrometheusMetrics) GetMetricsGroup(opType string) *MetricGroup {
@@ -16,23 +16,29 @@ package metric
 
 import (
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
 const (
-	RecordsInTotal    = "records_in_total"
-	RecordsOutTotal   = "records_out_total"
-	ProcessLatencyUs  = "process_latency_us"
-	LastInvocation    = "last_invocation"
-	BufferLength      = "buffer_length"
-	ExceptionsTotal   = "exceptions_total"
-	LastException     = "last_exception"
-	LastExceptionTime = "last_exception_time"
+	RecordsInTotal     = "records_in_total"
+	RecordsOutTotal    = "records_out_total"
+	ProcessLatencyUs   = "process_latency_us"
+	LastInvocation     = "last_invocation"
+	BufferLength       = "buffer_length"
+	ExceptionsTotal    = "exceptions_total"
+	LastException      = "last_exception"
+	LastExceptionTime  = "last_exception_time"
+	BackPressureTotal  = "back_pressure_total"
+	BackPressureTimeUs = "back_pressure_time_us"
+	CacheLength        = "cache_length"
+	CacheOldestAgeMs   = "cache_oldest_age_ms"
+	RetryTotal         = "retry_total"
 )
 
-var MetricNames = []string{RecordsInTotal, RecordsOutTotal, ProcessLatencyUs, BufferLength, LastInvocation, ExceptionsTotal, LastException, LastExceptionTime}
+var MetricNames = []string{RecordsInTotal, RecordsOutTotal, ProcessLatencyUs, BufferLength, LastInvocation, ExceptionsTotal, LastException, LastExceptionTime, BackPressureTotal, BackPressureTimeUs, CacheLength, CacheOldestAgeMs, RetryTotal}
 
 type StatManager interface {
 	IncTotalRecordsIn()
@@ -42,6 +48,12 @@ type StatManager interface {
 	ProcessTimeEnd()
 	SetBufferLength(l int64)
 	SetProcessTimeStart(t time.Time)
+	// IncBackPressure records a wait of the downstream to consume, which may happen in multiple goroutines
+	IncBackPressure(d time.Duration)
+	// SetCacheStatus sets the length of the sink cache and the age in millisecond of the oldest cached data
+	SetCacheStatus(length int64, oldestAge int64)
+	// IncRetry records a failed sending of the sink which will be resent from the cache
+	IncRetry()
 	GetMetrics() []interface{}
 	// Clean remove all metrics history
 	Clean(ruleId string)
@@ -58,6 +70,11 @@ type DefaultStatManager struct {
 	totalExceptions   int64
 	lastException     string
 	lastExceptionTime time.Time
+	backPressureTotal int64
+	backPressureTime  int64
+	cacheLength       int64
+	cacheOldestAge    int64
+	retryTotal        int64
 	// configs
 	opType           string //"source", "op", "sink"
 	prefix           string
@@ -124,6 +141,20 @@ func (sm *DefaultStatManager) SetProcessTimeStart(t time.Time) {
 	sm.lastInvocation = t
 }
 
+func (sm *DefaultStatManager) IncBackPressure(d time.Duration) {
+	atomic.AddInt64(&sm.backPressureTotal, 1)
+	atomic.AddInt64(&sm.backPressureTime, int64(d/time.Microsecond))
+}
+
+func (sm *DefaultStatManager) SetCacheStatus(length int64, oldestAge int64) {
+	sm.cacheLength = length
+	sm.cacheOldestAge = oldestAge
+}
+
+func (sm *DefaultStatManager) IncRetry() {
+	sm.retryTotal++
+}
+
 func (sm *DefaultStatManager) GetMetrics() []interface{} {
 	result := []interface{}{
 		sm.totalRecordsIn,
@@ -134,6 +165,11 @@ func (sm *DefaultStatManager) GetMetrics() []interface{} {
 		sm.totalExceptions,
 		sm.lastException,
 		0,
+		atomic.LoadInt64(&sm.backPressureTotal),
+		atomic.LoadInt64(&sm.backPressureTime),
+		sm.cacheLength,
+		sm.cacheOldestAge,
+		sm.retryTotal,
 	}
 
 	if !sm.lastInvocation.IsZero() {
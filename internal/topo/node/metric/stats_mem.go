@@ -19,6 +19,7 @@ package metric
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/pkg/api"
@@ -31,3 +32,9 @@ func getStatManager(_ api.StreamContext, sm DefaultStatManager) (StatManager, er
 		return &sm, nil
 	}
 }
+
+// ObserveCheckpoint records nothing without prometheus support
+func ObserveCheckpoint(_ string, _ time.Duration, _ bool) {}
+
+// CleanRule removes nothing without prometheus support
+func CleanRule(_ string) {}
@@ -41,12 +41,24 @@ func getStatManager(ctx api.StreamContext, dsm DefaultStatManager) (StatManager,
 		mg.TotalExceptions.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
 		mg.ProcessLatency.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
 		mg.BufferLength.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		mg.BackPressure.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		mg.BackPressureUs.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		mg.CacheLength.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		mg.CacheOldestAge.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		mg.ProcessLatencyHist.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		mg.Retry.DeleteLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
 
 		psm.pTotalRecordsIn = mg.TotalRecordsIn.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
 		psm.pTotalRecordsOut = mg.TotalRecordsOut.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
 		psm.pTotalExceptions = mg.TotalExceptions.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
 		psm.pProcessLatency = mg.ProcessLatency.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
 		psm.pBufferLength = mg.BufferLength.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		psm.pBackPressure = mg.BackPressure.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		psm.pBackPressureUs = mg.BackPressureUs.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		psm.pCacheLength = mg.CacheLength.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		psm.pCacheOldestAge = mg.CacheOldestAge.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		psm.pProcessLatencyHist = mg.ProcessLatencyHist.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
+		psm.pRetry = mg.Retry.WithLabelValues(ctx.GetRuleId(), dsm.opType, dsm.opId, strInId)
 		sm = psm
 	} else {
 		sm = &dsm
@@ -62,6 +74,13 @@ type PrometheusStatManager struct {
 	pTotalExceptions prometheus.Counter
 	pProcessLatency  prometheus.Gauge
 	pBufferLength    prometheus.Gauge
+	pBackPressure    prometheus.Counter
+	pBackPressureUs  prometheus.Counter
+	pCacheLength     prometheus.Gauge
+	pCacheOldestAge  prometheus.Gauge
+	// pProcessLatencyHist is the distribution of the process latency in seconds
+	pProcessLatencyHist prometheus.Observer
+	pRetry              prometheus.Counter
 }
 
 func (sm *PrometheusStatManager) IncTotalRecordsIn() {
@@ -81,8 +100,10 @@ func (sm *PrometheusStatManager) IncTotalExceptions(err string) {
 
 func (sm *PrometheusStatManager) ProcessTimeEnd() {
 	if !sm.processTimeStart.IsZero() {
-		sm.processLatency = int64(time.Since(sm.processTimeStart) / time.Microsecond)
+		d := time.Since(sm.processTimeStart)
+		sm.processLatency = int64(d / time.Microsecond)
 		sm.pProcessLatency.Set(float64(sm.processLatency))
+		sm.pProcessLatencyHist.Observe(d.Seconds())
 	}
 }
 
@@ -91,6 +112,23 @@ func (sm *PrometheusStatManager) SetBufferLength(l int64) {
 	sm.pBufferLength.Set(float64(l))
 }
 
+func (sm *PrometheusStatManager) IncBackPressure(d time.Duration) {
+	sm.DefaultStatManager.IncBackPressure(d)
+	sm.pBackPressure.Inc()
+	sm.pBackPressureUs.Add(float64(d / time.Microsecond))
+}
+
+func (sm *PrometheusStatManager) SetCacheStatus(length int64, oldestAge int64) {
+	sm.DefaultStatManager.SetCacheStatus(length, oldestAge)
+	sm.pCacheLength.Set(float64(length))
+	sm.pCacheOldestAge.Set(float64(oldestAge))
+}
+
+func (sm *PrometheusStatManager) IncRetry() {
+	sm.DefaultStatManager.IncRetry()
+	sm.pRetry.Inc()
+}
+
 func (sm *PrometheusStatManager) Clean(ruleId string) {
 	if conf.Config != nil && conf.Config.Basic.Prometheus {
 		mg := GetPrometheusMetrics().GetMetricsGroup(sm.opType)
@@ -100,5 +138,34 @@ func (sm *PrometheusStatManager) Clean(ruleId string) {
 		mg.TotalExceptions.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
 		mg.ProcessLatency.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
 		mg.BufferLength.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
+		mg.BackPressure.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
+		mg.BackPressureUs.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
+		mg.CacheLength.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
+		mg.CacheOldestAge.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
+		mg.ProcessLatencyHist.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
+		mg.Retry.DeleteLabelValues(ruleId, sm.opType, sm.opId, strInId)
+	}
+}
+
+// ObserveCheckpoint records the duration of a completed checkpoint or a failed checkpoint of the rule
+func ObserveCheckpoint(ruleId string, d time.Duration, success bool) {
+	if conf.Config == nil || !conf.Config.Basic.Prometheus {
+		return
+	}
+	rm := GetPrometheusMetrics().rule
+	if success {
+		rm.CheckpointDuration.WithLabelValues(ruleId).Observe(d.Seconds())
+	} else {
+		rm.CheckpointFailures.WithLabelValues(ruleId).Inc()
+	}
+}
+
+// CleanRule removes the rule level metrics of the rule
+func CleanRule(ruleId string) {
+	if conf.Config == nil || !conf.Config.Basic.Prometheus {
+		return
 	}
+	rm := GetPrometheusMetrics().rule
+	rm.CheckpointDuration.DeleteLabelValues(ruleId)
+	rm.CheckpointFailures.DeleteLabelValues(ruleId)
 }
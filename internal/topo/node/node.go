@@ -16,6 +16,7 @@ package node
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/lf-edge/ekuiper/internal/binder/io"
 	"github.com/lf-edge/ekuiper/internal/conf"
@@ -53,9 +54,11 @@ type defaultNode struct {
 	outputs      map[string]chan<- interface{}
 	concurrency  int
 	sendError    bool
+	backPressure bool
 	statManagers []metric.StatManager
 	ctx          api.StreamContext
 	qos          api.Qos
+	taps         tapSet
 }
 
 func (o *defaultNode) AddOutput(output chan<- interface{}, name string) error {
@@ -97,8 +100,11 @@ func (o *defaultNode) RemoveMetrics(ruleId string) {
 }
 
 func (o *defaultNode) Broadcast(val interface{}) error {
-	if _, ok := val.(error); ok && !o.sendError {
-		return nil
+	o.tap(val)
+	if e, ok := val.(error); ok {
+		if sendDeadLetter(o.ctx, DeadLetterRuntime, e, nil) || !o.sendError {
+			return nil
+		}
 	}
 	if o.qos >= api.AtLeastOnce {
 		boe := &checkpoint.BufferOrEvent{
@@ -112,6 +118,10 @@ func (o *defaultNode) Broadcast(val interface{}) error {
 	return nil
 }
 
+// doBroadcast sends the data to all the downstream nodes. The data is dropped if the buffer of the downstream is full.
+// If the back pressure is enabled, the buffer of each downstream input channel is the credits to send. When the
+// credits are used up, it waits until the downstream consumes instead of dropping the data so that the back pressure
+// propagates to the upstream and finally pauses the source.
 func (o *defaultNode) doBroadcast(val interface{}) {
 	for name, out := range o.outputs {
 		select {
@@ -120,8 +130,12 @@ func (o *defaultNode) doBroadcast(val interface{}) {
 		case <-o.ctx.Done():
 			// rule stop so stop waiting
 		default:
-			o.statManagers[0].IncTotalExceptions(fmt.Sprintf("buffer full, drop message from to %s", name))
-			o.ctx.GetLogger().Debugf("drop message from %s to %s", o.name, name)
+			if o.backPressure {
+				o.waitForCredit(name, out, val)
+			} else {
+				o.statManagers[0].IncTotalExceptions(fmt.Sprintf("buffer full, drop message from to %s", name))
+				o.ctx.GetLogger().Debugf("drop message from %s to %s", o.name, name)
+			}
 		}
 		switch vt := val.(type) {
 		case xsql.Collection:
@@ -133,6 +147,18 @@ func (o *defaultNode) doBroadcast(val interface{}) {
 	}
 }
 
+func (o *defaultNode) waitForCredit(name string, out chan<- interface{}, val interface{}) {
+	o.ctx.GetLogger().Debugf("back pressure from %s to %s", name, o.name)
+	start := time.Now()
+	select {
+	case out <- val:
+	case <-o.ctx.Done():
+	}
+	if len(o.statManagers) > 0 {
+		o.statManagers[0].IncBackPressure(time.Since(start))
+	}
+}
+
 func (o *defaultNode) GetStreamContext() api.StreamContext {
 	return o.ctx
 }
@@ -180,6 +206,12 @@ func (o *defaultSinkNode) preprocess(data interface{}) (interface{}, bool) {
 	return data, false
 }
 
+// SinkConfigure validates the sink properties by configuring the sink without connecting it
+func SinkConfigure(sinkType string, config map[string]interface{}) error {
+	_, err := getSink(sinkType, config)
+	return err
+}
+
 func SinkOpen(sinkType string, config map[string]interface{}) error {
 	sink, err := getSink(sinkType, config)
 	if err != nil {
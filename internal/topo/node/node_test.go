@@ -0,0 +1,102 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+)
+
+func TestBroadcastBackPressure(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestBroadcastBackPressure")
+	ctx, cancel := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithCancel()
+	stats, err := metric.NewStatManager(ctx, "op")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := make(chan interface{}, 1)
+	n := &defaultNode{
+		name:         "test",
+		outputs:      map[string]chan<- interface{}{"output": output},
+		ctx:          ctx,
+		backPressure: true,
+		statManagers: []metric.StatManager{stats},
+	}
+	n.doBroadcast(1)
+	done := make(chan struct{})
+	go func() {
+		n.doBroadcast(2)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("should wait for the downstream when the buffer is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	for i := 1; i <= 2; i++ {
+		if v := <-output; v != i {
+			t.Errorf("expect %d but got %v", i, v)
+		}
+	}
+	<-done
+	m := stats.GetMetrics()
+	if m[8] != int64(1) {
+		t.Errorf("expect back pressure total 1 but got %v", m[8])
+	}
+	if m[9].(int64) < 50000 {
+		t.Errorf("expect back pressure time more than 50ms but got %vus", m[9])
+	}
+	// rule stop should not be blocked
+	n.doBroadcast(3)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	n.doBroadcast(4)
+	if l := len(output); l != 1 {
+		t.Errorf("expect 1 data in the buffer but got %d", l)
+	}
+}
+
+func TestBroadcastDrop(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestBroadcastDrop")
+	ctx, cancel := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithCancel()
+	defer cancel()
+	stats, err := metric.NewStatManager(ctx, "op")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := make(chan interface{}, 1)
+	n := &defaultNode{
+		name:         "test",
+		outputs:      map[string]chan<- interface{}{"output": output},
+		ctx:          ctx,
+		statManagers: []metric.StatManager{stats},
+	}
+	n.doBroadcast(1)
+	// drop without waiting when the back pressure is not enabled
+	n.doBroadcast(2)
+	if v := <-output; v != 1 {
+		t.Errorf("expect 1 but got %v", v)
+	}
+	m := stats.GetMetrics()
+	if m[5] != int64(1) || m[8] != int64(0) {
+		t.Errorf("expect 1 exception and no back pressure but got %v and %v", m[5], m[8])
+	}
+}
@@ -18,9 +18,11 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
 	"github.com/lf-edge/ekuiper/pkg/infra"
 )
 
@@ -42,6 +44,10 @@ type UnaryOperator struct {
 	op        UnOperation
 	mutex     sync.RWMutex
 	cancelled bool
+	// autoScale adjusts the instances between the concurrency and the max concurrency if set
+	autoScale *api.AutoScale
+	// orderKeys are the keys to keep the order for the downstream, the data of the same key are processed in order
+	orderKeys ast.Dimensions
 }
 
 // NewUnary creates *UnaryOperator value
@@ -50,10 +56,11 @@ func New(name string, options *api.RuleOption) *UnaryOperator {
 		defaultSinkNode: &defaultSinkNode{
 			input: make(chan interface{}, options.BufferLength),
 			defaultNode: &defaultNode{
-				name:        name,
-				outputs:     make(map[string]chan<- interface{}),
-				concurrency: 1,
-				sendError:   options.SendError,
+				name:         name,
+				outputs:      make(map[string]chan<- interface{}),
+				concurrency:  1,
+				sendError:    options.SendError,
+				backPressure: options.BackPressure,
 			},
 		},
 	}
@@ -64,6 +71,12 @@ func (o *UnaryOperator) SetOperation(op UnOperation) {
 	o.op = op
 }
 
+// SetAutoScale enables the auto scaling of the operator. The operation must be stateless.
+func (o *UnaryOperator) SetAutoScale(autoScale *api.AutoScale, orderKeys ast.Dimensions) {
+	o.autoScale = autoScale
+	o.orderKeys = orderKeys
+}
+
 // Exec is the entry point for the executor
 func (o *UnaryOperator) Exec(ctx api.StreamContext, errCh chan<- error) {
 	o.ctx = ctx
@@ -82,6 +95,10 @@ func (o *UnaryOperator) Exec(ctx api.StreamContext, errCh chan<- error) {
 	// reset status
 	o.statManagers = nil
 
+	if o.autoScale != nil && o.autoScale.MaxConcurrency > o.concurrency {
+		newAutoScaler(o).run(ctx, errCh)
+		return
+	}
 	for i := 0; i < o.concurrency; i++ { // workers
 		instance := i
 		go func() {
@@ -97,6 +114,20 @@ func (o *UnaryOperator) Exec(ctx api.StreamContext, errCh chan<- error) {
 }
 
 func (o *UnaryOperator) doOp(ctx api.StreamContext, errCh chan<- error) {
+	stats, err := metric.NewStatManager(ctx, "op")
+	if err != nil {
+		infra.DrainError(ctx, err, errCh)
+		return
+	}
+	o.mutex.Lock()
+	o.statManagers = append(o.statManagers, stats)
+	o.mutex.Unlock()
+	o.work(ctx, o.input, true, stats, nil)
+}
+
+// work runs the operation for the data from the input until the context is done. The data from the operator input
+// need to be preprocessed. The onProcessed callback is called after each data is processed if it is not nil.
+func (o *UnaryOperator) work(ctx api.StreamContext, input <-chan interface{}, preprocess bool, stats metric.StatManager, onProcessed func()) {
 	logger := ctx.GetLogger()
 	if o.op == nil {
 		logger.Infoln("Unary operator missing operation")
@@ -109,48 +140,21 @@ func (o *UnaryOperator) doOp(ctx api.StreamContext, errCh chan<- error) {
 		cancel()
 	}()
 
-	stats, err := metric.NewStatManager(ctx, "op")
-	if err != nil {
-		infra.DrainError(ctx, err, errCh)
-		return
-	}
-	o.mutex.Lock()
-	o.statManagers = append(o.statManagers, stats)
-	o.mutex.Unlock()
 	fv, afv := xsql.NewFunctionValuersForOp(exeCtx)
 
 	for {
 		select {
 		// process incoming item
-		case item := <-o.input:
+		case item := <-input:
 			processed := false
-			if item, processed = o.preprocess(item); processed {
-				break
-			}
-			stats.IncTotalRecordsIn()
-			stats.ProcessTimeStart()
-			result := o.op.Apply(exeCtx, item, fv, afv)
-
-			switch val := result.(type) {
-			case nil:
-				continue
-			case error:
-				logger.Errorf("Operation %s error: %s", ctx.GetOpId(), val)
-				o.Broadcast(val)
-				stats.IncTotalExceptions(val.Error())
-				continue
-			case []xsql.TupleRow:
-				stats.ProcessTimeEnd()
-				for _, v := range val {
-					o.Broadcast(v)
-					stats.IncTotalRecordsOut()
+			if preprocess {
+				if item, processed = o.preprocess(item); processed {
+					break
 				}
-				stats.SetBufferLength(int64(len(o.input)))
-			default:
-				stats.ProcessTimeEnd()
-				o.Broadcast(val)
-				stats.IncTotalRecordsOut()
-				stats.SetBufferLength(int64(len(o.input)))
+			}
+			o.apply(exeCtx, item, stats, fv, afv)
+			if onProcessed != nil {
+				onProcessed()
 			}
 		// is cancelling
 		case <-ctx.Done():
@@ -163,3 +167,39 @@ func (o *UnaryOperator) doOp(ctx api.StreamContext, errCh chan<- error) {
 		}
 	}
 }
+
+func (o *UnaryOperator) apply(ctx api.StreamContext, item interface{}, stats metric.StatManager, fv *xsql.FunctionValuer, afv *xsql.AggregateFunctionValuer) {
+	stats.IncTotalRecordsIn()
+	stats.ProcessTimeStart()
+	span := startSpan(ctx, tracer.KindInternal, ctx.GetOpId())
+	result := o.op.Apply(ctx, item, fv, afv)
+	if span != nil {
+		span.SetAttribute("tuple.out", countTuples(result))
+		err, _ := result.(error)
+		endSpan(span, countTuples(item), err)
+	}
+
+	switch val := result.(type) {
+	case nil:
+		return
+	case error:
+		ctx.GetLogger().Errorf("Operation %s error: %s", ctx.GetOpId(), val)
+		if !sendDeadLetter(ctx, DeadLetterRuntime, val, item) {
+			o.Broadcast(val)
+		}
+		stats.IncTotalExceptions(val.Error())
+		return
+	case []xsql.TupleRow:
+		stats.ProcessTimeEnd()
+		for _, v := range val {
+			o.Broadcast(v)
+			stats.IncTotalRecordsOut()
+		}
+		stats.SetBufferLength(int64(len(o.input)))
+	default:
+		stats.ProcessTimeEnd()
+		o.Broadcast(val)
+		stats.IncTotalRecordsOut()
+		stats.SetBufferLength(int64(len(o.input)))
+	}
+}
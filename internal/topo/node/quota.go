@@ -0,0 +1,125 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// throttler limits the events processed per second by waiting for an even interval between the events
+type throttler struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func newThrottler(eventsPerSecond int) *throttler {
+	return &throttler{
+		interval: time.Second / time.Duration(eventsPerSecond),
+	}
+}
+
+// wait blocks until the next event is allowed. Return false if the context is done.
+func (t *throttler) wait(ctx api.StreamContext) bool {
+	now := conf.GetNow()
+	if t.next.After(now) {
+		timer := conf.GetTimerByTime(t.next)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+		now = t.next
+	}
+	t.next = now.Add(t.interval)
+	return true
+}
+
+// windowQuota limits the memory of the tuples kept by a window. To avoid scanning all the tuples for each event, the
+// memory is estimated by the count of the tuples and the moving average size of the incoming tuples.
+type windowQuota struct {
+	maxMemory int64
+	policy    string
+	avgSize   float64
+}
+
+func newWindowQuota(q *api.RuleQuota) *windowQuota {
+	if q == nil || q.MaxWindowMemory <= 0 {
+		return nil
+	}
+	return &windowQuota{
+		maxMemory: q.MaxWindowMemory,
+		policy:    q.Policy,
+	}
+}
+
+// add estimates the memory after the tuple is appended to the inputs. If the quota is exceeded, the oldest tuples are
+// dropped or an error is returned by the policy. Pausing the window input will block the triggers like the
+// watermarks, so the pauseSource policy drops the oldest tuples too.
+func (q *windowQuota) add(inputs []*xsql.Tuple, tuple *xsql.Tuple) ([]*xsql.Tuple, error) {
+	size := float64(estimateSize(tuple.Message))
+	if q.avgSize == 0 {
+		q.avgSize = size
+	} else {
+		q.avgSize = q.avgSize*0.9 + size*0.1
+	}
+	limit := int(float64(q.maxMemory) / q.avgSize)
+	if len(inputs) <= limit {
+		return inputs, nil
+	}
+	if q.policy == api.QuotaPolicyStopRule {
+		return inputs, fmt.Errorf("window state exceeds the quota maxWindowMemory %d bytes", q.maxMemory)
+	}
+	return inputs[len(inputs)-limit:], nil
+}
+
+// estimateSize returns the approximate bytes of the value
+func estimateSize(v interface{}) int64 {
+	switch vt := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(vt)) + 16
+	case []byte:
+		return int64(len(vt)) + 24
+	case map[string]interface{}:
+		var s int64 = 48
+		for k, e := range vt {
+			s += int64(len(k)) + 16 + estimateSize(e)
+		}
+		return s
+	case xsql.Message:
+		return estimateSize(map[string]interface{}(vt))
+	case []interface{}:
+		var s int64 = 24
+		for _, e := range vt {
+			s += estimateSize(e)
+		}
+		return s
+	case []map[string]interface{}:
+		var s int64 = 24
+		for _, e := range vt {
+			s += estimateSize(e)
+		}
+		return s
+	default:
+		return 16
+	}
+}
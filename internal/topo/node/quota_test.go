@@ -0,0 +1,66 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestEstimateSize(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		size int64
+	}{
+		{v: nil, size: 0},
+		{v: "abc", size: 19},
+		{v: 12.5, size: 16},
+		{v: map[string]interface{}{"a": "abc", "b": 1}, size: 48 + 17 + 19 + 17 + 16},
+		{v: []interface{}{"abc", true}, size: 24 + 19 + 16},
+	}
+	for i, tt := range tests {
+		require.Equal(t, tt.size, estimateSize(tt.v), "case %d", i)
+	}
+}
+
+func TestWindowQuota(t *testing.T) {
+	require.Nil(t, newWindowQuota(nil))
+	require.Nil(t, newWindowQuota(&api.RuleQuota{MaxBufferedTuples: 10}))
+	// each tuple is 48 + 17 + 16 = 81 bytes, so at most 4 tuples can be kept
+	q := newWindowQuota(&api.RuleQuota{MaxWindowMemory: 400, Policy: api.QuotaPolicyDropOldest})
+	var (
+		inputs []*xsql.Tuple
+		err    error
+	)
+	for i := 0; i < 6; i++ {
+		tuple := &xsql.Tuple{Message: xsql.Message{"a": i}}
+		inputs, err = q.add(append(inputs, tuple), tuple)
+		require.NoError(t, err)
+	}
+	require.Len(t, inputs, 4)
+	require.Equal(t, 2, inputs[0].Message["a"])
+
+	q = newWindowQuota(&api.RuleQuota{MaxWindowMemory: 400, Policy: api.QuotaPolicyStopRule})
+	inputs = nil
+	for i := 0; i < 5; i++ {
+		tuple := &xsql.Tuple{Message: xsql.Message{"a": i}}
+		inputs, err = q.add(append(inputs, tuple), tuple)
+	}
+	require.EqualError(t, err, "window state exceeds the quota maxWindowMemory 400 bytes")
+}
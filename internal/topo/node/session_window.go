@@ -0,0 +1,276 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+// SessionState is a session of the session window with dynamic gap. The session ends at End which is the time of
+// the last event plus its gap, capped by the max duration from the Start
+type SessionState struct {
+	Start  int64
+	End    int64
+	Tuples []*xsql.Tuple
+}
+
+const SESSIONS_KEY = "$$sessions"
+
+func init() {
+	gob.Register(map[string]*SessionState{})
+}
+
+// sessionWindow groups the events into sessions by the group by keys. Each event extends the session of its key by
+// the gap evaluated for it
+type sessionWindow struct {
+	o        *WindowOperator
+	fv       *xsql.FunctionValuer
+	sessions map[string]*SessionState
+}
+
+// add puts the tuple into the session of its key. If the tuple is after the end of the session, the session is
+// emitted and a new session is started
+func (s *sessionWindow) add(ctx api.StreamContext, tuple *xsql.Tuple) error {
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(tuple, s.fv)}
+	var key string
+	for _, d := range s.o.window.Keys {
+		r := ve.Eval(d.Expr)
+		if e, ok := r.(error); ok {
+			return fmt.Errorf("evaluate session window key %s error: %v", d.Expr, e)
+		}
+		key += fmt.Sprintf("%v,", r)
+	}
+	r := ve.Eval(s.o.window.Gap)
+	if e, ok := r.(error); ok {
+		return fmt.Errorf("evaluate session window gap %s error: %v", s.o.window.Gap, e)
+	}
+	gap, err := cast.ToInt64(r, cast.CONVERT_SAMEKIND)
+	if err != nil || gap <= 0 {
+		return fmt.Errorf("session window gap %v must be a positive integer", r)
+	}
+	gap *= int64(s.o.window.GapUnit)
+	ss, ok := s.sessions[key]
+	if ok && tuple.Timestamp >= ss.End {
+		s.emit(ctx, ss)
+		ok = false
+	}
+	if !ok {
+		ss = &SessionState{Start: tuple.Timestamp}
+		s.sessions[key] = ss
+	}
+	ss.Tuples = append(ss.Tuples, tuple)
+	if end := tuple.Timestamp + gap; end > ss.End {
+		ss.End = end
+	}
+	if s.o.window.Length > 0 && ss.End > ss.Start+int64(s.o.window.Length) {
+		ss.End = ss.Start + int64(s.o.window.Length)
+	}
+	return nil
+}
+
+// expire emits all the sessions which end before the ts
+func (s *sessionWindow) expire(ctx api.StreamContext, ts int64) {
+	var ended []string
+	for k, ss := range s.sessions {
+		if ss.End <= ts {
+			ended = append(ended, k)
+		}
+	}
+	// emit the sessions in time order
+	sort.SliceStable(ended, func(i, j int) bool {
+		return s.sessions[ended[i]].End < s.sessions[ended[j]].End
+	})
+	for _, k := range ended {
+		s.emit(ctx, s.sessions[k])
+		delete(s.sessions, k)
+	}
+}
+
+func (s *sessionWindow) nextEnd() int64 {
+	var r int64 = math.MaxInt64
+	for _, ss := range s.sessions {
+		if ss.End < r {
+			r = ss.End
+		}
+	}
+	return r
+}
+
+func (s *sessionWindow) emit(ctx api.StreamContext, ss *SessionState) {
+	results := &xsql.WindowTuples{
+		Content: make([]xsql.TupleRow, 0, len(ss.Tuples)),
+	}
+	for _, t := range ss.Tuples {
+		results = results.AddTuple(t)
+	}
+	results.WindowRange = xsql.NewWindowRange(ss.Start, ss.End)
+	ctx.GetLogger().Debugf("Sent session: %v", results)
+	s.o.Broadcast(results)
+	s.o.statManager.IncTotalRecordsOut()
+}
+
+// execSessionWindow runs the session window with dynamic gap. In processing time, the events are added once received
+// and a timer fires at the earliest session end. In event time, the events are buffered and added in time order
+// once the watermark passes them, then the sessions end before the watermark are emitted
+func (o *WindowOperator) execSessionWindow(ctx api.StreamContext, inputs []*xsql.Tuple, errCh chan<- error) {
+	log := ctx.GetLogger()
+	fv, _ := xsql.NewFunctionValuersForOp(ctx)
+	sw := &sessionWindow{o: o, fv: fv, sessions: make(map[string]*SessionState)}
+	if s, err := ctx.GetState(SESSIONS_KEY); err == nil && s != nil {
+		if st, ok := s.(map[string]*SessionState); ok {
+			sw.sessions = st
+		} else {
+			infra.DrainError(ctx, fmt.Errorf("restore window state `sessions` %v error, invalid type", s), errCh)
+			return
+		}
+	}
+	if o.isEventTime {
+		o.watermarkGenerator.lastWatermarkTs = 0
+		if s, err := ctx.GetState(WATERMARK_KEY); err == nil && s != nil {
+			if si, ok := s.(int64); ok {
+				o.watermarkGenerator.lastWatermarkTs = si
+			} else {
+				infra.DrainError(ctx, fmt.Errorf("restore window state `lastWatermarkTs` %v error, invalid type", s), errCh)
+				return
+			}
+		}
+	}
+	var (
+		timer   *clock.Timer
+		timeout <-chan time.Time
+		idleC   <-chan time.Time
+	)
+	if o.isEventTime && o.watermarkGenerator.idleTimeout > 0 {
+		idleTicker := conf.GetTicker(int(o.watermarkGenerator.idleTimeout))
+		defer idleTicker.Stop()
+		idleC = idleTicker.C
+	}
+	// reset the timer to the earliest session end for processing time
+	resetTimer := func() {
+		if o.isEventTime {
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+			timeout = nil
+		}
+		if next := sw.nextEnd(); next != math.MaxInt64 {
+			d := next - conf.GetNowInMilli()
+			if d < 0 {
+				d = 0
+			}
+			timer = conf.GetTimer(int(d))
+			timeout = timer.C
+		}
+	}
+	saveState := func() {
+		_ = ctx.PutState(WINDOW_INPUTS_KEY, inputs)
+		_ = ctx.PutState(SESSIONS_KEY, sw.sessions)
+	}
+	resetTimer()
+	for {
+		select {
+		case item, opened := <-o.input:
+			processed := false
+			if item, processed = o.preprocess(item); processed {
+				break
+			}
+			o.statManager.ProcessTimeStart()
+			if !opened {
+				o.statManager.IncTotalExceptions("input channel closed")
+				break
+			}
+			switch d := item.(type) {
+			case error:
+				o.statManager.IncTotalRecordsIn()
+				o.Broadcast(d)
+				o.statManager.IncTotalExceptions(d.Error())
+			case *xsql.Tuple:
+				o.statManager.IncTotalRecordsIn()
+				log.Debugf("Session window receive tuple %s", d.Message)
+				if o.isEventTime {
+					if o.watermarkGenerator.track(d.Emitter, d.GetTimestamp(), ctx) {
+						var err error
+						if inputs, err = o.addInput(inputs, d); err != nil {
+							infra.DrainError(ctx, err, errCh)
+							return
+						}
+					}
+				} else if err := sw.add(ctx, d); err != nil {
+					o.Broadcast(err)
+					o.statManager.IncTotalExceptions(err.Error())
+				} else {
+					resetTimer()
+				}
+				saveState()
+			case xsql.Event:
+				if d.IsWatermark() {
+					watermarkTs := d.GetTimestamp()
+					sort.SliceStable(inputs, func(i, j int) bool {
+						return inputs[i].Timestamp < inputs[j].Timestamp
+					})
+					i := 0
+					for ; i < len(inputs) && inputs[i].Timestamp <= watermarkTs; i++ {
+						// the sessions end before the event are emitted when adding it
+						sw.expire(ctx, inputs[i].Timestamp)
+						if err := sw.add(ctx, inputs[i]); err != nil {
+							o.Broadcast(err)
+							o.statManager.IncTotalExceptions(err.Error())
+						}
+					}
+					inputs = inputs[i:]
+					sw.expire(ctx, watermarkTs)
+					saveState()
+				}
+			default:
+				o.statManager.IncTotalRecordsIn()
+				e := fmt.Errorf("run Window error: expect xsql.Tuple type but got %[1]T(%[1]v)", d)
+				o.Broadcast(e)
+				o.statManager.IncTotalExceptions(e.Error())
+			}
+			o.statManager.ProcessTimeEnd()
+			o.statManager.SetBufferLength(int64(len(o.input)))
+		case now := <-timeout:
+			log.Debugf("Session window timeout at %v", now)
+			o.statManager.ProcessTimeStart()
+			sw.expire(ctx, conf.GetNowInMilli())
+			o.statManager.ProcessTimeEnd()
+			timer = nil
+			resetTimer()
+			saveState()
+		case <-idleC:
+			o.watermarkGenerator.trigger(ctx)
+		case <-ctx.Done():
+			log.Infoln("Cancelling window....")
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+	}
+}
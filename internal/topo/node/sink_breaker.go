@@ -0,0 +1,209 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+// The states of the circuit breaker
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "halfOpen"
+)
+
+type CircuitBreakerConf struct {
+	// FailureThreshold is the number of the consecutive failures to open the circuit
+	FailureThreshold int `json:"failureThreshold"`
+	// Cooldown is the duration in milliseconds to keep the circuit open before trying the sink again
+	Cooldown int `json:"cooldown"`
+	// Fallback is the sink action to receive the data when the circuit is open, such as {"log":{}}
+	Fallback map[string]interface{} `json:"fallback"`
+	// EventTopic is the memory topic to publish the state changes
+	EventTopic string `json:"eventTopic"`
+}
+
+func (c *CircuitBreakerConf) validate() error {
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = 5
+	} else if c.FailureThreshold < 0 {
+		return fmt.Errorf("circuitBreaker failureThreshold must be positive")
+	}
+	if c.Cooldown == 0 {
+		c.Cooldown = 30000
+	} else if c.Cooldown < 0 {
+		return fmt.Errorf("circuitBreaker cooldown must be positive")
+	}
+	if c.Fallback != nil && len(c.Fallback) != 1 {
+		return fmt.Errorf("circuitBreaker fallback must have exactly one sink")
+	}
+	return nil
+}
+
+// circuitBreakerSink stops calling the sink after the consecutive failures. When the circuit is open, the data are sent
+// to the fallback sink or fail immediately with an io error, so that the cache keeps them without retrying the broken
+// sink in a tight loop. After the cooldown, the circuit is half open to let one call try the sink, and it closes if the
+// call succeeds or opens again otherwise.
+type circuitBreakerSink struct {
+	api.Sink
+	conf     *CircuitBreakerConf
+	fallback api.Sink
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt int64
+	// trying is true when a call is trying the sink in the half open state
+	trying bool
+}
+
+func newCircuitBreakerSink(sink api.Sink, c *CircuitBreakerConf) (*circuitBreakerSink, error) {
+	b := &circuitBreakerSink{
+		Sink:  sink,
+		conf:  c,
+		state: CircuitClosed,
+	}
+	for name, action := range c.Fallback {
+		props, ok := action.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("circuitBreaker fallback %s properties must be a map", name)
+		}
+		s, err := getSink(name, props)
+		if err != nil {
+			return nil, fmt.Errorf("circuitBreaker fallback %s error: %v", name, err)
+		}
+		b.fallback = s
+	}
+	return b, nil
+}
+
+// open opens the fallback sink, the wrapped sink is already opened by the sink node
+func (b *circuitBreakerSink) open(ctx api.StreamContext) error {
+	if b.conf.EventTopic != "" {
+		pubsub.CreatePub(b.conf.EventTopic)
+	}
+	if b.fallback != nil {
+		return b.fallback.Open(ctx)
+	}
+	return nil
+}
+
+func (b *circuitBreakerSink) Collect(ctx api.StreamContext, data interface{}) error {
+	if !b.allow(ctx) {
+		if b.fallback != nil {
+			return b.fallback.Collect(ctx, data)
+		}
+		return fmt.Errorf("%s: circuit breaker of sink %s is open", errorx.IOErr, ctx.GetOpId())
+	}
+	err := b.Sink.Collect(ctx, data)
+	b.record(ctx, err)
+	return err
+}
+
+// Flush flushes the wrapped sink if it buffers the data
+func (b *circuitBreakerSink) Flush(ctx api.StreamContext) error {
+	if fs, ok := b.Sink.(flushableSink); ok {
+		return fs.Flush(ctx)
+	}
+	return nil
+}
+
+func (b *circuitBreakerSink) Close(ctx api.StreamContext) error {
+	if b.fallback != nil {
+		if err := b.fallback.Close(ctx); err != nil {
+			ctx.GetLogger().Warnf("close circuitBreaker fallback sink fails: %v", err)
+		}
+	}
+	if b.conf.EventTopic != "" {
+		pubsub.RemovePub(b.conf.EventTopic)
+	}
+	return b.Sink.Close(ctx)
+}
+
+// allow returns whether to call the sink. The open circuit turns half open after the cooldown.
+func (b *circuitBreakerSink) allow(ctx api.StreamContext) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if conf.GetNowInMilli()-b.openedAt < int64(b.conf.Cooldown) {
+			return false
+		}
+		b.transit(ctx, CircuitHalfOpen, nil)
+		b.trying = true
+		return true
+	case CircuitHalfOpen:
+		if b.trying {
+			return false
+		}
+		b.trying = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record updates the state by the result of the sink call
+func (b *circuitBreakerSink) record(ctx api.StreamContext, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trying = false
+	if err == nil {
+		b.failures = 0
+		if b.state != CircuitClosed {
+			b.transit(ctx, CircuitClosed, nil)
+		}
+		return
+	}
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.conf.FailureThreshold {
+		b.openedAt = conf.GetNowInMilli()
+		b.transit(ctx, CircuitOpen, err)
+	}
+}
+
+// transit changes the state and publishes the event. Must be called with the lock.
+func (b *circuitBreakerSink) transit(ctx api.StreamContext, state string, err error) {
+	from := b.state
+	b.state = state
+	if state == CircuitOpen {
+		ctx.GetLogger().Warnf("circuit breaker of sink %s instance %d opens after %d failures: %v", ctx.GetOpId(), ctx.GetInstanceId(), b.failures, err)
+	} else {
+		ctx.GetLogger().Infof("circuit breaker of sink %s instance %d changes from %s to %s", ctx.GetOpId(), ctx.GetInstanceId(), from, state)
+	}
+	if b.conf.EventTopic == "" {
+		return
+	}
+	event := map[string]interface{}{
+		"ruleId":     ctx.GetRuleId(),
+		"opId":       ctx.GetOpId(),
+		"instanceId": ctx.GetInstanceId(),
+		"from":       from,
+		"to":         state,
+		"failures":   b.failures,
+		"timestamp":  conf.GetNowInMilli(),
+	}
+	if err != nil {
+		event["error"] = err.Error()
+	}
+	pubsub.Produce(ctx, b.conf.EventTopic, event)
+}
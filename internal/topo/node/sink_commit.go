@@ -0,0 +1,166 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/topo/checkpoint"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// twoPhaseCommitKey is the state key of the pre-committed checkpoint ids which are waiting for the checkpoint completion
+const twoPhaseCommitKey = "$$twoPhaseCommit"
+
+// twoPhaseCommitter drives the two-phase commit of a sink by the checkpoints. The transaction is pre-committed when the
+// barrier arrives, and committed when the coordinator notifies the checkpoint completion. The pre-committed ids are
+// saved in the state of the sink node, so that they are committed again after restoring from the checkpoint.
+type twoPhaseCommitter struct {
+	sink api.TwoPhaseCommitSink
+	// pending are the pre-committed checkpoint ids in order
+	pending []int64
+	// completed is the latest completed checkpoint id, the pending ids no larger than it are to be committed
+	mu        sync.Mutex
+	completed int64
+	notify    chan struct{}
+}
+
+func newTwoPhaseCommitter(sink api.Sink, sconf *SinkConf, qos api.Qos, inputCount int) (*twoPhaseCommitter, error) {
+	if !sconf.ExactlyOnce {
+		return nil, nil
+	}
+	s, ok := sink.(api.TwoPhaseCommitSink)
+	if !ok {
+		return nil, fmt.Errorf("the sink does not support exactlyOnce")
+	}
+	if qos != api.ExactlyOnce {
+		return nil, fmt.Errorf("exactlyOnce sink requires the rule qos to be 2")
+	}
+	if sconf.Concurrency > 1 || sconf.EnableCache || sconf.isBatchSinkEnabled() || inputCount > 1 {
+		return nil, fmt.Errorf("exactlyOnce sink cannot be used with concurrency, cache, batch or multiple inputs")
+	}
+	if sconf.CircuitBreaker != nil {
+		return nil, fmt.Errorf("exactlyOnce sink cannot be used with circuitBreaker")
+	}
+	return &twoPhaseCommitter{
+		sink:   s,
+		notify: make(chan struct{}, 1),
+	}, nil
+}
+
+// restore commits the pre-committed transactions in the restored state. They all belong to the completed checkpoint
+// because the ids are saved in the state before the snapshot of the checkpoint.
+func (c *twoPhaseCommitter) restore(ctx api.StreamContext) error {
+	s, err := ctx.GetState(twoPhaseCommitKey)
+	if err != nil || s == nil {
+		return err
+	}
+	ids, ok := s.([]int64)
+	if !ok || len(ids) == 0 {
+		return nil
+	}
+	c.pending = ids
+	return c.commit(ctx, ids[len(ids)-1])
+}
+
+// preCommit pre-commits the transaction when the data is a barrier. It returns error if the pre-commit fails, then the
+// rule should fail so that the data of the transaction are replayed after restarting.
+func (c *twoPhaseCommitter) preCommit(ctx api.StreamContext, data interface{}) error {
+	b, ok := data.(*checkpoint.BufferOrEvent)
+	if !ok {
+		return nil
+	}
+	barrier, ok := b.Data.(*checkpoint.Barrier)
+	if !ok {
+		return nil
+	}
+	if l := len(c.pending); l > 0 && c.pending[l-1] >= barrier.CheckpointId {
+		return nil
+	}
+	if err := c.sink.PreCommit(ctx, barrier.CheckpointId); err != nil {
+		if e := c.sink.Abort(ctx, barrier.CheckpointId); e != nil {
+			ctx.GetLogger().Warnf("abort transaction %d error: %v", barrier.CheckpointId, e)
+		}
+		return fmt.Errorf("pre-commit transaction %d error: %v", barrier.CheckpointId, err)
+	}
+	c.pending = append(c.pending, barrier.CheckpointId)
+	return c.saveState(ctx)
+}
+
+// onComplete is called by the coordinator. It only records the id so that the commit is run in the sink goroutine.
+func (c *twoPhaseCommitter) onComplete(checkpointId int64) {
+	c.mu.Lock()
+	if checkpointId > c.completed {
+		c.completed = checkpointId
+	}
+	c.mu.Unlock()
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// commitCompleted commits the pending transactions which are covered by the latest completed checkpoint. The
+// transactions of the canceled checkpoints are committed with the later completed one.
+func (c *twoPhaseCommitter) commitCompleted(ctx api.StreamContext) error {
+	c.mu.Lock()
+	completed := c.completed
+	c.mu.Unlock()
+	return c.commit(ctx, completed)
+}
+
+func (c *twoPhaseCommitter) commit(ctx api.StreamContext, checkpointId int64) error {
+	n := 0
+	var err error
+	for _, id := range c.pending {
+		if id > checkpointId {
+			break
+		}
+		if err = c.sink.Commit(ctx, id); err != nil {
+			err = fmt.Errorf("commit transaction %d error: %v", id, err)
+			break
+		}
+		n++
+	}
+	if n > 0 {
+		c.pending = c.pending[n:]
+		if e := c.saveState(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// close commits the transactions completed right before stopping and aborts the others, whose data will be replayed
+// from the latest checkpoint when the rule restarts.
+func (c *twoPhaseCommitter) close(ctx api.StreamContext) {
+	logger := ctx.GetLogger()
+	if err := c.commitCompleted(ctx); err != nil {
+		logger.Warnf("sink fails to commit when closing: %v", err)
+	}
+	for _, id := range c.pending {
+		if err := c.sink.Abort(ctx, id); err != nil {
+			logger.Warnf("abort transaction %d error: %v", id, err)
+		}
+	}
+	c.pending = nil
+}
+
+func (c *twoPhaseCommitter) saveState(ctx api.StreamContext) error {
+	ids := make([]int64, len(c.pending))
+	copy(ids, c.pending)
+	return ctx.PutState(twoPhaseCommitKey, ids)
+}
@@ -22,6 +22,8 @@ import (
 	"github.com/lf-edge/ekuiper/internal/binder/io"
 	"github.com/lf-edge/ekuiper/internal/conf"
 	sinkUtil "github.com/lf-edge/ekuiper/internal/io/sink"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
+	"github.com/lf-edge/ekuiper/internal/topo/checkpoint"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
 	"github.com/lf-edge/ekuiper/internal/topo/node/cache"
 	nodeConf "github.com/lf-edge/ekuiper/internal/topo/node/conf"
@@ -43,12 +45,24 @@ type SinkConf struct {
 	Format         string   `json:"format"`
 	SchemaId       string   `json:"schemaId"`
 	Delimiter      string   `json:"delimiter"`
+	Compression    string   `json:"compression"`
+	Encryption     string   `json:"encryption"`
 	BufferLength   int      `json:"bufferLength"`
 	Fields         []string `json:"fields"`
 	DataField      string   `json:"dataField"`
 	BatchSize      int      `json:"batchSize"`
 	LingerInterval int      `json:"lingerInterval"`
+	// ExactlyOnce enables the two-phase commit of the sink which implements api.TwoPhaseCommitSink
+	ExactlyOnce bool `json:"exactlyOnce"`
+	// CircuitBreaker stops calling the sink for a cooldown after the consecutive failures
+	CircuitBreaker *CircuitBreakerConf `json:"circuitBreaker"`
+	// ExcludeFields, RenameFields and Flatten reshape the result maps before encoding
+	ExcludeFields    []string          `json:"excludeFields"`
+	RenameFields     map[string]string `json:"renameFields"`
+	Flatten          bool              `json:"flatten"`
+	FlattenSeparator string            `json:"flattenSeparator"`
 	conf.SinkConf
+	shaper *transform.Shaper
 }
 
 func (sc *SinkConf) isBatchSinkEnabled() bool {
@@ -58,6 +72,47 @@ func (sc *SinkConf) isBatchSinkEnabled() bool {
 	return false
 }
 
+// selfCompressSink handles the compression property by itself, such as compressing the whole file.
+// The sink node will not compress the payloads for it.
+type selfCompressSink interface {
+	CompressBySelf() bool
+}
+
+// selfEncryptSink handles the encryption property by itself, such as the transport encryption of the email sink
+type selfEncryptSink interface {
+	EncryptBySelf() bool
+}
+
+// withPayloadTransform compresses and then encrypts the encoded payloads unless the sink handles them by itself
+func withPayloadTransform(ctx api.StreamContext, sink api.Sink, tf transform.TransFunc, sconf *SinkConf) (api.StreamContext, error) {
+	var (
+		wrapped bool
+		err     error
+	)
+	if sconf.Compression != "" {
+		if sc, ok := sink.(selfCompressSink); !ok || !sc.CompressBySelf() {
+			tf, err = transform.WithCompression(tf, sconf.Compression)
+			if err != nil {
+				return nil, fmt.Errorf("invalid compression %s: %v", sconf.Compression, err)
+			}
+			wrapped = true
+		}
+	}
+	if sconf.Encryption != "" {
+		if se, ok := sink.(selfEncryptSink); !ok || !se.EncryptBySelf() {
+			tf, err = transform.WithEncryption(tf, sconf.Encryption)
+			if err != nil {
+				return nil, fmt.Errorf("invalid encryption %s: %v", sconf.Encryption, err)
+			}
+			wrapped = true
+		}
+	}
+	if !wrapped {
+		return ctx, nil
+	}
+	return context.WithValue(ctx.(*context.DefaultContext), context.TransKey, tf), nil
+}
+
 type SinkNode struct {
 	*defaultSinkNode
 	// static
@@ -67,7 +122,8 @@ type SinkNode struct {
 	options map[string]interface{}
 	isMock  bool
 	// states varies after restart
-	sinks []api.Sink
+	sinks     []api.Sink
+	committer *twoPhaseCommitter
 }
 
 func NewSinkNode(name string, sinkType string, props map[string]interface{}) *SinkNode {
@@ -93,7 +149,8 @@ func NewSinkNode(name string, sinkType string, props map[string]interface{}) *Si
 	}
 }
 
-// NewSinkNodeWithSink Only for mock source, do not use it in production
+// NewSinkNodeWithSink Only for mock source and the collector of the rule test, do not use it in production.
+// The sink runs in one instance.
 func NewSinkNodeWithSink(name string, sink api.Sink, props map[string]interface{}) *SinkNode {
 	return &SinkNode{
 		defaultSinkNode: &defaultSinkNode{
@@ -133,6 +190,7 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 			logger.Infof("open sink node %d instances", m.concurrency)
 			for i := 0; i < m.concurrency; i++ { // workers
 				go func(instance int) {
+					ctx := ctx
 					panicOrError := infra.SafeRun(func() error {
 						var (
 							sink api.Sink
@@ -148,13 +206,45 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 							m.mutex.Lock()
 							m.sinks = append(m.sinks, sink)
 							m.mutex.Unlock()
+						} else {
+							sink = m.sinks[instance]
+						}
+						pctx, err := withPayloadTransform(ctx, sink, tf, sconf)
+						if err != nil {
+							return err
+						}
+						ctx = pctx
+						if !m.isMock {
 							logger.Debugf("Now is to open sink for rule %s.\n", ctx.GetRuleId())
 							if err := sink.Open(ctx); err != nil {
 								return err
 							}
 							logger.Debugf("Successfully open sink for rule %s.\n", ctx.GetRuleId())
-						} else {
-							sink = m.sinks[instance]
+						}
+
+						committer, err := newTwoPhaseCommitter(sink, sconf, m.qos, m.inputCount)
+						if err != nil {
+							return err
+						}
+						var commitNotify chan struct{}
+						if committer != nil {
+							if err := committer.restore(ctx); err != nil {
+								return err
+							}
+							commitNotify = committer.notify
+							m.mutex.Lock()
+							m.committer = committer
+							m.mutex.Unlock()
+						}
+						if sconf.CircuitBreaker != nil {
+							b, err := newCircuitBreakerSink(sink, sconf.CircuitBreaker)
+							if err != nil {
+								return err
+							}
+							if err := b.open(ctx); err != nil {
+								return err
+							}
+							sink = b
 						}
 
 						stats, err := metric.NewStatManager(ctx, "sink")
@@ -179,6 +269,14 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 							for {
 								select {
 								case data := <-m.input:
+									if !flushOnBarrier(ctx, sink, data) {
+										break
+									}
+									if committer != nil {
+										if err := committer.preCommit(ctx, data); err != nil {
+											return err
+										}
+									}
 									if temp, processed := m.preprocess(data); !processed {
 										data = temp
 									} else {
@@ -189,9 +287,17 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 									err := doCollect(ctx, sink, data, sendManager, stats, sconf)
 									if err != nil {
 										logger.Warnf("sink collect error: %v", err)
+										sendDeadLetter(ctx, DeadLetterSink, err, data)
+									}
+								case <-commitNotify:
+									if err := committer.commitCompleted(ctx); err != nil {
+										return err
 									}
 								case <-ctx.Done():
 									logger.Infof("sink node %s instance %d done", m.name, instance)
+									if committer != nil {
+										committer.close(ctx)
+									}
 									if err := sink.Close(ctx); err != nil {
 										logger.Warnf("close sink node %s instance %d fails: %v", m.name, instance, err)
 									}
@@ -202,10 +308,23 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 							logger.Infof("Creating sink cache")
 							{ // sync mode, the ack is already in order
 								dataCh := make(chan []map[string]interface{}, sconf.BufferLength)
-								c := cache.NewSyncCache(ctx, dataCh, result, stats, &sconf.SinkConf, sconf.BufferLength)
+								var (
+									out <-chan []map[string]interface{}
+									ack chan<- bool
+								)
+								if sconf.StoreAndForward {
+									c := cache.NewDiskCache(ctx, dataCh, result, stats, &sconf.SinkConf, sconf.BufferLength)
+									out, ack = c.Out, c.Ack
+								} else {
+									c := cache.NewSyncCache(ctx, dataCh, result, stats, &sconf.SinkConf, sconf.BufferLength)
+									out, ack = c.Out, c.Ack
+								}
 								for {
 									select {
 									case data := <-m.input:
+										if !flushOnBarrier(ctx, sink, data) {
+											break
+										}
 										if temp, processed := m.preprocess(data); !processed {
 											data = temp
 										} else {
@@ -217,26 +336,31 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 											ctx.GetLogger().Debugf("receive empty in sink")
 											return nil
 										}
+										if sconf.shaper != nil {
+											outs = sconf.shaper.Apply(outs)
+										}
 										select {
 										case dataCh <- outs:
 										case <-ctx.Done():
 										}
-									case data := <-c.Out:
+									case data := <-out:
 										stats.ProcessTimeStart()
-										ack := true
+										isSuccess := true
 										err := doCollectMaps(ctx, sink, sconf, data, sendManager, stats)
 										// Only recoverable error should be cached
 										if err != nil {
 											if strings.HasPrefix(err.Error(), errorx.IOErr) { // do not log to prevent a lot of logs!
-												ack = false
+												isSuccess = false
+												stats.IncRetry()
 											} else {
 												ctx.GetLogger().Warnf("sink node %s instance %d publish %s error: %v", ctx.GetOpId(), ctx.GetInstanceId(), data, err)
+												sendDeadLetter(ctx, DeadLetterSink, err, data)
 											}
 										} else {
 											ctx.GetLogger().Debugf("sent data to MQTT: %v", data)
 										}
 										select {
-										case c.Ack <- ack:
+										case ack <- isSuccess:
 										case <-ctx.Done():
 										}
 										stats.ProcessTimeEnd()
@@ -264,6 +388,32 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 	}()
 }
 
+// flushableSink buffers the data and writes them in batches. The buffered data are flushed before the checkpoint
+type flushableSink interface {
+	Flush(ctx api.StreamContext) error
+}
+
+// flushOnBarrier flushes the buffered data of the sink when the checkpoint barrier arrives. It returns false if the
+// flush fails, then the barrier is dropped so that the checkpoint does not complete
+func flushOnBarrier(ctx api.StreamContext, sink api.Sink, data interface{}) bool {
+	fs, ok := sink.(flushableSink)
+	if !ok {
+		return true
+	}
+	b, ok := data.(*checkpoint.BufferOrEvent)
+	if !ok {
+		return true
+	}
+	if _, ok := b.Data.(*checkpoint.Barrier); !ok {
+		return true
+	}
+	if err := fs.Flush(ctx); err != nil {
+		ctx.GetLogger().Warnf("sink fails to flush before the checkpoint: %v", err)
+		return false
+	}
+	return true
+}
+
 func (m *SinkNode) parseConf(logger api.Logger) (*SinkConf, error) {
 	sconf := &SinkConf{
 		Concurrency:  1,
@@ -284,7 +434,7 @@ func (m *SinkNode) parseConf(logger api.Logger) (*SinkConf, error) {
 	m.concurrency = sconf.Concurrency
 	if sconf.Format == "" {
 		sconf.Format = "json"
-	} else if sconf.Format != message.FormatJson && sconf.Format != message.FormatProtobuf && sconf.Format != message.FormatBinary && sconf.Format != message.FormatCustom && sconf.Format != message.FormatDelimited {
+	} else if !message.IsFormatSupported(sconf.Format) {
 		logger.Warnf("invalid type for format property, should be json protobuf or binary but found %s", sconf.Format)
 		sconf.Format = "json"
 	}
@@ -301,6 +451,15 @@ func (m *SinkNode) parseConf(logger api.Logger) (*SinkConf, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid cache properties: %v", err)
 	}
+	if sconf.CircuitBreaker != nil {
+		if err := sconf.CircuitBreaker.validate(); err != nil {
+			return nil, err
+		}
+	}
+	sconf.shaper, err = transform.NewShaper(sconf.ExcludeFields, sconf.RenameFields, sconf.Flatten, sconf.FlattenSeparator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid renameFields: %v", err)
+	}
 	return sconf, err
 }
 
@@ -308,6 +467,7 @@ func (m *SinkNode) reset() {
 	if !m.isMock {
 		m.sinks = nil
 	}
+	m.committer = nil
 	m.statManagers = nil
 }
 
@@ -319,14 +479,21 @@ func doCollect(ctx api.StreamContext, sink api.Sink, item interface{}, sendManag
 		ctx.GetLogger().Debugf("receive empty in sink")
 		return nil
 	}
+	if sconf.shaper != nil {
+		outs = sconf.shaper.Apply(outs)
+	}
 	return doCollectMaps(ctx, sink, sconf, outs, sendManager, stats)
 }
 
-func doCollectMaps(ctx api.StreamContext, sink api.Sink, sconf *SinkConf, outs []map[string]interface{}, sendManager *sinkUtil.SendManager, stats metric.StatManager) error {
+func doCollectMaps(ctx api.StreamContext, sink api.Sink, sconf *SinkConf, outs []map[string]interface{}, sendManager *sinkUtil.SendManager, stats metric.StatManager) (err error) {
+	if span := startSpan(ctx, tracer.KindProducer, "send "+ctx.GetOpId()); span != nil {
+		defer func() {
+			endSpan(span, len(outs), err)
+		}()
+	}
 	if !sconf.SendSingle {
 		return doCollectData(ctx, sink, outs, sendManager, stats)
 	} else {
-		var err error
 		for _, d := range outs {
 			if sconf.Omitempty && (d == nil || len(d) == 0) {
 				ctx.GetLogger().Debugf("receive empty in sink")
@@ -437,6 +604,16 @@ func getSink(name string, action map[string]interface{}) (api.Sink, error) {
 	}
 }
 
+// NotifyCheckpointComplete commits the transactions of the sink if the two-phase commit is enabled
+func (m *SinkNode) NotifyCheckpointComplete(checkpointId int64) {
+	m.mutex.RLock()
+	committer := m.committer
+	m.mutex.RUnlock()
+	if committer != nil {
+		committer.onComplete(checkpointId)
+	}
+}
+
 // AddOutput Override defaultNode
 func (m *SinkNode) AddOutput(_ chan<- interface{}, name string) error {
 	return fmt.Errorf("fail to add output %s, sink %s cannot add output", name, m.name)
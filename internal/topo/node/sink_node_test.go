@@ -29,12 +29,16 @@ import (
 	"github.com/benbjohnson/clock"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
 	"github.com/lf-edge/ekuiper/internal/schema"
 	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/internal/topo/checkpoint"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
 	"github.com/lf-edge/ekuiper/internal/topo/topotest/mocknode"
 	"github.com/lf-edge/ekuiper/internal/topo/transform"
 	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
 func init() {
@@ -443,6 +447,64 @@ func TestConfig(t *testing.T) {
 				"resendInterval":       10,
 			},
 			err: errors.New("invalid cache properties: maxDiskCacheNotMultiple:maxDiskCache must be a multiple of bufferPageSize"),
+		}, {
+			config: map[string]interface{}{
+				"enableCache":     true,
+				"storeAndForward": true,
+				"maxCacheAge":     3600000,
+			},
+			sconf: &SinkConf{
+				Concurrency:  1,
+				Format:       "json",
+				BufferLength: 1024,
+				SinkConf: conf.SinkConf{
+					MemoryCacheThreshold: 1024,
+					MaxDiskCache:         1024000,
+					BufferPageSize:       256,
+					EnableCache:          true,
+					StoreAndForward:      true,
+					MaxCacheAge:          3600000,
+				},
+			},
+		}, {
+			config: map[string]interface{}{
+				"enableCache":     true,
+				"storeAndForward": true,
+				"maxCacheAge":     -1,
+			},
+			err: errors.New("invalid cache properties: maxCacheAge:maxCacheAge must be positive"),
+		}, {
+			config: map[string]interface{}{
+				"circuitBreaker": map[string]interface{}{
+					"cooldown": 1000,
+				},
+			},
+			sconf: &SinkConf{
+				Concurrency:  1,
+				Format:       "json",
+				BufferLength: 1024,
+				CircuitBreaker: &CircuitBreakerConf{
+					FailureThreshold: 5,
+					Cooldown:         1000,
+				},
+				SinkConf: conf.SinkConf{
+					MemoryCacheThreshold: 1024,
+					MaxDiskCache:         1024000,
+					BufferPageSize:       256,
+				},
+			},
+		}, {
+			config: map[string]interface{}{
+				"circuitBreaker": map[string]interface{}{
+					"fallback": map[string]interface{}{"log": map[string]interface{}{}, "nop": map[string]interface{}{}},
+				},
+			},
+			err: errors.New("circuitBreaker fallback must have exactly one sink"),
+		}, {
+			config: map[string]interface{}{
+				"renameFields": map[string]interface{}{"a": "c", "b": "c"},
+			},
+			err: errors.New("invalid renameFields: fields a and b cannot be renamed to the same name c"),
 		},
 	}
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
@@ -627,3 +689,192 @@ func TestSinkFields_Apply(t *testing.T) {
 		}
 	}
 }
+
+type mockFlushSink struct {
+	*mocknode.MockSink
+	flushed int
+	err     error
+}
+
+func (m *mockFlushSink) Flush(_ api.StreamContext) error {
+	m.flushed++
+	return m.err
+}
+
+func TestFlushOnBarrier(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestFlushOnBarrier")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	barrier := &checkpoint.BufferOrEvent{Data: &checkpoint.Barrier{CheckpointId: 1, OpId: "op1"}}
+	data := &checkpoint.BufferOrEvent{Data: map[string]interface{}{"a": 1}}
+	s := &mockFlushSink{MockSink: mocknode.NewMockSink()}
+	if !flushOnBarrier(ctx, s, data) || s.flushed != 0 {
+		t.Errorf("expect no flush for the data but flushed %d times", s.flushed)
+	}
+	if !flushOnBarrier(ctx, s, barrier) || s.flushed != 1 {
+		t.Errorf("expect flush for the barrier but flushed %d times", s.flushed)
+	}
+	s.err = errors.New("write error")
+	if flushOnBarrier(ctx, s, barrier) {
+		t.Errorf("expect the barrier is dropped if the flush fails")
+	}
+	if !flushOnBarrier(ctx, mocknode.NewMockSink(), barrier) {
+		t.Errorf("expect the barrier is processed for the sink without flush")
+	}
+}
+
+type mockTwoPhaseCommitSink struct {
+	*mocknode.MockSink
+	ops []string
+	err error
+}
+
+func (m *mockTwoPhaseCommitSink) PreCommit(_ api.StreamContext, checkpointId int64) error {
+	m.ops = append(m.ops, fmt.Sprintf("preCommit%d", checkpointId))
+	return m.err
+}
+
+func (m *mockTwoPhaseCommitSink) Commit(_ api.StreamContext, checkpointId int64) error {
+	m.ops = append(m.ops, fmt.Sprintf("commit%d", checkpointId))
+	return nil
+}
+
+func (m *mockTwoPhaseCommitSink) Abort(_ api.StreamContext, checkpointId int64) error {
+	m.ops = append(m.ops, fmt.Sprintf("abort%d", checkpointId))
+	return nil
+}
+
+func TestTwoPhaseCommitter(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestTwoPhaseCommitter")
+	store, _ := state.CreateStore("TestTwoPhaseCommitter", api.AtMostOnce)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("TestTwoPhaseCommitter", "sink1", store)
+	sconf := &SinkConf{Concurrency: 1, ExactlyOnce: true}
+	if _, err := newTwoPhaseCommitter(mocknode.NewMockSink(), sconf, api.ExactlyOnce, 1); err == nil {
+		t.Errorf("expect error for the sink without two-phase commit")
+	}
+	s := &mockTwoPhaseCommitSink{MockSink: mocknode.NewMockSink()}
+	if _, err := newTwoPhaseCommitter(s, sconf, api.AtLeastOnce, 1); err == nil {
+		t.Errorf("expect error for qos 1")
+	}
+	c, err := newTwoPhaseCommitter(s, sconf, api.ExactlyOnce, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	barrier := func(id int64) *checkpoint.BufferOrEvent {
+		return &checkpoint.BufferOrEvent{Data: &checkpoint.Barrier{CheckpointId: id, OpId: "op1"}}
+	}
+	for _, d := range []interface{}{map[string]interface{}{"a": 1}, barrier(1), barrier(1), barrier(2), barrier(3)} {
+		if err := c.preCommit(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The checkpoint 1 is canceled, so it is committed with the checkpoint 2
+	c.onComplete(2)
+	<-c.notify
+	if err := c.commitCompleted(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := ctx.GetState(twoPhaseCommitKey); !reflect.DeepEqual(v, []int64{3}) {
+		t.Errorf("state mismatch, got %v", v)
+	}
+	s.err = errors.New("write error")
+	if err := c.preCommit(ctx, barrier(4)); err == nil {
+		t.Errorf("expect error when pre-commit fails")
+	}
+	c.close(ctx)
+	exp := []string{"preCommit1", "preCommit2", "preCommit3", "commit1", "commit2", "preCommit4", "abort4", "abort3"}
+	if !reflect.DeepEqual(s.ops, exp) {
+		t.Errorf("ops mismatch, got %v", s.ops)
+	}
+	// The pending transactions in the state are committed when restoring
+	s.ops = nil
+	_ = ctx.PutState(twoPhaseCommitKey, []int64{5, 6})
+	c, _ = newTwoPhaseCommitter(s, sconf, api.ExactlyOnce, 1)
+	if err := c.restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.ops, []string{"commit5", "commit6"}) {
+		t.Errorf("restore ops mismatch, got %v", s.ops)
+	}
+}
+
+type mockFailSink struct {
+	*mocknode.MockSink
+	err   error
+	calls int
+}
+
+func (m *mockFailSink) Collect(ctx api.StreamContext, item interface{}) error {
+	m.calls++
+	if m.err != nil {
+		return m.err
+	}
+	return m.MockSink.Collect(ctx, item)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	mc := conf.Clock.(*clock.Mock)
+	conf.InitConf()
+	contextLogger := conf.Log.WithField("rule", "TestCircuitBreaker")
+	store, _ := state.CreateStore("TestCircuitBreaker", api.AtMostOnce)
+	tf, _ := transform.GenTransform("", "json", "", "", "", nil)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("TestCircuitBreaker", "sink1", store)
+	ctx = context.WithValue(ctx.(*context.DefaultContext), context.TransKey, tf)
+	events := pubsub.CreateSub("breaker", nil, "TestCircuitBreaker", 10)
+	defer pubsub.CloseSourceConsumerChannel("breaker", "TestCircuitBreaker")
+
+	s := &mockFailSink{MockSink: mocknode.NewMockSink(), err: errors.New("io error: connection refused")}
+	fallback := mocknode.NewMockSink()
+	b, err := newCircuitBreakerSink(s, &CircuitBreakerConf{FailureThreshold: 2, Cooldown: 1000, EventTopic: "breaker"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.fallback = fallback
+	if err := b.open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{"a": 1}
+	// two failures open the circuit, then the data go to the fallback without calling the sink
+	for i := 0; i < 4; i++ {
+		err := b.Collect(ctx, data)
+		if (i < 2) != (err != nil) {
+			t.Errorf("%d: unexpected error %v", i, err)
+		}
+	}
+	if s.calls != 2 || len(fallback.GetResults()) != 2 || b.state != CircuitOpen {
+		t.Errorf("expect the circuit open after 2 calls, got %d calls, %d fallback and %s", s.calls, len(fallback.GetResults()), b.state)
+	}
+	// after the cooldown, the trial fails and opens the circuit again
+	mc.Add(1000 * time.Millisecond)
+	if err := b.Collect(ctx, data); err == nil || s.calls != 3 || b.state != CircuitOpen {
+		t.Errorf("expect the failed trial opens the circuit, got %v, %d calls and %s", err, s.calls, b.state)
+	}
+	// the successful trial closes the circuit
+	mc.Add(1000 * time.Millisecond)
+	s.err = nil
+	if err := b.Collect(ctx, data); err != nil || s.calls != 4 || b.state != CircuitClosed {
+		t.Errorf("expect the successful trial closes the circuit, got %v, %d calls and %s", err, s.calls, b.state)
+	}
+	var transitions []string
+	for len(events) > 0 {
+		e := (<-events).Message()
+		transitions = append(transitions, fmt.Sprintf("%s->%s", e["from"], e["to"]))
+		if e["ruleId"] != "TestCircuitBreaker" || e["opId"] != "sink1" {
+			t.Errorf("event meta mismatch, got %v", e)
+		}
+	}
+	exp := []string{"closed->open", "open->halfOpen", "halfOpen->open", "open->halfOpen", "halfOpen->closed"}
+	if !reflect.DeepEqual(transitions, exp) {
+		t.Errorf("events mismatch, expect %v but got %v", exp, transitions)
+	}
+	// without the fallback, the data fail with io error so that the cache keeps them
+	b.fallback = nil
+	s.err = errors.New("io error: connection refused")
+	_ = b.Collect(ctx, data)
+	_ = b.Collect(ctx, data)
+	if err := b.Collect(ctx, data); err == nil || err.Error() != "io error: circuit breaker of sink sink1 is open" {
+		t.Errorf("expect circuit open error, got %v", err)
+	}
+	if err := b.Close(ctx); err != nil {
+		t.Error(err)
+	}
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/converter"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
 	nodeConf "github.com/lf-edge/ekuiper/internal/topo/node/conf"
 	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
@@ -28,6 +29,7 @@ import (
 	"github.com/lf-edge/ekuiper/pkg/ast"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 	"github.com/lf-edge/ekuiper/pkg/infra"
+	"github.com/lf-edge/ekuiper/pkg/message"
 )
 
 type SourceNode struct {
@@ -41,6 +43,9 @@ type SourceNode struct {
 	sources      []api.Source
 	preprocessOp UnOperation
 	schema       map[string]*ast.JsonStreamField
+	quota        *api.RuleQuota
+	// source replaces the source of the source type such as the fixtures of the rule test
+	source api.Source
 }
 
 func NewSourceNode(name string, st ast.StreamType, op UnOperation, options *ast.Options, sendError bool, schema map[string]*ast.JsonStreamField) *SourceNode {
@@ -67,8 +72,34 @@ func NewSourceNode(name string, st ast.StreamType, op UnOperation, options *ast.
 	}
 }
 
+// SetQuota limits the buffer and the processing rate of each source instance by the rule quota
+func (m *SourceNode) SetQuota(quota *api.RuleQuota) {
+	m.quota = quota
+}
+
+// SetBackPressure sets whether to wait for the downstream when its buffer is full. The source connector can also pause
+// receiving by the back pressure recorder in the context.
+func (m *SourceNode) SetBackPressure(backPressure bool) {
+	m.backPressure = backPressure
+}
+
+// SetSource replaces the source connector of the stream. The source is never shared and runs in one instance.
+func (m *SourceNode) SetSource(source api.Source) {
+	m.source = source
+}
+
 const OffsetKey = "$$offset"
 
+// batchTableSource is a table source which sends the whole table in each batch ended by an EOF tuple
+type batchTableSource interface {
+	IsBatchTable() bool
+}
+
+// batchInputOperation is the table processor which can fire by batches instead of retaining the latest rows
+type batchInputOperation interface {
+	SetBatchInput()
+}
+
 func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 	m.ctx = ctx
 	logger := ctx.GetLogger()
@@ -84,6 +115,9 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 					m.concurrency = t
 				}
 			}
+			if m.source != nil {
+				m.concurrency = 1
+			}
 			bl := 102400
 			if c, ok := props["bufferLength"]; ok {
 				if t, err := cast.ToInt(c, cast.STRICT); err != nil || t <= 0 {
@@ -92,6 +126,9 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 					bl = t
 				}
 			}
+			if m.quota != nil && m.quota.MaxBufferedTuples > 0 && m.quota.MaxBufferedTuples < bl {
+				bl = m.quota.MaxBufferedTuples
+			}
 			m.bufferLength = bl
 			if m.streamType == ast.TypeTable {
 				props["isTable"] = true
@@ -107,6 +144,22 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 				logger.Warnf(msg)
 				return fmt.Errorf(msg)
 			}
+			if pc, ok := converterTool.(message.PropsConfigurer); ok {
+				if err := pc.Configure(props); err != nil {
+					return fmt.Errorf("invalid properties of format %s: %v", m.options.FORMAT, err)
+				}
+			}
+			converterTool, err = converter.WithDecompression(converterTool, m.options.DECOMPRESSION)
+			if err != nil {
+				return fmt.Errorf("invalid decompression %s: %v", m.options.DECOMPRESSION, err)
+			}
+			converterTool, err = converter.WithDecryption(converterTool, m.options.DECRYPTION)
+			if err != nil {
+				return fmt.Errorf("invalid decryption %s: %v", m.options.DECRYPTION, err)
+			}
+			if tracer.Enabled() {
+				converterTool = &tracedConverter{Converter: converterTool, ctx: ctx}
+			}
 			ctx = context.WithValue(ctx.(*context.DefaultContext), context.DecodeKey, converterTool)
 			m.reset()
 			logger.Infof("open source node with props %v, concurrency: %d, bufferLength: %d", conf.Printable(m.props), m.concurrency, m.bufferLength)
@@ -128,14 +181,28 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 						m.statManagers = append(m.statManagers, stats)
 						m.mutex.Unlock()
 
-						si, err = getSourceInstance(m, instance)
+						si, err = getSourceInstance(m, instance, stats)
 						if err != nil {
 							return err
 						}
 						m.mutex.Lock()
 						m.sources = append(m.sources, si.source)
+						if m.streamType == ast.TypeTable && m.options.RETAIN_SIZE == 0 {
+							if bs, ok := si.source.(batchTableSource); ok && bs.IsBatchTable() {
+								if bp, ok := m.preprocessOp.(batchInputOperation); ok {
+									bp.SetBatchInput()
+								}
+							}
+						}
 						m.mutex.Unlock()
 						buffer = si.dataCh
+						var throttle *throttler
+						if m.quota != nil {
+							buffer.SetPolicy(m.quota.Policy)
+							if m.quota.MaxEventsPerSecond > 0 {
+								throttle = newThrottler(m.quota.MaxEventsPerSecond)
+							}
+						}
 
 						defer func() {
 							logger.Infof("source %s done", m.name)
@@ -156,10 +223,20 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 								return nil
 							case err := <-si.errorCh:
 								return err
+							case <-buffer.Overflow:
+								return fmt.Errorf("source %s buffer exceeds the quota maxBufferedTuples %d", m.name, m.bufferLength)
 							case data := <-buffer.Out:
+								if throttle != nil && !throttle.wait(ctx) {
+									m.schema = nil
+									return nil
+								}
 								if t, ok := data.(*xsql.ErrorSourceTuple); ok {
 									logger.Errorf("Source %s error: %v", ctx.GetOpId(), t.Error)
 									stats.IncTotalExceptions(t.Error.Error())
+									// Only the decode errors have the payload, the other errors such as the connection errors are not data
+									if t.Payload != nil {
+										sendDeadLetter(ctx, DeadLetterDecode, t.Error, t.Payload)
+									}
 									continue
 								}
 								stats.IncTotalRecordsIn()
@@ -182,7 +259,9 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 									continue
 								case error:
 									logger.Errorf("Source %s preprocess error: %s", ctx.GetOpId(), val)
-									m.Broadcast(val)
+									if !sendDeadLetter(ctx, DeadLetterDecode, val, tuple) {
+										m.Broadcast(val)
+									}
 									stats.IncTotalExceptions(val.Error())
 								default:
 									m.Broadcast(val)
@@ -221,7 +300,7 @@ func (m *SourceNode) reset() {
 }
 
 func (m *SourceNode) close() {
-	if m.options.SHARED {
+	if m.options.SHARED && m.source == nil {
 		removeSourceInstance(m)
 	}
 }
@@ -23,6 +23,7 @@ import (
 	"github.com/lf-edge/ekuiper/internal/binder/io"
 	"github.com/lf-edge/ekuiper/internal/conf"
 	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/topo/state"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/infra"
@@ -34,10 +35,10 @@ var pool = &sourcePool{
 	registry: make(map[string]*sourceSingleton),
 }
 
-// node is readonly
-func getSourceInstance(node *SourceNode, index int) (*sourceInstance, error) {
+// node is readonly. The stats records the back pressure of the rule specific instance.
+func getSourceInstance(node *SourceNode, index int, stats metric.StatManager) (*sourceInstance, error) {
 	var si *sourceInstance
-	if node.options.SHARED {
+	if node.options.SHARED && node.source == nil {
 		rkey := fmt.Sprintf("%s.%s", node.sourceType, node.name)
 		s, ok := pool.load(rkey)
 		if !ok {
@@ -67,7 +68,13 @@ func getSourceInstance(node *SourceNode, index int) (*sourceInstance, error) {
 			sourceInstanceChannels: s.outputs[instanceKey],
 		}
 	} else {
-		ns, err := io.Source(node.sourceType)
+		var (
+			ns  = node.source
+			err error
+		)
+		if ns == nil {
+			ns, err = io.Source(node.sourceType)
+		}
 		if ns != nil {
 			si, err = start(nil, node, ns)
 			if err != nil {
@@ -76,6 +83,9 @@ func getSourceInstance(node *SourceNode, index int) (*sourceInstance, error) {
 			go func() {
 				err := infra.SafeRun(func() error {
 					nctx := node.ctx.WithInstance(index)
+					if node.backPressure && stats != nil {
+						nctx = kctx.WithValue(nctx.(*kctx.DefaultContext), kctx.BackPressureKey, stats)
+					}
 					defer si.source.Close(nctx)
 					si.source.Open(nctx, si.dataCh.In, si.errorCh)
 					return nil
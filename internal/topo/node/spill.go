@@ -0,0 +1,141 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+)
+
+func init() {
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// tupleSpill keeps the older tuples of a window in a file when the tuples in memory exceed the threshold. The tuples
+// are spilled in batches, each batch is a length prefixed gob encoded tuple slice. The spilled tuples are always older
+// than the ones in memory, so they are put before the tuples in memory when loading back.
+type tupleSpill struct {
+	path      string
+	threshold int
+	file      *os.File
+	// size is the bytes of the file and count is the spilled tuples
+	size  int64
+	count int
+}
+
+// newTupleSpill creates the spill file of the operator. The spill is not part of the checkpoint, so the file of the
+// last run is truncated.
+func newTupleSpill(ruleId, name string, threshold int) (*tupleSpill, error) {
+	dataDir, err := conf.GetDataLoc()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(dataDir, "spill", ruleId)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create spill dir %s error: %v", dir, err)
+	}
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open spill file %s error: %v", path, err)
+	}
+	return &tupleSpill{
+		path:      path,
+		threshold: threshold,
+		file:      f,
+	}, nil
+}
+
+// spill writes the oldest tuples to the file if the tuples exceed the threshold. Half of the threshold tuples are kept
+// in memory so that the tuples are written in batches.
+func (s *tupleSpill) spill(inputs []*xsql.Tuple) ([]*xsql.Tuple, error) {
+	if len(inputs) <= s.threshold {
+		return inputs, nil
+	}
+	n := len(inputs) - s.threshold/2
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4))
+	if err := gob.NewEncoder(&buf).Encode(inputs[:n]); err != nil {
+		return inputs, fmt.Errorf("encode spilled tuples error: %v", err)
+	}
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	if _, err := s.file.WriteAt(b, s.size); err != nil {
+		// drop the partial batch
+		_ = s.file.Truncate(s.size)
+		return inputs, fmt.Errorf("write spill file %s error: %v", s.path, err)
+	}
+	s.size += int64(len(b))
+	s.count += n
+	// copy to release the memory of the spilled tuples
+	rest := make([]*xsql.Tuple, len(inputs)-n, s.threshold+1)
+	copy(rest, inputs[n:])
+	return rest, nil
+}
+
+// load reads all the spilled tuples back and puts them before the tuples in memory. The file is truncated after
+// loading, so the tuples are returned even if failing to read a part of them.
+func (s *tupleSpill) load(inputs []*xsql.Tuple) ([]*xsql.Tuple, error) {
+	if s.count == 0 {
+		return inputs, nil
+	}
+	result := make([]*xsql.Tuple, 0, s.count+len(inputs))
+	err := s.read(func(batch []*xsql.Tuple) {
+		result = append(result, batch...)
+	})
+	s.size = 0
+	s.count = 0
+	if e := s.file.Truncate(0); e != nil && err == nil {
+		err = fmt.Errorf("truncate spill file %s error: %v", s.path, e)
+	}
+	return append(result, inputs...), err
+}
+
+func (s *tupleSpill) read(f func(batch []*xsql.Tuple)) error {
+	r := bufio.NewReader(io.NewSectionReader(s.file, 0, s.size))
+	l := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, l); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("read spill file %s error: %v", s.path, err)
+		}
+		b := make([]byte, binary.BigEndian.Uint32(l))
+		if _, err := io.ReadFull(r, b); err != nil {
+			return fmt.Errorf("read spill file %s error: %v", s.path, err)
+		}
+		var batch []*xsql.Tuple
+		if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&batch); err != nil {
+			return fmt.Errorf("decode spilled tuples error: %v", err)
+		}
+		f(batch)
+	}
+}
+
+// close removes the spill file, the spilled tuples are dropped
+func (s *tupleSpill) close() error {
+	_ = s.file.Close()
+	return os.Remove(s.path)
+}
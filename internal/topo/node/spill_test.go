@@ -0,0 +1,57 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+)
+
+func TestTupleSpill(t *testing.T) {
+	conf.InitConf()
+	s, err := newTupleSpill("spillRule", "test_window", 4)
+	require.NoError(t, err)
+	var inputs []*xsql.Tuple
+	for i := 0; i < 11; i++ {
+		inputs = append(inputs, &xsql.Tuple{Emitter: "demo", Message: xsql.Message{"a": i, "b": map[string]interface{}{"c": "d"}}, Timestamp: int64(i)})
+		inputs, err = s.spill(inputs)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(inputs), 4)
+	}
+	require.Equal(t, 9, s.count)
+	inputs, err = s.load(inputs)
+	require.NoError(t, err)
+	require.Len(t, inputs, 11)
+	for i, tuple := range inputs {
+		require.Equal(t, "demo", tuple.Emitter)
+		require.Equal(t, i, tuple.Message["a"])
+		require.Equal(t, map[string]interface{}{"c": "d"}, tuple.Message["b"])
+		require.Equal(t, int64(i), tuple.Timestamp)
+	}
+	// nothing left after loading
+	require.Equal(t, 0, s.count)
+	inputs, err = s.load(inputs[:1])
+	require.NoError(t, err)
+	require.Len(t, inputs, 1)
+
+	require.NoError(t, s.close())
+	_, err = os.Stat(s.path)
+	require.True(t, os.IsNotExist(err))
+}
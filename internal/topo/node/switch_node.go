@@ -60,17 +60,19 @@ func NewSwitchNode(name string, conf *SwitchConfig, options *api.RuleOption) (*S
 	sn.defaultSinkNode = &defaultSinkNode{
 		input: make(chan interface{}, options.BufferLength),
 		defaultNode: &defaultNode{
-			outputs:   nil,
-			name:      name,
-			sendError: options.SendError,
+			outputs:      nil,
+			name:         name,
+			sendError:    options.SendError,
+			backPressure: options.BackPressure,
 		},
 	}
 	outputs := make([]defaultNode, len(conf.Cases))
 	for i := range conf.Cases {
 		outputs[i] = defaultNode{
-			outputs:   make(map[string]chan<- interface{}),
-			name:      name + fmt.Sprintf("_%d", i),
-			sendError: options.SendError,
+			outputs:      make(map[string]chan<- interface{}),
+			name:         name + fmt.Sprintf("_%d", i),
+			sendError:    options.SendError,
+			backPressure: options.BackPressure,
 		}
 	}
 	sn.outputNodes = outputs
@@ -85,9 +87,11 @@ func (n *SwitchNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 		return
 	}
 	n.statManager = stats
+	n.statManagers = []metric.StatManager{stats}
 	n.ctx = ctx
 	for i := range n.outputNodes {
 		n.outputNodes[i].ctx = ctx
+		n.outputNodes[i].statManagers = n.statManagers
 	}
 	fv, afv := xsql.NewFunctionValuersForOp(ctx)
 	go func() {
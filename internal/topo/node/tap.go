@@ -0,0 +1,140 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/topo/checkpoint"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+)
+
+// Tap receives the sampled outputs of a running node for debugging. The outputs are encoded as json when they are
+// sampled, so the rule can continue to modify them. The tap never blocks the rule, the outputs are dropped if the
+// receiver is slow or exceeding the rate.
+type Tap struct {
+	ch       chan []byte
+	sample   float64
+	interval time.Duration
+	mu       sync.Mutex
+	last     time.Time
+	dropped  atomic.Int64
+}
+
+// NewTap creates a tap which samples the outputs by the ratio in (0, 1] and sends at most rate outputs per second
+func NewTap(sample float64, rate int, bufferLength int) *Tap {
+	t := &Tap{
+		ch:     make(chan []byte, bufferLength),
+		sample: sample,
+	}
+	if rate > 0 {
+		t.interval = time.Second / time.Duration(rate)
+	}
+	return t
+}
+
+// Data returns the channel of the json encoded outputs
+func (t *Tap) Data() <-chan []byte {
+	return t.ch
+}
+
+// Dropped returns the number of the outputs dropped by the rate limit or the full buffer
+func (t *Tap) Dropped() int64 {
+	return t.dropped.Load()
+}
+
+func (t *Tap) offer(val interface{}) {
+	if t.sample < 1 && rand.Float64() >= t.sample {
+		return
+	}
+	if t.interval > 0 {
+		t.mu.Lock()
+		now := time.Now()
+		if now.Sub(t.last) < t.interval {
+			t.mu.Unlock()
+			t.dropped.Add(1)
+			return
+		}
+		t.last = now
+		t.mu.Unlock()
+	}
+	var v interface{}
+	switch vt := val.(type) {
+	case xsql.Collection:
+		v = vt.Clone().ToMaps()
+	case xsql.TupleRow:
+		v = vt.Clone().ToMap()
+	case error:
+		v = map[string]interface{}{"error": vt.Error()}
+	default: // the control signals such as the barriers are not data
+		return
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(map[string]interface{}{"error": err.Error()})
+	}
+	select {
+	case t.ch <- b:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// tapSet is the taps of a node. The zero value is ready to use.
+type tapSet struct {
+	mu   sync.RWMutex
+	taps []*Tap
+	// the count to check if tapped without locking in the hot path
+	n atomic.Int32
+}
+
+// AddTap starts to send the outputs of the node to the tap
+func (o *defaultNode) AddTap(t *Tap) {
+	o.taps.mu.Lock()
+	defer o.taps.mu.Unlock()
+	o.taps.taps = append(o.taps.taps, t)
+	o.taps.n.Store(int32(len(o.taps.taps)))
+}
+
+// RemoveTap stops sending the outputs to the tap
+func (o *defaultNode) RemoveTap(t *Tap) {
+	o.taps.mu.Lock()
+	defer o.taps.mu.Unlock()
+	for i, tt := range o.taps.taps {
+		if tt == t {
+			o.taps.taps = append(o.taps.taps[:i:i], o.taps.taps[i+1:]...)
+			break
+		}
+	}
+	o.taps.n.Store(int32(len(o.taps.taps)))
+}
+
+func (o *defaultNode) tap(val interface{}) {
+	if o.taps.n.Load() == 0 {
+		return
+	}
+	if boe, ok := val.(*checkpoint.BufferOrEvent); ok {
+		val = boe.Data
+	}
+	o.taps.mu.RLock()
+	defer o.taps.mu.RUnlock()
+	for _, t := range o.taps.taps {
+		t.offer(val)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/topo/checkpoint"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+)
+
+func TestTap(t *testing.T) {
+	n := &defaultNode{name: "test", outputs: make(map[string]chan<- interface{})}
+	tap := NewTap(1, 0, 10)
+	n.AddTap(tap)
+	n.Broadcast(&xsql.Tuple{Message: map[string]interface{}{"a": 1}})
+	n.Broadcast(&checkpoint.BufferOrEvent{Data: &xsql.Tuple{Message: map[string]interface{}{"a": 2}}})
+	n.Broadcast(&checkpoint.BufferOrEvent{Data: &checkpoint.Barrier{CheckpointId: 1, OpId: "op1"}})
+	n.Broadcast(&xsql.WindowTuples{Content: []xsql.TupleRow{&xsql.Tuple{Message: map[string]interface{}{"a": 3}}}})
+	for i, exp := range []string{`{"a":1}`, `{"a":2}`, `[{"a":3}]`} {
+		select {
+		case b := <-tap.Data():
+			if string(b) != exp {
+				t.Errorf("%d tap output mismatch, exp %s but got %s", i, exp, b)
+			}
+		default:
+			t.Fatalf("%d tap output not received", i)
+		}
+	}
+	if len(tap.Data()) != 0 {
+		t.Errorf("the barrier should not be tapped")
+	}
+	// rate limit to 1 per second
+	limited := NewTap(1, 1, 10)
+	n.AddTap(limited)
+	n.Broadcast(&xsql.Tuple{Message: map[string]interface{}{"a": 4}})
+	n.Broadcast(&xsql.Tuple{Message: map[string]interface{}{"a": 5}})
+	if len(limited.Data()) != 1 || limited.Dropped() != 1 {
+		t.Errorf("rate limit mismatch, got %d outputs and %d dropped", len(limited.Data()), limited.Dropped())
+	}
+	if len(tap.Data()) != 2 {
+		t.Errorf("expect 2 outputs of the unlimited tap but got %d", len(tap.Data()))
+	}
+	n.RemoveTap(tap)
+	n.RemoveTap(limited)
+	n.Broadcast(&xsql.Tuple{Message: map[string]interface{}{"a": 6}})
+	if len(tap.Data()) != 2 {
+		t.Errorf("removed tap should not receive outputs")
+	}
+}
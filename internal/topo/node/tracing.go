@@ -0,0 +1,82 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/message"
+)
+
+// startSpan records the span of the node operation with the rule and node attributes. It returns nil if the tracer
+// is disabled or the operation is not sampled.
+func startSpan(ctx api.StreamContext, kind int, name string) *tracer.Span {
+	s := tracer.Start(kind, name)
+	if s != nil {
+		s.SetAttribute("rule.id", ctx.GetRuleId())
+		s.SetAttribute("op.id", ctx.GetOpId())
+		s.SetAttribute("op.instance", ctx.GetInstanceId())
+	}
+	return s
+}
+
+// endSpan ends the span with the count of the input tuples and the error if any
+func endSpan(s *tracer.Span, count int, err error) {
+	if s == nil {
+		return
+	}
+	s.SetAttribute("tuple.count", count)
+	s.SetError(err)
+	s.End()
+}
+
+// countTuples returns the number of the tuples in the data flowing between the nodes
+func countTuples(data interface{}) int {
+	switch d := data.(type) {
+	case nil, error:
+		return 0
+	case xsql.Collection:
+		return d.Len()
+	case []xsql.TupleRow:
+		return len(d)
+	case []map[string]interface{}:
+		return len(d)
+	case []interface{}:
+		return len(d)
+	default:
+		return 1
+	}
+}
+
+// tracedConverter records the span of decoding each payload of the source
+type tracedConverter struct {
+	message.Converter
+	ctx api.StreamContext
+}
+
+func (c *tracedConverter) Decode(b []byte) (interface{}, error) {
+	s := startSpan(c.ctx, tracer.KindConsumer, "decode "+c.ctx.GetOpId())
+	r, err := c.Converter.Decode(b)
+	if s != nil {
+		s.SetAttribute("payload.bytes", len(b))
+		if pe, ok := err.(*message.PartialDecodeError); ok {
+			endSpan(s, countTuples(pe.Result), err)
+		} else {
+			endSpan(s, countTuples(r), err)
+		}
+	}
+	return r, err
+}
@@ -19,7 +19,10 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"time"
 
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/ast"
@@ -46,17 +49,25 @@ type WatermarkGenerator struct {
 	window        *WindowConfig
 	lateTolerance int64
 	interval      int
+	// idleTimeout is the processing time in ms after which a topic without events is excluded from the watermark
+	idleTimeout int64
+	// the last processing time of each topic to detect idle topics
+	topicToActive map[string]int64
+	startTime     int64
 	// ticker          *clock.Ticker
 	stream chan<- interface{}
 	// state
 	lastWatermarkTs int64
 }
 
-func NewWatermarkGenerator(window *WindowConfig, l int64, s []string, stream chan<- interface{}) (*WatermarkGenerator, error) {
+func NewWatermarkGenerator(window *WindowConfig, l int64, idle int64, s []string, stream chan<- interface{}) (*WatermarkGenerator, error) {
 	w := &WatermarkGenerator{
 		window:        window,
 		topicToTs:     make(map[string]int64),
 		lateTolerance: l,
+		idleTimeout:   idle,
+		topicToActive: make(map[string]int64),
+		startTime:     conf.GetNowInMilli(),
 		inputTopics:   s,
 		stream:        stream,
 	}
@@ -84,6 +95,9 @@ func (w *WatermarkGenerator) track(s string, ts int64, ctx api.StreamContext) bo
 	if !ok || ts > currentVal {
 		w.topicToTs[s] = ts
 	}
+	if w.idleTimeout > 0 {
+		w.topicToActive[s] = conf.GetNowInMilli()
+	}
 	r := ts >= w.lastWatermarkTs
 	if r {
 		w.trigger(ctx)
@@ -108,6 +122,9 @@ func (w *WatermarkGenerator) trigger(ctx api.StreamContext) {
 }
 
 func (w *WatermarkGenerator) computeWatermarkTs(_ context.Context) int64 {
+	if w.idleTimeout > 0 {
+		return w.computeWatermarkTsWithIdle()
+	}
 	var ts int64
 	if len(w.topicToTs) >= len(w.inputTopics) {
 		ts = math.MaxInt64
@@ -120,18 +137,52 @@ func (w *WatermarkGenerator) computeWatermarkTs(_ context.Context) int64 {
 	return ts - w.lateTolerance
 }
 
+// computeWatermarkTsWithIdle excludes the idle topics so that they won't stall the watermark.
+// If all topics are idle, the watermark is the max event time to flush the windows
+func (w *WatermarkGenerator) computeWatermarkTsWithIdle() int64 {
+	now := conf.GetNowInMilli()
+	var (
+		ts    int64 = math.MaxInt64
+		maxTs int64
+	)
+	for _, key := range w.inputTopics {
+		// an active topic without any event yet stalls the watermark by 0
+		t := w.topicToTs[key]
+		if t > maxTs {
+			maxTs = t
+		}
+		active, seen := w.topicToActive[key]
+		if !seen {
+			active = w.startTime
+		}
+		if now-active >= w.idleTimeout {
+			continue
+		}
+		if t < ts {
+			ts = t
+		}
+	}
+	if ts == math.MaxInt64 {
+		ts = maxTs
+	}
+	return ts - w.lateTolerance
+}
+
 // If window end cannot be determined yet, return max int64 so that it can be recalculated for the next watermark
 func (w *WatermarkGenerator) getNextWindow(inputs []*xsql.Tuple, current int64, watermark int64) int64 {
 	switch w.window.Type {
 	case ast.TUMBLING_WINDOW, ast.HOPPING_WINDOW:
 		if current > 0 {
-			return current + int64(w.interval)
+			return nextWindowEnd(w.window, current, w.interval)
 		} else { // first run without previous window
 			interval := int64(w.interval)
 			nextTs := getEarliestEventTs(inputs, current, watermark)
 			if nextTs == math.MaxInt64 {
 				return nextTs
 			}
+			if w.window.location != nil {
+				return getCalendarWindowEndTime(nextTs, w.window).UnixMilli()
+			}
 			return getAlignedWindowEndTime(nextTs, interval).UnixMilli()
 		}
 	case ast.SLIDING_WINDOW:
@@ -193,8 +244,33 @@ func (o *WindowOperator) execEventWindow(ctx api.StreamContext, inputs []*xsql.T
 		}
 	}
 	log.Infof("Start with window state lastWatermarkTs: %d", o.watermarkGenerator.lastWatermarkTs)
+	var late *lateHandler
+	if o.window.AllowedLateness > 0 || o.window.SideOutput != "" {
+		late = newLateHandler(o)
+		if s, err := ctx.GetState(LATE_RETAINED_KEY); err == nil && s != nil {
+			if st, ok := s.([]*xsql.Tuple); ok {
+				late.retained = st
+			} else {
+				infra.DrainError(ctx, fmt.Errorf("restore window state `lateRetained` %v error, invalid type", s), errCh)
+				return
+			}
+		}
+		if late.topic != "" {
+			pubsub.CreatePub(late.topic)
+			defer pubsub.RemovePub(late.topic)
+		}
+	}
+	// recompute the watermark periodically to exclude the idle topics even if no event comes
+	var idleC <-chan time.Time
+	if o.watermarkGenerator.idleTimeout > 0 {
+		idleTicker := conf.GetTicker(int(o.watermarkGenerator.idleTimeout))
+		defer idleTicker.Stop()
+		idleC = idleTicker.C
+	}
 	for {
 		select {
+		case <-idleC:
+			o.watermarkGenerator.trigger(ctx)
 		// process incoming item
 		case item, opened := <-o.input:
 			processed := false
@@ -251,12 +327,25 @@ func (o *WindowOperator) execEventWindow(ctx api.StreamContext, inputs []*xsql.T
 						log.Debugf("receive non tuple element %v", d)
 					}
 					log.Debugf("event window receive tuple %s", tuple.Message)
+					var err error
 					if o.watermarkGenerator.track(tuple.Emitter, d.GetTimestamp(), ctx) {
-						inputs = append(inputs, tuple)
+						inputs, err = o.addInput(inputs, tuple)
+						if late != nil {
+							late.retain(tuple, o.watermarkGenerator.lastWatermarkTs)
+						}
+					} else if late != nil && late.handle(ctx, tuple, prevWindowEndTs, o.watermarkGenerator.lastWatermarkTs) {
+						inputs, err = o.addInput(inputs, tuple)
+					}
+					if err != nil {
+						infra.DrainError(ctx, err, errCh)
+						return
 					}
 				}
 				o.statManager.ProcessTimeEnd()
 				ctx.PutState(WINDOW_INPUTS_KEY, inputs)
+				if late != nil && late.lateness > 0 {
+					ctx.PutState(LATE_RETAINED_KEY, late.retained)
+				}
 			default:
 				o.statManager.IncTotalRecordsIn()
 				e := fmt.Errorf("run Window error: expect xsql.Event type but got %[1]T(%[1]v)", d)
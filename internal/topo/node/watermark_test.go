@@ -0,0 +1,76 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestWatermarkIdleTimeout(t *testing.T) {
+	mc := conf.Clock.(*clock.Mock)
+	base := conf.GetNowInMilli()
+	w, err := NewWatermarkGenerator(&WindowConfig{Type: ast.TUMBLING_WINDOW, Length: 1000}, 10, 1000, []string{"s1", "s2"}, make(chan interface{}, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// set the event time and the processing time of the latest event of a topic
+	event := func(topic string, ts int64, at int64) {
+		w.topicToTs[topic] = ts
+		w.topicToActive[topic] = base + at
+	}
+	tests := []struct {
+		now    int64
+		events func()
+		result int64
+	}{
+		{ // 0: s2 is not idle yet
+			now:    0,
+			events: func() { event("s1", 100, 0) },
+			result: -10,
+		}, { // 1: s2 is idle since started
+			now:    1500,
+			events: func() { event("s1", 200, 1500) },
+			result: 190,
+		}, { // 2: s2 is active again
+			now:    1600,
+			events: func() { event("s2", 150, 1600) },
+			result: 140,
+		}, { // 3: s1 is idle
+			now:    2600,
+			events: func() { event("s2", 300, 2600) },
+			result: 290,
+		}, { // 4: all topics are idle
+			now:    5000,
+			events: func() { event("s1", 400, 2500) },
+			result: 390,
+		},
+	}
+	for i, tt := range tests {
+		if d := base + tt.now - conf.GetNowInMilli(); d > 0 {
+			mc.Add(time.Duration(d) * time.Millisecond)
+		}
+		tt.events()
+		if r := w.computeWatermarkTs(context.Background()); r != tt.result {
+			t.Errorf("%d: expect watermark %d but got %d", i, tt.result, r)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// incAggWindow keeps the aggregate states of the tuples in a processing time hopping or sliding window. In processing
+// time, the tuples arrive in the order of the timestamp, so the expired tuples are always the oldest ones and the
+// tuples later than the trigger time are always the newest ones. The states are updated by adding the new tuples and
+// evicting the expired ones so that the aggregates are not calculated from all the tuples for each trigger.
+//
+// The supported aggregates are count, sum, avg, min and max. The results are set to the cached fields of the calls in
+// the window tuples or each group, and the downstream operators read them instead of calculating again.
+type incAggWindow struct {
+	calls []*ast.Call
+	keys  ast.Dimensions
+	fv    *xsql.FunctionValuer
+	afv   *xsql.AggregateFunctionValuer
+	// entries are aligned with the window inputs. The first accumulated entries are added to the states.
+	entries     []*incAggEntry
+	accumulated int
+	groups      map[string]*incAggGroup
+	// invalid is the count of the accumulated entries failing to evaluate the group key
+	invalid int
+	seq     int64
+	// ordered is false if any tuple arrives earlier than the previous one, then the aggregates are calculated from
+	// scratch until the window is in order again
+	ordered bool
+}
+
+// incAggEntry is a tuple in the window with the evaluated aggregate arguments and group key
+type incAggEntry struct {
+	tuple *xsql.Tuple
+	seq   int64
+	args  []interface{}
+	key   string
+	err   error
+	group *incAggGroup
+}
+
+type incAggGroup struct {
+	key    string
+	size   int
+	states []*incAggState
+}
+
+func newIncAggWindow(calls []*ast.Call, keys ast.Dimensions) *incAggWindow {
+	return &incAggWindow{
+		calls:   calls,
+		keys:    keys,
+		groups:  make(map[string]*incAggGroup),
+		ordered: true,
+	}
+}
+
+func (a *incAggWindow) newEntry(tuple *xsql.Tuple) *incAggEntry {
+	a.seq++
+	e := &incAggEntry{
+		tuple: tuple,
+		seq:   a.seq,
+		args:  make([]interface{}, len(a.calls)),
+	}
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(tuple, a.fv, &xsql.WildcardValuer{Data: tuple})}
+	for i, c := range a.calls {
+		if _, ok := c.Args[0].(*ast.Wildcard); ok {
+			// count(*) only cares about the existence
+			e.args[i] = true
+		} else {
+			e.args[i] = ve.Eval(c.Args[0])
+		}
+	}
+	for _, d := range a.keys {
+		r := ve.Eval(d.Expr)
+		if _, ok := r.(error); ok {
+			e.err = fmt.Errorf("run Group By error: %s", r)
+			break
+		}
+		e.key += fmt.Sprintf("%v,", r)
+	}
+	return e
+}
+
+// add appends the new input tuple. It is not added to the states until a trigger covers it.
+func (a *incAggWindow) add(tuple *xsql.Tuple) {
+	if l := len(a.entries); l > 0 && tuple.Timestamp < a.entries[l-1].tuple.Timestamp {
+		a.ordered = false
+	}
+	a.entries = append(a.entries, a.newEntry(tuple))
+}
+
+// drop evicts the n oldest tuples
+func (a *incAggWindow) drop(n int) {
+	if n > len(a.entries) {
+		n = len(a.entries)
+	}
+	for i := 0; i < n; i++ {
+		if i < a.accumulated {
+			a.evict(a.entries[i])
+		}
+		a.entries[i] = nil
+	}
+	a.entries = a.entries[n:]
+	a.accumulated -= n
+	if a.accumulated < 0 {
+		a.accumulated = 0
+	}
+}
+
+// reset rebuilds the entries from the inputs such as the restored window state
+func (a *incAggWindow) reset(inputs []*xsql.Tuple) {
+	a.entries = make([]*incAggEntry, 0, len(inputs))
+	a.accumulated = 0
+	a.groups = make(map[string]*incAggGroup)
+	a.invalid = 0
+	a.ordered = true
+	for _, t := range inputs {
+		a.add(t)
+	}
+}
+
+func (a *incAggWindow) synced(inputs []*xsql.Tuple) bool {
+	if len(a.entries) != len(inputs) {
+		return false
+	}
+	return len(inputs) == 0 || a.entries[0].tuple == inputs[0]
+}
+
+func (a *incAggWindow) accumulate(e *incAggEntry) {
+	if e.err != nil {
+		a.invalid++
+		return
+	}
+	g, ok := a.groups[e.key]
+	if !ok {
+		g = &incAggGroup{key: e.key, states: make([]*incAggState, len(a.calls))}
+		for i, c := range a.calls {
+			g.states[i] = newIncAggState(c.Name)
+		}
+		a.groups[e.key] = g
+	}
+	g.size++
+	for i, s := range g.states {
+		s.add(e.seq, e.args[i])
+	}
+	e.group = g
+}
+
+func (a *incAggWindow) evict(e *incAggEntry) {
+	if e.err != nil {
+		a.invalid--
+		return
+	}
+	g := e.group
+	g.size--
+	for i, s := range g.states {
+		s.remove(e.seq, e.args[i])
+	}
+	if g.size == 0 {
+		delete(a.groups, g.key)
+	}
+}
+
+// scan evicts the tuples older than the trigger time for more than maxDiff, and then accumulates the tuples until the
+// trigger time. It returns the rest inputs and the window result with the aggregates.
+func (a *incAggWindow) scan(inputs []*xsql.Tuple, triggerTime int64, maxDiff int64, wr *xsql.WindowRange) ([]*xsql.Tuple, interface{}) {
+	if !a.synced(inputs) {
+		a.reset(inputs)
+	}
+	if !a.ordered {
+		return a.rescan(inputs, triggerTime, maxDiff, wr)
+	}
+	expired := 0
+	for expired < len(inputs) && triggerTime-inputs[expired].Timestamp > maxDiff {
+		expired++
+	}
+	a.drop(expired)
+	inputs = inputs[expired:]
+	for a.accumulated < len(a.entries) && a.entries[a.accumulated].tuple.Timestamp <= triggerTime {
+		a.accumulate(a.entries[a.accumulated])
+		a.accumulated++
+	}
+	return inputs, a.result(a.entries[:a.accumulated], wr)
+}
+
+// rescan calculates the aggregates from scratch for the out of order tuples like the normal window scan. The
+// arguments are still evaluated only once when the tuples are added.
+func (a *incAggWindow) rescan(inputs []*xsql.Tuple, triggerTime int64, maxDiff int64, wr *xsql.WindowRange) ([]*xsql.Tuple, interface{}) {
+	a.groups = make(map[string]*incAggGroup)
+	a.invalid = 0
+	var (
+		i        int
+		included []*incAggEntry
+	)
+	ordered := true
+	for j, tuple := range inputs {
+		if triggerTime-tuple.Timestamp > maxDiff {
+			continue
+		}
+		e := a.entries[j]
+		if i > 0 && tuple.Timestamp < inputs[i-1].Timestamp {
+			ordered = false
+		}
+		inputs[i] = tuple
+		a.entries[i] = e
+		i++
+		if tuple.Timestamp <= triggerTime {
+			a.accumulate(e)
+			included = append(included, e)
+		}
+	}
+	for j := i; j < len(a.entries); j++ {
+		a.entries[j] = nil
+	}
+	a.entries = a.entries[:i]
+	result := a.result(included, wr)
+	// The states cannot be kept for the next trigger, recalculate them if the rest tuples are in order
+	a.groups = make(map[string]*incAggGroup)
+	a.invalid = 0
+	a.accumulated = 0
+	a.ordered = ordered
+	return inputs[:i], result
+}
+
+// result builds the window tuples with the aggregate results. If there are group by dimensions, the tuples are
+// grouped, and the aggregate operator will pass the groups through.
+func (a *incAggWindow) result(entries []*incAggEntry, wr *xsql.WindowRange) interface{} {
+	if a.invalid > 0 {
+		for _, e := range entries {
+			if e.err != nil {
+				return e.err
+			}
+		}
+	}
+	if len(a.keys) == 0 {
+		results := &xsql.WindowTuples{
+			Content:     make([]xsql.TupleRow, 0, len(entries)),
+			WindowRange: wr,
+		}
+		for _, e := range entries {
+			results.Content = append(results.Content, e.tuple)
+		}
+		g := a.groups[""]
+		for i, c := range a.calls {
+			results.Set(c.CachedField, a.value(i, g, entries))
+		}
+		return results
+	}
+	if len(entries) == 0 {
+		return &xsql.WindowTuples{
+			Content:     make([]xsql.TupleRow, 0),
+			WindowRange: wr,
+		}
+	}
+	var (
+		groups  []*xsql.GroupedTuples
+		members [][]*incAggEntry
+		index   = make(map[*incAggGroup]int, len(a.groups))
+	)
+	for _, e := range entries {
+		i, ok := index[e.group]
+		if !ok {
+			i = len(groups)
+			index[e.group] = i
+			groups = append(groups, &xsql.GroupedTuples{WindowRange: wr})
+			members = append(members, nil)
+		}
+		groups[i].Content = append(groups[i].Content, e.tuple)
+		members[i] = append(members[i], e)
+	}
+	for g, i := range index {
+		for j, c := range a.calls {
+			groups[i].Set(c.CachedField, a.value(j, g, members[i]))
+		}
+	}
+	return &xsql.GroupedTuplesSet{Groups: groups, WindowRange: wr}
+}
+
+// value returns the aggregate result of the call. If the types of the arguments are mixed, the aggregate function is
+// called with all the arguments to get the same result as the normal calculation.
+func (a *incAggWindow) value(i int, g *incAggGroup, entries []*incAggEntry) interface{} {
+	c := a.calls[i]
+	if g == nil {
+		if c.Name == "count" {
+			return 0
+		}
+		return nil
+	}
+	if r, ok := g.states[i].result(c.Name); ok {
+		return r
+	}
+	args := make([]interface{}, len(entries))
+	for j, e := range entries {
+		args[j] = e.args[i]
+	}
+	r, _ := a.afv.Call(c.Name, c.FuncId, []interface{}{args})
+	return r
+}
+
+// incAggState is the incremental state of an aggregate call. The values of int and int64 are calculated as int64. The
+// min and max are kept by a monotonic queue of the values in the order of the tuples.
+type incAggState struct {
+	count    int
+	ints     int
+	floats   int
+	others   int
+	intSum   int64
+	floatSum float64
+	// extreme is 1 for max and -1 for min
+	extreme  int
+	extremes []incAggValue
+}
+
+type incAggValue struct {
+	seq int64
+	v   interface{}
+}
+
+func newIncAggState(name string) *incAggState {
+	s := &incAggState{}
+	switch name {
+	case "max":
+		s.extreme = 1
+	case "min":
+		s.extreme = -1
+	}
+	return s
+}
+
+func (s *incAggState) add(seq int64, v interface{}) {
+	if v == nil {
+		return
+	}
+	s.count++
+	switch vt := v.(type) {
+	case int:
+		s.ints++
+		s.intSum += int64(vt)
+		s.push(seq, int64(vt))
+	case int64:
+		s.ints++
+		s.intSum += vt
+		s.push(seq, vt)
+	case float64:
+		s.floats++
+		s.floatSum += vt
+		s.push(seq, vt)
+	default:
+		s.others++
+	}
+}
+
+// push appends the value to the monotonic queue, the values which can never be the extreme are removed
+func (s *incAggState) push(seq int64, v interface{}) {
+	if s.extreme == 0 {
+		return
+	}
+	for l := len(s.extremes); l > 0 && compareNumber(s.extremes[l-1].v, v)*s.extreme <= 0; l-- {
+		s.extremes = s.extremes[:l-1]
+	}
+	s.extremes = append(s.extremes, incAggValue{seq: seq, v: v})
+}
+
+// remove evicts the oldest value
+func (s *incAggState) remove(seq int64, v interface{}) {
+	if v == nil {
+		return
+	}
+	s.count--
+	switch vt := v.(type) {
+	case int:
+		s.ints--
+		s.intSum -= int64(vt)
+	case int64:
+		s.ints--
+		s.intSum -= vt
+	case float64:
+		s.floats--
+		s.floatSum -= vt
+		if s.floats == 0 {
+			// avoid the accumulated rounding error
+			s.floatSum = 0
+		}
+	default:
+		s.others--
+	}
+	if len(s.extremes) > 0 && s.extremes[0].seq == seq {
+		s.extremes = s.extremes[1:]
+	}
+}
+
+// result returns the aggregate result like the builtin function. It returns false if the result cannot be calculated
+// from the state because of the mixed types.
+func (s *incAggState) result(name string) (interface{}, bool) {
+	if name == "count" {
+		return s.count, true
+	}
+	if s.count == 0 {
+		return nil, true
+	}
+	if s.others > 0 || (s.ints > 0 && s.floats > 0) {
+		return nil, false
+	}
+	switch name {
+	case "sum":
+		if s.ints > 0 {
+			return s.intSum, true
+		}
+		return s.floatSum, true
+	case "avg":
+		if s.ints > 0 {
+			return s.intSum / int64(s.count), true
+		}
+		return s.floatSum / float64(s.count), true
+	case "max", "min":
+		return s.extremes[0].v, true
+	}
+	return nil, false
+}
+
+func compareNumber(a, b interface{}) int {
+	if ai, ok := a.(int64); ok {
+		if bi, ok := b.(int64); ok {
+			switch {
+			case ai < bi:
+				return -1
+			case ai > bi:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	af, bf := toFloat(a), toFloat(b)
+	switch {
+	case af < bf:
+		return -1
+	case af > bf:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func toFloat(v interface{}) float64 {
+	switch vt := v.(type) {
+	case int64:
+		return float64(vt)
+	case float64:
+		return vt
+	}
+	return 0
+}
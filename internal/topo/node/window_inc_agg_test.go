@@ -0,0 +1,170 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func newIncAggCalls(names ...string) []*ast.Call {
+	calls := make([]*ast.Call, len(names))
+	for i, n := range names {
+		var arg ast.Expr = &ast.FieldRef{Name: "temp", StreamName: ast.DefaultStream}
+		if n == "count" {
+			arg = &ast.Wildcard{Token: ast.ASTERISK}
+		}
+		calls[i] = &ast.Call{Name: n, FuncId: i, FuncType: ast.FuncTypeAgg, Args: []ast.Expr{arg}, CachedField: fmt.Sprintf("$$i_%s_%d", n, i), Cached: true}
+	}
+	return calls
+}
+
+func incAggValues(row xsql.Row, calls []*ast.Call) []interface{} {
+	result := make([]interface{}, len(calls))
+	for i, c := range calls {
+		result[i], _ = row.Value(c.CachedField, "")
+	}
+	return result
+}
+
+func TestIncAggWindow(t *testing.T) {
+	calls := newIncAggCalls("count", "sum", "avg", "max", "min")
+	a := newIncAggWindow(calls, nil)
+	a.fv, a.afv = xsql.NewFunctionValuersForOp(nil)
+	tuples := []*xsql.Tuple{
+		{Message: xsql.Message{"temp": 10}, Timestamp: 1000},
+		{Message: xsql.Message{"temp": 30}, Timestamp: 1500},
+		{Message: xsql.Message{"temp": 20}, Timestamp: 2000},
+		{Message: xsql.Message{}, Timestamp: 2500},
+		{Message: xsql.Message{"temp": 25.5}, Timestamp: 2600},
+		{Message: xsql.Message{"temp": 40.5}, Timestamp: 3000},
+	}
+	tests := []struct {
+		adds    int
+		trigger int64
+		rest    int
+		content int
+		values  []interface{}
+	}{
+		{ // tuples later than the trigger time are not included
+			adds:    3,
+			trigger: 1800,
+			rest:    3,
+			content: 2,
+			values:  []interface{}{2, int64(40), int64(20), int64(30), int64(10)},
+		}, {
+			adds:    0,
+			trigger: 2000,
+			rest:    3,
+			content: 3,
+			values:  []interface{}{3, int64(60), int64(20), int64(30), int64(10)},
+		}, { // nil is not counted for avg
+			adds:    1,
+			trigger: 2500,
+			rest:    3,
+			content: 3,
+			values:  []interface{}{3, int64(50), int64(25), int64(30), int64(20)},
+		}, { // mixed types are calculated like the builtin functions
+			adds:    1,
+			trigger: 2600,
+			rest:    3,
+			content: 3,
+			values:  []interface{}{3, int64(45), int64(22), int64(25), int64(20)},
+		}, {
+			adds:    1,
+			trigger: 3100,
+			rest:    3,
+			content: 3,
+			values:  []interface{}{3, 66.0, 33.0, 40.5, 25.5},
+		}, { // empty window
+			adds:    0,
+			trigger: 5000,
+			rest:    0,
+			content: 0,
+			values:  []interface{}{0, nil, nil, nil, nil},
+		},
+	}
+	var (
+		inputs []*xsql.Tuple
+		next   int
+	)
+	for i, tt := range tests {
+		for j := 0; j < tt.adds; j++ {
+			inputs = append(inputs, tuples[next])
+			a.add(tuples[next])
+			next++
+		}
+		var r interface{}
+		inputs, r = a.scan(inputs, tt.trigger, 1000, xsql.NewWindowRange(tt.trigger-1000, tt.trigger))
+		if len(inputs) != tt.rest {
+			t.Errorf("%d. rest mismatch, got %d, want %d", i, len(inputs), tt.rest)
+		}
+		wt, ok := r.(*xsql.WindowTuples)
+		if !ok {
+			t.Errorf("%d. expect window tuples but got %v", i, r)
+			continue
+		}
+		if len(wt.Content) != tt.content {
+			t.Errorf("%d. content mismatch, got %d, want %d", i, len(wt.Content), tt.content)
+		}
+		if values := incAggValues(wt, calls); !reflect.DeepEqual(values, tt.values) {
+			t.Errorf("%d. values mismatch,\ngot:\t%v\nwant:\t%v", i, values, tt.values)
+		}
+	}
+}
+
+func TestIncAggWindowGroup(t *testing.T) {
+	calls := newIncAggCalls("count", "sum")
+	a := newIncAggWindow(calls, ast.Dimensions{{Expr: &ast.FieldRef{Name: "color", StreamName: ast.DefaultStream}}})
+	a.fv, a.afv = xsql.NewFunctionValuersForOp(nil)
+	inputs := []*xsql.Tuple{
+		{Message: xsql.Message{"color": "red", "temp": 1}, Timestamp: 1000},
+		{Message: xsql.Message{"color": "blue", "temp": 2}, Timestamp: 1200},
+		{Message: xsql.Message{"color": "red", "temp": 3}, Timestamp: 1400},
+	}
+	// the restored inputs are added when scanning
+	inputs, r := a.scan(inputs, 1500, 1000, xsql.NewWindowRange(500, 1500))
+	exp := map[string][]interface{}{
+		"red":  {2, int64(4)},
+		"blue": {1, int64(2)},
+	}
+	checkGroups := func(r interface{}) {
+		gs, ok := r.(*xsql.GroupedTuplesSet)
+		if !ok {
+			t.Fatalf("expect grouped tuples but got %v", r)
+		}
+		result := make(map[string][]interface{}, len(gs.Groups))
+		for _, g := range gs.Groups {
+			c, _ := g.Value("color", "")
+			result[c.(string)] = incAggValues(g, calls)
+		}
+		if !reflect.DeepEqual(result, exp) {
+			t.Errorf("groups mismatch,\ngot:\t%v\nwant:\t%v", result, exp)
+		}
+	}
+	checkGroups(r)
+	inputs = append(inputs, &xsql.Tuple{Message: xsql.Message{"color": "blue", "temp": 4}, Timestamp: 2300})
+	a.add(inputs[len(inputs)-1])
+	_, r = a.scan(inputs, 2300, 1000, xsql.NewWindowRange(1300, 2300))
+	exp = map[string][]interface{}{
+		"red":  {1, int64(3)},
+		"blue": {1, int64(4)},
+	}
+	checkGroups(r)
+}
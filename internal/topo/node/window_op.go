@@ -23,6 +23,7 @@ import (
 	"github.com/benbjohnson/clock"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/tracer"
 	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
@@ -35,6 +36,22 @@ type WindowConfig struct {
 	Type     ast.WindowType
 	Length   int
 	Interval int // If interval is not set, it is equals to Length
+	// Gap is the dynamic timeout of the session window evaluated for each event, Length is the max duration in this case
+	Gap     ast.Expr
+	GapUnit int
+	// Keys are the group by dimensions to run sessions for each key
+	Keys ast.Dimensions
+	// AllowedLateness is the time to update the fired windows by the late events for event time
+	AllowedLateness int64
+	// SideOutput is the memory topic to send the events later than the allowed lateness
+	SideOutput string
+	// TimeZone and Months align the tumbling window to the local calendar of the time zone
+	TimeZone string
+	Months   int
+	// IncAggs are the aggregate calls calculated incrementally by the processing time hopping and sliding window
+	IncAggs []*ast.Call
+	// location is set for the calendar window only
+	location *time.Location
 }
 
 type WindowOperator struct {
@@ -43,9 +60,14 @@ type WindowOperator struct {
 	interval           int
 	isEventTime        bool
 	watermarkGenerator *WatermarkGenerator // For event time only
+	watermarkByKey     bool                // For event time only, run windows by the watermark of each key
 
 	statManager metric.StatManager
 	ticker      *clock.Ticker // For processing time only
+	quota       *windowQuota
+	incAgg      *incAggWindow // For the incremental aggregation only
+	spillAt     int           // For the disk state backend only, the threshold to spill the inputs
+	spill       *tupleSpill
 	// states
 	triggerTime int64
 	msgCount    int
@@ -68,20 +90,64 @@ func NewWindowOp(name string, w WindowConfig, streams []string, options *api.Rul
 	o.defaultSinkNode = &defaultSinkNode{
 		input: make(chan interface{}, options.BufferLength),
 		defaultNode: &defaultNode{
-			outputs:   make(map[string]chan<- interface{}),
-			name:      name,
-			sendError: options.SendError,
+			outputs:      make(map[string]chan<- interface{}),
+			name:         name,
+			sendError:    options.SendError,
+			backPressure: options.BackPressure,
 		},
 	}
 	o.isEventTime = options.IsEventTime
 	o.window = &w
+	o.quota = newWindowQuota(options.Quota)
+	if len(w.IncAggs) > 0 {
+		if options.IsEventTime || (w.Type != ast.HOPPING_WINDOW && w.Type != ast.SLIDING_WINDOW) {
+			return nil, fmt.Errorf("incremental aggregation is only supported by processing time hopping and sliding window")
+		}
+		o.incAgg = newIncAggWindow(w.IncAggs, w.Keys)
+	}
+	// Only the processing time tumbling and hopping windows scan the inputs at the trigger time, so the inputs can be
+	// kept on the disk in between. Other windows keep the inputs in memory.
+	if sb := options.StateBackend; sb != nil && sb.Type == api.StateBackendDisk && !options.IsEventTime &&
+		(w.Type == ast.TUMBLING_WINDOW || w.Type == ast.HOPPING_WINDOW) && o.incAgg == nil {
+		o.spillAt = sb.SpillThreshold
+	}
 	if o.window.Interval == 0 && o.window.Type == ast.COUNT_WINDOW {
 		// if no interval value is set and it's count window, then set interval to length value.
 		o.window.Interval = o.window.Length
 	}
+	if w.AllowedLateness > 0 || w.SideOutput != "" {
+		if !options.IsEventTime {
+			return nil, fmt.Errorf("allowed lateness is only supported in event time")
+		}
+		if options.WatermarkByKey {
+			return nil, fmt.Errorf("allowed lateness is not supported with watermarkByKey")
+		}
+	}
+	if w.TimeZone != "" || w.Months > 0 {
+		if w.Type != ast.TUMBLING_WINDOW {
+			return nil, fmt.Errorf("calendar window is only supported by tumbling window")
+		}
+		if options.IsEventTime && options.WatermarkByKey {
+			return nil, fmt.Errorf("calendar window is not supported with watermarkByKey")
+		}
+		o.window.location = time.Local
+		if w.TimeZone != "" {
+			loc, err := time.LoadLocation(w.TimeZone)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time zone %s: %v", w.TimeZone, err)
+			}
+			o.window.location = loc
+		}
+	}
+	if options.IsEventTime && options.WatermarkByKey {
+		if w.Type != ast.TUMBLING_WINDOW && w.Type != ast.HOPPING_WINDOW {
+			return nil, fmt.Errorf("watermarkByKey only supports tumbling window and hopping window")
+		}
+		o.watermarkByKey = true
+	}
 	if options.IsEventTime {
 		// Create watermark generator
-		if w, err := NewWatermarkGenerator(o.window, options.LateTol, streams, o.input); err != nil {
+		if w, err := NewWatermarkGenerator(o.window, options.LateTol, options.IdleTimeout, streams, o.input); err != nil {
 			return nil, err
 		} else {
 			o.watermarkGenerator = w
@@ -108,6 +174,17 @@ func (o *WindowOperator) Exec(ctx api.StreamContext, errCh chan<- error) {
 		return
 	}
 	o.statManager = stats
+	o.statManagers = []metric.StatManager{stats}
+	if o.incAgg != nil {
+		o.incAgg.fv, o.incAgg.afv = xsql.NewFunctionValuersForOp(ctx)
+	}
+	if o.spillAt > 0 {
+		o.spill, err = newTupleSpill(ctx.GetRuleId(), o.name, o.spillAt)
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+			return
+		}
+	}
 	var inputs []*xsql.Tuple
 	if s, err := ctx.GetState(WINDOW_INPUTS_KEY); err == nil {
 		switch st := s.(type) {
@@ -141,7 +218,27 @@ func (o *WindowOperator) Exec(ctx api.StreamContext, errCh chan<- error) {
 		}
 	}
 	log.Infof("Start with window state triggerTime: %d, msgCount: %d", o.triggerTime, o.msgCount)
-	if o.isEventTime {
+	if o.window.Type == ast.SESSION_WINDOW && o.window.Gap != nil {
+		go func() {
+			err := infra.SafeRun(func() error {
+				o.execSessionWindow(ctx, inputs, errCh)
+				return nil
+			})
+			if err != nil {
+				infra.DrainError(ctx, err, errCh)
+			}
+		}()
+	} else if o.watermarkByKey {
+		go func() {
+			err := infra.SafeRun(func() error {
+				o.execKeyedEventWindow(ctx, errCh)
+				return nil
+			})
+			if err != nil {
+				infra.DrainError(ctx, err, errCh)
+			}
+		}()
+	} else if o.isEventTime {
 		go func() {
 			err := infra.SafeRun(func() error {
 				o.execEventWindow(ctx, inputs, errCh)
@@ -164,6 +261,33 @@ func (o *WindowOperator) Exec(ctx api.StreamContext, errCh chan<- error) {
 	}
 }
 
+// addInput appends the tuple to the window inputs under the quota, and spills the older inputs to the disk if needed
+func (o *WindowOperator) addInput(inputs []*xsql.Tuple, tuple *xsql.Tuple) ([]*xsql.Tuple, error) {
+	inputs = append(inputs, tuple)
+	if o.incAgg != nil {
+		o.incAgg.add(tuple)
+	}
+	if o.quota != nil {
+		l := len(inputs)
+		var err error
+		inputs, err = o.quota.add(inputs, tuple)
+		if o.incAgg != nil {
+			o.incAgg.drop(l - len(inputs))
+		}
+		if err != nil {
+			return inputs, err
+		}
+	}
+	if o.spill != nil {
+		rest, err := o.spill.spill(inputs)
+		if err != nil {
+			o.ctx.GetLogger().Warnf("spill window inputs error, keep them in memory: %v", err)
+		}
+		inputs = rest
+	}
+	return inputs, nil
+}
+
 func getAlignedWindowEndTime(n, interval int64) time.Time {
 	now := time.UnixMilli(n)
 	offset := conf.GetLocalZone()
@@ -193,7 +317,11 @@ func (o *WindowOperator) execProcessingWindow(ctx api.StreamContext, inputs []*x
 	switch o.window.Type {
 	case ast.NOT_WINDOW:
 	case ast.TUMBLING_WINDOW:
-		firstTime, firstTicker = getFirstTimer(ctx, int64(o.window.Length))
+		if o.window.location != nil {
+			firstTime, firstTicker = getCalendarTimer(ctx, o.window, conf.GetNowInMilli())
+		} else {
+			firstTime, firstTicker = getFirstTimer(ctx, int64(o.window.Length))
+		}
 		o.interval = o.window.Length
 	case ast.HOPPING_WINDOW:
 		firstTime, firstTicker = getFirstTimer(ctx, int64(o.window.Interval))
@@ -216,7 +344,7 @@ func (o *WindowOperator) execProcessingWindow(ctx api.StreamContext, inputs []*x
 			switch o.window.Type {
 			case ast.TUMBLING_WINDOW, ast.HOPPING_WINDOW:
 				for {
-					next = next + int64(o.interval)
+					next = nextWindowEnd(o.window, next, o.interval)
 					if next > nextTick {
 						break
 					}
@@ -285,7 +413,11 @@ func (o *WindowOperator) execProcessingWindow(ctx api.StreamContext, inputs []*x
 				o.statManager.IncTotalExceptions(d.Error())
 			case *xsql.Tuple:
 				log.Debugf("Event window receive tuple %s", d.Message)
-				inputs = append(inputs, d)
+				var err error
+				if inputs, err = o.addInput(inputs, d); err != nil {
+					infra.DrainError(ctx, err, errCh)
+					return
+				}
 				switch o.window.Type {
 				case ast.NOT_WINDOW:
 					inputs = o.scan(inputs, d.Timestamp, ctx)
@@ -341,6 +473,13 @@ func (o *WindowOperator) execProcessingWindow(ctx api.StreamContext, inputs []*x
 			}
 		case now := <-firstC:
 			log.Debugf("First tick at %v(%d), defined at %d", now, now.UnixMilli(), firstTime)
+			if o.window.location != nil {
+				// the length of calendar window varies, so reset the timer to the next window end for each window
+				inputs = o.tick(ctx, inputs, firstTime, log)
+				firstTime, firstTicker = getCalendarTimer(ctx, o.window, firstTime)
+				firstC = firstTicker.C
+				break
+			}
 			switch o.window.Type {
 			case ast.TUMBLING_WINDOW:
 				o.ticker = conf.GetTicker(o.window.Length)
@@ -384,6 +523,11 @@ func (o *WindowOperator) execProcessingWindow(ctx api.StreamContext, inputs []*x
 			if o.ticker != nil {
 				o.ticker.Stop()
 			}
+			if o.spill != nil {
+				if err := o.spill.close(); err != nil {
+					log.Warnf("remove the spill file error: %v", err)
+				}
+			}
 			return
 		}
 	}
@@ -462,14 +606,23 @@ func (tl *TupleList) getRestTuples() []*xsql.Tuple {
 func (o *WindowOperator) scan(inputs []*xsql.Tuple, triggerTime int64, ctx api.StreamContext) []*xsql.Tuple {
 	log := ctx.GetLogger()
 	log.Debugf("window %s triggered at %s(%d)", o.name, time.Unix(triggerTime/1000, triggerTime%1000), triggerTime)
-	var (
-		delta       int64
-		windowStart int64
-		windowEnd   = triggerTime
-	)
+	span := startSpan(ctx, tracer.KindInternal, ctx.GetOpId())
+	defer endSpan(span, len(inputs), nil)
+	var delta int64
 	if o.window.Type == ast.HOPPING_WINDOW || o.window.Type == ast.SLIDING_WINDOW {
 		delta = o.calDelta(triggerTime, log)
 	}
+	if o.incAgg != nil {
+		return o.scanIncremental(inputs, triggerTime, delta, ctx)
+	}
+	if o.spill != nil {
+		var err error
+		if inputs, err = o.spill.load(inputs); err != nil {
+			log.Errorf("load the spilled window inputs error: %v", err)
+			o.Broadcast(err)
+			o.statManager.IncTotalExceptions(err.Error())
+		}
+	}
 	results := &xsql.WindowTuples{
 		Content: make([]xsql.TupleRow, 0),
 	}
@@ -497,18 +650,7 @@ func (o *WindowOperator) scan(inputs []*xsql.Tuple, triggerTime int64, ctx api.S
 		}
 	}
 
-	switch o.window.Type {
-	case ast.TUMBLING_WINDOW, ast.SESSION_WINDOW:
-		windowStart = o.triggerTime
-	case ast.HOPPING_WINDOW:
-		windowStart = o.triggerTime - int64(o.window.Interval)
-	case ast.SLIDING_WINDOW:
-		windowStart = triggerTime - int64(o.window.Length)
-	}
-	if windowStart <= 0 {
-		windowStart = windowEnd - int64(o.window.Length)
-	}
-	results.WindowRange = xsql.NewWindowRange(windowStart, windowEnd)
+	results.WindowRange = o.windowRange(triggerTime)
 	log.Debugf("window %s triggered for %d tuples", o.name, len(inputs))
 	if o.isEventTime {
 		results.Sort()
@@ -522,6 +664,37 @@ func (o *WindowOperator) scan(inputs []*xsql.Tuple, triggerTime int64, ctx api.S
 	return inputs[:i]
 }
 
+// scanIncremental is the scan of the incremental aggregation. Instead of evaluating the aggregates from all the tuples
+// for each trigger, the expired tuples are evicted from and the new tuples are added to the aggregate states.
+func (o *WindowOperator) scanIncremental(inputs []*xsql.Tuple, triggerTime int64, delta int64, ctx api.StreamContext) []*xsql.Tuple {
+	log := ctx.GetLogger()
+	inputs, results := o.incAgg.scan(inputs, triggerTime, int64(o.window.Length)+delta, o.windowRange(triggerTime))
+	log.Debugf("window %s triggered for %d tuples incrementally", o.name, len(inputs))
+	log.Debugf("Sent: %v", results)
+	o.Broadcast(results)
+	o.statManager.IncTotalRecordsOut()
+
+	o.triggerTime = triggerTime
+	log.Debugf("new trigger time %d", o.triggerTime)
+	return inputs
+}
+
+func (o *WindowOperator) windowRange(triggerTime int64) *xsql.WindowRange {
+	var windowStart int64
+	switch o.window.Type {
+	case ast.TUMBLING_WINDOW, ast.SESSION_WINDOW:
+		windowStart = o.triggerTime
+	case ast.HOPPING_WINDOW:
+		windowStart = o.triggerTime - int64(o.window.Interval)
+	case ast.SLIDING_WINDOW:
+		windowStart = triggerTime - int64(o.window.Length)
+	}
+	if windowStart <= 0 {
+		windowStart = triggerTime - int64(o.window.Length)
+	}
+	return xsql.NewWindowRange(windowStart, triggerTime)
+}
+
 func (o *WindowOperator) calDelta(triggerTime int64, log api.Logger) int64 {
 	var delta int64
 	lastTriggerTime := o.triggerTime
@@ -100,6 +100,60 @@ func TestTime(t *testing.T) {
 	}
 }
 
+func TestCalendarWindowEndTime(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		length int
+		months int
+		now    time.Time
+		end    time.Time
+	}{
+		{ // the day before DST starts
+			length: 3600000 * 24,
+			now:    time.Date(2023, 3, 25, 12, 0, 0, 0, loc),
+			end:    time.Date(2023, 3, 26, 0, 0, 0, 0, loc),
+		}, { // the day of 23 hours
+			length: 3600000 * 24,
+			now:    time.Date(2023, 3, 26, 0, 0, 0, 0, loc),
+			end:    time.Date(2023, 3, 27, 0, 0, 0, 0, loc),
+		}, { // the day of 25 hours
+			length: 3600000 * 24,
+			now:    time.Date(2023, 10, 29, 12, 0, 0, 0, loc),
+			end:    time.Date(2023, 10, 30, 0, 0, 0, 0, loc),
+		}, {
+			length: 3600000,
+			now:    time.Date(2023, 3, 26, 1, 30, 0, 0, loc),
+			end:    time.Date(2023, 3, 26, 3, 0, 0, 0, loc),
+		}, { // the last window of the day ends at midnight
+			length: 3600000 * 5,
+			now:    time.Date(2023, 3, 26, 22, 0, 0, 0, loc),
+			end:    time.Date(2023, 3, 27, 0, 0, 0, 0, loc),
+		}, {
+			months: 1,
+			now:    time.Date(2023, 12, 15, 8, 0, 0, 0, loc),
+			end:    time.Date(2024, 1, 1, 0, 0, 0, 0, loc),
+		}, {
+			months: 1,
+			now:    time.Date(2023, 5, 1, 0, 0, 0, 0, loc),
+			end:    time.Date(2023, 6, 1, 0, 0, 0, 0, loc),
+		}, {
+			months: 3,
+			now:    time.Date(2023, 5, 1, 0, 0, 0, 0, loc),
+			end:    time.Date(2023, 7, 1, 0, 0, 0, 0, loc),
+		},
+	}
+	for i, tt := range tests {
+		w := &WindowConfig{Length: tt.length, Months: tt.months, location: loc}
+		ae := getCalendarWindowEndTime(tt.now.UnixMilli(), w)
+		if tt.end.UnixMilli() != ae.UnixMilli() {
+			t.Errorf("%d. error mismatch:\n  exp=%s(%d)\n  got=%s(%d)\n\n", i, tt.end, tt.end.UnixMilli(), ae, ae.UnixMilli())
+		}
+	}
+}
+
 func TestNewTupleList(t *testing.T) {
 	_, e := NewTupleList(nil, 0)
 	es1 := "Window size should not be less than zero."
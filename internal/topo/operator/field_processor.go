@@ -32,75 +32,109 @@ type defaultFieldProcessor struct {
 	timestampFormat string
 }
 
+// validateAndConvert replaces the message of the tuple only if any field is converted. The message may be shared with
+// other rules such as the ones from the memory source, so it is never modified in place
 func (p *defaultFieldProcessor) validateAndConvert(tuple *xsql.Tuple) error {
-	_, err := p.validateAndConvertMessage(p.streamFields, tuple.Message)
-	return err
+	m, changed, err := p.validateAndConvertMessage(p.streamFields, tuple.Message)
+	if err != nil {
+		return err
+	}
+	if changed {
+		tuple.Message = m
+	}
+	return nil
 }
 
-func (p *defaultFieldProcessor) validateAndConvertMessage(schema map[string]*ast.JsonStreamField, message xsql.Message) (map[string]interface{}, error) {
+// validateAndConvertMessage returns the converted message and whether it is a new copy. The copy is created on the first write
+func (p *defaultFieldProcessor) validateAndConvertMessage(schema map[string]*ast.JsonStreamField, message xsql.Message) (map[string]interface{}, bool, error) {
+	result := message
+	copied := false
 	for name, sf := range schema {
 		v, ok := message.Value(name, "")
 		if !ok {
-			return nil, fmt.Errorf("field %s is not found", name)
+			return nil, false, fmt.Errorf("field %s is not found", name)
 		}
-		if nv, err := p.validateAndConvertField(sf, v); err != nil {
-			return nil, fmt.Errorf("field %s type mismatch: %v", name, err)
-		} else {
-			message[name] = nv
+		nv, changed, err := p.validateAndConvertField(sf, v)
+		if err != nil {
+			return nil, false, fmt.Errorf("field %s type mismatch: %v", name, err)
+		}
+		// the field found by ignoring case is also set with the name in the schema
+		if _, exact := message[name]; exact && !changed {
+			continue
+		}
+		if !copied {
+			result = make(map[string]interface{}, len(message)+1)
+			for k, mv := range message {
+				result[k] = mv
+			}
+			copied = true
 		}
+		result[name] = nv
 	}
-	return message, nil
+	return result, copied, nil
 }
 
-// Validate and convert field value to the type defined in schema
-func (p *defaultFieldProcessor) validateAndConvertField(sf *ast.JsonStreamField, t interface{}) (interface{}, error) {
+// Validate and convert field value to the type defined in schema. Return whether the value is changed, the original
+// value is never modified
+func (p *defaultFieldProcessor) validateAndConvertField(sf *ast.JsonStreamField, t interface{}) (interface{}, bool, error) {
 	v := reflect.ValueOf(t)
 	jtype := v.Kind()
 	switch sf.Type {
 	case (ast.BIGINT).String():
 		if jtype == reflect.Int64 {
-			return t, nil
+			return t, false, nil
 		}
-		return cast.ToInt64(t, cast.CONVERT_SAMEKIND)
+		return converted(cast.ToInt64(t, cast.CONVERT_SAMEKIND))
 	case (ast.FLOAT).String():
 		if jtype == reflect.Float64 {
-			return t, nil
+			return t, false, nil
 		}
-		return cast.ToFloat64(t, cast.CONVERT_SAMEKIND)
+		return converted(cast.ToFloat64(t, cast.CONVERT_SAMEKIND))
 	case (ast.BOOLEAN).String():
 		if jtype == reflect.Bool {
-			return t, nil
+			return t, false, nil
 		}
-		return cast.ToBool(t, cast.CONVERT_SAMEKIND)
+		return converted(cast.ToBool(t, cast.CONVERT_SAMEKIND))
 	case (ast.STRINGS).String():
 		if jtype == reflect.String {
-			return t, nil
+			return t, false, nil
 		}
-		return cast.ToString(t, cast.CONVERT_SAMEKIND)
+		return converted(cast.ToString(t, cast.CONVERT_SAMEKIND))
 	case (ast.DATETIME).String():
-		return cast.InterfaceToTime(t, p.timestampFormat)
+		if _, ok := t.(time.Time); ok {
+			return t, false, nil
+		}
+		return converted(cast.InterfaceToTime(t, p.timestampFormat))
 	case (ast.BYTEA).String():
-		return cast.ToByteA(t, cast.CONVERT_SAMEKIND)
+		if _, ok := t.([]byte); ok {
+			return t, false, nil
+		}
+		return converted(cast.ToByteA(t, cast.CONVERT_SAMEKIND))
 	case (ast.ARRAY).String():
 		if t == nil {
-			return []interface{}(nil), nil
+			return []interface{}(nil), true, nil
 		} else if jtype == reflect.Slice {
 			a, ok := t.([]interface{})
 			if !ok {
-				return nil, fmt.Errorf("cannot convert %v to []interface{}", t)
+				return nil, false, fmt.Errorf("cannot convert %v to []interface{}", t)
 			}
+			copied := false
 			for i, e := range a {
-				ne, err := p.validateAndConvertField(sf.Items, e)
+				ne, changed, err := p.validateAndConvertField(sf.Items, e)
 				if err != nil {
-					return nil, fmt.Errorf("array element type mismatch: %v", err)
+					return nil, false, fmt.Errorf("array element type mismatch: %v", err)
 				}
-				if ne != nil {
+				if changed && ne != nil {
+					if !copied {
+						a = append([]interface{}(nil), a...)
+						copied = true
+					}
 					a[i] = ne
 				}
 			}
-			return a, nil
+			return a, copied, nil
 		} else {
-			return nil, fmt.Errorf("expect array but got %v", t)
+			return nil, false, fmt.Errorf("expect array but got %v", t)
 		}
 	case (ast.STRUCT).String():
 		var (
@@ -108,26 +142,32 @@ func (p *defaultFieldProcessor) validateAndConvertField(sf *ast.JsonStreamField,
 			ok    bool
 		)
 		if t == nil {
-			return map[string]interface{}(nil), nil
+			return map[string]interface{}(nil), true, nil
 		} else if jtype == reflect.Map {
 			nextJ, ok = t.(map[string]interface{})
 			if !ok {
-				return nil, fmt.Errorf("expect map but found %[1]T(%[1]v)", t)
+				return nil, false, fmt.Errorf("expect map but found %[1]T(%[1]v)", t)
 			}
+			return p.validateAndConvertMessage(sf.Properties, nextJ)
 		} else if jtype == reflect.String {
 			err := json.Unmarshal([]byte(t.(string)), &nextJ)
 			if err != nil {
-				return nil, fmt.Errorf("invalid data type for %s, expect map but found %[1]T(%[1]v)", t)
+				return nil, false, fmt.Errorf("invalid data type for %s, expect map but found %[1]T(%[1]v)", t)
 			}
+			m, _, err := p.validateAndConvertMessage(sf.Properties, nextJ)
+			return m, true, err
 		} else {
-			return nil, fmt.Errorf("expect struct but found %[1]T(%[1]v)", t)
+			return nil, false, fmt.Errorf("expect struct but found %[1]T(%[1]v)", t)
 		}
-		return p.validateAndConvertMessage(sf.Properties, nextJ)
 	default:
-		return nil, fmt.Errorf("unsupported type %s", sf.Type)
+		return nil, false, fmt.Errorf("unsupported type %s", sf.Type)
 	}
 }
 
+func converted(v interface{}, err error) (interface{}, bool, error) {
+	return v, err == nil, err
+}
+
 func (p *defaultFieldProcessor) parseTime(s string) (time.Time, error) {
 	if p.timestampFormat != "" {
 		return cast.ParseTime(s, p.timestampFormat)
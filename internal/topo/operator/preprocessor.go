@@ -74,14 +74,21 @@ func (p *Preprocessor) Apply(ctx api.StreamContext, data interface{}, _ *xsql.Fu
 				return fmt.Errorf("error in preprocessor: %s", err)
 			}
 		} else {
+			// rename the default field in a new message as the message may be shared with other rules
 			for name := range p.streamFields {
-				tuple.Message[name] = tuple.Message[message.DefaultField]
-				delete(tuple.Message, message.DefaultField)
+				m := make(map[string]interface{}, len(tuple.Message))
+				for k, v := range tuple.Message {
+					if k != message.DefaultField {
+						m[k] = v
+					}
+				}
+				m[name] = tuple.Message[message.DefaultField]
+				tuple.Message = m
 				break
 			}
 		}
 	}
-	if p.isEventTime {
+	if p.isEventTime && p.timestampField != "" {
 		if t, ok := tuple.Message[p.timestampField]; ok {
 			if ts, err := cast.InterfaceToUnixMilli(t, p.timestampFormat); err != nil {
 				return fmt.Errorf("cannot convert timestamp field %s to timestamp with error %v", p.timestampField, err)
@@ -1087,3 +1087,75 @@ func TestPreprocessorForBinary(t *testing.T) {
 
 	}
 }
+
+func TestPreprocessorSharedMessage(t *testing.T) {
+	defer conf.CloseLogger()
+	contextLogger := conf.Log.WithField("rule", "TestPreprocessorSharedMessage")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	// the message shared by the rules subscribing the same memory topic
+	shared := map[string]interface{}{
+		"abc":  float64(6),
+		"def":  "hello",
+		"arr":  []interface{}{1, 2},
+		"self": []byte("data"),
+	}
+	exp := map[string]interface{}{
+		"abc":  float64(6),
+		"def":  "hello",
+		"arr":  []interface{}{1, 2},
+		"self": []byte("data"),
+	}
+	tests := []struct {
+		fields   ast.StreamFields
+		isBinary bool
+		result   map[string]interface{}
+	}{
+		{
+			fields: ast.StreamFields{
+				{Name: "abc", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+				{Name: "arr", FieldType: &ast.ArrayType{Type: ast.FLOAT}},
+			},
+			result: map[string]interface{}{
+				"abc":  int64(6),
+				"def":  "hello",
+				"arr":  []interface{}{float64(1), float64(2)},
+				"self": []byte("data"),
+			},
+		}, {
+			fields: ast.StreamFields{
+				{Name: "abc", FieldType: &ast.BasicType{Type: ast.FLOAT}},
+				{Name: "def", FieldType: &ast.BasicType{Type: ast.STRINGS}},
+			},
+			result: exp,
+		}, {
+			fields: ast.StreamFields{
+				{Name: "img", FieldType: &ast.BasicType{Type: ast.BYTEA}},
+			},
+			isBinary: true,
+			result: map[string]interface{}{
+				"abc": float64(6),
+				"def": "hello",
+				"arr": []interface{}{1, 2},
+				"img": []byte("data"),
+			},
+		},
+	}
+	for i, tt := range tests {
+		pp := &Preprocessor{checkSchema: true, isBinary: tt.isBinary}
+		pp.streamFields = tt.fields.ToJsonSchema()
+		tuple := &xsql.Tuple{Message: shared}
+		fv, afv := xsql.NewFunctionValuersForOp(nil)
+		result := pp.Apply(ctx, tuple, fv, afv)
+		r, ok := result.(*xsql.Tuple)
+		if !ok {
+			t.Errorf("%d. expect tuple but got %v", i, result)
+			continue
+		}
+		if !reflect.DeepEqual(tt.result, map[string]interface{}(r.Message)) {
+			t.Errorf("%d. result mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.result, r.Message)
+		}
+		if !reflect.DeepEqual(exp, shared) {
+			t.Errorf("%d. shared message is modified: %#v", i, shared)
+		}
+	}
+}
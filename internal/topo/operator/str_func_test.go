@@ -117,6 +117,20 @@ func TestStrFunc_Apply1(t *testing.T) {
 				"a": "2019-09-19 T 00:55:15",
 			}},
 		},
+		{
+			sql: "SELECT format_time(a, \"yyyy-MM-dd T HH:mm:ss\", \"America/New_York\") AS a FROM test",
+			data: &xsql.Tuple{
+				Emitter: "test",
+				Message: xsql.Message{
+					"a": cast.TimeFromUnixMilli(1568854515000),
+					"b": "ya",
+					"c": "myc",
+				},
+			},
+			result: []map[string]interface{}{{
+				"a": "2019-09-18 T 20:55:15",
+			}},
+		},
 		{
 			sql: "SELECT format_time(meta(created) * 1000, \"yyyy-MM-dd T HH:mm:ss\") AS time FROM test",
 			data: &xsql.Tuple{
@@ -53,6 +53,13 @@ func NewTableProcessor(isSchemaless bool, name string, fields map[string]*ast.Js
 	return p, nil
 }
 
+// SetBatchInput makes the processor fire only when EOF is received, so that the table is swapped by each batch.
+// It is set by the source node if the source sends the whole table in batches such as the sql source with refresh
+func (p *TableProcessor) SetBatchInput() {
+	p.isBatchInput = true
+	p.retainSize = 0
+}
+
 // Apply
 //
 //	input: *xsql.Tuple or BatchCount
@@ -145,3 +145,33 @@ func TestTableProcessor_Apply(t *testing.T) {
 
 	}
 }
+
+func TestTableProcessorBatchInput(t *testing.T) {
+	pp, err := NewTableProcessor(true, "demo", nil, &ast.Options{TYPE: "sql"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp.SetBatchInput()
+	contextLogger := conf.Log.WithField("rule", "TestTableProcessorBatchInput")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	fv, afv := xsql.NewFunctionValuersForOp(nil)
+	batches := [][]map[string]interface{}{
+		{{"id": 1}, {"id": 2}},
+		{{"id": 3}},
+	}
+	for i, batch := range batches {
+		for _, m := range batch {
+			if r := pp.Apply(ctx, &xsql.Tuple{Emitter: "demo", Message: m}, fv, afv); r != nil {
+				t.Errorf("%d. expect no output before EOF but got %v", i, r)
+			}
+		}
+		result := pp.Apply(ctx, &xsql.Tuple{}, fv, afv)
+		exp := &xsql.WindowTuples{Content: make([]xsql.TupleRow, 0, len(batch))}
+		for _, m := range batch {
+			exp.Content = append(exp.Content, &xsql.Tuple{Emitter: "demo", Message: m})
+		}
+		if !reflect.DeepEqual(exp, result) {
+			t.Errorf("%d. result mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, exp, result)
+		}
+	}
+}
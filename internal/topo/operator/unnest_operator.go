@@ -0,0 +1,73 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+type UnnestOp struct {
+	Unnests []*ast.Unnest
+}
+
+// Apply implement UnOperation
+// UnnestOp expands the array of each row into multiple rows by the lateral unnest. For example, unnest arr as t(v, i)
+// with ordinality will do the following transform:
+// {"a":1,"arr":[4,5]} => {"a":1,"arr":[4,5],"v":4,"i":1},{"a":1,"arr":[4,5],"v":5,"i":2}
+// The rows with a null or empty array are dropped like the cross join
+func (p *UnnestOp) Apply(ctx api.StreamContext, data interface{}, fv *xsql.FunctionValuer, _ *xsql.AggregateFunctionValuer) interface{} {
+	ctx.GetLogger().Debugf("UnnestOp receive: %s", data)
+	switch input := data.(type) {
+	case error:
+		return input
+	case xsql.TupleRow:
+		rows := []xsql.TupleRow{input}
+		for _, u := range p.Unnests {
+			var next []xsql.TupleRow
+			for _, row := range rows {
+				ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(row, fv)}
+				result := ve.Eval(u.Expr)
+				switch r := result.(type) {
+				case nil:
+					continue
+				case error:
+					return fmt.Errorf("run unnest error: %v", r)
+				case []interface{}:
+					for i, v := range r {
+						nr := row.Clone().(xsql.TupleRow)
+						nr.Set(u.Name, v)
+						if u.Ordinality != "" {
+							nr.Set(u.Ordinality, i+1)
+						}
+						next = append(next, nr)
+					}
+				default:
+					return fmt.Errorf("run unnest error: the value to unnest must be an array but got %[1]T(%[1]v)", r)
+				}
+			}
+			rows = next
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return rows
+	default:
+		return fmt.Errorf("run unnest op error: invalid input %[1]T(%[1]v)", input)
+	}
+}
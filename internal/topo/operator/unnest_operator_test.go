@@ -0,0 +1,114 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestUnnestOp_Apply(t *testing.T) {
+	tests := []struct {
+		unnests []*ast.Unnest
+		data    interface{}
+		result  interface{}
+	}{
+		{ // 0 with ordinality
+			unnests: []*ast.Unnest{
+				{Expr: &ast.FieldRef{Name: "arr"}, Alias: "t", Name: "v", Ordinality: "i"},
+			},
+			data: &xsql.Tuple{
+				Emitter: "test",
+				Message: xsql.Message{"a": 1, "arr": []interface{}{4, 5}},
+			},
+			result: []map[string]interface{}{
+				{"a": 1, "arr": []interface{}{4, 5}, "v": 4, "i": 1},
+				{"a": 1, "arr": []interface{}{4, 5}, "v": 5, "i": 2},
+			},
+		},
+		{ // 1 multiple unnests
+			unnests: []*ast.Unnest{
+				{Expr: &ast.FieldRef{Name: "x"}, Name: "u"},
+				{Expr: &ast.FieldRef{Name: "y"}, Name: "v"},
+			},
+			data: &xsql.Tuple{
+				Emitter: "test",
+				Message: xsql.Message{"x": []interface{}{"a", "b"}, "y": []interface{}{1, 2}},
+			},
+			result: []map[string]interface{}{
+				{"x": []interface{}{"a", "b"}, "y": []interface{}{1, 2}, "u": "a", "v": 1},
+				{"x": []interface{}{"a", "b"}, "y": []interface{}{1, 2}, "u": "a", "v": 2},
+				{"x": []interface{}{"a", "b"}, "y": []interface{}{1, 2}, "u": "b", "v": 1},
+				{"x": []interface{}{"a", "b"}, "y": []interface{}{1, 2}, "u": "b", "v": 2},
+			},
+		},
+		{ // 2 empty array
+			unnests: []*ast.Unnest{
+				{Expr: &ast.FieldRef{Name: "arr"}, Name: "v"},
+			},
+			data: &xsql.Tuple{
+				Emitter: "test",
+				Message: xsql.Message{"a": 1, "arr": []interface{}{}},
+			},
+			result: nil,
+		},
+		{ // 3 null
+			unnests: []*ast.Unnest{
+				{Expr: &ast.FieldRef{Name: "arr"}, Name: "v"},
+			},
+			data: &xsql.Tuple{
+				Emitter: "test",
+				Message: xsql.Message{"a": 1},
+			},
+			result: nil,
+		},
+		{ // 4 not an array
+			unnests: []*ast.Unnest{
+				{Expr: &ast.FieldRef{Name: "arr"}, Name: "v"},
+			},
+			data: &xsql.Tuple{
+				Emitter: "test",
+				Message: xsql.Message{"a": 1, "arr": 3},
+			},
+			result: errors.New("run unnest error: the value to unnest must be an array but got int(3)"),
+		},
+	}
+	contextLogger := conf.Log.WithField("rule", "TestUnnestOp_Apply")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	for i, tt := range tests {
+		pp := &UnnestOp{Unnests: tt.unnests}
+		fv, afv := xsql.NewFunctionValuersForOp(nil)
+		var r interface{}
+		switch result := pp.Apply(ctx, tt.data, fv, afv).(type) {
+		case []xsql.TupleRow:
+			rows := make([]map[string]interface{}, 0, len(result))
+			for _, row := range result {
+				rows = append(rows, row.ToMap())
+			}
+			r = rows
+		default:
+			r = result
+		}
+		if !reflect.DeepEqual(tt.result, r) {
+			t.Errorf("%d.\n\nresult mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.result, r)
+		}
+	}
+}
@@ -58,9 +58,23 @@ func decorateStmt(s *ast.SelectStatement, store kv.KeyValue) ([]*streamInfo, []*
 	// [fieldName][streamsName][*aliasRef] if alias, with special key alias/default. Each key has exactly one value
 	fieldsMap := newFieldsMap(isSchemaless, dsn)
 	if !isSchemaless {
-		for _, streamStmt := range streamStmts {
-			for _, field := range streamStmt.schema {
-				fieldsMap.reserve(field.Name, streamStmt.stmt.Name)
+		if s.MatchRecognize != nil {
+			// the fields of the rows after the pattern recognition
+			for _, name := range s.MatchRecognize.OutputNames() {
+				fieldsMap.reserve(name, streamStmts[0].stmt.Name)
+			}
+		} else {
+			for _, streamStmt := range streamStmts {
+				for _, field := range streamStmt.schema {
+					fieldsMap.reserve(field.Name, streamStmt.stmt.Name)
+				}
+			}
+			// the columns set by unnest
+			for _, u := range s.Unnests {
+				fieldsMap.reserve(u.Name, streamStmts[0].stmt.Name)
+				if u.Ordinality != "" {
+					fieldsMap.reserve(u.Ordinality, streamStmts[0].stmt.Name)
+				}
 			}
 		}
 	}
@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -193,17 +193,14 @@ func (p *DataSourcePlan) getAllFields() {
 }
 
 func (p *DataSourcePlan) getProps() error {
+	// if the TIMESTAMP option is not set, the event time is the timestamp of the source tuple
 	if p.iet {
-		if p.streamStmt.Options.TIMESTAMP != "" {
-			p.timestampField = p.streamStmt.Options.TIMESTAMP
-		} else {
-			return fmt.Errorf("preprocessor is set to be event time but stream option TIMESTAMP not found")
-		}
+		p.timestampField = p.streamStmt.Options.TIMESTAMP
 	}
 	if p.streamStmt.Options.TIMESTAMP_FORMAT != "" {
 		p.timestampFormat = p.streamStmt.Options.TIMESTAMP_FORMAT
 	}
-	if strings.EqualFold(p.streamStmt.Options.FORMAT, message.FormatBinary) {
+	if strings.EqualFold(p.streamStmt.Options.FORMAT, message.FormatBinary) && p.streamStmt.Options.SCHEMAID == "" {
 		p.isBinary = true
 	}
 	return nil
@@ -0,0 +1,403 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	store2 "github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// ExplainNode is a node of the physical plan of a rule
+type ExplainNode struct {
+	// Id is the node name in the topo graph such as source_demo, op_2_project and sink_log_0
+	Id          string                 `json:"id"`
+	Type        string                 `json:"type"`
+	Parallelism int                    `json:"parallelism"`
+	Inputs      []string               `json:"inputs,omitempty"`
+	Info        map[string]interface{} `json:"info,omitempty"`
+	// Metrics are the metrics of each instance of the running node
+	Metrics []map[string]interface{} `json:"metrics,omitempty"`
+}
+
+// Explain plans the sql rule and returns the physical plan from the sources to the sinks without running it
+func Explain(rule *api.Rule) ([]*ExplainNode, error) {
+	if rule.Sql == "" {
+		return nil, fmt.Errorf("explain is only supported for the sql rule")
+	}
+	stmt, err := xsql.GetStatementFromSql(rule.Sql)
+	if err != nil {
+		return nil, err
+	}
+	store, err := store2.GetKV("stream")
+	if err != nil {
+		return nil, err
+	}
+	lp, err := createLogicalPlan(stmt, rule.Options, store)
+	if err != nil {
+		return nil, err
+	}
+	e := &explainer{options: rule.Options}
+	e.sources = countSources(lp)
+	last, _ := e.explainOps(lp, 0)
+	for i, m := range rule.Actions {
+		for name, action := range m {
+			props, _ := action.(map[string]interface{})
+			e.add(&ExplainNode{
+				Id:          fmt.Sprintf("sink_%s_%d", name, i),
+				Type:        "sink",
+				Parallelism: concurrencyOf(props),
+				Inputs:      []string{last},
+				Info:        map[string]interface{}{"sinkType": name},
+			})
+		}
+	}
+	return e.nodes, nil
+}
+
+type explainer struct {
+	options *api.RuleOption
+	// sources is the number of the data sources to decide if the filters are pushed down below the join
+	sources int
+	nodes   []*ExplainNode
+}
+
+func (e *explainer) add(n *ExplainNode) {
+	e.nodes = append(e.nodes, n)
+}
+
+// explainOps walks the plan in the same order as buildOps so that the names are the same
+func (e *explainer) explainOps(lp LogicalPlan, index int) (string, int) {
+	var inputs []string
+	newIndex := index
+	for _, c := range lp.Children() {
+		input, ni := e.explainOps(c, newIndex)
+		newIndex = ni
+		inputs = append(inputs, input)
+	}
+	newIndex++
+	n := &ExplainNode{
+		Id:          "op_" + opName(lp, newIndex),
+		Parallelism: 1,
+		Inputs:      inputs,
+	}
+	if isStateless(lp) {
+		n.Parallelism = e.options.Concurrency
+	}
+	n.Type, n.Info = e.describe(lp)
+	switch t := lp.(type) {
+	case *DataSourcePlan:
+		n.Id = "source_" + opName(lp, newIndex)
+	case *WindowPlan:
+		// the window condition is run by a separate filter node before the window
+		if t.condition != nil {
+			f := &ExplainNode{
+				Id:          "op_" + windowFilterName(newIndex),
+				Type:        "filter",
+				Parallelism: e.options.Concurrency,
+				Inputs:      inputs,
+				Info:        map[string]interface{}{"condition": n.Info["condition"]},
+			}
+			delete(n.Info, "condition")
+			e.add(f)
+			n.Inputs = []string{f.Id}
+		}
+	}
+	e.add(n)
+	return n.Id, newIndex
+}
+
+// describe returns the type and the details of the logical plan node
+func (e *explainer) describe(lp LogicalPlan) (string, map[string]interface{}) {
+	var (
+		typ  string
+		info = make(map[string]interface{})
+	)
+	switch t := lp.(type) {
+	case *DataSourcePlan:
+		typ = "source"
+		info["stream"] = string(t.name)
+		info["streamType"] = ast.StreamTypeMap[t.streamStmt.StreamType]
+		if t.streamStmt.Options != nil {
+			info["sourceType"] = t.streamStmt.Options.TYPE
+			info["format"] = t.streamStmt.Options.FORMAT
+		}
+		if t.isWildCard || t.isSchemaless && len(t.fields) == 0 {
+			info["fields"] = []string{"*"}
+		} else {
+			fields := make([]string, 0, len(t.fields))
+			for f := range t.fields {
+				fields = append(fields, f)
+			}
+			sort.Strings(fields)
+			info["fields"] = fields
+		}
+		if t.iet {
+			info["eventTime"] = t.timestampField
+		}
+	case *DedupPlan:
+		typ = "dedup"
+		info["keys"] = e.exprStrings(t.dedup.Keys)
+		info["ttl"] = t.dedup.TTL
+	case *UnnestPlan:
+		typ = "unnest"
+		exprs := make([]string, len(t.unnests))
+		for i, u := range t.unnests {
+			exprs[i] = e.exprString(u.Expr)
+		}
+		info["unnests"] = exprs
+	case *MatchRecognizePlan:
+		typ = "matchRecognize"
+	case *AnalyticFuncsPlan:
+		typ = "analytic"
+		funcs := make([]string, len(t.funcs))
+		for i, f := range t.funcs {
+			funcs[i] = e.exprString(f)
+		}
+		info["funcs"] = funcs
+	case *WindowPlan:
+		typ = "window"
+		if t.condition != nil {
+			info["condition"] = e.exprString(t.condition)
+		}
+		info["windowType"] = windowTypeName(t.wtype)
+		info["length"] = t.length
+		if t.interval > 0 {
+			info["interval"] = t.interval
+		}
+		if len(t.keys) > 0 {
+			keys := make([]string, len(t.keys))
+			for i, k := range t.keys {
+				keys[i] = e.exprString(k.Expr)
+			}
+			info["keys"] = keys
+		}
+		info["eventTime"] = t.isEventTime
+		if len(t.incAggs) > 0 {
+			aggs := make([]string, len(t.incAggs))
+			for i, a := range t.incAggs {
+				aggs[i] = e.exprString(a)
+			}
+			info["incrementalAggregates"] = aggs
+		}
+	case *LookupPlan:
+		typ = "lookup"
+		info["table"] = t.joinExpr.Name
+		info["joinType"] = joinTypeName(t.joinExpr.JoinType)
+		info["keys"] = t.keys
+	case *JoinAlignPlan:
+		typ = "joinAlign"
+		info["emitters"] = t.Emitters
+	case *JoinPlan:
+		typ = "join"
+		if t.interval != nil {
+			typ = "intervalJoin"
+			info["lower"] = t.interval.Lower
+			info["upper"] = t.interval.Upper
+		}
+		joins := make([]map[string]interface{}, len(t.joins))
+		for i, j := range t.joins {
+			joins[i] = map[string]interface{}{
+				"name":     j.Name,
+				"joinType": joinTypeName(j.JoinType),
+				"on":       e.exprString(j.Expr),
+			}
+		}
+		info["joins"] = joins
+	case *FilterPlan:
+		typ = "filter"
+		info["condition"] = e.exprString(t.condition)
+		// the filter is pushed down to the data source before the join
+		if len(lp.Children()) == 1 && e.sources > 1 {
+			if _, ok := lp.Children()[0].(*DataSourcePlan); ok {
+				info["pushedDown"] = true
+			}
+		}
+	case *AggregatePlan:
+		typ = "aggregate"
+		dims := make([]string, 0, len(t.dimensions))
+		for _, d := range t.dimensions {
+			if _, ok := d.Expr.(*ast.Window); ok {
+				continue
+			}
+			dims = append(dims, e.exprString(d.Expr))
+		}
+		info["dimensions"] = dims
+	case *HavingPlan:
+		typ = "having"
+		info["condition"] = e.exprString(t.condition)
+	case *OrderPlan:
+		typ = "order"
+		fields := make([]string, len(t.SortFields))
+		for i, f := range t.SortFields {
+			order := "ASC"
+			if !f.Ascending {
+				order = "DESC"
+			}
+			fields[i] = f.Name + " " + order
+		}
+		info["sortFields"] = fields
+	case *ProjectPlan:
+		typ = "project"
+		fields := make([]string, len(t.fields))
+		for i, f := range t.fields {
+			fields[i] = e.exprString(f.Expr)
+			if f.AName != "" {
+				fields[i] += " AS " + f.AName
+			}
+		}
+		info["fields"] = fields
+	case *ProjectSetPlan:
+		typ = "projectSet"
+	}
+	if len(info) == 0 {
+		info = nil
+	}
+	return typ, info
+}
+
+func countSources(lp LogicalPlan) int {
+	if _, ok := lp.(*DataSourcePlan); ok {
+		return 1
+	}
+	c := 0
+	for _, child := range lp.Children() {
+		c += countSources(child)
+	}
+	return c
+}
+
+func concurrencyOf(props map[string]interface{}) int {
+	if c, ok := props["concurrency"]; ok {
+		if t, err := cast.ToInt(c, cast.CONVERT_SAMEKIND); err == nil && t > 0 {
+			return t
+		}
+	}
+	return 1
+}
+
+func windowTypeName(t ast.WindowType) string {
+	switch t {
+	case ast.TUMBLING_WINDOW:
+		return "tumbling"
+	case ast.HOPPING_WINDOW:
+		return "hopping"
+	case ast.SLIDING_WINDOW:
+		return "sliding"
+	case ast.SESSION_WINDOW:
+		return "session"
+	case ast.COUNT_WINDOW:
+		return "count"
+	default:
+		return ""
+	}
+}
+
+func joinTypeName(t ast.JoinType) string {
+	switch t {
+	case ast.LEFT_JOIN:
+		return "left"
+	case ast.INNER_JOIN:
+		return "inner"
+	case ast.RIGHT_JOIN:
+		return "right"
+	case ast.FULL_JOIN:
+		return "full"
+	case ast.CROSS_JOIN:
+		return "cross"
+	default:
+		return ""
+	}
+}
+
+func (e *explainer) exprStrings(exprs []ast.Expr) []string {
+	r := make([]string, len(exprs))
+	for i, expr := range exprs {
+		r[i] = e.exprString(expr)
+	}
+	return r
+}
+
+// exprString prints the field with the stream name only when there are multiple streams
+func (e *explainer) exprString(expr ast.Expr) string {
+	return exprString(expr, e.sources > 1)
+}
+
+// exprString prints the expression like sql for displaying only, it cannot be parsed back
+func exprString(expr ast.Expr, qualified bool) string {
+	switch e := expr.(type) {
+	case nil:
+		return ""
+	case *ast.BinaryExpr:
+		op := e.OP.String()
+		if op == "[]" || op == "->" {
+			return exprString(e.LHS, qualified) + exprString(e.RHS, qualified)
+		}
+		return exprString(e.LHS, qualified) + " " + op + " " + exprString(e.RHS, qualified)
+	case *ast.ParenExpr:
+		return "(" + exprString(e.Expr, qualified) + ")"
+	case *ast.FieldRef:
+		if e.AliasRef != nil && e.AliasRef.Expression != nil {
+			return exprString(e.AliasRef.Expression, qualified)
+		}
+		if qualified && e.StreamName != "" && e.StreamName != ast.DefaultStream && e.StreamName != ast.AliasStream {
+			return string(e.StreamName) + "." + e.Name
+		}
+		return e.Name
+	case *ast.MetaRef:
+		return "meta(" + e.Name + ")"
+	case *ast.JsonFieldRef:
+		return "->" + e.Name
+	case *ast.ArrowExpr:
+		return "->" + exprString(e.Expr, qualified)
+	case *ast.BracketExpr:
+		return "[" + exprString(e.Expr, qualified) + "]"
+	case *ast.IndexExpr:
+		return exprString(e.Index, qualified)
+	case *ast.ColonExpr:
+		return exprString(e.Start, qualified) + ":" + exprString(e.End, qualified)
+	case *ast.Call:
+		args := make([]string, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = exprString(a, qualified)
+		}
+		return e.Name + "(" + strings.Join(args, ", ") + ")"
+	case *ast.Wildcard:
+		return "*"
+	case *ast.IntegerLiteral:
+		return strconv.Itoa(e.Val)
+	case *ast.NumberLiteral:
+		return strconv.FormatFloat(e.Val, 'g', -1, 64)
+	case *ast.StringLiteral:
+		return strconv.Quote(e.Val)
+	case *ast.BooleanLiteral:
+		return strconv.FormatBool(e.Val)
+	case *ast.TimeLiteral:
+		return e.Val.String()
+	case *ast.ColFuncField:
+		return e.Name
+	default:
+		conf.Log.Debugf("cannot print expression %T", expr)
+		return fmt.Sprintf("<%T>", expr)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestExplain(t *testing.T) {
+	kv, err := store.GetKV("stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := json.Marshal(&xsql.StreamInfo{
+		StreamType: ast.TypeStream,
+		Statement:  `CREATE STREAM explainSrc (id1 BIGINT, temp BIGINT, name string) WITH (DATASOURCE="explainSrc", FORMAT="json");`,
+	})
+	if err := kv.Set("explainSrc", string(s)); err != nil {
+		t.Fatal(err)
+	}
+	defer kv.Delete("explainSrc")
+	rule := &api.Rule{
+		Id:      "explainRule",
+		Sql:     "SELECT temp, abs(id1) AS a FROM explainSrc WHERE temp > 20 GROUP BY TumblingWindow(ss, 10)",
+		Actions: []map[string]interface{}{{"log": map[string]interface{}{"concurrency": 2}}},
+		Options: &api.RuleOption{Concurrency: 3},
+	}
+	nodes, err := Explain(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type brief struct {
+		Id          string
+		Type        string
+		Parallelism int
+		Inputs      []string
+	}
+	exp := []brief{
+		{Id: "source_explainSrc", Type: "source", Parallelism: 1},
+		{Id: "op_2_filter", Type: "filter", Parallelism: 3, Inputs: []string{"source_explainSrc"}},
+		{Id: "op_3_window", Type: "window", Parallelism: 1, Inputs: []string{"op_2_filter"}},
+		{Id: "op_4_project", Type: "project", Parallelism: 3, Inputs: []string{"op_3_window"}},
+		{Id: "sink_log_0", Type: "sink", Parallelism: 2, Inputs: []string{"op_4_project"}},
+	}
+	got := make([]brief, len(nodes))
+	for i, n := range nodes {
+		got[i] = brief{Id: n.Id, Type: n.Type, Parallelism: n.Parallelism, Inputs: n.Inputs}
+	}
+	if !reflect.DeepEqual(exp, got) {
+		t.Fatalf("plan mismatch\nexp=%v\ngot=%v", exp, got)
+	}
+	if c := nodes[1].Info["condition"]; c != "temp > 20" {
+		t.Errorf("filter condition mismatch, got %v", c)
+	}
+	if w := nodes[2].Info["windowType"]; w != "tumbling" {
+		t.Errorf("window type mismatch, got %v", w)
+	}
+	if f := nodes[3].Info["fields"]; !reflect.DeepEqual(f, []string{"temp", "abs(id1) AS a"}) {
+		t.Errorf("project fields mismatch, got %v", f)
+	}
+	if _, err := Explain(&api.Rule{Id: "graphRule", Options: &api.RuleOption{}}); err == nil {
+		t.Errorf("expect error for the graph rule")
+	}
+}
@@ -14,12 +14,17 @@
 
 package planner
 
-import "github.com/lf-edge/ekuiper/pkg/ast"
+import (
+	"github.com/lf-edge/ekuiper/internal/topo/node"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
 
 type JoinPlan struct {
 	baseLogicalPlan
 	from  *ast.Table
 	joins ast.Joins
+	// interval is set for the stream-stream interval join without window
+	interval *node.IntervalJoinConf
 }
 
 func (p JoinPlan) Init() *JoinPlan {
@@ -75,3 +80,98 @@ func (p *JoinPlan) PruneColumns(fields []ast.Expr) error {
 	f := getFields(p.joins)
 	return p.baseLogicalPlan.PruneColumns(append(fields, f...))
 }
+
+// extractIntervalJoin finds the time bound like `r.ts BETWEEN l.ts - INTERVAL '5' SECOND AND l.ts` in the join
+// condition of two streams. It returns nil if there is no such bound
+func extractIntervalJoin(from *ast.Table, joins ast.Joins) *node.IntervalJoinConf {
+	if len(joins) != 1 || joins[0].JoinType != ast.INNER_JOIN {
+		return nil
+	}
+	names := map[string]string{from.Name: from.Name, joins[0].Name: joins[0].Name}
+	if from.Alias != "" {
+		names[from.Alias] = from.Name
+	}
+	if joins[0].Alias != "" {
+		names[joins[0].Alias] = joins[0].Name
+	}
+	var result *node.IntervalJoinConf
+	ast.WalkFunc(joins[0].Expr, func(n ast.Node) bool {
+		if result != nil {
+			return false
+		}
+		if _, ok := n.(*ast.ParenExpr); ok {
+			return true
+		}
+		be, ok := n.(*ast.BinaryExpr)
+		if !ok {
+			return false
+		}
+		switch be.OP {
+		case ast.AND:
+			return true
+		case ast.BETWEEN:
+			result = intervalBound(be, names)
+		}
+		return false
+	})
+	return result
+}
+
+func intervalBound(be *ast.BinaryExpr, names map[string]string) *node.IntervalJoinConf {
+	r, ok := be.LHS.(*ast.FieldRef)
+	if !ok {
+		return nil
+	}
+	b, ok := be.RHS.(*ast.BetweenExpr)
+	if !ok {
+		return nil
+	}
+	ll, lower, ok := timeOffset(b.Lower)
+	if !ok {
+		return nil
+	}
+	lu, upper, ok := timeOffset(b.Higher)
+	if !ok || ll.StreamName != lu.StreamName || ll.Name != lu.Name || lower > upper {
+		return nil
+	}
+	left, ok := names[string(ll.StreamName)]
+	if !ok {
+		return nil
+	}
+	right, ok := names[string(r.StreamName)]
+	if !ok || left == right {
+		return nil
+	}
+	return &node.IntervalJoinConf{
+		Left:  left,
+		Right: right,
+		Lower: lower,
+		Upper: upper,
+	}
+}
+
+// timeOffset parses the bound expression of field, field + integer or field - integer
+func timeOffset(e ast.Expr) (*ast.FieldRef, int64, bool) {
+	switch t := e.(type) {
+	case *ast.FieldRef:
+		return t, 0, true
+	case *ast.ParenExpr:
+		return timeOffset(t.Expr)
+	case *ast.BinaryExpr:
+		f, ok := t.LHS.(*ast.FieldRef)
+		if !ok {
+			return nil, 0, false
+		}
+		v, ok := t.RHS.(*ast.IntegerLiteral)
+		if !ok {
+			return nil, 0, false
+		}
+		switch t.OP {
+		case ast.ADD:
+			return f, int64(v.Val), true
+		case ast.SUB:
+			return f, -int64(v.Val), true
+		}
+	}
+	return nil, 0, false
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/topo/node"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestExtractIntervalJoin(t *testing.T) {
+	tests := []struct {
+		sql string
+		r   *node.IntervalJoinConf
+	}{
+		{ // 0
+			sql: "SELECT * FROM src1 AS a INNER JOIN src2 AS b ON b.ts BETWEEN a.ts - INTERVAL '5' SECOND AND a.ts + INTERVAL 1 MINUTE",
+			r:   &node.IntervalJoinConf{Left: "src1", Right: "src2", Lower: -5000, Upper: 60000},
+		}, { // 1
+			sql: "SELECT * FROM src1 INNER JOIN src2 ON src1.id = src2.id AND (src1.ts BETWEEN src2.ts AND src2.ts + 10)",
+			r:   &node.IntervalJoinConf{Left: "src2", Right: "src1", Lower: 0, Upper: 10},
+		}, { // 2
+			sql: "SELECT * FROM src1 LEFT JOIN src2 ON src2.ts BETWEEN src1.ts AND src1.ts + 10",
+			r:   nil,
+		}, { // 3
+			sql: "SELECT * FROM src1 INNER JOIN src2 ON src1.id = src2.id",
+			r:   nil,
+		}, { // 4
+			sql: "SELECT * FROM src1 INNER JOIN src2 ON src1.ts BETWEEN src1.ts AND src1.ts + 10",
+			r:   nil,
+		}, { // 5
+			sql: "SELECT * FROM src1 INNER JOIN src2 ON src2.ts BETWEEN src1.ts + 10 AND src1.ts",
+			r:   nil,
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		if err != nil {
+			t.Errorf("%d: parse sql %s error: %s", i, tt.sql, err)
+			continue
+		}
+		r := extractIntervalJoin(stmt.Sources[0].(*ast.Table), stmt.Joins)
+		if !reflect.DeepEqual(tt.r, r) {
+			t.Errorf("%d: expect %+v but got %+v", i, tt.r, r)
+		}
+	}
+}
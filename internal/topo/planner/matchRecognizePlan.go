@@ -0,0 +1,58 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import "github.com/lf-edge/ekuiper/pkg/ast"
+
+type MatchRecognizePlan struct {
+	baseLogicalPlan
+	mr *ast.MatchRecognize
+}
+
+func (p MatchRecognizePlan) Init() *MatchRecognizePlan {
+	p.baseLogicalPlan.self = &p
+	return &p
+}
+
+// PushDownPredicate the conditions after the pattern recognition are on its output, so they cannot be pushed down
+func (p *MatchRecognizePlan) PushDownPredicate(condition ast.Expr) (ast.Expr, LogicalPlan) {
+	return condition, p
+}
+
+// PruneColumns the fields of the upper plans are the output of the pattern recognition, only the source fields
+// used by the pattern recognition are needed. The pattern variables of the field refs are removed
+func (p *MatchRecognizePlan) PruneColumns(_ []ast.Expr) error {
+	var fields []ast.Expr
+	collect := func(n ast.Node) bool {
+		if f, ok := n.(*ast.FieldRef); ok {
+			if f.Name == "*" {
+				fields = append(fields, &ast.Wildcard{Token: ast.ASTERISK})
+			} else {
+				fields = append(fields, &ast.FieldRef{StreamName: ast.DefaultStream, Name: f.Name})
+			}
+		}
+		return true
+	}
+	for _, f := range p.mr.PartitionBy {
+		ast.WalkFunc(f, collect)
+	}
+	for _, f := range p.mr.Measures {
+		ast.WalkFunc(f.Expr, collect)
+	}
+	for _, d := range p.mr.Defines {
+		ast.WalkFunc(d.Condition, collect)
+	}
+	return p.baseLogicalPlan.PruneColumns(fields)
+}
@@ -98,10 +98,25 @@ func createTopo(rule *api.Rule, lp LogicalPlan, sources []*node.SourceNode, sink
 			}
 		}
 	}
+	if err := buildDeadLetter(rule, tp); err != nil {
+		return nil, err
+	}
 
 	return tp, nil
 }
 
+// buildDeadLetter creates the dead letter sink which receives the failed data of all the nodes
+func buildDeadLetter(rule *api.Rule, tp *topo.Topo) error {
+	for name, action := range rule.Options.DeadLetter {
+		props, ok := action.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expect map[string]interface{} type for the deadLetter properties, but found %v", action)
+		}
+		tp.SetDeadLetter(node.NewSinkNode("deadLetter_"+name, name, props))
+	}
+	return nil
+}
+
 func buildOps(lp LogicalPlan, tp *topo.Topo, options *api.RuleOption, sources []*node.SourceNode, streamsFromStmt []string, index int) (api.Emitter, int, error) {
 	var inputs []api.Emitter
 	newIndex := index
@@ -115,8 +130,9 @@ func buildOps(lp LogicalPlan, tp *topo.Topo, options *api.RuleOption, sources []
 	}
 	newIndex++
 	var (
-		op  api.Emitter
-		err error
+		op   api.Emitter
+		err  error
+		name = opName(lp, newIndex)
 	)
 	switch t := lp.(type) {
 	case *DataSourcePlan:
@@ -127,42 +143,64 @@ func buildOps(lp LogicalPlan, tp *topo.Topo, options *api.RuleOption, sources []
 		tp.AddSrc(srcNode)
 		inputs = []api.Emitter{srcNode}
 		op = srcNode
+	case *DedupPlan:
+		op, err = node.NewDedupNode(name, t.dedup, options)
+	case *UnnestPlan:
+		op = Transform(&operator.UnnestOp{Unnests: t.unnests}, name, options)
+	case *MatchRecognizePlan:
+		op, err = node.NewMatchRecognizeNode(name, t.mr, options)
 	case *AnalyticFuncsPlan:
-		op = Transform(&operator.AnalyticFuncsOp{Funcs: t.funcs}, fmt.Sprintf("%d_analytic", newIndex), options)
+		op = Transform(&operator.AnalyticFuncsOp{Funcs: t.funcs}, name, options)
 	case *WindowPlan:
 		if t.condition != nil {
-			wfilterOp := Transform(&operator.FilterOp{Condition: t.condition}, fmt.Sprintf("%d_windowFilter", newIndex), options)
+			wfilterOp := Transform(&operator.FilterOp{Condition: t.condition}, windowFilterName(newIndex), options)
 			wfilterOp.SetConcurrency(options.Concurrency)
+			// the window requires the order of the data, so only scale when the order of each key is enough
+			if options.AutoScale != nil && len(t.keys) > 0 {
+				wfilterOp.SetAutoScale(options.AutoScale, t.keys)
+			}
 			tp.AddOperator(inputs, wfilterOp)
 			inputs = []api.Emitter{wfilterOp}
 		}
 
-		op, err = node.NewWindowOp(fmt.Sprintf("%d_window", newIndex), node.WindowConfig{
-			Type:     t.wtype,
-			Length:   t.length,
-			Interval: t.interval,
+		op, err = node.NewWindowOp(name, node.WindowConfig{
+			Type:            t.wtype,
+			Length:          t.length,
+			Interval:        t.interval,
+			Gap:             t.gap,
+			GapUnit:         t.gapUnit,
+			Keys:            t.keys,
+			AllowedLateness: t.allowedLateness,
+			SideOutput:      t.sideOutput,
+			TimeZone:        t.timeZone,
+			Months:          t.months,
+			IncAggs:         t.incAggs,
 		}, streamsFromStmt, options)
 		if err != nil {
 			return nil, 0, err
 		}
 	case *LookupPlan:
-		op, err = node.NewLookupNode(t.joinExpr.Name, t.fields, t.keys, t.joinExpr.JoinType, t.valvars, t.options, options)
+		op, err = node.NewLookupNode(name, t.fields, t.keys, t.joinExpr.JoinType, t.valvars, t.options, options)
 	case *JoinAlignPlan:
-		op, err = node.NewJoinAlignNode(fmt.Sprintf("%d_join_aligner", newIndex), t.Emitters, options)
+		op, err = node.NewJoinAlignNode(name, t.Emitters, options)
 	case *JoinPlan:
-		op = Transform(&operator.JoinOp{Joins: t.joins, From: t.from}, fmt.Sprintf("%d_join", newIndex), options)
+		if t.interval != nil {
+			op, err = node.NewIntervalJoinNode(name, t.interval, &operator.JoinOp{Joins: t.joins, From: t.from}, options)
+		} else {
+			op = Transform(&operator.JoinOp{Joins: t.joins, From: t.from}, name, options)
+		}
 	case *FilterPlan:
-		op = Transform(&operator.FilterOp{Condition: t.condition}, fmt.Sprintf("%d_filter", newIndex), options)
+		op = Transform(&operator.FilterOp{Condition: t.condition}, name, options)
 	case *AggregatePlan:
-		op = Transform(&operator.AggregateOp{Dimensions: t.dimensions}, fmt.Sprintf("%d_aggregate", newIndex), options)
+		op = Transform(&operator.AggregateOp{Dimensions: t.dimensions}, name, options)
 	case *HavingPlan:
-		op = Transform(&operator.HavingOp{Condition: t.condition}, fmt.Sprintf("%d_having", newIndex), options)
+		op = Transform(&operator.HavingOp{Condition: t.condition}, name, options)
 	case *OrderPlan:
-		op = Transform(&operator.OrderOp{SortFields: t.SortFields}, fmt.Sprintf("%d_order", newIndex), options)
+		op = Transform(&operator.OrderOp{SortFields: t.SortFields}, name, options)
 	case *ProjectPlan:
-		op = Transform(&operator.ProjectOp{ColNames: t.colNames, AliasNames: t.aliasNames, AliasFields: t.aliasFields, ExprFields: t.exprFields, IsAggregate: t.isAggregate, AllWildcard: t.allWildcard, WildcardEmitters: t.wildcardEmitters, ExprNames: t.exprNames, SendMeta: t.sendMeta}, fmt.Sprintf("%d_project", newIndex), options)
+		op = Transform(&operator.ProjectOp{ColNames: t.colNames, AliasNames: t.aliasNames, AliasFields: t.aliasFields, ExprFields: t.exprFields, IsAggregate: t.isAggregate, AllWildcard: t.allWildcard, WildcardEmitters: t.wildcardEmitters, ExprNames: t.exprNames, SendMeta: t.sendMeta}, name, options)
 	case *ProjectSetPlan:
-		op = Transform(&operator.ProjectSetOperator{SrfMapping: t.SrfMapping}, fmt.Sprintf("%d_projectset", newIndex), options)
+		op = Transform(&operator.ProjectSetOperator{SrfMapping: t.SrfMapping}, name, options)
 	default:
 		err = fmt.Errorf("unknown logical plan %v", t)
 	}
@@ -171,6 +209,9 @@ func buildOps(lp LogicalPlan, tp *topo.Topo, options *api.RuleOption, sources []
 	}
 	if uop, ok := op.(*node.UnaryOperator); ok {
 		uop.SetConcurrency(options.Concurrency)
+		if options.AutoScale != nil && isStateless(lp) {
+			uop.SetAutoScale(options.AutoScale, nil)
+		}
 	}
 	if onode, ok := op.(node.OperatorNode); ok {
 		tp.AddOperator(inputs, onode)
@@ -178,6 +219,62 @@ func buildOps(lp LogicalPlan, tp *topo.Topo, options *api.RuleOption, sources []
 	return op, newIndex, nil
 }
 
+// opName returns the name of the node built from the plan. The index is the order of the plan in the plan tree.
+func opName(lp LogicalPlan, index int) string {
+	switch t := lp.(type) {
+	case *DataSourcePlan:
+		return string(t.name)
+	case *DedupPlan:
+		return fmt.Sprintf("%d_dedup", index)
+	case *UnnestPlan:
+		return fmt.Sprintf("%d_unnest", index)
+	case *MatchRecognizePlan:
+		return fmt.Sprintf("%d_match_recognize", index)
+	case *AnalyticFuncsPlan:
+		return fmt.Sprintf("%d_analytic", index)
+	case *WindowPlan:
+		return fmt.Sprintf("%d_window", index)
+	case *LookupPlan:
+		return t.joinExpr.Name
+	case *JoinAlignPlan:
+		return fmt.Sprintf("%d_join_aligner", index)
+	case *JoinPlan:
+		if t.interval != nil {
+			return fmt.Sprintf("%d_interval_join", index)
+		}
+		return fmt.Sprintf("%d_join", index)
+	case *FilterPlan:
+		return fmt.Sprintf("%d_filter", index)
+	case *AggregatePlan:
+		return fmt.Sprintf("%d_aggregate", index)
+	case *HavingPlan:
+		return fmt.Sprintf("%d_having", index)
+	case *OrderPlan:
+		return fmt.Sprintf("%d_order", index)
+	case *ProjectPlan:
+		return fmt.Sprintf("%d_project", index)
+	case *ProjectSetPlan:
+		return fmt.Sprintf("%d_projectset", index)
+	default:
+		return ""
+	}
+}
+
+// windowFilterName returns the name of the filter node before the window for the window filter condition
+func windowFilterName(index int) string {
+	return fmt.Sprintf("%d_windowFilter", index)
+}
+
+// isStateless returns whether the operator of the plan can process the data concurrently in any order
+func isStateless(lp LogicalPlan) bool {
+	switch lp.(type) {
+	case *FilterPlan, *HavingPlan, *ProjectPlan, *ProjectSetPlan:
+		return true
+	default:
+		return false
+	}
+}
+
 func transformSourceNode(t *DataSourcePlan, sources []*node.SourceNode, options *api.RuleOption) (*node.SourceNode, error) {
 	isSchemaless := t.isSchemaless
 	switch t.streamStmt.StreamType {
@@ -200,6 +297,8 @@ func transformSourceNode(t *DataSourcePlan, sources []*node.SourceNode, options
 				schema = nil
 			}
 			sourceNode = node.NewSourceNode(string(t.name), t.streamStmt.StreamType, pp, t.streamStmt.Options, options.SendError, schema)
+			sourceNode.SetQuota(options.Quota)
+			sourceNode.SetBackPressure(options.BackPressure)
 			srcNode = sourceNode
 		} else {
 			srcNode = getMockSource(sources, string(t.name))
@@ -223,6 +322,8 @@ func transformSourceNode(t *DataSourcePlan, sources []*node.SourceNode, options
 				schema = nil
 			}
 			srcNode = node.NewSourceNode(string(t.name), t.streamStmt.StreamType, pp, t.streamStmt.Options, options.SendError, schema)
+			srcNode.SetQuota(options.Quota)
+			srcNode.SetBackPressure(options.BackPressure)
 		}
 		return srcNode, nil
 	}
@@ -279,6 +380,36 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 			}
 		}
 	}
+	if stmt.Deduplicate != nil {
+		if len(children) == 0 {
+			return nil, errors.New("cannot run DEDUPLICATE BY for TABLE sources")
+		}
+		p = DedupPlan{
+			dedup: stmt.Deduplicate,
+		}.Init()
+		p.SetChildren(children)
+		children = []LogicalPlan{p}
+	}
+	if len(stmt.Unnests) > 0 {
+		if len(children) == 0 {
+			return nil, errors.New("cannot run UNNEST for TABLE sources")
+		}
+		p = UnnestPlan{
+			unnests: stmt.Unnests,
+		}.Init()
+		p.SetChildren(children)
+		children = []LogicalPlan{p}
+	}
+	if stmt.MatchRecognize != nil {
+		if len(children) == 0 {
+			return nil, errors.New("cannot run MATCH_RECOGNIZE for TABLE sources")
+		}
+		p = MatchRecognizePlan{
+			mr: stmt.MatchRecognize,
+		}.Init()
+		p.SetChildren(children)
+		children = []LogicalPlan{p}
+	}
 	if len(analyticFuncs) > 0 {
 		p = AnalyticFuncsPlan{
 			funcs: analyticFuncs,
@@ -306,17 +437,39 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 			if w.Filter != nil {
 				wp.condition = w.Filter
 			}
+			if w.Gap != nil {
+				wp.gap = w.Gap
+				wp.gapUnit = w.GapUnit
+			}
+			wp.keys = dimensions.GetGroups()
+			if w.AllowedLateness != nil {
+				wp.allowedLateness = int64(w.AllowedLateness.Val)
+				wp.sideOutput = w.SideOutput
+			}
+			wp.timeZone = w.TimeZone
+			wp.months = w.Months
 			// TODO calculate limit
-			// TODO incremental aggregate
+			wp.incAggs = extractIncAggs(stmt, w, opt)
 			wp.SetChildren(children)
 			children = []LogicalPlan{wp}
 			p = wp
 		}
 	}
-	if stmt.Joins != nil {
-		if len(lookupTableChildren) == 0 && len(scanTableChildren) == 0 && w == nil {
+	if stmt.Joins != nil && len(lookupTableChildren) == 0 && len(scanTableChildren) == 0 && w == nil {
+		// join streams without window by the time bound in the join condition
+		from := stmt.Sources[0].(*ast.Table)
+		interval := extractIntervalJoin(from, stmt.Joins)
+		if interval == nil {
 			return nil, errors.New("a time window or count window is required to join multiple streams")
 		}
+		p = JoinPlan{
+			from:     from,
+			joins:    stmt.Joins,
+			interval: interval,
+		}.Init()
+		p.SetChildren(children)
+		children = []LogicalPlan{p}
+	} else if stmt.Joins != nil {
 		if len(lookupTableChildren) > 0 {
 			var joins []ast.Join
 			for _, join := range stmt.Joins {
@@ -390,6 +390,9 @@ func PlanByGraph(rule *api.Rule) (*topo.Topo, error) {
 			tp.AddOperator(inputs, n.(node.OperatorNode))
 		}
 	}
+	if err := buildDeadLetter(rule, tp); err != nil {
+		return nil, err
+	}
 	return tp, nil
 }
 
@@ -493,11 +496,13 @@ func parseSource(nodeName string, gn *api.GraphNode, rule *api.Rule, store kv.Ke
 		sourceOption.TYPE = gn.NodeType
 		switch sourceMeta.SourceType {
 		case "stream":
-			pp, err := operator.NewPreprocessor(true, nil, true, nil, rule.Options.IsEventTime, sourceOption.TIMESTAMP, sourceOption.TIMESTAMP_FORMAT, strings.EqualFold(sourceOption.FORMAT, message.FormatBinary), sourceOption.STRICT_VALIDATION)
+			pp, err := operator.NewPreprocessor(true, nil, true, nil, rule.Options.IsEventTime, sourceOption.TIMESTAMP, sourceOption.TIMESTAMP_FORMAT, strings.EqualFold(sourceOption.FORMAT, message.FormatBinary) && sourceOption.SCHEMAID == "", sourceOption.STRICT_VALIDATION)
 			if err != nil {
 				return nil, ILLEGAL, "", err
 			}
 			srcNode := node.NewSourceNode(nodeName, ast.TypeStream, pp, sourceOption, rule.Options.SendError, nil)
+			srcNode.SetQuota(rule.Options.Quota)
+			srcNode.SetBackPressure(rule.Options.BackPressure)
 			return srcNode, STREAM, nodeName, nil
 		case "table":
 			return nil, ILLEGAL, "", fmt.Errorf("anonymouse table source is not supported, please create it prior to the rule")
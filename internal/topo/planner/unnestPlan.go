@@ -0,0 +1,54 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import "github.com/lf-edge/ekuiper/pkg/ast"
+
+type UnnestPlan struct {
+	baseLogicalPlan
+	unnests []*ast.Unnest
+}
+
+func (p UnnestPlan) Init() *UnnestPlan {
+	p.baseLogicalPlan.self = &p
+	return &p
+}
+
+// PushDownPredicate the conditions may refer to the unnest columns, so they cannot be pushed down
+func (p *UnnestPlan) PushDownPredicate(condition ast.Expr) (ast.Expr, LogicalPlan) {
+	return condition, p
+}
+
+// PruneColumns the unnest columns are not in the source, replace them with the fields of the unnest expressions
+func (p *UnnestPlan) PruneColumns(fields []ast.Expr) error {
+	cols := make(map[string]bool)
+	for _, u := range p.unnests {
+		cols[u.Name] = true
+		if u.Ordinality != "" {
+			cols[u.Ordinality] = true
+		}
+	}
+	var ff []ast.Expr
+	for _, f := range fields {
+		if fr, ok := f.(*ast.FieldRef); ok && cols[fr.Name] {
+			continue
+		}
+		ff = append(ff, f)
+	}
+	for _, u := range p.unnests {
+		ff = append(ff, getFields(u.Expr)...)
+	}
+	return p.baseLogicalPlan.PruneColumns(ff)
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"errors"
+	"fmt"
+
+	store2 "github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/topo/node"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// The stages of the rule validation where the errors are found
+const (
+	ValidateStageRule  = "rule"
+	ValidateStageParse = "parse"
+	ValidateStagePlan  = "plan"
+	ValidateStageSink  = "sink"
+)
+
+// ValidateError is an error found by the rule validation. The position is only available for the syntax errors.
+type ValidateError struct {
+	Stage   string `json:"stage"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	// Action is the sink name of the error in the sink stage
+	Action string `json:"action,omitempty"`
+}
+
+// ValidateResult is the result of the dry run of a rule
+type ValidateResult struct {
+	Valid  bool             `json:"valid"`
+	Errors []*ValidateError `json:"errors,omitempty"`
+	Plan   *LogicalNode     `json:"plan,omitempty"`
+}
+
+// LogicalNode is a node of the optimized logical plan tree whose root is the last operator before the sinks
+type LogicalNode struct {
+	Type     string                 `json:"type"`
+	Info     map[string]interface{} `json:"info,omitempty"`
+	Children []*LogicalNode         `json:"children,omitempty"`
+}
+
+// Validate parses, plans and type checks the sql rule against the stream schemas without creating it.
+// The sinks are configured but not connected.
+func Validate(rule *api.Rule) *ValidateResult {
+	r := &ValidateResult{}
+	if rule.Sql == "" {
+		r.addError(ValidateStageRule, fmt.Errorf("validate is only supported for the sql rule"))
+		return r
+	}
+	stmt, err := xsql.ParseSelectStatement(rule.Sql)
+	if err != nil {
+		ve := &ValidateError{Stage: ValidateStageParse, Message: err.Error()}
+		var pe *xsql.ParseError
+		if errors.As(err, &pe) {
+			ve.Message, ve.Line, ve.Column = pe.Message, pe.Line, pe.Column
+		}
+		r.Errors = append(r.Errors, ve)
+		return r
+	}
+	streamsFromStmt := xsql.GetStreams(stmt)
+	if rule.Options.SendMetaToSink && (len(streamsFromStmt) > 1 || stmt.Dimensions != nil) {
+		r.addError(ValidateStageRule, fmt.Errorf("Invalid option sendMetaToSink, it can not be applied to window"))
+	}
+	store, err := store2.GetKV("stream")
+	if err != nil {
+		r.addError(ValidateStagePlan, err)
+		return r
+	}
+	lp, err := createLogicalPlan(stmt, rule.Options, store)
+	if err != nil {
+		r.addError(ValidateStagePlan, err)
+		return r
+	}
+	e := &explainer{options: rule.Options, sources: countSources(lp)}
+	r.Plan = e.logicalNode(lp)
+	// build the physical plan to validate the operators, the topo is never opened
+	if _, err := createTopo(rule, lp, nil, nil, streamsFromStmt); err != nil {
+		r.addError(ValidateStagePlan, err)
+	}
+	for i, m := range rule.Actions {
+		for name, action := range m {
+			props, ok := action.(map[string]interface{})
+			if !ok {
+				// reported by createTopo
+				continue
+			}
+			if err := node.SinkConfigure(name, props); err != nil {
+				r.Errors = append(r.Errors, &ValidateError{Stage: ValidateStageSink, Message: err.Error(), Action: fmt.Sprintf("%s_%d", name, i)})
+			}
+		}
+	}
+	r.Valid = len(r.Errors) == 0
+	return r
+}
+
+func (r *ValidateResult) addError(stage string, err error) {
+	r.Errors = append(r.Errors, &ValidateError{Stage: stage, Message: err.Error()})
+}
+
+func (e *explainer) logicalNode(lp LogicalPlan) *LogicalNode {
+	n := &LogicalNode{}
+	n.Type, n.Info = e.describe(lp)
+	for _, c := range lp.Children() {
+		n.Children = append(n.Children, e.logicalNode(c))
+	}
+	return n
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestValidateRule(t *testing.T) {
+	kv, err := store.GetKV("stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := json.Marshal(&xsql.StreamInfo{
+		StreamType: ast.TypeStream,
+		Statement:  `CREATE STREAM validateSrc (id1 BIGINT, temp BIGINT, name string) WITH (DATASOURCE="validateSrc", FORMAT="json");`,
+	})
+	if err := kv.Set("validateSrc", string(s)); err != nil {
+		t.Fatal(err)
+	}
+	defer kv.Delete("validateSrc")
+	logAction := []map[string]interface{}{{"log": map[string]interface{}{}}}
+
+	r := Validate(&api.Rule{
+		Id:      "validateRule",
+		Sql:     "SELECT temp FROM validateSrc WHERE temp > 20 GROUP BY TumblingWindow(ss, 10)",
+		Actions: logAction,
+		Options: &api.RuleOption{Concurrency: 1},
+	})
+	if !r.Valid || len(r.Errors) > 0 {
+		t.Fatalf("expect valid but got errors %v", r.Errors)
+	}
+	var types []string
+	for n := r.Plan; n != nil; {
+		types = append(types, n.Type)
+		if len(n.Children) == 0 {
+			break
+		}
+		n = n.Children[0]
+	}
+	if exp := []string{"project", "window", "filter", "source"}; !reflect.DeepEqual(exp, types) {
+		t.Errorf("plan mismatch, got %v", types)
+	}
+
+	tests := []struct {
+		sql     string
+		actions []map[string]interface{}
+		stage   string
+		line    int
+	}{
+		{sql: "SELECT temp\nFROM validateSrc\nWHERE temp >", actions: logAction, stage: ValidateStageParse, line: 3},
+		{sql: "SELECT nonexist FROM validateSrc", actions: logAction, stage: ValidateStagePlan},
+		{sql: "SELECT temp FROM validateSrc", actions: []map[string]interface{}{{"nonexist": map[string]interface{}{}}}, stage: ValidateStageSink},
+	}
+	for i, tt := range tests {
+		r := Validate(&api.Rule{Id: "validateRule", Sql: tt.sql, Actions: tt.actions, Options: &api.RuleOption{Concurrency: 1}})
+		if r.Valid || len(r.Errors) != 1 {
+			t.Errorf("%d: expect one error but got %v", i, r.Errors)
+			continue
+		}
+		if e := r.Errors[0]; e.Stage != tt.stage || e.Line != tt.line {
+			t.Errorf("%d: error mismatch, got %+v", i, e)
+		}
+	}
+}
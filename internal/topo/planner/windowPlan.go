@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -14,7 +14,25 @@
 
 package planner
 
-import "github.com/lf-edge/ekuiper/pkg/ast"
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// incAggPrefix is the prefix of the cached fields for the incremental aggregates
+const incAggPrefix = "$$i"
+
+// incAggFuncs are the aggregate functions which can be calculated incrementally
+var incAggFuncs = map[string]bool{
+	"count": true,
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+}
 
 type WindowPlan struct {
 	baseLogicalPlan
@@ -24,6 +42,19 @@ type WindowPlan struct {
 	interval    int // If interval is not set, it is equals to Length
 	limit       int // If limit is not positive, there will be no limit
 	isEventTime bool
+	// gap is the dynamic timeout of the session window
+	gap     ast.Expr
+	gapUnit int
+	// keys are the group by dimensions to separate the sessions of dynamic gap or the watermarks by key
+	keys ast.Dimensions
+	// allowedLateness and sideOutput handle the late events for event time
+	allowedLateness int64
+	sideOutput      string
+	// timeZone and months align the tumbling window to the local calendar
+	timeZone string
+	months   int
+	// incAggs are the aggregate calls calculated incrementally by the window
+	incAggs []*ast.Call
 }
 
 func (p WindowPlan) Init() *WindowPlan {
@@ -53,5 +84,77 @@ func (p *WindowPlan) PushDownPredicate(condition ast.Expr) (ast.Expr, LogicalPla
 
 func (p *WindowPlan) PruneColumns(fields []ast.Expr) error {
 	f := getFields(p.condition)
+	if p.gap != nil {
+		f = append(f, getFields(p.gap)...)
+	}
+	for _, d := range p.keys {
+		f = append(f, getFields(d.Expr)...)
+	}
 	return p.baseLogicalPlan.PruneColumns(append(fields, f...))
 }
+
+// extractIncAggs returns the aggregate calls to calculate incrementally in the window, and marks them as cached so that
+// the downstream operators read the results. Only the processing time hopping and sliding window without join are
+// supported because the tuples arrive in order and the window content is decided by the window itself. The sliding
+// window with WHERE condition is not supported because the condition is not pushed down. All the aggregate calls
+// must be supported, otherwise the window tuples must be scanned anyway.
+func extractIncAggs(stmt *ast.SelectStatement, w *ast.Window, opt *api.RuleOption) []*ast.Call {
+	if opt.IsEventTime || stmt.Joins != nil {
+		return nil
+	}
+	// The disk state backend keeps the inputs in the spill file instead
+	if opt.StateBackend != nil && opt.StateBackend.Type == api.StateBackendDisk {
+		return nil
+	}
+	switch w.WindowType {
+	case ast.HOPPING_WINDOW:
+	case ast.SLIDING_WINDOW:
+		if stmt.Condition != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+	for _, d := range stmt.Dimensions.GetGroups() {
+		if !isTupleExpr(d.Expr) {
+			return nil
+		}
+	}
+	var calls []*ast.Call
+	valid := true
+	walk := func(n ast.Node) bool {
+		if c, ok := n.(*ast.Call); ok && c.FuncType == ast.FuncTypeAgg {
+			if !incAggFuncs[c.Name] || len(c.Args) != 1 || c.Partition != nil || c.WhenExpr != nil || !isTupleExpr(c.Args[0]) {
+				valid = false
+			}
+			calls = append(calls, c)
+			return false
+		}
+		return valid
+	}
+	ast.WalkFunc(stmt.Fields, walk)
+	ast.WalkFunc(stmt.Having, walk)
+	ast.WalkFunc(stmt.SortFields, walk)
+	if !valid || len(calls) == 0 {
+		return nil
+	}
+	for _, c := range calls {
+		c.CachedField = fmt.Sprintf("%s_%s_%d", incAggPrefix, c.Name, c.FuncId)
+		c.Cached = true
+	}
+	return calls
+}
+
+// isTupleExpr returns whether the expression can be evaluated by a single tuple without the window
+func isTupleExpr(expr ast.Expr) bool {
+	r := true
+	ast.WalkFunc(expr, func(n ast.Node) bool {
+		if c, ok := n.(*ast.Call); ok {
+			if c.FuncType != ast.FuncTypeScalar || c.Name == "window_start" || c.Name == "window_end" || function.IsAnalyticFunc(c.Name) {
+				r = false
+			}
+		}
+		return r
+	})
+	return r
+}
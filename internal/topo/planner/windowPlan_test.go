@@ -0,0 +1,85 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestExtractIncAggs(t *testing.T) {
+	tests := []struct {
+		sql         string
+		isEventTime bool
+		disk        bool
+		r           []string
+	}{
+		{ // 0
+			sql: "SELECT count(*), avg(temp) AS a FROM src1 WHERE temp > 20 GROUP BY HOPPINGWINDOW(ss, 10, 5)",
+			r:   []string{"$$i_count_0", "$$i_avg_1"},
+		}, { // 1
+			sql: "SELECT name, round(max(temp)) FROM src1 GROUP BY SLIDINGWINDOW(ss, 10), name HAVING min(temp) > 0 ORDER BY sum(temp)",
+			r:   []string{"$$i_max_0", "$$i_min_2", "$$i_sum_3"},
+		}, { // 2 sliding window with WHERE condition
+			sql: "SELECT count(*) FROM src1 WHERE temp > 20 GROUP BY SLIDINGWINDOW(ss, 10)",
+			r:   nil,
+		}, { // 3
+			sql:         "SELECT count(*) FROM src1 GROUP BY HOPPINGWINDOW(ss, 10, 5)",
+			isEventTime: true,
+			r:           nil,
+		}, { // 4 not supported aggregate function
+			sql: "SELECT count(*), collect(temp) FROM src1 GROUP BY HOPPINGWINDOW(ss, 10, 5)",
+			r:   nil,
+		}, { // 5
+			sql: "SELECT count(*) FROM src1 GROUP BY TUMBLINGWINDOW(ss, 10)",
+			r:   nil,
+		}, { // 6 no aggregate
+			sql: "SELECT * FROM src1 GROUP BY HOPPINGWINDOW(ss, 10, 5)",
+			r:   nil,
+		}, { // 7
+			sql: "SELECT count(*) FROM src1 INNER JOIN src2 ON src1.id1 = src2.id2 GROUP BY HOPPINGWINDOW(ss, 10, 5)",
+			r:   nil,
+		}, { // 8 disk state backend
+			sql:  "SELECT count(*) FROM src1 GROUP BY HOPPINGWINDOW(ss, 10, 5)",
+			disk: true,
+			r:    nil,
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		if err != nil {
+			t.Errorf("%d: parse sql %s error: %s", i, tt.sql, err)
+			continue
+		}
+		opt := &api.RuleOption{IsEventTime: tt.isEventTime}
+		if tt.disk {
+			opt.StateBackend = &api.StateBackend{Type: api.StateBackendDisk}
+		}
+		var r []string
+		for _, c := range extractIncAggs(stmt, stmt.Dimensions.GetWindow(), opt) {
+			if !c.Cached {
+				t.Errorf("%d: call %s is not cached", i, c.Name)
+			}
+			r = append(r, c.CachedField)
+		}
+		if !reflect.DeepEqual(tt.r, r) {
+			t.Errorf("%d: expect %v but got %v", i, tt.r, r)
+		}
+	}
+}
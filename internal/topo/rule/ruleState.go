@@ -19,14 +19,19 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/robfig/cron/v3"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/ruleevent"
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
 	"github.com/lf-edge/ekuiper/internal/topo"
+	"github.com/lf-edge/ekuiper/internal/topo/node"
 	"github.com/lf-edge/ekuiper/internal/topo/planner"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/infra"
 )
@@ -130,6 +135,50 @@ func (rs *RuleState) UpdateTopo(rule *api.Rule) error {
 	}
 }
 
+// drainTimeout is the max milliseconds to wait for the old topo to drain when updating gracefully
+const drainTimeout = 10000
+
+// UpdateTopoGracefully update the rule without dropping the data in flight. The new topology is planned while the old
+// one keeps running. Then the old topology stops ingesting and drains with a final checkpoint if the checkpoint is
+// enabled, so that the new topology restores the latest state when it starts. If the topology structure changes, the
+// state is incompatible, and the new topology starts with empty state.
+func (rs *RuleState) UpdateTopoGracefully(rule *api.Rule) error {
+	tp, err := planner.Plan(rule)
+	if err != nil {
+		return err
+	}
+	rs.Lock()
+	defer rs.Unlock()
+	if rs.triggered == -1 {
+		return fmt.Errorf("rule %s is already deleted", rs.RuleId)
+	}
+	old := rs.Topology
+	if rs.triggered == 1 && old != nil {
+		if err := old.Drain(drainTimeout); err != nil {
+			conf.Log.Warnf("drain rule %s error, the data in flight may be lost: %v", rs.RuleId, err)
+		}
+		old.Cancel()
+		rs.ActionCh <- ActionSignalStop
+		// wait a little to make sure the old topo is stopped
+		time.Sleep(1 * time.Millisecond)
+	}
+	if old == nil || !reflect.DeepEqual(old.GetTopo(), tp.GetTopo()) {
+		conf.Log.Infof("rule %s topology changes, drop the incompatible state", rs.RuleId)
+		if err := store.DropTS(rs.RuleId); err != nil {
+			conf.Log.Warnf("drop rule %s state error: %v", rs.RuleId, err)
+		}
+		if err := state.DropRemoteCheckpoint(rs.RuleId); err != nil {
+			conf.Log.Warnf("drop rule %s remote state error: %v", rs.RuleId, err)
+		}
+	}
+	rs.Rule = rule
+	rs.topoGraph = nil
+	rs.Topology = tp
+	rs.triggered = 1
+	rs.ActionCh <- ActionSignalStart
+	return nil
+}
+
 // Run start to run the two loops, do not access any changeable states
 func (rs *RuleState) run() {
 	var (
@@ -187,12 +236,15 @@ func (rs *RuleState) runTopo(ctx context.Context) {
 		ticker := time.NewTicker(time.Duration(d) * time.Millisecond)
 		defer ticker.Stop()
 		for {
+			errCh := tp.Open()
+			ruleevent.Emit(&ruleevent.Event{RuleId: rs.RuleId, Type: ruleevent.Started})
 			select {
-			case e := <-tp.Open():
+			case e := <-errCh:
 				er = e
 				if er != nil { // Only restart rule for errors
 					tp.GetContext().SetError(er)
 					conf.Log.Errorf("closing rule %s for error: %v", rs.RuleId, er)
+					ruleevent.Emit(&ruleevent.Event{RuleId: rs.RuleId, Type: ruleevent.Exception, Message: er.Error()})
 					tp.Cancel()
 				} else { // exit normally
 					return nil
@@ -208,6 +260,7 @@ func (rs *RuleState) runTopo(ctx context.Context) {
 				} else {
 					conf.Log.Infof("Rule %s will restart with delay %d", rs.RuleId, d)
 				}
+				ruleevent.Emit(&ruleevent.Event{RuleId: rs.RuleId, Type: ruleevent.Restarting, Attempt: count + 1, Delay: d})
 				// retry after delay
 				select {
 				case <-ticker.C:
@@ -230,6 +283,7 @@ func (rs *RuleState) runTopo(ctx context.Context) {
 		// The only change the state by error
 		if rs.triggered != -1 {
 			rs.triggered = 0
+			ruleevent.Emit(&ruleevent.Event{RuleId: rs.RuleId, Type: ruleevent.Stopped, Message: err.Error()})
 			if rs.Topology != nil {
 				rs.topoGraph = rs.Topology.GetTopo()
 			}
@@ -258,6 +312,7 @@ func (rs *RuleState) Start() error {
 // Job will do following 2 things:
 // 1. start the rule in cron if else the job is already stopped
 // 2. after the rule started, start an extract goroutine to stop the rule after specific duration
+// If the rule is started in the middle of an activation window, it runs at once until the end of the window.
 func (rs *RuleState) startScheduleRule() error {
 	if rs.cronState.isInSchedule {
 		return fmt.Errorf("rule %s is already in schedule", rs.RuleId)
@@ -269,40 +324,64 @@ func (rs *RuleState) startScheduleRule() error {
 	var cronCtx context.Context
 	cronCtx, rs.cronState.cancel = context.WithCancel(context.Background())
 	entryID, err := backgroundCron.AddFunc(rs.Rule.Options.Cron, func() {
-		if err := func() error {
-			rs.Lock()
-			defer rs.Unlock()
-			return rs.start()
-		}(); err != nil {
-			rs.Lock()
-			rs.cronState.startFailedCnt++
-			rs.Unlock()
-			conf.Log.Errorf(err.Error())
-			return
-		}
-		after := time.After(d)
-		go func(ctx context.Context) {
-			select {
-			case <-after:
-				rs.Lock()
-				defer rs.Unlock()
-				if err := rs.stop(); err != nil {
-					conf.Log.Errorf("close rule %s failed, err: %v", rs.RuleId, err)
-				}
-				return
-			case <-cronCtx.Done():
-				return
-			}
-		}(cronCtx)
+		rs.runScheduled(cronCtx, d)
 	})
 	if err != nil {
 		return err
 	}
 	rs.cronState.isInSchedule = true
 	rs.cronState.entryID = entryID
+	if remaining := activeRemaining(rs.Rule.Options.Cron, d, conf.GetNow()); remaining > 0 {
+		go rs.runScheduled(cronCtx, remaining)
+	}
 	return nil
 }
 
+// runScheduled starts the rule and stops it after the duration unless the schedule is canceled
+func (rs *RuleState) runScheduled(cronCtx context.Context, d time.Duration) {
+	if err := func() error {
+		rs.Lock()
+		defer rs.Unlock()
+		if cronCtx.Err() != nil {
+			return nil
+		}
+		return rs.start()
+	}(); err != nil {
+		rs.Lock()
+		rs.cronState.startFailedCnt++
+		rs.Unlock()
+		conf.Log.Errorf(err.Error())
+		return
+	}
+	after := time.After(d)
+	go func(ctx context.Context) {
+		select {
+		case <-after:
+			rs.Lock()
+			defer rs.Unlock()
+			if err := rs.stop(); err != nil {
+				conf.Log.Errorf("close rule %s failed, err: %v", rs.RuleId, err)
+			}
+			return
+		case <-ctx.Done():
+			return
+		}
+	}(cronCtx)
+}
+
+// activeRemaining returns the remaining time of the activation window which covers now. An activation window starts
+// at a cron trigger time and lasts for the duration. Return 0 if now is not in any window.
+func activeRemaining(spec string, d time.Duration, now time.Time) time.Duration {
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return 0
+	}
+	if begin := sched.Next(now.Add(-d)); !begin.After(now) {
+		return begin.Add(d).Sub(now)
+	}
+	return 0
+}
+
 func (rs *RuleState) start() error {
 	if rs.triggered != 1 {
 		// If the rule has been stopped due to error, the topology is not nil
@@ -339,6 +418,9 @@ func (rs *RuleState) stop() error {
 	if rs.triggered == -1 {
 		return fmt.Errorf("rule %s is already deleted", rs.RuleId)
 	}
+	if rs.triggered == 1 {
+		ruleevent.Emit(&ruleevent.Event{RuleId: rs.RuleId, Type: ruleevent.Stopped})
+	}
 	rs.triggered = 0
 	if rs.Topology != nil {
 		rs.Topology.Cancel()
@@ -355,6 +437,7 @@ func (rs *RuleState) Close() error {
 	}
 	if rs.triggered == 1 && rs.Topology != nil {
 		rs.Topology.Cancel()
+		ruleevent.Emit(&ruleevent.Event{RuleId: rs.RuleId, Type: ruleevent.Stopped, Message: "deleted"})
 	}
 	rs.triggered = -1
 	if rs.Rule.IsScheduleRule() && rs.cronState.isInSchedule {
@@ -369,6 +452,19 @@ func (rs *RuleState) Close() error {
 	return nil
 }
 
+// Tap starts to send the outputs of an operator of the running rule to the tap. It returns the function to stop tapping.
+func (rs *RuleState) Tap(op string, t *node.Tap) (func(), error) {
+	rs.RLock()
+	defer rs.RUnlock()
+	if rs.triggered != 1 || rs.Topology == nil {
+		return nil, fmt.Errorf("rule %s is not running", rs.RuleId)
+	}
+	return rs.Topology.Tap(op, t)
+}
+
+// scheduledState is the state of a schedule rule which is out of its activation windows
+const scheduledState = "Scheduled: waiting for next schedule."
+
 func (rs *RuleState) GetState() (string, error) {
 	rs.RLock()
 	defer rs.RUnlock()
@@ -384,7 +480,7 @@ func (rs *RuleState) GetState() (string, error) {
 				result = "Running"
 			case context.Canceled:
 				if rs.Rule.IsScheduleRule() && rs.cronState.isInSchedule {
-					result = "Stopped: waiting for next schedule."
+					result = scheduledState
 				} else {
 					result = "Stopped: canceled manually."
 				}
@@ -395,7 +491,7 @@ func (rs *RuleState) GetState() (string, error) {
 			}
 		} else {
 			if rs.cronState.isInSchedule {
-				result = "Stopped: waiting for next schedule."
+				result = scheduledState
 			} else {
 				result = "Stopped: canceled manually."
 			}
@@ -191,6 +191,88 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestUpdateGracefully(t *testing.T) {
+	sp := processor.NewStreamProcessor()
+	sp.ExecStmt(`CREATE STREAM demo () WITH (DATASOURCE="users", FORMAT="JSON")`)
+	defer sp.ExecStmt(`DROP STREAM demo`)
+	rs, err := NewRuleState(&api.Rule{
+		Triggered: false,
+		Id:        "testGraceful",
+		Sql:       "SELECT ts FROM demo",
+		Actions: []map[string]interface{}{
+			{
+				"log": map[string]interface{}{},
+			},
+		},
+		Options: defaultOption,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer rs.Close()
+	err = rs.Start()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	tests := []struct {
+		r *api.Rule
+		e error
+	}{
+		{
+			r: &api.Rule{
+				Triggered: false,
+				Id:        "testGraceful",
+				Sql:       "SELECT * FROM demo1",
+				Actions: []map[string]interface{}{
+					{
+						"log": map[string]interface{}{},
+					},
+				},
+				Options: defaultOption,
+			},
+			e: errors.New("fail to get stream demo1, please check if stream is created"),
+		},
+		{
+			r: &api.Rule{
+				Triggered: false,
+				Id:        "testGraceful",
+				Sql:       "SELECT * FROM demo",
+				Actions: []map[string]interface{}{
+					{
+						"log": map[string]interface{}{},
+					},
+				},
+				Options: defaultOption,
+			},
+			e: nil,
+		},
+		{
+			r: &api.Rule{
+				Triggered: false,
+				Id:        "testGraceful",
+				Sql:       "SELECT * FROM demo WHERE ts > 10",
+				Actions: []map[string]interface{}{
+					{
+						"log": map[string]interface{}{},
+					},
+				},
+				Options: defaultOption,
+			},
+			e: nil,
+		},
+	}
+	for i, tt := range tests {
+		err = rs.UpdateTopoGracefully(tt.r)
+		if !reflect.DeepEqual(err, tt.e) {
+			t.Errorf("%d.\n\nerror mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.e, err)
+		}
+	}
+	require.Equal(t, 1, rs.triggered)
+	require.Equal(t, "SELECT * FROM demo WHERE ts > 10", rs.Rule.Sql)
+}
+
 func TestMultipleAccess(t *testing.T) {
 	sp := processor.NewStreamProcessor()
 	sp.ExecStmt(`CREATE STREAM demo () WITH (DATASOURCE="users", FORMAT="JSON")`)
@@ -346,7 +428,7 @@ func TestScheduleRule(t *testing.T) {
 	r.Options.Cron = "mockCron"
 	r.Options.Duration = "1s"
 	const ruleStarted = "Running"
-	const ruleStopped = "Stopped: waiting for next schedule."
+	const ruleStopped = "Scheduled: waiting for next schedule."
 	func() {
 		rs, err := NewRuleState(r)
 		if err != nil {
@@ -474,6 +556,46 @@ func TestScheduleRule(t *testing.T) {
 		rs.cronState.isInSchedule = true
 		status, err := rs.GetState()
 		require.NoError(t, err)
-		require.Equal(t, "Stopped: waiting for next schedule.", status)
+		require.Equal(t, "Scheduled: waiting for next schedule.", status)
 	}()
 }
+
+func TestActiveRemaining(t *testing.T) {
+	tests := []struct {
+		spec     string
+		d        time.Duration
+		now      time.Time
+		expected time.Duration
+	}{
+		{
+			spec:     "0 9 * * 1-5",
+			d:        8 * time.Hour,
+			now:      time.Date(2023, 5, 3, 10, 30, 0, 0, time.Local),
+			expected: 6*time.Hour + 30*time.Minute,
+		}, {
+			spec:     "0 9 * * 1-5",
+			d:        8 * time.Hour,
+			now:      time.Date(2023, 5, 3, 18, 0, 0, 0, time.Local),
+			expected: 0,
+		}, {
+			spec:     "0 9 * * 1-5",
+			d:        8 * time.Hour,
+			now:      time.Date(2023, 5, 6, 10, 30, 0, 0, time.Local),
+			expected: 0,
+		}, {
+			spec:     "*/10 * * * *",
+			d:        5 * time.Minute,
+			now:      time.Date(2023, 5, 3, 10, 12, 0, 0, time.Local),
+			expected: 3 * time.Minute,
+		}, {
+			spec:     "mockCron",
+			d:        time.Hour,
+			now:      time.Date(2023, 5, 3, 10, 30, 0, 0, time.Local),
+			expected: 0,
+		},
+	}
+	for i, tt := range tests {
+		r := activeRemaining(tt.spec, tt.d, tt.now)
+		require.Equal(t, tt.expected, r, "case %d", i)
+	}
+}
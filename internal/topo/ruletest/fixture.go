@@ -0,0 +1,162 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruletest
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+type event struct {
+	stream string
+	ts     int64
+	data   map[string]interface{}
+	// sent is closed once the event is sent to the source buffer
+	sent chan struct{}
+}
+
+// feeder sends the fixtures of all streams in the order of the timestamp. The next event is sent only after the
+// previous one is in the buffer of its source.
+type feeder struct {
+	events  []*event
+	streams map[string]chan *event
+}
+
+func newFeeder(inputs map[string][]*Fixture) *feeder {
+	return &feeder{
+		events:  sortFixtures(inputs),
+		streams: make(map[string]chan *event),
+	}
+}
+
+// source creates the source of the stream which may have no fixtures
+func (f *feeder) source(name string) api.Source {
+	ch := make(chan *event)
+	f.streams[name] = ch
+	return &fixtureSource{events: ch}
+}
+
+// run sends the events until stop and returns a channel closed when all events are sent
+func (f *feeder) run(stop <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for _, e := range f.events {
+			e.sent = make(chan struct{})
+			select {
+			case f.streams[e.stream] <- e:
+			case <-stop:
+				return
+			}
+			select {
+			case <-e.sent:
+			case <-stop:
+				return
+			}
+		}
+		close(done)
+	}()
+	return done
+}
+
+type fixtureSource struct {
+	events chan *event
+}
+
+func (s *fixtureSource) Configure(_ string, _ map[string]interface{}) error {
+	return nil
+}
+
+func (s *fixtureSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, _ chan<- error) {
+	for {
+		select {
+		case e := <-s.events:
+			data := e.data
+			if data == nil {
+				data = make(map[string]interface{})
+			}
+			select {
+			case consumer <- api.NewDefaultSourceTupleWithTime(data, map[string]interface{}{"stream": e.stream}, cast.TimeFromUnixMilli(e.ts)):
+				close(e.sent)
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *fixtureSource) Close(_ api.StreamContext) error {
+	return nil
+}
+
+// collector is the sink to collect the outputs of the rule
+type collector struct {
+	sync.Mutex
+	outputs []interface{}
+	// signal is notified when an output comes
+	signal chan struct{}
+}
+
+func newCollector() *collector {
+	return &collector{signal: make(chan struct{}, 1)}
+}
+
+func (c *collector) Configure(_ map[string]interface{}) error {
+	return nil
+}
+
+func (c *collector) Open(_ api.StreamContext) error {
+	return nil
+}
+
+func (c *collector) Collect(ctx api.StreamContext, item interface{}) error {
+	b, _, err := ctx.TransformOutput(item)
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	// the output of the data template may not be json
+	if err := json.Unmarshal(b, &v); err != nil {
+		v = string(b)
+	}
+	c.Lock()
+	c.outputs = append(c.outputs, v)
+	c.Unlock()
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (c *collector) Close(_ api.StreamContext) error {
+	return nil
+}
+
+func (c *collector) count() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.outputs)
+}
+
+func (c *collector) results() []interface{} {
+	c.Lock()
+	defer c.Unlock()
+	return append([]interface{}{}, c.outputs...)
+}
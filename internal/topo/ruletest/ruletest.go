@@ -0,0 +1,240 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ruletest runs a rule with the fixtures as the inputs of its streams and compares the outputs with the
+// expected ones. The rule runs in event time so that the windows are triggered by the timestamps of the fixtures.
+package ruletest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/topo/node"
+	"github.com/lf-edge/ekuiper/internal/topo/planner"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+const (
+	// DefaultTimeout is the default milliseconds to wait for the outputs
+	DefaultTimeout = 5000
+	MaxTimeout     = 60000
+	// settle is the quiet period after the expected outputs are received to catch the unexpected outputs
+	settle = 200 * time.Millisecond
+)
+
+// Fixture is an input event of a stream
+type Fixture struct {
+	// Timestamp is the unix milliseconds of the event. It is the event time if the stream has no TIMESTAMP option.
+	Timestamp int64                  `json:"timestamp"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// Test is a rule test case. Inputs are the fixtures of each stream and Expected are the outputs received by the sink
+// in order. Each output is usually an array of the result rows unless sendSingle is set in the SinkProps.
+type Test struct {
+	Rule        json.RawMessage        `json:"rule"`
+	Inputs      map[string][]*Fixture  `json:"inputs"`
+	Expected    []interface{}          `json:"expected"`
+	IgnoreOrder bool                   `json:"ignoreOrder"`
+	SinkProps   map[string]interface{} `json:"sinkProps"`
+	// Timeout is the milliseconds to wait for the outputs
+	Timeout int `json:"timeout"`
+}
+
+// Result is the result of a rule test. Diffs are the mismatched outputs with the index of the expected output or the
+// actual output if it is unexpected.
+type Result struct {
+	Pass    bool          `json:"pass"`
+	Outputs []interface{} `json:"outputs"`
+	Diffs   []*Diff       `json:"diffs,omitempty"`
+	Errors  []string      `json:"errors,omitempty"`
+}
+
+type Diff struct {
+	Index    int         `json:"index"`
+	Expected interface{} `json:"expected,omitempty"`
+	Actual   interface{} `json:"actual,omitempty"`
+}
+
+var seq atomic.Int64
+
+// Run runs the test of the sql rule. The actions of the rule are replaced by a sink collecting the outputs and the
+// sources are replaced by the fixtures. An error is returned if the test cannot run.
+func Run(rule *api.Rule, t *Test) (*Result, error) {
+	if rule.Sql == "" {
+		return nil, fmt.Errorf("rule test is only supported for the sql rule")
+	}
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	} else if timeout > MaxTimeout {
+		return nil, fmt.Errorf("timeout %d exceeds the max %d", timeout, MaxTimeout)
+	}
+	// the collector has only one instance
+	props := make(map[string]interface{}, len(t.SinkProps))
+	for k, v := range t.SinkProps {
+		if k != "concurrency" {
+			props[k] = v
+		}
+	}
+	c := newCollector()
+	tp, err := planner.PlanSQLWithSourcesAndSinks(testRule(rule), nil, []*node.SinkNode{node.NewSinkNodeWithSink("ruletest", c, props)})
+	if err != nil {
+		return nil, err
+	}
+	f := newFeeder(t.Inputs)
+	found := make(map[string]bool)
+	for _, src := range tp.GetSources() {
+		if sn, ok := src.(*node.SourceNode); ok {
+			found[sn.GetName()] = true
+			sn.SetSource(f.source(sn.GetName()))
+		}
+	}
+	for name := range t.Inputs {
+		if !found[name] {
+			return nil, fmt.Errorf("%s is not a stream or scan table of the rule", name)
+		}
+	}
+
+	r := &Result{}
+	errCh := tp.Open()
+	defer func() {
+		tp.Cancel()
+		tp.RemoveMetrics()
+	}()
+	stop := make(chan struct{})
+	defer close(stop)
+	fed := f.run(stop)
+	deadline := time.NewTimer(time.Duration(timeout) * time.Millisecond)
+	defer deadline.Stop()
+	quiet := time.NewTimer(settle)
+	defer quiet.Stop()
+loop:
+	for {
+		select {
+		case <-fed:
+			fed = nil
+		case <-c.signal:
+			quiet.Reset(settle)
+		case <-quiet.C:
+			// all fixtures are sent and no output comes for a while
+			if fed == nil && c.count() >= len(t.Expected) {
+				break loop
+			}
+			quiet.Reset(settle)
+		case err := <-errCh:
+			if err != nil {
+				r.Errors = append(r.Errors, err.Error())
+			}
+			break loop
+		case <-deadline.C:
+			if fed != nil {
+				r.Errors = append(r.Errors, "timeout to send the fixtures")
+			}
+			break loop
+		}
+	}
+	r.Outputs = c.results()
+	r.Diffs = compare(t.Expected, r.Outputs, t.IgnoreOrder)
+	r.Pass = len(r.Errors) == 0 && len(r.Diffs) == 0
+	return r, nil
+}
+
+// testRule copies the rule to run once in event time without the checkpoint and restart
+func testRule(rule *api.Rule) *api.Rule {
+	opt := *rule.Options
+	if !opt.IsEventTime {
+		opt.IsEventTime = true
+		opt.LateTol = 0
+	}
+	opt.Qos = api.AtMostOnce
+	opt.Restart = &api.RestartStrategy{}
+	opt.Cron, opt.Duration = "", ""
+	opt.Quota, opt.AutoScale, opt.StateBackend, opt.DeadLetter = nil, nil, nil, nil
+	return &api.Rule{
+		Id:      fmt.Sprintf("$$ruletest_%s_%d", rule.Id, seq.Add(1)),
+		Sql:     rule.Sql,
+		Options: &opt,
+	}
+}
+
+func compare(expected []interface{}, actual []interface{}, ignoreOrder bool) []*Diff {
+	var diffs []*Diff
+	if !ignoreOrder {
+		for i := 0; i < len(expected) || i < len(actual); i++ {
+			d := &Diff{Index: i}
+			if i < len(expected) {
+				d.Expected = expected[i]
+			}
+			if i < len(actual) {
+				d.Actual = actual[i]
+			}
+			if i >= len(expected) || i >= len(actual) || !reflect.DeepEqual(d.Expected, d.Actual) {
+				diffs = append(diffs, d)
+			}
+		}
+		return diffs
+	}
+	matched := make([]bool, len(actual))
+	for i, e := range expected {
+		found := false
+		for j, a := range actual {
+			if !matched[j] && reflect.DeepEqual(e, a) {
+				matched[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			diffs = append(diffs, &Diff{Index: i, Expected: e})
+		}
+	}
+	for j, a := range actual {
+		if !matched[j] {
+			diffs = append(diffs, &Diff{Index: j, Actual: a})
+		}
+	}
+	return diffs
+}
+
+// sortFixtures returns the fixtures of all streams in the order of the timestamp. The order of the fixtures with the
+// same timestamp is kept.
+func sortFixtures(inputs map[string][]*Fixture) []*event {
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var events []*event
+	for _, name := range names {
+		var last int64
+		for _, f := range inputs[name] {
+			// the timestamp defaults to the previous one
+			ts := f.Timestamp
+			if ts == 0 {
+				ts = last
+			}
+			last = ts
+			events = append(events, &event{stream: name, ts: ts, data: f.Data})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].ts < events[j].ts
+	})
+	return events
+}
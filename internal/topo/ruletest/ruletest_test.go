@@ -0,0 +1,134 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruletest
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func init() {
+	testx.InitEnv()
+}
+
+func TestRun(t *testing.T) {
+	kv, err := store.GetKV("stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := json.Marshal(&xsql.StreamInfo{
+		StreamType: ast.TypeStream,
+		Statement:  `CREATE STREAM ruletestSrc (temp BIGINT) WITH (DATASOURCE="ruletestSrc", FORMAT="json");`,
+	})
+	if err := kv.Set("ruletestSrc", string(s)); err != nil {
+		t.Fatal(err)
+	}
+	defer kv.Delete("ruletestSrc")
+
+	const base = 1541152480000
+	inputs := map[string][]*Fixture{
+		"ruletestSrc": {
+			{Timestamp: base + 1000, Data: map[string]interface{}{"temp": 10}},
+			{Timestamp: base + 2000, Data: map[string]interface{}{"temp": 30}},
+			{Timestamp: base + 11000, Data: map[string]interface{}{"temp": 40}},
+			{Timestamp: base + 21000, Data: map[string]interface{}{"temp": 5}},
+		},
+	}
+	tests := []struct {
+		sql      string
+		test     *Test
+		pass     bool
+		outputs  []interface{}
+		diffSize int
+	}{
+		{
+			sql: "SELECT count(*) AS c FROM ruletestSrc GROUP BY TumblingWindow(ss, 10)",
+			test: &Test{
+				Inputs:   inputs,
+				Expected: []interface{}{[]interface{}{map[string]interface{}{"c": float64(2)}}, []interface{}{map[string]interface{}{"c": float64(1)}}},
+			},
+			pass: true,
+			outputs: []interface{}{
+				[]interface{}{map[string]interface{}{"c": float64(2)}},
+				[]interface{}{map[string]interface{}{"c": float64(1)}},
+			},
+		}, {
+			sql: "SELECT temp FROM ruletestSrc WHERE temp > 20",
+			test: &Test{
+				Inputs:    inputs,
+				Expected:  []interface{}{map[string]interface{}{"temp": float64(40)}, map[string]interface{}{"temp": float64(30)}},
+				SinkProps: map[string]interface{}{"sendSingle": true},
+			},
+			pass: false,
+			outputs: []interface{}{
+				map[string]interface{}{"temp": float64(30)},
+				map[string]interface{}{"temp": float64(40)},
+			},
+			diffSize: 2,
+		}, {
+			sql: "SELECT temp FROM ruletestSrc WHERE temp > 20",
+			test: &Test{
+				Inputs:      inputs,
+				Expected:    []interface{}{map[string]interface{}{"temp": float64(40)}, map[string]interface{}{"temp": float64(30)}},
+				IgnoreOrder: true,
+				SinkProps:   map[string]interface{}{"sendSingle": true},
+			},
+			pass: true,
+			outputs: []interface{}{
+				map[string]interface{}{"temp": float64(30)},
+				map[string]interface{}{"temp": float64(40)},
+			},
+		},
+	}
+	for i, tt := range tests {
+		rule := &api.Rule{Id: "ruletest", Sql: tt.sql, Options: &api.RuleOption{Concurrency: 1, BufferLength: 1024, SendError: true}}
+		r, err := Run(rule, tt.test)
+		if err != nil {
+			t.Errorf("%d: run error %v", i, err)
+			continue
+		}
+		if r.Pass != tt.pass || len(r.Diffs) != tt.diffSize || len(r.Errors) > 0 {
+			t.Errorf("%d: result mismatch, got %+v", i, r)
+		}
+		if !reflect.DeepEqual(tt.outputs, r.Outputs) {
+			t.Errorf("%d: outputs mismatch\nexp=%v\ngot=%v", i, tt.outputs, r.Outputs)
+		}
+	}
+	_, err = Run(&api.Rule{Id: "ruletest", Sql: "SELECT * FROM ruletestSrc", Options: &api.RuleOption{Concurrency: 1}}, &Test{
+		Inputs: map[string][]*Fixture{"nonexist": {}},
+	})
+	if err == nil {
+		t.Errorf("expect error for the unknown stream")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	exp := []interface{}{"a", "b", "c"}
+	diffs := compare(exp, []interface{}{"a", "c"}, false)
+	if !reflect.DeepEqual(diffs, []*Diff{{Index: 1, Expected: "b", Actual: "c"}, {Index: 2, Expected: "c"}}) {
+		t.Errorf("ordered diffs mismatch, got %v", diffs)
+	}
+	diffs = compare(exp, []interface{}{"c", "a", "d"}, true)
+	if !reflect.DeepEqual(diffs, []*Diff{{Index: 1, Expected: "b"}, {Index: 2, Actual: "d"}}) {
+		t.Errorf("unordered diffs mismatch, got %v", diffs)
+	}
+}
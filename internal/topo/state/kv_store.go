@@ -1,4 +1,4 @@
-// Copyright 2021 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -42,6 +42,7 @@ type KVStore struct {
 	checkpoints []int64
 	max         int
 	ruleId      string
+	remote      remoteStorage // mirror the checkpoints if configured
 }
 
 // Store in path ./data/checkpoint/$ruleId
@@ -55,6 +56,10 @@ func getKVStore(ruleId string) (*KVStore, error) {
 		return nil, err
 	}
 	s := &KVStore{db: db, max: 3, mapStore: &sync.Map{}, ruleId: ruleId}
+	s.remote, err = getRemoteStorage()
+	if err != nil {
+		conf.Log.Warnf("create checkpoint remote storage error, keep the checkpoints locally only: %v", err)
+	}
 	// read data from badger db
 	if err := s.restore(); err != nil {
 		return nil, err
@@ -68,6 +73,20 @@ func (s *KVStore) restore() error {
 	if err != nil {
 		return err
 	}
+	// restore from the remote storage if there is no local checkpoint such as on a new device
+	if k == 0 && s.remote != nil {
+		k, m, err = downloadCheckpoint(s.remote, s.ruleId)
+		if err != nil {
+			conf.Log.Warnf("download checkpoint of rule %s from remote storage error, start without state: %v", s.ruleId, err)
+			return nil
+		}
+		if k > 0 {
+			conf.Log.Infof("restore checkpoint %d of rule %s from remote storage", k, s.ruleId)
+			if _, err := s.db.Set(k, m); err != nil {
+				return err
+			}
+		}
+	}
 	if k > 0 {
 		s.checkpoints = []int64{k}
 		s.mapStore.Store(k, cast.MapToSyncMap(m))
@@ -105,10 +124,16 @@ func (s *KVStore) SaveCheckpoint(checkpointId int64) error {
 				s.checkpoints = s.checkpoints[1:]
 				s.mapStore.Delete(cp)
 			}
-			_, err := s.db.Set(checkpointId, cast.SyncMapToMap(m))
+			state := cast.SyncMapToMap(m)
+			_, err := s.db.Set(checkpointId, state)
 			if err != nil {
 				return fmt.Errorf("save checkpoint err: %v", err)
 			}
+			if s.remote != nil {
+				if err := uploadCheckpoint(s.remote, s.ruleId, checkpointId, state); err != nil {
+					conf.Log.Warnf("upload checkpoint %d of rule %s error: %v", checkpointId, s.ruleId, err)
+				}
+			}
 		}
 	}
 	return nil
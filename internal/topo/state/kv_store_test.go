@@ -25,6 +25,7 @@ import (
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/pkg/store/definition"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 )
 
@@ -280,3 +281,61 @@ func cleanStateData() {
 		conf.Log.Error(err)
 	}
 }
+
+// setupTempStore sets up the sqlite stores in a temp dir of the test, so that the database files opened by the
+// previous tests are not removed under them
+func setupTempStore(t *testing.T) {
+	err := store.Setup(definition.Config{
+		Type:         "sqlite",
+		ExtStateType: "sqlite",
+		Sqlite: definition.SqliteConfig{
+			Path: t.TempDir(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplaceCheckpoints(t *testing.T) {
+	setupTempStore(t)
+	const ruleId = "replaceRule"
+	s, err := getKVStore(ruleId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cid := range []int64{1, 2} {
+		if err := s.SaveState(cid, "op1", map[string]interface{}{"ci": cid}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveCheckpoint(cid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	k, m, err := GetLatestCheckpoint(ruleId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]interface{}{"op1": map[string]interface{}{"ci": int64(2)}}
+	if k != 2 || !reflect.DeepEqual(m, exp) {
+		t.Errorf("latest checkpoint mismatch, got %d %v", k, m)
+	}
+	saved := map[string]interface{}{"op1": map[string]interface{}{"ci": int64(100)}}
+	if err := ReplaceCheckpoints(ruleId, saved); err != nil {
+		t.Fatal(err)
+	}
+	s, err = getKVStore(ruleId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.checkpoints) != 1 {
+		t.Fatalf("expect 1 checkpoint but got %v", s.checkpoints)
+	}
+	ns, err := s.GetOpState("op1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := cast.SyncMapToMap(ns); !reflect.DeepEqual(r, saved["op1"]) {
+		t.Errorf("restored state mismatch, got %v", r)
+	}
+}
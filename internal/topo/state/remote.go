@@ -0,0 +1,176 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+// remoteStorage mirrors the latest checkpoint of each rule to a remote place. The checkpoint of a rule is saved as a
+// single file named by the rule id which is overwritten by each checkpoint.
+type remoteStorage interface {
+	Upload(name string, data []byte) error
+	// Download returns false if the file does not exist
+	Download(name string) ([]byte, bool, error)
+	Delete(name string) error
+}
+
+type remoteCheckpoint struct {
+	Id    int64
+	State map[string]interface{}
+}
+
+var (
+	remoteMu      sync.Mutex
+	remote        remoteStorage
+	remotePrefix  string
+	remoteCreated bool
+	uploaders     = make(map[string]*remoteUploader)
+)
+
+// getRemoteStorage returns nil if the remote storage is not configured
+func getRemoteStorage() (remoteStorage, error) {
+	remoteMu.Lock()
+	defer remoteMu.Unlock()
+	if remoteCreated {
+		return remote, nil
+	}
+	if conf.Config == nil {
+		return nil, nil
+	}
+	c := &conf.Config.Store.CheckpointRemote
+	var err error
+	switch c.Type {
+	case "":
+	case "s3":
+		remote, err = newS3Storage(c)
+	case "sftp":
+		remote, err = newSftpStorage(c)
+	default:
+		err = fmt.Errorf("unknown checkpoint remote type %s", c.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	remotePrefix = c.Prefix
+	remoteCreated = true
+	return remote, nil
+}
+
+func remoteName(ruleId string) string {
+	return path.Join(remotePrefix, ruleId+".checkpoint")
+}
+
+// downloadCheckpoint returns the id 0 if there is no checkpoint in the remote storage
+func downloadCheckpoint(r remoteStorage, ruleId string) (int64, map[string]interface{}, error) {
+	data, found, err := r.Download(remoteName(ruleId))
+	if err != nil || !found {
+		return 0, nil, err
+	}
+	var cp remoteCheckpoint
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&cp); err != nil {
+		return 0, nil, fmt.Errorf("decode remote checkpoint error: %v", err)
+	}
+	return cp.Id, cp.State, nil
+}
+
+// uploadCheckpoint uploads the checkpoint asynchronously so that the slow network does not block the checkpointing
+func uploadCheckpoint(r remoteStorage, ruleId string, checkpointId int64, state map[string]interface{}) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&remoteCheckpoint{Id: checkpointId, State: state}); err != nil {
+		return fmt.Errorf("encode remote checkpoint error: %v", err)
+	}
+	remoteMu.Lock()
+	u, ok := uploaders[ruleId]
+	if !ok {
+		u = &remoteUploader{storage: r, name: remoteName(ruleId)}
+		uploaders[ruleId] = u
+	}
+	remoteMu.Unlock()
+	u.upload(buf.Bytes())
+	return nil
+}
+
+// DropRemoteCheckpoint deletes the checkpoint of the rule in the remote storage if configured
+func DropRemoteCheckpoint(ruleId string) error {
+	r, err := getRemoteStorage()
+	if err != nil || r == nil {
+		return err
+	}
+	remoteMu.Lock()
+	if u, ok := uploaders[ruleId]; ok {
+		u.cancel()
+		delete(uploaders, ruleId)
+	}
+	remoteMu.Unlock()
+	return r.Delete(remoteName(ruleId))
+}
+
+// remoteUploader uploads the checkpoints of a rule one by one. If the checkpoints are produced faster than uploading,
+// only the latest one is uploaded.
+type remoteUploader struct {
+	storage remoteStorage
+	name    string
+
+	mu      sync.Mutex
+	pending []byte
+	running bool
+	// held during uploading
+	uploading sync.Mutex
+}
+
+func (u *remoteUploader) upload(data []byte) {
+	u.mu.Lock()
+	u.pending = data
+	if u.running {
+		u.mu.Unlock()
+		return
+	}
+	u.running = true
+	u.mu.Unlock()
+	go func() {
+		for {
+			u.mu.Lock()
+			data := u.pending
+			u.pending = nil
+			if data == nil {
+				u.running = false
+				u.mu.Unlock()
+				return
+			}
+			u.uploading.Lock()
+			u.mu.Unlock()
+			if err := u.storage.Upload(u.name, data); err != nil {
+				conf.Log.Warnf("upload checkpoint %s to remote storage error: %v", u.name, err)
+			}
+			u.uploading.Unlock()
+		}
+	}()
+}
+
+// cancel drops the pending checkpoint and waits for the uploading one
+func (u *remoteUploader) cancel() {
+	u.mu.Lock()
+	u.pending = nil
+	u.mu.Unlock()
+	u.uploading.Lock()
+	defer u.uploading.Unlock()
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+// s3Storage saves the checkpoints as the objects of a S3 compatible storage. The requests are signed by AWS signature
+// version 4.
+type s3Storage struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+}
+
+func newS3Storage(c *conf.CheckpointRemoteConf) (*s3Storage, error) {
+	u, err := url.Parse(c.S3.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %s", c.S3.Endpoint)
+	}
+	return &s3Storage{
+		endpoint:  u,
+		region:    c.S3.Region,
+		bucket:    c.S3.Bucket,
+		accessKey: c.S3.AccessKey,
+		secretKey: c.S3.SecretKey,
+		pathStyle: c.S3.PathStyle,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (s *s3Storage) Upload(name string, data []byte) error {
+	resp, err := s.do(http.MethodPut, name, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *s3Storage) Download(name string) ([]byte, bool, error) {
+	resp, err := s.do(http.MethodGet, name, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, false, fmt.Errorf("read s3 object %s error: %v", name, err)
+		}
+		return data, true, nil
+	case http.StatusNotFound:
+		return nil, false, nil
+	default:
+		return nil, false, s3Error(resp)
+	}
+}
+
+func (s *s3Storage) Delete(name string) error {
+	resp, err := s.do(http.MethodDelete, name, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *s3Storage) do(method, name string, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	segments := strings.Split(strings.Trim(name, "/"), "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	key := strings.Join(segments, "/")
+	if s.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// sign adds the AWS signature version 4 headers to the request
+func (s *s3Storage) sign(req *http.Request, body []byte, t time.Time) {
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		"host:" + req.URL.Host + "\n" + "x-amz-content-sha256:" + payloadHash + "\n" + "x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func s3Error(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 request %s %s error: %s %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, msg)
+}
@@ -0,0 +1,476 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+// The packet types and the status codes of the SFTP protocol version 3. Only the file operations to save the
+// checkpoints are implemented.
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpRename   = 18
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpExtended = 200
+
+	sftpStatusOk         = 0
+	sftpStatusEOF        = 1
+	sftpStatusNoSuchFile = 2
+
+	sftpFlagRead  = 0x01
+	sftpFlagWrite = 0x02
+	sftpFlagCreat = 0x08
+	sftpFlagTrunc = 0x10
+
+	sftpChunkSize   = 32768
+	sftpPosixRename = "posix-rename@openssh.com"
+)
+
+type sftpStatusError struct {
+	code uint32
+	msg  string
+}
+
+func (e *sftpStatusError) Error() string {
+	return fmt.Sprintf("sftp status %d: %s", e.code, e.msg)
+}
+
+// sftpStorage saves the checkpoints as the files of a SFTP server. The connection is created lazily and recreated
+// after any error.
+type sftpStorage struct {
+	addr   string
+	config *ssh.ClientConfig
+
+	mu     sync.Mutex
+	client *ssh.Client
+	conn   *sftpConn
+}
+
+func newSftpStorage(c *conf.CheckpointRemoteConf) (*sftpStorage, error) {
+	var auth []ssh.AuthMethod
+	if c.Sftp.PrivateKey != "" {
+		key, err := os.ReadFile(c.Sftp.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("read sftp private key error: %v", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse sftp private key error: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if c.Sftp.Password != "" {
+		auth = append(auth, ssh.Password(c.Sftp.Password))
+	}
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if c.Sftp.HostKey != "" {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(c.Sftp.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("parse sftp host key error: %v", err)
+		}
+		hostKeyCallback = ssh.FixedHostKey(key)
+	} else {
+		conf.Log.Warnf("sftp host key is not set, the server will not be verified")
+	}
+	return &sftpStorage{
+		addr: net.JoinHostPort(c.Sftp.Host, strconv.Itoa(c.Sftp.Port)),
+		config: &ssh.ClientConfig{
+			User:            c.Sftp.Username,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         10 * time.Second,
+		},
+	}, nil
+}
+
+// Upload writes to a temp file and then renames it so that the checkpoint file is always complete
+func (s *sftpStorage) Upload(name string, data []byte) error {
+	return s.run(func(c *sftpConn) error {
+		c.mkdirAll(path.Dir(name))
+		tmp := name + ".tmp"
+		h, err := c.open(tmp, sftpFlagWrite|sftpFlagCreat|sftpFlagTrunc)
+		if err != nil {
+			return err
+		}
+		for off := 0; off < len(data); off += sftpChunkSize {
+			end := off + sftpChunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+			if err := c.write(h, uint64(off), data[off:end]); err != nil {
+				_ = c.close(h)
+				return err
+			}
+		}
+		if err := c.close(h); err != nil {
+			return err
+		}
+		return c.rename(tmp, name)
+	})
+}
+
+func (s *sftpStorage) Download(name string) ([]byte, bool, error) {
+	var (
+		data  []byte
+		found bool
+	)
+	err := s.run(func(c *sftpConn) error {
+		h, err := c.open(name, sftpFlagRead)
+		if err != nil {
+			if isSftpNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		defer func() { _ = c.close(h) }()
+		for {
+			chunk, err := c.read(h, uint64(len(data)), sftpChunkSize)
+			if err == io.EOF {
+				found = true
+				return nil
+			} else if err != nil {
+				return err
+			}
+			data = append(data, chunk...)
+		}
+	})
+	return data, found, err
+}
+
+func (s *sftpStorage) Delete(name string) error {
+	return s.run(func(c *sftpConn) error {
+		if err := c.remove(name); err != nil && !isSftpNotFound(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// run executes the operation with the connection. Any error except the status error of the server closes the
+// connection, and the next operation will reconnect.
+func (s *sftpStorage) run(f func(c *sftpConn) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	err := f(s.conn)
+	var se *sftpStatusError
+	if err != nil && !errors.As(err, &se) {
+		_ = s.client.Close()
+		s.client = nil
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *sftpStorage) connect() error {
+	client, err := ssh.Dial("tcp", s.addr, s.config)
+	if err != nil {
+		return fmt.Errorf("connect to sftp server %s error: %v", s.addr, err)
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		_ = client.Close()
+		return err
+	}
+	w, err := session.StdinPipe()
+	if err != nil {
+		_ = client.Close()
+		return err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		_ = client.Close()
+		return err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		_ = client.Close()
+		return fmt.Errorf("start sftp subsystem error: %v", err)
+	}
+	conn := &sftpConn{w: w, r: r}
+	if err := conn.init(); err != nil {
+		_ = client.Close()
+		return err
+	}
+	s.client = client
+	s.conn = conn
+	return nil
+}
+
+func isSftpNotFound(err error) bool {
+	var se *sftpStatusError
+	return errors.As(err, &se) && se.code == sftpStatusNoSuchFile
+}
+
+// sftpConn sends the requests one by one and waits for each response
+type sftpConn struct {
+	w           io.Writer
+	r           io.Reader
+	id          uint32
+	posixRename bool
+}
+
+func (c *sftpConn) init() error {
+	// The init packet has the version instead of the request id
+	if err := c.send(sftpInit, nil); err != nil {
+		return err
+	}
+	t, payload, err := c.recv()
+	if err != nil {
+		return err
+	}
+	if t != sftpVersion {
+		return fmt.Errorf("unexpected sftp packet %d, expect version", t)
+	}
+	p := &sftpPayload{b: payload}
+	if _, err := p.uint32(); err != nil {
+		return err
+	}
+	// read the extension pairs
+	for len(p.b) > 0 {
+		name, err := p.string()
+		if err != nil {
+			return err
+		}
+		if _, err := p.string(); err != nil {
+			return err
+		}
+		if name == sftpPosixRename {
+			c.posixRename = true
+		}
+	}
+	return nil
+}
+
+func (c *sftpConn) send(t byte, payload []byte) error {
+	b := make([]byte, 9, 9+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(5+len(payload)))
+	b[4] = t
+	if t == sftpInit {
+		binary.BigEndian.PutUint32(b[5:], 3)
+	} else {
+		c.id++
+		binary.BigEndian.PutUint32(b[5:], c.id)
+	}
+	_, err := c.w.Write(append(b, payload...))
+	return err
+}
+
+func (c *sftpConn) recv() (byte, []byte, error) {
+	var l [4]byte
+	if _, err := io.ReadFull(c.r, l[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(l[:])
+	if n < 1 || n > 256*1024 {
+		return 0, nil, fmt.Errorf("invalid sftp packet length %d", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return 0, nil, err
+	}
+	return b[0], b[1:], nil
+}
+
+// request sends the request and returns the response type and the payload after the request id
+func (c *sftpConn) request(t byte, payload []byte) (byte, *sftpPayload, error) {
+	if err := c.send(t, payload); err != nil {
+		return 0, nil, err
+	}
+	rt, b, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	p := &sftpPayload{b: b}
+	id, err := p.uint32()
+	if err != nil {
+		return 0, nil, err
+	}
+	if id != c.id {
+		return 0, nil, fmt.Errorf("unexpected sftp response id %d, expect %d", id, c.id)
+	}
+	return rt, p, nil
+}
+
+// requestStatus sends a request which expects a status response
+func (c *sftpConn) requestStatus(t byte, payload []byte) error {
+	rt, p, err := c.request(t, payload)
+	if err != nil {
+		return err
+	}
+	if rt != sftpStatus {
+		return fmt.Errorf("unexpected sftp packet %d, expect status", rt)
+	}
+	return p.status()
+}
+
+func (c *sftpConn) open(name string, flags uint32) (string, error) {
+	b := appendSftpString(nil, name)
+	b = binary.BigEndian.AppendUint32(b, flags)
+	// empty attributes
+	b = binary.BigEndian.AppendUint32(b, 0)
+	rt, p, err := c.request(sftpOpen, b)
+	if err != nil {
+		return "", err
+	}
+	switch rt {
+	case sftpHandle:
+		return p.string()
+	case sftpStatus:
+		if err := p.status(); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("unexpected sftp packet %d, expect handle", rt)
+}
+
+func (c *sftpConn) close(handle string) error {
+	return c.requestStatus(sftpClose, appendSftpString(nil, handle))
+}
+
+func (c *sftpConn) write(handle string, offset uint64, data []byte) error {
+	b := appendSftpString(nil, handle)
+	b = binary.BigEndian.AppendUint64(b, offset)
+	b = appendSftpString(b, string(data))
+	return c.requestStatus(sftpWrite, b)
+}
+
+// read returns io.EOF if reaching the end of the file
+func (c *sftpConn) read(handle string, offset uint64, length uint32) ([]byte, error) {
+	b := appendSftpString(nil, handle)
+	b = binary.BigEndian.AppendUint64(b, offset)
+	b = binary.BigEndian.AppendUint32(b, length)
+	rt, p, err := c.request(sftpRead, b)
+	if err != nil {
+		return nil, err
+	}
+	switch rt {
+	case sftpData:
+		s, err := p.string()
+		return []byte(s), err
+	case sftpStatus:
+		err := p.status()
+		var se *sftpStatusError
+		if errors.As(err, &se) && se.code == sftpStatusEOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("unexpected sftp packet %d, expect data", rt)
+}
+
+func (c *sftpConn) remove(name string) error {
+	return c.requestStatus(sftpRemove, appendSftpString(nil, name))
+}
+
+func (c *sftpConn) rename(from, to string) error {
+	if c.posixRename {
+		b := appendSftpString(nil, sftpPosixRename)
+		b = appendSftpString(b, from)
+		b = appendSftpString(b, to)
+		return c.requestStatus(sftpExtended, b)
+	}
+	// The standard rename fails if the target exists
+	if err := c.remove(to); err != nil && !isSftpNotFound(err) {
+		return err
+	}
+	b := appendSftpString(nil, from)
+	b = appendSftpString(b, to)
+	return c.requestStatus(sftpRename, b)
+}
+
+// mkdirAll creates the directories and ignores the errors because the directory may exist. If the directory cannot be
+// created, the following operation will fail.
+func (c *sftpConn) mkdirAll(dir string) {
+	if dir == "." || dir == "/" {
+		return
+	}
+	c.mkdirAll(path.Dir(dir))
+	b := appendSftpString(nil, dir)
+	b = binary.BigEndian.AppendUint32(b, 0)
+	_ = c.requestStatus(sftpMkdir, b)
+}
+
+func appendSftpString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+type sftpPayload struct {
+	b []byte
+}
+
+func (p *sftpPayload) uint32() (uint32, error) {
+	if len(p.b) < 4 {
+		return 0, errors.New("sftp packet is too short")
+	}
+	v := binary.BigEndian.Uint32(p.b)
+	p.b = p.b[4:]
+	return v, nil
+}
+
+func (p *sftpPayload) string() (string, error) {
+	l, err := p.uint32()
+	if err != nil {
+		return "", err
+	}
+	if uint32(len(p.b)) < l {
+		return "", errors.New("sftp packet is too short")
+	}
+	s := string(p.b[:l])
+	p.b = p.b[l:]
+	return s, nil
+}
+
+// status returns nil for the ok status, otherwise a status error
+func (p *sftpPayload) status() error {
+	code, err := p.uint32()
+	if err != nil {
+		return err
+	}
+	if code == sftpStatusOk {
+		return nil
+	}
+	msg, _ := p.string()
+	return &sftpStatusError{code: code, msg: msg}
+}
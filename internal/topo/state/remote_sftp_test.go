@@ -0,0 +1,240 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+// fakeSftpFS is an in-memory file system served by a fake SFTP server
+type fakeSftpFS struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+func serveFakeSftp(t *testing.T, fs *fakeSftpFS, posix bool) (string, int) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer, _ := ssh.NewSignerFromKey(priv)
+	cfg := &ssh.ServerConfig{PasswordCallback: func(c ssh.ConnMetadata, p []byte) (*ssh.Permissions, error) { return nil, nil }}
+	cfg.AddHostKey(signer)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(nc, cfg)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nch := range chans {
+					ch, creqs, _ := nch.Accept()
+					go func() {
+						for r := range creqs {
+							r.Reply(r.Type == "subsystem", nil)
+							if r.Type == "subsystem" {
+								go handleFakeSftp(ch, fs, posix)
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+func readSftpString(b []byte) (string, []byte) {
+	l := binary.BigEndian.Uint32(b)
+	return string(b[4 : 4+l]), b[4+l:]
+}
+
+func handleFakeSftp(ch ssh.Channel, fs *fakeSftpFS, posix bool) {
+	handles := map[string]string{}
+	for {
+		var lb [4]byte
+		if _, err := io.ReadFull(ch, lb[:]); err != nil {
+			return
+		}
+		b := make([]byte, binary.BigEndian.Uint32(lb[:]))
+		io.ReadFull(ch, b)
+		typ := b[0]
+		id := binary.BigEndian.Uint32(b[1:5])
+		p := b[5:]
+		resp := func(t byte, payload []byte) {
+			out := make([]byte, 9)
+			binary.BigEndian.PutUint32(out, uint32(5+len(payload)))
+			out[4] = t
+			binary.BigEndian.PutUint32(out[5:], id)
+			ch.Write(append(out, payload...))
+		}
+		status := func(code uint32) {
+			pl := binary.BigEndian.AppendUint32(nil, code)
+			pl = appendSftpString(pl, "msg")
+			pl = appendSftpString(pl, "")
+			resp(sftpStatus, pl)
+		}
+		fs.mu.Lock()
+		switch typ {
+		case sftpInit:
+			out := make([]byte, 9)
+			out[4] = sftpVersion
+			binary.BigEndian.PutUint32(out[5:], 3)
+			var ext []byte
+			if posix {
+				ext = appendSftpString(ext, sftpPosixRename)
+				ext = appendSftpString(ext, "1")
+			}
+			binary.BigEndian.PutUint32(out, uint32(5+len(ext)))
+			ch.Write(append(out, ext...))
+		case sftpOpen:
+			name, rest := readSftpString(p)
+			flags := binary.BigEndian.Uint32(rest)
+			if _, ok := fs.files[name]; !ok && flags&sftpFlagCreat == 0 {
+				status(sftpStatusNoSuchFile)
+				break
+			}
+			if flags&sftpFlagTrunc != 0 {
+				fs.files[name] = nil
+			}
+			h := "h" + strconv.Itoa(len(handles))
+			handles[h] = name
+			resp(sftpHandle, appendSftpString(nil, h))
+		case sftpWrite:
+			h, rest := readSftpString(p)
+			off := binary.BigEndian.Uint64(rest)
+			data, _ := readSftpString(rest[8:])
+			f := fs.files[handles[h]]
+			if int(off) != len(f) {
+				status(4)
+				break
+			}
+			fs.files[handles[h]] = append(f, data...)
+			status(0)
+		case sftpRead:
+			h, rest := readSftpString(p)
+			off := binary.BigEndian.Uint64(rest)
+			l := binary.BigEndian.Uint32(rest[8:])
+			f := fs.files[handles[h]]
+			if int(off) >= len(f) {
+				status(sftpStatusEOF)
+				break
+			}
+			end := int(off) + int(l)
+			if end > len(f) {
+				end = len(f)
+			}
+			resp(sftpData, appendSftpString(nil, string(f[off:end])))
+		case sftpClose:
+			status(0)
+		case sftpRemove:
+			name, _ := readSftpString(p)
+			if _, ok := fs.files[name]; !ok {
+				status(sftpStatusNoSuchFile)
+				break
+			}
+			delete(fs.files, name)
+			status(0)
+		case sftpMkdir:
+			name, _ := readSftpString(p)
+			if fs.dirs[name] {
+				status(4)
+				break
+			}
+			fs.dirs[name] = true
+			status(0)
+		case sftpRename, sftpExtended:
+			if typ == sftpExtended {
+				_, p = readSftpString(p)
+			}
+			from, rest := readSftpString(p)
+			to, _ := readSftpString(rest)
+			if _, ok := fs.files[to]; ok && typ == sftpRename {
+				status(4)
+				break
+			}
+			fs.files[to] = fs.files[from]
+			delete(fs.files, from)
+			status(0)
+		}
+		fs.mu.Unlock()
+	}
+}
+
+func TestSftpStorage(t *testing.T) {
+	for _, posix := range []bool{true, false} {
+		fs := &fakeSftpFS{files: map[string][]byte{}, dirs: map[string]bool{}}
+		host, port := serveFakeSftp(t, fs, posix)
+		c := &conf.CheckpointRemoteConf{Type: "sftp"}
+		c.Sftp.Host = host
+		c.Sftp.Port = port
+		c.Sftp.Username = "u"
+		c.Sftp.Password = "p"
+		if err := c.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		s, err := newSftpStorage(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := "/backup/ckpt/r1.checkpoint"
+		if _, found, err := s.Download(name); err != nil || found {
+			t.Fatal(found, err)
+		}
+		big := make([]byte, 100000)
+		rand.Read(big)
+		for i := 0; i < 2; i++ {
+			if err := s.Upload(name, big); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, found, err := s.Download(name)
+		if err != nil || !found || string(data) != string(big) {
+			t.Fatal(len(data), found, err)
+		}
+		if !fs.dirs["/backup"] || !fs.dirs["/backup/ckpt"] || len(fs.files) != 1 {
+			t.Fatal(fs.dirs, len(fs.files))
+		}
+		if err := s.Delete(name); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete(name); err != nil {
+			t.Fatal(err)
+		}
+		if len(fs.files) != 0 {
+			t.Fatal(len(fs.files))
+		}
+	}
+}
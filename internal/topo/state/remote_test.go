@@ -0,0 +1,152 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+type memRemote struct {
+	sync.Mutex
+	files map[string][]byte
+}
+
+func (m *memRemote) Upload(name string, data []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	m.files[name] = data
+	return nil
+}
+
+func (m *memRemote) Download(name string) ([]byte, bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	data, ok := m.files[name]
+	return data, ok, nil
+}
+
+func (m *memRemote) Delete(name string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.files, name)
+	return nil
+}
+
+func TestRemoteCheckpoint(t *testing.T) {
+	const ruleId = "remoteRule"
+	r := &memRemote{files: make(map[string][]byte)}
+	remoteMu.Lock()
+	remote, remotePrefix, remoteCreated = r, "backup", true
+	remoteMu.Unlock()
+	defer func() {
+		remoteMu.Lock()
+		remote, remotePrefix, remoteCreated = nil, "", false
+		remoteMu.Unlock()
+	}()
+
+	setupTempStore(t)
+	s, err := getKVStore(ruleId)
+	require.NoError(t, err)
+	for _, cid := range []int64{1, 2} {
+		require.NoError(t, s.SaveState(cid, "op1", map[string]interface{}{"ci": cid}))
+		require.NoError(t, s.SaveCheckpoint(cid))
+	}
+	require.Eventually(t, func() bool {
+		k, _, err := downloadCheckpoint(r, ruleId)
+		return err == nil && k == 2
+	}, time.Second, 10*time.Millisecond)
+	_, found, _ := r.Download("backup/remoteRule.checkpoint")
+	require.True(t, found)
+
+	// simulate a new device without local state
+	setupTempStore(t)
+	s, err = getKVStore(ruleId)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2}, s.checkpoints)
+	ns, err := s.GetOpState("op1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"ci": int64(2)}, cast.SyncMapToMap(ns))
+
+	require.NoError(t, DropRemoteCheckpoint(ruleId))
+	_, found, _ = r.Download("backup/remoteRule.checkpoint")
+	require.False(t, found)
+}
+
+func TestS3Storage(t *testing.T) {
+	objects := make(map[string][]byte)
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=ak/") || !strings.Contains(auth, "/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(req.Body)
+			if sha256Hex(body) != req.Header.Get("x-amz-content-sha256") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects[req.URL.Path] = body
+		case http.MethodGet:
+			body, ok := objects[req.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(body)
+		case http.MethodDelete:
+			delete(objects, req.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	c := &conf.CheckpointRemoteConf{Type: "s3"}
+	c.S3.Endpoint = server.URL
+	c.S3.Bucket = "ekuiper"
+	c.S3.AccessKey = "ak"
+	c.S3.SecretKey = "sk"
+	c.S3.PathStyle = true
+	require.NoError(t, c.Validate())
+	s, err := newS3Storage(c)
+	require.NoError(t, err)
+
+	_, found, err := s.Download("ckpt/rule1.checkpoint")
+	require.NoError(t, err)
+	require.False(t, found)
+	require.NoError(t, s.Upload("ckpt/rule1.checkpoint", []byte("state")))
+	require.Contains(t, objects, "/ekuiper/ckpt/rule1.checkpoint")
+	data, found, err := s.Download("ckpt/rule1.checkpoint")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("state"), data)
+	require.NoError(t, s.Delete("ckpt/rule1.checkpoint"))
+	require.Empty(t, objects)
+}
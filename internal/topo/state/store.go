@@ -1,4 +1,4 @@
-// Copyright 2021 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -15,6 +15,9 @@
 package state
 
 import (
+	"fmt"
+
+	ts "github.com/lf-edge/ekuiper/internal/pkg/store"
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
@@ -27,3 +30,38 @@ func CreateStore(ruleId string, qos api.Qos) (api.Store, error) {
 		return newMemoryStore(), nil
 	}
 }
+
+// GetLatestCheckpoint returns the latest completed checkpoint of the rule. The id is 0 if there is no checkpoint.
+func GetLatestCheckpoint(ruleId string) (int64, map[string]interface{}, error) {
+	db, err := ts.GetTS(ruleId)
+	if err != nil {
+		return 0, nil, err
+	}
+	var m map[string]interface{}
+	k, err := db.Last(&m)
+	if err != nil {
+		return 0, nil, fmt.Errorf("read the latest checkpoint of rule %s error: %v", ruleId, err)
+	}
+	return k, m, nil
+}
+
+// ReplaceCheckpoints drops all the checkpoints of the rule and saves the state as the only one, so that the rule
+// restores the state when it starts next time. The rule must not be running.
+// The state is saved with the id next to the latest checkpoint, which is always positive thus can be restored.
+func ReplaceCheckpoints(ruleId string, state map[string]interface{}) error {
+	k, _, err := GetLatestCheckpoint(ruleId)
+	if err != nil {
+		return err
+	}
+	if err := ts.DropTS(ruleId); err != nil {
+		return err
+	}
+	db, err := ts.GetTS(ruleId)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Set(k+1, state); err != nil {
+		return fmt.Errorf("save checkpoint of rule %s error: %v", ruleId, err)
+	}
+	return nil
+}
@@ -19,8 +19,10 @@ import (
 	"fmt"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/ruleevent"
 	"github.com/lf-edge/ekuiper/internal/topo/checkpoint"
 	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
 	"github.com/lf-edge/ekuiper/internal/topo/node"
@@ -35,6 +37,7 @@ type Topo struct {
 	sinks              []*node.SinkNode
 	ctx                api.StreamContext
 	cancel             context.CancelFunc
+	srcCancel          context.CancelFunc
 	drain              chan error
 	ops                []node.OperatorNode
 	name               string
@@ -43,6 +46,7 @@ type Topo struct {
 	store              api.Store
 	coordinator        *checkpoint.Coordinator
 	topo               *api.PrintableTopo
+	deadLetter         *node.DeadLetterQueue
 	mu                 sync.Mutex
 }
 
@@ -92,6 +96,13 @@ func (s *Topo) AddSink(inputs []api.Emitter, snk *node.SinkNode) *Topo {
 	return s
 }
 
+// SetDeadLetter sets the dead letter sink of the rule. It is not connected to the other nodes but receives the failed
+// data from them through the context. It does not take part in the checkpoint.
+func (s *Topo) SetDeadLetter(snk *node.SinkNode) *Topo {
+	s.deadLetter = node.NewDeadLetterQueue(snk)
+	return s
+}
+
 func (s *Topo) AddOperator(inputs []api.Emitter, operator node.OperatorNode) *Topo {
 	for _, input := range inputs {
 		input.AddOutput(operator.GetInput())
@@ -122,6 +133,9 @@ func (s *Topo) prepareContext() {
 	if s.ctx == nil || s.ctx.Err() != nil {
 		contextLogger := conf.Log.WithField("rule", s.name)
 		ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+		if s.deadLetter != nil {
+			ctx = kctx.WithValue(ctx, kctx.DeadLetterKey, s.deadLetter)
+		}
 		s.ctx, s.cancel = ctx.WithCancel()
 	}
 }
@@ -145,6 +159,10 @@ func (s *Topo) Open() <-chan error {
 				return fmt.Errorf("topo %s create store error %v", s.name, err)
 			}
 			s.enableCheckpoint()
+			if s.deadLetter != nil {
+				dl := s.deadLetter.GetSink()
+				dl.Open(s.ctx.WithMeta(s.name, dl.GetName(), s.store), s.drain)
+			}
 			// open stream sink, after log sink is ready.
 			for _, snk := range s.sinks {
 				snk.Open(s.ctx.WithMeta(s.name, snk.GetName(), s.store), s.drain)
@@ -156,8 +174,10 @@ func (s *Topo) Open() <-chan error {
 			}
 
 			// open source, if err bail
+			var srcCtx api.StreamContext
+			srcCtx, s.srcCancel = s.ctx.WithCancel()
 			for _, source := range s.sources {
-				source.Open(s.ctx.WithMeta(s.name, source.GetName(), s.store), s.drain)
+				source.Open(srcCtx.WithMeta(s.name, source.GetName(), s.store), s.drain)
 			}
 
 			// activate checkpoint
@@ -174,6 +194,65 @@ func (s *Topo) Open() <-chan error {
 	return s.drain
 }
 
+// drainInterval is the interval in milliseconds to check if the data in flight are all processed
+const drainInterval = 10
+
+// Drain stops the sources by srcCancel and waits until the data in flight are processed by the operators and sinks. If checkpoint
+// is enabled, a final checkpoint is saved so that a new topo of the same rule can restore the latest state.
+// The topo is not cancelled after draining, the caller must cancel it.
+func (s *Topo) Drain(timeout int) error {
+	s.mu.Lock()
+	srcCancel := s.srcCancel
+	coordinator := s.coordinator
+	s.mu.Unlock()
+	if srcCancel == nil {
+		return fmt.Errorf("topo %s is not opened", s.name)
+	}
+	srcCancel()
+	// The node may be processing the data just read from the input, so make sure it is idle twice
+	idleCount := 0
+	for waited := 0; idleCount < 2; waited += drainInterval {
+		if waited >= timeout {
+			return fmt.Errorf("drain topo %s timeout", s.name)
+		}
+		if s.isIdle() {
+			idleCount++
+		} else {
+			idleCount = 0
+		}
+		time.Sleep(drainInterval * time.Millisecond)
+	}
+	if coordinator != nil {
+		return coordinator.Snapshot(timeout)
+	}
+	return nil
+}
+
+// Savepoint saves a checkpoint immediately while the topo keeps running, and waits until it is completed.
+func (s *Topo) Savepoint(timeout int) error {
+	s.mu.Lock()
+	coordinator := s.coordinator
+	s.mu.Unlock()
+	if coordinator == nil {
+		return fmt.Errorf("topo %s does not enable checkpoint, please set the qos", s.name)
+	}
+	return coordinator.Snapshot(timeout)
+}
+
+func (s *Topo) isIdle() bool {
+	for _, op := range s.ops {
+		if ch, _ := op.GetInput(); len(ch) > 0 {
+			return false
+		}
+	}
+	for _, snk := range s.allSinks() {
+		if ch, _ := snk.GetInput(); len(ch) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Topo) enableCheckpoint() error {
 	if s.qos >= api.AtLeastOnce {
 		var sources []checkpoint.StreamTask
@@ -189,11 +268,18 @@ func (s *Topo) enableCheckpoint() error {
 			sinks = append(sinks, r)
 		}
 		c := checkpoint.NewCoordinator(s.name, sources, ops, sinks, s.qos, s.store, s.checkpointInterval, s.ctx)
+		c.OnComplete(func(checkpointId int64) {
+			ruleevent.Emit(&ruleevent.Event{RuleId: s.name, Type: ruleevent.CheckpointCompleted, CheckpointId: checkpointId})
+		})
 		s.coordinator = c
 	}
 	return nil
 }
 
+func (s *Topo) GetSources() []node.DataSourceNode {
+	return s.sources
+}
+
 func (s *Topo) GetCoordinator() *checkpoint.Coordinator {
 	return s.coordinator
 }
@@ -215,7 +301,7 @@ func (s *Topo) GetMetrics() (keys []string, values []interface{}) {
 			}
 		}
 	}
-	for _, sn := range s.sinks {
+	for _, sn := range s.allSinks() {
 		for ins, metrics := range sn.GetMetrics() {
 			for i, v := range metrics {
 				keys = append(keys, "sink_"+sn.GetName()+"_"+strconv.Itoa(ins)+"_"+metric.MetricNames[i])
@@ -233,11 +319,57 @@ func (s *Topo) RemoveMetrics() {
 	for _, so := range s.ops {
 		so.RemoveMetrics(s.name)
 	}
-	for _, sn := range s.sinks {
+	for _, sn := range s.allSinks() {
 		sn.RemoveMetrics(s.name)
 	}
+	metric.CleanRule(s.name)
+}
+
+// allSinks returns the sinks including the dead letter sink
+func (s *Topo) allSinks() []*node.SinkNode {
+	if s.deadLetter == nil {
+		return s.sinks
+	}
+	return append(s.sinks[:len(s.sinks):len(s.sinks)], s.deadLetter.GetSink())
 }
 
 func (s *Topo) GetTopo() *api.PrintableTopo {
 	return s.topo
 }
+
+// tappable is the node whose outputs can be tapped for debugging
+type tappable interface {
+	AddTap(t *node.Tap)
+	RemoveTap(t *node.Tap)
+}
+
+// Tap starts to send the outputs of the node to the tap. The name is the node name in the topo graph like
+// op_2_project or the bare node name. It returns the function to stop tapping.
+func (s *Topo) Tap(name string, t *node.Tap) (func(), error) {
+	var n interface{}
+	for _, src := range s.sources {
+		if name == "source_"+src.GetName() || name == src.GetName() {
+			n = src
+			break
+		}
+	}
+	if n == nil {
+		for _, op := range s.ops {
+			if name == "op_"+op.GetName() || name == op.GetName() {
+				n = op
+				break
+			}
+		}
+	}
+	if n == nil {
+		return nil, fmt.Errorf("operator %s is not found in rule %s", name, s.name)
+	}
+	tn, ok := n.(tappable)
+	if !ok {
+		return nil, fmt.Errorf("operator %s of rule %s cannot be tapped", name, s.name)
+	}
+	tn.AddTap(t)
+	return func() {
+		tn.RemoveTap(t)
+	}, nil
+}
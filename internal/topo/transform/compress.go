@@ -0,0 +1,48 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/compressor"
+)
+
+// WithCompression compresses the output of the transform function with the compression method.
+// The compressor is not thread safe so it is guarded by a mutex for the concurrent collecting.
+func WithCompression(tf TransFunc, compression string) (TransFunc, error) {
+	c, err := compressor.GetCompressor(compression)
+	if err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	return func(d interface{}) ([]byte, bool, error) {
+		bs, transformed, err := tf(d)
+		if err != nil {
+			return nil, false, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		r, err := c.Compress(bs)
+		if err != nil {
+			return nil, false, fmt.Errorf("fail to compress data with %s: %v", compression, err)
+		}
+		// the compressor reuses its buffer, so copy the result before the next compression
+		result := make([]byte, len(r))
+		copy(result, r)
+		return result, transformed, nil
+	}, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/compressor"
+)
+
+func TestWithCompression(t *testing.T) {
+	tf, err := GenTransform("", "json", "", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctf, err := WithCompression(tf, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := compressor.GetDecompressor("gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []map[string]interface{}{{"a": 1}, {"b": "hello"}} {
+		exp, _, err := tf(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, _, err := ctf(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err = d.Decompress(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(r) != string(exp) {
+			t.Errorf("expect %s but got %s", exp, r)
+		}
+	}
+	_, err = WithCompression(tf, "rar")
+	if err == nil || err.Error() != "unsupported compressor: rar" {
+		t.Errorf("expect unsupported error but got %v", err)
+	}
+}
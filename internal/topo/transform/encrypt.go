@@ -0,0 +1,40 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/encryptor"
+)
+
+// WithEncryption encrypts the output of the transform function, which is after the encoding and compression
+func WithEncryption(tf TransFunc, encryption string) (TransFunc, error) {
+	e, err := encryptor.GetEncryptor(encryption)
+	if err != nil {
+		return nil, err
+	}
+	return func(d interface{}) ([]byte, bool, error) {
+		bs, transformed, err := tf(d)
+		if err != nil {
+			return nil, false, err
+		}
+		r, err := e.Encrypt(bs)
+		if err != nil {
+			return nil, false, fmt.Errorf("fail to encrypt data with %s: %v", encryption, err)
+		}
+		return r, transformed, nil
+	}, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/encryptor"
+)
+
+func TestWithEncryption(t *testing.T) {
+	conf.InitConf()
+	conf.Config.Basic.AesKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	defer func() {
+		conf.Config.Basic.AesKey = ""
+	}()
+	tf, err := GenTransform("", "json", "", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etf, err := WithEncryption(tf, "aes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	de, err := encryptor.GetDecryptor("aes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := map[string]interface{}{"a": 1}
+	r, _, err := etf(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = de.Decrypt(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(r) != `{"a":1}` {
+		t.Errorf("expect {\"a\":1} but got %s", r)
+	}
+	_, err = WithEncryption(tf, "des")
+	if err == nil || err.Error() != "unsupported encryptor: des" {
+		t.Errorf("expect unsupported error but got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultFlattenSeparator joins the keys of the nested maps when flattening
+const DefaultFlattenSeparator = "."
+
+// Shaper reshapes the result maps by the declarative sink properties before they are sent to the sink. The fields
+// are excluded and flattened in one pass, and then renamed.
+type Shaper struct {
+	exclude   map[string]struct{}
+	rename    map[string]string
+	flatten   bool
+	separator string
+}
+
+// NewShaper validates the properties and returns nil if there is nothing to reshape
+func NewShaper(excludeFields []string, renameFields map[string]string, flatten bool, separator string) (*Shaper, error) {
+	if len(excludeFields) == 0 && len(renameFields) == 0 && !flatten {
+		return nil, nil
+	}
+	if separator == "" {
+		separator = DefaultFlattenSeparator
+	}
+	s := &Shaper{
+		flatten:   flatten,
+		separator: separator,
+	}
+	if len(excludeFields) > 0 {
+		s.exclude = make(map[string]struct{}, len(excludeFields))
+		for _, f := range excludeFields {
+			s.exclude[f] = struct{}{}
+		}
+	}
+	if len(renameFields) > 0 {
+		keys := make([]string, 0, len(renameFields))
+		for k := range renameFields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		targets := make(map[string]string, len(renameFields))
+		for _, k := range keys {
+			n := renameFields[k]
+			if n == "" {
+				return nil, fmt.Errorf("the new name of field %s cannot be empty", k)
+			}
+			if other, ok := targets[n]; ok {
+				return nil, fmt.Errorf("fields %s and %s cannot be renamed to the same name %s", other, k, n)
+			}
+			targets[n] = k
+		}
+		s.rename = renameFields
+	}
+	return s, nil
+}
+
+// Apply reshapes each map into a new one, the input maps are not changed because they may be shared by other sinks
+func (s *Shaper) Apply(outs []map[string]interface{}) []map[string]interface{} {
+	if outs == nil {
+		return nil
+	}
+	result := make([]map[string]interface{}, len(outs))
+	for i, m := range outs {
+		result[i] = s.shape(m)
+	}
+	return result
+}
+
+func (s *Shaper) shape(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(m))
+	s.collect(result, "", m)
+	if len(s.rename) == 0 {
+		return result
+	}
+	// the renamed fields override the fields with the same name, and the names can be swapped
+	renamed := make(map[string]interface{}, len(result))
+	for k, v := range result {
+		if _, ok := s.rename[k]; !ok {
+			renamed[k] = v
+		}
+	}
+	for k, n := range s.rename {
+		if v, ok := result[k]; ok {
+			renamed[n] = v
+		}
+	}
+	return renamed
+}
+
+// collect copies the fields which are not excluded. The nested maps are flattened if enabled, and the excluded names
+// are the flattened keys such as a.b for them.
+func (s *Shaper) collect(result map[string]interface{}, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		key := prefix + k
+		if _, ok := s.exclude[key]; ok {
+			continue
+		}
+		if s.flatten {
+			if nested, ok := v.(map[string]interface{}); ok {
+				s.collect(result, key+s.separator, nested)
+				continue
+			}
+		}
+		result[key] = v
+	}
+}
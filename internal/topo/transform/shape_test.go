@@ -0,0 +1,115 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestShaper(t *testing.T) {
+	tests := []struct {
+		exclude   []string
+		rename    map[string]string
+		flatten   bool
+		separator string
+		input     []map[string]interface{}
+		result    []map[string]interface{}
+	}{
+		{
+			exclude: []string{"b", "c"},
+			input:   []map[string]interface{}{{"a": 1, "b": 2, "c": map[string]interface{}{"d": 3}}, {"b": 2}},
+			result:  []map[string]interface{}{{"a": 1}, {}},
+		}, {
+			rename: map[string]string{"a": "b", "b": "a", "x": "y"},
+			input:  []map[string]interface{}{{"a": 1, "b": 2, "c": 3}},
+			result: []map[string]interface{}{{"a": 2, "b": 1, "c": 3}},
+		}, {
+			flatten: true,
+			input: []map[string]interface{}{{
+				"a": 1,
+				"b": map[string]interface{}{"c": 2, "d": map[string]interface{}{"e": "v"}, "f": map[string]interface{}{}},
+				"g": []interface{}{map[string]interface{}{"h": 1}},
+			}},
+			result: []map[string]interface{}{{
+				"a":     1,
+				"b.c":   2,
+				"b.d.e": "v",
+				"g":     []interface{}{map[string]interface{}{"h": 1}},
+			}},
+		}, {
+			exclude:   []string{"b_c", "d"},
+			rename:    map[string]string{"b_e": "e"},
+			flatten:   true,
+			separator: "_",
+			input:     []map[string]interface{}{{"a": 1, "b": map[string]interface{}{"c": 2, "e": 3}, "d": map[string]interface{}{"f": 4}}},
+			result:    []map[string]interface{}{{"a": 1, "e": 3}},
+		}, {
+			exclude: []string{"a"},
+			input:   nil,
+			result:  nil,
+		},
+	}
+	for i, tt := range tests {
+		s, err := NewShaper(tt.exclude, tt.rename, tt.flatten, tt.separator)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		r := s.Apply(tt.input)
+		if !reflect.DeepEqual(tt.result, r) {
+			t.Errorf("%d: result mismatch\nexp=%v\ngot=%v", i, tt.result, r)
+		}
+	}
+}
+
+func TestShaperInput(t *testing.T) {
+	s, err := NewShaper(nil, map[string]string{"a": "b"}, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []map[string]interface{}{{"a": 1, "c": map[string]interface{}{"d": 2}}}
+	_ = s.Apply(input)
+	exp := []map[string]interface{}{{"a": 1, "c": map[string]interface{}{"d": 2}}}
+	if !reflect.DeepEqual(exp, input) {
+		t.Errorf("the input is changed to %v", input)
+	}
+}
+
+func TestNewShaper(t *testing.T) {
+	tests := []struct {
+		rename map[string]string
+		err    error
+	}{
+		{
+			rename: map[string]string{"a": ""},
+			err:    errors.New("the new name of field a cannot be empty"),
+		}, {
+			rename: map[string]string{"b": "c", "a": "c"},
+			err:    errors.New("fields a and b cannot be renamed to the same name c"),
+		},
+	}
+	for i, tt := range tests {
+		_, err := NewShaper(nil, tt.rename, false, "")
+		if !reflect.DeepEqual(tt.err, err) {
+			t.Errorf("%d: error mismatch, expect %v but got %v", i, tt.err, err)
+		}
+	}
+	s, err := NewShaper(nil, nil, false, ".")
+	if s != nil || err != nil {
+		t.Errorf("expect nil shaper but got %v, %v", s, err)
+	}
+}
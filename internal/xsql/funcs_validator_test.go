@@ -503,6 +503,16 @@ func TestFuncValidator(t *testing.T) {
 			stmt: nil,
 			err:  "Expect bool type for parameter 2",
 		},
+		{
+			s:    `SELECT top_n(temp) from tbl`,
+			stmt: nil,
+			err:  "Expect 2 arguments but found 1.",
+		},
+		{
+			s:    `SELECT bottom_n(temp, 0) from tbl`,
+			stmt: nil,
+			err:  "Expect positive int type for parameter 2",
+		},
 	}
 
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
@@ -26,13 +26,30 @@ import (
 
 type Scanner struct {
 	r *bufio.Reader
+	// pos is the position of the next rune, prev is the position before the last read to unread
+	pos  Pos
+	prev Pos
+	// tokPos is the start position of the last scanned token
+	tokPos Pos
+}
+
+// Pos is the position in the sql. Line and Column are 1-based.
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
 }
 
 func NewScanner(r io.Reader) *Scanner {
-	return &Scanner{r: bufio.NewReader(r)}
+	return &Scanner{r: bufio.NewReader(r), pos: Pos{Line: 1, Column: 1}}
+}
+
+// Pos returns the start position of the last scanned token
+func (s *Scanner) Pos() Pos {
+	return s.tokPos
 }
 
 func (s *Scanner) Scan() (tok ast.Token, lit string) {
+	s.tokPos = s.pos
 	ch := s.read()
 	if isWhiteSpace(ch) {
 		// s.unread()
@@ -145,6 +162,14 @@ func (s *Scanner) Scan() (tok ast.Token, lit string) {
 		return ast.HASH, ast.Tokens[ast.HASH]
 	case ';':
 		return ast.SEMICOLON, ast.Tokens[ast.SEMICOLON]
+	case '?':
+		return ast.QUESTION, ast.Tokens[ast.QUESTION]
+	case '{':
+		return ast.LBRACE, ast.Tokens[ast.LBRACE]
+	case '}':
+		return ast.RBRACE, ast.Tokens[ast.RBRACE]
+	case '\'':
+		return s.ScanSingleQuoteString()
 	}
 	return ast.ILLEGAL, ""
 }
@@ -240,6 +265,8 @@ func (s *Scanner) ScanIdent() (tok ast.Token, lit string) {
 		return ast.SS, lit
 	case "MS":
 		return ast.MS, lit
+	case "MM":
+		return ast.MM, lit
 	}
 	return ast.IDENT, buf.String()
 }
@@ -271,6 +298,24 @@ func (s *Scanner) ScanString() (tok ast.Token, lit string) {
 	return ast.STRING, r
 }
 
+// ScanSingleQuoteString scans the SQL string literal in single quotes such as '5', the quote is escaped by doubling it
+func (s *Scanner) ScanSingleQuoteString() (tok ast.Token, lit string) {
+	var buf bytes.Buffer
+	for {
+		ch := s.read()
+		if ch == eof {
+			return ast.BADSTRING, "'" + buf.String()
+		} else if ch == '\'' {
+			if next := s.read(); next != '\'' {
+				s.unread()
+				break
+			}
+		}
+		buf.WriteRune(ch)
+	}
+	return ast.STRING, buf.String()
+}
+
 func (s *Scanner) ScanDigit() (tok ast.Token, lit string) {
 	var buf bytes.Buffer
 	ch := s.read()
@@ -379,11 +424,21 @@ func (s *Scanner) read() rune {
 	if err != nil {
 		return eof
 	}
+	s.prev = s.pos
+	if ch == '\n' {
+		s.pos.Line++
+		s.pos.Column = 1
+	} else {
+		s.pos.Column++
+	}
 	return ch
 }
 
 func (s *Scanner) unread() {
-	_ = s.r.UnreadRune()
+	// unread fails if the last read is eof, then the position is not moved either
+	if err := s.r.UnreadRune(); err == nil {
+		s.pos = s.prev
+	}
 }
 
 var eof = rune(0)
@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-collections/collections/stack"
 
@@ -37,6 +38,7 @@ type Parser struct {
 	buf [3]struct {
 		tok ast.Token
 		lit string
+		pos Pos
 	}
 	inFunc      string // currently parsing function name
 	f           int    // anonymous field index number
@@ -68,7 +70,7 @@ func (p *Parser) scan() (tok ast.Token, lit string) {
 	if tok != ast.WS && tok != ast.COMMENT {
 		p.i = (p.i + 1) % len(p.buf)
 		buf := &p.buf[p.i]
-		buf.tok, buf.lit = tok, lit
+		buf.tok, buf.lit, buf.pos = tok, lit, p.s.Pos()
 	}
 
 	return
@@ -80,6 +82,11 @@ func (p *Parser) curr() (ast.Token, string) {
 	return buf.tok, buf.lit
 }
 
+// Pos returns the position of the furthest token read by the parser, which is where the syntax error is found
+func (p *Parser) Pos() Pos {
+	return p.buf[p.i].pos
+}
+
 func (p *Parser) scanIgnoreWhitespace() (tok ast.Token, lit string) {
 	tok, lit = p.scan()
 
@@ -149,11 +156,22 @@ func (p *Parser) Parse() (*ast.SelectStatement, error) {
 	} else {
 		selects.Sources = src
 	}
+	if dedup, err := p.parseDeduplicate(); err != nil {
+		return nil, err
+	} else {
+		selects.Deduplicate = dedup
+	}
+	if mr, err := p.parseMatchRecognize(selects.Sources[0].(*ast.Table)); err != nil {
+		return nil, err
+	} else {
+		selects.MatchRecognize = mr
+	}
 	p.clause = "join"
-	if joins, err := p.parseJoins(); err != nil {
+	if joins, unnests, err := p.parseJoins(); err != nil {
 		return nil, err
 	} else {
 		selects.Joins = joins
+		selects.Unnests = unnests
 	}
 	// The source names may be injected from outside to parse part of the sql
 	if p.sourceNames == nil {
@@ -222,7 +240,7 @@ func (p *Parser) parseSourceLiteral() (string, string, error) {
 	var alias string
 	for {
 		// HASH, DIV & ADD token is specially support for MQTT topic name patterns.
-		if tok, lit := p.scanIgnoreWhitespace(); tok.AllowedSourceToken() {
+		if tok, lit := p.scanIgnoreWhitespace(); tok.AllowedSourceToken() && !isSourceClause(tok, lit) {
 			sourceSeg = append(sourceSeg, lit)
 			if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 == ast.AS {
 				if tok2, lit2 := p.scanIgnoreWhitespace(); tok2 == ast.IDENT {
@@ -230,7 +248,7 @@ func (p *Parser) parseSourceLiteral() (string, string, error) {
 				} else {
 					return "", "", fmt.Errorf("found %q, expected JOIN key word.", lit)
 				}
-			} else if tok1.AllowedSourceToken() {
+			} else if tok1.AllowedSourceToken() && !isSourceClause(tok1, lit1) {
 				sourceSeg = append(sourceSeg, lit1)
 			} else {
 				p.unscan()
@@ -244,6 +262,300 @@ func (p *Parser) parseSourceLiteral() (string, string, error) {
 	return strings.Join(sourceSeg, ""), alias, nil
 }
 
+// isSourceClause returns true if the token starts a clause after the source
+func isSourceClause(tok ast.Token, lit string) bool {
+	return isMatchRecognize(tok, lit) || isDeduplicate(tok, lit)
+}
+
+func isMatchRecognize(tok ast.Token, lit string) bool {
+	return tok == ast.IDENT && strings.EqualFold(lit, "MATCH_RECOGNIZE")
+}
+
+func isDeduplicate(tok ast.Token, lit string) bool {
+	return tok == ast.IDENT && strings.EqualFold(lit, "DEDUPLICATE")
+}
+
+// parseDeduplicate parses the clause like DEDUPLICATE BY (expr, ...) WITHIN INTERVAL 'n' UNIT
+func (p *Parser) parseDeduplicate() (*ast.Deduplicate, error) {
+	if tok, lit := p.scanIgnoreWhitespace(); !isDeduplicate(tok, lit) {
+		p.unscan()
+		return nil, nil
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.BY {
+		return nil, fmt.Errorf("found %q, expected BY after DEDUPLICATE.", lit)
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.LPAREN {
+		return nil, fmt.Errorf("found %q, expected ( after DEDUPLICATE BY.", lit)
+	}
+	d := &ast.Deduplicate{}
+	for {
+		exp, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		d.Keys = append(d.Keys, exp)
+		if tok, lit := p.scanIgnoreWhitespace(); tok == ast.RPAREN {
+			break
+		} else if tok != ast.COMMA {
+			return nil, fmt.Errorf("found %q, expected ) after the keys of DEDUPLICATE BY.", lit)
+		}
+	}
+	if !p.scanKeyword("WITHIN") {
+		_, lit := p.scanIgnoreWhitespace()
+		return nil, fmt.Errorf("found %q, expected WITHIN after DEDUPLICATE BY.", lit)
+	}
+	p.scanKeyword("INTERVAL")
+	ttl, err := p.parseInterval()
+	if err != nil {
+		return nil, err
+	}
+	d.TTL = int64(ttl.(*ast.IntegerLiteral).Val)
+	if d.TTL <= 0 {
+		return nil, fmt.Errorf("the interval of DEDUPLICATE BY must be positive.")
+	}
+	return d, nil
+}
+
+// scanKeyword scans the next token and returns true if it is the non-reserved keyword kw
+func (p *Parser) scanKeyword(kw string) bool {
+	if tok, lit := p.scanIgnoreWhitespace(); tok == ast.IDENT && strings.EqualFold(lit, kw) {
+		return true
+	}
+	p.unscan()
+	return false
+}
+
+// parseMatchRecognize parses the clause like
+// MATCH_RECOGNIZE ([PARTITION BY f1, f2] MEASURES expr AS name, ... [ONE ROW PER MATCH]
+// [AFTER MATCH SKIP PAST LAST ROW | AFTER MATCH SKIP TO NEXT ROW] PATTERN (A B+ C?) [WITHIN INTERVAL 'n' UNIT]
+// DEFINE A AS condition, ...)
+func (p *Parser) parseMatchRecognize(src *ast.Table) (*ast.MatchRecognize, error) {
+	if tok, lit := p.scanIgnoreWhitespace(); !isMatchRecognize(tok, lit) {
+		p.unscan()
+		return nil, nil
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.LPAREN {
+		return nil, fmt.Errorf("found %q, expected ( after MATCH_RECOGNIZE.", lit)
+	}
+	// the pattern variables are used like the stream names in the expressions, validate them at last
+	sn := p.sourceNames
+	p.sourceNames = nil
+	defer func() { p.sourceNames = sn }()
+	mr := &ast.MatchRecognize{}
+	if tok, _ := p.scanIgnoreWhitespace(); tok == ast.PARTITION {
+		if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 != ast.BY {
+			return nil, fmt.Errorf("found %q, expected BY after PARTITION.", lit1)
+		}
+		for {
+			exp, err := p.ParseExpr()
+			if err != nil {
+				return nil, err
+			}
+			fr, ok := exp.(*ast.FieldRef)
+			if !ok {
+				return nil, fmt.Errorf("PARTITION BY of MATCH_RECOGNIZE only supports fields.")
+			}
+			mr.PartitionBy = append(mr.PartitionBy, fr)
+			if tok, _ := p.scanIgnoreWhitespace(); tok != ast.COMMA {
+				p.unscan()
+				break
+			}
+		}
+	} else {
+		p.unscan()
+	}
+	if !p.scanKeyword("MEASURES") {
+		_, lit := p.scanIgnoreWhitespace()
+		return nil, fmt.Errorf("found %q, expected MEASURES.", lit)
+	}
+	for {
+		exp, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if tok, lit := p.scanIgnoreWhitespace(); tok != ast.AS {
+			return nil, fmt.Errorf("found %q, expected AS after the measure.", lit)
+		}
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok != ast.IDENT {
+			return nil, fmt.Errorf("found %q, expected the measure name.", lit)
+		}
+		mr.Measures = append(mr.Measures, ast.Field{AName: lit, Expr: exp})
+		if tok, _ := p.scanIgnoreWhitespace(); tok != ast.COMMA {
+			p.unscan()
+			break
+		}
+	}
+	if p.scanKeyword("ONE") {
+		if !p.scanKeyword("ROW") || !p.scanKeyword("PER") || !p.scanKeyword("MATCH") {
+			return nil, fmt.Errorf("expected ONE ROW PER MATCH.")
+		}
+	}
+	if p.scanKeyword("AFTER") {
+		if !p.scanKeyword("MATCH") || !p.scanKeyword("SKIP") {
+			return nil, fmt.Errorf("expected AFTER MATCH SKIP.")
+		}
+		if p.scanKeyword("PAST") && p.scanKeyword("LAST") && p.scanKeyword("ROW") {
+			mr.AfterMatchSkip = ast.SKIP_PAST_LAST_ROW
+		} else if p.scanKeyword("TO") && p.scanKeyword("NEXT") && p.scanKeyword("ROW") {
+			mr.AfterMatchSkip = ast.SKIP_TO_NEXT_ROW
+		} else {
+			return nil, fmt.Errorf("expected PAST LAST ROW or TO NEXT ROW after AFTER MATCH SKIP.")
+		}
+	}
+	if !p.scanKeyword("PATTERN") {
+		_, lit := p.scanIgnoreWhitespace()
+		return nil, fmt.Errorf("found %q, expected PATTERN.", lit)
+	}
+	pattern, err := p.parsePattern()
+	if err != nil {
+		return nil, err
+	}
+	mr.Pattern = pattern
+	if p.scanKeyword("WITHIN") {
+		p.scanKeyword("INTERVAL")
+		w, err := p.parseInterval()
+		if err != nil {
+			return nil, err
+		}
+		mr.Within = int64(w.(*ast.IntegerLiteral).Val)
+	}
+	if p.scanKeyword("DEFINE") {
+		for {
+			tok, lit := p.scanIgnoreWhitespace()
+			if tok != ast.IDENT {
+				return nil, fmt.Errorf("found %q, expected the pattern variable to define.", lit)
+			}
+			if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 != ast.AS {
+				return nil, fmt.Errorf("found %q, expected AS after the pattern variable %s.", lit1, lit)
+			}
+			exp, err := p.ParseExpr()
+			if err != nil {
+				return nil, err
+			}
+			mr.Defines = append(mr.Defines, &ast.PatternDefine{Name: lit, Condition: exp})
+			if tok, _ := p.scanIgnoreWhitespace(); tok != ast.COMMA {
+				p.unscan()
+				break
+			}
+		}
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.RPAREN {
+		return nil, fmt.Errorf("found %q, expected ) to end MATCH_RECOGNIZE.", lit)
+	}
+	return mr, validateMatchRecognize(mr, src)
+}
+
+// parsePattern parses the pattern variables with the quantifiers *, +, ?, {n}, {n,} and {n,m}
+func (p *Parser) parsePattern() ([]*ast.PatternTerm, error) {
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.LPAREN {
+		return nil, fmt.Errorf("found %q, expected ( after PATTERN.", lit)
+	}
+	var terms []*ast.PatternTerm
+	for {
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok == ast.RPAREN {
+			break
+		}
+		if tok != ast.IDENT {
+			return nil, fmt.Errorf("found %q, expected the pattern variable.", lit)
+		}
+		t := &ast.PatternTerm{Name: lit, Min: 1, Max: 1}
+		switch tok1, _ := p.scanIgnoreWhitespace(); tok1 {
+		case ast.ASTERISK:
+			t.Min, t.Max = 0, -1
+		case ast.ADD:
+			t.Min, t.Max = 1, -1
+		case ast.QUESTION:
+			t.Min, t.Max = 0, 1
+		case ast.LBRACE:
+			tok2, lit2 := p.scanIgnoreWhitespace()
+			n, err := strconv.Atoi(lit2)
+			if tok2 != ast.INTEGER || err != nil {
+				return nil, fmt.Errorf("found %q, expected integer in the quantifier of %s.", lit2, t.Name)
+			}
+			t.Min, t.Max = n, n
+			if tok3, _ := p.scanIgnoreWhitespace(); tok3 == ast.COMMA {
+				t.Max = -1
+				if tok4, lit4 := p.scanIgnoreWhitespace(); tok4 == ast.INTEGER {
+					m, _ := strconv.Atoi(lit4)
+					t.Max = m
+				} else {
+					p.unscan()
+				}
+			} else {
+				p.unscan()
+			}
+			if tok3, lit3 := p.scanIgnoreWhitespace(); tok3 != ast.RBRACE {
+				return nil, fmt.Errorf("found %q, expected } to end the quantifier of %s.", lit3, t.Name)
+			}
+			if t.Max == 0 || (t.Max > 0 && t.Max < t.Min) {
+				return nil, fmt.Errorf("invalid quantifier {%d,%d} of %s.", t.Min, t.Max, t.Name)
+			}
+		default:
+			p.unscan()
+		}
+		terms = append(terms, t)
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("PATTERN must have at least one pattern variable.")
+	}
+	return terms, nil
+}
+
+func validateMatchRecognize(mr *ast.MatchRecognize, src *ast.Table) error {
+	vars := make(map[string]bool)
+	for _, t := range mr.Pattern {
+		vars[t.Name] = true
+	}
+	defined := make(map[string]bool)
+	for _, d := range mr.Defines {
+		if !vars[d.Name] {
+			return fmt.Errorf("pattern variable %s in DEFINE is not found in PATTERN.", d.Name)
+		}
+		if defined[d.Name] {
+			return fmt.Errorf("pattern variable %s is defined more than once.", d.Name)
+		}
+		defined[d.Name] = true
+	}
+	names := make(map[string]bool)
+	for _, n := range mr.OutputNames() {
+		if names[n] {
+			return fmt.Errorf("duplicate field %s in the output of MATCH_RECOGNIZE.", n)
+		}
+		names[n] = true
+	}
+	for _, f := range mr.PartitionBy {
+		if f.StreamName != ast.DefaultStream && f.StreamName != ast.StreamName(src.Name) && f.StreamName != ast.StreamName(src.Alias) {
+			return fmt.Errorf("PARTITION BY of MATCH_RECOGNIZE only supports the source fields but got %s.%s.", f.StreamName, f.Name)
+		}
+		f.StreamName = ast.DefaultStream
+	}
+	// the field refs must refer to the pattern variables or the source
+	var err error
+	check := func(n ast.Node) bool {
+		if f, ok := n.(*ast.FieldRef); ok && f.StreamName != ast.DefaultStream && !vars[string(f.StreamName)] {
+			if f.StreamName == ast.StreamName(src.Name) || f.StreamName == ast.StreamName(src.Alias) {
+				f.StreamName = ast.DefaultStream
+			} else {
+				err = fmt.Errorf("unknown pattern variable %s.", f.StreamName)
+				return false
+			}
+		}
+		return err == nil
+	}
+	for _, f := range mr.Measures {
+		ast.WalkFunc(f.Expr, check)
+	}
+	for _, d := range mr.Defines {
+		ast.WalkFunc(d.Condition, check)
+		if err == nil && HasAggFuncs(d.Condition) {
+			err = fmt.Errorf("Not allowed to call aggregate functions in DEFINE clause.")
+		}
+	}
+	return err
+}
+
 func (p *Parser) parseFieldNameSections(isSubField bool) ([]string, error) {
 	var fieldNameSects []string
 	for {
@@ -268,8 +580,11 @@ func (p *Parser) parseFieldNameSections(isSubField bool) ([]string, error) {
 	return fieldNameSects, nil
 }
 
-func (p *Parser) parseJoins() (ast.Joins, error) {
-	var joins ast.Joins
+func (p *Parser) parseJoins() (ast.Joins, []*ast.Unnest, error) {
+	var (
+		joins   ast.Joins
+		unnests []*ast.Unnest
+	)
 	for {
 		if tok, lit := p.scanIgnoreWhitespace(); tok == ast.INNER || tok == ast.LEFT || tok == ast.RIGHT || tok == ast.FULL || tok == ast.CROSS {
 			if tok1, _ := p.scanIgnoreWhitespace(); tok1 == ast.JOIN {
@@ -286,25 +601,101 @@ func (p *Parser) parseJoins() (ast.Joins, error) {
 				case ast.CROSS:
 					jt = ast.CROSS_JOIN
 				}
+				if u, err := p.parseUnnest(jt); err != nil {
+					return nil, nil, err
+				} else if u != nil {
+					unnests = append(unnests, u)
+					continue
+				}
 
 				if j, err := p.ParseJoin(jt); err != nil {
-					return nil, err
+					return nil, nil, err
 				} else {
 					joins = append(joins, *j)
 				}
 			} else {
-				return nil, fmt.Errorf("found %q, expected JOIN key word.", lit)
+				return nil, nil, fmt.Errorf("found %q, expected JOIN key word.", lit)
 			}
 		} else {
 			p.unscan()
 			if len(joins) > 0 {
-				return joins, nil
+				return joins, unnests, nil
 			}
-			return nil, nil
+			return nil, unnests, nil
 		}
 	}
 }
 
+// parseUnnest parses the lateral unnest after JOIN like UNNEST(expr) [WITH ORDINALITY] AS t(name [, ordinality])
+// or UNNEST(expr) AS name. It returns nil if the join source is not UNNEST
+func (p *Parser) parseUnnest(jt ast.JoinType) (*ast.Unnest, error) {
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.IDENT || !strings.EqualFold(lit, "UNNEST") {
+		p.unscan()
+		return nil, nil
+	}
+	if tok, _ := p.scanIgnoreWhitespace(); tok != ast.LPAREN {
+		p.unscan()
+		p.unscan()
+		return nil, nil
+	}
+	if jt != ast.CROSS_JOIN {
+		return nil, fmt.Errorf("UNNEST only supports CROSS JOIN.")
+	}
+	u := &ast.Unnest{}
+	exp, err := p.ParseExpr()
+	if err != nil {
+		return nil, err
+	}
+	u.Expr = exp
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.RPAREN {
+		return nil, fmt.Errorf("found %q, expected ) after UNNEST.", lit)
+	}
+	withOrdinality := false
+	if p.scanKeyword("WITH") {
+		if !p.scanKeyword("ORDINALITY") {
+			return nil, fmt.Errorf("expected ORDINALITY after WITH.")
+		}
+		withOrdinality = true
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.AS {
+		return nil, fmt.Errorf("found %q, expected AS after UNNEST.", lit)
+	}
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok != ast.IDENT {
+		return nil, fmt.Errorf("found %q, expected the name of UNNEST.", lit)
+	}
+	if tok1, _ := p.scanIgnoreWhitespace(); tok1 != ast.LPAREN {
+		p.unscan()
+		u.Name = lit
+	} else {
+		u.Alias = lit
+		var cols []string
+		for {
+			tok2, lit2 := p.scanIgnoreWhitespace()
+			if tok2 != ast.IDENT {
+				return nil, fmt.Errorf("found %q, expected the column name of UNNEST.", lit2)
+			}
+			cols = append(cols, lit2)
+			if tok3, lit3 := p.scanIgnoreWhitespace(); tok3 == ast.RPAREN {
+				break
+			} else if tok3 != ast.COMMA {
+				return nil, fmt.Errorf("found %q, expected ) after the column names of UNNEST.", lit3)
+			}
+		}
+		u.Name = cols[0]
+		if len(cols) > 1 {
+			u.Ordinality = cols[1]
+		}
+		if len(cols) > 2 || (len(cols) == 2) != withOrdinality {
+			return nil, fmt.Errorf("UNNEST must have one column name, and another one for the ordinality if WITH ORDINALITY is specified.")
+		}
+	}
+	if withOrdinality && u.Ordinality == "" {
+		return nil, fmt.Errorf("UNNEST must have one column name, and another one for the ordinality if WITH ORDINALITY is specified.")
+	}
+	return u, nil
+}
+
 func (p *Parser) ParseJoin(joinType ast.JoinType) (*ast.Join, error) {
 	j := &ast.Join{JoinType: joinType}
 	if src, alias, err := p.parseSourceLiteral(); err != nil {
@@ -598,7 +989,7 @@ func (p *Parser) ParseExpr() (ast.Expr, error) {
 }
 
 func (p *Parser) parseBetween(lhs ast.Expr, op ast.Token) (ast.Expr, error) {
-	alhs, err := p.parseUnaryExpr(false)
+	alhs, err := p.parseBetweenBound()
 	if err != nil {
 		return nil, err
 	}
@@ -606,7 +997,7 @@ func (p *Parser) parseBetween(lhs ast.Expr, op ast.Token) (ast.Expr, error) {
 	if opp != ast.AND {
 		return nil, fmt.Errorf("expect AND expression after between but found %s", opp)
 	}
-	arhs, err := p.parseUnaryExpr(false)
+	arhs, err := p.parseBetweenBound()
 	if err != nil {
 		return nil, err
 	}
@@ -620,6 +1011,40 @@ func (p *Parser) parseBetween(lhs ast.Expr, op ast.Token) (ast.Expr, error) {
 	}, nil
 }
 
+// parseBetweenBound parses the bound of between which can be an arithmetic expression such as a.ts - INTERVAL '5' SECOND
+func (p *Parser) parseBetweenBound() (ast.Expr, error) {
+	var err error
+	root := &ast.BinaryExpr{}
+	root.RHS, err = p.parseUnaryExpr(false)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, _ := p.scanIgnoreWhitespace()
+		if op == ast.ASTERISK {
+			op = ast.MUL
+		}
+		switch op {
+		case ast.ADD, ast.SUB, ast.MUL, ast.DIV, ast.MOD:
+		default:
+			p.unscan()
+			return root.RHS, nil
+		}
+		rhs, err := p.parseUnaryExpr(false)
+		if err != nil {
+			return nil, err
+		}
+		for node := root; ; {
+			r, ok := node.RHS.(*ast.BinaryExpr)
+			if !ok || r.OP.Precedence() >= op.Precedence() {
+				node.RHS = &ast.BinaryExpr{LHS: node.RHS, RHS: rhs, OP: op}
+				break
+			}
+			node = r
+		}
+	}
+}
+
 func (p *Parser) parseUnaryExpr(isSubField bool) (ast.Expr, error) {
 	if tok1, _ := p.scanIgnoreWhitespace(); tok1 == ast.LPAREN {
 		expr, err := p.ParseExpr()
@@ -644,6 +1069,13 @@ func (p *Parser) parseUnaryExpr(isSubField bool) (ast.Expr, error) {
 	if tok == ast.CASE {
 		return p.parseCaseExpr()
 	} else if tok == ast.IDENT {
+		if strings.EqualFold(lit, "INTERVAL") {
+			tok1, _ := p.scanIgnoreWhitespace()
+			p.unscan()
+			if tok1 == ast.STRING || tok1 == ast.INTEGER {
+				return p.parseInterval()
+			}
+		}
 		if tok1, _ := p.scanIgnoreWhitespace(); tok1 == ast.LPAREN {
 			return p.parseCall(lit)
 		}
@@ -712,6 +1144,37 @@ func (p *Parser) parseUnaryExpr(isSubField bool) (ast.Expr, error) {
 	return nil, fmt.Errorf("found %q, expected expression.", lit)
 }
 
+var intervalUnits = map[string]int{
+	"MILLISECOND": 1,
+	"MS":          1,
+	"SECOND":      1000,
+	"SS":          1000,
+	"MINUTE":      60 * 1000,
+	"MI":          60 * 1000,
+	"HOUR":        3600 * 1000,
+	"HH":          3600 * 1000,
+	"DAY":         24 * 3600 * 1000,
+	"DD":          24 * 3600 * 1000,
+}
+
+// parseInterval parses the interval such as INTERVAL '5' SECOND to the integer literal in milliseconds
+func (p *Parser) parseInterval() (ast.Expr, error) {
+	_, lit := p.scanIgnoreWhitespace()
+	val, err := strconv.Atoi(strings.TrimSpace(lit))
+	if err != nil {
+		return nil, fmt.Errorf("found %q, expected integer interval value.", lit)
+	}
+	_, unit := p.scanIgnoreWhitespace()
+	u, ok := intervalUnits[strings.TrimSuffix(strings.ToUpper(unit), "S")]
+	if !ok {
+		u, ok = intervalUnits[strings.ToUpper(unit)]
+	}
+	if !ok {
+		return nil, fmt.Errorf("found %q, expected interval unit of MILLISECOND, SECOND, MINUTE, HOUR or DAY.", unit)
+	}
+	return &ast.IntegerLiteral{Val: val * u}, nil
+}
+
 func (p *Parser) parseValueSetExpr() (ast.Expr, error) {
 	valsetExpr := &ast.ValueSetExpr{
 		LiteralExprs: nil,
@@ -896,7 +1359,7 @@ func (p *Parser) parseCall(n string) (ast.Expr, error) {
 			return nil, valErr
 		}
 		// Add context for some aggregate func
-		if name == "deduplicate" {
+		if name == "deduplicate" || name == "top_n" || name == "bottom_n" {
 			args = append([]ast.Expr{&ast.Wildcard{Token: ast.ASTERISK}}, args...)
 		}
 		c := &ast.Call{Name: name, Args: args, FuncId: p.fn, FuncType: ft}
@@ -918,6 +1381,9 @@ func (p *Parser) parseCall(n string) (ast.Expr, error) {
 		} else if f != nil {
 			win.Filter = f
 		}
+		if err := p.parseLateness(win); err != nil {
+			return nil, err
+		}
 		return win, nil
 	}
 }
@@ -988,6 +1454,17 @@ loop:
 func validateWindows(fname string, args []ast.Expr) (ast.WindowType, error) {
 	switch fname {
 	case "tumblingwindow":
+		// the optional time zone aligns the window to the local calendar
+		if len(args) == 3 {
+			tz, ok := args[2].(*ast.StringLiteral)
+			if !ok {
+				return ast.TUMBLING_WINDOW, fmt.Errorf("The 3rd argument for %s is expecting string literal expression of the time zone.\n", fname)
+			}
+			if _, err := time.LoadLocation(tz.Val); err != nil {
+				return ast.TUMBLING_WINDOW, fmt.Errorf("Invalid time zone %s for %s: %v.\n", tz.Val, fname, err)
+			}
+			args = args[:2]
+		}
 		if err := validateWindow(fname, 2, args); err != nil {
 			return ast.TUMBLING_WINDOW, err
 		}
@@ -998,6 +1475,15 @@ func validateWindows(fname string, args []ast.Expr) (ast.WindowType, error) {
 		}
 		return ast.HOPPING_WINDOW, nil
 	case "sessionwindow":
+		// the timeout can be an expression evaluated for each event
+		if len(args) == 3 {
+			if _, ok := args[2].(*ast.IntegerLiteral); !ok {
+				if err := validateWindow(fname, 2, args[:2]); err != nil {
+					return ast.SESSION_WINDOW, err
+				}
+				return ast.SESSION_WINDOW, nil
+			}
+		}
 		if err := validateWindow(fname, 3, args); err != nil {
 			return ast.SESSION_WINDOW, err
 		}
@@ -1037,8 +1523,10 @@ func validateWindow(funcName string, expectLen int, args []ast.Expr) error {
 	if len(args) != expectLen {
 		return fmt.Errorf("The arguments for %s should be %d.\n", funcName, expectLen)
 	}
-	if _, ok := args[0].(*ast.TimeLiteral); !ok {
+	if tl, ok := args[0].(*ast.TimeLiteral); !ok {
 		return fmt.Errorf("The 1st argument for %s is expecting timer literal expression. One value of [dd|hh|mi|ss|ms].\n", funcName)
+	} else if tl.Val == ast.MM && funcName != "tumblingwindow" {
+		return fmt.Errorf("The time unit mm is only supported by tumblingwindow.\n")
 	}
 
 	for i := 1; i < len(args); i++ {
@@ -1049,6 +1537,9 @@ func validateWindow(funcName string, expectLen int, args []ast.Expr) error {
 	return nil
 }
 
+// maxMonthMillis is the length of the longest month which is used as the max length of the monthly window
+const maxMonthMillis = 31 * 24 * 3600 * 1000
+
 func (p *Parser) ConvertToWindows(wtype ast.WindowType, args []ast.Expr) (*ast.Window, error) {
 	win := &ast.Window{WindowType: wtype}
 	if wtype == ast.COUNT_WINDOW {
@@ -1061,6 +1552,10 @@ func (p *Parser) ConvertToWindows(wtype ast.WindowType, args []ast.Expr) (*ast.W
 	unit := 1
 	v := args[0].(*ast.TimeLiteral).Val
 	switch v {
+	case ast.MM:
+		// the month length varies, so the window is always aligned to the calendar
+		win.Months = args[1].(*ast.IntegerLiteral).Val
+		unit = maxMonthMillis
 	case ast.DD:
 		unit = 24 * 3600 * 1000
 	case ast.HH:
@@ -1075,6 +1570,18 @@ func (p *Parser) ConvertToWindows(wtype ast.WindowType, args []ast.Expr) (*ast.W
 		return nil, fmt.Errorf("Invalid timeliteral %s", v)
 	}
 	win.Length = &ast.IntegerLiteral{Val: args[1].(*ast.IntegerLiteral).Val * unit}
+	if wtype == ast.TUMBLING_WINDOW && len(args) > 2 {
+		win.TimeZone = args[2].(*ast.StringLiteral).Val
+		args = args[:2]
+	}
+	if wtype == ast.SESSION_WINDOW {
+		if _, ok := args[2].(*ast.IntegerLiteral); !ok {
+			win.Interval = &ast.IntegerLiteral{Val: 0}
+			win.Gap = args[2]
+			win.GapUnit = unit
+			return win, nil
+		}
+	}
 	if len(args) > 2 {
 		win.Interval = &ast.IntegerLiteral{Val: args[2].(*ast.IntegerLiteral).Val * unit}
 	} else {
@@ -1139,6 +1646,10 @@ func validateStream(stmt *ast.StreamStmt) error {
 	lf := strings.ToLower(f)
 	switch lf {
 	case message.FormatBinary:
+		// the binary format with schema decodes the frame into the fields of the schema
+		if stmt.Options.SCHEMAID != "" {
+			break
+		}
 		if stmt.StreamType == ast.TypeTable {
 			return fmt.Errorf("'binary' format is not supported for table")
 		}
@@ -1535,6 +2046,44 @@ func (p *Parser) parseFilter() (ast.Expr, error) {
 	return expr, nil
 }
 
+// parseLateness parses the optional clause like ALLOWED LATENESS INTERVAL '5' SECOND SIDE OUTPUT 'late_topic'
+func (p *Parser) parseLateness(win *ast.Window) error {
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.IDENT || !strings.EqualFold(lit, "ALLOWED") {
+		p.unscan()
+		return nil
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.IDENT || !strings.EqualFold(lit, "LATENESS") {
+		return fmt.Errorf("found %q after ALLOWED, expect LATENESS.", lit)
+	}
+	if win.WindowType != ast.TUMBLING_WINDOW && win.WindowType != ast.HOPPING_WINDOW {
+		return fmt.Errorf("ALLOWED LATENESS is only supported by tumbling window and hopping window.")
+	}
+	if win.TimeZone != "" || win.Months > 0 {
+		return fmt.Errorf("ALLOWED LATENESS is not supported by the calendar window.")
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.IDENT || !strings.EqualFold(lit, "INTERVAL") {
+		p.unscan()
+	}
+	l, err := p.parseInterval()
+	if err != nil {
+		return err
+	}
+	win.AllowedLateness = l.(*ast.IntegerLiteral)
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.IDENT || !strings.EqualFold(lit, "SIDE") {
+		p.unscan()
+		return nil
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.IDENT || !strings.EqualFold(lit, "OUTPUT") {
+		return fmt.Errorf("found %q after SIDE, expect OUTPUT.", lit)
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.STRING || lit == "" {
+		return fmt.Errorf("found %q after SIDE OUTPUT, expect the topic string.", lit)
+	} else {
+		win.SideOutput = lit
+	}
+	return nil
+}
+
 func (p *Parser) parseAsterisk() (ast.Expr, error) {
 	switch p.inFunc {
 	case "mqtt", "meta":
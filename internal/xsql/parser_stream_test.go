@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -418,6 +418,19 @@ func TestParser_ParseCreateStream(t *testing.T) {
 				},
 			},
 		},
+		{
+			s: `CREATE STREAM demo (
+				) WITH (DATASOURCE="users", FORMAT="BINARY", SCHEMAID="frames.Telemetry");`,
+			stmt: &ast.StreamStmt{
+				Name:         ast.StreamName("demo"),
+				StreamFields: nil,
+				Options: &ast.Options{
+					DATASOURCE: "users",
+					FORMAT:     "BINARY",
+					SCHEMAID:   "frames.Telemetry",
+				},
+			},
+		},
 		{
 			s: `CREATE STREAM demo (
 				) WITH (DATASOURCE="users", FORMAT="DELIMITED", Delimiter=" ");`,
@@ -431,6 +444,20 @@ func TestParser_ParseCreateStream(t *testing.T) {
 				},
 			},
 		},
+		{
+			s: `CREATE STREAM demo (
+				) WITH (DATASOURCE="users", FORMAT="JSON", DECOMPRESSION="gzip", DECRYPTION="aes");`,
+			stmt: &ast.StreamStmt{
+				Name:         ast.StreamName("demo"),
+				StreamFields: nil,
+				Options: &ast.Options{
+					DATASOURCE:    "users",
+					FORMAT:        "JSON",
+					DECOMPRESSION: "gzip",
+					DECRYPTION:    "aes",
+				},
+			},
+		},
 	}
 
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
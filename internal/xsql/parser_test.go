@@ -633,6 +633,24 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		{
+			s: `SELECT top_n(temperature, 3) FROM tbl`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						AName: "",
+						Name:  "top_n",
+						Expr: &ast.Call{
+							Name:     "top_n",
+							Args:     []ast.Expr{&ast.Wildcard{Token: ast.ASTERISK}, &ast.FieldRef{Name: "temperature", StreamName: ast.DefaultStream}, &ast.IntegerLiteral{Val: 3}},
+							FuncType: ast.FuncTypeAgg,
+						},
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "tbl"}},
+			},
+		},
+
 		{
 			s: `SELECT "abc" FROM tbl`,
 			stmt: &ast.SelectStatement{
@@ -2280,6 +2298,103 @@ func TestParser_ParseWindowsExpr(t *testing.T) {
 			},
 		},
 
+		{
+			s: `SELECT f1 FROM tbl GROUP BY TUMBLINGWINDOW(ss, 10) ALLOWED LATENESS INTERVAL '5' SECOND SIDE OUTPUT 'late'`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.FieldRef{Name: "f1", StreamName: ast.DefaultStream},
+						Name:  "f1",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "tbl"}},
+				Dimensions: ast.Dimensions{
+					ast.Dimension{
+						Expr: &ast.Window{
+							WindowType:      ast.TUMBLING_WINDOW,
+							Length:          &ast.IntegerLiteral{Val: 10000},
+							Interval:        &ast.IntegerLiteral{Val: 0},
+							AllowedLateness: &ast.IntegerLiteral{Val: 5000},
+							SideOutput:      "late",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			s:    `SELECT f1 FROM tbl GROUP BY SLIDINGWINDOW(ss, 10) ALLOWED LATENESS INTERVAL '5' SECOND`,
+			stmt: nil,
+			err:  "ALLOWED LATENESS is only supported by tumbling window and hopping window.",
+		},
+
+		{
+			s: `SELECT f1 FROM tbl GROUP BY TUMBLINGWINDOW(dd, 1, 'Europe/Berlin')`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.FieldRef{Name: "f1", StreamName: ast.DefaultStream},
+						Name:  "f1",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "tbl"}},
+				Dimensions: ast.Dimensions{
+					ast.Dimension{
+						Expr: &ast.Window{
+							WindowType: ast.TUMBLING_WINDOW,
+							Length:     &ast.IntegerLiteral{Val: 8.64e7},
+							Interval:   &ast.IntegerLiteral{Val: 0},
+							TimeZone:   "Europe/Berlin",
+						},
+					},
+				},
+			},
+		},
+
+		{
+			s: `SELECT f1 FROM tbl GROUP BY TUMBLINGWINDOW(mm, 1)`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.FieldRef{Name: "f1", StreamName: ast.DefaultStream},
+						Name:  "f1",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "tbl"}},
+				Dimensions: ast.Dimensions{
+					ast.Dimension{
+						Expr: &ast.Window{
+							WindowType: ast.TUMBLING_WINDOW,
+							Length:     &ast.IntegerLiteral{Val: 2.6784e9},
+							Interval:   &ast.IntegerLiteral{Val: 0},
+							Months:     1,
+						},
+					},
+				},
+			},
+		},
+
+		{
+			s:    `SELECT f1 FROM tbl GROUP BY TUMBLINGWINDOW(dd, 1, 'Mars/Olympus')`,
+			stmt: nil,
+			err:  "Invalid time zone Mars/Olympus for tumblingwindow: unknown time zone Mars/Olympus.\n",
+		},
+
+		{
+			s:    `SELECT f1 FROM tbl GROUP BY HOPPINGWINDOW(mm, 2, 1)`,
+			stmt: nil,
+			err:  "The time unit mm is only supported by tumblingwindow.\n",
+		},
+
+		{
+			s:    `SELECT f1 FROM tbl GROUP BY TUMBLINGWINDOW(mm, 1, 'Asia/Shanghai') ALLOWED LATENESS INTERVAL '5' SECOND`,
+			stmt: nil,
+			err:  "ALLOWED LATENESS is not supported by the calendar window.",
+		},
+
 		{
 			s: `SELECT f1 FROM tbl GROUP BY HOPPINGWINDOW(mi, 5, 1)`,
 			stmt: &ast.SelectStatement{
@@ -2326,6 +2441,52 @@ func TestParser_ParseWindowsExpr(t *testing.T) {
 			},
 		},
 
+		{
+			s: `SELECT f1 FROM tbl GROUP BY f2, SESSIONWINDOW(ss, 60, CASE WHEN f3 = 'slow' THEN 10 ELSE 2 END)`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.FieldRef{Name: "f1", StreamName: ast.DefaultStream},
+						Name:  "f1",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "tbl"}},
+				Dimensions: ast.Dimensions{
+					ast.Dimension{
+						Expr: &ast.FieldRef{Name: "f2", StreamName: ast.DefaultStream},
+					},
+					ast.Dimension{
+						Expr: &ast.Window{
+							WindowType: ast.SESSION_WINDOW,
+							Length:     &ast.IntegerLiteral{Val: 6e4},
+							Interval:   &ast.IntegerLiteral{Val: 0},
+							Gap: &ast.CaseExpr{
+								WhenClauses: []*ast.WhenClause{
+									{
+										Expr: &ast.BinaryExpr{
+											OP:  ast.EQ,
+											LHS: &ast.FieldRef{Name: "f3", StreamName: ast.DefaultStream},
+											RHS: &ast.StringLiteral{Val: "slow"},
+										},
+										Result: &ast.IntegerLiteral{Val: 10},
+									},
+								},
+								ElseClause: &ast.IntegerLiteral{Val: 2},
+							},
+							GapUnit: 1000,
+						},
+					},
+				},
+			},
+		},
+
+		{
+			s:    `SELECT f1 FROM tbl GROUP BY SESSIONWINDOW(ss, f2, f3)`,
+			stmt: nil,
+			err:  "The 1 argument for sessionwindow is expecting interger literal expression. \n",
+		},
+
 		{
 			s: `SELECT f1 FROM tbl GROUP BY SLIDINGWINDOW(ms, 5)`,
 			stmt: &ast.SelectStatement{
@@ -2529,6 +2690,264 @@ func TestParser_ParseWindowsExpr(t *testing.T) {
 	}
 }
 
+func TestParser_ParseMatchRecognize(t *testing.T) {
+	tests := []struct {
+		s    string
+		stmt *ast.SelectStatement
+		err  string
+	}{
+		{
+			s: `SELECT * FROM demo MATCH_RECOGNIZE (PARTITION BY deviceId MEASURES A.temp AS startTemp, avg(B.temp) AS avgTemp ONE ROW PER MATCH AFTER MATCH SKIP TO NEXT ROW PATTERN (A B+ C? D{2,3}) WITHIN INTERVAL '10' SECOND DEFINE A AS temp > 10, B AS temp > A.temp) WHERE startTemp > 20`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.Wildcard{Token: ast.ASTERISK},
+						Name:  "*",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				MatchRecognize: &ast.MatchRecognize{
+					PartitionBy: []*ast.FieldRef{{StreamName: ast.DefaultStream, Name: "deviceId"}},
+					Measures: ast.Fields{
+						{AName: "startTemp", Expr: &ast.FieldRef{StreamName: "A", Name: "temp"}},
+						{AName: "avgTemp", Expr: &ast.Call{Name: "avg", Args: []ast.Expr{&ast.FieldRef{StreamName: "B", Name: "temp"}}, FuncType: ast.FuncTypeAgg}},
+					},
+					AfterMatchSkip: ast.SKIP_TO_NEXT_ROW,
+					Pattern: []*ast.PatternTerm{
+						{Name: "A", Min: 1, Max: 1},
+						{Name: "B", Min: 1, Max: -1},
+						{Name: "C", Min: 0, Max: 1},
+						{Name: "D", Min: 2, Max: 3},
+					},
+					Within: 10000,
+					Defines: []*ast.PatternDefine{
+						{
+							Name: "A",
+							Condition: &ast.BinaryExpr{
+								OP:  ast.GT,
+								LHS: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "temp"},
+								RHS: &ast.IntegerLiteral{Val: 10},
+							},
+						},
+						{
+							Name: "B",
+							Condition: &ast.BinaryExpr{
+								OP:  ast.GT,
+								LHS: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "temp"},
+								RHS: &ast.FieldRef{StreamName: "A", Name: "temp"},
+							},
+						},
+					},
+				},
+				Condition: &ast.BinaryExpr{
+					OP:  ast.GT,
+					LHS: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "startTemp"},
+					RHS: &ast.IntegerLiteral{Val: 20},
+				},
+			},
+		},
+		{
+			s: `SELECT * FROM demo AS d MATCH_RECOGNIZE (MEASURES d.temp AS t PATTERN (A*))`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.Wildcard{Token: ast.ASTERISK},
+						Name:  "*",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo", Alias: "d"}},
+				MatchRecognize: &ast.MatchRecognize{
+					Measures: ast.Fields{
+						{AName: "t", Expr: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "temp"}},
+					},
+					Pattern: []*ast.PatternTerm{{Name: "A", Min: 0, Max: -1}},
+				},
+			},
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (MEASURES X.temp AS t PATTERN (A))`,
+			err: "unknown pattern variable X.",
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (MEASURES A.temp AS t PATTERN (A) DEFINE B AS temp > 1)`,
+			err: "pattern variable B in DEFINE is not found in PATTERN.",
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (MEASURES A.temp AS t PATTERN (A{3,2}))`,
+			err: "invalid quantifier {3,2} of A.",
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (MEASURES A.temp PATTERN (A))`,
+			err: "found \"PATTERN\", expected AS after the measure.",
+		},
+		{
+			s:   `SELECT * FROM demo MATCH_RECOGNIZE (MEASURES A.temp AS t PATTERN (A)) GROUP BY TUMBLINGWINDOW(ss, 1)`,
+			err: "MATCH_RECOGNIZE cannot be used with JOIN or GROUP BY.",
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := NewParser(strings.NewReader(tt.s)).Parse()
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.s, tt.err, err)
+		} else if tt.err == "" && !reflect.DeepEqual(tt.stmt, stmt) {
+			t.Errorf("%d. %q\n\nstmt mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.s, tt.stmt, stmt)
+		}
+	}
+}
+
+func TestParser_ParseUnnest(t *testing.T) {
+	tests := []struct {
+		s    string
+		stmt *ast.SelectStatement
+		err  string
+	}{
+		{
+			s: `SELECT id, t.v, t.i FROM demo CROSS JOIN UNNEST(demo.arr) WITH ORDINALITY AS t(v, i) WHERE t.v > 1`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{Expr: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "id"}, Name: "id"},
+					{Expr: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "v"}, Name: "v"},
+					{Expr: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "i"}, Name: "i"},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				Unnests: []*ast.Unnest{
+					{
+						Expr:       &ast.FieldRef{StreamName: "demo", Name: "arr"},
+						Alias:      "t",
+						Name:       "v",
+						Ordinality: "i",
+					},
+				},
+				Condition: &ast.BinaryExpr{
+					OP:  ast.GT,
+					LHS: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "v"},
+					RHS: &ast.IntegerLiteral{Val: 1},
+				},
+			},
+		},
+		{
+			s: `SELECT v FROM demo CROSS JOIN UNNEST(obj->arr) AS v`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{Expr: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "v"}, Name: "v"},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				Unnests: []*ast.Unnest{
+					{
+						Expr: &ast.BinaryExpr{
+							OP:  ast.ARROW,
+							LHS: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "obj"},
+							RHS: &ast.JsonFieldRef{Name: "arr"},
+						},
+						Name: "v",
+					},
+				},
+			},
+		},
+		{
+			s:   `SELECT v FROM demo LEFT JOIN UNNEST(arr) AS v`,
+			err: "UNNEST only supports CROSS JOIN.",
+		},
+		{
+			s:   `SELECT v FROM demo CROSS JOIN UNNEST(arr) WITH ORDINALITY AS t(v)`,
+			err: "UNNEST must have one column name, and another one for the ordinality if WITH ORDINALITY is specified.",
+		},
+		{
+			s:   `SELECT v FROM demo CROSS JOIN UNNEST(arr) AS t(v, i)`,
+			err: "UNNEST must have one column name, and another one for the ordinality if WITH ORDINALITY is specified.",
+		},
+		{
+			s:   `SELECT v FROM demo CROSS JOIN UNNEST(arr) WITH ORDINALITY AS v`,
+			err: "UNNEST must have one column name, and another one for the ordinality if WITH ORDINALITY is specified.",
+		},
+		{
+			s:   `SELECT v FROM demo CROSS JOIN UNNEST(arr) AS v INNER JOIN table1 ON demo.id = table1.id`,
+			err: "UNNEST cannot be used with other joins.",
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := NewParser(strings.NewReader(tt.s)).Parse()
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.s, tt.err, err)
+		} else if tt.err == "" && !reflect.DeepEqual(tt.stmt, stmt) {
+			t.Errorf("%d. %q\n\nstmt mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.s, tt.stmt, stmt)
+		}
+	}
+}
+
+func TestParser_ParseDeduplicate(t *testing.T) {
+	tests := []struct {
+		s    string
+		stmt *ast.SelectStatement
+		err  string
+	}{
+		{
+			s: `SELECT * FROM demo DEDUPLICATE BY (demo.id, meta(topic)) WITHIN INTERVAL '10' MINUTE WHERE temp > 20`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.Wildcard{Token: ast.ASTERISK},
+						Name:  "*",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				Deduplicate: &ast.Deduplicate{
+					Keys: []ast.Expr{
+						&ast.FieldRef{StreamName: "demo", Name: "id"},
+						&ast.Call{Name: "meta", Args: []ast.Expr{&ast.MetaRef{StreamName: ast.DefaultStream, Name: "topic"}}},
+					},
+					TTL: 600000,
+				},
+				Condition: &ast.BinaryExpr{
+					OP:  ast.GT,
+					LHS: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "temp"},
+					RHS: &ast.IntegerLiteral{Val: 20},
+				},
+			},
+		},
+		{
+			s: `SELECT id FROM demo AS d DEDUPLICATE BY (id) WITHIN 500 MILLISECOND`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{Expr: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "id"}, Name: "id"},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo", Alias: "d"}},
+				Deduplicate: &ast.Deduplicate{
+					Keys: []ast.Expr{&ast.FieldRef{StreamName: ast.DefaultStream, Name: "id"}},
+					TTL:  500,
+				},
+			},
+		},
+		{
+			s:   `SELECT * FROM demo DEDUPLICATE BY (id)`,
+			err: "found \"EOF\", expected WITHIN after DEDUPLICATE BY.",
+		},
+		{
+			s:   `SELECT * FROM demo DEDUPLICATE (id) WITHIN INTERVAL '1' SECOND`,
+			err: "found \"(\", expected BY after DEDUPLICATE.",
+		},
+		{
+			s:   `SELECT * FROM demo DEDUPLICATE BY (id) WITHIN INTERVAL '0' SECOND`,
+			err: "the interval of DEDUPLICATE BY must be positive.",
+		},
+		{
+			s:   `SELECT * FROM demo DEDUPLICATE BY (id) WITHIN INTERVAL '1' SECOND INNER JOIN table1 ON demo.id = table1.id`,
+			err: "DEDUPLICATE BY cannot be used with JOIN.",
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := NewParser(strings.NewReader(tt.s)).Parse()
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.s, tt.err, err)
+		} else if tt.err == "" && !reflect.DeepEqual(tt.stmt, stmt) {
+			t.Errorf("%d. %q\n\nstmt mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.s, tt.stmt, stmt)
+		}
+	}
+}
+
 func TestParser_ParseJsonExpr(t *testing.T) {
 	tests := []struct {
 		s    string
@@ -3249,6 +3668,44 @@ func TestParser_ParseJoins(t *testing.T) {
 				},
 			},
 		},
+		{
+			s: `SELECT * FROM demo AS a INNER JOIN demo2 AS b ON b.ts BETWEEN a.ts - INTERVAL '5' SECOND AND a.ts + INTERVAL 1 MINUTES`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.Wildcard{Token: ast.ASTERISK},
+						Name:  "*",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo", Alias: "a"}},
+				Joins: []ast.Join{
+					{
+						Name: "demo2", Alias: "b", JoinType: ast.INNER_JOIN, Expr: &ast.BinaryExpr{
+							LHS: &ast.FieldRef{StreamName: ast.StreamName("b"), Name: "ts"},
+							OP:  ast.BETWEEN,
+							RHS: &ast.BetweenExpr{
+								Lower: &ast.BinaryExpr{
+									LHS: &ast.FieldRef{StreamName: ast.StreamName("a"), Name: "ts"},
+									OP:  ast.SUB,
+									RHS: &ast.IntegerLiteral{Val: 5000},
+								},
+								Higher: &ast.BinaryExpr{
+									LHS: &ast.FieldRef{StreamName: ast.StreamName("a"), Name: "ts"},
+									OP:  ast.ADD,
+									RHS: &ast.IntegerLiteral{Val: 60000},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			s:    `SELECT * FROM demo AS a INNER JOIN demo2 AS b ON b.ts BETWEEN a.ts - INTERVAL '5' WEEK AND a.ts`,
+			stmt: nil,
+			err:  "found \"WEEK\", expected interval unit of MILLISECOND, SECOND, MINUTE, HOUR or DAY.",
+		},
 	}
 
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
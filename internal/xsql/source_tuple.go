@@ -22,6 +22,8 @@ import (
 
 type ErrorSourceTuple struct {
 	Error error `json:"error"`
+	// Payload is the raw data failing to decode, which is sent to the dead letter queue
+	Payload []byte `json:"payload,omitempty"`
 }
 
 func (t *ErrorSourceTuple) Message() map[string]interface{} {
@@ -33,6 +33,18 @@ func Validate(stmt *ast.SelectStatement) error {
 		}
 	}
 
+	if stmt.Deduplicate != nil && len(stmt.Joins) > 0 {
+		return fmt.Errorf("DEDUPLICATE BY cannot be used with JOIN.")
+	}
+
+	if len(stmt.Unnests) > 0 && len(stmt.Joins) > 0 {
+		return fmt.Errorf("UNNEST cannot be used with other joins.")
+	}
+
+	if stmt.MatchRecognize != nil && (len(stmt.Joins) > 0 || len(stmt.Dimensions) > 0) {
+		return fmt.Errorf("MATCH_RECOGNIZE cannot be used with JOIN or GROUP BY.")
+	}
+
 	if err := validateSRFNestedForbidden("select", stmt.Fields); err != nil {
 		return err
 	}
@@ -123,6 +135,29 @@ func validateFields(stmt *ast.SelectStatement, streamNames []string) {
 	for i, join := range stmt.Joins {
 		stmt.Joins[i].Expr = validateExpr(join.Expr, streamNames)
 	}
+	for _, u := range stmt.Unnests {
+		u.Expr = validateExpr(u.Expr, streamNames)
+	}
+	if stmt.Deduplicate != nil {
+		for i, k := range stmt.Deduplicate.Keys {
+			stmt.Deduplicate.Keys[i] = validateExpr(k, streamNames)
+		}
+	}
+	// the columns of unnest are set to the rows of the source
+	if len(stmt.Unnests) > 0 {
+		aliases := make(map[ast.StreamName]bool, len(stmt.Unnests))
+		for _, u := range stmt.Unnests {
+			if u.Alias != "" {
+				aliases[ast.StreamName(u.Alias)] = true
+			}
+		}
+		ast.WalkFunc(stmt, func(n ast.Node) bool {
+			if f, ok := n.(*ast.FieldRef); ok && aliases[f.StreamName] {
+				f.StreamName = ast.DefaultStream
+			}
+			return true
+		})
+	}
 }
 
 // validateExpr checks if the streamName of a fieldRef is existed and covert it to json filed if not exist.
@@ -238,5 +273,11 @@ func getStreamNames(stmt *ast.SelectStatement) (result []string) {
 			result = append(result, join.Alias)
 		}
 	}
+
+	for _, u := range stmt.Unnests {
+		if u.Alias != "" {
+			result = append(result, u.Alias)
+		}
+	}
 	return
 }
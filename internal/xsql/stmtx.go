@@ -54,6 +54,30 @@ func GetStatementFromSql(sql string) (*ast.SelectStatement, error) {
 	}
 }
 
+// ParseError is the syntax error of the sql with the position where the parser stops
+type ParseError struct {
+	Pos
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at line %d, column %d", e.Message, e.Line, e.Column)
+}
+
+// ParseSelectStatement parses the sql like GetStatementFromSql but returns the ParseError with the position
+func ParseSelectStatement(sql string) (*ast.SelectStatement, error) {
+	parser := NewParser(strings.NewReader(sql))
+	stmt, err := Language.Parse(parser)
+	if err != nil {
+		return nil, &ParseError{Pos: parser.Pos(), Message: strings.TrimSpace(err.Error())}
+	}
+	r, ok := stmt.(*ast.SelectStatement)
+	if !ok || r == nil {
+		return nil, &ParseError{Pos: Pos{Line: 1, Column: 1}, Message: "not a select statement"}
+	}
+	return r, nil
+}
+
 type StreamInfo struct {
 	StreamType ast.StreamType `json:"streamType"`
 	StreamKind string         `json:"streamKind"`
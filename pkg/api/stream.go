@@ -117,6 +117,21 @@ type Sink interface {
 	Closable
 }
 
+// TwoPhaseCommitSink is a sink which can deliver the data exactly once by two-phase commit. The data collected
+// between two checkpoint barriers form a transaction. The hooks are only called when the sink property `exactlyOnce`
+// is set and the rule qos is exactly once.
+type TwoPhaseCommitSink interface {
+	Sink
+	// PreCommit is called when the checkpoint barrier arrives. It seals the data collected since the previous barrier as
+	// the transaction of the checkpoint, and must make the transaction durable, for example by putting it into the state
+	PreCommit(ctx StreamContext, checkpointId int64) error
+	// Commit is called when the checkpoint is completed, to make the transaction visible. It must be idempotent
+	// because it is called again for the pre-committed transactions when the rule restores from the checkpoint
+	Commit(ctx StreamContext, checkpointId int64) error
+	// Abort discards the pre-committed transaction when it cannot be committed
+	Abort(ctx StreamContext, checkpointId int64) error
+}
+
 type Emitter interface {
 	AddOutput(chan<- interface{}, string) error
 }
@@ -137,8 +152,11 @@ type Rewindable interface {
 type RuleOption struct {
 	IsEventTime        bool             `json:"isEventTime" yaml:"isEventTime"`
 	LateTol            int64            `json:"lateTolerance" yaml:"lateTolerance"`
+	WatermarkByKey     bool             `json:"watermarkByKey" yaml:"watermarkByKey"`
+	IdleTimeout        int64            `json:"idleTimeout" yaml:"idleTimeout"`
 	Concurrency        int              `json:"concurrency" yaml:"concurrency"`
 	BufferLength       int              `json:"bufferLength" yaml:"bufferLength"`
+	BackPressure       bool             `json:"backPressure" yaml:"backPressure"`
 	SendMetaToSink     bool             `json:"sendMetaToSink" yaml:"sendMetaToSink"`
 	SendError          bool             `json:"sendError" yaml:"sendError"`
 	Qos                Qos              `json:"qos" yaml:"qos"`
@@ -146,6 +164,11 @@ type RuleOption struct {
 	Restart            *RestartStrategy `json:"restartStrategy" yaml:"restartStrategy"`
 	Cron               string           `json:"cron" yaml:"cron"`
 	Duration           string           `json:"duration" yaml:"duration"`
+	Quota              *RuleQuota       `json:"quota,omitempty" yaml:"quota,omitempty"`
+	AutoScale          *AutoScale       `json:"autoScale,omitempty" yaml:"autoScale,omitempty"`
+	StateBackend       *StateBackend    `json:"stateBackend,omitempty" yaml:"stateBackend,omitempty"`
+	// DeadLetter is the sink action like {"mqtt": {...}} to receive the data failing to decode, process or sink
+	DeadLetter map[string]interface{} `json:"deadLetter,omitempty" yaml:"deadLetter,omitempty"`
 }
 
 type RestartStrategy struct {
@@ -156,6 +179,50 @@ type RestartStrategy struct {
 	JitterFactor float64 `json:"jitter" yaml:"jitter"`
 }
 
+// AutoScale adjusts the concurrency of the stateless operators between the concurrency option and MaxConcurrency
+type AutoScale struct {
+	// MaxConcurrency is the max instances of each stateless operator
+	MaxConcurrency int `json:"maxConcurrency" yaml:"maxConcurrency"`
+	// Interval is the milliseconds to check the load of the operators
+	Interval int `json:"interval" yaml:"interval"`
+	// MaxCpu is the cpu usage percentage above which the operators will not scale up
+	MaxCpu float64 `json:"maxCpu" yaml:"maxCpu"`
+}
+
+// StateBackend decides where to keep the large states of the operators such as the window buffers
+type StateBackend struct {
+	// Type is memory or disk, the default is memory
+	Type string `json:"type" yaml:"type"`
+	// SpillThreshold is the count of the tuples kept in memory by each window, the older tuples are spilled to disk
+	SpillThreshold int `json:"spillThreshold" yaml:"spillThreshold"`
+}
+
+const (
+	StateBackendMemory = "memory"
+	StateBackendDisk   = "disk"
+)
+
+// RuleQuota limits the resources used by a rule so that a single rule cannot exhaust the whole node
+type RuleQuota struct {
+	// MaxBufferedTuples is the max tuples buffered by each source instance, 0 means the default buffer length
+	MaxBufferedTuples int `json:"maxBufferedTuples" yaml:"maxBufferedTuples"`
+	// MaxWindowMemory is the max estimated bytes of the tuples kept by each window, 0 means unlimited
+	MaxWindowMemory int64 `json:"maxWindowMemory" yaml:"maxWindowMemory"`
+	// MaxEventsPerSecond throttles each source instance to limit the cpu usage, 0 means unlimited
+	MaxEventsPerSecond int `json:"maxEventsPerSecond" yaml:"maxEventsPerSecond"`
+	// Policy is the action when the buffer or the window memory exceeds the quota
+	Policy string `json:"policy" yaml:"policy"`
+}
+
+const (
+	// QuotaPolicyPauseSource stops reading the source until the buffer is consumed. It is the default policy.
+	QuotaPolicyPauseSource = "pauseSource"
+	// QuotaPolicyDropOldest drops the oldest tuples to make room for the new ones
+	QuotaPolicyDropOldest = "dropOldest"
+	// QuotaPolicyStopRule stops the rule with an error
+	QuotaPolicyStopRule = "stopRule"
+)
+
 type PrintableTopo struct {
 	Sources []string                 `json:"sources"`
 	Edges   map[string][]interface{} `json:"edges"`
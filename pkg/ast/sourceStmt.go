@@ -235,6 +235,10 @@ type Options struct {
 	KIND string `json:"kind,omitempty"`
 	// for delimited format only
 	DELIMITER string `json:"delimiter,omitempty"`
+	// the compression method to decompress the payload before decoding
+	DECOMPRESSION string `json:"decompression,omitempty"`
+	// the algorithm to decrypt the payload before decompressing
+	DECRYPTION string `json:"decryption,omitempty"`
 
 	Schema map[string]*JsonStreamField `json:"-"`
 }
@@ -27,6 +27,12 @@ type SelectStatement struct {
 	Dimensions Dimensions
 	Having     Expr
 	SortFields SortFields
+	// MatchRecognize is the pattern recognition on the rows of the source
+	MatchRecognize *MatchRecognize
+	// Unnests are the lateral unnest of the array fields by CROSS JOIN UNNEST
+	Unnests []*Unnest
+	// Deduplicate drops the duplicate rows of the source
+	Deduplicate *Deduplicate
 
 	Statement
 }
@@ -131,6 +137,26 @@ type Joins []Join
 
 func (j Joins) node() {}
 
+// Unnest expands the array of each row into multiple rows. Each element is set to the field Name, and its position
+// starting from 1 is set to the field Ordinality if WITH ORDINALITY is specified
+type Unnest struct {
+	Expr       Expr
+	Alias      string
+	Name       string
+	Ordinality string
+
+	Node
+}
+
+// Deduplicate drops the rows whose key evaluated by the Keys is the same as a previous row within the TTL in
+// milliseconds from the first row of the key
+type Deduplicate struct {
+	Keys []Expr
+	TTL  int64
+
+	Node
+}
+
 type Dimension struct {
 	Expr Expr
 
@@ -176,9 +202,70 @@ type Window struct {
 	Length     *IntegerLiteral
 	Interval   *IntegerLiteral
 	Filter     Expr
+	// Gap is the timeout of the session window evaluated for each event, it is set instead of the Interval
+	Gap Expr
+	// GapUnit is the milliseconds of the time unit of the Gap
+	GapUnit int
+	// AllowedLateness is the time in milliseconds to keep the fired windows to update them by the late events
+	AllowedLateness *IntegerLiteral
+	// SideOutput is the memory topic to send the events later than the allowed lateness
+	SideOutput string
+	// TimeZone is the location name to align the tumbling window to the local calendar such as the local midnight
+	TimeZone string
+	// Months is the size of the monthly tumbling window, the Length is the longest possible length in this case
+	Months int
 	Expr
 }
 
+type SkipType int
+
+const (
+	SKIP_PAST_LAST_ROW SkipType = iota
+	SKIP_TO_NEXT_ROW
+)
+
+// MatchRecognize finds the sequences of rows matching the pattern in each partition and returns a row
+// of the partition fields and the measures for each match
+type MatchRecognize struct {
+	PartitionBy []*FieldRef
+	// Measures must have alias names. The field refs with the stream name of a pattern variable refer to
+	// the last row mapped to the variable
+	Measures Fields
+	// AfterMatchSkip is where to resume the matching after a match is found
+	AfterMatchSkip SkipType
+	Pattern        []*PatternTerm
+	// Within is the max time in milliseconds from the first row to the last row of a match, 0 means no limit
+	Within int64
+	// Defines are the conditions of the pattern variables. The undefined variables match any row
+	Defines []*PatternDefine
+
+	Node
+}
+
+// PatternTerm is a pattern variable with its quantifier. Max is -1 if unbounded
+type PatternTerm struct {
+	Name string
+	Min  int
+	Max  int
+}
+
+type PatternDefine struct {
+	Name      string
+	Condition Expr
+}
+
+// OutputNames returns the field names of the rows emitted by the pattern recognition
+func (m *MatchRecognize) OutputNames() []string {
+	r := make([]string, 0, len(m.PartitionBy)+len(m.Measures))
+	for _, p := range m.PartitionBy {
+		r = append(r, p.Name)
+	}
+	for _, f := range m.Measures {
+		r = append(r, f.AName)
+	}
+	return r
+}
+
 type SortField struct {
 	Name       string
 	StreamName StreamName
@@ -79,6 +79,9 @@ const (
 	COLON     //:
 	SEMICOLON //;
 	COLSEP    //\007
+	QUESTION  // ?
+	LBRACE    // {
+	RBRACE    // }
 
 	// Keywords
 	SELECT
@@ -114,6 +117,7 @@ const (
 	MI
 	SS
 	MS
+	MM
 )
 
 var Tokens = []string{
@@ -158,6 +162,9 @@ var Tokens = []string{
 	SEMICOLON: ";",
 	COLON:     ":",
 	COLSEP:    "\007",
+	QUESTION:  "?",
+	LBRACE:    "{",
+	RBRACE:    "}",
 
 	SELECT:    "SELECT",
 	FROM:      "FROM",
@@ -196,6 +203,7 @@ var Tokens = []string{
 	MI: "MI",
 	SS: "SS",
 	MS: "MS",
+	MM: "MM",
 }
 
 const (
@@ -224,6 +232,8 @@ const (
 	SCHEMAID          = "SCHEMAID"
 	KIND              = "KIND"
 	DELIMITER         = "DELIMITER"
+	DECOMPRESSION     = "DECOMPRESSION"
+	DECRYPTION        = "DECRYPTION"
 
 	XBIGINT   = "BIGINT"
 	XFLOAT    = "FLOAT"
@@ -249,6 +259,8 @@ var StreamTokens = map[string]struct{}{
 	SCHEMAID:          {},
 	KIND:              {},
 	DELIMITER:         {},
+	DECOMPRESSION:     {},
+	DECRYPTION:        {},
 }
 
 var StreamDataTypes = map[string]DataType{
@@ -281,7 +293,7 @@ func (tok Token) IsOperator() bool {
 	return (tok > operatorBeg && tok < operatorEnd) || tok == ASTERISK || tok == LBRACKET || tok == DOT
 }
 
-func (tok Token) IsTimeLiteral() bool { return tok >= DD && tok <= MS }
+func (tok Token) IsTimeLiteral() bool { return tok >= DD && tok <= MM }
 
 func (tok Token) AllowedSourceToken() bool {
 	return tok == IDENT || tok == DIV || tok == HASH || tok == ADD
@@ -36,6 +36,10 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.Fields)
 		Walk(v, n.Sources)
 		Walk(v, n.Joins)
+		for _, u := range n.Unnests {
+			Walk(v, u)
+		}
+		Walk(v, n.Deduplicate)
 		Walk(v, n.Condition)
 		Walk(v, n.Dimensions)
 		Walk(v, n.Having)
@@ -67,6 +71,14 @@ func Walk(v Visitor, node Node) {
 	case *Join:
 		Walk(v, n.Expr)
 
+	case *Unnest:
+		Walk(v, n.Expr)
+
+	case *Deduplicate:
+		for _, k := range n.Keys {
+			Walk(v, k)
+		}
+
 	case Dimensions:
 		Walk(v, n.GetWindow())
 		for _, dimension := range n.GetGroups() {
@@ -77,6 +89,7 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.Length)
 		Walk(v, n.Interval)
 		Walk(v, n.Filter)
+		Walk(v, n.Gap)
 
 	case SortFields:
 		for _, sf := range n {
@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -14,12 +14,17 @@
 
 package message
 
+import "fmt"
+
 const (
 	FormatBinary    = "binary"
 	FormatJson      = "json"
 	FormatProtobuf  = "protobuf"
 	FormatDelimited = "delimited"
 	FormatCustom    = "custom"
+	FormatAvro      = "avro"
+	FormatCbor      = "cbor"
+	FormatMsgpack   = "msgpack"
 
 	DefaultField = "self"
 	MetaKey      = "__meta"
@@ -27,7 +32,7 @@ const (
 
 func IsFormatSupported(format string) bool {
 	switch format {
-	case FormatBinary, FormatJson, FormatProtobuf, FormatCustom, FormatDelimited:
+	case FormatBinary, FormatJson, FormatProtobuf, FormatCustom, FormatDelimited, FormatAvro, FormatCbor, FormatMsgpack:
 		return true
 	default:
 		return false
@@ -44,6 +49,32 @@ type ColumnSetter interface {
 	SetColumns([]string)
 }
 
+// PropsConfigurer is implemented by the converters which read the format properties from the source or sink
+// properties, such as the header and the column types of the delimited format
+type PropsConfigurer interface {
+	Configure(props map[string]interface{}) error
+}
+
+// MalformedRow is the raw data of a row which cannot be decoded
+type MalformedRow struct {
+	Data []byte
+	Err  error
+}
+
+// PartialDecodeError is returned by the converters which decode a payload into multiple rows when some rows are
+// malformed and should be sent to the dead letter queue. The Result is the decoded rows.
+type PartialDecodeError struct {
+	Result    interface{}
+	Malformed []*MalformedRow
+}
+
+func (e *PartialDecodeError) Error() string {
+	if len(e.Malformed) == 0 {
+		return "no malformed row"
+	}
+	return fmt.Sprintf("%d malformed rows, the first error: %v", len(e.Malformed), e.Malformed[0].Err)
+}
+
 type SchemaProvider interface {
 	GetSchemaJson() string
 }
@@ -56,3 +87,12 @@ type Compressor interface {
 type Decompressor interface {
 	Decompress([]byte) ([]byte, error)
 }
+
+// Encryptor encrypts the encoded payload
+type Encryptor interface {
+	Encrypt([]byte) ([]byte, error)
+}
+
+type Decryptor interface {
+	Decrypt([]byte) ([]byte, error)
+}
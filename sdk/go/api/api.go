@@ -61,6 +61,23 @@ type Function interface {
 	IsAggregate() bool
 }
 
+// AggregateFunction is the user-defined aggregate function computed in phases. For each group such as a window, an
+// accumulator is created, and then the arguments of each row are accumulated into it. The result is got from the
+// accumulator at last. The accumulators of the partial groups can be merged. The accumulator must be a pointer which
+// can be encoded by JSON, so that it can be serialized as the state between the phases
+type AggregateFunction interface {
+	// The argument is a list of xsql.Expr
+	Validate(args []interface{}) error
+	// CreateAccumulator creates an empty accumulator for a group
+	CreateAccumulator(ctx FunctionContext) interface{}
+	// Accumulate adds the arguments of a row to the accumulator
+	Accumulate(acc interface{}, args []interface{}, ctx FunctionContext) error
+	// Merge merges the other accumulator into acc
+	Merge(acc interface{}, other interface{}, ctx FunctionContext) error
+	// Result returns the aggregate result of the accumulator
+	Result(acc interface{}, ctx FunctionContext) (interface{}, error)
+}
+
 type Sink interface {
 	// Should be sync function for normal case. The container will run it in go func
 	Open(ctx StreamContext) error
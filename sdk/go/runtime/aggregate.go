@@ -0,0 +1,138 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/sdk/go/api"
+)
+
+// aggregateFunc runs the aggregate function in phases as an aggregate function whose arguments are the slices of
+// the values of the group. The phases can also be run separately with the serialized accumulator state
+type aggregateFunc struct {
+	f api.AggregateFunction
+}
+
+// WrapAggregateFunction wraps the aggregate function in phases to be registered as a function
+func WrapAggregateFunction(f api.AggregateFunction) api.Function {
+	return &aggregateFunc{f: f}
+}
+
+func (a *aggregateFunc) Validate(args []interface{}) error {
+	return a.f.Validate(args)
+}
+
+func (a *aggregateFunc) Exec(args []interface{}, ctx api.FunctionContext) (interface{}, bool) {
+	acc := a.f.CreateAccumulator(ctx)
+	if err := a.accumulate(acc, args, ctx); err != nil {
+		return err, false
+	}
+	r, err := a.f.Result(acc, ctx)
+	if err != nil {
+		return err, false
+	}
+	return r, true
+}
+
+func (a *aggregateFunc) IsAggregate() bool {
+	return true
+}
+
+// accumulate adds all rows of the group to the accumulator. Each argument is the slice of the values of the rows
+func (a *aggregateFunc) accumulate(acc interface{}, args []interface{}, ctx api.FunctionContext) error {
+	n := -1
+	cols := make([][]interface{}, len(args))
+	for i, arg := range args {
+		col, ok := arg.([]interface{})
+		if !ok {
+			return fmt.Errorf("the argument %d of aggregate function must be a slice but got %v", i, arg)
+		}
+		if n >= 0 && len(col) != n {
+			return fmt.Errorf("the arguments of aggregate function must have the same length")
+		}
+		n = len(col)
+		cols[i] = col
+	}
+	for j := 0; j < n; j++ {
+		row := make([]interface{}, len(cols))
+		for i, col := range cols {
+			row[i] = col[j]
+		}
+		if err := a.f.Accumulate(acc, row, ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPhase runs a phase with the serialized states. Accumulate has the state followed by the arguments like Exec
+// and returns the new state. Merge has two states and returns the merged state. Result has the state and returns
+// the result. An empty state is the empty accumulator
+func (a *aggregateFunc) runPhase(phase string, args []interface{}, ctx api.FunctionContext) (interface{}, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("the state of %s is not found", phase)
+	}
+	acc, err := a.decodeState(args[0], ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch phase {
+	case "Accumulate":
+		if err := a.accumulate(acc, args[1:], ctx); err != nil {
+			return nil, err
+		}
+		return a.encodeState(acc)
+	case "Merge":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("merge requires 2 states but got %d", len(args))
+		}
+		other, err := a.decodeState(args[1], ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.f.Merge(acc, other, ctx); err != nil {
+			return nil, err
+		}
+		return a.encodeState(acc)
+	case "Result":
+		return a.f.Result(acc, ctx)
+	default:
+		return nil, fmt.Errorf("invalid phase %s", phase)
+	}
+}
+
+func (a *aggregateFunc) encodeState(acc interface{}) (string, error) {
+	bs, err := json.Marshal(acc)
+	if err != nil {
+		return "", fmt.Errorf("fail to serialize the accumulator: %v", err)
+	}
+	return string(bs), nil
+}
+
+func (a *aggregateFunc) decodeState(state interface{}, ctx api.FunctionContext) (interface{}, error) {
+	s, ok := state.(string)
+	if !ok {
+		return nil, fmt.Errorf("the state must be a string but got %v", state)
+	}
+	acc := a.f.CreateAccumulator(ctx)
+	if s != "" {
+		if err := json.Unmarshal([]byte(s), acc); err != nil {
+			return nil, fmt.Errorf("fail to deserialize the accumulator: %v", err)
+		}
+	}
+	return acc, nil
+}
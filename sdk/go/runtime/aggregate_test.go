@@ -0,0 +1,131 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/sdk/go/api"
+	"github.com/lf-edge/ekuiper/sdk/go/mock"
+)
+
+type avgAcc struct {
+	Sum   float64 `json:"sum"`
+	Count int     `json:"count"`
+}
+
+// avgFunc is the average of the numbers by phases
+type avgFunc struct{}
+
+func (f *avgFunc) Validate(args []interface{}) error {
+	if len(args) != 1 {
+		return fmt.Errorf("avg function only supports 1 parameter but got %d", len(args))
+	}
+	return nil
+}
+
+func (f *avgFunc) CreateAccumulator(_ api.FunctionContext) interface{} {
+	return &avgAcc{}
+}
+
+func (f *avgFunc) Accumulate(acc interface{}, args []interface{}, _ api.FunctionContext) error {
+	a := acc.(*avgAcc)
+	switch v := args[0].(type) {
+	case nil:
+	case int:
+		a.Sum += float64(v)
+		a.Count++
+	case float64:
+		a.Sum += v
+		a.Count++
+	default:
+		return fmt.Errorf("avg function requires number but got %v", v)
+	}
+	return nil
+}
+
+func (f *avgFunc) Merge(acc interface{}, other interface{}, _ api.FunctionContext) error {
+	a, o := acc.(*avgAcc), other.(*avgAcc)
+	a.Sum += o.Sum
+	a.Count += o.Count
+	return nil
+}
+
+func (f *avgFunc) Result(acc interface{}, _ api.FunctionContext) (interface{}, error) {
+	a := acc.(*avgAcc)
+	if a.Count == 0 {
+		return nil, nil
+	}
+	return a.Sum / float64(a.Count), nil
+}
+
+func TestAggregateFuncExec(t *testing.T) {
+	f := WrapAggregateFunction(&avgFunc{})
+	if !f.IsAggregate() {
+		t.Errorf("should be aggregate function")
+	}
+	tests := []mock.FuncTest{
+		{
+			Args:   []interface{}{[]interface{}{1, 2.5, nil, 4.5}},
+			Result: float64(8) / 3,
+			Ok:     true,
+		}, {
+			Args:   []interface{}{[]interface{}{}},
+			Result: nil,
+			Ok:     true,
+		}, {
+			Args:   []interface{}{[]interface{}{1, "a"}},
+			Result: fmt.Errorf("avg function requires number but got a"),
+			Ok:     false,
+		}, {
+			Args:   []interface{}{1},
+			Result: fmt.Errorf("the argument 0 of aggregate function must be a slice but got 1"),
+			Ok:     false,
+		},
+	}
+	mock.TestFuncExec(f, tests, t)
+}
+
+func TestAggregateFuncPhases(t *testing.T) {
+	f := WrapAggregateFunction(&avgFunc{}).(*aggregateFunc)
+	s1, err := f.runPhase("Accumulate", []interface{}{"", []interface{}{1, 2}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(`{"sum":3,"count":2}`, s1) {
+		t.Errorf("state mismatch, expect %s but got %v", `{"sum":3,"count":2}`, s1)
+	}
+	s2, err := f.runPhase("Accumulate", []interface{}{`{"sum":4,"count":1}`, []interface{}{5.0}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := f.runPhase("Merge", []interface{}{s1, s2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := f.runPhase("Result", []interface{}{s}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(float64(3), r) {
+		t.Errorf("result mismatch, expect 3 but got %v", r)
+	}
+	_, err = f.runPhase("Merge", []interface{}{s1}, nil)
+	if err == nil || err.Error() != "merge requires 2 states but got 1" {
+		t.Errorf("expect merge error but got %v", err)
+	}
+}
@@ -86,6 +86,24 @@ func (s *funcRuntime) run() {
 		case "IsAggregate":
 			result := s.s.IsAggregate()
 			return encodeReply(true, result)
+		case "Accumulate", "Merge", "Result":
+			af, ok := s.s.(*aggregateFunc)
+			if !ok {
+				return encodeReply(false, fmt.Sprintf("func %s is not supported by non phased function", d.Func))
+			}
+			arg, ok := d.Arg.([]interface{})
+			if !ok {
+				return encodeReply(false, "argument is not interface array")
+			}
+			farg, fctx, err := parseFuncContextArgs(arg)
+			if err != nil {
+				return encodeReply(false, err.Error())
+			}
+			r, err := af.runPhase(d.Func, farg, fctx)
+			if err != nil {
+				return encodeReply(false, err.Error())
+			}
+			return encodeReply(true, r)
 		default:
 			return encodeReply(false, fmt.Sprintf("invalid func %s", d.Func))
 		}
@@ -35,3 +35,43 @@ class Function(object):
     def is_aggregate(self):
         """callback to check if function is for aggregation, return bool"""
         pass
+
+
+class AggregateFunction(Function):
+    """abstract class for eKuiper aggregate function plugin computed in phases. For each group such as a window,
+    an accumulator is created and the arguments of each row are accumulated into it, then the result is got
+    from the accumulator. The accumulators of the partial groups can be merged. The accumulator must be
+    serializable by json to be saved as the state between the phases"""
+
+    @abstractmethod
+    def create_accumulator(self, ctx: Context) -> Any:
+        """callback to create an empty accumulator for a group"""
+        pass
+
+    @abstractmethod
+    def accumulate(self, acc: Any, args: List[Any], ctx: Context) -> Any:
+        """callback to add the arguments of a row to the accumulator, return the updated accumulator"""
+        pass
+
+    @abstractmethod
+    def merge(self, acc: Any, other: Any, ctx: Context) -> Any:
+        """callback to merge the other accumulator into acc, return the merged accumulator"""
+        pass
+
+    @abstractmethod
+    def result(self, acc: Any, ctx: Context) -> Any:
+        """callback to get the aggregate result of the accumulator"""
+        pass
+
+    def accumulate_rows(self, acc: Any, args: List[Any], ctx: Context) -> Any:
+        """add all rows of the group to the accumulator, each argument is the list of the values of the rows"""
+        for row in zip(*args):
+            acc = self.accumulate(acc, list(row), ctx)
+        return acc
+
+    def exec(self, args: List[Any], ctx: Context) -> Any:
+        acc = self.accumulate_rows(self.create_accumulator(ctx), args, ctx)
+        return self.result(acc, ctx)
+
+    def is_aggregate(self):
+        return True
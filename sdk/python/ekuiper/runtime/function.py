@@ -20,7 +20,7 @@ from . import reg
 from .connection import PairChannel
 from .contextimpl import ContextImpl
 from .symbol import SymbolRuntime
-from ..function import Function
+from ..function import Function, AggregateFunction
 
 
 class FunctionRuntime(SymbolRuntime):
@@ -62,16 +62,8 @@ class FunctionRuntime(SymbolRuntime):
                 if isinstance(args, list) is False or len(args) < 1:
                     return encode_reply(False, 'invalid arg')
                 fmeta = json.loads(args[-1])
-                if 'ruleId' in fmeta and 'opId' in fmeta and 'instanceId' in fmeta \
-                        and 'funcId' in fmeta:
-                    key = f"{fmeta['ruleId']}_{fmeta['opId']}_{fmeta['instanceId']}" \
-                          f"_{fmeta['funcId']}"
-                    if key in self.funcs:
-                        fctx = self.funcs[key]
-                    else:
-                        fctx = ContextImpl(fmeta)
-                        self.funcs[key] = fctx
-                else:
+                fctx = self.get_context(fmeta)
+                if fctx is None:
                     return encode_reply(False,
                                         f'invalid arg: {fmeta} ruleId, opId, instanceId and funcId'
                                         f' are required')
@@ -80,6 +72,19 @@ class FunctionRuntime(SymbolRuntime):
             elif name == "IsAggregate":
                 r = self.s.is_aggregate()
                 return encode_reply(True, r)
+            elif name in ("Accumulate", "Merge", "Result"):
+                if not isinstance(self.s, AggregateFunction):
+                    return encode_reply(False, f"func {name} is not supported by non phased function")
+                args = c['arg']
+                if isinstance(args, list) is False or len(args) < 2:
+                    return encode_reply(False, 'invalid arg')
+                fmeta = json.loads(args[-1])
+                fctx = self.get_context(fmeta)
+                if fctx is None:
+                    return encode_reply(False,
+                                        f'invalid arg: {fmeta} ruleId, opId, instanceId and funcId'
+                                        f' are required')
+                return encode_reply(True, self.run_phase(name, args[:-1], fctx))
             else:
                 return encode_reply(False, "invalid func {}".format(name))
         except Exception:
@@ -89,6 +94,36 @@ class FunctionRuntime(SymbolRuntime):
                 logging.error(traceback.format_exc())
                 return encode_reply(False, traceback.format_exc())
 
+    def get_context(self, fmeta: dict):
+        if 'ruleId' in fmeta and 'opId' in fmeta and 'instanceId' in fmeta \
+                and 'funcId' in fmeta:
+            key = f"{fmeta['ruleId']}_{fmeta['opId']}_{fmeta['instanceId']}" \
+                  f"_{fmeta['funcId']}"
+            if key not in self.funcs:
+                self.funcs[key] = ContextImpl(fmeta)
+            return self.funcs[key]
+        return None
+
+    def run_phase(self, name: str, args: list, fctx):
+        """run a phase with the json serialized states. Accumulate has the state followed by the arguments
+        like Exec and returns the new state. Merge has two states and returns the merged state. Result has
+        the state and returns the result. An empty state is the empty accumulator"""
+
+        def decode(state: str):
+            if state == "":
+                return self.s.create_accumulator(fctx)
+            return json.loads(state)
+
+        acc = decode(args[0])
+        if name == "Accumulate":
+            return json.dumps(self.s.accumulate_rows(acc, args[1:], fctx))
+        elif name == "Merge":
+            if len(args) != 2:
+                raise ValueError(f"merge requires 2 states but got {len(args)}")
+            return json.dumps(self.s.merge(acc, decode(args[1]), fctx))
+        else:
+            return self.s.result(acc, fctx)
+
     def stop(self):
         self.running = False
         # noinspection PyBroadException